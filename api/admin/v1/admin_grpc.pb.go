@@ -0,0 +1,254 @@
+// Management API for fleet-provisioning backends. Mirrors the HTTP admin API
+// under /admin and adds a lifecycle event stream. Clients authenticate with
+// mutual TLS; the server requires a client certificate signed by the
+// configured CA. See docs/grpc-admin.md.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v4.25.3
+// source: api/admin/v1/admin.proto
+
+package adminv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	DeviceAdmin_ListDeviceCodes_FullMethodName  = "/wrale.deviceproxy.admin.v1.DeviceAdmin/ListDeviceCodes"
+	DeviceAdmin_RevokeDeviceCode_FullMethodName = "/wrale.deviceproxy.admin.v1.DeviceAdmin/RevokeDeviceCode"
+	DeviceAdmin_GetFlowStatus_FullMethodName    = "/wrale.deviceproxy.admin.v1.DeviceAdmin/GetFlowStatus"
+	DeviceAdmin_StreamEvents_FullMethodName     = "/wrale.deviceproxy.admin.v1.DeviceAdmin/StreamEvents"
+)
+
+// DeviceAdminClient is the client API for DeviceAdmin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DeviceAdminClient interface {
+	// ListDeviceCodes returns the outstanding device codes of a client
+	ListDeviceCodes(ctx context.Context, in *ListDeviceCodesRequest, opts ...grpc.CallOption) (*ListDeviceCodesResponse, error)
+	// RevokeDeviceCode ends a pending flow so its device receives access_denied
+	RevokeDeviceCode(ctx context.Context, in *RevokeDeviceCodeRequest, opts ...grpc.CallOption) (*RevokeDeviceCodeResponse, error)
+	// GetFlowStatus reports the state of the flow for a device or user code
+	GetFlowStatus(ctx context.Context, in *GetFlowStatusRequest, opts ...grpc.CallOption) (*FlowStatus, error)
+	// StreamEvents delivers lifecycle events as they are emitted, the same
+	// events sent to the webhook
+	StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type deviceAdminClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDeviceAdminClient(cc grpc.ClientConnInterface) DeviceAdminClient {
+	return &deviceAdminClient{cc}
+}
+
+func (c *deviceAdminClient) ListDeviceCodes(ctx context.Context, in *ListDeviceCodesRequest, opts ...grpc.CallOption) (*ListDeviceCodesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListDeviceCodesResponse)
+	err := c.cc.Invoke(ctx, DeviceAdmin_ListDeviceCodes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceAdminClient) RevokeDeviceCode(ctx context.Context, in *RevokeDeviceCodeRequest, opts ...grpc.CallOption) (*RevokeDeviceCodeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RevokeDeviceCodeResponse)
+	err := c.cc.Invoke(ctx, DeviceAdmin_RevokeDeviceCode_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceAdminClient) GetFlowStatus(ctx context.Context, in *GetFlowStatusRequest, opts ...grpc.CallOption) (*FlowStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FlowStatus)
+	err := c.cc.Invoke(ctx, DeviceAdmin_GetFlowStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *deviceAdminClient) StreamEvents(ctx context.Context, in *StreamEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &DeviceAdmin_ServiceDesc.Streams[0], DeviceAdmin_StreamEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DeviceAdmin_StreamEventsClient = grpc.ServerStreamingClient[Event]
+
+// DeviceAdminServer is the server API for DeviceAdmin service.
+// All implementations must embed UnimplementedDeviceAdminServer
+// for forward compatibility.
+type DeviceAdminServer interface {
+	// ListDeviceCodes returns the outstanding device codes of a client
+	ListDeviceCodes(context.Context, *ListDeviceCodesRequest) (*ListDeviceCodesResponse, error)
+	// RevokeDeviceCode ends a pending flow so its device receives access_denied
+	RevokeDeviceCode(context.Context, *RevokeDeviceCodeRequest) (*RevokeDeviceCodeResponse, error)
+	// GetFlowStatus reports the state of the flow for a device or user code
+	GetFlowStatus(context.Context, *GetFlowStatusRequest) (*FlowStatus, error)
+	// StreamEvents delivers lifecycle events as they are emitted, the same
+	// events sent to the webhook
+	StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedDeviceAdminServer()
+}
+
+// UnimplementedDeviceAdminServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedDeviceAdminServer struct{}
+
+func (UnimplementedDeviceAdminServer) ListDeviceCodes(context.Context, *ListDeviceCodesRequest) (*ListDeviceCodesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListDeviceCodes not implemented")
+}
+func (UnimplementedDeviceAdminServer) RevokeDeviceCode(context.Context, *RevokeDeviceCodeRequest) (*RevokeDeviceCodeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeDeviceCode not implemented")
+}
+func (UnimplementedDeviceAdminServer) GetFlowStatus(context.Context, *GetFlowStatusRequest) (*FlowStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFlowStatus not implemented")
+}
+func (UnimplementedDeviceAdminServer) StreamEvents(*StreamEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedDeviceAdminServer) mustEmbedUnimplementedDeviceAdminServer() {}
+func (UnimplementedDeviceAdminServer) testEmbeddedByValue()                     {}
+
+// UnsafeDeviceAdminServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DeviceAdminServer will
+// result in compilation errors.
+type UnsafeDeviceAdminServer interface {
+	mustEmbedUnimplementedDeviceAdminServer()
+}
+
+func RegisterDeviceAdminServer(s grpc.ServiceRegistrar, srv DeviceAdminServer) {
+	// If the following call pancis, it indicates UnimplementedDeviceAdminServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&DeviceAdmin_ServiceDesc, srv)
+}
+
+func _DeviceAdmin_ListDeviceCodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListDeviceCodesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceAdminServer).ListDeviceCodes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceAdmin_ListDeviceCodes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceAdminServer).ListDeviceCodes(ctx, req.(*ListDeviceCodesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceAdmin_RevokeDeviceCode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeDeviceCodeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceAdminServer).RevokeDeviceCode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceAdmin_RevokeDeviceCode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceAdminServer).RevokeDeviceCode(ctx, req.(*RevokeDeviceCodeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceAdmin_GetFlowStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFlowStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DeviceAdminServer).GetFlowStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: DeviceAdmin_GetFlowStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DeviceAdminServer).GetFlowStatus(ctx, req.(*GetFlowStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DeviceAdmin_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DeviceAdminServer).StreamEvents(m, &grpc.GenericServerStream[StreamEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type DeviceAdmin_StreamEventsServer = grpc.ServerStreamingServer[Event]
+
+// DeviceAdmin_ServiceDesc is the grpc.ServiceDesc for DeviceAdmin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var DeviceAdmin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "wrale.deviceproxy.admin.v1.DeviceAdmin",
+	HandlerType: (*DeviceAdminServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListDeviceCodes",
+			Handler:    _DeviceAdmin_ListDeviceCodes_Handler,
+		},
+		{
+			MethodName: "RevokeDeviceCode",
+			Handler:    _DeviceAdmin_RevokeDeviceCode_Handler,
+		},
+		{
+			MethodName: "GetFlowStatus",
+			Handler:    _DeviceAdmin_GetFlowStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _DeviceAdmin_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/admin/v1/admin.proto",
+}
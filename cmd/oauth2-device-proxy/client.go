@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/pow"
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+)
+
+// deviceCodeGrantType is the grant type for device access token requests per RFC 8628 section 3.4
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// clientOptions configures the reference device client
+type clientOptions struct {
+	proxyURL string
+	clientID string
+	scope    string
+	deviceID string
+	qr       templates.QRRenderer // Prints verification_uri_complete as a QR code, omitted if nil
+	http     *http.Client
+	stdout   io.Writer
+}
+
+// runClient implements the "client" subcommand, which performs the full device
+// flow against a running proxy and prints the resulting token response
+func runClient(args []string) error {
+	fs := flag.NewFlagSet("client", flag.ContinueOnError)
+	opts := clientOptions{http: &http.Client{Timeout: 30 * time.Second}, stdout: os.Stdout}
+	fs.StringVar(&opts.proxyURL, "url", "http://localhost:8080", "Base URL of the device proxy")
+	fs.StringVar(&opts.clientID, "client-id", "", "OAuth client identifier (required)")
+	fs.StringVar(&opts.scope, "scope", "", "Space-separated scopes to request")
+	fs.StringVar(&opts.deviceID, "device-id", "", "Device identifier sent as device_id")
+	showQR := fs.Bool("qr", true, "Print verification_uri_complete as a QR code")
+	qrFormat := fs.String("qr-format", templates.QRFormatTerminal, "QR code format: terminal, or ascii for light backgrounds and plain text logs")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *showQR {
+		qr, err := templates.NewQRRenderer(*qrFormat)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(qr.ContentType(), "text/plain") {
+			return fmt.Errorf("-qr-format %s cannot be printed, use %s or %s", *qrFormat, templates.QRFormatTerminal, templates.QRFormatASCII)
+		}
+		opts.qr = qr
+	}
+	if opts.clientID == "" {
+		fs.Usage()
+		return errors.New("-client-id is required")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	token, err := runDeviceFlow(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(opts.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(token)
+}
+
+// runDeviceFlow requests a device code, shows the user instructions and polls
+// the token endpoint until the flow completes per RFC 8628 sections 3.1-3.5
+func runDeviceFlow(ctx context.Context, opts clientOptions) (*deviceflow.TokenResponse, error) {
+	base := strings.TrimSuffix(opts.proxyURL, "/")
+
+	form := url.Values{"client_id": {opts.clientID}}
+	if opts.scope != "" {
+		form.Set("scope", opts.scope)
+	}
+	if opts.deviceID != "" {
+		form.Set("device_id", opts.deviceID)
+	}
+	var code deviceflow.DeviceCode
+	err := postForm(ctx, opts.http, base+"/device/code", form, &code)
+	var challenge *powChallengeError
+	if errors.As(err, &challenge) {
+		// Solve the proof of work the proxy requires and ask again
+		nonce, err := pow.Solve(ctx, challenge.Challenge, challenge.Difficulty)
+		if err != nil {
+			return nil, fmt.Errorf("solving proof of work: %w", err)
+		}
+		form.Set("pow_challenge", challenge.Challenge)
+		form.Set("pow_nonce", nonce)
+		err = postForm(ctx, opts.http, base+"/device/code", form, &code)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("requesting device code: %w", err)
+	}
+
+	printInstructions(opts.stdout, &code, opts.qr)
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second // Default per RFC 8628 section 3.2
+	}
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	form = url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {code.DeviceCode},
+		"client_id":   {opts.clientID},
+	}
+	for {
+		if time.Now().After(deadline) {
+			return nil, deviceflow.ErrExpiredCode
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		var token deviceflow.TokenResponse
+		err := postForm(ctx, opts.http, base+"/device/token", form, &token)
+		if err == nil {
+			return &token, nil
+		}
+
+		dfe, ok := deviceflow.AsDeviceFlowError(err)
+		if !ok {
+			return nil, fmt.Errorf("polling for token: %w", err)
+		}
+		switch dfe.Code {
+		case deviceflow.ErrorCodeAuthorizationPending:
+			// Keep polling at the current interval
+		case deviceflow.ErrorCodeSlowDown:
+			interval += 5 * time.Second // Required by RFC 8628 section 3.5
+		default:
+			return nil, dfe
+		}
+	}
+}
+
+// printInstructions tells the user where to enter the code per RFC 8628 section 3.3
+func printInstructions(w io.Writer, code *deviceflow.DeviceCode, qr templates.QRRenderer) {
+	fmt.Fprintf(w, "To sign in, visit %s and enter the code:\n\n    %s\n\n", code.VerificationURI, code.UserCode)
+
+	if qr != nil && code.VerificationURIComplete != "" {
+		if art, err := templates.RenderQRCode(qr, code.VerificationURIComplete); err == nil {
+			fmt.Fprintln(w, "Or scan this QR code:")
+			w.Write(art)
+			fmt.Fprintln(w)
+		}
+	}
+
+	fmt.Fprintln(w, "Waiting for authorization...")
+}
+
+// powChallengeError is a device code request error asking for proof of work
+type powChallengeError struct {
+	*deviceflow.DeviceFlowError
+	Challenge  string `json:"pow_challenge"`
+	Difficulty int    `json:"pow_difficulty"`
+}
+
+// postForm posts a form and decodes a successful JSON response into out.
+// Error responses are returned as DeviceFlowError values.
+func postForm(ctx context.Context, client *http.Client, endpoint string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var dfe deviceflow.DeviceFlowError
+		if err := json.Unmarshal(body, &dfe); err != nil || dfe.Code == "" {
+			return fmt.Errorf("unexpected response: %s", resp.Status)
+		}
+		var challenge powChallengeError
+		if err := json.Unmarshal(body, &challenge); err == nil && challenge.Challenge != "" {
+			challenge.DeviceFlowError = &dfe
+			return &challenge
+		}
+		return &dfe
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("parsing response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+func TestRunDeviceFlow(t *testing.T) {
+	polls := 0
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device/code", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.FormValue("client_id"); got != "tv" {
+			t.Errorf("client_id = %q, want tv", got)
+		}
+		json.NewEncoder(w).Encode(deviceflow.DeviceCode{
+			DeviceCode:      "dev-123",
+			UserCode:        "ABCD-EFGH",
+			VerificationURI: "https://proxy.example/device",
+			ExpiresIn:       600,
+			Interval:        1,
+		})
+	})
+	mux.HandleFunc("/device/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != deviceCodeGrantType || r.FormValue("device_code") != "dev-123" {
+			t.Errorf("unexpected token request %v", r.Form)
+		}
+		polls++
+		if polls == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(deviceflow.ErrPendingAuthorization)
+			return
+		}
+		json.NewEncoder(w).Encode(deviceflow.TokenResponse{AccessToken: "at", TokenType: "Bearer"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var out bytes.Buffer
+	token, err := runDeviceFlow(context.Background(), clientOptions{
+		proxyURL: srv.URL,
+		clientID: "tv",
+		http:     srv.Client(),
+		stdout:   &out,
+	})
+	if err != nil {
+		t.Fatalf("runDeviceFlow() error = %v", err)
+	}
+	if token.AccessToken != "at" || polls != 2 {
+		t.Errorf("token = %+v after %d polls, want at after 2", token, polls)
+	}
+	if !strings.Contains(out.String(), "ABCD-EFGH") {
+		t.Errorf("instructions missing user code:\n%s", out.String())
+	}
+}
+
+func TestRunDeviceFlowTerminalError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device/code", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(deviceflow.DeviceCode{DeviceCode: "dev-123", ExpiresIn: 600, Interval: 1})
+	})
+	mux.HandleFunc("/device/token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(deviceflow.ErrAccessDenied)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	_, err := runDeviceFlow(context.Background(), clientOptions{
+		proxyURL: srv.URL,
+		clientID: "tv",
+		http:     srv.Client(),
+		stdout:   &bytes.Buffer{},
+	})
+	if dfe, ok := deviceflow.AsDeviceFlowError(err); !ok || dfe.Code != deviceflow.ErrorCodeAccessDenied {
+		t.Errorf("runDeviceFlow() error = %v, want access_denied", err)
+	}
+}
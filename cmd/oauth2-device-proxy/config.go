@@ -4,19 +4,273 @@ import "time"
 
 // Config holds server configuration loaded from environment variables
 type Config struct {
-	Port              int           `envconfig:"PORT" default:"8080"`
-	RedisURL          string        `envconfig:"REDIS_URL" required:"true"`
-	KeycloakURL       string        `envconfig:"KEYCLOAK_URL" required:"true"`
-	KeycloakRealm     string        `envconfig:"KEYCLOAK_REALM" required:"true"`
-	KeycloakClientID  string        `envconfig:"KEYCLOAK_CLIENT_ID" required:"true"`
-	CodeExpiry        time.Duration `envconfig:"CODE_EXPIRY" default:"15m"`
-	PollInterval      time.Duration `envconfig:"POLL_INTERVAL" default:"5s"`
-	MaxPollsPerMinute int           `envconfig:"MAX_POLLS_PER_MINUTE" default:"12"`
-	BaseURL           string        `envconfig:"BASE_URL" required:"true"`
+	Port                int           `envconfig:"PORT" default:"8080"`
+	RedisURL            string        `envconfig:"REDIS_URL" required:"true"`
+	RedisKeyPrefix      string        `envconfig:"REDIS_KEY_PREFIX"`      // Namespace for all keys, e.g. staging:
+	StoreEncryptionKeys string        `envconfig:"STORE_ENCRYPTION_KEYS"` // Comma-separated id:base64 AES keys, current first; encrypts tokens at rest
+	KeycloakURL         string        `envconfig:"KEYCLOAK_URL" required:"true"`
+	KeycloakRealm       string        `envconfig:"KEYCLOAK_REALM" required:"true"`
+	KeycloakClientID    string        `envconfig:"KEYCLOAK_CLIENT_ID" required:"true"`
+	CodeExpiry          time.Duration `envconfig:"CODE_EXPIRY" default:"15m"`
+	MaxCodeExpiry       time.Duration `envconfig:"MAX_CODE_EXPIRY" default:"30m"` // Startup fails when CODE_EXPIRY exceeds it
+	MaxBatchCodeExpiry  time.Duration `envconfig:"MAX_BATCH_CODE_EXPIRY"`         // Lets batch codes outlive MAX_CODE_EXPIRY, up to 720h; unset caps them at it
+	PollInterval        time.Duration `envconfig:"POLL_INTERVAL" default:"5s"`
+	MaxPollInterval     time.Duration `envconfig:"MAX_POLL_INTERVAL" default:"1m"` // Upper bound for intervals clients ask for
+	MaxPollsPerMinute   int           `envconfig:"MAX_POLLS_PER_MINUTE" default:"12"`
+	SlowDownStrategy    string        `envconfig:"POLL_SLOWDOWN_STRATEGY" default:"fixed"` // fixed (+5s per RFC 8628) or exponential
+	SlowDownFactor      float64       `envconfig:"POLL_SLOWDOWN_FACTOR" default:"2"`       // Multiplier for the exponential strategy
+	SlowDownMaxInterval time.Duration `envconfig:"POLL_SLOWDOWN_MAX_INTERVAL"`             // Caps slow_down growth, 0 for no cap
+	SubmissionWindow    time.Duration `envconfig:"SUBMISSION_WINDOW" default:"30s"`
+	TokenTTL            time.Duration `envconfig:"TOKEN_TTL"` // Defaults to CODE_EXPIRY
+	RateLimitWindow     time.Duration `envconfig:"RATE_LIMIT_WINDOW" default:"1m"`
+	ExpiredTokenGrace   time.Duration `envconfig:"EXPIRED_TOKEN_GRACE" default:"10m"` // How long gone codes are answered as expired or used rather than unknown, 0 disables
+	MaxOutstandingCodes int           `envconfig:"MAX_OUTSTANDING_CODES" default:"0"` // Global cap on pending codes, 0 disables
+	MaxCodesPerClient   int           `envconfig:"MAX_CODES_PER_CLIENT" default:"0"`  // Per-client cap on unexpired codes, 0 disables
+	CleanupInterval     time.Duration `envconfig:"CLEANUP_INTERVAL" default:"1m"`     // Expired code sweep interval
+	BaseURL             string        `envconfig:"BASE_URL" required:"true"`
+
+	// Plain HTTP handling: off, redirect or reject. X-Forwarded-Proto is only
+	// honored from TRUSTED_PROXIES, a list of addresses or CIDR ranges.
+	EnforceHTTPS   string   `envconfig:"ENFORCE_HTTPS" default:"off"`
+	TrustedProxies []string `envconfig:"TRUSTED_PROXIES"`
+
+	// HTTP middleware chain, outermost first. Empty uses request_id, logging,
+	// recovery, https, real_ip and timeout; rate_limit and security_headers
+	// are added by listing them.
+	Middlewares         []string      `envconfig:"MIDDLEWARES"`
+	RequestTimeout      time.Duration `envconfig:"REQUEST_TIMEOUT" default:"30s"`
+	HTTPRateLimit       int           `envconfig:"HTTP_RATE_LIMIT" default:"120"` // Requests per client IP per HTTP_RATE_LIMIT_WINDOW
+	HTTPRateLimitWindow time.Duration `envconfig:"HTTP_RATE_LIMIT_WINDOW" default:"1m"`
+	HSTSMaxAge          time.Duration `envconfig:"HSTS_MAX_AGE"` // Strict-Transport-Security max-age, 0 omits the header
+
+	// Degraded mode answers polls from a short-lived in-memory cache while
+	// Redis fails and rejects new device codes with a retriable error
+	DegradedMode        bool          `envconfig:"DEGRADED_MODE" default:"false"`
+	DegradedCacheTTL    time.Duration `envconfig:"DEGRADED_CACHE_TTL" default:"30s"`
+	DegradedErrorBudget int           `envconfig:"DEGRADED_ERROR_BUDGET" default:"5"` // Redis failures per DEGRADED_WINDOW before skipping Redis
+	DegradedWindow      time.Duration `envconfig:"DEGRADED_WINDOW" default:"10s"`
+	DegradedCooldown    time.Duration `envconfig:"DEGRADED_COOLDOWN" default:"5s"` // How long Redis is skipped before it is probed again
+
+	// Per-replica cache telling devices that poll before their interval to
+	// slow down without a Redis round trip
+	PollCache    bool          `envconfig:"POLL_CACHE" default:"false"`
+	PollCacheTTL time.Duration `envconfig:"POLL_CACHE_TTL" default:"1m"` // Intervals longer than this always go to Redis
+
+	// Client policy. ENABLE_CONSENT_SCREEN and ENABLE_ANOMALY_REVERIFY feature
+	// flags override CONSENT_PAGE and POLL_ANOMALY_REVERIFY, e.g. with 25% to
+	// roll them out to a quarter of clients
+	ClientsFile             string        `envconfig:"CLIENTS_FILE"`                                 // Optional JSON file of per-client settings
+	RegisteredClientsOnly   bool          `envconfig:"REGISTERED_CLIENTS_ONLY" default:"false"`      // Reject clients missing from CLIENTS_FILE with invalid_client
+	ClientAuthFailureDelay  time.Duration `envconfig:"CLIENT_AUTH_FAILURE_DELAY" default:"100ms"`    // Least time invalid_client responses take, hiding which client IDs exist
+	VerificationURIComplete bool          `envconfig:"VERIFICATION_URI_COMPLETE" default:"true"`     // Default for clients without an override
+	ConsentPage             bool          `envconfig:"CONSENT_PAGE" default:"true"`                  // Confirm client and scopes before authorization
+	ShortCodeOnly           bool          `envconfig:"SHORT_CODE_ONLY" default:"false"`              // Withhold verification_uri_complete and always show consent, per RFC 8628 section 5.4
+	CompleteURITemplate     string        `envconfig:"VERIFICATION_URI_COMPLETE_TEMPLATE"`           // Deep link with {user_code}, e.g. BASE_URL/a/{user_code}
+	UserCodeDisplayFormat   string        `envconfig:"USER_CODE_DISPLAY_FORMAT" default:"XXXX-XXXX"` // Code layout on pages and in verification_uri_complete, e.g. "XXXX XXXX"
+	IncludeIDToken          bool          `envconfig:"INCLUDE_ID_TOKEN" default:"false"`             // Return the validated ID token to polling devices
+	AnomalyReverify         bool          `envconfig:"POLL_ANOMALY_REVERIFY" default:"false"`        // Require approval again when a code is polled from another network or User-Agent
+
+	// Client names, logos and consent text looked up with Keycloak's admin API
+	// by a service account granted view-clients, falling back to CLIENTS_FILE
+	KeycloakAdminClientID     string        `envconfig:"KEYCLOAK_ADMIN_CLIENT_ID"` // Lookup is disabled when empty
+	KeycloakAdminClientSecret string        `envconfig:"KEYCLOAK_ADMIN_CLIENT_SECRET"`
+	ClientMetadataCacheTTL    time.Duration `envconfig:"CLIENT_METADATA_CACHE_TTL" default:"5m"`
+
+	// Token response members kept from devices unless a client overrides it,
+	// e.g. refresh_token where long-lived credentials on devices are prohibited
+	WithheldTokens []string `envconfig:"WITHHELD_TOKENS"`
+
+	// Scopes devices may request unless a client overrides it, e.g.
+	// openid,profile. Requested scopes are always checked against RFC 6749
+	// syntax, deduplicated and sorted. Unset allows any scope.
+	AllowedScopes []string `envconfig:"ALLOWED_SCOPES"`
+
+	// Proxy-managed renewal keeps refresh tokens server-side; devices renew
+	// their access token at /token/current and never receive a refresh token
+	TokenRenewal         bool          `envconfig:"TOKEN_RENEWAL" default:"false"`
+	TokenRenewalGrantTTL time.Duration `envconfig:"TOKEN_RENEWAL_GRANT_TTL" default:"720h"` // Unused grants are dropped after this long
+
+	// Request offline_access so that Keycloak issues offline tokens outliving
+	// the user's session. With TOKEN_RENEWAL their grants never expire and are
+	// revoked from the admin API.
+	OfflineAccess bool `envconfig:"OFFLINE_ACCESS" default:"false"` // Default for clients without an override
+
+	// Device request parameters passed through to the identity provider, e.g. audience,acr_values
+	ForwardedAuthParams []string `envconfig:"FORWARDED_AUTH_PARAMS"`
+
+	// Proof of work required of public clients before they are issued device codes
+	ProofOfWork           bool          `envconfig:"DEVICE_PROOF_OF_WORK" default:"false"` // Default for clients without an override
+	ProofOfWorkDifficulty int           `envconfig:"DEVICE_POW_DIFFICULTY" default:"18"`   // Leading zero bits, each doubles the work
+	ProofOfWorkTTL        time.Duration `envconfig:"DEVICE_POW_TTL" default:"2m"`
+	ProofOfWorkSecret     string        `envconfig:"DEVICE_POW_SECRET"` // Signs challenges, defaults to a key derived from CSRF_SECRET
+
+	// Help pages linked from device flow errors as error_uri, the base joined
+	// to the error code, e.g. https://docs.example.com/errors/ links
+	// expired_token to https://docs.example.com/errors/expired_token
+	ErrorURIBase string `envconfig:"ERROR_URI_BASE"`
+
+	// Add user_code_format, qr_uri and branding members to device code responses
+	ResponseExtensions bool   `envconfig:"DEVICE_RESPONSE_EXTENSIONS" default:"false"`
+	ResponseQRFormat   string `envconfig:"DEVICE_RESPONSE_QR_FORMAT" default:"png"` // qr_uri format: png, svg, ascii or terminal
+
+	// Devices may name a callback_uri that receives the signed token response
+	// when authorization completes, in addition to polling. Callback URIs must
+	// be at a public origin listed in the client's callback_origins.
+	DeviceCallbacks          bool          `envconfig:"DEVICE_CALLBACKS" default:"false"`
+	DeviceCallbackMaxRetries int           `envconfig:"DEVICE_CALLBACK_MAX_RETRIES" default:"3"`
+	DeviceCallbackTimeout    time.Duration `envconfig:"DEVICE_CALLBACK_TIMEOUT" default:"5s"`
+
+	// Forward /device/code and /device/token to an identity provider that
+	// implements RFC 8628 itself; the proxy then only hosts the code entry page
+	DevicePassthrough       bool   `envconfig:"DEVICE_PASSTHROUGH" default:"false"`
+	UpstreamDeviceEndpoint  string `envconfig:"UPSTREAM_DEVICE_ENDPOINT"`  // Defaults to the Keycloak realm's device endpoint
+	UpstreamVerificationURI string `envconfig:"UPSTREAM_VERIFICATION_URI"` // Defaults to the Keycloak realm's device page
+
+	// Forward client_credentials token requests at /token for back-end
+	// provisioning tools, limited to clients with client_credentials enabled in
+	// CLIENTS_FILE. The tools authenticate to Keycloak with their own secrets.
+	ClientCredentials bool `envconfig:"CLIENT_CREDENTIALS_PASSTHROUGH" default:"false"`
+
+	// Token streaming for devices that can hold a connection open
+	TokenStream        bool          `envconfig:"TOKEN_STREAM" default:"false"`       // Serve /device/token/stream
+	TokenStreamTimeout time.Duration `envconfig:"TOKEN_STREAM_TIMEOUT" default:"25s"` // Must stay below the 30s request timeout
+
+	// Per-IP limits on the verification pages, separate from device polling limits
+	VerifyRateWindow  time.Duration `envconfig:"VERIFY_RATE_WINDOW" default:"1m"`
+	VerifyMaxRequests int           `envconfig:"VERIFY_MAX_REQUESTS" default:"60"` // Page requests per IP per window, 0 disables throttling
+	VerifyMaxFailures int           `envconfig:"VERIFY_MAX_FAILURES" default:"10"` // Invalid codes per IP per window before lockout
+	VerifyLockout     time.Duration `envconfig:"VERIFY_LOCKOUT" default:"15m"`
+
+	// Request location headers set by a trusted edge proxy, shown on the consent page
+	GeoCityHeader    string `envconfig:"GEO_CITY_HEADER"`
+	GeoRegionHeader  string `envconfig:"GEO_REGION_HEADER"`
+	GeoCountryHeader string `envconfig:"GEO_COUNTRY_HEADER"`
+
+	// Branding for the hosted pages
+	BrandProductName  string   `envconfig:"BRAND_PRODUCT_NAME"`
+	BrandLogoURL      string   `envconfig:"BRAND_LOGO_URL"`
+	BrandPrimaryColor string   `envconfig:"BRAND_PRIMARY_COLOR"` // Hex color, e.g. #1a73e8
+	BrandFooterLinks  []string `envconfig:"BRAND_FOOTER_LINKS"`  // Comma-separated Label=URL pairs
+
+	// Rendered QR codes kept in memory and served with an ETag, 0 disables caching
+	QRCacheSize int `envconfig:"QR_CACHE_SIZE" default:"256"`
+
+	// Accessibility toggles for the hosted pages
+	HighContrast bool `envconfig:"HIGH_CONTRAST" default:"false"`  // Black-on-white pages, ignoring BRAND_PRIMARY_COLOR
+	LargeCode    bool `envconfig:"LARGE_CODE" default:"false"`     // Enlarged user code display and entry
+	SpellOutCode bool `envconfig:"SPELL_OUT_CODE" default:"false"` // Screen readers read user codes character by character
 
 	// CSRF Configuration
 	CSRFSecret      string        `envconfig:"CSRF_SECRET" required:"true"`
 	CSRFTokenExpiry time.Duration `envconfig:"CSRF_TOKEN_EXPIRY" default:"1h"`
+	CSRFMode        string        `envconfig:"CSRF_MODE" default:"store"` // store, or double_submit to bind tokens to a cookie instead of Redis
+
+	// Secrets may be given as file:/path or vault:path#field references
+	VaultAddr              string        `envconfig:"VAULT_ADDR"`
+	VaultToken             string        `envconfig:"VAULT_TOKEN"`                           // Literal or file: reference
+	SecretsRefreshInterval time.Duration `envconfig:"SECRETS_REFRESH_INTERVAL" default:"5m"` // Re-read rotatable secret references
+
+	// Verification session cookie binding OAuth state to a device code
+	SessionSecret string        `envconfig:"SESSION_SECRET"` // Defaults to CSRF_SECRET
+	SessionTTL    time.Duration `envconfig:"SESSION_TTL" default:"10m"`
+
+	// Webhook Configuration
+	WebhookURL        string        `envconfig:"WEBHOOK_URL"`
+	WebhookSecret     string        `envconfig:"WEBHOOK_SECRET"`
+	WebhookMaxRetries int           `envconfig:"WEBHOOK_MAX_RETRIES" default:"5"`
+	WebhookTimeout    time.Duration `envconfig:"WEBHOOK_TIMEOUT" default:"5s"`
+
+	// Admin API and audit trail
+	AdminToken   string `envconfig:"ADMIN_TOKEN"`                   // Admin API is disabled when empty
+	AuditBackend string `envconfig:"AUDIT_BACKEND" default:"redis"` // redis, file or none
+	AuditFile    string `envconfig:"AUDIT_FILE"`                    // JSON lines path for the file backend
+
+	// Internal listener for /metrics, /admin, /debug/runtime and, with PPROF,
+	// /debug/pprof, keeping the public PORT to the device flow. Disabled when
+	// ADMIN_PORT is 0, serving /metrics and /admin on PORT.
+	AdminPort        int    `envconfig:"ADMIN_PORT" default:"0"`
+	AdminBindAddress string `envconfig:"ADMIN_BIND_ADDRESS" default:"127.0.0.1"` // Localhost or an internal interface
+	Pprof            bool   `envconfig:"PPROF" default:"false"`                  // Requires ADMIN_PORT
+
+	// gRPC management API for fleet-provisioning backends, served on its own
+	// listener to callers presenting a certificate signed by
+	// GRPC_ADMIN_CLIENT_CA. Disabled when GRPC_ADMIN_PORT is 0.
+	GRPCAdminPort        int    `envconfig:"GRPC_ADMIN_PORT" default:"0"`
+	GRPCAdminBindAddress string `envconfig:"GRPC_ADMIN_BIND_ADDRESS" default:"127.0.0.1"`
+	GRPCAdminCertFile    string `envconfig:"GRPC_ADMIN_TLS_CERT"`  // PEM server certificate chain
+	GRPCAdminKeyFile     string `envconfig:"GRPC_ADMIN_TLS_KEY"`   // PEM server private key
+	GRPCAdminClientCA    string `envconfig:"GRPC_ADMIN_CLIENT_CA"` // PEM CA bundle for client certificates
+
+	// Authorization policy consulted before the redirect to Keycloak and
+	// before tokens are stored, as an OPA Data API decision URL such as
+	// http://opa:8181/v1/data/deviceproxy/decision. Disabled when empty.
+	PolicyURL     string        `envconfig:"POLICY_URL"`
+	PolicyTimeout time.Duration `envconfig:"POLICY_TIMEOUT" default:"2s"`
+
+	// Notifications telling users about devices they authorized: none, smtp
+	// or webhook. The message links to NOTIFY_REVOKE_URL, by default the
+	// Keycloak account console's applications page.
+	NotifyBackend       string `envconfig:"NOTIFY_BACKEND" default:"none"`
+	NotifyRevokeURL     string `envconfig:"NOTIFY_REVOKE_URL"`
+	NotifyWebhookURL    string `envconfig:"NOTIFY_WEBHOOK_URL"`
+	NotifyWebhookSecret string `envconfig:"NOTIFY_WEBHOOK_SECRET"`
+	SMTPAddr            string `envconfig:"SMTP_ADDR"` // host:port
+	SMTPUsername        string `envconfig:"SMTP_USERNAME"`
+	SMTPPassword        string `envconfig:"SMTP_PASSWORD"`
+	SMTPFrom            string `envconfig:"SMTP_FROM"`
+
+	// Self-service page at /my/devices where users sign in with Keycloak to
+	// list the devices they authorized and revoke the grants kept with
+	// TOKEN_RENEWAL. BASE_URL/my/devices/callback must be a valid redirect URI
+	// of OAUTH_CLIENT_ID.
+	DevicesPage bool `envconfig:"DEVICES_PAGE" default:"false"`
+
+	// Token introspection per RFC 7662 at /introspect for resource servers,
+	// which authenticate with HTTP Basic credentials from comma-separated
+	// id:secret pairs. Answers are cached up to the token's expiry. Disabled
+	// when no resource servers are configured.
+	IntrospectionClients    string        `envconfig:"INTROSPECTION_CLIENTS"`
+	IntrospectionCacheTTL   time.Duration `envconfig:"INTROSPECTION_CACHE_TTL" default:"1m"`
+	IntrospectionCacheLimit int           `envconfig:"INTROSPECTION_CACHE_LIMIT" default:"10000"`
+
+	// Token exchange per RFC 8693 at /token/exchange for devices trading their
+	// access token for one targeted at another client, listed here. Keycloak's
+	// token exchange feature must allow the proxy's client to exchange for
+	// each audience. Devices may only exchange tokens the proxy delivered to
+	// their client. Disabled when no audiences are configured.
+	TokenExchangeAudiences []string `envconfig:"TOKEN_EXCHANGE_AUDIENCES"`
+
+	// Test hook approving device codes with synthetic tokens for automated
+	// tests, at /internal/test/approve. Never enable it in production.
+	TestHook      bool   `envconfig:"TEST_HOOK" default:"false"`
+	TestHookToken string `envconfig:"TEST_HOOK_TOKEN"` // Bearer token required by the hook when set
+
+	// Upstream identity provider client
+	UpstreamTimeout          time.Duration `envconfig:"UPSTREAM_TIMEOUT" default:"10s"`
+	UpstreamMaxRetries       int           `envconfig:"UPSTREAM_MAX_RETRIES" default:"2"`
+	UpstreamBreakerThreshold int           `envconfig:"UPSTREAM_BREAKER_THRESHOLD" default:"5"`
+	UpstreamBreakerCooldown  time.Duration `envconfig:"UPSTREAM_BREAKER_COOLDOWN" default:"30s"`
+
+	// Redis connection pool, zero values keep the go-redis defaults
+	RedisPoolSize     int           `envconfig:"REDIS_POOL_SIZE"`      // Defaults to 10 per CPU
+	RedisMinIdleConns int           `envconfig:"REDIS_MIN_IDLE_CONNS"` // Connections kept warm for polling bursts
+	RedisPoolTimeout  time.Duration `envconfig:"REDIS_POOL_TIMEOUT"`   // Wait for a free connection, defaults to READ_TIMEOUT + 1s
+	RedisDialTimeout  time.Duration `envconfig:"REDIS_DIAL_TIMEOUT"`
+	RedisReadTimeout  time.Duration `envconfig:"REDIS_READ_TIMEOUT"`
+	RedisWriteTimeout time.Duration `envconfig:"REDIS_WRITE_TIMEOUT"`
+
+	// Retries of idempotent device flow store operations failing transiently,
+	// as during a Redis failover, with exponential backoff and jitter
+	StoreRetries         int           `envconfig:"STORE_RETRIES" default:"2"` // Negative disables retries
+	StoreRetryBackoff    time.Duration `envconfig:"STORE_RETRY_BACKOFF" default:"50ms"`
+	StoreRetryMaxBackoff time.Duration `envconfig:"STORE_RETRY_MAX_BACKOFF" default:"1s"`
+
+	// Dual writes of device flow state to a second Redis deployment for a
+	// staged cutover. Writes go to both, reads go to DUAL_WRITE_PRIMARY first
+	// and fall back to the other; CSRF, renewal and stats stay on REDIS_URL.
+	DualWriteURL       string `envconfig:"DUAL_WRITE_REDIS_URL"`                 // Enables dual writes
+	DualWriteKeyPrefix string `envconfig:"DUAL_WRITE_KEY_PREFIX"`                // Defaults to REDIS_KEY_PREFIX
+	DualWritePrimary   string `envconfig:"DUAL_WRITE_PRIMARY" default:"current"` // current (REDIS_URL) or new (DUAL_WRITE_REDIS_URL)
 
 	// HTTP Server Timeouts
 	ReadHeaderTimeout time.Duration `envconfig:"READ_HEADER_TIMEOUT" default:"10s"`
@@ -27,8 +281,16 @@ type Config struct {
 	// OAuth Configuration
 	OAuth struct {
 		ClientID              string `envconfig:"OAUTH_CLIENT_ID" required:"true"`
-		ClientSecret          string `envconfig:"OAUTH_CLIENT_SECRET" required:"true"`
+		ClientSecret          string `envconfig:"OAUTH_CLIENT_SECRET"` // Required unless a client assertion key is set
 		AuthorizationEndpoint string `envconfig:"OAUTH_AUTH_ENDPOINT" required:"true"`
 		TokenEndpoint         string `envconfig:"OAUTH_TOKEN_ENDPOINT" required:"true"`
+		RevocationEndpoint    string `envconfig:"OAUTH_REVOCATION_ENDPOINT"`    // Defaults to the Keycloak realm's revocation endpoint
+		UserinfoEndpoint      string `envconfig:"OAUTH_USERINFO_ENDPOINT"`      // Names users without an ID token on the completion page, optional
+		IntrospectionEndpoint string `envconfig:"OAUTH_INTROSPECTION_ENDPOINT"` // Defaults to the Keycloak realm's introspection endpoint
+
+		// PEM private key authenticating the proxy with private_key_jwt instead
+		// of the client secret, literal or a file: or vault: reference
+		ClientAssertionKey   string `envconfig:"OAUTH_CLIENT_ASSERTION_KEY"`
+		ClientAssertionKeyID string `envconfig:"OAUTH_CLIENT_ASSERTION_KEY_ID"` // kid of the registered key
 	}
 }
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/token"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+	"github.com/wrale/oauth2-device-proxy/internal/tokencache"
+)
+
+// newSubjectClientFunc looks up the client an access token was delivered to,
+// by the device flow or by renewal at /token/current. Without a record of
+// delivered tokens every exchange is refused.
+func newSubjectClientFunc(issued *tokencache.IssuedTokens, renewer *renewal.Renewer) token.SubjectClientFunc {
+	if issued == nil {
+		return nil
+	}
+	return func(ctx context.Context, accessToken string) (string, error) {
+		clientID, err := issued.ClientOf(ctx, accessToken)
+		if err != nil || clientID != "" || renewer == nil {
+			return clientID, err
+		}
+		return renewer.ClientOf(ctx, accessToken)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	adminv1 "github.com/wrale/oauth2-device-proxy/api/admin/v1"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/grpcadmin"
+)
+
+// validateGRPCAdminListener checks the gRPC management API listener settings
+func validateGRPCAdminListener(cfg Config) error {
+	if cfg.GRPCAdminPort == 0 {
+		return nil
+	}
+	if cfg.GRPCAdminPort < 0 || cfg.GRPCAdminPort > 65535 {
+		return fmt.Errorf("invalid port %d", cfg.GRPCAdminPort)
+	}
+	if cfg.GRPCAdminPort == cfg.Port {
+		return fmt.Errorf("port %d is already used by PORT", cfg.GRPCAdminPort)
+	}
+	if cfg.GRPCAdminPort == cfg.AdminPort {
+		return fmt.Errorf("port %d is already used by ADMIN_PORT", cfg.GRPCAdminPort)
+	}
+	if cfg.GRPCAdminCertFile == "" || cfg.GRPCAdminKeyFile == "" || cfg.GRPCAdminClientCA == "" {
+		return fmt.Errorf("GRPC_ADMIN_TLS_CERT, GRPC_ADMIN_TLS_KEY and GRPC_ADMIN_CLIENT_CA are required")
+	}
+	return nil
+}
+
+// newGRPCAdminTLS builds the mutual TLS configuration of the gRPC management
+// API, refusing callers without a certificate signed by GRPC_ADMIN_CLIENT_CA
+func newGRPCAdminTLS(cfg Config) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.GRPCAdminCertFile, cfg.GRPCAdminKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading server certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(cfg.GRPCAdminClientCA)
+	if err != nil {
+		return nil, fmt.Errorf("reading client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.GRPCAdminClientCA)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// newGRPCAdminServer creates the gRPC server for the management API
+func newGRPCAdminServer(cfg Config, admin grpcadmin.Config) (*grpc.Server, error) {
+	tlsConfig, err := newGRPCAdminTLS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	adminv1.RegisterDeviceAdminServer(srv, grpcadmin.New(admin))
+	return srv, nil
+}
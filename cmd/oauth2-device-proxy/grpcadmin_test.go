@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	adminv1 "github.com/wrale/oauth2-device-proxy/api/admin/v1"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/grpcadmin"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// testCA issues certificates for the gRPC admin listener tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a PEM certificate and key signed by the CA
+func (ca *testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestGRPCAdminListener(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	serverCA := newTestCA(t, "server CA")
+	clientCA := newTestCA(t, "client CA")
+	serverCert, serverKey := serverCA.issue(t, "proxy", x509.ExtKeyUsageServerAuth)
+	cfg := Config{
+		GRPCAdminCertFile: write("server.pem", serverCert),
+		GRPCAdminKeyFile:  write("server-key.pem", serverKey),
+		GRPCAdminClientCA: write("client-ca.pem", clientCA.pem),
+	}
+
+	flow := &test.MockFlow{
+		ListClientCodesFunc: func(ctx context.Context, clientID string) ([]*deviceflow.DeviceCode, error) {
+			return []*deviceflow.DeviceCode{{DeviceCode: "dc", UserCode: "ABCD-EFGH", ClientID: clientID}}, nil
+		},
+	}
+	srv, err := newGRPCAdminServer(cfg, grpcadmin.Config{Flow: flow})
+	if err != nil {
+		t.Fatalf("newGRPCAdminServer failed: %v", err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCA.cert)
+	list := func(certs []tls.Certificate) error {
+		conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			RootCAs:      roots,
+			Certificates: certs,
+		})))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err = adminv1.NewDeviceAdminClient(conn).ListDeviceCodes(ctx, &adminv1.ListDeviceCodesRequest{ClientId: "tv"})
+		return err
+	}
+	pair := func(ca *testCA) []tls.Certificate {
+		certPEM, keyPEM := ca.issue(t, "fleet backend", x509.ExtKeyUsageClientAuth)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []tls.Certificate{cert}
+	}
+
+	if err := list(pair(clientCA)); err != nil {
+		t.Errorf("client with a trusted certificate: %v", err)
+	}
+	if err := list(nil); err == nil {
+		t.Error("client without a certificate was accepted")
+	}
+	if err := list(pair(serverCA)); err == nil {
+		t.Error("client with a certificate from another CA was accepted")
+	}
+}
+
+func TestValidateGRPCAdminListener(t *testing.T) {
+	tlsFiles := Config{GRPCAdminCertFile: "cert.pem", GRPCAdminKeyFile: "key.pem", GRPCAdminClientCA: "ca.pem"}
+	withPorts := func(cfg Config, port, adminPort, grpcPort int) Config {
+		cfg.Port, cfg.AdminPort, cfg.GRPCAdminPort = port, adminPort, grpcPort
+		return cfg
+	}
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "disabled", cfg: withPorts(Config{}, 8080, 0, 0)},
+		{name: "separate port", cfg: withPorts(tlsFiles, 8080, 9090, 9443)},
+		{name: "public port", cfg: withPorts(tlsFiles, 8080, 9090, 8080), wantErr: true},
+		{name: "admin port", cfg: withPorts(tlsFiles, 8080, 9090, 9090), wantErr: true},
+		{name: "missing client CA", cfg: withPorts(Config{GRPCAdminCertFile: "cert.pem", GRPCAdminKeyFile: "key.pem"}, 8080, 0, 9443), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateGRPCAdminListener(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateGRPCAdminListener() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
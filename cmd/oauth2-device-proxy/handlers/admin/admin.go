@@ -0,0 +1,95 @@
+// Package admin provides operator management endpoints for the device proxy
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// Handler serves the admin API. All routes require a bearer token.
+type Handler struct {
+	token  string
+	flow   deviceflow.Flow
+	audit  audit.Logger
+	stats  StatsSource
+	grants GrantManager
+	mux    *chi.Mux
+}
+
+// Config contains admin handler configuration
+type Config struct {
+	Token string          // Bearer token required on every request
+	Flow  deviceflow.Flow // Device flow used for batch management
+	Audit audit.Logger    // Audit trail exposed for compliance review
+	Stats StatsSource     // Conversion stats, the /stats route is omitted when nil
+
+	// Grants manages offline grants kept by proxy-managed renewal, the
+	// /grants routes are omitted when nil
+	Grants GrantManager
+}
+
+// New creates a new admin API handler
+func New(cfg Config) *Handler {
+	h := &Handler{
+		token:  cfg.Token,
+		flow:   cfg.Flow,
+		audit:  cfg.Audit,
+		stats:  cfg.Stats,
+		grants: cfg.Grants,
+		mux:    chi.NewRouter(),
+	}
+	if h.audit == nil {
+		h.audit = audit.NopLogger{}
+	}
+
+	h.mux.Use(h.authenticate)
+	h.mux.Get("/audit", h.handleAudit)
+	h.mux.Post("/batches", h.handleCreateBatch)
+	h.mux.Get("/batches/{id}", h.handleGetBatch)
+	h.mux.Delete("/batches/{id}", h.handleInvalidateBatch)
+	h.mux.Get("/clients/{id}/codes", h.handleClientCodes)
+	h.mux.Post("/clients/{id}/revoke", h.handleRevokeClient)
+	if h.stats != nil {
+		h.mux.Get("/stats", h.handleStats)
+	}
+	if h.grants != nil {
+		h.mux.Get("/grants/offline", h.handleOfflineGrants)
+		h.mux.Delete("/grants/offline/{id}", h.handleRevokeOfflineGrant)
+	}
+
+	return h
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// authenticate rejects requests without the configured bearer token
+func (h *Handler) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || h.token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(h.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			common.WriteError(w, deviceflow.ErrorCodeInvalidToken, "A valid admin bearer token is required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes a successful JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	common.SetJSONHeaders(w)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		common.WriteJSONError(w, err)
+	}
+}
@@ -0,0 +1,185 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/stats"
+)
+
+type mockAudit struct {
+	records []audit.Record
+	filter  audit.Filter
+	err     error
+}
+
+func (m *mockAudit) Record(ctx context.Context, record audit.Record) error {
+	m.records = append(m.records, record)
+	return nil
+}
+
+func (m *mockAudit) List(ctx context.Context, filter audit.Filter) ([]audit.Record, error) {
+	m.filter = filter
+	return m.records, m.err
+}
+
+func TestAuthentication(t *testing.T) {
+	h := New(Config{Token: "secret", Audit: &mockAudit{}})
+
+	tests := []struct {
+		name       string
+		header     string
+		wantStatus int
+	}{
+		{name: "missing token", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", header: "Bearer wrong", wantStatus: http.StatusUnauthorized},
+		{name: "wrong scheme", header: "Basic secret", wantStatus: http.StatusUnauthorized},
+		{name: "valid token", header: "Bearer secret", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/audit", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestHandleAudit(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		listErr    error
+		wantStatus int
+		wantFilter audit.Filter
+	}{
+		{
+			name:       "default filter",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "client and since",
+			query:      "?client_id=kiosk&since=2024-01-01T00:00:00Z&limit=5",
+			wantStatus: http.StatusOK,
+			wantFilter: audit.Filter{
+				ClientID: "kiosk",
+				Since:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+				Limit:    5,
+			},
+		},
+		{name: "invalid since", query: "?since=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "invalid limit", query: "?limit=-1", wantStatus: http.StatusBadRequest},
+		{name: "list error", listErr: errors.New("redis down"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &mockAudit{
+				records: []audit.Record{{ID: "1", Action: audit.ActionApproved, ClientID: "kiosk"}},
+				err:     tt.listErr,
+			}
+			h := New(Config{Token: "secret", Audit: logger})
+
+			req := httptest.NewRequest(http.MethodGet, "/audit"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if !logger.filter.Since.Equal(tt.wantFilter.Since) ||
+				logger.filter.ClientID != tt.wantFilter.ClientID ||
+				logger.filter.Limit != tt.wantFilter.Limit {
+				t.Errorf("filter = %+v, want %+v", logger.filter, tt.wantFilter)
+			}
+
+			var resp AuditResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if len(resp.Records) != 1 || resp.Records[0].ID != "1" {
+				t.Errorf("records = %+v, want one record", resp.Records)
+			}
+		})
+	}
+}
+
+type mockStats []stats.ClientStats
+
+func (m mockStats) Stats(ctx context.Context) ([]stats.ClientStats, error) {
+	return m, nil
+}
+
+func TestStats(t *testing.T) {
+	source := mockStats{{ClientID: "printer", Issued: 1}, {ClientID: "tv", Issued: 4, Completed: 2, ConversionRate: 0.5}}
+	h := New(Config{Token: "secret", Audit: &mockAudit{}, Stats: source})
+
+	tests := []struct {
+		name        string
+		query       string
+		wantClients []string
+	}{
+		{name: "all clients", wantClients: []string{"printer", "tv"}},
+		{name: "one client", query: "?client_id=tv", wantClients: []string{"tv"}},
+		{name: "unknown client", query: "?client_id=radio", wantClients: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/stats"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			var resp StatsResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if len(resp.Clients) != len(tt.wantClients) {
+				t.Fatalf("got %d clients, want %d", len(resp.Clients), len(tt.wantClients))
+			}
+			for i, id := range tt.wantClients {
+				if resp.Clients[i].ClientID != id {
+					t.Errorf("client %d = %q, want %q", i, resp.Clients[i].ClientID, id)
+				}
+			}
+		})
+	}
+}
+
+func TestStatsDisabled(t *testing.T) {
+	h := New(Config{Token: "secret", Audit: &mockAudit{}})
+	req := httptest.NewRequest(http.MethodGet, "/stats", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
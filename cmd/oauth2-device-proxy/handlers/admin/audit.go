@@ -0,0 +1,51 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// AuditResponse lists audit records, most recent first
+type AuditResponse struct {
+	Records []audit.Record `json:"records"`
+}
+
+// handleAudit returns audit records filtered by client_id, since (RFC 3339) and limit
+func (h *Handler) handleAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := audit.Filter{ClientID: query.Get("client_id")}
+
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "The since parameter must be an RFC 3339 timestamp")
+			return
+		}
+		filter.Since = t
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "The limit parameter must be a positive integer")
+			return
+		}
+		filter.Limit = n
+	}
+
+	records, err := h.audit.List(r.Context(), filter)
+	if err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeServerError, "Failed to read audit log")
+		return
+	}
+	if records == nil {
+		records = []audit.Record{}
+	}
+
+	writeJSON(w, AuditResponse{Records: records})
+}
@@ -0,0 +1,202 @@
+package admin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// BatchRequest asks for a batch of pre-generated device codes
+type BatchRequest struct {
+	ClientID  string `json:"client_id"`
+	Scope     string `json:"scope,omitempty"`
+	Count     int    `json:"count"`
+	ExpiresIn int    `json:"expires_in"` // Seconds; defaults to the flow's code expiry
+}
+
+// BatchCode is a pre-generated device code as embedded in an offline device
+type BatchCode struct {
+	DeviceCode              string    `json:"device_code"`
+	UserCode                string    `json:"user_code"`
+	VerificationURI         string    `json:"verification_uri"`
+	VerificationURIComplete string    `json:"verification_uri_complete,omitempty"`
+	ExpiresAt               time.Time `json:"expires_at"`
+	Interval                int       `json:"interval"`
+
+	// Progress of the code's flow, omitted for steps not yet reached
+	RequestedAt  *time.Time `json:"requested_at,omitempty"`
+	VerifiedAt   *time.Time `json:"verified_at,omitempty"`
+	AuthorizedAt *time.Time `json:"authorized_at,omitempty"`
+	ConsumedAt   *time.Time `json:"consumed_at,omitempty"`
+}
+
+// BatchResponse describes a batch and its outstanding device codes
+type BatchResponse struct {
+	ID          string      `json:"id"`
+	ClientID    string      `json:"client_id"`
+	Scope       string      `json:"scope,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+	ExpiresAt   time.Time   `json:"expires_at"`
+	Invalidated bool        `json:"invalidated,omitempty"`
+	Codes       []BatchCode `json:"codes"`
+}
+
+// InvalidateResponse reports how many device codes a bulk invalidation revoked
+type InvalidateResponse struct {
+	ID      string `json:"id"`
+	Revoked int    `json:"revoked"`
+}
+
+// Export formats supported by GET /batches/{id}
+const (
+	formatJSON   = "json"
+	formatCSV    = "csv"
+	formatLabels = "labels"
+)
+
+// handleCreateBatch generates a new batch of device codes
+func (h *Handler) handleCreateBatch(w http.ResponseWriter, r *http.Request) {
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.ExpiresIn < 0 {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "The expires_in parameter must not be negative")
+		return
+	}
+
+	expiry := time.Duration(req.ExpiresIn) * time.Second
+	batch, codes, err := h.flow.CreateBatch(r.Context(), req.ClientID, req.Scope, req.Count, expiry)
+	if err != nil {
+		writeFlowError(w, err)
+		return
+	}
+
+	common.SetJSONHeaders(w)
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(newBatchResponse(batch, codes)); err != nil {
+		common.WriteJSONError(w, err)
+	}
+}
+
+// handleGetBatch exports a batch as JSON, CSV or printable labels
+func (h *Handler) handleGetBatch(w http.ResponseWriter, r *http.Request) {
+	batchID := chi.URLParam(r, "id")
+	batch, codes, err := h.flow.GetBatch(r.Context(), batchID)
+	if err != nil {
+		writeFlowError(w, err)
+		return
+	}
+	if batch == nil {
+		common.WriteErrorStatus(w, http.StatusNotFound, deviceflow.ErrorCodeInvalidRequest, "Unknown batch")
+		return
+	}
+
+	resp := newBatchResponse(batch, codes)
+	switch format := r.URL.Query().Get("format"); format {
+	case "", formatJSON:
+		writeJSON(w, resp)
+	case formatCSV:
+		writeBatchCSV(w, resp)
+	case formatLabels:
+		writeBatchLabels(w, resp)
+	default:
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Unsupported format: "+format)
+	}
+}
+
+// handleInvalidateBatch revokes all outstanding device codes in a batch
+func (h *Handler) handleInvalidateBatch(w http.ResponseWriter, r *http.Request) {
+	batchID := chi.URLParam(r, "id")
+	revoked, err := h.flow.InvalidateBatch(r.Context(), batchID)
+	if err != nil {
+		writeFlowError(w, err)
+		return
+	}
+
+	writeJSON(w, InvalidateResponse{ID: batchID, Revoked: revoked})
+}
+
+// newBatchResponse converts a batch and its codes to the API representation
+func newBatchResponse(batch *deviceflow.Batch, codes []*deviceflow.DeviceCode) BatchResponse {
+	resp := BatchResponse{
+		ID:          batch.ID,
+		ClientID:    batch.ClientID,
+		Scope:       batch.Scope,
+		CreatedAt:   batch.CreatedAt,
+		ExpiresAt:   batch.ExpiresAt,
+		Invalidated: batch.Invalidated,
+		Codes:       make([]BatchCode, 0, len(codes)),
+	}
+	for _, code := range codes {
+		resp.Codes = append(resp.Codes, BatchCode{
+			DeviceCode:              code.DeviceCode,
+			UserCode:                code.UserCode,
+			VerificationURI:         code.VerificationURI,
+			VerificationURIComplete: code.VerificationURIComplete,
+			ExpiresAt:               code.ExpiresAt,
+			Interval:                code.Interval,
+			RequestedAt:             audit.OptionalTime(code.RequestedAt),
+			VerifiedAt:              audit.OptionalTime(code.VerifiedAt),
+			AuthorizedAt:            audit.OptionalTime(code.AuthorizedAt),
+			ConsumedAt:              audit.OptionalTime(code.ConsumedAt),
+		})
+	}
+	return resp
+}
+
+// writeBatchCSV writes one row per code for provisioning tools
+func writeBatchCSV(w http.ResponseWriter, resp BatchResponse) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="batch-%s.csv"`, resp.ID))
+
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"batch_id", "device_code", "user_code", "verification_uri", "verification_uri_complete", "expires_at", "interval"})
+	for _, code := range resp.Codes {
+		_ = cw.Write([]string{
+			resp.ID,
+			code.DeviceCode,
+			code.UserCode,
+			code.VerificationURI,
+			code.VerificationURIComplete,
+			code.ExpiresAt.UTC().Format(time.RFC3339),
+			strconv.Itoa(code.Interval),
+		})
+	}
+	cw.Flush()
+}
+
+// writeBatchLabels writes printable labels showing only what users need to enter.
+// Device codes are omitted since they must stay confidential to the device.
+func writeBatchLabels(w http.ResponseWriter, resp BatchResponse) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	for _, code := range resp.Codes {
+		fmt.Fprintf(w, "Visit:   %s\n", code.VerificationURI)
+		fmt.Fprintf(w, "Code:    %s\n", code.UserCode)
+		fmt.Fprintf(w, "Expires: %s\n\n", code.ExpiresAt.UTC().Format("2006-01-02"))
+	}
+}
+
+// writeFlowError maps device flow errors to admin API responses
+func writeFlowError(w http.ResponseWriter, err error) {
+	var dferr *deviceflow.DeviceFlowError
+	if errors.As(err, &dferr) && dferr.Code != deviceflow.ErrorCodeServerError {
+		common.WriteError(w, dferr.Code, dferr.Description)
+		return
+	}
+	common.WriteError(w, deviceflow.ErrorCodeServerError, "Internal server error")
+}
@@ -0,0 +1,169 @@
+package admin
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+func newBatchFixture() (*deviceflow.Batch, []*deviceflow.DeviceCode) {
+	expiresAt := time.Now().Add(24 * time.Hour)
+	batch := &deviceflow.Batch{
+		ID:          "batch-1",
+		ClientID:    "kiosk",
+		ExpiresAt:   expiresAt,
+		DeviceCodes: []string{"device-1"},
+	}
+	codes := []*deviceflow.DeviceCode{{
+		DeviceCode:      "device-1",
+		UserCode:        "BCDF-GHJK",
+		VerificationURI: "https://example.com/device",
+		ExpiresAt:       expiresAt,
+		Interval:        5,
+		RequestedAt:     expiresAt.Add(-24 * time.Hour),
+		VerifiedAt:      expiresAt.Add(-23 * time.Hour),
+	}}
+	return batch, codes
+}
+
+func serveAdmin(h *Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestCreateBatch(t *testing.T) {
+	var gotCount int
+	var gotExpiry time.Duration
+	flow := &test.MockFlow{
+		CreateBatchFunc: func(ctx context.Context, clientID, scope string, count int, expiry time.Duration) (*deviceflow.Batch, []*deviceflow.DeviceCode, error) {
+			gotCount, gotExpiry = count, expiry
+			if count > deviceflow.MaxBatchSize {
+				return nil, nil, deviceflow.NewDeviceFlowError(deviceflow.ErrorCodeInvalidRequest, "too many")
+			}
+			batch, codes := newBatchFixture()
+			return batch, codes, nil
+		},
+	}
+	h := New(Config{Token: "secret", Flow: flow})
+
+	w := serveAdmin(h, http.MethodPost, "/batches", `{"client_id":"kiosk","count":1,"expires_in":86400}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	if gotCount != 1 || gotExpiry != 24*time.Hour {
+		t.Errorf("CreateBatch called with count %d expiry %v", gotCount, gotExpiry)
+	}
+
+	var resp BatchResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.ID != "batch-1" || len(resp.Codes) != 1 || resp.Codes[0].DeviceCode != "device-1" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	if w := serveAdmin(h, http.MethodPost, "/batches", `{"client_id":"kiosk","count":5000}`); w.Code != http.StatusBadRequest {
+		t.Errorf("oversized batch status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := serveAdmin(h, http.MethodPost, "/batches", `not json`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid body status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetBatchFormats(t *testing.T) {
+	flow := &test.MockFlow{
+		GetBatchFunc: func(ctx context.Context, batchID string) (*deviceflow.Batch, []*deviceflow.DeviceCode, error) {
+			if batchID != "batch-1" {
+				return nil, nil, nil
+			}
+			batch, codes := newBatchFixture()
+			return batch, codes, nil
+		},
+	}
+	h := New(Config{Token: "secret", Flow: flow})
+
+	t.Run("json", func(t *testing.T) {
+		w := serveAdmin(h, http.MethodGet, "/batches/batch-1", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+		}
+		var resp BatchResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if len(resp.Codes) != 1 {
+			t.Fatalf("codes = %d, want 1", len(resp.Codes))
+		}
+		code := resp.Codes[0]
+		if code.RequestedAt == nil || code.VerifiedAt == nil || code.AuthorizedAt != nil || code.ConsumedAt != nil {
+			t.Errorf("timeline = %v/%v/%v/%v, want requested and verified only",
+				code.RequestedAt, code.VerifiedAt, code.AuthorizedAt, code.ConsumedAt)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		w := serveAdmin(h, http.MethodGet, "/batches/batch-1?format=csv", "")
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+			t.Errorf("Content-Type = %q, want text/csv", ct)
+		}
+		rows, err := csv.NewReader(w.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("parsing CSV: %v", err)
+		}
+		if len(rows) != 2 || rows[1][1] != "device-1" || rows[1][2] != "BCDF-GHJK" {
+			t.Errorf("rows = %v", rows)
+		}
+	})
+
+	t.Run("labels omit device code", func(t *testing.T) {
+		w := serveAdmin(h, http.MethodGet, "/batches/batch-1?format=labels", "")
+		body := w.Body.String()
+		if !strings.Contains(body, "BCDF-GHJK") || strings.Contains(body, "device-1") {
+			t.Errorf("labels = %q", body)
+		}
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		if w := serveAdmin(h, http.MethodGet, "/batches/batch-1?format=pdf", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+	})
+
+	t.Run("unknown batch", func(t *testing.T) {
+		if w := serveAdmin(h, http.MethodGet, "/batches/missing", ""); w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", w.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestInvalidateBatch(t *testing.T) {
+	flow := &test.MockFlow{
+		InvalidateBatchFunc: func(ctx context.Context, batchID string) (int, error) {
+			return 3, nil
+		},
+	}
+	h := New(Config{Token: "secret", Flow: flow})
+
+	w := serveAdmin(h, http.MethodDelete, "/batches/batch-1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var resp InvalidateResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.ID != "batch-1" || resp.Revoked != 3 {
+		t.Errorf("response = %+v", resp)
+	}
+}
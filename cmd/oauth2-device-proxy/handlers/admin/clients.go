@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// ClientCode describes an outstanding device code of a client. The device code
+// itself is a bearer secret, so only its audit trail hash is shown.
+type ClientCode struct {
+	DeviceCodeHash string    `json:"device_code_hash"`
+	UserCode       string    `json:"user_code"`
+	Scope          string    `json:"scope,omitempty"`
+	DeviceID       string    `json:"device_id,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at"`
+
+	// Progress of the code's flow, omitted for steps not yet reached
+	RequestedAt  *time.Time `json:"requested_at,omitempty"`
+	VerifiedAt   *time.Time `json:"verified_at,omitempty"`
+	AuthorizedAt *time.Time `json:"authorized_at,omitempty"`
+}
+
+// ClientCodesResponse lists a client's outstanding device codes, soonest to
+// expire first
+type ClientCodesResponse struct {
+	ClientID string       `json:"client_id"`
+	Count    int          `json:"count"`
+	Codes    []ClientCode `json:"codes"`
+}
+
+// RevokeClientRequest optionally explains why a client's codes are revoked
+type RevokeClientRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// RevokeClientResponse reports what revoking a client removed
+type RevokeClientResponse struct {
+	ClientID      string `json:"client_id"`
+	RevokedCodes  int    `json:"revoked_codes"`
+	RevokedGrants int    `json:"revoked_grants"`
+}
+
+// handleClientCodes lists the outstanding device codes of a client
+func (h *Handler) handleClientCodes(w http.ResponseWriter, r *http.Request) {
+	clientID := chi.URLParam(r, "id")
+	codes, err := h.flow.ListClientCodes(r.Context(), clientID)
+	if err != nil {
+		writeFlowError(w, err)
+		return
+	}
+
+	resp := ClientCodesResponse{ClientID: clientID, Count: len(codes), Codes: make([]ClientCode, 0, len(codes))}
+	for _, code := range codes {
+		entry := ClientCode{
+			DeviceCodeHash: audit.HashDeviceCode(code.DeviceCode),
+			UserCode:       code.UserCode,
+			Scope:          code.Scope,
+			ExpiresAt:      code.ExpiresAt,
+			RequestedAt:    audit.OptionalTime(code.RequestedAt),
+			VerifiedAt:     audit.OptionalTime(code.VerifiedAt),
+			AuthorizedAt:   audit.OptionalTime(code.AuthorizedAt),
+		}
+		if code.Device != nil {
+			entry.DeviceID = code.Device.ID
+		}
+		resp.Codes = append(resp.Codes, entry)
+	}
+	sort.Slice(resp.Codes, func(i, j int) bool {
+		return resp.Codes[i].ExpiresAt.Before(resp.Codes[j].ExpiresAt)
+	})
+
+	writeJSON(w, resp)
+}
+
+// handleRevokeClient revokes every outstanding device code and undelivered
+// token of a client, such as one running compromised firmware, along with its
+// offline grants when the proxy keeps them
+func (h *Handler) handleRevokeClient(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	clientID := chi.URLParam(r, "id")
+
+	// The body is optional
+	var req RevokeClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	revokedCodes, err := h.flow.RevokeClient(ctx, clientID)
+	if err != nil {
+		writeFlowError(w, err)
+		return
+	}
+	resp := RevokeClientResponse{ClientID: clientID, RevokedCodes: revokedCodes}
+
+	if h.grants != nil {
+		grants, err := h.grants.OfflineGrants(ctx)
+		if err != nil {
+			log.Printf("Error: listing offline grants: %v", err)
+			common.WriteError(w, deviceflow.ErrorCodeServerError, "Failed to list offline grants")
+			return
+		}
+		for _, grant := range grants {
+			if grant.ClientID != clientID {
+				continue
+			}
+			if _, err := h.grants.RevokeOffline(ctx, grant.ID); err != nil {
+				log.Printf("Error: revoking offline grant: %v", err)
+				common.WriteErrorStatus(w, http.StatusBadGateway, deviceflow.ErrorCodeServerError, "Failed to revoke the client's offline grants")
+				return
+			}
+			resp.RevokedGrants++
+		}
+	}
+
+	if err := h.audit.Record(ctx, audit.Record{
+		Action:   audit.ActionClientRevoked,
+		ClientID: clientID,
+		Reason:   req.Reason,
+		RemoteIP: common.ClientIP(r),
+	}); err != nil {
+		log.Printf("Error: failed to record %s audit entry: %v", audit.ActionClientRevoked, err)
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+)
+
+func TestRevokeClient(t *testing.T) {
+	var revokedClient string
+	flow := &test.MockFlow{
+		RevokeClientFunc: func(ctx context.Context, clientID string) (int, error) {
+			revokedClient = clientID
+			return 4, nil
+		},
+	}
+	grants := &mockGrants{grants: map[string]*renewal.Grant{
+		"a": {ID: "a", ClientID: "tv", Offline: true},
+		"b": {ID: "b", ClientID: "kiosk", Offline: true},
+	}}
+	recorder := &mockAudit{}
+	h := New(Config{Token: "secret", Flow: flow, Audit: recorder, Grants: grants})
+
+	w := serveAdmin(h, http.MethodPost, "/clients/tv/revoke", `{"reason":"compromised firmware 2.1"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	var resp RevokeClientResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.ClientID != "tv" || resp.RevokedCodes != 4 || resp.RevokedGrants != 1 {
+		t.Errorf("response = %+v", resp)
+	}
+	if revokedClient != "tv" {
+		t.Errorf("revoked codes of %q, want tv", revokedClient)
+	}
+	if _, kept := grants.grants["b"]; !kept || len(grants.grants) != 1 {
+		t.Errorf("remaining grants = %v, want only kiosk's", grants.grants)
+	}
+	if len(recorder.records) != 1 || recorder.records[0].Action != audit.ActionClientRevoked ||
+		recorder.records[0].ClientID != "tv" || recorder.records[0].Reason != "compromised firmware 2.1" {
+		t.Errorf("audit records = %+v, want one tv revocation", recorder.records)
+	}
+
+	// The body is optional
+	if w := serveAdmin(h, http.MethodPost, "/clients/tv/revoke", ""); w.Code != http.StatusOK {
+		t.Errorf("status without body = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := serveAdmin(h, http.MethodPost, "/clients/tv/revoke", "{"); w.Code != http.StatusBadRequest {
+		t.Errorf("status with invalid body = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestClientCodes(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	flow := &test.MockFlow{
+		ListClientCodesFunc: func(ctx context.Context, clientID string) ([]*deviceflow.DeviceCode, error) {
+			if clientID != "tv" {
+				return nil, nil
+			}
+			return []*deviceflow.DeviceCode{
+				{DeviceCode: "device-later", UserCode: "BCDF-GHJK", ClientID: "tv", ExpiresAt: now.Add(time.Hour)},
+				{DeviceCode: "device-sooner", UserCode: "LMNP-QRST", ClientID: "tv", ExpiresAt: now.Add(time.Minute),
+					Device: &deviceflow.DeviceIdentity{ID: "serial-42"}, AuthorizedAt: now},
+			}, nil
+		},
+	}
+	h := New(Config{Token: "secret", Flow: flow})
+
+	w := serveAdmin(h, http.MethodGet, "/clients/tv/codes", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if strings.Contains(w.Body.String(), "device-later") {
+		t.Error("listing exposes device codes")
+	}
+	var resp ClientCodesResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.ClientID != "tv" || resp.Count != 2 || len(resp.Codes) != 2 {
+		t.Fatalf("response = %+v", resp)
+	}
+	sooner := resp.Codes[0]
+	if sooner.UserCode != "LMNP-QRST" || sooner.DeviceID != "serial-42" || sooner.AuthorizedAt == nil ||
+		sooner.DeviceCodeHash != audit.HashDeviceCode("device-sooner") {
+		t.Errorf("first code = %+v, want the one expiring soonest", sooner)
+	}
+}
@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+)
+
+// GrantManager lists and revokes the offline grants held by the proxy
+type GrantManager interface {
+	OfflineGrants(ctx context.Context) ([]*renewal.Grant, error)
+	RevokeOffline(ctx context.Context, id string) (*renewal.Grant, error)
+}
+
+// OfflineGrant describes an offline grant without its tokens
+type OfflineGrant struct {
+	ID        string    `json:"id"`
+	ClientID  string    `json:"client_id"`
+	Scope     string    `json:"scope,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OfflineGrantsResponse lists offline grants, oldest first
+type OfflineGrantsResponse struct {
+	Grants []OfflineGrant `json:"grants"`
+}
+
+// handleOfflineGrants lists offline grants, or those of the client given by client_id
+func (h *Handler) handleOfflineGrants(w http.ResponseWriter, r *http.Request) {
+	grants, err := h.grants.OfflineGrants(r.Context())
+	if err != nil {
+		log.Printf("Error: listing offline grants: %v", err)
+		common.WriteError(w, deviceflow.ErrorCodeServerError, "Failed to list offline grants")
+		return
+	}
+
+	clientID := r.URL.Query().Get("client_id")
+	resp := OfflineGrantsResponse{Grants: make([]OfflineGrant, 0, len(grants))}
+	for _, grant := range grants {
+		if clientID == "" || grant.ClientID == clientID {
+			resp.Grants = append(resp.Grants, OfflineGrant{
+				ID:        grant.ID,
+				ClientID:  grant.ClientID,
+				Scope:     grant.Scope,
+				CreatedAt: grant.CreatedAt,
+			})
+		}
+	}
+	sort.Slice(resp.Grants, func(i, j int) bool {
+		return resp.Grants[i].CreatedAt.Before(resp.Grants[j].CreatedAt)
+	})
+
+	writeJSON(w, resp)
+}
+
+// handleRevokeOfflineGrant revokes an offline grant at the identity provider
+// and removes it, after which the device can no longer renew its access token
+func (h *Handler) handleRevokeOfflineGrant(w http.ResponseWriter, r *http.Request) {
+	grant, err := h.grants.RevokeOffline(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, renewal.ErrUnknownGrant) {
+		common.WriteErrorStatus(w, http.StatusNotFound, deviceflow.ErrorCodeInvalidRequest, "Unknown offline grant")
+		return
+	}
+	if err != nil {
+		log.Printf("Error: revoking offline grant: %v", err)
+		common.WriteErrorStatus(w, http.StatusBadGateway, deviceflow.ErrorCodeServerError, "Failed to revoke the offline grant")
+		return
+	}
+
+	if err := h.audit.Record(r.Context(), audit.Record{
+		Action:   audit.ActionOfflineRevoked,
+		ClientID: grant.ClientID,
+		Scope:    grant.Scope,
+		RemoteIP: common.ClientIP(r),
+	}); err != nil {
+		log.Printf("Error: failed to record %s audit entry: %v", audit.ActionOfflineRevoked, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,98 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+)
+
+type mockGrants struct {
+	grants    map[string]*renewal.Grant
+	revokeErr error
+}
+
+func (m *mockGrants) OfflineGrants(ctx context.Context) ([]*renewal.Grant, error) {
+	var grants []*renewal.Grant
+	for _, grant := range m.grants {
+		grants = append(grants, grant)
+	}
+	return grants, nil
+}
+
+func (m *mockGrants) RevokeOffline(ctx context.Context, id string) (*renewal.Grant, error) {
+	grant, ok := m.grants[id]
+	if !ok {
+		return nil, renewal.ErrUnknownGrant
+	}
+	if m.revokeErr != nil {
+		return nil, m.revokeErr
+	}
+	delete(m.grants, id)
+	return grant, nil
+}
+
+func TestOfflineGrants(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	grants := &mockGrants{grants: map[string]*renewal.Grant{
+		"b": {ID: "b", ClientID: "tv", Scope: "openid offline_access", RefreshToken: "secret-b", CreatedAt: now, Offline: true},
+		"a": {ID: "a", ClientID: "kiosk", RefreshToken: "secret-a", CreatedAt: now.Add(-time.Hour), Offline: true},
+	}}
+	recorder := &mockAudit{}
+	h := New(Config{Token: "secret", Audit: recorder, Grants: grants})
+
+	do := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/grants/offline")
+	if w.Code != http.StatusOK {
+		t.Fatalf("list status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if strings.Contains(w.Body.String(), "secret-") {
+		t.Error("listing exposes refresh tokens")
+	}
+	var resp OfflineGrantsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(resp.Grants) != 2 || resp.Grants[0].ID != "a" || resp.Grants[1].ID != "b" {
+		t.Errorf("grants = %+v, want a then b", resp.Grants)
+	}
+
+	w = do(http.MethodGet, "/grants/offline?client_id=tv")
+	resp = OfflineGrantsResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(resp.Grants) != 1 || resp.Grants[0].ClientID != "tv" {
+		t.Errorf("filtered grants = %+v, want only tv", resp.Grants)
+	}
+
+	grants.revokeErr = errors.New("provider down")
+	if w := do(http.MethodDelete, "/grants/offline/a"); w.Code != http.StatusBadGateway {
+		t.Errorf("failed revocation status = %d, want %d", w.Code, http.StatusBadGateway)
+	}
+	grants.revokeErr = nil
+
+	if w := do(http.MethodDelete, "/grants/offline/a"); w.Code != http.StatusNoContent {
+		t.Errorf("revocation status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if len(recorder.records) != 1 || recorder.records[0].Action != audit.ActionOfflineRevoked || recorder.records[0].ClientID != "kiosk" {
+		t.Errorf("audit records = %+v, want one kiosk revocation", recorder.records)
+	}
+	if w := do(http.MethodDelete, "/grants/offline/a"); w.Code != http.StatusNotFound {
+		t.Errorf("repeated revocation status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
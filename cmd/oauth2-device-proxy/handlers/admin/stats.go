@@ -0,0 +1,39 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/stats"
+)
+
+// StatsSource reports per-client device flow conversion stats
+type StatsSource interface {
+	Stats(ctx context.Context) ([]stats.ClientStats, error)
+}
+
+// StatsResponse lists per-client stats ordered by client ID
+type StatsResponse struct {
+	Clients []stats.ClientStats `json:"clients"`
+}
+
+// handleStats returns conversion stats for every client, or the one given by client_id
+func (h *Handler) handleStats(w http.ResponseWriter, r *http.Request) {
+	all, err := h.stats.Stats(r.Context())
+	if err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeServerError, "Failed to read stats")
+		return
+	}
+
+	clients := make([]stats.ClientStats, 0, len(all))
+	clientID := r.URL.Query().Get("client_id")
+	for _, s := range all {
+		if clientID == "" || s.ClientID == clientID {
+			clients = append(clients, s)
+		}
+	}
+
+	writeJSON(w, StatsResponse{Clients: clients})
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+	"github.com/wrale/oauth2-device-proxy/internal/tokencache"
+)
+
+// AssertionVerifier authenticates clients by their JWT assertions, as
+// tokencache.AssertionVerifier does
+type AssertionVerifier interface {
+	VerifyAssertion(ctx context.Context, assertion string) (string, error)
+}
+
+// ClientAuthenticator identifies the client of a device authorization or
+// token request. Public clients send only client_id; confidential clients
+// authenticate with a private_key_jwt assertion per RFC 7523 section 2.2. A
+// nil ClientAuthenticator accepts public clients only.
+type ClientAuthenticator struct {
+	Verifier AssertionVerifier
+	Required func(clientID string) bool // Reports clients that must send an assertion
+	Known    func(clientID string) bool // Reports registered clients, nil accepts any client
+
+	// FailureDelay is the least time a failed authentication takes, so that
+	// unknown clients, confidential clients without assertions and forged
+	// assertions cannot be told apart by response time to discover client IDs
+	FailureDelay time.Duration
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration)
+}
+
+// Authenticate returns the client ID of a parsed request and whether the
+// client authenticated. Failures are DeviceFlowErrors to relay to the client;
+// invalid_client failures take at least FailureDelay.
+func (a *ClientAuthenticator) Authenticate(r *http.Request) (string, bool, error) {
+	if a == nil || a.FailureDelay <= 0 {
+		return a.authenticate(r)
+	}
+
+	now := a.now
+	if now == nil {
+		now = time.Now
+	}
+	start := now()
+	clientID, authenticated, err := a.authenticate(r)
+	if errors.Is(err, deviceflow.ErrInvalidClient) {
+		sleep := a.sleep
+		if sleep == nil {
+			sleep = sleepContext
+		}
+		sleep(r.Context(), a.FailureDelay-now().Sub(start))
+	}
+	return clientID, authenticated, err
+}
+
+// authenticate identifies the client of a request
+func (a *ClientAuthenticator) authenticate(r *http.Request) (string, bool, error) {
+	clientID := r.Form.Get("client_id")
+	assertionType := r.Form.Get("client_assertion_type")
+	assertion := r.Form.Get("client_assertion")
+
+	if assertionType == "" && assertion == "" {
+		if clientID == "" {
+			return "", false, deviceflow.ErrMissingClientID
+		}
+		if !a.known(clientID) {
+			return "", false, deviceflow.ErrInvalidClient
+		}
+		if a != nil && a.Required != nil && a.Required(clientID) {
+			return "", false, deviceflow.ErrInvalidClient
+		}
+		return clientID, false, nil
+	}
+
+	if a == nil || a.Verifier == nil || assertionType != oauth.ClientAssertionType || assertion == "" {
+		return "", false, deviceflow.ErrInvalidClient
+	}
+	subject, err := a.Verifier.VerifyAssertion(r.Context(), assertion)
+	if err != nil {
+		if errors.Is(err, tokencache.ErrInvalidAssertion) {
+			log.Printf("Rejected client assertion: %v", err)
+			return "", false, deviceflow.ErrInvalidClient
+		}
+		log.Printf("Error verifying client assertion: %v", err)
+		return "", false, deviceflow.ErrServerError
+	}
+
+	// client_id is optional with an assertion but must agree with it
+	if clientID != "" && clientID != subject {
+		return "", false, deviceflow.ErrInvalidClient
+	}
+	if !a.known(subject) {
+		return "", false, deviceflow.ErrInvalidClient
+	}
+	return subject, true, nil
+}
+
+// known reports whether a client may use the proxy
+func (a *ClientAuthenticator) known(clientID string) bool {
+	return a == nil || a.Known == nil || a.Known(clientID)
+}
+
+// sleepContext waits for d unless the request ends first
+func sleepContext(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+	"github.com/wrale/oauth2-device-proxy/internal/tokencache"
+)
+
+// fakeClock is a virtual clock advanced by verification work and sleeps
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) {
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+}
+
+// slowVerifier accepts the assertion "valid" for its subject, spending the
+// given virtual time on every assertion as signature checks would
+type slowVerifier struct {
+	clock   *fakeClock
+	subject string
+	cost    time.Duration
+}
+
+func (v slowVerifier) VerifyAssertion(ctx context.Context, assertion string) (string, error) {
+	v.clock.now = v.clock.now.Add(v.cost)
+	if assertion != "valid" {
+		return "", tokencache.ErrInvalidAssertion
+	}
+	return v.subject, nil
+}
+
+func formRequest(body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/device/code", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := r.ParseForm(); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func TestAuthenticateKnownClients(t *testing.T) {
+	auth := &ClientAuthenticator{
+		Known: func(clientID string) bool { return clientID == "tv" },
+	}
+
+	if clientID, _, err := auth.Authenticate(formRequest("client_id=tv")); err != nil || clientID != "tv" {
+		t.Errorf("registered client = %q, %v; want tv", clientID, err)
+	}
+	if _, _, err := auth.Authenticate(formRequest("client_id=unknown")); !errors.Is(err, deviceflow.ErrInvalidClient) {
+		t.Errorf("unknown client error = %v, want %v", err, deviceflow.ErrInvalidClient)
+	}
+	if _, _, err := auth.Authenticate(formRequest("scope=read")); !errors.Is(err, deviceflow.ErrMissingClientID) {
+		t.Errorf("missing client error = %v, want %v", err, deviceflow.ErrMissingClientID)
+	}
+
+	// Without a registry any client is accepted
+	var open *ClientAuthenticator
+	if clientID, _, err := open.Authenticate(formRequest("client_id=unknown")); err != nil || clientID != "unknown" {
+		t.Errorf("client without registry = %q, %v; want unknown", clientID, err)
+	}
+}
+
+// Failures for unknown client IDs must not be distinguishable by timing from
+// failures for registered ones, or client IDs could be discovered by probing
+func TestAuthenticateFailureTiming(t *testing.T) {
+	const delay = 100 * time.Millisecond
+	assertion := "&client_assertion_type=" + url.QueryEscape(oauth.ClientAssertionType) + "&client_assertion="
+
+	tests := []struct {
+		name    string
+		body    string
+		subject string
+		wantErr error
+		want    time.Duration
+	}{
+		{name: "unknown public client", body: "client_id=unknown",
+			wantErr: deviceflow.ErrInvalidClient, want: delay},
+		{name: "registered confidential client without assertion", body: "client_id=tv",
+			wantErr: deviceflow.ErrInvalidClient, want: delay},
+		{name: "forged assertion for a registered client", body: "client_id=tv" + assertion + "forged",
+			wantErr: deviceflow.ErrInvalidClient, want: delay},
+		{name: "valid assertion for an unknown client", body: assertion + "valid", subject: "unknown",
+			wantErr: deviceflow.ErrInvalidClient, want: delay},
+		{name: "successful authentication is not delayed", body: assertion + "valid", subject: "tv",
+			want: 30 * time.Millisecond},
+		{name: "registered public client is not delayed", body: "client_id=printer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: time.Unix(1700000000, 0)}
+			auth := &ClientAuthenticator{
+				Verifier:     slowVerifier{clock: clock, subject: tt.subject, cost: 30 * time.Millisecond},
+				Required:     func(clientID string) bool { return clientID == "tv" },
+				Known:        func(clientID string) bool { return clientID == "tv" || clientID == "printer" },
+				FailureDelay: delay,
+				now:          clock.Now,
+				sleep:        clock.Sleep,
+			}
+
+			start := clock.Now()
+			_, _, err := auth.Authenticate(formRequest(tt.body))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate error = %v, want %v", err, tt.wantErr)
+			}
+			if got := clock.Now().Sub(start); got != tt.want {
+				t.Errorf("Authenticate took %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,12 +4,19 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
 )
 
 // RFC 8628 Compliant Error Response
 type ErrorResponse struct {
 	Error            string `json:"error"`
 	ErrorDescription string `json:"error_description,omitempty"`
+	ErrorURI         string `json:"error_uri,omitempty"`
+
+	// Interval is the polling interval in seconds a device must now use, sent
+	// with authorization_pending and slow_down errors for clients that read it
+	Interval int `json:"interval,omitempty"`
 }
 
 // SetJSONHeaders sets required headers for JSON responses per RFC 8628
@@ -20,16 +27,47 @@ func SetJSONHeaders(w http.ResponseWriter) {
 
 // WriteError sends a standardized error response per RFC 8628 section 3.5
 func WriteError(w http.ResponseWriter, code string, description string) {
+	WriteErrorStatus(w, StatusFor(code), code, description)
+}
+
+// StatusFor returns the HTTP status of an OAuth error response. Client
+// authentication failures are 401 Unauthorized per RFC 6749 section 5.2, and
+// failures of the proxy or the services behind it are 5xx so that clients and
+// load balancers do not mistake them for rejected requests.
+func StatusFor(code string) int {
+	switch code {
+	case deviceflow.ErrorCodeInvalidClient, deviceflow.ErrorCodeInvalidToken:
+		return http.StatusUnauthorized
+	case deviceflow.ErrorCodeServerError:
+		return http.StatusInternalServerError
+	case deviceflow.ErrorCodeTemporarilyUnavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// WriteErrorStatus sends a standardized error response with an explicit status code
+func WriteErrorStatus(w http.ResponseWriter, status int, code string, description string) {
+	writeErrorResponse(w, status, ErrorResponse{Error: code, ErrorDescription: description})
+}
+
+// WriteErrorResponse sends an error response, with the status for its code,
+// that may carry members beyond the error code and description
+func WriteErrorResponse(w http.ResponseWriter, response ErrorResponse) {
+	writeErrorResponse(w, StatusFor(response.Error), response)
+}
+
+func writeErrorResponse(w http.ResponseWriter, status int, response ErrorResponse) {
 	// First set required headers per RFC 8628
 	SetJSONHeaders(w)
-
-	response := ErrorResponse{
-		Error:            code,
-		ErrorDescription: strings.TrimSpace(description),
+	response.ErrorDescription = strings.TrimSpace(response.ErrorDescription)
+	if lw, ok := w.(*localizingWriter); ok {
+		response = lw.localize(response)
 	}
 
 	// Set status code and write response
-	w.WriteHeader(http.StatusBadRequest)
+	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		WriteJSONError(w, err)
 		return
@@ -6,6 +6,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
 )
 
 func TestWriteError(t *testing.T) {
@@ -72,6 +74,23 @@ func TestWriteError(t *testing.T) {
 	}
 }
 
+func TestStatusFor(t *testing.T) {
+	tests := map[string]int{
+		deviceflow.ErrorCodeInvalidRequest:         http.StatusBadRequest,
+		deviceflow.ErrorCodeAuthorizationPending:   http.StatusBadRequest,
+		deviceflow.ErrorCodeInvalidScope:           http.StatusBadRequest,
+		deviceflow.ErrorCodeInvalidClient:          http.StatusUnauthorized,
+		deviceflow.ErrorCodeInvalidToken:           http.StatusUnauthorized,
+		deviceflow.ErrorCodeServerError:            http.StatusInternalServerError,
+		deviceflow.ErrorCodeTemporarilyUnavailable: http.StatusServiceUnavailable,
+	}
+	for code, want := range tests {
+		if got := StatusFor(code); got != want {
+			t.Errorf("StatusFor(%q) = %d, want %d", code, got, want)
+		}
+	}
+}
+
 func TestWriteJSONError(t *testing.T) {
 	// Create a test error by trying to decode invalid JSON
 	testErr := json.NewDecoder(strings.NewReader("{invalid")).Decode(&struct{}{})
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// ParseAcceptLanguage returns the language tags of an Accept-Language header
+// per RFC 9110 section 12.5.4, most preferred first. Wildcards and tags with
+// a quality of zero are left out.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag     string
+		quality float64
+	}
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil || q < 0 || q > 1 {
+				q = 0
+			}
+			quality = q
+		}
+		if quality > 0 {
+			langs = append(langs, weighted{tag: tag, quality: quality})
+		}
+	}
+
+	// Equal qualities keep the order the client listed them in
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].quality > langs[j].quality })
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
+
+// LocalizeErrors translates the descriptions of JSON error responses written
+// by the wrapped handler into the language the client prefers, and links them
+// to their help page under uriBase, if set, as error_uri
+func LocalizeErrors(uriBase string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&localizingWriter{
+				ResponseWriter: w,
+				languages:      ParseAcceptLanguage(r.Header.Get("Accept-Language")),
+				uriBase:        uriBase,
+			}, r)
+		})
+	}
+}
+
+// localizingWriter carries the request's language preferences to the error
+// response writers
+type localizingWriter struct {
+	http.ResponseWriter
+	languages []string
+	uriBase   string
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (w *localizingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// localize translates and links an error response, marking the language it
+// is in when translated
+func (w *localizingWriter) localize(response ErrorResponse) ErrorResponse {
+	w.Header().Add("Vary", "Accept-Language")
+	if w.uriBase != "" && response.ErrorURI == "" {
+		response.ErrorURI = deviceflow.ErrorURI(w.uriBase, response.Error)
+	}
+	description, lang := deviceflow.LocalizeDescription(response.ErrorDescription, w.languages)
+	if lang != "en" {
+		response.ErrorDescription = description
+		w.Header().Set("Content-Language", lang)
+	}
+	return response
+}
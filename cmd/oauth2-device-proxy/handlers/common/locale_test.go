@@ -0,0 +1,105 @@
+package common
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{header: "", want: []string{}},
+		{header: "de", want: []string{"de"}},
+		{header: "fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5", want: []string{"fr-ch", "fr", "en", "de"}},
+		{header: "en;q=0.5, es", want: []string{"es", "en"}},
+		{header: "de;q=0, fr;q=bad, es;q=0.1", want: []string{"es"}},
+	}
+
+	for _, tt := range tests {
+		if got := ParseAcceptLanguage(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseAcceptLanguage(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestLocalizeErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		language     string
+		uriBase      string
+		description  string
+		wantDesc     string
+		wantURI      string
+		wantLanguage string
+	}{
+		{
+			name:        "english by default",
+			description: deviceflow.ErrorDescExpiredToken,
+			wantDesc:    deviceflow.ErrorDescExpiredToken,
+		},
+		{
+			name:         "translated description",
+			language:     "de-DE,de;q=0.9,en;q=0.5",
+			description:  deviceflow.ErrorDescExpiredToken,
+			wantDesc:     "Der device_code ist abgelaufen",
+			wantLanguage: "de",
+		},
+		{
+			name:        "english preferred over a supported language",
+			language:    "en-GB, fr;q=0.8",
+			description: deviceflow.ErrorDescExpiredToken,
+			wantDesc:    deviceflow.ErrorDescExpiredToken,
+		},
+		{
+			name:        "unknown description stays in english",
+			language:    "fr",
+			description: "Scope admin is not allowed",
+			wantDesc:    "Scope admin is not allowed",
+		},
+		{
+			name:        "error uri under base",
+			uriBase:     "https://docs.example.com/errors/",
+			description: deviceflow.ErrorDescExpiredToken,
+			wantDesc:    deviceflow.ErrorDescExpiredToken,
+			wantURI:     "https://docs.example.com/errors/expired_token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := LocalizeErrors(tt.uriBase)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				WriteError(w, deviceflow.ErrorCodeExpiredToken, tt.description)
+			}))
+			r := httptest.NewRequest(http.MethodPost, "/device/token", nil)
+			if tt.language != "" {
+				r.Header.Set("Accept-Language", tt.language)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			var resp ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if resp.ErrorDescription != tt.wantDesc {
+				t.Errorf("error_description = %q, want %q", resp.ErrorDescription, tt.wantDesc)
+			}
+			if resp.ErrorURI != tt.wantURI {
+				t.Errorf("error_uri = %q, want %q", resp.ErrorURI, tt.wantURI)
+			}
+			if got := w.Header().Get("Content-Language"); got != tt.wantLanguage {
+				t.Errorf("Content-Language = %q, want %q", got, tt.wantLanguage)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept-Language" {
+				t.Errorf("Vary = %q, want Accept-Language", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package common
+
+import (
+	"net"
+	"net/http"
+)
+
+// ClientIP returns the request's remote address without the port. Behind a
+// proxy this relies on the RealIP middleware having rewritten RemoteAddr.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
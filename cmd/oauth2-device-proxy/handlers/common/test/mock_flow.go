@@ -3,6 +3,7 @@ package test
 
 import (
 	"context"
+	"time"
 
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
 )
@@ -11,11 +12,29 @@ import (
 type MockFlow struct {
 	// Common test functions that can be overridden
 	CheckHealthFunc       func(ctx context.Context) error
-	RequestDeviceCodeFunc func(ctx context.Context, clientID string, scope string) (*deviceflow.DeviceCode, error)
+	CheckCapacityFunc     func(ctx context.Context) error
+	RequestDeviceCodeFunc func(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error)
 	GetDeviceCodeFunc     func(ctx context.Context, deviceCode string) (*deviceflow.DeviceCode, error)
+	RefreshUserCodeFunc   func(ctx context.Context, deviceCode, clientID string) (*deviceflow.DeviceCode, error)
 	CheckDeviceCodeFunc   func(ctx context.Context, deviceCode string) (*deviceflow.TokenResponse, error)
+	WaitForTokenFunc      func(ctx context.Context, deviceCode string) (*deviceflow.TokenResponse, error)
+	GetStatusFunc         func(ctx context.Context, deviceCode string) (deviceflow.Status, error)
 	VerifyUserCodeFunc    func(ctx context.Context, userCode string) (*deviceflow.DeviceCode, error)
 	CompleteAuthFunc      func(ctx context.Context, deviceCode string, token *deviceflow.TokenResponse) error
+	DenyAuthFunc          func(ctx context.Context, deviceCode string) error
+	FailAuthFunc          func(ctx context.Context, deviceCode string, failure *deviceflow.DeviceFlowError) error
+	ClaimSubmissionFunc   func(ctx context.Context, nonce string) (*deviceflow.SubmissionResult, error)
+	RecordSubmissionFunc  func(ctx context.Context, nonce string, result *deviceflow.SubmissionResult) error
+	CreateBatchFunc       func(ctx context.Context, clientID, scope string, count int, expiry time.Duration) (*deviceflow.Batch, []*deviceflow.DeviceCode, error)
+	GetBatchFunc          func(ctx context.Context, batchID string) (*deviceflow.Batch, []*deviceflow.DeviceCode, error)
+	InvalidateBatchFunc   func(ctx context.Context, batchID string) (int, error)
+	ListClientCodesFunc   func(ctx context.Context, clientID string) ([]*deviceflow.DeviceCode, error)
+	RevokeClientFunc      func(ctx context.Context, clientID string) (int, error)
+	AllowsCompleteURIFunc func(ctx context.Context, userCode string) bool
+	BeginConsentFunc      func(ctx context.Context, deviceCode string) (string, error)
+	ResolveConsentFunc    func(ctx context.Context, ticket string) (*deviceflow.DeviceCode, error)
+	IssueCallbackFunc     func(ctx context.Context, deviceCode string) (string, error)
+	ConsumeCallbackFunc   func(ctx context.Context, deviceCode, nonce string) error
 }
 
 // Ensure MockFlow implements Flow interface
@@ -29,10 +48,18 @@ func (m *MockFlow) CheckHealth(ctx context.Context) error {
 	return nil
 }
 
+// CheckCapacity implements deviceflow.Flow
+func (m *MockFlow) CheckCapacity(ctx context.Context) error {
+	if m.CheckCapacityFunc != nil {
+		return m.CheckCapacityFunc(ctx)
+	}
+	return nil
+}
+
 // RequestDeviceCode implements deviceflow.Flow
-func (m *MockFlow) RequestDeviceCode(ctx context.Context, clientID string, scope string) (*deviceflow.DeviceCode, error) {
+func (m *MockFlow) RequestDeviceCode(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
 	if m.RequestDeviceCodeFunc != nil {
-		return m.RequestDeviceCodeFunc(ctx, clientID, scope)
+		return m.RequestDeviceCodeFunc(ctx, clientID, scope, opts...)
 	}
 	return nil, nil
 }
@@ -45,6 +72,14 @@ func (m *MockFlow) GetDeviceCode(ctx context.Context, deviceCode string) (*devic
 	return nil, nil
 }
 
+// RefreshUserCode implements deviceflow.Flow
+func (m *MockFlow) RefreshUserCode(ctx context.Context, deviceCode, clientID string) (*deviceflow.DeviceCode, error) {
+	if m.RefreshUserCodeFunc != nil {
+		return m.RefreshUserCodeFunc(ctx, deviceCode, clientID)
+	}
+	return nil, nil
+}
+
 // CheckDeviceCode implements deviceflow.Flow per RFC 8628 section 3.5 error order:
 // 1. Store errors take precedence (authorization server errors)
 // 2. Code existence and expiry (expired_token)
@@ -57,6 +92,22 @@ func (m *MockFlow) CheckDeviceCode(ctx context.Context, deviceCode string) (*dev
 	return nil, nil
 }
 
+// WaitForToken implements deviceflow.Flow
+func (m *MockFlow) WaitForToken(ctx context.Context, deviceCode string) (*deviceflow.TokenResponse, error) {
+	if m.WaitForTokenFunc != nil {
+		return m.WaitForTokenFunc(ctx, deviceCode)
+	}
+	return m.CheckDeviceCode(ctx, deviceCode)
+}
+
+// GetStatus implements deviceflow.Flow
+func (m *MockFlow) GetStatus(ctx context.Context, deviceCode string) (deviceflow.Status, error) {
+	if m.GetStatusFunc != nil {
+		return m.GetStatusFunc(ctx, deviceCode)
+	}
+	return deviceflow.StatusPending, nil
+}
+
 // VerifyUserCode implements deviceflow.Flow
 func (m *MockFlow) VerifyUserCode(ctx context.Context, userCode string) (*deviceflow.DeviceCode, error) {
 	if m.VerifyUserCodeFunc != nil {
@@ -72,3 +123,115 @@ func (m *MockFlow) CompleteAuthorization(ctx context.Context, deviceCode string,
 	}
 	return nil
 }
+
+// DenyAuthorization implements deviceflow.Flow
+func (m *MockFlow) DenyAuthorization(ctx context.Context, deviceCode string) error {
+	if m.DenyAuthFunc != nil {
+		return m.DenyAuthFunc(ctx, deviceCode)
+	}
+	return nil
+}
+
+// FailAuthorization implements deviceflow.Flow
+func (m *MockFlow) FailAuthorization(ctx context.Context, deviceCode string, failure *deviceflow.DeviceFlowError) error {
+	if m.FailAuthFunc != nil {
+		return m.FailAuthFunc(ctx, deviceCode, failure)
+	}
+	return nil
+}
+
+// ClaimSubmission implements deviceflow.Flow
+func (m *MockFlow) ClaimSubmission(ctx context.Context, nonce string) (*deviceflow.SubmissionResult, error) {
+	if m.ClaimSubmissionFunc != nil {
+		return m.ClaimSubmissionFunc(ctx, nonce)
+	}
+	return nil, nil
+}
+
+// RecordSubmission implements deviceflow.Flow
+func (m *MockFlow) RecordSubmission(ctx context.Context, nonce string, result *deviceflow.SubmissionResult) error {
+	if m.RecordSubmissionFunc != nil {
+		return m.RecordSubmissionFunc(ctx, nonce, result)
+	}
+	return nil
+}
+
+// CreateBatch implements deviceflow.Flow
+func (m *MockFlow) CreateBatch(ctx context.Context, clientID, scope string, count int, expiry time.Duration) (*deviceflow.Batch, []*deviceflow.DeviceCode, error) {
+	if m.CreateBatchFunc != nil {
+		return m.CreateBatchFunc(ctx, clientID, scope, count, expiry)
+	}
+	return nil, nil, nil
+}
+
+// GetBatch implements deviceflow.Flow
+func (m *MockFlow) GetBatch(ctx context.Context, batchID string) (*deviceflow.Batch, []*deviceflow.DeviceCode, error) {
+	if m.GetBatchFunc != nil {
+		return m.GetBatchFunc(ctx, batchID)
+	}
+	return nil, nil, nil
+}
+
+// InvalidateBatch implements deviceflow.Flow
+func (m *MockFlow) InvalidateBatch(ctx context.Context, batchID string) (int, error) {
+	if m.InvalidateBatchFunc != nil {
+		return m.InvalidateBatchFunc(ctx, batchID)
+	}
+	return 0, nil
+}
+
+// ListClientCodes implements deviceflow.Flow
+func (m *MockFlow) ListClientCodes(ctx context.Context, clientID string) ([]*deviceflow.DeviceCode, error) {
+	if m.ListClientCodesFunc != nil {
+		return m.ListClientCodesFunc(ctx, clientID)
+	}
+	return nil, nil
+}
+
+// RevokeClient implements deviceflow.Flow
+func (m *MockFlow) RevokeClient(ctx context.Context, clientID string) (int, error) {
+	if m.RevokeClientFunc != nil {
+		return m.RevokeClientFunc(ctx, clientID)
+	}
+	return 0, nil
+}
+
+// AllowsCompleteURI implements deviceflow.Flow
+func (m *MockFlow) AllowsCompleteURI(ctx context.Context, userCode string) bool {
+	if m.AllowsCompleteURIFunc != nil {
+		return m.AllowsCompleteURIFunc(ctx, userCode)
+	}
+	return true
+}
+
+// BeginConsent implements deviceflow.Flow
+func (m *MockFlow) BeginConsent(ctx context.Context, deviceCode string) (string, error) {
+	if m.BeginConsentFunc != nil {
+		return m.BeginConsentFunc(ctx, deviceCode)
+	}
+	return "", nil
+}
+
+// ResolveConsent implements deviceflow.Flow
+func (m *MockFlow) ResolveConsent(ctx context.Context, ticket string) (*deviceflow.DeviceCode, error) {
+	if m.ResolveConsentFunc != nil {
+		return m.ResolveConsentFunc(ctx, ticket)
+	}
+	return nil, nil
+}
+
+// IssueCallbackNonce implements deviceflow.Flow
+func (m *MockFlow) IssueCallbackNonce(ctx context.Context, deviceCode string) (string, error) {
+	if m.IssueCallbackFunc != nil {
+		return m.IssueCallbackFunc(ctx, deviceCode)
+	}
+	return "callback-nonce", nil
+}
+
+// ConsumeCallbackNonce implements deviceflow.Flow, accepting every nonce by default
+func (m *MockFlow) ConsumeCallbackNonce(ctx context.Context, deviceCode, nonce string) error {
+	if m.ConsumeCallbackFunc != nil {
+		return m.ConsumeCallbackFunc(ctx, deviceCode, nonce)
+	}
+	return nil
+}
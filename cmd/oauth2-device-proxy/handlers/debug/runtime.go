@@ -0,0 +1,107 @@
+// Package debug serves runtime diagnostics on the internal admin listener
+package debug
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"runtime"
+	"time"
+)
+
+// RuntimePath is where runtime diagnostics are served
+const RuntimePath = "/debug/runtime"
+
+// PoolStats reports the counters of a store connection pool, as go-redis's
+// PoolStats does
+type PoolStats struct {
+	Hits       uint32 `json:"hits"`     // Connections reused from the pool
+	Misses     uint32 `json:"misses"`   // Connections dialed because none was idle
+	Timeouts   uint32 `json:"timeouts"` // Waits for a free connection that timed out
+	TotalConns uint32 `json:"total_conns"`
+	IdleConns  uint32 `json:"idle_conns"`
+	StaleConns uint32 `json:"stale_conns"` // Connections closed as stale
+}
+
+// GCStats summarizes garbage collection and heap usage
+type GCStats struct {
+	NumGC         uint32     `json:"num_gc"`
+	LastGC        *time.Time `json:"last_gc,omitempty"`
+	PauseTotal    string     `json:"pause_total"`
+	LastPause     string     `json:"last_pause"`
+	HeapAlloc     uint64     `json:"heap_alloc_bytes"`
+	HeapInuse     uint64     `json:"heap_inuse_bytes"`
+	HeapObjects   uint64     `json:"heap_objects"`
+	Sys           uint64     `json:"sys_bytes"` // Memory obtained from the OS
+	NextGC        uint64     `json:"next_gc_bytes"`
+	GCCPUFraction float64    `json:"gc_cpu_fraction"`
+}
+
+// RuntimeResponse is the body of the runtime diagnostics endpoint
+type RuntimeResponse struct {
+	GoVersion  string               `json:"go_version"`
+	Goroutines int                  `json:"goroutines"`
+	CPUs       int                  `json:"cpus"`
+	MaxProcs   int                  `json:"max_procs"`
+	Uptime     string               `json:"uptime"`
+	GC         GCStats              `json:"gc"`
+	Pools      map[string]PoolStats `json:"pools,omitempty"`
+}
+
+// Handler serves runtime diagnostics
+type Handler struct {
+	started time.Time
+	pools   map[string]func() PoolStats
+}
+
+// New creates a runtime diagnostics handler
+func New() *Handler {
+	return &Handler{started: time.Now(), pools: make(map[string]func() PoolStats)}
+}
+
+// WithPool reports the connection pool statistics of a store under name
+func (h *Handler) WithPool(name string, stats func() PoolStats) *Handler {
+	h.pools[name] = stats
+	return h
+}
+
+// ServeHTTP reports goroutine counts, garbage collection and pool statistics
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	response := RuntimeResponse{
+		GoVersion:  runtime.Version(),
+		Goroutines: runtime.NumGoroutine(),
+		CPUs:       runtime.NumCPU(),
+		MaxProcs:   runtime.GOMAXPROCS(0),
+		Uptime:     time.Since(h.started).Round(time.Second).String(),
+		GC: GCStats{
+			NumGC:         mem.NumGC,
+			PauseTotal:    time.Duration(mem.PauseTotalNs).String(),
+			LastPause:     time.Duration(mem.PauseNs[(mem.NumGC+255)%256]).String(),
+			HeapAlloc:     mem.HeapAlloc,
+			HeapInuse:     mem.HeapInuse,
+			HeapObjects:   mem.HeapObjects,
+			Sys:           mem.Sys,
+			NextGC:        mem.NextGC,
+			GCCPUFraction: mem.GCCPUFraction,
+		},
+	}
+	if mem.LastGC > 0 {
+		lastGC := time.Unix(0, int64(mem.LastGC)).UTC()
+		response.GC.LastGC = &lastGC
+	}
+	if len(h.pools) > 0 {
+		response.Pools = make(map[string]PoolStats, len(h.pools))
+		for name, stats := range h.pools {
+			response.Pools[name] = stats()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Warning: writing runtime diagnostics: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestRuntime(t *testing.T) {
+	handler := New().WithPool("redis", func() PoolStats {
+		return PoolStats{Hits: 10, Misses: 2, TotalConns: 3, IdleConns: 1}
+	})
+
+	runtime.GC()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, RuntimePath, nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", w.Header().Get("Cache-Control"))
+	}
+	var resp RuntimeResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if resp.Goroutines < 1 || resp.GoVersion != runtime.Version() || resp.MaxProcs < 1 {
+		t.Errorf("runtime = %+v", resp)
+	}
+	if resp.GC.NumGC < 1 || resp.GC.LastGC == nil || resp.GC.HeapAlloc == 0 {
+		t.Errorf("GC stats = %+v, want at least the forced collection", resp.GC)
+	}
+	if pool := resp.Pools["redis"]; pool.Hits != 10 || pool.TotalConns != 3 {
+		t.Errorf("redis pool = %+v", pool)
+	}
+}
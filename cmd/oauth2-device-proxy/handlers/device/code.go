@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/geo"
 )
 
 // CodeResponse represents the device code response per RFC 8628 section 3.2
@@ -18,18 +20,54 @@ type CodeResponse struct {
 	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
 	ExpiresIn               int    `json:"expires_in"`
 	Interval                int    `json:"interval"`
+
+	// Optional extensions, see Extensions
+	UserCodeFormat *UserCodeFormat `json:"user_code_format,omitempty"`
+	QRURI          string          `json:"qr_uri,omitempty"`
+	Branding       *Branding       `json:"branding,omitempty"`
 }
 
 // Handler processes device code requests per RFC 8628 section 3.2
 type Handler struct {
-	flow deviceflow.Flow
+	flow       deviceflow.Flow
+	locator    geo.Locator
+	forwarded  []string
+	clientAuth *common.ClientAuthenticator
+	extensions *Extensions
+	pow        *ProofOfWork
 }
 
 // New creates a new device code request handler
 func New(flow deviceflow.Flow) *Handler {
 	return &Handler{
-		flow: flow,
+		flow:    flow,
+		locator: geo.NopLocator{},
+	}
+}
+
+// WithLocator sets how the requesting device's location is resolved for display
+// on the approval page
+func (h *Handler) WithLocator(locator geo.Locator) *Handler {
+	if locator != nil {
+		h.locator = locator
 	}
+	return h
+}
+
+// WithForwardedParams sets the request parameters, such as audience or
+// acr_values, passed through to the upstream authorization and token requests.
+// Other parameters are never forwarded. Names must pass
+// deviceflow.ValidateForwardedParams.
+func (h *Handler) WithForwardedParams(names []string) *Handler {
+	h.forwarded = names
+	return h
+}
+
+// WithClientAuth sets how confidential clients authenticate. Without it only
+// public clients are accepted.
+func (h *Handler) WithClientAuth(auth *common.ClientAuthenticator) *Handler {
+	h.clientAuth = auth
+	return h
 }
 
 // ServeHTTP handles device code requests
@@ -46,23 +84,60 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check for duplicate parameters per RFC 8628 section 3.1
+	// Check for duplicate parameters per RFC 8628 section 3.1. Resource
+	// indicators may repeat per RFC 8707 section 2.
 	for key, values := range r.Form {
-		if len(values) > 1 {
+		if len(values) > 1 && key != "resource" {
 			common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Parameters MUST NOT be included more than once: "+key)
 			return
 		}
 	}
 
-	clientID := r.Form.Get("client_id")
-	if clientID == "" {
-		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "The client_id parameter is REQUIRED")
+	clientID, authenticated, err := h.clientAuth.Authenticate(r)
+	if err != nil {
+		dferr := deviceflow.ErrServerError
+		errors.As(err, &dferr)
+		common.WriteError(w, dferr.Code, dferr.Description)
+		return
+	}
+	if !h.checkProofOfWork(w, r, clientID, authenticated) {
+		return
+	}
+
+	timing, err := requestedTiming(r)
+	if err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, err.Error())
 		return
 	}
 
 	scope := r.Form.Get("scope")
-	code, err := h.flow.RequestDeviceCode(r.Context(), clientID, scope)
+	code, err := h.flow.RequestDeviceCode(r.Context(), clientID, scope,
+		timing,
+		deviceflow.WithDeviceIdentity(r.Form.Get("device_id"), r.Form.Get("device_attestation")),
+		deviceflow.WithRequestOrigin(common.ClientIP(r), h.locator.Locate(r).String()),
+		deviceflow.WithRequestUserAgent(r.UserAgent()),
+		deviceflow.WithAuthParams(h.forwardedParams(r)),
+		deviceflow.WithResources(r.Form["resource"]),
+		deviceflow.WithCallbackURI(r.Form.Get("callback_uri")),
+		deviceflow.WithClientAuthentication(authenticated))
 	if err != nil {
+		// Shed requests while an outstanding code cap is reached or the
+		// store is degraded, asking devices to retry
+		var shed *deviceflow.DeviceFlowError
+		switch {
+		case errors.Is(err, deviceflow.ErrCapacityExceeded):
+			shed = deviceflow.ErrCapacityExceeded
+		case errors.Is(err, deviceflow.ErrClientQuotaExceeded):
+			shed = deviceflow.ErrClientQuotaExceeded
+		case errors.Is(err, deviceflow.ErrStoreDegraded):
+			shed = deviceflow.ErrStoreDegraded
+		}
+		if shed != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(deviceflow.ShedRetryAfter.Seconds())))
+			common.WriteErrorStatus(w, http.StatusServiceUnavailable, shed.Code, shed.Description)
+			return
+		}
+
 		var dferr *deviceflow.DeviceFlowError
 		if errors.As(err, &dferr) {
 			common.WriteError(w, dferr.Code, dferr.Description)
@@ -73,6 +148,11 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.writeCode(w, code)
+}
+
+// writeCode sends a device code in the response format of RFC 8628 section 3.2
+func (h *Handler) writeCode(w http.ResponseWriter, code *deviceflow.DeviceCode) {
 	// Ensure expires_in is positive and calculated from response time
 	expiresIn := int(time.Until(code.ExpiresAt).Seconds())
 	if expiresIn <= 0 {
@@ -89,9 +169,48 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ExpiresIn:               expiresIn,
 		Interval:                code.Interval,
 	}
+	h.extend(&response, code)
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		common.WriteJSONError(w, err)
 		return
 	}
 }
+
+// maxTimingHint caps timing hints, in seconds, before the flow bounds them
+const maxTimingHint = 24 * 60 * 60
+
+// requestedTiming reads the optional expires_in and interval hints, in
+// seconds, with which a device asks for a longer code lifetime or polling
+// interval. The flow bounds both.
+func requestedTiming(r *http.Request) (deviceflow.RequestOption, error) {
+	var durations [2]time.Duration
+	for i, name := range []string{"expires_in", "interval"} {
+		value := r.Form.Get(name)
+		if value == "" {
+			continue
+		}
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 {
+			return nil, errors.New("The " + name + " parameter must be a positive number of seconds")
+		}
+		// Cap before converting so huge hints cannot overflow; the flow
+		// bounds them far lower anyway
+		durations[i] = time.Duration(min(seconds, maxTimingHint)) * time.Second
+	}
+	return deviceflow.WithRequestedTiming(durations[0], durations[1]), nil
+}
+
+// forwardedParams collects the allowlisted parameters present in the request
+func (h *Handler) forwardedParams(r *http.Request) map[string]string {
+	if len(h.forwarded) == 0 {
+		return nil
+	}
+	params := make(map[string]string, len(h.forwarded))
+	for _, name := range h.forwarded {
+		if value := r.Form.Get(name); value != "" {
+			params[name] = value
+		}
+	}
+	return params
+}
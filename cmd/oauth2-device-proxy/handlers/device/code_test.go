@@ -1,8 +1,11 @@
 package device
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"image/png"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -10,8 +13,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
 	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/geo"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+	"github.com/wrale/oauth2-device-proxy/internal/tokencache"
 )
 
 func TestDeviceCodeHandler(t *testing.T) {
@@ -28,6 +36,9 @@ func TestDeviceCodeHandler(t *testing.T) {
 		wantErrorCode string
 		wantErrorDesc string
 		validateBody  bool
+
+		wantRetryAfter string
+		wantDeviceID   string
 	}{
 		{
 			name:          "wrong method",
@@ -71,6 +82,24 @@ func TestDeviceCodeHandler(t *testing.T) {
 			wantStatus:   http.StatusOK,
 			validateBody: true,
 		},
+		{
+			name:   "device identity forwarded",
+			method: "POST",
+			params: map[string]string{
+				"client_id":          "test-client",
+				"device_id":          "SN-4411-A",
+				"device_attestation": "eyJhbGciOiJFUzI1NiJ9.e30.c2ln",
+			},
+			mockResponse: &deviceflow.DeviceCode{
+				DeviceCode:      "device-123",
+				UserCode:        "USER-123",
+				VerificationURI: "https://example.com/verify",
+				ExpiresAt:       validExpiry,
+				Interval:        5,
+			},
+			wantStatus:   http.StatusOK,
+			wantDeviceID: "SN-4411-A",
+		},
 		{
 			name:   "flow error",
 			method: "POST",
@@ -78,20 +107,48 @@ func TestDeviceCodeHandler(t *testing.T) {
 				"client_id": "test-client",
 			},
 			mockError: &deviceflow.DeviceFlowError{
-				Code:        "server_error",
+				Code:        deviceflow.ErrorCodeServerError,
 				Description: "Internal error",
 			},
-			wantStatus:    http.StatusBadRequest,
-			wantErrorCode: "server_error",
+			wantStatus:    http.StatusInternalServerError,
+			wantErrorCode: deviceflow.ErrorCodeServerError,
 			wantErrorDesc: "Internal error",
 		},
+		{
+			name:   "outstanding code cap reached",
+			method: "POST",
+			params: map[string]string{
+				"client_id": "test-client",
+			},
+			mockError:      deviceflow.ErrCapacityExceeded,
+			wantStatus:     http.StatusServiceUnavailable,
+			wantErrorCode:  "temporarily_unavailable",
+			wantErrorDesc:  deviceflow.ErrorDescCapacityExceeded,
+			wantRetryAfter: "30",
+		},
+		{
+			name:   "store degraded",
+			method: "POST",
+			params: map[string]string{
+				"client_id": "test-client",
+			},
+			mockError:      deviceflow.ErrStoreDegraded,
+			wantStatus:     http.StatusServiceUnavailable,
+			wantErrorCode:  "temporarily_unavailable",
+			wantErrorDesc:  deviceflow.ErrorDescStoreUnavailable,
+			wantRetryAfter: "30",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Setup mock using common test mock implementation
+			var requested deviceflow.DeviceCode
 			flow := &test.MockFlow{
-				RequestDeviceCodeFunc: func(ctx context.Context, clientID string, scope string) (*deviceflow.DeviceCode, error) {
+				RequestDeviceCodeFunc: func(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
+					for _, opt := range opts {
+						opt(&requested)
+					}
 					if tt.mockError != nil {
 						return nil, tt.mockError
 					}
@@ -130,6 +187,12 @@ func TestDeviceCodeHandler(t *testing.T) {
 			if w.Header().Get("Content-Type") != "application/json" {
 				t.Error("missing Content-Type: application/json header")
 			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+			if tt.wantDeviceID != "" && (requested.Device == nil || requested.Device.ID != tt.wantDeviceID) {
+				t.Errorf("requested device identity = %+v, want ID %q", requested.Device, tt.wantDeviceID)
+			}
 
 			// Parse response body
 			var resp map[string]interface{}
@@ -191,3 +254,288 @@ func TestDeviceCodeHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestDeviceCodeHandlerRequestOrigin(t *testing.T) {
+	var requested deviceflow.DeviceCode
+	flow := &test.MockFlow{
+		RequestDeviceCodeFunc: func(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
+			for _, opt := range opts {
+				opt(&requested)
+			}
+			return &deviceflow.DeviceCode{DeviceCode: "device-123", UserCode: "BCDF-GHJK", ExpiresAt: time.Now().Add(15 * time.Minute)}, nil
+		},
+	}
+	handler := New(flow).WithLocator(geo.HeaderLocator{CountryHeader: "CF-IPCountry"})
+
+	req := httptest.NewRequest(http.MethodPost, "/device/code", strings.NewReader("client_id=tv"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("CF-IPCountry", "DE")
+	req.Header.Set("User-Agent", "tv-app/1.0")
+	req.RemoteAddr = "203.0.113.7:41234"
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if requested.RequestIP != "203.0.113.7" || requested.RequestLocation != "DE" {
+		t.Errorf("requested origin = %q, %q, want 203.0.113.7, DE", requested.RequestIP, requested.RequestLocation)
+	}
+	if requested.RequestUserAgent != "tv-app/1.0" {
+		t.Errorf("requested User-Agent = %q, want tv-app/1.0", requested.RequestUserAgent)
+	}
+}
+
+func TestDeviceCodeHandlerForwardedParams(t *testing.T) {
+	var requested deviceflow.DeviceCode
+	flow := &test.MockFlow{
+		RequestDeviceCodeFunc: func(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
+			for _, opt := range opts {
+				opt(&requested)
+			}
+			return &deviceflow.DeviceCode{DeviceCode: "device-123", UserCode: "BCDF-GHJK", ExpiresAt: time.Now().Add(15 * time.Minute)}, nil
+		},
+	}
+	handler := New(flow).WithForwardedParams([]string{"audience", "acr_values"})
+
+	body := "client_id=tv&audience=https%3A%2F%2Fapi.example.com&prompt=none&redirect_uri=https%3A%2F%2Fevil.example"
+	req := httptest.NewRequest(http.MethodPost, "/device/code", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	// Only allowlisted parameters present in the request are forwarded
+	want := map[string]string{"audience": "https://api.example.com"}
+	if len(requested.AuthParams) != len(want) || requested.AuthParams["audience"] != want["audience"] {
+		t.Errorf("forwarded params = %v, want %v", requested.AuthParams, want)
+	}
+}
+
+func TestDeviceCodeHandlerTiming(t *testing.T) {
+	var requested deviceflow.DeviceCode
+	flow := &test.MockFlow{
+		RequestDeviceCodeFunc: func(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
+			requested = deviceflow.DeviceCode{ExpiresIn: 900, Interval: 5}
+			for _, opt := range opts {
+				opt(&requested)
+			}
+			return &deviceflow.DeviceCode{DeviceCode: "device-123", UserCode: "BCDF-GHJK", ExpiresAt: time.Now().Add(15 * time.Minute)}, nil
+		},
+	}
+	handler := New(flow)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/device/code", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("client_id=kiosk&expires_in=1800&interval=10"); w.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if requested.ExpiresIn != 1800 || requested.Interval != 10 {
+		t.Errorf("requested expires_in, interval = %d, %d; want 1800, 10", requested.ExpiresIn, requested.Interval)
+	}
+
+	if w := post("client_id=kiosk"); w.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if requested.ExpiresIn != 900 || requested.Interval != 5 {
+		t.Errorf("expires_in, interval without hints = %d, %d; want the defaults", requested.ExpiresIn, requested.Interval)
+	}
+
+	for _, body := range []string{"client_id=kiosk&expires_in=soon", "client_id=kiosk&interval=0", "client_id=kiosk&interval=-5"} {
+		if w := post(body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status code = %d, want %d", body, w.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestDeviceCodeHandlerResources(t *testing.T) {
+	var requested deviceflow.DeviceCode
+	flow := &test.MockFlow{
+		RequestDeviceCodeFunc: func(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
+			for _, opt := range opts {
+				opt(&requested)
+			}
+			return &deviceflow.DeviceCode{DeviceCode: "device-123", UserCode: "BCDF-GHJK", ExpiresAt: time.Now().Add(15 * time.Minute)}, nil
+		},
+	}
+	handler := New(flow)
+
+	// Resource indicators may repeat per RFC 8707 section 2
+	body := "client_id=tv&resource=https%3A%2F%2Fapi.example.com&resource=urn%3Aexample%3Aprinter"
+	req := httptest.NewRequest(http.MethodPost, "/device/code", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if len(requested.Resources) != 2 || requested.Resources[1] != "urn:example:printer" {
+		t.Errorf("requested resources = %q", requested.Resources)
+	}
+}
+
+// stubVerifier accepts the assertion "valid" as the tv client
+type stubVerifier struct{}
+
+func (stubVerifier) VerifyAssertion(ctx context.Context, assertion string) (string, error) {
+	if assertion != "valid" {
+		return "", tokencache.ErrInvalidAssertion
+	}
+	return "tv", nil
+}
+
+func TestDeviceCodeHandlerClientAuth(t *testing.T) {
+	assertion := "&client_assertion_type=" + url.QueryEscape(oauth.ClientAssertionType) + "&client_assertion="
+
+	tests := []struct {
+		name              string
+		body              string
+		wantStatus        int
+		wantError         string
+		wantClient        string
+		wantAuthenticated bool
+	}{
+		{name: "assertion without client_id", body: "scope=read" + assertion + "valid",
+			wantStatus: http.StatusOK, wantClient: "tv", wantAuthenticated: true},
+		{name: "assertion with matching client_id", body: "client_id=tv" + assertion + "valid",
+			wantStatus: http.StatusOK, wantClient: "tv", wantAuthenticated: true},
+		{name: "assertion for another client", body: "client_id=printer" + assertion + "valid",
+			wantStatus: http.StatusUnauthorized, wantError: deviceflow.ErrorCodeInvalidClient},
+		{name: "invalid assertion", body: "client_id=tv" + assertion + "forged",
+			wantStatus: http.StatusUnauthorized, wantError: deviceflow.ErrorCodeInvalidClient},
+		{name: "confidential client without assertion", body: "client_id=tv",
+			wantStatus: http.StatusUnauthorized, wantError: deviceflow.ErrorCodeInvalidClient},
+		{name: "public client", body: "client_id=printer", wantStatus: http.StatusOK, wantClient: "printer"},
+		{name: "unregistered client", body: "client_id=unknown",
+			wantStatus: http.StatusUnauthorized, wantError: deviceflow.ErrorCodeInvalidClient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested deviceflow.DeviceCode
+			flow := &test.MockFlow{
+				RequestDeviceCodeFunc: func(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
+					requested.ClientID = clientID
+					for _, opt := range opts {
+						opt(&requested)
+					}
+					return &deviceflow.DeviceCode{DeviceCode: "device-123", UserCode: "BCDF-GHJK", ExpiresAt: time.Now().Add(15 * time.Minute)}, nil
+				},
+			}
+			handler := New(flow).WithClientAuth(&common.ClientAuthenticator{
+				Verifier: stubVerifier{},
+				Required: func(clientID string) bool { return clientID == "tv" },
+				Known:    func(clientID string) bool { return clientID == "tv" || clientID == "printer" },
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/device/code", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantError != "" {
+				var resp common.ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("decoding response: %v", err)
+				}
+				if resp.Error != tt.wantError {
+					t.Errorf("error = %q, want %q", resp.Error, tt.wantError)
+				}
+				return
+			}
+			if requested.ClientID != tt.wantClient || requested.ClientAuthenticated != tt.wantAuthenticated {
+				t.Errorf("client, authenticated = %q, %v; want %q, %v",
+					requested.ClientID, requested.ClientAuthenticated, tt.wantClient, tt.wantAuthenticated)
+			}
+		})
+	}
+}
+
+func TestDeviceCodeHandlerExtensions(t *testing.T) {
+	flow := &test.MockFlow{
+		RequestDeviceCodeFunc: func(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
+			return &deviceflow.DeviceCode{
+				DeviceCode:              "device-123",
+				UserCode:                "BCDF-GHJK",
+				VerificationURI:         "https://example.com/device",
+				VerificationURIComplete: "HTTPS://EX.CO/D/BCDFGHJK", // Short enough for the QR encoder
+				ExpiresAt:               time.Now().Add(15 * time.Minute),
+				Interval:                5,
+			}, nil
+		},
+	}
+
+	request := func(handler *Handler) map[string]json.RawMessage {
+		req := httptest.NewRequest(http.MethodPost, "/device/code", strings.NewReader("client_id=tv"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+		}
+		var resp map[string]json.RawMessage
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		return resp
+	}
+
+	// Responses stay standard unless extensions are enabled
+	for _, member := range []string{"user_code_format", "qr_uri", "branding"} {
+		if _, ok := request(New(flow))[member]; ok {
+			t.Errorf("default response includes %s", member)
+		}
+	}
+
+	resp := request(New(flow).WithExtensions(&Extensions{
+		UserCodeFormat: true,
+		QRRenderer:     templates.PNGRenderer{},
+		Branding:       &Branding{ProductName: "Acme TV"},
+	}))
+
+	var format UserCodeFormat
+	if err := json.Unmarshal(resp["user_code_format"], &format); err != nil {
+		t.Fatalf("decoding user_code_format: %v", err)
+	}
+	if format.Charset != "BCDFGHJKLMNPQRSTVWXZ" || len(format.Groups) != 2 || format.Separator != "-" {
+		t.Errorf("user_code_format = %+v", format)
+	}
+
+	var qrURI string
+	if err := json.Unmarshal(resp["qr_uri"], &qrURI); err != nil {
+		t.Fatalf("decoding qr_uri: %v", err)
+	}
+	img, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(qrURI, "data:image/png;base64,"))
+	if err != nil || !strings.HasPrefix(qrURI, "data:image/png;base64,") {
+		t.Fatalf("qr_uri = %.40q, want a PNG data URI", qrURI)
+	}
+	if _, err := png.Decode(bytes.NewReader(img)); err != nil {
+		t.Errorf("decoding QR code PNG: %v", err)
+	}
+
+	var branding Branding
+	if err := json.Unmarshal(resp["branding"], &branding); err != nil || branding.ProductName != "Acme TV" {
+		t.Errorf("branding = %s", resp["branding"])
+	}
+	// Other formats are embedded with their own media type
+	resp = request(New(flow).WithExtensions(&Extensions{QRRenderer: templates.ASCIIRenderer{}}))
+	if err := json.Unmarshal(resp["qr_uri"], &qrURI); err != nil ||
+		!strings.HasPrefix(qrURI, "data:text/plain;charset=us-ascii;base64,") {
+		t.Errorf("ascii qr_uri = %.50q, want a text data URI", qrURI)
+	}
+}
@@ -0,0 +1,67 @@
+package device
+
+import (
+	"log"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
+)
+
+// Extensions configures optional members added to device authorization
+// responses so that clients such as smart displays can render richer pairing
+// screens. Clients must ignore members they do not understand per RFC 8628
+// section 3.2, so standard clients are unaffected.
+type Extensions struct {
+	UserCodeFormat bool                 // Describe the user code's groups and character set
+	QRRenderer     templates.QRRenderer // Renders the verification_uri_complete QR code as qr_uri, omitted if nil
+	Branding       *Branding            // Branding hints, omitted if nil
+}
+
+// UserCodeFormat describes how user codes are built so clients can lay them out
+type UserCodeFormat struct {
+	Charset   string `json:"charset"`   // Characters codes are drawn from
+	Groups    []int  `json:"groups"`    // Length of each group of characters
+	Separator string `json:"separator"` // Shown between groups, optional when entered
+}
+
+// Branding tells clients how the hosted pages are styled
+type Branding struct {
+	ProductName  string `json:"product_name,omitempty"`
+	LogoURI      string `json:"logo_uri,omitempty"` // Absolute URI of the logo
+	PrimaryColor string `json:"primary_color,omitempty"`
+}
+
+// userCodeFormat is the format of every user code the proxy issues
+var userCodeFormat = &UserCodeFormat{
+	Charset:   validation.ValidCharset,
+	Groups:    []int{validation.MinGroupSize, validation.MinLength - validation.MinGroupSize},
+	Separator: "-",
+}
+
+// WithExtensions enables optional response members. Without it responses
+// contain only the members defined by RFC 8628.
+func (h *Handler) WithExtensions(ext *Extensions) *Handler {
+	h.extensions = ext
+	return h
+}
+
+// extend adds the enabled extension members to a response
+func (h *Handler) extend(response *CodeResponse, code *deviceflow.DeviceCode) {
+	if h.extensions == nil {
+		return
+	}
+	if h.extensions.UserCodeFormat {
+		response.UserCodeFormat = userCodeFormat
+	}
+	if h.extensions.QRRenderer != nil && code.VerificationURIComplete != "" {
+		uri, err := templates.QRCodeDataURI(h.extensions.QRRenderer, code.VerificationURIComplete)
+		if err != nil {
+			// The URI remains in the response for clients to encode themselves
+			log.Printf("Warning: omitting QR code from device code response: %v", err)
+		} else {
+			response.QRURI = uri
+		}
+	}
+	response.Branding = h.extensions.Branding
+}
@@ -0,0 +1,88 @@
+package device
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/pow"
+)
+
+// ProofOfWork requires clients that do not authenticate to solve a challenge
+// before they are issued a device code, making bulk code farming costly
+type ProofOfWork struct {
+	Issuer   *pow.Issuer
+	Required func(clientID string) bool // Whether a public client must solve a challenge
+}
+
+// ChallengeResponse is the error returned when a device code request lacks a
+// valid proof of work. The device finds a nonce such that the SHA-256 of
+// "<pow_challenge>:<nonce>" starts with pow_difficulty zero bits, then repeats
+// its request with pow_challenge and pow_nonce.
+type ChallengeResponse struct {
+	common.ErrorResponse
+	Challenge  string `json:"pow_challenge"`
+	Difficulty int    `json:"pow_difficulty"`
+	ExpiresIn  int    `json:"pow_expires_in"`
+}
+
+// Proof of work error descriptions
+const (
+	errorDescPoWRequired = "A proof of work is required, solve pow_challenge and repeat the request with pow_challenge and pow_nonce"
+	errorDescPoWInvalid  = "The proof of work is invalid, expired or was already used, solve the new pow_challenge"
+)
+
+// WithProofOfWork requires proof of work from public clients for which
+// p.Required reports true. Authenticated clients are never challenged.
+func (h *Handler) WithProofOfWork(p *ProofOfWork) *Handler {
+	h.pow = p
+	return h
+}
+
+// checkProofOfWork verifies the request's proof of work when one is required,
+// writing a fresh challenge and returning false when it is missing or invalid
+func (h *Handler) checkProofOfWork(w http.ResponseWriter, r *http.Request, clientID string, authenticated bool) bool {
+	if h.pow == nil || authenticated || !h.pow.Required(clientID) {
+		return true
+	}
+
+	description := errorDescPoWRequired
+	challenge, nonce := r.Form.Get("pow_challenge"), r.Form.Get("pow_nonce")
+	if challenge != "" || nonce != "" {
+		err := h.pow.Issuer.Verify(r.Context(), clientID, challenge, nonce)
+		if err == nil {
+			return true
+		}
+		if !errors.Is(err, pow.ErrInvalidSolution) && !errors.Is(err, pow.ErrExpired) && !errors.Is(err, pow.ErrReplayed) {
+			log.Printf("Error verifying proof of work: %v", err)
+			common.WriteError(w, deviceflow.ErrorCodeServerError, "Failed to verify proof of work")
+			return false
+		}
+		description = errorDescPoWInvalid
+	}
+
+	issued, err := h.pow.Issuer.Issue(clientID)
+	if err != nil {
+		log.Printf("Error issuing proof of work challenge: %v", err)
+		common.WriteError(w, deviceflow.ErrorCodeServerError, "Failed to issue proof of work challenge")
+		return false
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+	response := ChallengeResponse{
+		ErrorResponse: common.ErrorResponse{
+			Error:            deviceflow.ErrorCodeInvalidRequest,
+			ErrorDescription: description,
+		},
+		Challenge:  issued.Value,
+		Difficulty: issued.Difficulty,
+		ExpiresIn:  issued.ExpiresIn,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		log.Printf("Error encoding proof of work challenge: %v", err)
+	}
+	return false
+}
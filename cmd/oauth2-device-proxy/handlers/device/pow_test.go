@@ -0,0 +1,88 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/pow"
+)
+
+func TestDeviceCodeHandlerProofOfWork(t *testing.T) {
+	issued := 0
+	flow := &test.MockFlow{
+		RequestDeviceCodeFunc: func(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
+			issued++
+			return &deviceflow.DeviceCode{
+				DeviceCode:      "device-123",
+				UserCode:        "BCDF-GHJK",
+				VerificationURI: "https://example.com/device",
+				ExpiresAt:       time.Now().Add(15 * time.Minute),
+				Interval:        5,
+			}, nil
+		},
+	}
+	handler := New(flow).WithProofOfWork(&ProofOfWork{
+		Issuer:   pow.New(pow.Config{Secret: []byte("secret"), Difficulty: 8}),
+		Required: func(clientID string) bool { return clientID == "kiosk" },
+	})
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/device/code", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	challengeFrom := func(w *httptest.ResponseRecorder) ChallengeResponse {
+		t.Helper()
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status code = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+		var resp ChallengeResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("decoding challenge: %v", err)
+		}
+		if resp.Error != deviceflow.ErrorCodeInvalidRequest || resp.Challenge == "" || resp.Difficulty != 8 {
+			t.Fatalf("challenge response = %+v", resp)
+		}
+		return resp
+	}
+
+	// Clients without the requirement are served directly
+	if w := post(url.Values{"client_id": {"tv"}}); w.Code != http.StatusOK {
+		t.Fatalf("unchallenged client status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	challenge := challengeFrom(post(url.Values{"client_id": {"kiosk"}}))
+	if issued != 1 {
+		t.Fatalf("device code issued without proof of work")
+	}
+
+	nonce, err := pow.Solve(context.Background(), challenge.Challenge, challenge.Difficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	solved := url.Values{"client_id": {"kiosk"}, "pow_challenge": {challenge.Challenge}, "pow_nonce": {nonce}}
+	if w := post(solved); w.Code != http.StatusOK {
+		t.Fatalf("solved request status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if issued != 2 {
+		t.Errorf("device codes issued = %d, want 2", issued)
+	}
+
+	// A spent challenge is answered with a new one
+	if next := challengeFrom(post(solved)); next.Challenge == challenge.Challenge {
+		t.Error("replayed request received the spent challenge")
+	}
+	if issued != 2 {
+		t.Errorf("device code issued for a replayed proof of work")
+	}
+}
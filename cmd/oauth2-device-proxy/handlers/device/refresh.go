@@ -0,0 +1,66 @@
+package device
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// ServeRefresh handles user code refresh requests. A device presents its
+// device_code and receives a new user_code and verification URIs in the
+// device authorization response format, keeping its device code, so a user
+// code that ran out before it was entered does not restart the flow.
+func (h *Handler) ServeRefresh(w http.ResponseWriter, r *http.Request) {
+	common.SetJSONHeaders(w)
+
+	if r.Method != http.MethodPost {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "POST method required")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Invalid request format")
+		return
+	}
+
+	for key, values := range r.Form {
+		if len(values) > 1 {
+			common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Parameters MUST NOT be included more than once: "+key)
+			return
+		}
+	}
+
+	deviceCode := r.Form.Get("device_code")
+	if deviceCode == "" {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "The device_code parameter is REQUIRED")
+		return
+	}
+
+	// Refreshes are authenticated like token requests for the same code
+	clientID, authenticated, err := h.clientAuth.Authenticate(r)
+	if err != nil {
+		dferr := deviceflow.ErrServerError
+		errors.As(err, &dferr)
+		common.WriteError(w, dferr.Code, dferr.Description)
+		return
+	}
+	ctx := r.Context()
+	if authenticated {
+		ctx = deviceflow.WithAuthenticatedClient(ctx, clientID)
+	}
+
+	code, err := h.flow.RefreshUserCode(ctx, deviceCode, clientID)
+	if err != nil {
+		var dferr *deviceflow.DeviceFlowError
+		if errors.As(err, &dferr) {
+			common.WriteError(w, dferr.Code, dferr.Description)
+			return
+		}
+		common.WriteError(w, deviceflow.ErrorCodeServerError, "Failed to refresh user code")
+		return
+	}
+
+	h.writeCode(w, code)
+}
@@ -0,0 +1,93 @@
+package device
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+func TestRefreshHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		refreshErr error
+		wantStatus int
+		wantError  string
+	}{
+		{name: "refreshed", method: http.MethodPost, body: "client_id=tv&device_code=device-123",
+			wantStatus: http.StatusOK},
+		{name: "wrong method", method: http.MethodGet,
+			wantStatus: http.StatusBadRequest, wantError: deviceflow.ErrorCodeInvalidRequest},
+		{name: "missing device code", method: http.MethodPost, body: "client_id=tv",
+			wantStatus: http.StatusBadRequest, wantError: deviceflow.ErrorCodeInvalidRequest},
+		{name: "missing client id", method: http.MethodPost, body: "device_code=device-123",
+			wantStatus: http.StatusBadRequest, wantError: deviceflow.ErrorCodeInvalidRequest},
+		{name: "expired code", method: http.MethodPost, body: "client_id=tv&device_code=device-123",
+			refreshErr: deviceflow.ErrExpiredCode,
+			wantStatus: http.StatusBadRequest, wantError: deviceflow.ErrorCodeExpiredToken},
+		{name: "limit reached", method: http.MethodPost, body: "client_id=tv&device_code=device-123",
+			refreshErr: deviceflow.ErrRefreshLimitReached,
+			wantStatus: http.StatusBadRequest, wantError: deviceflow.ErrorCodeInvalidRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotDevice, gotClient string
+			flow := &test.MockFlow{
+				RefreshUserCodeFunc: func(ctx context.Context, deviceCode, clientID string) (*deviceflow.DeviceCode, error) {
+					gotDevice, gotClient = deviceCode, clientID
+					if tt.refreshErr != nil {
+						return nil, tt.refreshErr
+					}
+					return &deviceflow.DeviceCode{
+						DeviceCode:      deviceCode,
+						UserCode:        "MNPQ-RSTV",
+						VerificationURI: "https://example.com/device",
+						ExpiresAt:       time.Now().Add(15 * time.Minute),
+						Interval:        5,
+					}, nil
+				},
+			}
+			handler := New(flow)
+
+			req := httptest.NewRequest(tt.method, "/device/code/refresh", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			handler.ServeRefresh(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantError != "" {
+				var resp common.ErrorResponse
+				if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+					t.Fatalf("decoding response: %v", err)
+				}
+				if resp.Error != tt.wantError {
+					t.Errorf("error = %q, want %q", resp.Error, tt.wantError)
+				}
+				return
+			}
+
+			var resp CodeResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if gotDevice != "device-123" || gotClient != "tv" {
+				t.Errorf("refreshed %q for %q, want device-123 for tv", gotDevice, gotClient)
+			}
+			if resp.DeviceCode != "device-123" || resp.UserCode != "MNPQ-RSTV" || resp.ExpiresIn <= 0 {
+				t.Errorf("response = %+v", resp)
+			}
+		})
+	}
+}
@@ -0,0 +1,308 @@
+// Package grpcadmin serves the gRPC management API for fleet-provisioning
+// backends, mirroring the HTTP admin API and streaming lifecycle events
+package grpcadmin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	adminv1 "github.com/wrale/oauth2-device-proxy/api/admin/v1"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
+)
+
+// Default and maximum page sizes of ListDeviceCodes
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// Server implements the DeviceAdmin service
+type Server struct {
+	adminv1.UnimplementedDeviceAdminServer
+
+	flow   deviceflow.Flow
+	audit  audit.Logger
+	events *events.Broadcaster
+}
+
+// Config contains gRPC management API configuration
+type Config struct {
+	Flow   deviceflow.Flow     // Device flow the codes are managed through
+	Audit  audit.Logger        // Records revocations
+	Events *events.Broadcaster // Source of StreamEvents, which is unavailable when nil
+}
+
+// New creates a DeviceAdmin server
+func New(cfg Config) *Server {
+	s := &Server{
+		flow:   cfg.Flow,
+		audit:  cfg.Audit,
+		events: cfg.Events,
+	}
+	if s.audit == nil {
+		s.audit = audit.NopLogger{}
+	}
+	return s
+}
+
+// ListDeviceCodes returns a client's outstanding device codes, soonest to
+// expire first. Page tokens are offsets into that order.
+func (s *Server) ListDeviceCodes(ctx context.Context, req *adminv1.ListDeviceCodesRequest) (*adminv1.ListDeviceCodesResponse, error) {
+	if req.GetClientId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "client_id is required")
+	}
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize <= 0:
+		pageSize = DefaultPageSize
+	case pageSize > MaxPageSize:
+		pageSize = MaxPageSize
+	}
+	offset := 0
+	if token := req.GetPageToken(); token != "" {
+		var err error
+		if offset, err = strconv.Atoi(token); err != nil || offset < 0 {
+			return nil, status.Error(codes.InvalidArgument, "invalid page_token")
+		}
+	}
+
+	list, err := s.flow.ListClientCodes(ctx, req.GetClientId())
+	if err != nil {
+		return nil, flowStatus(err)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ExpiresAt.Before(list[j].ExpiresAt)
+	})
+
+	resp := &adminv1.ListDeviceCodesResponse{}
+	if offset >= len(list) {
+		return resp, nil
+	}
+	end := min(offset+pageSize, len(list))
+	for _, code := range list[offset:end] {
+		resp.DeviceCodes = append(resp.DeviceCodes, deviceCodeMessage(code))
+	}
+	if end < len(list) {
+		resp.NextPageToken = strconv.Itoa(end)
+	}
+	return resp, nil
+}
+
+// RevokeDeviceCode denies a pending flow, so its device receives
+// access_denied on its next poll, and records who revoked it
+func (s *Server) RevokeDeviceCode(ctx context.Context, req *adminv1.RevokeDeviceCodeRequest) (*adminv1.RevokeDeviceCodeResponse, error) {
+	code, err := s.findCode(ctx, req.GetClientId(), req.GetUserCode(), req.GetDeviceCodeHash())
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.flow.GetStatus(ctx, code.DeviceCode)
+	if err != nil {
+		return nil, flowStatus(err)
+	}
+	if state.Finished() {
+		return &adminv1.RevokeDeviceCodeResponse{}, nil
+	}
+	// The flow may end between the status check and the denial, which
+	// refuses to overwrite the outcome
+	err = s.flow.DenyAuthorization(ctx, code.DeviceCode)
+	if errors.Is(err, deviceflow.ErrAlreadyAuthorized) || errors.Is(err, deviceflow.ErrAuthorizationEnded) {
+		return &adminv1.RevokeDeviceCodeResponse{}, nil
+	}
+	if err != nil {
+		return nil, flowStatus(err)
+	}
+
+	record := audit.Record{
+		Action:         audit.ActionCodeRevoked,
+		ClientID:       code.ClientID,
+		UserCode:       code.UserCode,
+		DeviceCodeHash: audit.HashDeviceCode(code.DeviceCode),
+		Scope:          code.Scope,
+		Reason:         req.GetReason(),
+		Actor:          peerSubject(ctx),
+	}
+	if code.Device != nil {
+		record.DeviceID = code.Device.ID
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		record.RemoteIP, _, _ = net.SplitHostPort(p.Addr.String())
+	}
+	if err := s.audit.Record(ctx, record); err != nil {
+		log.Printf("Error: failed to record %s audit entry: %v", audit.ActionCodeRevoked, err)
+	}
+	return &adminv1.RevokeDeviceCodeResponse{Revoked: true}, nil
+}
+
+// GetFlowStatus reports the state of the flow for one of a client's codes
+func (s *Server) GetFlowStatus(ctx context.Context, req *adminv1.GetFlowStatusRequest) (*adminv1.FlowStatus, error) {
+	code, err := s.findCode(ctx, req.GetClientId(), req.GetUserCode(), req.GetDeviceCodeHash())
+	if err != nil {
+		return nil, err
+	}
+	state, err := s.flow.GetStatus(ctx, code.DeviceCode)
+	if err != nil {
+		return nil, flowStatus(err)
+	}
+
+	resp := &adminv1.FlowStatus{State: stateMessage(state), DeviceCode: deviceCodeMessage(code)}
+	if state == deviceflow.StatusFailed && code.Failure != nil {
+		resp.Error = code.Failure.Code
+	}
+	return resp, nil
+}
+
+// StreamEvents delivers lifecycle events until the caller disconnects.
+// Events emitted while the stream cannot keep up are dropped for it.
+func (s *Server) StreamEvents(req *adminv1.StreamEventsRequest, stream adminv1.DeviceAdmin_StreamEventsServer) error {
+	if s.events == nil {
+		return status.Error(codes.Unavailable, "event streaming is not enabled")
+	}
+	types := make([]events.Type, len(req.GetTypes()))
+	for i, t := range req.GetTypes() {
+		types[i] = events.Type(t)
+	}
+	subscription, cancel := s.events.Subscribe(types...)
+	defer cancel()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event := <-subscription:
+			if err := stream.Send(eventMessage(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// findCode looks up an outstanding code of a client by its user code or the
+// hash of its device code
+func (s *Server) findCode(ctx context.Context, clientID, userCode, deviceCodeHash string) (*deviceflow.DeviceCode, error) {
+	if clientID == "" {
+		return nil, status.Error(codes.InvalidArgument, "client_id is required")
+	}
+	if userCode == "" && deviceCodeHash == "" {
+		return nil, status.Error(codes.InvalidArgument, "user_code or device_code_hash is required")
+	}
+
+	list, err := s.flow.ListClientCodes(ctx, clientID)
+	if err != nil {
+		return nil, flowStatus(err)
+	}
+	for _, code := range list {
+		if userCode != "" && validation.NormalizeCode(code.UserCode) == validation.NormalizeCode(userCode) {
+			return code, nil
+		}
+		if deviceCodeHash != "" && audit.HashDeviceCode(code.DeviceCode) == deviceCodeHash {
+			return code, nil
+		}
+	}
+	return nil, status.Error(codes.NotFound, "no outstanding device code matches")
+}
+
+// flowStatus converts a device flow error to a gRPC status
+func flowStatus(err error) error {
+	if errors.Is(err, deviceflow.ErrMissingClientID) {
+		return status.Error(codes.InvalidArgument, "client_id is required")
+	}
+	var dferr *deviceflow.DeviceFlowError
+	if errors.As(err, &dferr) {
+		switch dferr.Code {
+		case deviceflow.ErrorCodeServerError:
+			// Logged and reported as internal below
+		case deviceflow.ErrorCodeTemporarilyUnavailable:
+			return status.Error(codes.Unavailable, dferr.Description)
+		case deviceflow.ErrorCodeExpiredToken:
+			return status.Error(codes.NotFound, dferr.Description)
+		default:
+			return status.Error(codes.FailedPrecondition, dferr.Description)
+		}
+	}
+	log.Printf("Error: gRPC management API: %v", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// peerSubject returns the subject of the caller's verified client certificate
+func peerSubject(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return info.State.VerifiedChains[0][0].Subject.String()
+}
+
+// deviceCodeMessage describes a device code by its audit trail hash
+func deviceCodeMessage(code *deviceflow.DeviceCode) *adminv1.DeviceCode {
+	msg := &adminv1.DeviceCode{
+		DeviceCodeHash: audit.HashDeviceCode(code.DeviceCode),
+		UserCode:       code.UserCode,
+		ClientId:       code.ClientID,
+		Scope:          code.Scope,
+		BatchId:        code.BatchID,
+		ExpiresAt:      timestamppb.New(code.ExpiresAt),
+	}
+	if code.Device != nil {
+		msg.DeviceId = code.Device.ID
+	}
+	return msg
+}
+
+// stateMessage converts a flow status to its message enum
+func stateMessage(state deviceflow.Status) adminv1.FlowStatus_State {
+	switch state {
+	case deviceflow.StatusPending:
+		return adminv1.FlowStatus_STATE_PENDING
+	case deviceflow.StatusAuthorized:
+		return adminv1.FlowStatus_STATE_AUTHORIZED
+	case deviceflow.StatusDenied:
+		return adminv1.FlowStatus_STATE_DENIED
+	case deviceflow.StatusFailed:
+		return adminv1.FlowStatus_STATE_FAILED
+	case deviceflow.StatusExpired:
+		return adminv1.FlowStatus_STATE_EXPIRED
+	default:
+		return adminv1.FlowStatus_STATE_UNSPECIFIED
+	}
+}
+
+// eventMessage converts a lifecycle event, rendering its data as strings
+func eventMessage(event events.Event) *adminv1.Event {
+	msg := &adminv1.Event{
+		Id:             event.ID,
+		Type:           string(event.Type),
+		Time:           timestamppb.New(event.Timestamp),
+		ClientId:       event.ClientID,
+		DeviceCodeHash: event.DeviceCodeHash,
+		DeviceId:       event.DeviceID,
+		Data:           make(map[string]string, len(event.Data)+2),
+	}
+	if event.UserCode != "" {
+		msg.Data["user_code"] = event.UserCode
+	}
+	if event.Scope != "" {
+		msg.Data["scope"] = event.Scope
+	}
+	for key, value := range event.Data {
+		msg.Data[key] = fmt.Sprint(value)
+	}
+	return msg
+}
@@ -0,0 +1,232 @@
+package grpcadmin
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	adminv1 "github.com/wrale/oauth2-device-proxy/api/admin/v1"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+)
+
+// recordingAudit keeps the records written to it
+type recordingAudit struct {
+	audit.NopLogger
+	records []audit.Record
+}
+
+func (a *recordingAudit) Record(ctx context.Context, record audit.Record) error {
+	a.records = append(a.records, record)
+	return nil
+}
+
+// dial serves s over an in-memory connection
+func dial(t *testing.T, s *Server) adminv1.DeviceAdminClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	adminv1.RegisterDeviceAdminServer(srv, s)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dialing: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return adminv1.NewDeviceAdminClient(conn)
+}
+
+func testFlow() (*test.MockFlow, map[string]deviceflow.Status) {
+	now := time.Now()
+	codes := []*deviceflow.DeviceCode{
+		{DeviceCode: "later", UserCode: "BBBB-BBBB", ClientID: "tv", ExpiresAt: now.Add(10 * time.Minute)},
+		{DeviceCode: "sooner", UserCode: "AAAA-AAAA", ClientID: "tv", ExpiresAt: now.Add(5 * time.Minute),
+			Device: &deviceflow.DeviceIdentity{ID: "serial-1"}},
+		{DeviceCode: "failed", UserCode: "CCCC-CCCC", ClientID: "tv", ExpiresAt: now.Add(15 * time.Minute),
+			Failure: deviceflow.NewDeviceFlowError(deviceflow.ErrorCodeAccessDenied, "Denied upstream")},
+	}
+	states := map[string]deviceflow.Status{
+		"later":  deviceflow.StatusPending,
+		"sooner": deviceflow.StatusPending,
+		"failed": deviceflow.StatusFailed,
+	}
+	flow := &test.MockFlow{
+		ListClientCodesFunc: func(ctx context.Context, clientID string) ([]*deviceflow.DeviceCode, error) {
+			if clientID != "tv" {
+				return nil, nil
+			}
+			return append([]*deviceflow.DeviceCode(nil), codes...), nil
+		},
+		GetStatusFunc: func(ctx context.Context, deviceCode string) (deviceflow.Status, error) {
+			return states[deviceCode], nil
+		},
+		DenyAuthFunc: func(ctx context.Context, deviceCode string) error {
+			states[deviceCode] = deviceflow.StatusDenied
+			return nil
+		},
+	}
+	return flow, states
+}
+
+func TestListDeviceCodes(t *testing.T) {
+	flow, _ := testFlow()
+	client := dial(t, New(Config{Flow: flow}))
+	ctx := context.Background()
+
+	first, err := client.ListDeviceCodes(ctx, &adminv1.ListDeviceCodesRequest{ClientId: "tv", PageSize: 2})
+	if err != nil {
+		t.Fatalf("ListDeviceCodes failed: %v", err)
+	}
+	if len(first.DeviceCodes) != 2 || first.DeviceCodes[0].UserCode != "AAAA-AAAA" || first.NextPageToken == "" {
+		t.Fatalf("first page = %v, want the two codes expiring soonest and a page token", first)
+	}
+	if got := first.DeviceCodes[0]; got.DeviceCodeHash != audit.HashDeviceCode("sooner") || got.DeviceId != "serial-1" {
+		t.Errorf("code = %v, want its hash and device ID", got)
+	}
+
+	second, err := client.ListDeviceCodes(ctx, &adminv1.ListDeviceCodesRequest{ClientId: "tv", PageSize: 2, PageToken: first.NextPageToken})
+	if err != nil {
+		t.Fatalf("ListDeviceCodes failed: %v", err)
+	}
+	if len(second.DeviceCodes) != 1 || second.DeviceCodes[0].UserCode != "CCCC-CCCC" || second.NextPageToken != "" {
+		t.Errorf("second page = %v, want the last code", second)
+	}
+
+	for name, req := range map[string]*adminv1.ListDeviceCodesRequest{
+		"missing client": {},
+		"bad page token": {ClientId: "tv", PageToken: "x"},
+	} {
+		if _, err := client.ListDeviceCodes(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: error = %v, want InvalidArgument", name, err)
+		}
+	}
+}
+
+func TestRevokeDeviceCode(t *testing.T) {
+	flow, states := testFlow()
+	auditLog := &recordingAudit{}
+	client := dial(t, New(Config{Flow: flow, Audit: auditLog}))
+	ctx := context.Background()
+
+	resp, err := client.RevokeDeviceCode(ctx, &adminv1.RevokeDeviceCodeRequest{
+		ClientId: "tv",
+		Code:     &adminv1.RevokeDeviceCodeRequest_UserCode{UserCode: "aaaaaaaa"},
+		Reason:   "returned hardware",
+	})
+	if err != nil {
+		t.Fatalf("RevokeDeviceCode failed: %v", err)
+	}
+	if !resp.Revoked || states["sooner"] != deviceflow.StatusDenied {
+		t.Errorf("revoked = %v, state = %s, want the pending code denied", resp.Revoked, states["sooner"])
+	}
+	if len(auditLog.records) != 1 || auditLog.records[0].Action != audit.ActionCodeRevoked ||
+		auditLog.records[0].Reason != "returned hardware" || auditLog.records[0].DeviceCodeHash != audit.HashDeviceCode("sooner") {
+		t.Errorf("audit records = %+v", auditLog.records)
+	}
+
+	// Finished flows are left alone
+	resp, err = client.RevokeDeviceCode(ctx, &adminv1.RevokeDeviceCodeRequest{
+		ClientId: "tv",
+		Code:     &adminv1.RevokeDeviceCodeRequest_DeviceCodeHash{DeviceCodeHash: audit.HashDeviceCode("failed")},
+	})
+	if err != nil || resp.Revoked {
+		t.Errorf("revoking a finished flow = %v, %v, want not revoked", resp, err)
+	}
+
+	// As are flows approved after their status was read
+	flow.DenyAuthFunc = func(ctx context.Context, deviceCode string) error {
+		return deviceflow.ErrAlreadyAuthorized
+	}
+	resp, err = client.RevokeDeviceCode(ctx, &adminv1.RevokeDeviceCodeRequest{
+		ClientId: "tv",
+		Code:     &adminv1.RevokeDeviceCodeRequest_UserCode{UserCode: "BBBB-BBBB"},
+	})
+	if err != nil || resp.Revoked || len(auditLog.records) != 1 {
+		t.Errorf("revoking a flow approved concurrently = %v, %v, want not revoked or audited", resp, err)
+	}
+
+	_, err = client.RevokeDeviceCode(ctx, &adminv1.RevokeDeviceCodeRequest{
+		ClientId: "tv",
+		Code:     &adminv1.RevokeDeviceCodeRequest_UserCode{UserCode: "ZZZZ-ZZZZ"},
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("unknown code error = %v, want NotFound", err)
+	}
+}
+
+func TestGetFlowStatus(t *testing.T) {
+	flow, _ := testFlow()
+	client := dial(t, New(Config{Flow: flow}))
+
+	resp, err := client.GetFlowStatus(context.Background(), &adminv1.GetFlowStatusRequest{
+		ClientId: "tv",
+		Code:     &adminv1.GetFlowStatusRequest_UserCode{UserCode: "CCCC-CCCC"},
+	})
+	if err != nil {
+		t.Fatalf("GetFlowStatus failed: %v", err)
+	}
+	if resp.State != adminv1.FlowStatus_STATE_FAILED || resp.Error != deviceflow.ErrorCodeAccessDenied {
+		t.Errorf("status = %v, want failed with access_denied", resp)
+	}
+
+	_, err = client.GetFlowStatus(context.Background(), &adminv1.GetFlowStatusRequest{ClientId: "tv"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("missing code error = %v, want InvalidArgument", err)
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	broadcaster := events.NewBroadcaster()
+	client := dial(t, New(Config{Flow: &test.MockFlow{}, Events: broadcaster}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.StreamEvents(ctx, &adminv1.StreamEventsRequest{Types: []string{string(events.TypeAuthorizationDenied)}})
+	if err != nil {
+		t.Fatalf("StreamEvents failed: %v", err)
+	}
+
+	// Emit until the subscription is in place, since the stream is
+	// established asynchronously
+	received := make(chan *adminv1.Event, 1)
+	go func() {
+		if event, err := stream.Recv(); err == nil {
+			received <- event
+		}
+	}()
+	event := events.New(events.TypeAuthorizationDenied)
+	event.ClientID = "tv"
+	event.DeviceCodeHash = audit.HashDeviceCode("sooner")
+	event.Data = map[string]any{"attempts": 2}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(5 * time.Second)
+	for {
+		broadcaster.Emit(ctx, events.New(events.TypeDeviceCodeCreated))
+		broadcaster.Emit(ctx, event)
+		select {
+		case got := <-received:
+			if got.Type != string(events.TypeAuthorizationDenied) || got.ClientId != "tv" ||
+				got.DeviceCodeHash != event.DeviceCodeHash || got.Data["attempts"] != "2" {
+				t.Errorf("event = %v", got)
+			}
+			return
+		case <-ticker.C:
+		case <-timeout:
+			t.Fatal("no event received")
+		}
+	}
+}
@@ -1,24 +1,35 @@
 package health
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
+	"github.com/wrale/oauth2-device-proxy/internal/buildinfo"
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
 )
 
 // Handler processes health check requests
 type Handler struct {
-	flow    deviceflow.Flow // Changed from *deviceflow.Flow to deviceflow.Flow
-	version string          // Added version field
+	flow         deviceflow.Flow // Changed from *deviceflow.Flow to deviceflow.Flow
+	version      string          // Added version field
+	build        *buildinfo.Info
+	dependencies []dependency
+}
+
+// dependency is a non-critical upstream whose failure degrades but does not fail health
+type dependency struct {
+	name  string
+	check func(ctx context.Context) error
 }
 
 // Response represents the health check response.
 // Note: Version field is omitted when empty per RFC 8628 error response format.
 type Response struct {
-	Status  string         `json:"status"`
-	Version string         `json:"version,omitempty"` // Added Version field
-	Details map[string]any `json:"details,omitempty"`
+	Status  string          `json:"status"`
+	Version string          `json:"version,omitempty"` // Added Version field
+	Build   *buildinfo.Info `json:"build,omitempty"`   // Commit, build date and enabled features
+	Details map[string]any  `json:"details,omitempty"`
 }
 
 // New creates a new health check handler
@@ -35,6 +46,20 @@ func (h *Handler) WithVersion(version string) *Handler {
 	return h
 }
 
+// WithBuildInfo includes build details in health check responses
+func (h *Handler) WithBuildInfo(info buildinfo.Info) *Handler {
+	h.version = info.Version
+	h.build = &info
+	return h
+}
+
+// WithDependency adds a non-critical dependency check. Failures report the service
+// as degraded with 200 OK, since pending device flows can still be polled.
+func (h *Handler) WithDependency(name string, check func(ctx context.Context) error) *Handler {
+	h.dependencies = append(h.dependencies, dependency{name: name, check: check})
+	return h
+}
+
 // ServeHTTP handles health check requests
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Set required headers
@@ -44,6 +69,7 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Initialize response with healthy status
 	response := Response{
 		Status:  "healthy",
+		Build:   h.build,
 		Details: make(map[string]any),
 	}
 
@@ -65,8 +91,25 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Check non-critical dependencies
+	for _, dep := range h.dependencies {
+		if err := dep.check(r.Context()); err != nil {
+			if response.Status == "healthy" {
+				response.Status = "degraded"
+			}
+			response.Details[dep.name] = map[string]any{
+				"status":  "unhealthy",
+				"message": err.Error(),
+			}
+		} else {
+			response.Details[dep.name] = map[string]any{
+				"status": "healthy",
+			}
+		}
+	}
+
 	// Set status code based on overall health
-	if response.Status != "healthy" {
+	if response.Status == "unhealthy" {
 		w.WriteHeader(http.StatusServiceUnavailable)
 	}
 
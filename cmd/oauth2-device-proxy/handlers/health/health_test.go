@@ -9,10 +9,13 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
+	"github.com/wrale/oauth2-device-proxy/internal/buildinfo"
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
 )
 
 type mockFlow struct {
+	test.MockFlow
 	checkHealthFunc func(ctx context.Context) error
 }
 
@@ -24,7 +27,7 @@ func (m *mockFlow) CheckHealth(ctx context.Context) error {
 }
 
 // Mock required Flow interface methods
-func (m *mockFlow) RequestDeviceCode(ctx context.Context, clientID string, scope string) (*deviceflow.DeviceCode, error) {
+func (m *mockFlow) RequestDeviceCode(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
 	return nil, errors.New("not implemented in mock")
 }
 
@@ -123,3 +126,64 @@ func TestHealthHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestHealthHandlerDependencies(t *testing.T) {
+	tests := []struct {
+		name       string
+		flowErr    error
+		depErr     error
+		wantCode   int
+		wantStatus string
+	}{
+		{name: "all healthy", wantCode: http.StatusOK, wantStatus: "healthy"},
+		{name: "dependency failing", depErr: errors.New("upstream circuit breaker is open"), wantCode: http.StatusOK, wantStatus: "degraded"},
+		{name: "flow and dependency failing", flowErr: errors.New("redis down"), depErr: errors.New("idp down"), wantCode: http.StatusServiceUnavailable, wantStatus: "unhealthy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow := &mockFlow{checkHealthFunc: func(ctx context.Context) error { return tt.flowErr }}
+			handler := New(flow).WithDependency("identity_provider", func(ctx context.Context) error {
+				return tt.depErr
+			})
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+
+			var got Response
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", got.Status, tt.wantStatus)
+			}
+			if _, ok := got.Details["identity_provider"]; !ok {
+				t.Error("missing identity_provider details")
+			}
+		})
+	}
+}
+
+func TestHealthHandlerBuildInfo(t *testing.T) {
+	info := buildinfo.Info{Version: "1.2.0", Commit: "abc123", BuildDate: "2024-05-01T00:00:00Z", GoVersion: "go1.21.0"}.
+		WithFeatures("token_renewal")
+	handler := New(&mockFlow{}).WithBuildInfo(info)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+
+	var got Response
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if got.Version != "1.2.0" {
+		t.Errorf("version = %q, want 1.2.0", got.Version)
+	}
+	if diff := cmp.Diff(&info, got.Build); diff != "" {
+		t.Errorf("build info mismatch (-want +got):\n%s", diff)
+	}
+}
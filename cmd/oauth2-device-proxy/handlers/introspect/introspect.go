@@ -0,0 +1,263 @@
+// Package introspect proxies OAuth 2.0 token introspection per RFC 7662, so
+// that resource servers can validate device tokens through the proxy without
+// credentials for the identity provider
+package introspect
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// Path is where the introspection endpoint is served
+const Path = "/introspect"
+
+// Cache defaults
+const (
+	DefaultCacheTTL   = time.Minute
+	DefaultMaxEntries = 10000
+)
+
+// IntrospectFunc asks the authorization server about a token per RFC 7662
+// section 2.1, returning its introspection response body
+type IntrospectFunc func(ctx context.Context, token, tokenTypeHint string) ([]byte, error)
+
+// Config configures an introspection Handler. Zero values use the defaults.
+type Config struct {
+	Introspect      IntrospectFunc
+	ResourceServers map[string]string // Secrets of the resource servers allowed to introspect, by ID
+	CacheTTL        time.Duration     // Upper bound on caching a response, which never outlives the token
+	MaxEntries      int               // Maximum cached responses
+}
+
+// Handler serves the introspection endpoint
+type Handler struct {
+	introspect      IntrospectFunc
+	resourceServers map[string]string
+	cacheTTL        time.Duration
+	maxEntries      int
+	now             func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a cached introspection response
+type cacheEntry struct {
+	body      []byte
+	expiresAt time.Time
+}
+
+// response holds the members of an introspection response that decide how
+// long it may be cached
+type response struct {
+	Active    *bool `json:"active"`
+	ExpiresAt int64 `json:"exp"` // Seconds since the epoch per RFC 7662 section 2.2
+}
+
+// New creates an introspection handler
+func New(cfg Config) *Handler {
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultCacheTTL
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultMaxEntries
+	}
+	return &Handler{
+		introspect:      cfg.Introspect,
+		resourceServers: cfg.ResourceServers,
+		cacheTTL:        cfg.CacheTTL,
+		maxEntries:      cfg.MaxEntries,
+		now:             time.Now,
+		entries:         make(map[string]cacheEntry),
+	}
+}
+
+// ParseResourceServers parses comma-separated id:secret pairs naming the
+// resource servers allowed to introspect tokens
+func ParseResourceServers(spec string) (map[string]string, error) {
+	servers := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(pair, ":")
+		if !ok || id == "" || secret == "" {
+			return nil, fmt.Errorf("invalid resource server %q, want id:secret", pair)
+		}
+		if _, dup := servers[id]; dup {
+			return nil, fmt.Errorf("duplicate resource server %q", id)
+		}
+		servers[id] = secret
+	}
+	return servers, nil
+}
+
+// ServeHTTP answers an introspection request per RFC 7662 section 2, from the
+// cache when the token was introspected recently
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		common.WriteErrorStatus(w, http.StatusMethodNotAllowed, deviceflow.ErrorCodeInvalidRequest, "POST method required")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Invalid request format")
+		return
+	}
+
+	// Introspection requires authorization per RFC 7662 section 2.1, so that
+	// the endpoint cannot be used to scan for valid tokens
+	if _, ok := h.authenticate(r); !ok {
+		introspections.Inc("unauthorized")
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+		common.WriteError(w, deviceflow.ErrorCodeInvalidClient, "Resource server authentication failed")
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Missing token parameter")
+		return
+	}
+
+	key := cacheKey(token)
+	if body, ok := h.cached(key); ok {
+		introspections.Inc("cached")
+		writeResponse(w, body)
+		return
+	}
+
+	body, err := h.introspect(r.Context(), token, r.PostForm.Get("token_type_hint"))
+	if err != nil {
+		introspections.Inc("failed")
+		log.Printf("Error: token introspection failed: %v", err)
+		common.WriteErrorStatus(w, http.StatusBadGateway, deviceflow.ErrorCodeServerError, "Unable to introspect the token")
+		return
+	}
+
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil || resp.Active == nil {
+		introspections.Inc("failed")
+		log.Printf("Error: invalid introspection response from the authorization server")
+		common.WriteErrorStatus(w, http.StatusBadGateway, deviceflow.ErrorCodeServerError, "Invalid response from the authorization server")
+		return
+	}
+	h.store(key, body, resp)
+	introspections.Inc("forwarded")
+	writeResponse(w, body)
+}
+
+// authenticate identifies the resource server by HTTP Basic credentials per
+// RFC 6749 section 2.3.1, which form-encodes the ID and secret
+func (h *Handler) authenticate(r *http.Request) (string, bool) {
+	id, secret, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	if decoded, err := url.QueryUnescape(id); err == nil {
+		id = decoded
+	}
+	if decoded, err := url.QueryUnescape(secret); err == nil {
+		secret = decoded
+	}
+
+	want, known := h.resourceServers[id]
+	if !known {
+		// Compare anyway so unknown IDs take as long as wrong secrets
+		want = "\x00"
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(want)) != 1 || !known {
+		return "", false
+	}
+	return id, true
+}
+
+// cached returns the cached response for a token, if still fresh
+func (h *Handler) cached(key string) ([]byte, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entry, ok := h.entries[key]
+	if !ok || !h.now().Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+// store caches a response until the token expires or the cache TTL passes,
+// whichever is first. Inactive tokens never become active again, so their
+// responses are cached for the full TTL.
+func (h *Handler) store(key string, body []byte, resp response) {
+	now := h.now()
+	expiresAt := now.Add(h.cacheTTL)
+	if *resp.Active && resp.ExpiresAt > 0 {
+		if exp := time.Unix(resp.ExpiresAt, 0); exp.Before(expiresAt) {
+			expiresAt = exp
+		}
+	}
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.evictLocked(now)
+	h.entries[key] = cacheEntry{body: body, expiresAt: expiresAt}
+}
+
+// evictLocked makes room for a new entry, dropping expired entries first.
+// Callers hold h.mu.
+func (h *Handler) evictLocked(now time.Time) {
+	if len(h.entries) < h.maxEntries {
+		return
+	}
+	for key, entry := range h.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(h.entries, key)
+		}
+	}
+	for key := range h.entries {
+		if len(h.entries) < h.maxEntries {
+			break
+		}
+		delete(h.entries, key)
+	}
+}
+
+// writeResponse relays an introspection response, which must not be cached
+// by intermediaries
+func writeResponse(w http.ResponseWriter, body []byte) {
+	common.SetJSONHeaders(w)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil && !errors.Is(err, http.ErrHandlerTimeout) {
+		log.Printf("Warning: writing introspection response: %v", err)
+	}
+}
+
+// cacheKey hashes the token so that raw bearer tokens are not held as map keys
+func cacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// introspections counts introspection requests by outcome
+var introspections = metrics.Default.NewCounter(
+	"device_proxy_introspection_requests_total",
+	"Token introspection requests by outcome.",
+	"result",
+)
@@ -0,0 +1,177 @@
+package introspect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeProvider answers introspection requests from canned responses
+type fakeProvider struct {
+	responses map[string]string
+	calls     int
+	err       error
+}
+
+func (f *fakeProvider) introspect(ctx context.Context, token, tokenTypeHint string) ([]byte, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if body, ok := f.responses[token]; ok {
+		return []byte(body), nil
+	}
+	return []byte(`{"active":false}`), nil
+}
+
+func newTestHandler(provider *fakeProvider) *Handler {
+	return New(Config{
+		Introspect:      provider.introspect,
+		ResourceServers: map[string]string{"api": "api-secret"},
+	})
+}
+
+func introspectRequest(token, id, secret string) *http.Request {
+	form := url.Values{"token": {token}}
+	req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if id != "" {
+		req.SetBasicAuth(id, secret)
+	}
+	return req
+}
+
+func TestServeHTTPAuthentication(t *testing.T) {
+	provider := &fakeProvider{}
+	handler := newTestHandler(provider)
+
+	tests := []struct {
+		name       string
+		id, secret string
+		wantStatus int
+	}{
+		{name: "valid credentials", id: "api", secret: "api-secret", wantStatus: http.StatusOK},
+		{name: "wrong secret", id: "api", secret: "guess", wantStatus: http.StatusUnauthorized},
+		{name: "unknown resource server", id: "other", secret: "api-secret", wantStatus: http.StatusUnauthorized},
+		{name: "no credentials", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, introspectRequest("token", tt.id, tt.secret))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Code == http.StatusUnauthorized && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic") {
+				t.Errorf("WWW-Authenticate = %q, want a Basic challenge", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider called %d times, want only for the authenticated request", provider.calls)
+	}
+}
+
+func TestServeHTTPCaching(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	provider := &fakeProvider{responses: map[string]string{
+		"long-lived":  `{"active":true,"sub":"alice","exp":` + formatUnix(now.Add(time.Hour)) + `}`,
+		"short-lived": `{"active":true,"sub":"bob","exp":` + formatUnix(now.Add(10*time.Second)) + `}`,
+	}}
+	handler := newTestHandler(provider)
+	handler.now = func() time.Time { return now }
+
+	introspect := func(token string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, introspectRequest(token, "api", "api-secret"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+		}
+		if w.Header().Get("Cache-Control") != "no-store" {
+			t.Errorf("Cache-Control = %q, want no-store", w.Header().Get("Cache-Control"))
+		}
+		return w.Body.String()
+	}
+
+	if body := introspect("long-lived"); !strings.Contains(body, `"sub":"alice"`) {
+		t.Errorf("response = %s, want the provider's answer", body)
+	}
+	introspect("long-lived")
+	introspect("short-lived")
+	introspect("unknown")
+	introspect("unknown")
+	if provider.calls != 3 {
+		t.Fatalf("provider called %d times, want 3 with cached repeats", provider.calls)
+	}
+
+	// Answers are not cached beyond the token's expiry
+	now = now.Add(30 * time.Second)
+	introspect("long-lived")
+	introspect("short-lived")
+	if provider.calls != 4 {
+		t.Errorf("provider called %d times, want the expired token introspected again", provider.calls)
+	}
+
+	// Nor beyond the cache TTL
+	now = now.Add(DefaultCacheTTL)
+	introspect("long-lived")
+	if provider.calls != 5 {
+		t.Errorf("provider called %d times, want the stale answer refreshed", provider.calls)
+	}
+}
+
+func TestServeHTTPErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   *fakeProvider
+		token      string
+		wantStatus int
+	}{
+		{name: "missing token", provider: &fakeProvider{}, wantStatus: http.StatusBadRequest},
+		{name: "provider unavailable", provider: &fakeProvider{err: errors.New("connection refused")}, token: "t", wantStatus: http.StatusBadGateway},
+		{name: "invalid provider response", provider: &fakeProvider{responses: map[string]string{"t": `<html>`}}, token: "t", wantStatus: http.StatusBadGateway},
+		{name: "response without active", provider: &fakeProvider{responses: map[string]string{"t": `{"sub":"alice"}`}}, token: "t", wantStatus: http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			newTestHandler(tt.provider).ServeHTTP(w, introspectRequest(tt.token, "api", "api-secret"))
+			if w.Code != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	newTestHandler(&fakeProvider{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, Path, nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status code = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestParseResourceServers(t *testing.T) {
+	servers, err := ParseResourceServers(" api:s3cret, billing:pa:ss ,")
+	if err != nil {
+		t.Fatalf("ParseResourceServers() error = %v", err)
+	}
+	if len(servers) != 2 || servers["api"] != "s3cret" || servers["billing"] != "pa:ss" {
+		t.Errorf("servers = %v", servers)
+	}
+
+	for _, spec := range []string{"api", "api:", ":secret", "api:a,api:b"} {
+		if _, err := ParseResourceServers(spec); err == nil {
+			t.Errorf("ParseResourceServers(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func formatUnix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
@@ -0,0 +1,79 @@
+package passthrough
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// ClientCredentialsPath is where back-end clients obtain tokens with the
+// client_credentials grant
+const ClientCredentialsPath = "/token"
+
+// grantTypeClientCredentials is the client credentials grant per RFC 6749
+// section 4.4
+const grantTypeClientCredentials = "client_credentials"
+
+// ServeClientCredentials forwards a client credentials token request per RFC
+// 6749 section 4.4, so that provisioning tools enrolling devices without a
+// user can reach the identity provider the proxy is configured for. Only
+// clients enabled in the registry are forwarded, and they authenticate with
+// their own credentials; the proxy adds none of its own.
+func (h *Handler) ServeClientCredentials(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "POST method required")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Invalid request format")
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "":
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "The grant_type parameter is REQUIRED")
+		return
+	case grantTypeClientCredentials:
+	default:
+		common.WriteError(w, deviceflow.ErrorCodeUnsupportedGrant, "Only client_credentials is supported")
+		return
+	}
+
+	clientID, ok := requestClientID(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		common.WriteError(w, deviceflow.ErrorCodeInvalidClient, "Client authentication is REQUIRED")
+		return
+	}
+	if !h.clients.AllowsClientCredentials(clientID) {
+		common.WriteError(w, deviceflow.ErrorCodeUnauthorizedClient,
+			"The client is not allowed to use the client_credentials grant")
+		return
+	}
+
+	resp, body, ok := h.forward(w, r, h.tokenEndpoint)
+	if !ok {
+		return
+	}
+	writeUpstream(w, resp, body)
+}
+
+// requestClientID returns the client a token request claims to be, from its
+// HTTP Basic credentials per RFC 6749 section 2.3.1 or its client_id
+// parameter. The identity provider verifies the credentials.
+func requestClientID(r *http.Request) (string, bool) {
+	if username, _, ok := r.BasicAuth(); ok {
+		clientID, err := url.QueryUnescape(username)
+		if err != nil || clientID == "" {
+			return "", false
+		}
+		if formID := r.PostForm.Get("client_id"); formID != "" && formID != clientID {
+			return "", false // Two identities, which the provider may resolve differently
+		}
+		return clientID, true
+	}
+	clientID := r.PostForm.Get("client_id")
+	return clientID, clientID != ""
+}
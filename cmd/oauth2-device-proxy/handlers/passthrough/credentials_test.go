@@ -0,0 +1,73 @@
+package passthrough
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/clients"
+)
+
+func TestServeClientCredentials(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if _, secret, _ := r.BasicAuth(); secret != "s3cret" && r.FormValue("client_secret") != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"service","token_type":"Bearer","expires_in":300}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	registry, err := clients.NewRegistry([]clients.Client{
+		{ID: "provisioner", ClientCredentials: true},
+		{ID: "tv"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(Config{TokenEndpoint: upstream.URL, Clients: registry})
+
+	tests := []struct {
+		name        string
+		form        url.Values
+		basicUser   string
+		wantStatus  int
+		wantContain string
+	}{
+		{name: "form credentials", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {"provisioner"}, "client_secret": {"s3cret"}},
+			wantStatus: http.StatusOK, wantContain: `"access_token":"service"`},
+		{name: "basic credentials", form: url.Values{"grant_type": {"client_credentials"}}, basicUser: "provisioner",
+			wantStatus: http.StatusOK, wantContain: `"access_token":"service"`},
+		{name: "wrong secret relayed", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {"provisioner"}, "client_secret": {"guess"}},
+			wantStatus: http.StatusUnauthorized, wantContain: `"error":"invalid_client"`},
+		{name: "client not enabled", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {"tv"}, "client_secret": {"s3cret"}},
+			wantStatus: http.StatusBadRequest, wantContain: `"error":"unauthorized_client"`},
+		{name: "unlisted client", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {"stranger"}, "client_secret": {"s3cret"}},
+			wantStatus: http.StatusBadRequest, wantContain: `"error":"unauthorized_client"`},
+		{name: "conflicting identities", form: url.Values{"grant_type": {"client_credentials"}, "client_id": {"tv"}}, basicUser: "provisioner",
+			wantStatus: http.StatusUnauthorized, wantContain: `"error":"invalid_client"`},
+		{name: "anonymous", form: url.Values{"grant_type": {"client_credentials"}},
+			wantStatus: http.StatusUnauthorized, wantContain: `"error":"invalid_client"`},
+		{name: "other grant", form: url.Values{"grant_type": {"password"}, "client_id": {"provisioner"}},
+			wantStatus: http.StatusBadRequest, wantContain: `"error":"unsupported_grant_type"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := postForm(tt.form)
+			if tt.basicUser != "" {
+				req.SetBasicAuth(tt.basicUser, "s3cret")
+			}
+			w := httptest.NewRecorder()
+			h.ServeClientCredentials(w, req)
+
+			if w.Code != tt.wantStatus || !strings.Contains(w.Body.String(), tt.wantContain) {
+				t.Errorf("response = %d %s, want %d containing %s", w.Code, w.Body, tt.wantStatus, tt.wantContain)
+			}
+		})
+	}
+}
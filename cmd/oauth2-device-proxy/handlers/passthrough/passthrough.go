@@ -0,0 +1,227 @@
+// Package passthrough forwards device authorization and token requests to an
+// identity provider implementing RFC 8628 itself, instead of simulating the
+// grant with the authorization code flow. The proxy keeps hosting the
+// verification page and hands entered codes to the provider's own page.
+package passthrough
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/clients"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+)
+
+// maxUpstreamResponse bounds how much of a provider response is read
+const maxUpstreamResponse = 1 << 20
+
+// forwardedHeaders are copied from provider responses to devices
+var forwardedHeaders = []string{"Cache-Control", "Content-Type", "Pragma", "Retry-After", "WWW-Authenticate"}
+
+// Config configures a passthrough Handler
+type Config struct {
+	DeviceEndpoint  string // Provider device authorization endpoint per RFC 8628 section 3.1
+	TokenEndpoint   string // Provider token endpoint per RFC 8628 section 3.4
+	VerificationURI string // Provider verification page, which accepts a user_code parameter
+	BaseURL         string
+	Templates       *templates.Templates
+	HTTPClient      *http.Client // http.DefaultClient if nil
+
+	// Clients decides which clients may use ServeClientCredentials, none if nil
+	Clients *clients.Registry
+}
+
+// Handler forwards the device endpoints to the identity provider
+type Handler struct {
+	deviceEndpoint  string
+	tokenEndpoint   string
+	verificationURI string
+	baseURL         string
+	templates       *templates.Templates
+	client          *http.Client
+	clients         *clients.Registry
+}
+
+// New creates a passthrough handler
+func New(cfg Config) *Handler {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return &Handler{
+		deviceEndpoint:  cfg.DeviceEndpoint,
+		tokenEndpoint:   cfg.TokenEndpoint,
+		verificationURI: cfg.VerificationURI,
+		baseURL:         strings.TrimRight(cfg.BaseURL, "/"),
+		templates:       cfg.Templates,
+		client:          cfg.HTTPClient,
+		clients:         cfg.Clients,
+	}
+}
+
+// ServeDeviceCode forwards a device authorization request, pointing the
+// verification URIs of a successful response at the proxy's page
+func (h *Handler) ServeDeviceCode(w http.ResponseWriter, r *http.Request) {
+	resp, body, ok := h.forward(w, r, h.deviceEndpoint)
+	if !ok {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		writeUpstream(w, resp, body)
+		return
+	}
+
+	var grant map[string]any
+	if err := json.Unmarshal(body, &grant); err != nil {
+		log.Printf("Error: parsing upstream device authorization response: %v", err)
+		common.WriteErrorStatus(w, http.StatusBadGateway, deviceflow.ErrorCodeServerError,
+			"Invalid response from the authorization server")
+		return
+	}
+
+	verificationURI := h.baseURL + "/device"
+	grant["verification_uri"] = verificationURI
+	if _, ok := grant["verification_uri_complete"]; ok {
+		if userCode, _ := grant["user_code"].(string); userCode != "" {
+			grant["verification_uri_complete"] = verificationURI + "?code=" + url.QueryEscape(userCode)
+		} else {
+			delete(grant, "verification_uri_complete")
+		}
+	}
+
+	common.SetJSONHeaders(w)
+	if err := json.NewEncoder(w).Encode(grant); err != nil {
+		common.WriteJSONError(w, err)
+	}
+}
+
+// ServeToken forwards a device access token request and relays the provider's
+// response, including its authorization_pending and slow_down errors
+func (h *Handler) ServeToken(w http.ResponseWriter, r *http.Request) {
+	resp, body, ok := h.forward(w, r, h.tokenEndpoint)
+	if !ok {
+		return
+	}
+	writeUpstream(w, resp, body)
+}
+
+// HandleForm shows the verification form
+func (h *Handler) HandleForm(w http.ResponseWriter, r *http.Request) {
+	baseURL, err := url.Parse(h.baseURL)
+	if err != nil {
+		h.renderError(w, "Unable to display verification page")
+		return
+	}
+	baseURL.Path = path.Join(baseURL.Path, "device")
+	verificationURI := baseURL.String()
+
+	code := r.URL.Query().Get("code")
+	data := templates.VerifyData{
+		PrefilledCode:   code,
+		VerificationURI: verificationURI,
+	}
+	if code != "" {
+		completeURI := verificationURI + "?code=" + url.QueryEscape(code)
+		if qrCode, err := h.templates.GenerateQRCode(completeURI); err != nil {
+			log.Printf("Warning: QR code generation failed: %v", err)
+		} else {
+			data.VerificationQRCodeSVG = qrCode
+			data.VerificationQRCodeURL = h.templates.QRCodePath(completeURI)
+		}
+	}
+
+	if err := h.templates.RenderVerify(w, data); err != nil {
+		log.Printf("Error: rendering verification page: %v", err)
+	}
+}
+
+// HandleSubmit sends the user to the provider's verification page with the
+// entered code. The provider validates the code and asks for consent.
+func (h *Handler) HandleSubmit(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		h.renderError(w, "The submitted form could not be read")
+		return
+	}
+	code := strings.TrimSpace(r.PostForm.Get("code"))
+	if code == "" {
+		http.Redirect(w, r, h.baseURL+"/device", http.StatusSeeOther)
+		return
+	}
+
+	target, err := url.Parse(h.verificationURI)
+	if err != nil {
+		h.renderError(w, "Unable to continue verification")
+		return
+	}
+	query := target.Query()
+	query.Set("user_code", code)
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusSeeOther)
+}
+
+// forward sends the request's form and client authentication to the provider,
+// writing an error response when it cannot be reached
+func (h *Handler) forward(w http.ResponseWriter, r *http.Request, endpoint string) (*http.Response, []byte, bool) {
+	if r.Method != http.MethodPost {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "POST method required")
+		return nil, nil, false
+	}
+	if err := r.ParseForm(); err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Invalid request format")
+		return nil, nil, false
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, endpoint, strings.NewReader(r.PostForm.Encode()))
+	if err != nil {
+		log.Printf("Error: creating upstream request: %v", err)
+		common.WriteError(w, deviceflow.ErrorCodeServerError, "Failed to contact the authorization server")
+		return nil, nil, false
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		req.Header.Set("Authorization", auth) // HTTP Basic client authentication per RFC 6749 section 2.3.1
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		log.Printf("Error: forwarding to %s: %v", endpoint, err)
+		common.WriteError(w, deviceflow.ErrorCodeTemporarilyUnavailable,
+			"The authorization server is unavailable")
+		return nil, nil, false
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamResponse))
+	if err != nil {
+		log.Printf("Error: reading response from %s: %v", endpoint, err)
+		common.WriteErrorStatus(w, http.StatusBadGateway, deviceflow.ErrorCodeServerError,
+			"Invalid response from the authorization server")
+		return nil, nil, false
+	}
+	return resp, body, true
+}
+
+// writeUpstream relays a provider response unchanged
+func writeUpstream(w http.ResponseWriter, resp *http.Response, body []byte) {
+	for _, name := range forwardedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(body)
+}
+
+// renderError shows the error page
+func (h *Handler) renderError(w http.ResponseWriter, message string) {
+	if err := h.templates.RenderError(w, templates.ErrorData{Title: "Verification Failed", Message: message}); err != nil {
+		log.Printf("Error: rendering error page: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package passthrough
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		switch r.URL.Path {
+		case "/device":
+			if r.FormValue("client_id") != "tv" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"device_code":"dc","user_code":"WDJB-MJHT","verification_uri":"https://idp.example.com/device",` +
+				`"verification_uri_complete":"https://idp.example.com/device?user_code=WDJB-MJHT","expires_in":600,"interval":5}`))
+		case "/token":
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"authorization_pending"}`))
+		}
+	}))
+	t.Cleanup(upstream.Close)
+
+	tmpls, err := templates.LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(Config{
+		DeviceEndpoint:  upstream.URL + "/device",
+		TokenEndpoint:   upstream.URL + "/token",
+		VerificationURI: "https://idp.example.com/device",
+		BaseURL:         "https://proxy.example.com",
+		Templates:       tmpls,
+	})
+}
+
+func postForm(values url.Values) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+func TestServeDeviceCode(t *testing.T) {
+	h := newTestHandler(t)
+
+	w := httptest.NewRecorder()
+	h.ServeDeviceCode(w, postForm(url.Values{"client_id": {"tv"}}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body)
+	}
+	var grant map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &grant); err != nil {
+		t.Fatal(err)
+	}
+	if grant["device_code"] != "dc" || grant["interval"] != float64(5) {
+		t.Errorf("grant = %v, want the provider's device code and interval", grant)
+	}
+	if got := grant["verification_uri"]; got != "https://proxy.example.com/device" {
+		t.Errorf("verification_uri = %v, want the proxy page", got)
+	}
+	if got := grant["verification_uri_complete"]; got != "https://proxy.example.com/device?code=WDJB-MJHT" {
+		t.Errorf("verification_uri_complete = %v, want the proxy page", got)
+	}
+
+	// Provider errors are relayed unchanged
+	w = httptest.NewRecorder()
+	h.ServeDeviceCode(w, postForm(url.Values{"client_id": {"unknown"}}))
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "invalid_client") {
+		t.Errorf("unknown client = %d %s, want the provider's invalid_client", w.Code, w.Body)
+	}
+}
+
+func TestServeToken(t *testing.T) {
+	h := newTestHandler(t)
+
+	w := httptest.NewRecorder()
+	h.ServeToken(w, postForm(url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:device_code"}, "device_code": {"dc"}}))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "authorization_pending") {
+		t.Errorf("token response = %d %s, want authorization_pending", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want the provider's no-store", got)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeToken(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("GET status = %d, want 400", w.Code)
+	}
+}
+
+func TestHandleSubmit(t *testing.T) {
+	h := newTestHandler(t)
+
+	w := httptest.NewRecorder()
+	h.HandleSubmit(w, postForm(url.Values{"code": {" WDJB-MJHT "}}))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status = %d, want 303", w.Code)
+	}
+	if got := w.Header().Get("Location"); got != "https://idp.example.com/device?user_code=WDJB-MJHT" {
+		t.Errorf("Location = %q, want the provider page with the user code", got)
+	}
+
+	// An empty code returns to the proxy's own page under BASE_URL
+	w = httptest.NewRecorder()
+	h.HandleSubmit(w, postForm(url.Values{"code": {" "}}))
+	if got := w.Header().Get("Location"); got != "https://proxy.example.com/device" {
+		t.Errorf("empty code Location = %q, want the proxy's verification page", got)
+	}
+}
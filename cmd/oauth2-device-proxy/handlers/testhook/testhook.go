@@ -0,0 +1,127 @@
+// Package testhook lets automated tests approve device codes without a
+// browser. It bypasses user authentication entirely and must never be
+// enabled in production.
+package testhook
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// ApprovePath is where the approval hook is served
+const ApprovePath = "/internal/test/approve"
+
+// tokenLifetime is the expires_in of synthetic tokens
+const tokenLifetime = 3600
+
+// Config configures a test hook Handler
+type Config struct {
+	Flow  deviceflow.Flow
+	Token string       // Bearer token required when set
+	Audit audit.Logger // Approvals are recorded like user approvals
+}
+
+// Handler approves device codes with synthetic tokens
+type Handler struct {
+	flow  deviceflow.Flow
+	token string
+	audit audit.Logger
+}
+
+// New creates a test hook handler
+func New(cfg Config) *Handler {
+	h := &Handler{flow: cfg.Flow, token: cfg.Token, audit: cfg.Audit}
+	if h.audit == nil {
+		h.audit = audit.NopLogger{}
+	}
+	return h
+}
+
+// ServeApprove marks the device code of the posted user_code authorized with
+// a synthetic access token, as if the user had signed in and approved it
+func (h *Handler) ServeApprove(w http.ResponseWriter, r *http.Request) {
+	if h.token != "" {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(h.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="test"`)
+			common.WriteError(w, deviceflow.ErrorCodeInvalidToken, "A valid test hook bearer token is required")
+			return
+		}
+	}
+	if err := r.ParseForm(); err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Invalid request format")
+		return
+	}
+	userCode := r.PostForm.Get("user_code")
+	if userCode == "" {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Missing user_code parameter")
+		return
+	}
+
+	ctx := r.Context()
+	code, err := h.flow.VerifyUserCode(ctx, userCode)
+	if err != nil {
+		writeFlowError(w, err)
+		return
+	}
+	token, err := syntheticToken(code.Scope)
+	if err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeServerError, "Failed to generate token")
+		return
+	}
+	if err := h.flow.CompleteAuthorization(ctx, code.DeviceCode, token); err != nil {
+		writeFlowError(w, err)
+		return
+	}
+
+	record := audit.Record{
+		Action:         audit.ActionTestApproved,
+		ClientID:       code.ClientID,
+		UserCode:       code.UserCode,
+		DeviceCodeHash: audit.HashDeviceCode(code.DeviceCode),
+		Scope:          code.Scope,
+		RemoteIP:       common.ClientIP(r),
+		UserAgent:      r.UserAgent(),
+		RequestedAt:    audit.OptionalTime(code.RequestedAt),
+		VerifiedAt:     audit.OptionalTime(code.VerifiedAt),
+	}
+	if authorized, err := h.flow.GetDeviceCode(ctx, code.DeviceCode); err == nil && authorized != nil {
+		record.AuthorizedAt = audit.OptionalTime(authorized.AuthorizedAt)
+	}
+	if err := h.audit.Record(ctx, record); err != nil {
+		log.Printf("Error: failed to record %s audit entry: %v", audit.ActionTestApproved, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// syntheticToken creates a random access token that no resource server accepts
+func syntheticToken(scope string) (*deviceflow.TokenResponse, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &deviceflow.TokenResponse{
+		AccessToken: "test-" + hex.EncodeToString(b),
+		TokenType:   "Bearer",
+		ExpiresIn:   tokenLifetime,
+		Scope:       scope,
+	}, nil
+}
+
+// writeFlowError reports a device flow error, or a server error for others
+func writeFlowError(w http.ResponseWriter, err error) {
+	if dfe, ok := deviceflow.AsDeviceFlowError(err); ok {
+		common.WriteError(w, dfe.Code, dfe.Description)
+		return
+	}
+	log.Printf("Error: test hook approval failed: %v", err)
+	common.WriteError(w, deviceflow.ErrorCodeServerError, "Failed to approve the device code")
+}
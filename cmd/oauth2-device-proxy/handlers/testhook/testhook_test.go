@@ -0,0 +1,110 @@
+package testhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+type mockAudit struct {
+	audit.NopLogger
+	records []audit.Record
+}
+
+func (m *mockAudit) Record(ctx context.Context, record audit.Record) error {
+	m.records = append(m.records, record)
+	return nil
+}
+
+func TestServeApprove(t *testing.T) {
+	var completed *deviceflow.TokenResponse
+	flow := &test.MockFlow{
+		VerifyUserCodeFunc: func(ctx context.Context, userCode string) (*deviceflow.DeviceCode, error) {
+			if userCode != "ABCD-EFGH" {
+				return nil, deviceflow.ErrInvalidUserCode
+			}
+			return &deviceflow.DeviceCode{DeviceCode: "dev-123", UserCode: userCode, ClientID: "tv", Scope: "read"}, nil
+		},
+		CompleteAuthFunc: func(ctx context.Context, deviceCode string, token *deviceflow.TokenResponse) error {
+			if deviceCode != "dev-123" {
+				t.Errorf("completed device code %q, want dev-123", deviceCode)
+			}
+			completed = token
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name       string
+		auth       string
+		userCode   string
+		wantStatus int
+	}{
+		{name: "missing token", userCode: "ABCD-EFGH", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", auth: "Bearer wrong", userCode: "ABCD-EFGH", wantStatus: http.StatusUnauthorized},
+		{name: "missing user code", auth: "Bearer secret", wantStatus: http.StatusBadRequest},
+		{name: "unknown user code", auth: "Bearer secret", userCode: "WXYZ-WXYZ", wantStatus: http.StatusBadRequest},
+		{name: "approved", auth: "Bearer secret", userCode: "ABCD-EFGH", wantStatus: http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &mockAudit{}
+			completed = nil
+			h := New(Config{Flow: flow, Token: "secret", Audit: logger})
+
+			req := httptest.NewRequest(http.MethodPost, ApprovePath,
+				strings.NewReader(url.Values{"user_code": {tt.userCode}}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			h.ServeApprove(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusNoContent {
+				if completed != nil {
+					t.Error("code authorized on a failed request")
+				}
+				return
+			}
+			if completed == nil || !strings.HasPrefix(completed.AccessToken, "test-") || completed.Scope != "read" {
+				t.Errorf("token = %+v, want a synthetic token with the code's scope", completed)
+			}
+			if len(logger.records) != 1 || logger.records[0].Action != audit.ActionTestApproved {
+				t.Errorf("audit records = %+v, want one test approval", logger.records)
+			}
+		})
+	}
+}
+
+func TestServeApproveWithoutToken(t *testing.T) {
+	flow := &test.MockFlow{
+		VerifyUserCodeFunc: func(ctx context.Context, userCode string) (*deviceflow.DeviceCode, error) {
+			return &deviceflow.DeviceCode{DeviceCode: "dev-123", UserCode: userCode}, nil
+		},
+		CompleteAuthFunc: func(ctx context.Context, deviceCode string, token *deviceflow.TokenResponse) error {
+			return deviceflow.ErrAlreadyAuthorized
+		},
+	}
+	h := New(Config{Flow: flow})
+
+	req := httptest.NewRequest(http.MethodPost, ApprovePath, strings.NewReader("user_code=ABCD-EFGH"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ServeApprove(w, req)
+
+	// No token is required when none is configured, and flow errors are relayed
+	if w.Code == http.StatusUnauthorized || w.Code == http.StatusNoContent {
+		t.Errorf("status = %d, want the already authorized error: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,58 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+)
+
+// Renewer returns a valid access token for the grant whose current access
+// token is presented, renewing it with the refresh token the proxy keeps
+type Renewer interface {
+	Current(ctx context.Context, accessToken string) (*deviceflow.TokenResponse, error)
+}
+
+// ServeCurrent lets devices that never receive a refresh token obtain a valid
+// access token. The device presents its current access token as a bearer
+// token per RFC 6750 section 2.1 and receives it back, or its replacement
+// when it was about to expire. A replaced access token cannot be presented
+// again, so devices must keep the token from the latest response.
+func (h *Handler) ServeCurrent(w http.ResponseWriter, r *http.Request) {
+	common.SetJSONHeaders(w)
+
+	accessToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || accessToken == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="token"`)
+		common.WriteErrorStatus(w, http.StatusUnauthorized, deviceflow.ErrorCodeInvalidRequest,
+			"The current access token is REQUIRED as a bearer token")
+		return
+	}
+
+	token, err := h.renewer.Current(r.Context(), accessToken)
+	switch {
+	case errors.Is(err, renewal.ErrUnknownGrant), errors.Is(err, renewal.ErrGrantRevoked):
+		// The device must go through the device flow again
+		w.Header().Set("WWW-Authenticate", `Bearer realm="token", error="invalid_token"`)
+		common.WriteError(w, deviceflow.ErrorCodeInvalidToken,
+			"The access token is not current or its grant has ended")
+		return
+	case err != nil:
+		// The grant is kept, so the device may retry with the same token
+		log.Printf("Error: renewing access token: %v", err)
+		common.WriteError(w, deviceflow.ErrorCodeTemporarilyUnavailable,
+			"The access token could not be renewed, retry later")
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(token); err != nil {
+		common.WriteJSONError(w, err)
+		return
+	}
+}
@@ -0,0 +1,74 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+)
+
+// renewerFunc adapts a function to the Renewer interface
+type renewerFunc func(ctx context.Context, accessToken string) (*deviceflow.TokenResponse, error)
+
+func (fn renewerFunc) Current(ctx context.Context, accessToken string) (*deviceflow.TokenResponse, error) {
+	return fn(ctx, accessToken)
+}
+
+func TestServeCurrent(t *testing.T) {
+	renewer := renewerFunc(func(ctx context.Context, accessToken string) (*deviceflow.TokenResponse, error) {
+		switch accessToken {
+		case "current":
+			return &deviceflow.TokenResponse{AccessToken: "renewed", TokenType: "Bearer", ExpiresIn: 300}, nil
+		case "revoked":
+			return nil, renewal.ErrGrantRevoked
+		case "flaky":
+			return nil, errors.New("upstream unavailable")
+		default:
+			return nil, renewal.ErrUnknownGrant
+		}
+	})
+
+	tests := []struct {
+		name        string
+		header      string
+		wantStatus  int
+		wantContain string
+	}{
+		{name: "missing token", wantStatus: http.StatusUnauthorized, wantContain: `"error":"invalid_request"`},
+		{name: "basic credentials", header: "Basic Y2xpZW50OnNlY3JldA==", wantStatus: http.StatusUnauthorized, wantContain: `"error":"invalid_request"`},
+		{name: "current token", header: "Bearer current", wantStatus: http.StatusOK, wantContain: `"access_token":"renewed"`},
+		{name: "unknown token", header: "Bearer stale", wantStatus: http.StatusUnauthorized, wantContain: `"error":"invalid_token"`},
+		{name: "revoked grant", header: "Bearer revoked", wantStatus: http.StatusUnauthorized, wantContain: `"error":"invalid_token"`},
+		{name: "refresh failure", header: "Bearer flaky", wantStatus: http.StatusServiceUnavailable, wantContain: `"error":"temporarily_unavailable"`},
+	}
+
+	h := New(Config{Flow: &mockFlow{}, Renewer: renewer})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/token/current", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeCurrent(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if body := w.Body.String(); !strings.Contains(body, tt.wantContain) {
+				t.Errorf("body = %s, want %s", body, tt.wantContain)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("missing WWW-Authenticate header")
+			}
+			if got := w.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", got)
+			}
+		})
+	}
+}
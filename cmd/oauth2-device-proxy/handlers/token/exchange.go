@@ -0,0 +1,189 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+)
+
+// ExchangePath is where devices exchange their access token for one targeted
+// at another audience
+const ExchangePath = "/token/exchange"
+
+// ExchangeFunc exchanges a token at the authorization server per RFC 8693,
+// authenticating as the proxy
+type ExchangeFunc func(ctx context.Context, req oauth.ExchangeRequest) (*oauth.Token, error)
+
+// SubjectClientFunc returns the client an access token was delivered to by
+// the proxy, or an empty string if the proxy did not deliver it
+type SubjectClientFunc func(ctx context.Context, accessToken string) (string, error)
+
+// exchangeResponse is a successful token exchange response per RFC 8693
+// section 2.2.1
+type exchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int    `json:"expires_in,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+}
+
+// ServeExchange lets devices exchange their access token for one targeted at
+// another audience per RFC 8693, such as a second API the device calls. The
+// proxy forwards the exchange under its own client credentials, so callers
+// must identify a known client and may only exchange tokens the proxy
+// delivered to that client. Only the configured audiences may be requested
+// and only access tokens are issued; refresh tokens stay with the proxy.
+func (h *Handler) ServeExchange(w http.ResponseWriter, r *http.Request) {
+	common.SetJSONHeaders(w)
+
+	if r.Method != http.MethodPost {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "POST method required")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Invalid request format")
+		return
+	}
+	for key, values := range r.Form {
+		if len(values) > 1 {
+			common.WriteError(w, deviceflow.ErrorCodeInvalidRequest,
+				"Parameters MUST NOT be included more than once: "+key)
+			return
+		}
+	}
+
+	// Validate the exchange per RFC 8693 section 2.1
+	switch r.Form.Get("grant_type") {
+	case "":
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "The grant_type parameter is REQUIRED")
+		return
+	case oauth.GrantTypeTokenExchange:
+	default:
+		common.WriteError(w, deviceflow.ErrorCodeUnsupportedGrant,
+			"Only "+oauth.GrantTypeTokenExchange+" is supported")
+		return
+	}
+	subjectToken := r.Form.Get("subject_token")
+	if subjectToken == "" {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "The subject_token parameter is REQUIRED")
+		return
+	}
+	if r.Form.Get("subject_token_type") != oauth.TokenTypeAccessToken {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest,
+			"The subject_token_type parameter MUST be "+oauth.TokenTypeAccessToken)
+		return
+	}
+	if requested := r.Form.Get("requested_token_type"); requested != "" && requested != oauth.TokenTypeAccessToken {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest,
+			"Only "+oauth.TokenTypeAccessToken+" may be requested")
+		return
+	}
+	audience := r.Form.Get("audience")
+	if !h.exchangeAudiences[audience] {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidTarget,
+			"The audience parameter MUST name an audience tokens may be exchanged for")
+		return
+	}
+
+	// Public clients identify themselves with client_id, confidential
+	// clients authenticate with an assertion
+	clientID, _, err := h.clientAuth.Authenticate(r)
+	if errors.Is(err, deviceflow.ErrMissingClientID) {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest,
+			"The client_id parameter is REQUIRED for public clients")
+		return
+	}
+	if err != nil {
+		resp := errorFor(err)
+		common.WriteError(w, resp.Error, resp.ErrorDescription)
+		return
+	}
+
+	// The proxy's credentials would exchange any token the authorization
+	// server issued to it, so only the client the token was delivered to may
+	// exchange it
+	if !h.deliveredTo(w, r, subjectToken, clientID) {
+		return
+	}
+
+	token, err := h.exchange(r.Context(), oauth.ExchangeRequest{
+		SubjectToken:       subjectToken,
+		SubjectTokenType:   oauth.TokenTypeAccessToken,
+		Audience:           audience,
+		Scope:              r.Form.Get("scope"),
+		RequestedTokenType: oauth.TokenTypeAccessToken,
+	})
+	if err != nil {
+		writeExchangeError(w, err)
+		return
+	}
+
+	issuedType := token.IssuedTokenType
+	if issuedType == "" {
+		issuedType = oauth.TokenTypeAccessToken
+	}
+	resp := exchangeResponse{
+		AccessToken:     token.AccessToken,
+		IssuedTokenType: issuedType,
+		TokenType:       token.TokenType,
+		Scope:           token.Scope,
+	}
+	if !token.ExpiresAt.IsZero() {
+		resp.ExpiresIn = int(time.Until(token.ExpiresAt).Round(time.Second).Seconds())
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		common.WriteJSONError(w, err)
+		return
+	}
+}
+
+// deliveredTo reports whether the proxy delivered accessToken to clientID,
+// writing an error response when it did not
+func (h *Handler) deliveredTo(w http.ResponseWriter, r *http.Request, accessToken, clientID string) bool {
+	if h.subjectClient == nil {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidGrant, "The subject_token was not issued to the client")
+		return false
+	}
+	issuedTo, err := h.subjectClient(r.Context(), accessToken)
+	if err != nil {
+		log.Printf("Error: looking up the client of a subject token: %v", err)
+		common.WriteError(w, deviceflow.ErrorCodeTemporarilyUnavailable,
+			"The token could not be exchanged, retry later")
+		return false
+	}
+	if issuedTo == "" || issuedTo != clientID {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidGrant, "The subject_token was not issued to the client")
+		return false
+	}
+	return true
+}
+
+// writeExchangeError relays why the authorization server refused an exchange.
+// Refusals of the proxy's own client, such as the audience not allowing
+// exchanges, tell the device the audience is unavailable.
+func writeExchangeError(w http.ResponseWriter, err error) {
+	var providerErr *oauth.ProviderError
+	switch {
+	case errors.Is(err, oauth.ErrInvalidGrant):
+		common.WriteError(w, deviceflow.ErrorCodeInvalidGrant, "The subject_token is invalid or expired")
+	case errors.Is(err, oauth.ErrProviderUnavailable):
+		common.WriteError(w, deviceflow.ErrorCodeTemporarilyUnavailable,
+			"The token could not be exchanged, retry later")
+	case errors.As(err, &providerErr) && providerErr.Code == deviceflow.ErrorCodeInvalidScope:
+		common.WriteError(w, deviceflow.ErrorCodeInvalidScope, providerErr.Description)
+	case errors.As(err, &providerErr) && providerErr.Code != deviceflow.ErrorCodeInvalidClient:
+		log.Printf("Warning: token exchange refused: %v", err)
+		common.WriteError(w, deviceflow.ErrorCodeInvalidTarget, "Tokens cannot be exchanged for the audience")
+	default:
+		log.Printf("Error: exchanging token: %v", err)
+		common.WriteError(w, deviceflow.ErrorCodeServerError, "The token could not be exchanged")
+	}
+}
@@ -0,0 +1,123 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+)
+
+func TestServeExchange(t *testing.T) {
+	exchange := func(ctx context.Context, req oauth.ExchangeRequest) (*oauth.Token, error) {
+		switch req.SubjectToken {
+		case "current":
+			if req.Audience != "api" || req.RequestedTokenType != oauth.TokenTypeAccessToken {
+				return nil, fmt.Errorf("unexpected exchange %+v", req)
+			}
+			return &oauth.Token{AccessToken: "exchanged", TokenType: "Bearer", ExpiresAt: time.Now().Add(5 * time.Minute)}, nil
+		case "expired":
+			return nil, oauth.ErrInvalidGrant
+		case "forbidden":
+			return nil, fmt.Errorf("token exchange request failed: %w", &oauth.ProviderError{Code: "access_denied"})
+		default:
+			return nil, fmt.Errorf("token exchange request failed: %w", oauth.ErrProviderUnavailable)
+		}
+	}
+	// Every subject token was delivered to tv, except the other client's
+	subjectClient := func(ctx context.Context, accessToken string) (string, error) {
+		switch accessToken {
+		case "unknown":
+			return "", nil
+		case "other":
+			return "kiosk", nil
+		case "lookup-fails":
+			return "", fmt.Errorf("redis down")
+		}
+		return "tv", nil
+	}
+	form := func(subjectToken, audience string) url.Values {
+		return url.Values{
+			"grant_type":         {oauth.GrantTypeTokenExchange},
+			"subject_token":      {subjectToken},
+			"subject_token_type": {oauth.TokenTypeAccessToken},
+			"audience":           {audience},
+			"client_id":          {"tv"},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		form        url.Values
+		wantStatus  int
+		wantContain string
+	}{
+		{name: "exchanged", form: form("current", "api"), wantStatus: http.StatusOK,
+			wantContain: `"access_token":"exchanged","issued_token_type":"urn:ietf:params:oauth:token-type:access_token"`},
+		{name: "wrong grant", form: url.Values{"grant_type": {"refresh_token"}}, wantStatus: http.StatusBadRequest, wantContain: `"error":"unsupported_grant_type"`},
+		{name: "missing subject token", form: form("", "api"), wantStatus: http.StatusBadRequest, wantContain: `"error":"invalid_request"`},
+		{name: "unlisted audience", form: form("current", "billing"), wantStatus: http.StatusBadRequest, wantContain: `"error":"invalid_target"`},
+		{name: "expired token", form: form("expired", "api"), wantStatus: http.StatusBadRequest, wantContain: `"error":"invalid_grant"`},
+		{name: "exchange refused", form: form("forbidden", "api"), wantStatus: http.StatusBadRequest, wantContain: `"error":"invalid_target"`},
+		{name: "provider down", form: form("flaky", "api"), wantStatus: http.StatusServiceUnavailable, wantContain: `"error":"temporarily_unavailable"`},
+		{name: "missing client", form: without(form("current", "api"), "client_id"), wantStatus: http.StatusBadRequest, wantContain: `"error":"invalid_request"`},
+		{name: "token of another client", form: form("other", "api"), wantStatus: http.StatusBadRequest, wantContain: `"error":"invalid_grant"`},
+		{name: "token not issued by the proxy", form: form("unknown", "api"), wantStatus: http.StatusBadRequest, wantContain: `"error":"invalid_grant"`},
+		{name: "lookup failure", form: form("lookup-fails", "api"), wantStatus: http.StatusServiceUnavailable, wantContain: `"error":"temporarily_unavailable"`},
+	}
+
+	h := New(Config{Flow: &mockFlow{}, Exchange: exchange, ExchangeAudiences: []string{"api"}, SubjectClient: subjectClient})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, ExchangePath, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			h.ServeExchange(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantContain) {
+				t.Errorf("body = %s, want it to contain %s", w.Body.String(), tt.wantContain)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", got)
+			}
+		})
+	}
+}
+
+func TestServeExchangeWithoutSubjectClient(t *testing.T) {
+	exchange := func(ctx context.Context, req oauth.ExchangeRequest) (*oauth.Token, error) {
+		t.Error("token exchanged without knowing its client")
+		return &oauth.Token{AccessToken: "exchanged"}, nil
+	}
+	h := New(Config{Flow: &mockFlow{}, Exchange: exchange, ExchangeAudiences: []string{"api"}})
+
+	form := url.Values{
+		"grant_type":         {oauth.GrantTypeTokenExchange},
+		"subject_token":      {"current"},
+		"subject_token_type": {oauth.TokenTypeAccessToken},
+		"audience":           {"api"},
+		"client_id":          {"tv"},
+	}
+	req := httptest.NewRequest(http.MethodPost, ExchangePath, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	h.ServeExchange(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), `"error":"invalid_grant"`) {
+		t.Errorf("response = %d %s, want invalid_grant", w.Code, w.Body.String())
+	}
+}
+
+// without returns form less the named parameter
+func without(form url.Values, key string) url.Values {
+	form.Del(key)
+	return form
+}
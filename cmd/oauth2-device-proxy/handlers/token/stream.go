@@ -0,0 +1,107 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/features"
+)
+
+const (
+	// DefaultStreamTimeout bounds how long a token request is held open. It
+	// stays under the server's request timeout so devices always receive a
+	// response they can act on instead of a dropped connection.
+	DefaultStreamTimeout = 25 * time.Second
+
+	// streamHeartbeat is how often an idle event stream sends a comment so
+	// intermediaries do not close it
+	streamHeartbeat = 10 * time.Second
+
+	// eventStreamType is the media type of Server-Sent Events
+	eventStreamType = "text/event-stream"
+)
+
+// ServeStream handles token requests from devices able to hold a connection
+// open. It accepts the same parameters as the token endpoint but, while
+// authorization is pending, waits for the user to finish before answering.
+// Devices sending "Accept: text/event-stream" receive the outcome as a
+// Server-Sent Event, others a long-poll response identical to ServeHTTP's.
+// Either way an authorization_pending result means the device should retry.
+// Clients without the SSETokenDelivery feature always receive long polls.
+func (h *Handler) ServeStream(w http.ResponseWriter, r *http.Request) {
+	deviceCode, clientID, ok := h.parseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(requestContext(r, clientID), h.streamTimeout)
+	defer cancel()
+
+	// Outlast the server's write timeout, which is sized for ordinary requests
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Now().Add(h.streamTimeout + 5*time.Second))
+
+	if !strings.Contains(r.Header.Get("Accept"), eventStreamType) || !h.sseEnabled(clientID) {
+		token, err := h.flow.WaitForToken(ctx, deviceCode)
+		h.writeResult(w, token, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", eventStreamType)
+	w.Header().Set("X-Accel-Buffering", "no") // Keep reverse proxies from buffering events
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	type result struct {
+		token *deviceflow.TokenResponse
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		token, err := h.flow.WaitForToken(ctx, deviceCode)
+		done <- result{token, err}
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case res := <-done:
+			if res.err != nil {
+				writeEvent(w, "error", errorFor(res.err))
+			} else {
+				writeEvent(w, "token", h.filterToken(res.token))
+			}
+			_ = rc.Flush()
+			return
+
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return // The device went away, which also cancels the wait
+			}
+			_ = rc.Flush()
+		}
+	}
+}
+
+// writeEvent writes a Server-Sent Event with a JSON payload
+func writeEvent(w http.ResponseWriter, event string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		event, data = "error", []byte(`{"error":"server_error"}`)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+}
+
+// sseEnabled reports whether a client may receive Server-Sent Events
+func (h *Handler) sseEnabled(clientID string) bool {
+	return h.features == nil || h.features.EnabledFor(features.SSETokenDelivery, clientID)
+}
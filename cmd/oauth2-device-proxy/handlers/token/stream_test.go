@@ -0,0 +1,157 @@
+package token
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/features"
+)
+
+// streamRequest builds a token stream request for device-123
+func streamRequest(accept string) *http.Request {
+	values := url.Values{}
+	values.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+	values.Set("device_code", "device-123")
+	values.Set("client_id", "test")
+	req := httptest.NewRequest(http.MethodPost, "/device/token/stream", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	return req
+}
+
+func TestServeStream(t *testing.T) {
+	authorized := func(ctx context.Context, deviceCode string) (*deviceflow.TokenResponse, error) {
+		return &deviceflow.TokenResponse{AccessToken: "access", TokenType: "Bearer", IDToken: "id-token"}, nil
+	}
+	pending := func(ctx context.Context, deviceCode string) (*deviceflow.TokenResponse, error) {
+		<-ctx.Done()
+		return nil, deviceflow.ErrPendingAuthorization
+	}
+
+	tests := []struct {
+		name        string
+		accept      string
+		wait        func(ctx context.Context, deviceCode string) (*deviceflow.TokenResponse, error)
+		features    *features.Set
+		wantStatus  int
+		wantType    string
+		wantContain []string
+		wantAbsent  []string
+	}{
+		{
+			name:        "long poll authorized",
+			wait:        authorized,
+			wantStatus:  http.StatusOK,
+			wantType:    "application/json",
+			wantContain: []string{`"access_token":"access"`},
+			wantAbsent:  []string{"id-token"},
+		},
+		{
+			name:        "long poll times out pending",
+			wait:        pending,
+			wantStatus:  http.StatusBadRequest,
+			wantType:    "application/json",
+			wantContain: []string{`"error":"authorization_pending"`},
+		},
+		{
+			name: "long poll denied",
+			wait: func(ctx context.Context, deviceCode string) (*deviceflow.TokenResponse, error) {
+				return nil, deviceflow.ErrAccessDenied
+			},
+			wantStatus:  http.StatusBadRequest,
+			wantType:    "application/json",
+			wantContain: []string{`"error":"access_denied"`},
+		},
+		{
+			name:        "event stream authorized",
+			accept:      "text/event-stream",
+			wait:        authorized,
+			wantStatus:  http.StatusOK,
+			wantType:    "text/event-stream",
+			wantContain: []string{"event: token\ndata: {", `"access_token":"access"`},
+			wantAbsent:  []string{"id-token"},
+		},
+		{
+			name:        "event stream times out pending",
+			accept:      "text/event-stream",
+			wait:        pending,
+			wantStatus:  http.StatusOK,
+			wantType:    "text/event-stream",
+			wantContain: []string{"event: error\ndata: {", `"error":"authorization_pending"`},
+		},
+		{
+			name:        "event stream disabled by feature flag",
+			accept:      "text/event-stream",
+			wait:        authorized,
+			features:    features.New(),
+			wantStatus:  http.StatusOK,
+			wantType:    "application/json",
+			wantContain: []string{`"access_token":"access"`},
+			wantAbsent:  []string{"event: token"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow := &mockFlow{}
+			flow.WaitForTokenFunc = tt.wait
+			handler := New(Config{Flow: flow, StreamTimeout: 20 * time.Millisecond, Features: tt.features})
+
+			w := httptest.NewRecorder()
+			handler.ServeStream(w, streamRequest(tt.accept))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.wantType)
+			}
+			if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", cc)
+			}
+			body := w.Body.String()
+			for _, want := range tt.wantContain {
+				if !strings.Contains(body, want) {
+					t.Errorf("body missing %q: %s", want, body)
+				}
+			}
+			for _, absent := range tt.wantAbsent {
+				if strings.Contains(body, absent) {
+					t.Errorf("body contains %q: %s", absent, body)
+				}
+			}
+		})
+	}
+}
+
+func TestServeStreamInvalidRequest(t *testing.T) {
+	flow := &mockFlow{}
+	flow.WaitForTokenFunc = func(ctx context.Context, deviceCode string) (*deviceflow.TokenResponse, error) {
+		t.Error("invalid request reached the flow")
+		return nil, nil
+	}
+	handler := New(Config{Flow: flow})
+
+	req := httptest.NewRequest(http.MethodPost, "/device/token/stream", strings.NewReader("grant_type=password"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "text/event-stream")
+	w := httptest.NewRecorder()
+	handler.ServeStream(w, req)
+
+	var resp map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if w.Code != http.StatusBadRequest || resp["error"] != deviceflow.ErrorCodeUnsupportedGrant {
+		t.Errorf("response = %d %v, want unsupported_grant_type", w.Code, resp)
+	}
+}
@@ -1,51 +1,116 @@
 package token
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/features"
 )
 
 // Handler processes device access token requests per RFC 8628 section 3.4
 type Handler struct {
-	flow deviceflow.Flow // Changed from *deviceflow.Flow to deviceflow.Flow
+	flow           deviceflow.Flow // Changed from *deviceflow.Flow to deviceflow.Flow
+	includeIDToken bool
+	streamTimeout  time.Duration
+	clientAuth     *common.ClientAuthenticator
+	renewer        Renewer
+	features       *features.Set
+
+	exchange          ExchangeFunc
+	exchangeAudiences map[string]bool
+	subjectClient     SubjectClientFunc
 }
 
 // Config contains handler configuration options
 type Config struct {
-	Flow deviceflow.Flow // Added Config struct for consistency
+	Flow           deviceflow.Flow // Added Config struct for consistency
+	IncludeIDToken bool            // Deliver the ID token to OIDC-capable devices
+	StreamTimeout  time.Duration   // How long ServeStream holds requests open, DefaultStreamTimeout if zero
+
+	// ClientAuth authenticates confidential clients, nil accepts public clients only
+	ClientAuth *common.ClientAuthenticator
+
+	// Renewer serves ServeCurrent when the proxy keeps refresh tokens, optional
+	Renewer Renewer
+
+	// Features gates Server-Sent Event delivery per client, nil delivers
+	// events to every device asking for them
+	Features *features.Set
+
+	// Exchange serves ServeExchange for the ExchangeAudiences devices may
+	// exchange their access tokens for, optional. SubjectClient binds each
+	// access token to the client it was delivered to; nil refuses every
+	// exchange.
+	Exchange          ExchangeFunc
+	ExchangeAudiences []string
+	SubjectClient     SubjectClientFunc
 }
 
 // New creates a new token request handler
 func New(cfg Config) *Handler {
-	return &Handler{
-		flow: cfg.Flow,
+	h := &Handler{
+		flow:           cfg.Flow,
+		includeIDToken: cfg.IncludeIDToken,
+		streamTimeout:  cfg.StreamTimeout,
+		clientAuth:     cfg.ClientAuth,
+		renewer:        cfg.Renewer,
+		features:       cfg.Features,
+		exchange:       cfg.Exchange,
+		subjectClient:  cfg.SubjectClient,
 	}
+	if len(cfg.ExchangeAudiences) > 0 {
+		h.exchangeAudiences = make(map[string]bool, len(cfg.ExchangeAudiences))
+		for _, audience := range cfg.ExchangeAudiences {
+			h.exchangeAudiences[audience] = true
+		}
+	}
+	if h.streamTimeout <= 0 {
+		h.streamTimeout = DefaultStreamTimeout
+	}
+	return h
 }
 
 // ServeHTTP handles token polling requests
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	deviceCode, clientID, ok := h.parseRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Check device code status
+	token, err := h.flow.CheckDeviceCode(requestContext(r, clientID), deviceCode)
+	h.writeResult(w, token, err)
+}
+
+// parseRequest validates a token request per RFC 8628 section 3.4 and returns
+// its device code and the authenticated client, if any, writing an error
+// response when the request is invalid
+func (h *Handler) parseRequest(w http.ResponseWriter, r *http.Request) (string, string, bool) {
 	common.SetJSONHeaders(w)
 
 	if r.Method != http.MethodPost {
 		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "POST method required")
-		return
+		return "", "", false
 	}
 
 	if err := r.ParseForm(); err != nil {
 		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Invalid request format")
-		return
+		return "", "", false
 	}
 
-	// Check for duplicate parameters per RFC 8628 section 3.4
+	// Check for duplicate parameters per RFC 8628 section 3.4. Resource
+	// indicators may repeat per RFC 8707 section 2.
 	for key, values := range r.Form {
-		if len(values) > 1 {
+		if len(values) > 1 && key != "resource" {
 			common.WriteError(w, deviceflow.ErrorCodeInvalidRequest,
 				"Parameters MUST NOT be included more than once: "+key)
-			return
+			return "", "", false
 		}
 	}
 
@@ -54,62 +119,116 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if grantType == "" {
 		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest,
 			"The grant_type parameter is REQUIRED")
-		return
+		return "", "", false
 	}
 
 	if grantType != "urn:ietf:params:oauth:grant-type:device_code" {
 		common.WriteError(w, deviceflow.ErrorCodeUnsupportedGrant,
 			"Only urn:ietf:params:oauth:grant-type:device_code is supported")
-		return
+		return "", "", false
 	}
 
 	deviceCode := r.Form.Get("device_code")
 	if deviceCode == "" {
 		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest,
 			"The device_code parameter is REQUIRED")
-		return
+		return "", "", false
 	}
 
-	clientID := r.Form.Get("client_id")
-	if clientID == "" {
+	// Public clients identify themselves with client_id, confidential
+	// clients authenticate with an assertion
+	clientID, authenticated, err := h.clientAuth.Authenticate(r)
+	if errors.Is(err, deviceflow.ErrMissingClientID) {
 		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest,
 			"The client_id parameter is REQUIRED for public clients")
-		return
+		return "", "", false
 	}
-
-	// Check device code status
-	token, err := h.flow.CheckDeviceCode(r.Context(), deviceCode)
 	if err != nil {
-		var dferr *deviceflow.DeviceFlowError
-		if errors.As(err, &dferr) {
-			common.WriteError(w, dferr.Code, dferr.Description)
-			return
-		}
+		resp := errorFor(err)
+		common.WriteError(w, resp.Error, resp.ErrorDescription)
+		return "", "", false
+	}
+	if !authenticated {
+		clientID = ""
+	}
+
+	return deviceCode, clientID, true
+}
 
-		// Map standard errors to OAuth error responses per RFC 8628 section 3.5
-		switch {
-		case errors.Is(err, deviceflow.ErrInvalidDeviceCode):
-			common.WriteError(w, deviceflow.ErrorCodeInvalidGrant,
-				"The device_code is invalid or expired")
-		case errors.Is(err, deviceflow.ErrExpiredCode):
-			common.WriteError(w, deviceflow.ErrorCodeExpiredToken,
-				"The device_code has expired")
-		case errors.Is(err, deviceflow.ErrPendingAuthorization):
-			common.WriteError(w, deviceflow.ErrorCodeAuthorizationPending,
-				"The authorization request is still pending")
-		case errors.Is(err, deviceflow.ErrSlowDown):
-			common.WriteError(w, deviceflow.ErrorCodeSlowDown,
-				"Polling interval must be increased by 5 seconds")
-		default:
-			common.WriteError(w, deviceflow.ErrorCodeServerError,
-				"An unexpected error occurred processing the request")
+// requestContext identifies the polling client to the flow, which flags device
+// codes polled from elsewhere than they were requested, and carries the
+// authenticated client and the resources the token is requested for
+func requestContext(r *http.Request, authenticatedClient string) context.Context {
+	ctx := deviceflow.WithPoller(r.Context(), deviceflow.Poller{
+		IP:        common.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	})
+	if authenticatedClient != "" {
+		ctx = deviceflow.WithAuthenticatedClient(ctx, authenticatedClient)
+	}
+	return deviceflow.WithRequestedResources(ctx, r.Form["resource"])
+}
+
+// writeResult sends the token, or the error returned while checking for it
+func (h *Handler) writeResult(w http.ResponseWriter, token *deviceflow.TokenResponse, err error) {
+	if err != nil {
+		// Tell the device how long to back off, including any slow_down escalation
+		if wait, ok := deviceflow.RetryAfter(err); ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())))
 		}
+		common.WriteErrorResponse(w, errorFor(err))
 		return
 	}
 
 	// Return successful token response
-	if err := json.NewEncoder(w).Encode(token); err != nil {
+	if err := json.NewEncoder(w).Encode(h.filterToken(token)); err != nil {
 		common.WriteJSONError(w, err)
 		return
 	}
 }
+
+// filterToken removes the parts of a token response devices are not configured to receive
+func (h *Handler) filterToken(token *deviceflow.TokenResponse) *deviceflow.TokenResponse {
+	// The ID token stays server-side unless devices are configured to receive it
+	if !h.includeIDToken {
+		token.IDToken = ""
+	}
+	return token
+}
+
+// errorFor maps an error from the device flow to its OAuth error response,
+// including the interval to poll at when the error carries one
+func errorFor(err error) common.ErrorResponse {
+	resp := errorResponseFor(err)
+	if wait, ok := deviceflow.RetryAfter(err); ok {
+		resp.Interval = int(wait.Seconds())
+	}
+	return resp
+}
+
+// errorResponseFor maps an error to its OAuth error code and description
+func errorResponseFor(err error) common.ErrorResponse {
+	var dferr *deviceflow.DeviceFlowError
+	if errors.As(err, &dferr) {
+		return common.ErrorResponse{Error: dferr.Code, ErrorDescription: dferr.Description, ErrorURI: dferr.URI}
+	}
+
+	// Map standard errors to OAuth error responses per RFC 8628 section 3.5
+	switch {
+	case errors.Is(err, deviceflow.ErrInvalidDeviceCode):
+		return common.ErrorResponse{Error: deviceflow.ErrorCodeInvalidGrant,
+			ErrorDescription: "The device_code is invalid or expired"}
+	case errors.Is(err, deviceflow.ErrExpiredCode):
+		return common.ErrorResponse{Error: deviceflow.ErrorCodeExpiredToken,
+			ErrorDescription: "The device_code has expired"}
+	case errors.Is(err, deviceflow.ErrPendingAuthorization):
+		return common.ErrorResponse{Error: deviceflow.ErrorCodeAuthorizationPending,
+			ErrorDescription: "The authorization request is still pending"}
+	case errors.Is(err, deviceflow.ErrSlowDown):
+		return common.ErrorResponse{Error: deviceflow.ErrorCodeSlowDown,
+			ErrorDescription: deviceflow.ErrorDescSlowDown}
+	default:
+		return common.ErrorResponse{Error: deviceflow.ErrorCodeServerError,
+			ErrorDescription: "An unexpected error occurred processing the request"}
+	}
+}
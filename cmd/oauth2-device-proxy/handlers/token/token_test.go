@@ -3,17 +3,22 @@ package token
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
 )
 
 // mockFlow implements the minimum required deviceflow.Flow interface for token testing
 type mockFlow struct {
+	test.MockFlow
 	checkDeviceCode       func(ctx context.Context, code string) (*deviceflow.TokenResponse, error)
 	requestDeviceCode     func(ctx context.Context, clientID, scope string) (*deviceflow.DeviceCode, error)
 	verifyUserCode        func(ctx context.Context, code string) (*deviceflow.DeviceCode, error)
@@ -28,7 +33,7 @@ func (m *mockFlow) CheckDeviceCode(ctx context.Context, code string) (*deviceflo
 	return nil, deviceflow.ErrPendingAuthorization // RFC 8628 section 3.5 default
 }
 
-func (m *mockFlow) RequestDeviceCode(ctx context.Context, clientID, scope string) (*deviceflow.DeviceCode, error) {
+func (m *mockFlow) RequestDeviceCode(ctx context.Context, clientID, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
 	if m.requestDeviceCode != nil {
 		return m.requestDeviceCode(ctx, clientID, scope)
 	}
@@ -62,16 +67,17 @@ func (m *mockFlow) CheckHealth(ctx context.Context) error {
 
 func TestTokenHandler(t *testing.T) {
 	tests := []struct {
-		name          string
-		method        string
-		params        map[string]string
-		duplicateKey  string
-		mockResponse  *deviceflow.TokenResponse
-		mockError     error
-		wantStatus    int
-		wantErrorCode string
-		wantErrorDesc string
-		validateBody  bool
+		name           string
+		method         string
+		params         map[string]string
+		duplicateKey   string
+		mockResponse   *deviceflow.TokenResponse
+		mockError      error
+		wantStatus     int
+		wantErrorCode  string
+		wantErrorDesc  string
+		wantRetryAfter string
+		validateBody   bool
 	}{
 		{
 			name:          "wrong method",
@@ -134,6 +140,21 @@ func TestTokenHandler(t *testing.T) {
 			wantErrorCode: "invalid_request",
 			wantErrorDesc: "Parameters MUST NOT be included more than once: client_id",
 		},
+		{
+			name:   "repeated resource indicator",
+			method: "POST",
+			params: map[string]string{
+				"grant_type":  "urn:ietf:params:oauth:grant-type:device_code",
+				"device_code": "pending-code",
+				"client_id":   "test",
+				"resource":    "https://api.example.com",
+			},
+			duplicateKey:  "resource",
+			mockError:     deviceflow.ErrPendingAuthorization,
+			wantStatus:    http.StatusBadRequest,
+			wantErrorCode: "authorization_pending",
+			wantErrorDesc: "The authorization request is still pending",
+		},
 		{
 			name:   "successful token request",
 			method: "POST",
@@ -176,7 +197,21 @@ func TestTokenHandler(t *testing.T) {
 			mockError:     deviceflow.ErrSlowDown,
 			wantStatus:    http.StatusBadRequest,
 			wantErrorCode: "slow_down",
-			wantErrorDesc: "Polling interval must be increased by 5 seconds",
+			wantErrorDesc: "Polling interval must be increased to the interval given",
+		},
+		{
+			name:   "slow down with escalated interval",
+			method: "POST",
+			params: map[string]string{
+				"grant_type":  "urn:ietf:params:oauth:grant-type:device_code",
+				"device_code": "rate-limited",
+				"client_id":   "test",
+			},
+			mockError:      &deviceflow.PollBackoffError{DeviceFlowError: deviceflow.ErrSlowDown, Interval: 15 * time.Second},
+			wantStatus:     http.StatusBadRequest,
+			wantErrorCode:  "slow_down",
+			wantErrorDesc:  "Polling interval must be increased to the interval given",
+			wantRetryAfter: "15",
 		},
 		{
 			name:   "expired code",
@@ -227,6 +262,9 @@ func TestTokenHandler(t *testing.T) {
 			}
 
 			// Check headers per RFC 8628
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
 			if w.Header().Get("Cache-Control") != "no-store" {
 				t.Error("missing Cache-Control: no-store header")
 			}
@@ -248,6 +286,12 @@ func TestTokenHandler(t *testing.T) {
 				if got := resp["error_description"].(string); got != tt.wantErrorDesc {
 					t.Errorf("error description = %q, want %q", got, tt.wantErrorDesc)
 				}
+				// The backoff interval is repeated in the body for clients that read it
+				if got, _ := resp["interval"].(float64); tt.wantRetryAfter != "" && fmt.Sprint(got) != tt.wantRetryAfter {
+					t.Errorf("interval = %v, want %s", resp["interval"], tt.wantRetryAfter)
+				} else if _, ok := resp["interval"]; tt.wantRetryAfter == "" && ok {
+					t.Errorf("unexpected interval %v", resp["interval"])
+				}
 				return
 			}
 
@@ -288,3 +332,82 @@ func TestTokenHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenHandlerIDToken(t *testing.T) {
+	for _, include := range []bool{false, true} {
+		flow := &mockFlow{
+			checkDeviceCode: func(ctx context.Context, code string) (*deviceflow.TokenResponse, error) {
+				return &deviceflow.TokenResponse{
+					AccessToken: "access",
+					TokenType:   "Bearer",
+					ExpiresIn:   300,
+					IDToken:     "id-token",
+					Identity:    &deviceflow.Identity{Subject: "user-1", Email: "user@example.com"},
+				}, nil
+			},
+		}
+		handler := New(Config{Flow: flow, IncludeIDToken: include})
+
+		values := url.Values{}
+		values.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+		values.Set("device_code", "device-123")
+		values.Set("client_id", "test")
+		req := httptest.NewRequest(http.MethodPost, "/device/token", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		var resp map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if _, ok := resp["id_token"]; ok != include {
+			t.Errorf("IncludeIDToken=%v: response %v", include, resp)
+		}
+		if strings.Contains(w.Body.String(), "user@example.com") {
+			t.Errorf("response exposes identity claims: %v", resp)
+		}
+	}
+}
+
+func TestTokenHandlerUnknownClient(t *testing.T) {
+	polled := false
+	handler := New(Config{
+		Flow: &mockFlow{checkDeviceCode: func(ctx context.Context, code string) (*deviceflow.TokenResponse, error) {
+			polled = true
+			return nil, deviceflow.ErrPendingAuthorization
+		}},
+		ClientAuth: &common.ClientAuthenticator{
+			Known: func(clientID string) bool { return clientID == "tv" },
+		},
+	})
+
+	poll := func(clientID string) *httptest.ResponseRecorder {
+		form := url.Values{
+			"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+			"device_code": {"test-code"},
+			"client_id":   {clientID},
+		}
+		req := httptest.NewRequest(http.MethodPost, "/device/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := poll("unknown")
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	var resp common.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Error != deviceflow.ErrorCodeInvalidClient {
+		t.Errorf("error = %q, %v; want %q", resp.Error, err, deviceflow.ErrorCodeInvalidClient)
+	}
+	if polled {
+		t.Error("device code polled for an unknown client")
+	}
+
+	if w := poll("tv"); w.Code != http.StatusBadRequest || !polled {
+		t.Errorf("registered client status code = %d, polled %v; want a pending poll", w.Code, polled)
+	}
+}
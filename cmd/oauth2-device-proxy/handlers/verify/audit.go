@@ -0,0 +1,91 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// recordAudit appends an authorization decision to the audit trail. Failures are
+// logged rather than surfaced since the decision has already taken effect.
+func (h *Handler) recordAudit(r *http.Request, action string, code *deviceflow.DeviceCode, subject string) {
+	h.writeAudit(r, auditRecord(r, action, code, subject))
+}
+
+// auditRecord describes an authorization decision on a device code
+func auditRecord(r *http.Request, action string, code *deviceflow.DeviceCode, subject string) audit.Record {
+	record := audit.Record{
+		Action:         action,
+		ClientID:       code.ClientID,
+		UserCode:       code.UserCode,
+		DeviceCodeHash: audit.HashDeviceCode(code.DeviceCode),
+		Subject:        subject,
+		Scope:          code.Scope,
+		RemoteIP:       common.ClientIP(r),
+		UserAgent:      r.UserAgent(),
+		RequestedAt:    audit.OptionalTime(code.RequestedAt),
+		VerifiedAt:     audit.OptionalTime(code.VerifiedAt),
+		AuthorizedAt:   audit.OptionalTime(code.AuthorizedAt),
+	}
+	if code.Device != nil {
+		record.DeviceID = code.Device.ID
+	}
+	return record
+}
+
+// writeAudit appends a record to the audit trail
+func (h *Handler) writeAudit(r *http.Request, record audit.Record) {
+	if err := h.audit.Record(r.Context(), record); err != nil {
+		log.Printf("Error: failed to record %s audit entry: %v", record.Action, err)
+	}
+}
+
+// approvingSubject identifies the user who approved the device by the
+// immutable sub claim of the validated ID token, falling back to the access
+// token's for flows without one. Usernames and email addresses can be changed
+// or reassigned, so they are never used to key audit records or sessions.
+func approvingSubject(token *deviceflow.TokenResponse) string {
+	if token.Identity != nil && token.Identity.Subject != "" {
+		return token.Identity.Subject
+	}
+	return tokenClaim(token.AccessToken, "sub")
+}
+
+// tokenClaim extracts a string claim from a JWT access token. Opaque tokens
+// yield an empty value.
+func tokenClaim(accessToken, name string) string {
+	payload := tokenPayload(accessToken)
+	if payload == nil {
+		return ""
+	}
+
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	value, _ := claims[name].(string)
+	return value
+}
+
+// tokenPayload returns the claims segment of a JWT access token. The token was
+// received directly from the token endpoint over the back channel, so its claims
+// are read without signature verification. Opaque tokens yield nil.
+func tokenPayload(accessToken string) []byte {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	return payload
+}
@@ -0,0 +1,124 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"net/http"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// retryKind selects the link offered on an error page
+type retryKind int
+
+const (
+	// retryAgain offers to repeat the attempt, for transient and server errors
+	retryAgain retryKind = iota
+
+	// retryNewCode sends the user back to enter the code from their device
+	// again, for codes and requests that are no longer valid
+	retryNewCode
+
+	// retryNone offers no link because the flow has ended
+	retryNone
+
+	// retryDevices returns to the self-service devices page
+	retryDevices
+)
+
+// pageError is an entry in the catalog of pages that end a verification
+// request. Codes are stable and machine-readable; titles and messages may be
+// reworded freely.
+type pageError struct {
+	code    string
+	status  int
+	title   string
+	message string
+	retry   retryKind
+}
+
+// Error page catalog
+var (
+	errInvalidForm = pageError{deviceflow.ErrorCodeInvalidRequest, http.StatusBadRequest,
+		"Invalid Request", "Unable to process form submission. Please try again.", retryAgain}
+	errMissingCode = pageError{"missing_code", http.StatusBadRequest,
+		"Missing Code", "Please enter the code shown on your device.", retryAgain}
+	errInvalidCode = pageError{"invalid_code", http.StatusBadRequest,
+		"Invalid Code", msgInvalidCode, retryAgain}
+	errSubmissionPending = pageError{"submission_in_progress", http.StatusConflict,
+		"Request In Progress", "Your code is already being verified. Please wait a moment and refresh the page.", retryNone}
+	errTooManyAttempts = pageError{"too_many_attempts", http.StatusTooManyRequests,
+		"Too Many Attempts", "Too many codes were entered from your network. Please wait a few minutes and try again.", retryNone}
+	errSessionExpired = pageError{"session_expired", http.StatusBadRequest,
+		"Security Error", msgSessionExpired, retryAgain}
+	errCSRFUnavailable = pageError{"csrf_unavailable", http.StatusBadRequest,
+		"Security Error", "Unable to process request securely. Please try again in a moment.", retryAgain}
+	errConfiguration = pageError{"configuration_error", http.StatusInternalServerError,
+		"Configuration Error", "Invalid service configuration. Please try again later.", retryAgain}
+	errInvalidDeviceCode = pageError{"invalid_device_code", http.StatusBadRequest,
+		"Invalid Request", "Unable to verify device code. Please start over.", retryNewCode}
+	errRequestExpired = pageError{deviceflow.ErrorCodeExpiredToken, http.StatusBadRequest,
+		"Request Expired", "This authorization request is no longer valid. Please enter the code from your device again.", retryNewCode}
+	errConsentChoice = pageError{"consent_required", http.StatusBadRequest,
+		"Invalid Request", "Please choose whether to approve or deny the request.", retryNewCode}
+	errSessionStart = pageError{"session_start_failed", http.StatusInternalServerError,
+		"Server Error", "Unable to start authorization. Please try again.", retryAgain}
+	errCallbackSource = pageError{"invalid_state", http.StatusBadRequest,
+		"Invalid Request", "Unable to verify authorization source. Please try again.", retryNewCode}
+	errCallbackReplayed = pageError{"callback_replayed", http.StatusBadRequest,
+		"Invalid Request", "This sign-in response has already been used. Please enter the code from your device again.", retryNewCode}
+	errMissingAuthorization = pageError{"missing_authorization", http.StatusBadRequest,
+		"Invalid Request", "No authorization received. Please try again.", retryNewCode}
+	errUpstreamUnavailable = pageError{"upstream_unavailable", http.StatusServiceUnavailable,
+		"Service Unavailable", "The sign-in service is temporarily unavailable. Please try again in a few minutes.", retryNewCode}
+	errCallbackInvalid = pageError{"invalid_callback", http.StatusBadRequest,
+		"Authorization Failed", "The sign-in response could not be verified. Please try again.", retryNewCode}
+	errExchangeFailed = pageError{"exchange_failed", http.StatusInternalServerError,
+		"Authorization Failed", "Unable to complete device authorization. Please try again.", retryNewCode}
+	errUpstreamError = pageError{"upstream_error", http.StatusBadRequest,
+		"Authorization Failed", "The authorization server was unable to complete the request. Please try again.", retryNewCode}
+	errAccessNotGranted = pageError{"access_not_granted", http.StatusBadRequest,
+		"Authorization Failed", "The device requested access that could not be granted. You may close this window.", retryNone}
+	errPolicyDenied = pageError{"policy_denied", http.StatusForbidden,
+		"Authorization Not Allowed", "Your organization does not allow this device to be authorized. You may close this window.", retryNone}
+	errPolicyUnavailable = pageError{"policy_unavailable", http.StatusServiceUnavailable,
+		"Service Unavailable", "Unable to check whether this authorization is allowed. Please try again in a few minutes.", retryNewCode}
+	errSaveFailed = pageError{"save_failed", http.StatusInternalServerError,
+		"Server Error", "Unable to save authorization. Your device may need to start over.", retryNewCode}
+
+	// Self-service devices page errors
+	errDevicesSignIn = pageError{"sign_in_failed", http.StatusBadRequest,
+		"Sign-in Failed", "Unable to sign you in. Please try again.", retryDevices}
+	errDevicesSession = pageError{"session_expired", http.StatusBadRequest,
+		"Security Error", "Your session has expired. Please sign in again.", retryDevices}
+	errDevicesUnavailable = pageError{"devices_unavailable", http.StatusServiceUnavailable,
+		"Service Unavailable", "Unable to load your devices. Please try again in a few minutes.", retryDevices}
+	errUnknownDevice = pageError{"unknown_device", http.StatusNotFound,
+		"Unknown Device", "This device is not among the devices you authorized.", retryDevices}
+	errDeviceNotRevocable = pageError{"not_revocable", http.StatusConflict,
+		"Cannot Revoke Access", "This service no longer holds the device's access. Remove it in your account settings instead.", retryDevices}
+	errRevokeFailed = pageError{"revoke_failed", http.StatusBadGateway,
+		"Revocation Failed", "Unable to revoke the device's access. Please try again.", retryDevices}
+
+	// Pages ending the flow successfully share the catalog so API consumers
+	// see the same response shape
+	pageAuthorized = pageError{"authorization_complete", http.StatusOK,
+		"Authorization Complete", "Device successfully authorized. You may close this window.", retryNone}
+	pageAlreadyAuthorized = pageError{"already_authorized", http.StatusOK,
+		"Already Authorized", "This device has already been authorized. You may close this window.", retryNone}
+	pageDenied = pageError{deviceflow.ErrorCodeAccessDenied, http.StatusOK,
+		"Authorization Denied", "You denied access for the device. You may close this window.", retryNone}
+)
+
+// retryLink returns the label and target of the page's retry link
+func (h *Handler) retryLink(e pageError) (label, target string) {
+	switch e.retry {
+	case retryNewCode:
+		return "Enter a New Code", h.baseURL + "/device"
+	case retryAgain:
+		return "Try Again", h.baseURL + "/device"
+	case retryDevices:
+		return "Back to My Devices", h.baseURL + DevicesPath
+	default:
+		return "", ""
+	}
+}
@@ -0,0 +1,136 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+)
+
+func TestRenderError_RetryLinks(t *testing.T) {
+	tests := []struct {
+		name      string
+		page      pageError
+		wantLabel string
+		wantFinal bool
+	}{
+		{name: "expired code", page: errRequestExpired, wantLabel: "Enter a New Code"},
+		{name: "server error", page: errSessionStart, wantLabel: "Try Again"},
+		{name: "flow ended", page: pageDenied, wantFinal: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got templates.ErrorData
+			handler := New(Config{
+				Flow: &mockFlow{},
+				Templates: newMockTemplates().WithRenderError(func(w http.ResponseWriter, data templates.ErrorData) error {
+					got = data
+					return nil
+				}).ToTemplates(),
+				CSRF:    newMockCSRF().ToManager(),
+				BaseURL: "https://example.com",
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/device", nil)
+			req = req.WithContext(context.WithValue(req.Context(), middleware.RequestIDKey, "req-42"))
+			w := httptest.NewRecorder()
+			handler.renderError(w, req, tt.page)
+
+			if w.Code != tt.page.status {
+				t.Errorf("status code = %d, want %d", w.Code, tt.page.status)
+			}
+			if got.Code != tt.page.code || got.CorrelationID != "req-42" {
+				t.Errorf("code, correlation ID = %q, %q; want %q, req-42", got.Code, got.CorrelationID, tt.page.code)
+			}
+			if got.Final != tt.wantFinal || got.RetryLabel != tt.wantLabel {
+				t.Errorf("final, retry label = %v, %q; want %v, %q", got.Final, got.RetryLabel, tt.wantFinal, tt.wantLabel)
+			}
+			if !tt.wantFinal && got.RetryURL != "https://example.com/device" {
+				t.Errorf("retry URL = %q, want https://example.com/device", got.RetryURL)
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_JSONNegotiation(t *testing.T) {
+	csrfManager := newMockCSRF().ToManager()
+	handler := New(Config{
+		Flow: &mockFlow{
+			verifyUserCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+				return nil, errors.New("invalid code")
+			},
+		},
+		Templates: newMockTemplates().WithRenderVerify(func(w http.ResponseWriter, data templates.VerifyData) error {
+			return nil
+		}).ToTemplates(),
+		CSRF:    csrfManager,
+		BaseURL: "https://example.com",
+	})
+
+	t.Run("form", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/device", nil)
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		handler.HandleForm(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("Content-Type = %q, want application/json", ct)
+		}
+		var body formBody
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if body.VerificationURI != "https://example.com/device" || body.CSRFToken == "" || body.FormNonce == "" {
+			t.Errorf("unexpected form response: %+v", body)
+		}
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		token, err := csrfManager.GenerateToken(context.Background())
+		if err != nil {
+			t.Fatalf("GenerateToken failed: %v", err)
+		}
+		values := url.Values{}
+		values.Set("code", "BDFG-HJKL")
+		values.Set("csrf_token", token)
+		req := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+		w := httptest.NewRecorder()
+		handler.HandleSubmit(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("status code = %d, want %d", w.Code, http.StatusBadRequest)
+		}
+		var body errorBody
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("decoding response: %v", err)
+		}
+		if body.Error != "invalid_code" || body.ErrorDescription != msgInvalidCode || body.CorrelationID == "" {
+			t.Errorf("unexpected error response: %+v", body)
+		}
+	})
+
+	t.Run("browser", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/device", nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/json;q=0.9")
+		w := httptest.NewRecorder()
+		handler.HandleForm(w, req)
+
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("Content-Type = %q, want text/html", ct)
+		}
+	})
+}
@@ -2,9 +2,14 @@
 package verify
 
 import (
+	"errors"
 	"log"
 	"net/http"
 
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/httpclient"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
 	"github.com/wrale/oauth2-device-proxy/internal/templates"
 )
 
@@ -12,57 +17,153 @@ import (
 func (h *Handler) HandleComplete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Verify state matches device code
-	deviceCode := r.URL.Query().Get("state")
-	if deviceCode == "" {
-		h.renderError(w, http.StatusBadRequest,
-			"Invalid Request",
-			"Unable to verify authorization source. Please try again.")
+	// The state must match the session started in this browser, which names
+	// the device code so a substituted state cannot complete another device
+	sessionState, nonce := splitCallbackState(r.URL.Query().Get("state"))
+	sess, err := h.sessions.Verify(r, sessionState)
+	if err != nil {
+		h.renderError(w, r, errCallbackSource)
+		return
+	}
+	// Each session completes at most once, but stays readable so pages left
+	// open in other tabs can still report the outcome
+	if err := h.sessions.Finish(w, sess); err != nil {
+		log.Printf("Warning: failed to finish verification session: %v", err)
+		h.sessions.Clear(w)
+	}
+	deviceCode := sess.DeviceCode
+
+	// The callback nonce is consumed server-side, so a replayed callback URL
+	// fails even alongside a copied session cookie
+	if err := h.flow.ConsumeCallbackNonce(ctx, deviceCode, nonce); err != nil {
+		if dfe, ok := deviceflow.AsDeviceFlowError(err); !ok || dfe.Code == deviceflow.ErrorCodeServerError {
+			log.Printf("Error: failed to consume callback nonce: %v", err)
+		}
+		h.renderError(w, r, errCallbackReplayed)
+		return
+	}
+
+	// The authorization server reports user denial via the error parameter
+	// per RFC 6749 section 4.1.2.1, which ends the device flow with access_denied
+	if errCode := r.URL.Query().Get("error"); errCode != "" {
+		h.handleAuthorizationError(w, r, deviceCode, errCode)
 		return
 	}
 
 	// Verify auth code presence
 	authCode := r.URL.Query().Get("code")
 	if authCode == "" {
-		h.renderError(w, http.StatusBadRequest,
-			"Invalid Request",
-			"No authorization received. Please try again.")
+		h.renderError(w, r, errMissingAuthorization)
 		return
 	}
 
 	// Load device code details
 	dCode, err := h.flow.GetDeviceCode(ctx, deviceCode)
 	if err != nil {
-		h.renderError(w, http.StatusBadRequest,
-			"Invalid Request",
-			"Unable to verify device code. Please start over.")
+		h.renderError(w, r, errInvalidDeviceCode)
 		return
 	}
 
 	// Exchange code for token
 	token, err := h.exchangeCode(ctx, authCode, dCode)
+	if errors.Is(err, httpclient.ErrCircuitOpen) || errors.Is(err, oauth.ErrProviderUnavailable) {
+		h.renderError(w, r, errUpstreamUnavailable)
+		return
+	}
+	if dfe, ok := deviceflow.AsDeviceFlowError(err); ok {
+		h.handleUpstreamError(w, r, dCode, dfe)
+		return
+	}
+	if errors.Is(err, oauth.ErrInvalidToken) || errors.Is(err, oauth.ErrTokenExpired) {
+		log.Printf("Rejected ID token from authorization server: %v", err)
+		h.renderError(w, r, errCallbackInvalid)
+		return
+	}
 	if err != nil {
-		h.renderError(w, http.StatusInternalServerError,
-			"Authorization Failed",
-			"Unable to complete device authorization. Please try again.")
+		h.renderError(w, r, errExchangeFailed)
 		return
 	}
 
-	// Complete device authorization
+	// The policy sees who signed in before the device can receive a token
+	if !h.checkPolicy(w, r, dCode, token) {
+		return
+	}
+
+	// Complete device authorization. Only the first of concurrent completions,
+	// such as the same code approved in two tabs, stores its token.
 	if err := h.flow.CompleteAuthorization(ctx, deviceCode, token); err != nil {
-		h.renderError(w, http.StatusInternalServerError,
-			"Server Error",
-			"Unable to save authorization. Your device may need to start over.")
+		if errors.Is(err, deviceflow.ErrAlreadyAuthorized) {
+			h.renderAlreadyAuthorized(w, r)
+			return
+		}
+		// A token enricher may refuse the authorization, which ends the flow
+		if dfe, ok := deviceflow.AsDeviceFlowError(err); ok && dfe.Terminal() && dfe.Code != deviceflow.ErrorCodeExpiredToken {
+			h.renderError(w, r, errAccessNotGranted)
+			return
+		}
+		h.renderError(w, r, errSaveFailed)
 		return
 	}
 
+	// Reload the code for the authorization time recorded by the flow
+	if authorized, err := h.flow.GetDeviceCode(ctx, deviceCode); err == nil && authorized != nil {
+		dCode = authorized
+	}
+	h.recordAudit(r, audit.ActionApproved, dCode, approvingSubject(token))
+
+	summary := h.completionSummary(ctx, dCode, token)
+	h.notifyAuthorized(r, dCode, token, summary)
+
 	// Show success page with 200 OK per RFC 8628
 	if err := h.templates.RenderComplete(w, templates.CompleteData{
 		Message: "You have successfully authorized the device. You may now close this window and return to your device.",
+		Summary: summary,
+	}); err != nil {
+		log.Printf("Failed to render completion page: %v", err)
+		h.renderError(w, r, pageAuthorized)
+	}
+}
+
+// renderAlreadyAuthorized tells the user the device was approved elsewhere
+func (h *Handler) renderAlreadyAuthorized(w http.ResponseWriter, r *http.Request) {
+	if err := h.templates.RenderComplete(w, templates.CompleteData{
+		Message: "This device has already been authorized. You may close this window and return to your device.",
 	}); err != nil {
 		log.Printf("Failed to render completion page: %v", err)
-		h.renderError(w, http.StatusOK, // Use 200 per RFC 8628
-			"Authorization Complete",
-			"Device successfully authorized. You may close this window.")
+		h.renderError(w, r, pageAlreadyAuthorized)
+	}
+}
+
+// handleAuthorizationError processes an error redirect from the authorization server
+func (h *Handler) handleAuthorizationError(w http.ResponseWriter, r *http.Request, deviceCode, errCode string) {
+	dCode, err := h.flow.GetDeviceCode(r.Context(), deviceCode)
+	if err != nil {
+		h.renderError(w, r, errInvalidDeviceCode)
+		return
+	}
+
+	h.handleUpstreamError(w, r, dCode,
+		deviceflow.MapUpstreamError(errCode, r.URL.Query().Get("error_description")))
+}
+
+// handleUpstreamError relays an authorization server error. Terminal errors end
+// the device flow so the poller stops waiting; others let the user retry.
+func (h *Handler) handleUpstreamError(w http.ResponseWriter, r *http.Request, dCode *deviceflow.DeviceCode, dfe *deviceflow.DeviceFlowError) {
+	switch {
+	case dfe.Code == deviceflow.ErrorCodeAccessDenied:
+		h.denyAuthorization(w, r, dCode)
+
+	case dfe.Terminal():
+		if err := h.flow.FailAuthorization(r.Context(), dCode.DeviceCode, dfe); err != nil {
+			log.Printf("Error: failed to record authorization failure: %v", err)
+		}
+		h.renderError(w, r, errAccessNotGranted)
+
+	case dfe.Code == deviceflow.ErrorCodeTemporarilyUnavailable:
+		h.renderError(w, r, errUpstreamUnavailable)
+
+	default:
+		log.Printf("Authorization server returned error: %s", dfe.Error())
+		h.renderError(w, r, errUpstreamError)
 	}
 }
@@ -0,0 +1,193 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/features"
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+)
+
+// Consent form actions
+const (
+	consentApprove = "approve"
+	consentDeny    = "deny"
+)
+
+// HandleConsent processes the user's decision on the consent page
+func (h *Handler) HandleConsent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		h.renderError(w, r, errInvalidForm)
+		return
+	}
+
+	if err := h.csrf.ConsumeRequest(r, r.PostFormValue("csrf_token")); err != nil {
+		h.renderError(w, r, errSessionExpired)
+		return
+	}
+
+	deviceCode, err := h.flow.ResolveConsent(ctx, r.PostFormValue("consent_ticket"))
+	if err != nil {
+		h.renderError(w, r, errRequestExpired)
+		return
+	}
+
+	// The ticket must belong to the browser that verified the user code
+	sess, err := h.sessions.Load(r)
+	if err != nil || sess.DeviceCode != deviceCode.DeviceCode {
+		h.renderError(w, r, errRequestExpired)
+		return
+	}
+
+	switch r.PostFormValue("action") {
+	case consentApprove:
+		// A device code flagged after the page was shown must be approved
+		// again with the warning visible
+		if requiresReverification(deviceCode) && r.PostFormValue("anomaly_acknowledged") != "true" {
+			h.showConsent(w, r, deviceCode)
+			return
+		}
+		h.redirectToAuthorization(w, r, deviceCode, sess.State)
+	case consentDeny:
+		h.denyAuthorization(w, r, deviceCode)
+	default:
+		h.renderError(w, r, errConsentChoice)
+	}
+}
+
+// continueAuthorization starts a verification session for the device code, then
+// shows the consent page or redirects straight to the authorization endpoint
+// when consent is disabled and the code does not require reverification
+func (h *Handler) continueAuthorization(w http.ResponseWriter, r *http.Request, deviceCode *deviceflow.DeviceCode) {
+	sess, err := h.sessions.Start(w, deviceCode.DeviceCode)
+	if err != nil {
+		log.Printf("Error: failed to start verification session: %v", err)
+		h.renderError(w, r, errSessionStart)
+		return
+	}
+
+	if !h.consentEnabled(deviceCode.ClientID) && !requiresReverification(deviceCode) {
+		h.redirectToAuthorization(w, r, deviceCode, sess.State)
+		return
+	}
+
+	h.showConsent(w, r, deviceCode)
+}
+
+// consentEnabled reports whether the consent page is shown to a client's users
+func (h *Handler) consentEnabled(clientID string) bool {
+	return h.consent || h.features.EnabledFor(features.ConsentScreen, clientID)
+}
+
+// showConsent renders the consent page for a verified device code
+func (h *Handler) showConsent(w http.ResponseWriter, r *http.Request, deviceCode *deviceflow.DeviceCode) {
+	ticket, err := h.flow.BeginConsent(r.Context(), deviceCode.DeviceCode)
+	if err != nil {
+		h.renderError(w, r, errInvalidDeviceCode)
+		return
+	}
+
+	client := h.clients.Metadata(r.Context(), deviceCode.ClientID)
+	h.renderConsent(w, templates.ConsentData{
+		ClientName:  client.Name,
+		ClientLogo:  client.LogoURI,
+		ConsentText: client.ConsentText,
+		UserCode:    deviceCode.UserCode,
+		Scopes:      h.consentScopes(deviceCode.Scope),
+		Device:      consentDevice(deviceCode.Device),
+		Origin:      requestOrigin(deviceCode),
+		Anomaly:     consentAnomaly(deviceCode.Anomaly),
+		CSRFToken:   h.freshCSRFToken(w, r),
+		Ticket:      ticket,
+	})
+}
+
+// requiresReverification reports whether the user must approve the device code
+// on the consent page after it was polled by an unexpected client
+func requiresReverification(code *deviceflow.DeviceCode) bool {
+	return code.Anomaly != nil && code.Anomaly.Reverify
+}
+
+// consentAnomaly describes an unexpected poller for display
+func consentAnomaly(anomaly *deviceflow.PollAnomaly) *templates.ConsentAnomaly {
+	if anomaly == nil {
+		return nil
+	}
+	display := &templates.ConsentAnomaly{IP: anomaly.IP}
+	for _, reason := range anomaly.Reasons {
+		switch reason {
+		case deviceflow.AnomalyNetworkChanged:
+			display.NetworkChanged = true
+		case deviceflow.AnomalyUserAgentChanged:
+			display.UserAgentChanged = true
+		}
+	}
+	return display
+}
+
+// consentScopes describes each requested scope for display
+func (h *Handler) consentScopes(scope string) []templates.ConsentScope {
+	names := strings.Fields(scope)
+	scopes := make([]templates.ConsentScope, 0, len(names))
+	for _, name := range names {
+		scopes = append(scopes, templates.ConsentScope{
+			Name:        name,
+			Description: h.clients.ScopeDescription(name),
+		})
+	}
+	return scopes
+}
+
+// consentDevice describes the client-asserted device identity for display
+func consentDevice(device *deviceflow.DeviceIdentity) *templates.ConsentDevice {
+	if device == nil {
+		return nil
+	}
+	return &templates.ConsentDevice{
+		ID:       device.ID,
+		Attested: device.Attestation != "",
+	}
+}
+
+// requestOrigin describes where the device authorization request came from
+func requestOrigin(code *deviceflow.DeviceCode) string {
+	switch {
+	case code.RequestIP == "":
+		return code.RequestLocation
+	case code.RequestLocation == "":
+		return code.RequestIP
+	default:
+		return code.RequestIP + " (" + code.RequestLocation + ")"
+	}
+}
+
+// denyAuthorization ends the flow with access_denied per RFC 8628 section 3.5
+// and records the decision in the audit trail
+func (h *Handler) denyAuthorization(w http.ResponseWriter, r *http.Request, deviceCode *deviceflow.DeviceCode) {
+	if err := h.flow.DenyAuthorization(r.Context(), deviceCode.DeviceCode); err != nil {
+		h.renderError(w, r, errInvalidDeviceCode)
+		return
+	}
+
+	h.recordAudit(r, audit.ActionDenied, deviceCode, "")
+
+	h.renderError(w, r, pageDenied)
+}
+
+// renderConsent handles consent page rendering
+func (h *Handler) renderConsent(w http.ResponseWriter, data templates.ConsentData) {
+	rw := newResponseWriter(w)
+	rw.WriteHeader(http.StatusOK)
+
+	if err := h.templates.RenderConsent(rw, data); err != nil {
+		log.Printf("Failed to render consent page: %v", err)
+		h.writeResponse(rw, http.StatusOK,
+			"Unable to display the approval page. Please try again.")
+	}
+}
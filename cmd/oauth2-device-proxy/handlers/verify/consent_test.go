@@ -0,0 +1,298 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/clients"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/session"
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+)
+
+func TestVerifyHandler_HandleSubmitConsent(t *testing.T) {
+	deviceCode := &deviceflow.DeviceCode{
+		DeviceCode: "device-123",
+		UserCode:   "BCDF-GHJK",
+		ClientID:   "kiosk",
+		Scope:      "openid orders:read",
+	}
+
+	flow := &mockFlow{
+		verifyUserCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+			return deviceCode, nil
+		},
+	}
+	flow.BeginConsentFunc = func(ctx context.Context, code string) (string, error) {
+		return "ticket-" + code, nil
+	}
+
+	var consent templates.ConsentData
+	tmpls := newMockTemplates().
+		WithRenderConsent(func(w http.ResponseWriter, data templates.ConsentData) error {
+			consent = data
+			return nil
+		})
+
+	registry, err := clients.NewRegistry([]clients.Client{{ID: "kiosk", Name: "Lobby Kiosk"}})
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+
+	csrfManager := newMockCSRF().ToManager()
+	token, err := csrfManager.GenerateToken(context.Background())
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	handler := New(Config{
+		Flow:      flow,
+		Templates: tmpls.ToTemplates(),
+		CSRF:      csrfManager,
+		OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}},
+		BaseURL:   "https://example.com",
+		Clients:   registry,
+		Consent:   true,
+	})
+
+	values := url.Values{}
+	values.Set("code", "BCDF-GHJK")
+	values.Set("csrf_token", token)
+	req := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.HandleSubmit(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w.Header().Get("Location") != "" {
+		t.Error("consent step should not redirect to the authorization endpoint")
+	}
+	if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Name != session.CookieName {
+		t.Errorf("cookies = %+v, want a verification session", cookies)
+	}
+	if consent.ClientName != "Lobby Kiosk" || consent.UserCode != "BCDF-GHJK" {
+		t.Errorf("consent data = %+v", consent)
+	}
+	if consent.Ticket != "ticket-device-123" || consent.CSRFToken == "" {
+		t.Errorf("consent form fields = ticket %q, csrf %q", consent.Ticket, consent.CSRFToken)
+	}
+	// The consent form carries a freshly issued token rather than the submitted one
+	if consent.CSRFToken == token {
+		t.Error("consent form reused the submitted CSRF token")
+	}
+	if err := csrfManager.ValidateToken(context.Background(), consent.CSRFToken); err != nil {
+		t.Errorf("consent CSRF token rejected: %v", err)
+	}
+	if len(consent.Scopes) != 2 || consent.Scopes[0].Description == "" || consent.Scopes[1].Name != "orders:read" {
+		t.Errorf("consent scopes = %+v", consent.Scopes)
+	}
+}
+
+func TestVerifyHandler_HandleConsent(t *testing.T) {
+	deviceCode := &deviceflow.DeviceCode{DeviceCode: "device-123", ClientID: "kiosk", Scope: "openid", Nonce: "nonce-1"}
+
+	tests := []struct {
+		name         string
+		ticket       string
+		action       string
+		otherSession bool // Session cookie belongs to a different device code
+		wantStatus   int
+		wantRedirect bool
+		wantDenied   bool
+	}{
+		{
+			name:         "approve redirects to authorization",
+			ticket:       "ticket-123",
+			action:       "approve",
+			wantStatus:   http.StatusFound,
+			wantRedirect: true,
+		},
+		{
+			name:       "deny ends the flow",
+			ticket:     "ticket-123",
+			action:     "deny",
+			wantStatus: http.StatusOK,
+			wantDenied: true,
+		},
+		{
+			name:       "unknown ticket",
+			ticket:     "bogus",
+			action:     "approve",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "session for another device",
+			ticket:       "ticket-123",
+			action:       "approve",
+			otherSession: true,
+			wantStatus:   http.StatusBadRequest,
+		},
+		{
+			name:       "missing action",
+			ticket:     "ticket-123",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var denied bool
+			flow := &mockFlow{}
+			flow.ResolveConsentFunc = func(ctx context.Context, ticket string) (*deviceflow.DeviceCode, error) {
+				if ticket != "ticket-123" {
+					return nil, deviceflow.NewDeviceFlowError(deviceflow.ErrorCodeInvalidRequest, "Unknown or expired consent ticket")
+				}
+				return deviceCode, nil
+			}
+			flow.DenyAuthFunc = func(ctx context.Context, code string) error {
+				denied = code == "device-123"
+				return nil
+			}
+
+			auditLog := &recordingAudit{}
+			csrfManager := newMockCSRF().ToManager()
+			token, err := csrfManager.GenerateToken(context.Background())
+			if err != nil {
+				t.Fatalf("GenerateToken failed: %v", err)
+			}
+
+			handler := New(Config{
+				Flow:      flow,
+				Templates: newMockTemplates().ToTemplates(),
+				CSRF:      csrfManager,
+				OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}},
+				BaseURL:   "https://example.com",
+				Audit:     auditLog,
+				Consent:   true,
+			})
+
+			sessionCode := "device-123"
+			if tt.otherSession {
+				sessionCode = "device-456"
+			}
+			sess, cookie := startSession(t, handler, sessionCode)
+
+			values := url.Values{}
+			values.Set("csrf_token", token)
+			values.Set("consent_ticket", tt.ticket)
+			values.Set("action", tt.action)
+			req := httptest.NewRequest(http.MethodPost, "/device/consent", strings.NewReader(values.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			handler.HandleConsent(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+
+			location := w.Header().Get("Location")
+			if tt.wantRedirect != strings.HasPrefix(location, "https://idp.example.com/auth?") {
+				t.Errorf("Location = %q, want redirect %v", location, tt.wantRedirect)
+			}
+			if tt.wantRedirect && (!strings.Contains(location, "state="+sess.State) || !strings.Contains(location, "nonce=nonce-1")) {
+				t.Errorf("Location = %q, want session state and nonce", location)
+			}
+
+			if denied != tt.wantDenied {
+				t.Errorf("denied = %v, want %v", denied, tt.wantDenied)
+			}
+			if tt.wantDenied && (len(auditLog.records) != 1 || auditLog.records[0].Action != audit.ActionDenied) {
+				t.Errorf("audit records = %+v, want one denial", auditLog.records)
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_ConsentReverification(t *testing.T) {
+	deviceCode := &deviceflow.DeviceCode{
+		DeviceCode: "device-123",
+		UserCode:   "BCDF-GHJK",
+		ClientID:   "kiosk",
+		Anomaly: &deviceflow.PollAnomaly{
+			Reasons:  []string{deviceflow.AnomalyNetworkChanged},
+			IP:       "198.51.100.7",
+			Reverify: true,
+		},
+	}
+
+	var consent *templates.ConsentData
+	flow := &mockFlow{
+		verifyUserCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+			return deviceCode, nil
+		},
+	}
+	flow.BeginConsentFunc = func(ctx context.Context, code string) (string, error) {
+		return "ticket-123", nil
+	}
+	flow.ResolveConsentFunc = func(ctx context.Context, ticket string) (*deviceflow.DeviceCode, error) {
+		return deviceCode, nil
+	}
+	tmpls := newMockTemplates().
+		WithRenderConsent(func(w http.ResponseWriter, data templates.ConsentData) error {
+			consent = &data
+			return nil
+		})
+
+	csrfManager := newMockCSRF().ToManager()
+	token, err := csrfManager.GenerateToken(context.Background())
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+
+	// Consent is disabled, yet the flagged code must be approved on the page
+	handler := New(Config{
+		Flow:      flow,
+		Templates: tmpls.ToTemplates(),
+		CSRF:      csrfManager,
+		OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}},
+		BaseURL:   "https://example.com",
+	})
+
+	values := url.Values{}
+	values.Set("code", "BCDF-GHJK")
+	values.Set("csrf_token", token)
+	req := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	handler.HandleSubmit(w, req)
+
+	if w.Header().Get("Location") != "" || consent == nil {
+		t.Fatalf("flagged code skipped the consent page, Location = %q", w.Header().Get("Location"))
+	}
+	if consent.Anomaly == nil || !consent.Anomaly.NetworkChanged || consent.Anomaly.IP != "198.51.100.7" {
+		t.Errorf("consent anomaly = %+v", consent.Anomaly)
+	}
+	cookie := w.Result().Cookies()[0]
+
+	for _, acknowledged := range []bool{false, true} {
+		consent = nil
+		values := url.Values{}
+		values.Set("csrf_token", token)
+		values.Set("consent_ticket", "ticket-123")
+		values.Set("action", "approve")
+		if acknowledged {
+			values.Set("anomaly_acknowledged", "true")
+		}
+		req := httptest.NewRequest(http.MethodPost, "/device/consent", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		handler.HandleConsent(w, req)
+
+		redirected := strings.HasPrefix(w.Header().Get("Location"), "https://idp.example.com/auth?")
+		if redirected != acknowledged || (consent != nil) == acknowledged {
+			t.Errorf("acknowledged=%v: redirected %v, consent shown %v", acknowledged, redirected, consent != nil)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+	"github.com/wrale/oauth2-device-proxy/internal/session"
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+)
+
+// Self-service device management paths
+const (
+	DevicesPath         = "/my/devices"
+	DevicesCallbackPath = DevicesPath + "/callback"
+	DevicesRevokePath   = DevicesPath + "/revoke"
+)
+
+// DeviceGrants looks up and revokes the grants the proxy holds for authorized
+// devices, as renewal.Renewer does
+type DeviceGrants interface {
+	AuthorizationGrant(ctx context.Context, deviceCodeHash string) (*renewal.Grant, error)
+	RevokeAuthorization(ctx context.Context, deviceCodeHash string) (*renewal.Grant, error)
+}
+
+// HandleDevices lists the devices the signed-in user authorized, sending
+// users without an account session to sign in at the identity provider
+func (h *Handler) HandleDevices(w http.ResponseWriter, r *http.Request) {
+	account, err := h.sessions.LoadAccount(r)
+	if err != nil || !account.SignedIn() {
+		h.startDevicesSignIn(w, r)
+		return
+	}
+	h.renderDevices(w, r, account, "")
+}
+
+// HandleDevicesCallback completes signing in to the devices page. The user
+// is identified by subject as in the audit trail, so the devices they approved
+// match even after their username changes.
+func (h *Handler) HandleDevicesCallback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	pending, err := h.sessions.VerifySignIn(r, r.URL.Query().Get("state"))
+	if err != nil {
+		h.renderError(w, r, errDevicesSignIn)
+		return
+	}
+	if errCode := r.URL.Query().Get("error"); errCode != "" {
+		log.Printf("Devices page sign-in refused by the authorization server: %s", errCode)
+		h.renderError(w, r, errDevicesSignIn)
+		return
+	}
+	authCode := r.URL.Query().Get("code")
+	if authCode == "" {
+		h.renderError(w, r, errDevicesSignIn)
+		return
+	}
+
+	token, err := h.exchangeCodeAt(ctx, h.baseURL+DevicesCallbackPath, authCode, &deviceflow.DeviceCode{Nonce: pending.Nonce})
+	if err != nil {
+		log.Printf("Error: devices page sign-in failed: %v", err)
+		h.renderError(w, r, errDevicesSignIn)
+		return
+	}
+	subject := approvingSubject(token)
+	if subject == "" {
+		log.Printf("Error: devices page sign-in returned no user identity")
+		h.renderError(w, r, errDevicesSignIn)
+		return
+	}
+
+	if _, err := h.sessions.SignIn(w, subject, h.displayName(ctx, token, subject)); err != nil {
+		log.Printf("Error: failed to start account session: %v", err)
+		h.renderError(w, r, errDevicesSignIn)
+		return
+	}
+	http.Redirect(w, r, h.baseURL+DevicesPath, http.StatusFound)
+}
+
+// HandleRevokeDevice revokes the grant held for one of the signed-in user's
+// devices, after which the device can no longer renew its access token
+func (h *Handler) HandleRevokeDevice(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	account, err := h.sessions.LoadAccount(r)
+	if err != nil || !account.SignedIn() {
+		h.renderError(w, r, errDevicesSession)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		h.renderError(w, r, errDevicesSession)
+		return
+	}
+	if err := h.csrf.ConsumeRequest(r, r.PostFormValue("csrf_token")); err != nil {
+		h.renderError(w, r, errDevicesSession)
+		return
+	}
+
+	// Only authorizations the user approved may be revoked
+	ref := r.PostFormValue("device")
+	approved, err := h.approvedDevice(ctx, account.Subject, ref)
+	if err != nil {
+		log.Printf("Error: listing authorized devices: %v", err)
+		h.renderError(w, r, errDevicesUnavailable)
+		return
+	}
+	if approved == nil {
+		h.renderError(w, r, errUnknownDevice)
+		return
+	}
+	if h.deviceGrants == nil {
+		h.renderError(w, r, errDeviceNotRevocable)
+		return
+	}
+
+	grant, err := h.deviceGrants.RevokeAuthorization(ctx, ref)
+	if errors.Is(err, renewal.ErrUnknownGrant) {
+		h.renderError(w, r, errDeviceNotRevocable)
+		return
+	}
+	if err != nil {
+		log.Printf("Error: revoking device grant: %v", err)
+		h.renderError(w, r, errRevokeFailed)
+		return
+	}
+
+	h.writeAudit(r, audit.Record{
+		Action:         audit.ActionDeviceRevoked,
+		ClientID:       grant.ClientID,
+		DeviceCodeHash: ref,
+		Subject:        account.Subject,
+		Scope:          grant.Scope,
+		DeviceID:       approved.DeviceID,
+		RemoteIP:       common.ClientIP(r),
+		UserAgent:      r.UserAgent(),
+	})
+	h.renderDevices(w, r, account, "Access for "+h.clients.Metadata(ctx, grant.ClientID).Name+" was revoked.")
+}
+
+// startDevicesSignIn sends the user to the authorization endpoint to sign in
+// to the devices page, reusing their session at the identity provider
+func (h *Handler) startDevicesSignIn(w http.ResponseWriter, r *http.Request) {
+	pending, err := h.sessions.StartSignIn(w)
+	if err != nil {
+		log.Printf("Error: failed to start devices page sign-in: %v", err)
+		h.renderError(w, r, errDevicesSignIn)
+		return
+	}
+	http.Redirect(w, r, h.devicesSignInURL(pending), http.StatusFound)
+}
+
+// devicesSignInURL builds the OpenID Connect authorization request signing the
+// user in to the devices page as the proxy's own client
+func (h *Handler) devicesSignInURL(pending *session.Account) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", h.oauth.ClientID)
+	params.Set("redirect_uri", h.baseURL+DevicesCallbackPath)
+	params.Set("scope", "openid")
+	params.Set("state", pending.State)
+	params.Set("nonce", pending.Nonce)
+	return h.oauth.Endpoint.AuthURL + "?" + params.Encode()
+}
+
+// displayName names the signed-in user on the devices page
+func (h *Handler) displayName(ctx context.Context, token *deviceflow.TokenResponse, subject string) string {
+	user := h.signedInUser(ctx, token)
+	for _, name := range []string{user.Name, user.PreferredUsername, user.Email, tokenClaim(token.AccessToken, "preferred_username")} {
+		if name != "" {
+			return name
+		}
+	}
+	return subject
+}
+
+// renderDevices shows the devices the user approved, newest first, with the
+// outcome of the last action when given
+func (h *Handler) renderDevices(w http.ResponseWriter, r *http.Request, account *session.Account, message string) {
+	ctx := r.Context()
+
+	records, err := h.audit.List(ctx, audit.Filter{Subject: account.Subject, Limit: audit.MaxListLimit})
+	if err != nil {
+		log.Printf("Error: listing authorized devices: %v", err)
+		h.renderError(w, r, errDevicesUnavailable)
+		return
+	}
+
+	revoked := make(map[string]bool)
+	for _, record := range records {
+		if record.Action == audit.ActionDeviceRevoked {
+			revoked[record.DeviceCodeHash] = true
+		}
+	}
+	devices := make([]templates.Device, 0, len(records))
+	for _, record := range records {
+		if record.Action != audit.ActionApproved || record.DeviceCodeHash == "" {
+			continue
+		}
+		device := templates.Device{
+			Ref:          record.DeviceCodeHash,
+			ClientName:   h.clients.Metadata(ctx, record.ClientID).Name,
+			DeviceID:     record.DeviceID,
+			Scope:        record.Scope,
+			AuthorizedAt: record.Time,
+			ApprovedFrom: record.RemoteIP,
+			Revoked:      revoked[record.DeviceCodeHash],
+		}
+		if !device.Revoked && h.deviceGrants != nil {
+			grant, err := h.deviceGrants.AuthorizationGrant(ctx, record.DeviceCodeHash)
+			if err != nil {
+				log.Printf("Warning: looking up device grant: %v", err)
+			}
+			device.Revocable = grant != nil
+		}
+		devices = append(devices, device)
+	}
+
+	csrfToken, err := h.csrf.IssueToken(w, r)
+	if err != nil {
+		log.Printf("Error: failed to issue CSRF token: %v", err)
+		h.renderError(w, r, errDevicesUnavailable)
+		return
+	}
+
+	// The page lists the user's devices, so shared caches must not keep it
+	w.Header().Set("Cache-Control", "no-store")
+	if err := h.templates.RenderDevices(w, templates.DevicesData{
+		UserName:  account.Name,
+		Devices:   devices,
+		Message:   message,
+		ManageURL: h.revokeURL,
+		CSRFToken: csrfToken,
+	}); err != nil {
+		log.Printf("Failed to render devices page: %v", err)
+	}
+}
+
+// approvedDevice returns the approval record of the authorization with the
+// given device code hash if the user approved it, or nil
+func (h *Handler) approvedDevice(ctx context.Context, subject, deviceCodeHash string) (*audit.Record, error) {
+	if deviceCodeHash == "" {
+		return nil, nil
+	}
+	records, err := h.audit.List(ctx, audit.Filter{Subject: subject, Action: audit.ActionApproved, Limit: audit.MaxListLimit})
+	if err != nil {
+		return nil, err
+	}
+	for i := range records {
+		if records[i].DeviceCodeHash == deviceCodeHash {
+			return &records[i], nil
+		}
+	}
+	return nil, nil
+}
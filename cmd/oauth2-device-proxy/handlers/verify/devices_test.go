@@ -0,0 +1,203 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+	"github.com/wrale/oauth2-device-proxy/internal/session"
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+)
+
+// fakeDeviceGrants holds grants by device code hash
+type fakeDeviceGrants struct {
+	grants  map[string]*renewal.Grant
+	revoked []string
+}
+
+func (f *fakeDeviceGrants) AuthorizationGrant(ctx context.Context, deviceCodeHash string) (*renewal.Grant, error) {
+	return f.grants[deviceCodeHash], nil
+}
+
+func (f *fakeDeviceGrants) RevokeAuthorization(ctx context.Context, deviceCodeHash string) (*renewal.Grant, error) {
+	grant, ok := f.grants[deviceCodeHash]
+	if !ok {
+		return nil, renewal.ErrUnknownGrant
+	}
+	delete(f.grants, deviceCodeHash)
+	f.revoked = append(f.revoked, deviceCodeHash)
+	return grant, nil
+}
+
+func TestDevicesSignIn(t *testing.T) {
+	accessToken := "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"u1","preferred_username":"alice"}`)) + ".sig"
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("redirect_uri") != "https://example.com"+DevicesCallbackPath {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"` + accessToken + `","token_type":"Bearer","expires_in":300}`))
+	}))
+	defer tokenServer.Close()
+
+	handler := New(Config{
+		Flow:      &mockFlow{},
+		Templates: newMockTemplates().ToTemplates(),
+		CSRF:      newMockCSRF().ToManager(),
+		OAuth: &oauth2.Config{
+			ClientID:    "device-proxy",
+			RedirectURL: "https://example.com/device/complete",
+			Endpoint:    oauth2.Endpoint{AuthURL: "https://idp.example.com/auth", TokenURL: tokenServer.URL},
+		},
+		BaseURL: "https://example.com",
+	})
+
+	// Without an account session the page starts signing in
+	w := httptest.NewRecorder()
+	handler.HandleDevices(w, httptest.NewRequest(http.MethodGet, DevicesPath, nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusFound)
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parsing Location: %v", err)
+	}
+	params := location.Query()
+	if params.Get("client_id") != "device-proxy" || params.Get("redirect_uri") != "https://example.com"+DevicesCallbackPath ||
+		params.Get("scope") != "openid" || params.Get("state") == "" || params.Get("nonce") == "" {
+		t.Errorf("sign-in request = %s", location)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != session.AccountCookieName {
+		t.Fatalf("cookies = %v, want the account cookie", cookies)
+	}
+
+	callback := func(state string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, DevicesCallbackPath+"?code=auth-code&state="+url.QueryEscape(state), nil)
+		req.AddCookie(cookies[0])
+		w := httptest.NewRecorder()
+		handler.HandleDevicesCallback(w, req)
+		return w
+	}
+
+	if w := callback("substituted-state"); w.Code != errDevicesSignIn.status {
+		t.Errorf("substituted state status code = %d, want %d", w.Code, errDevicesSignIn.status)
+	}
+
+	w = callback(params.Get("state"))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com"+DevicesPath {
+		t.Fatalf("callback = %d to %q, want a redirect to the devices page", w.Code, w.Header().Get("Location"))
+	}
+	req := httptest.NewRequest(http.MethodGet, DevicesPath, nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	account, err := handler.sessions.LoadAccount(req)
+	// The session is keyed by the immutable subject, the username is only shown
+	if err != nil || account.Subject != "u1" || account.Name != "alice" {
+		t.Errorf("account = %+v, %v; want u1 signed in as alice", account, err)
+	}
+}
+
+func TestDevicesListAndRevoke(t *testing.T) {
+	ctx := context.Background()
+	logger, err := audit.NewFileLogger(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("NewFileLogger failed: %v", err)
+	}
+	defer logger.Close()
+	for _, record := range []audit.Record{
+		{Action: audit.ActionApproved, ClientID: "kiosk", DeviceCodeHash: "bbbb", Subject: "alice"},
+		{Action: audit.ActionApproved, ClientID: "tv", DeviceCodeHash: "aaaa", Subject: "alice", DeviceID: "serial-42"},
+		{Action: audit.ActionApproved, ClientID: "tv", DeviceCodeHash: "cccc", Subject: "bob"},
+	} {
+		if err := logger.Record(ctx, record); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	grants := &fakeDeviceGrants{grants: map[string]*renewal.Grant{
+		"aaaa": {ClientID: "tv"},
+		"cccc": {ClientID: "tv"},
+	}}
+	var rendered templates.DevicesData
+	handler := New(Config{
+		Flow: &mockFlow{},
+		Templates: newMockTemplates().WithRenderDevices(func(w http.ResponseWriter, data templates.DevicesData) error {
+			rendered = data
+			return nil
+		}).ToTemplates(),
+		CSRF:         newMockCSRF().ToManager(),
+		BaseURL:      "https://example.com",
+		Audit:        logger,
+		DeviceGrants: grants,
+	})
+
+	w := httptest.NewRecorder()
+	if _, err := handler.sessions.SignIn(w, "alice", "Alice"); err != nil {
+		t.Fatalf("SignIn failed: %v", err)
+	}
+	accountCookie := w.Result().Cookies()[0]
+
+	req := httptest.NewRequest(http.MethodGet, DevicesPath, nil)
+	req.AddCookie(accountCookie)
+	w = httptest.NewRecorder()
+	handler.HandleDevices(w, req)
+
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", w.Header().Get("Cache-Control"))
+	}
+	if rendered.UserName != "Alice" || len(rendered.Devices) != 2 {
+		t.Fatalf("devices page = %+v, want alice's two devices", rendered)
+	}
+	if tv := rendered.Devices[0]; tv.ClientName != "tv" || tv.DeviceID != "serial-42" || !tv.Revocable {
+		t.Errorf("newest device = %+v, want the revocable tv", tv)
+	}
+	if kiosk := rendered.Devices[1]; kiosk.ClientName != "kiosk" || kiosk.Revocable {
+		t.Errorf("oldest device = %+v, want the kiosk without a grant", kiosk)
+	}
+
+	revoke := func(ref string, withAccount bool) *httptest.ResponseRecorder {
+		form := url.Values{"device": {ref}, "csrf_token": {rendered.CSRFToken}}
+		req := httptest.NewRequest(http.MethodPost, DevicesRevokePath, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if withAccount {
+			req.AddCookie(accountCookie)
+		}
+		w := httptest.NewRecorder()
+		handler.HandleRevokeDevice(w, req)
+		return w
+	}
+
+	if w := revoke("aaaa", false); w.Code != errDevicesSession.status {
+		t.Errorf("revoke without account status code = %d, want %d", w.Code, errDevicesSession.status)
+	}
+	if w := revoke("cccc", true); w.Code != errUnknownDevice.status {
+		t.Errorf("revoking another user's device status code = %d, want %d", w.Code, errUnknownDevice.status)
+	}
+	if w := revoke("aaaa", true); w.Code != http.StatusOK {
+		t.Fatalf("revoke status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if len(grants.revoked) != 1 || grants.revoked[0] != "aaaa" {
+		t.Errorf("revoked %v, want only alice's tv", grants.revoked)
+	}
+	if !strings.Contains(rendered.Message, "tv") || !rendered.Devices[0].Revoked || rendered.Devices[0].Revocable {
+		t.Errorf("devices page after revocation = %+v", rendered)
+	}
+
+	records, err := logger.List(ctx, audit.Filter{Action: audit.ActionDeviceRevoked})
+	if err != nil || len(records) != 1 || records[0].Subject != "alice" || records[0].DeviceID != "serial-42" {
+		t.Errorf("revocation audit records = %+v, %v", records, err)
+	}
+}
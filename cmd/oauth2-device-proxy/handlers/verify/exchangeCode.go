@@ -3,26 +3,121 @@ package verify
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"golang.org/x/oauth2"
+
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
 )
 
 // exchangeCode exchanges an authorization code for tokens per RFC 8628 section 3.5
 func (h *Handler) exchangeCode(ctx context.Context, code string, deviceCode *deviceflow.DeviceCode) (*deviceflow.TokenResponse, error) {
-	// Exchange code using OAuth2 config
-	token, err := h.oauth.Exchange(ctx, code)
+	return h.exchangeCodeAt(ctx, h.oauth.RedirectURL, code, deviceCode)
+}
+
+// exchangeCodeAt exchanges an authorization code issued to the given redirect
+// URI, which the token request must repeat per RFC 6749 section 4.1.3
+func (h *Handler) exchangeCodeAt(ctx context.Context, redirectURL, code string, deviceCode *deviceflow.DeviceCode) (*deviceflow.TokenResponse, error) {
+	// Route the exchange through the resilient upstream client when configured
+	if h.httpClient != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, h.httpClient)
+	}
+
+	// Exchange code using OAuth2 config with the current client secret
+	exchange := *h.oauth
+	exchange.RedirectURL = redirectURL
+	if h.clientSecret != nil {
+		exchange.ClientSecret = h.clientSecret()
+	}
+	config, opts, err := h.withClientAssertion(&exchange)
+	if err != nil {
+		return nil, fmt.Errorf("exchanging authorization code: %w", err)
+	}
+	// Parameters forwarded from the device request apply to the token request too
+	for name, value := range deviceCode.AuthParams {
+		opts = append(opts, oauth2.SetAuthURLParam(name, value))
+	}
+	// A single resource indicator is repeated on the token request per RFC 8707
+	// section 2.2. With several, the request names none and the authorization
+	// server issues a token for all resources of the grant.
+	if len(deviceCode.Resources) == 1 {
+		opts = append(opts, oauth2.SetAuthURLParam("resource", deviceCode.Resources[0]))
+	}
+	token, err := config.Exchange(ctx, code, opts...)
 	if err != nil {
+		// Relay structured token endpoint errors per RFC 6749 section 5.2
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode != "" {
+			return nil, fmt.Errorf("exchanging authorization code: %w",
+				deviceflow.MapUpstreamError(retrieveErr.ErrorCode, retrieveErr.ErrorDescription))
+		}
 		return nil, fmt.Errorf("exchanging authorization code: %w", err)
 	}
 
 	// Convert oauth2.Token to deviceflow.TokenResponse per RFC 8628
-	return &deviceflow.TokenResponse{
+	resp := &deviceflow.TokenResponse{
 		AccessToken:  token.AccessToken,
 		TokenType:    token.TokenType,
 		ExpiresIn:    int(time.Until(token.Expiry).Seconds()),
 		RefreshToken: token.RefreshToken,
 		Scope:        deviceCode.Scope,
+	}
+	granted, _ := token.Extra("scope").(string)
+	resp.Offline = oauth.IsOfflineToken(token.RefreshToken, granted)
+	if err := h.captureIDToken(ctx, token, deviceCode.Nonce, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// withClientAssertion authenticates the exchange with a client assertion per
+// RFC 7523 section 2.2 when configured. The assertion replaces the secret, so
+// the config is copied without it and the client ID is sent in the body.
+func (h *Handler) withClientAssertion(config *oauth2.Config) (*oauth2.Config, []oauth2.AuthCodeOption, error) {
+	if h.assertions == nil {
+		return config, nil, nil
+	}
+	assertion, err := h.assertions.Sign()
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing client assertion: %w", err)
+	}
+
+	withAssertion := *config
+	withAssertion.ClientSecret = ""
+	withAssertion.Endpoint.AuthStyle = oauth2.AuthStyleInParams
+	return &withAssertion, []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("client_assertion_type", oauth.ClientAssertionType),
+		oauth2.SetAuthURLParam("client_assertion", assertion),
 	}, nil
 }
+
+// captureIDToken validates the ID token returned with an OpenID Connect
+// exchange and records it with the identity it asserts. ID tokens are dropped
+// when no validator is configured so that unverified claims are never stored.
+// A nonce sent with the authorization request must come back in the ID token.
+func (h *Handler) captureIDToken(ctx context.Context, token *oauth2.Token, nonce string, resp *deviceflow.TokenResponse) error {
+	if h.idTokens == nil {
+		return nil
+	}
+
+	raw, _ := token.Extra("id_token").(string)
+	if raw == "" {
+		if nonce != "" {
+			// OpenID Connect Core 1.0 section 3.1.3.3 requires an ID token
+			return fmt.Errorf("validating ID token: %w: missing id_token", oauth.ErrInvalidToken)
+		}
+		return nil
+	}
+
+	claims, err := h.idTokens.ValidateIDToken(ctx, raw, nonce)
+	if err != nil {
+		return fmt.Errorf("validating ID token: %w", err)
+	}
+
+	resp.IDToken = raw
+	resp.Identity = &deviceflow.Identity{Subject: claims.Subject, Email: claims.Email, Name: claims.Name}
+	return nil
+}
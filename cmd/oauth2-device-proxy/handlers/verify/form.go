@@ -2,6 +2,8 @@
 package verify
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"log"
 	"net/http"
 	"net/url"
@@ -14,38 +16,39 @@ import (
 func (h *Handler) HandleForm(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	if !h.checkThrottle(w, r) {
+		return
+	}
+
 	// Generate CSRF token for security
-	token, err := h.csrf.GenerateToken(ctx)
+	token, err := h.csrf.IssueToken(w, r)
 	if err != nil {
 		// CSRF failures return 400 Bad Request per RFC 8628
-		w.WriteHeader(http.StatusBadRequest)
-		h.renderError(w, http.StatusBadRequest,
-			"Security Error",
-			"Unable to process request securely. Please try again in a moment.")
+		h.renderError(w, r, errCSRFUnavailable)
 		return
 	}
 
-	// Get prefilled code from query string
+	// Get prefilled code from query string. Clients without verification_uri_complete
+	// require manual entry, so links carrying the code are not honored for them.
 	code := r.URL.Query().Get("code")
+	if code != "" && !h.flow.AllowsCompleteURI(ctx, code) {
+		code = ""
+	}
 
 	// Prepare verification data with required URI per RFC 8628
 	baseURL, err := url.Parse(h.baseURL)
 	if err != nil {
-		h.renderError(w, http.StatusInternalServerError,
-			"Configuration Error",
-			"Invalid service configuration. Please try again later.")
+		h.renderError(w, r, errConfiguration)
 		return
 	}
 
-	// Set 200 OK status now for successful form display per RFC 8628 section 3.3
-	w.WriteHeader(http.StatusOK)
-
 	baseURL.Path = path.Join(baseURL.Path, "device")
 	verificationURI := baseURL.String()
 
 	data := templates.VerifyData{
 		PrefilledCode:   code,
 		CSRFToken:       token,
+		FormNonce:       newFormNonce(),
 		VerificationURI: verificationURI,
 	}
 
@@ -58,9 +61,33 @@ func (h *Handler) HandleForm(w http.ResponseWriter, r *http.Request) {
 			log.Printf("Warning: QR code generation failed: %v", err)
 		} else {
 			data.VerificationQRCodeSVG = qrCode
+			data.VerificationQRCodeURL = h.templates.QRCodePath(completeURI)
 		}
 	}
 
 	// Render form - errors are already logged in template renderer
-	h.renderVerify(w, data)
+	h.renderVerify(w, r, data)
+}
+
+// freshCSRFToken issues a new CSRF token for a form rendered after a
+// submission, keeping the submitted token if a new one cannot be issued
+func (h *Handler) freshCSRFToken(w http.ResponseWriter, r *http.Request) string {
+	token, err := h.csrf.IssueToken(w, r)
+	if err != nil {
+		log.Printf("Warning: failed to issue CSRF token: %v", err)
+		return r.PostFormValue("csrf_token")
+	}
+	return token
+}
+
+// newFormNonce generates a random nonce identifying a single rendering of the
+// verification form, used to detect duplicate submissions. An empty nonce only
+// disables deduplication, so random source failures are not fatal.
+func newFormNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Warning: form nonce generation failed: %v", err)
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
 }
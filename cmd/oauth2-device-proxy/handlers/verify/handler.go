@@ -2,11 +2,24 @@
 package verify
 
 import (
+	"context"
+	"crypto/rand"
+	"net/http"
+	"strings"
+
 	"golang.org/x/oauth2"
 
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/clients"
 	"github.com/wrale/oauth2-device-proxy/internal/csrf"
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/features"
+	"github.com/wrale/oauth2-device-proxy/internal/notify"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+	"github.com/wrale/oauth2-device-proxy/internal/policy"
+	"github.com/wrale/oauth2-device-proxy/internal/session"
 	"github.com/wrale/oauth2-device-proxy/internal/templates"
+	"github.com/wrale/oauth2-device-proxy/internal/throttle"
 )
 
 // Handler processes user verification flow per RFC 8628 section 3.3
@@ -14,8 +27,37 @@ type Handler struct {
 	flow      deviceflow.Flow
 	templates *templates.Templates
 	csrf      *csrf.Manager
+	sessions  *session.Manager
 	oauth     *oauth2.Config
 	baseURL   string
+	audit     audit.Logger
+	clients   *clients.Registry
+	consent   bool
+	idTokens  IDTokenValidator
+	throttle  *throttle.Limiter
+	offline   bool
+	features  *features.Set
+	policy    policy.Policy
+	notifier  notify.Notifier
+	revokeURL string
+
+	deviceGrants DeviceGrants
+
+	clientSecret     func() string
+	assertions       AssertionSigner
+	httpClient       *http.Client
+	userinfoEndpoint string
+}
+
+// AssertionSigner creates client assertions authenticating the proxy to the
+// authorization server, as oauth.AssertionSigner does
+type AssertionSigner interface {
+	Sign() (string, error)
+}
+
+// IDTokenValidator validates ID tokens returned by the authorization code exchange
+type IDTokenValidator interface {
+	ValidateIDToken(ctx context.Context, token, nonce string) (*oauth.IDTokenClaims, error)
 }
 
 // Config contains handler configuration
@@ -23,17 +65,75 @@ type Config struct {
 	Flow      deviceflow.Flow
 	Templates *templates.Templates
 	CSRF      *csrf.Manager
+	Sessions  *session.Manager // Signs the cookie binding OAuth state to a device code
 	OAuth     *oauth2.Config
 	BaseURL   string
+	Audit     audit.Logger      // Optional audit trail of authorization decisions
+	Clients   *clients.Registry // Optional client names and scope descriptions
+	Consent   bool              // Always show client and scopes for approval before redirecting
+	IDTokens  IDTokenValidator  // Optional, ID tokens are discarded unless validated
+	Throttle  *throttle.Limiter // Optional per-IP limits on code entry, separate from polling limits
+	Offline   bool              // Request offline_access for clients without an override
+	Features  *features.Set     // Optional, the ConsentScreen flag shows the consent page per client
+	Policy    policy.Policy     // Optional, decides whether authorizations may proceed
+	Notifier  notify.Notifier   // Optional, tells users about devices they authorized; must not block
+	RevokeURL string            // Where users can remove a device's access at the identity provider
+
+	// DeviceGrants optionally lets users revoke the grants the proxy holds for
+	// their devices from the devices page
+	DeviceGrants DeviceGrants
+
+	ClientSecret func() string   // Optional, returns the current OAuth client secret so rotations apply
+	Assertions   AssertionSigner // Optional, authenticates with private_key_jwt instead of the secret
+	HTTPClient   *http.Client    // Optional client for identity provider calls
+
+	// UserinfoEndpoint optionally names the user on the completion page when
+	// the exchange returned no validated ID token
+	UserinfoEndpoint string
 }
 
 // New creates a new verification flow handler
 func New(cfg Config) *Handler {
-	return &Handler{
+	h := &Handler{
 		flow:      cfg.Flow,
 		templates: cfg.Templates,
 		csrf:      cfg.CSRF,
+		sessions:  cfg.Sessions,
 		oauth:     cfg.OAuth,
 		baseURL:   cfg.BaseURL,
+		audit:     cfg.Audit,
+		clients:   cfg.Clients,
+		consent:   cfg.Consent,
+		idTokens:  cfg.IDTokens,
+		throttle:  cfg.Throttle,
+		offline:   cfg.Offline,
+		features:  cfg.Features,
+		policy:    cfg.Policy,
+		notifier:  cfg.Notifier,
+		revokeURL: cfg.RevokeURL,
+
+		deviceGrants: cfg.DeviceGrants,
+
+		clientSecret:     cfg.ClientSecret,
+		assertions:       cfg.Assertions,
+		httpClient:       cfg.HTTPClient,
+		userinfoEndpoint: cfg.UserinfoEndpoint,
+	}
+	if h.audit == nil {
+		h.audit = audit.NopLogger{}
+	}
+	if h.sessions == nil {
+		// A per-process key only suits a single instance
+		h.sessions = session.NewManager(randomKey(), session.DefaultTTL, strings.HasPrefix(h.baseURL, "https://"))
+	}
+	return h
+}
+
+// randomKey returns a fresh session signing key
+func randomKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic("verify: generating session key: " + err.Error())
 	}
+	return key
 }
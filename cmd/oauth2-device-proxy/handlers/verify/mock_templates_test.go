@@ -18,8 +18,10 @@ type mockTemplates struct {
 
 	// Mock function fields
 	renderVerify   func(w http.ResponseWriter, data templates.VerifyData) error
+	renderConsent  func(w http.ResponseWriter, data templates.ConsentData) error
 	renderError    func(w http.ResponseWriter, data templates.ErrorData) error
 	renderComplete func(w http.ResponseWriter, data templates.CompleteData) error
+	renderDevices  func(w http.ResponseWriter, data templates.DevicesData) error
 	generateQR     func(uri string) (string, error)
 
 	// Thread safety for concurrent tests
@@ -59,6 +61,17 @@ func newMockTemplates() *mockTemplates {
 	`))
 	template.Must(base.New("verify").Parse(`{{template "layout" .}}`))
 
+	template.Must(base.New("consent-title").Parse(`Approve Device`))
+	template.Must(base.New("consent-content").Parse(`
+		<p>{{.ClientName}} {{.UserCode}}</p>
+		<form method="post" action="/device/consent">
+			<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+			<input type="hidden" name="consent_ticket" value="{{.Ticket}}">
+			<button type="submit" name="action" value="approve">Approve</button>
+		</form>
+	`))
+	template.Must(base.New("consent").Parse(`{{template "layout" .}}`))
+
 	template.Must(base.New("error-title").Parse(`Error`))
 	template.Must(base.New("error-content").Parse(`Error: {{.Message}}`))
 	template.Must(base.New("error").Parse(`{{template "layout" .}}`))
@@ -67,12 +80,18 @@ func newMockTemplates() *mockTemplates {
 	template.Must(base.New("complete-content").Parse(`{{.Message}}`))
 	template.Must(base.New("complete").Parse(`{{template "layout" .}}`))
 
+	template.Must(base.New("devices-title").Parse(`My Devices`))
+	template.Must(base.New("devices-content").Parse(`{{.Message}}{{range .Devices}}<li>{{.ClientName}}</li>{{end}}`))
+	template.Must(base.New("devices").Parse(`{{template "layout" .}}`))
+
 	mock.tmpl = base
 
 	// Initialize templates
 	mock.templates.SetVerify(base)
+	mock.templates.SetConsent(base)
 	mock.templates.SetError(base)
 	mock.templates.SetComplete(base)
+	mock.templates.SetDevices(base)
 
 	return mock
 }
@@ -82,18 +101,26 @@ func (m *mockTemplates) ToTemplates() *templates.Templates {
 	t := &templates.Templates{}
 
 	t.SetVerify(m.tmpl)
+	t.SetConsent(m.tmpl)
 	t.SetComplete(m.tmpl)
+	t.SetDevices(m.tmpl)
 	t.SetError(m.tmpl)
 
 	t.SetRenderVerifyFunc(func(w http.ResponseWriter, data templates.VerifyData) error {
 		return m.RenderVerify(w, data)
 	})
+	t.SetRenderConsentFunc(func(w http.ResponseWriter, data templates.ConsentData) error {
+		return m.RenderConsent(w, data)
+	})
 	t.SetRenderErrorFunc(func(w http.ResponseWriter, data templates.ErrorData) error {
 		return m.RenderError(w, data)
 	})
 	t.SetRenderCompleteFunc(func(w http.ResponseWriter, data templates.CompleteData) error {
 		return m.RenderComplete(w, data)
 	})
+	t.SetRenderDevicesFunc(func(w http.ResponseWriter, data templates.DevicesData) error {
+		return m.RenderDevices(w, data)
+	})
 	t.SetGenerateQRCodeFunc(func(uri string) (string, error) {
 		return m.GenerateQRCode(uri)
 	})
@@ -113,6 +140,18 @@ func (m *mockTemplates) RenderVerify(w http.ResponseWriter, data templates.Verif
 	return m.defaultRender(w, "verify", data)
 }
 
+// RenderConsent renders the consent step shown before authorization
+func (m *mockTemplates) RenderConsent(w http.ResponseWriter, data templates.ConsentData) error {
+	m.mu.RLock()
+	fn := m.renderConsent
+	m.mu.RUnlock()
+
+	if fn != nil {
+		return fn(w, data)
+	}
+	return m.defaultRender(w, "consent", data)
+}
+
 // RenderError follows RFC 8628 section 3.3 user interaction requirements
 func (m *mockTemplates) RenderError(w http.ResponseWriter, data templates.ErrorData) error {
 	m.mu.RLock()
@@ -137,6 +176,18 @@ func (m *mockTemplates) RenderComplete(w http.ResponseWriter, data templates.Com
 	return m.defaultRender(w, "complete", data)
 }
 
+// RenderDevices renders the self-service devices page
+func (m *mockTemplates) RenderDevices(w http.ResponseWriter, data templates.DevicesData) error {
+	m.mu.RLock()
+	fn := m.renderDevices
+	m.mu.RUnlock()
+
+	if fn != nil {
+		return fn(w, data)
+	}
+	return m.defaultRender(w, "devices", data)
+}
+
 // GenerateQRCode follows RFC 8628 section 3.3.1 for verification_uri_complete
 func (m *mockTemplates) GenerateQRCode(uri string) (string, error) {
 	m.mu.RLock()
@@ -163,6 +214,14 @@ func (m *mockTemplates) WithRenderVerify(fn func(w http.ResponseWriter, data tem
 	return m
 }
 
+// WithRenderConsent sets the mock RenderConsent function
+func (m *mockTemplates) WithRenderConsent(fn func(w http.ResponseWriter, data templates.ConsentData) error) *mockTemplates {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.renderConsent = fn
+	return m
+}
+
 // WithRenderError sets the mock RenderError function
 func (m *mockTemplates) WithRenderError(fn func(w http.ResponseWriter, data templates.ErrorData) error) *mockTemplates {
 	m.mu.Lock()
@@ -179,6 +238,14 @@ func (m *mockTemplates) WithRenderComplete(fn func(w http.ResponseWriter, data t
 	return m
 }
 
+// WithRenderDevices sets the mock RenderDevices function
+func (m *mockTemplates) WithRenderDevices(fn func(w http.ResponseWriter, data templates.DevicesData) error) *mockTemplates {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.renderDevices = fn
+	return m
+}
+
 // WithGenerateQRCode sets the mock GenerateQRCode function
 func (m *mockTemplates) WithGenerateQRCode(fn func(uri string) (string, error)) *mockTemplates {
 	m.mu.Lock()
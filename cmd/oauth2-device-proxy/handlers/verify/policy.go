@@ -0,0 +1,76 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/policy"
+)
+
+// checkPolicy asks the authorization policy whether the flow may continue,
+// before the redirect to the identity provider when token is nil and before
+// the token is stored otherwise. Denials end the flow with access_denied;
+// when no decision can be made the user may try again. It reports whether
+// the caller may continue, having rendered the outcome otherwise.
+func (h *Handler) checkPolicy(w http.ResponseWriter, r *http.Request, code *deviceflow.DeviceCode, token *deviceflow.TokenResponse) bool {
+	if h.policy == nil {
+		return true
+	}
+
+	input := policy.Input{
+		Stage:    policy.StageRedirect,
+		ClientID: code.ClientID,
+		Scope:    code.Scope,
+		RemoteIP: common.ClientIP(r),
+	}
+	if code.Device != nil {
+		input.DeviceID = code.Device.ID
+	}
+	var subject string
+	if token != nil {
+		subject = approvingSubject(token)
+		input.Stage = policy.StageToken
+		input.Subject = subject
+		input.Claims = tokenClaims(token.AccessToken)
+	}
+
+	decision, err := policy.Evaluate(r.Context(), h.policy, input)
+	if err != nil {
+		log.Printf("Error: authorization policy unavailable: %v", err)
+		h.renderError(w, r, errPolicyUnavailable)
+		return false
+	}
+	if decision.Allow {
+		return true
+	}
+
+	denial := deviceflow.NewDeviceFlowError(deviceflow.ErrorCodeAccessDenied, "The authorization was refused by policy")
+	if err := h.flow.FailAuthorization(r.Context(), code.DeviceCode, denial); err != nil {
+		log.Printf("Error: failed to record policy denial: %v", err)
+	}
+	record := auditRecord(r, audit.ActionPolicyDenied, code, subject)
+	record.Reason = decision.Reason
+	h.writeAudit(r, record)
+
+	h.renderError(w, r, errPolicyDenied)
+	return false
+}
+
+// tokenClaims returns the claims of a JWT access token for policy decisions,
+// nil for opaque tokens
+func tokenClaims(accessToken string) map[string]any {
+	payload := tokenPayload(accessToken)
+	if payload == nil {
+		return nil
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil
+	}
+	return claims
+}
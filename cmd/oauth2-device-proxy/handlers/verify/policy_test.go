@@ -0,0 +1,186 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/policy"
+)
+
+// groupPolicy allows users in a group at the token stage, and fails or denies
+// redirects as configured
+type groupPolicy struct {
+	group       string
+	redirectErr error
+	denyAll     bool
+	inputs      []policy.Input
+}
+
+func (p *groupPolicy) Decide(ctx context.Context, input policy.Input) (policy.Decision, error) {
+	p.inputs = append(p.inputs, input)
+	if p.denyAll {
+		return policy.Decision{Reason: "outside business hours"}, nil
+	}
+	if input.Stage == policy.StageRedirect {
+		return policy.Decision{Allow: p.redirectErr == nil}, p.redirectErr
+	}
+	groups, _ := input.Claims["groups"].([]any)
+	for _, group := range groups {
+		if group == p.group {
+			return policy.Decision{Allow: true}, nil
+		}
+	}
+	return policy.Decision{Reason: "user not in group " + p.group}, nil
+}
+
+func TestVerifyHandler_PolicyRedirect(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     *groupPolicy
+		wantStatus int
+		wantFailed bool
+	}{
+		{name: "allowed", policy: &groupPolicy{}, wantStatus: http.StatusFound},
+		{name: "denied", policy: &groupPolicy{denyAll: true}, wantStatus: http.StatusForbidden, wantFailed: true},
+		{name: "unavailable", policy: &groupPolicy{redirectErr: errors.New("timeout")}, wantStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow := &mockFlow{}
+			var failedWith string
+			flow.FailAuthFunc = func(ctx context.Context, deviceCode string, failure *deviceflow.DeviceFlowError) error {
+				failedWith = failure.Code
+				return nil
+			}
+			auditLog := &recordingAudit{}
+			handler := New(Config{
+				Flow:      flow,
+				Templates: newMockTemplates().ToTemplates(),
+				CSRF:      newMockCSRF().ToManager(),
+				OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}},
+				BaseURL:   "https://example.com",
+				Audit:     auditLog,
+				Policy:    tt.policy,
+			})
+
+			code := &deviceflow.DeviceCode{
+				DeviceCode: "device-123",
+				ClientID:   "tv",
+				Scope:      "profile",
+				Device:     &deviceflow.DeviceIdentity{ID: "serial-1"},
+			}
+			w := httptest.NewRecorder()
+			handler.redirectToAuthorization(w, httptest.NewRequest(http.MethodPost, "/device", nil), code, "state")
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if len(tt.policy.inputs) != 1 {
+				t.Fatalf("policy consulted %d times, want once", len(tt.policy.inputs))
+			}
+			input := tt.policy.inputs[0]
+			if input.Stage != policy.StageRedirect || input.ClientID != "tv" || input.DeviceID != "serial-1" || input.Subject != "" {
+				t.Errorf("policy input = %+v", input)
+			}
+			if tt.wantFailed != (failedWith == deviceflow.ErrorCodeAccessDenied) {
+				t.Errorf("flow failed with %q, want denial %v", failedWith, tt.wantFailed)
+			}
+			if tt.wantFailed {
+				if len(auditLog.records) != 1 || auditLog.records[0].Action != audit.ActionPolicyDenied ||
+					auditLog.records[0].Reason != "outside business hours" {
+					t.Errorf("audit records = %+v, want policy denial", auditLog.records)
+				}
+			} else if len(auditLog.records) != 0 {
+				t.Errorf("unexpected audit records %+v", auditLog.records)
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_PolicyToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		groups     string
+		wantStatus int
+		wantStored bool
+	}{
+		{name: "user in group", groups: `["tv-admins"]`, wantStatus: http.StatusOK, wantStored: true},
+		{name: "user not in group", groups: `["staff"]`, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := base64.RawURLEncoding.EncodeToString(
+				[]byte(`{"sub":"u1","preferred_username":"alice","groups":` + tt.groups + `}`))
+			accessToken := "e30." + claims + ".sig"
+			tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"access_token":"` + accessToken + `","token_type":"Bearer","expires_in":3600}`))
+			}))
+			defer tokenServer.Close()
+
+			var stored bool
+			var failedWith string
+			flow := &mockFlow{
+				getDeviceCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+					return &deviceflow.DeviceCode{DeviceCode: code, ClientID: "tv"}, nil
+				},
+				completeAuthorization: func(ctx context.Context, code string, token *deviceflow.TokenResponse) error {
+					stored = true
+					return nil
+				},
+			}
+			flow.FailAuthFunc = func(ctx context.Context, deviceCode string, failure *deviceflow.DeviceFlowError) error {
+				failedWith = failure.Code
+				return nil
+			}
+
+			groups := &groupPolicy{group: "tv-admins"}
+			auditLog := &recordingAudit{}
+			handler := New(Config{
+				Flow:      flow,
+				Templates: newMockTemplates().ToTemplates(),
+				CSRF:      newMockCSRF().ToManager(),
+				OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL}},
+				BaseURL:   "https://example.com",
+				Audit:     auditLog,
+				Policy:    groups,
+			})
+
+			sess, cookie := startSession(t, handler, "device-123")
+			req := httptest.NewRequest(http.MethodGet, "/device/complete?state="+sess.State+"&code=auth-code", nil)
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			handler.HandleComplete(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if stored != tt.wantStored {
+				t.Errorf("token stored = %v, want %v", stored, tt.wantStored)
+			}
+			if len(groups.inputs) != 1 || groups.inputs[0].Stage != policy.StageToken || groups.inputs[0].Subject != "u1" {
+				t.Fatalf("policy inputs = %+v", groups.inputs)
+			}
+			if !tt.wantStored {
+				if failedWith != deviceflow.ErrorCodeAccessDenied {
+					t.Errorf("flow failed with %q, want access_denied", failedWith)
+				}
+				if len(auditLog.records) != 1 || auditLog.records[0].Action != audit.ActionPolicyDenied ||
+					auditLog.records[0].Subject != "u1" || auditLog.records[0].Reason != "user not in group tv-admins" {
+					t.Errorf("audit records = %+v, want policy denial of alice", auditLog.records)
+				}
+			}
+		})
+	}
+}
@@ -2,9 +2,14 @@
 package verify
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
 
 	"github.com/wrale/oauth2-device-proxy/internal/templates"
 )
@@ -56,27 +61,65 @@ func (w *responseWriter) SetContentType(contentType string) {
 	}
 }
 
-// renderError handles error page rendering per RFC 8628 section 3.3
-func (h *Handler) renderError(w http.ResponseWriter, status int, title, message string) {
+// renderError renders a catalog error page per RFC 8628 section 3.3, or its
+// JSON form for API consumers that asked for one
+func (h *Handler) renderError(w http.ResponseWriter, r *http.Request, e pageError) {
+	id := correlationID(r)
+	if e.status >= http.StatusBadRequest {
+		log.Printf("Verification error %s (status %d, correlation ID %s)", e.code, e.status, id)
+	}
+	label, target := h.retryLink(e)
+
+	if wantsJSON(r) {
+		h.writeJSON(w, e.status, errorBody{
+			Error:            e.code,
+			ErrorDescription: e.message,
+			CorrelationID:    id,
+			RetryURI:         target,
+		})
+		return
+	}
+
 	// Wrap the writer to ensure proper header handling
 	rw := newResponseWriter(w)
 
 	// Set status before any writing per RFC 8628
-	rw.WriteHeader(status)
+	rw.WriteHeader(e.status)
 
 	// Render template with wrapped writer
 	if err := h.templates.RenderError(rw, templates.ErrorData{
-		Title:   title,
-		Message: message,
+		Title:         e.title,
+		Message:       e.message,
+		Code:          e.code,
+		CorrelationID: id,
+		RetryURL:      target,
+		RetryLabel:    label,
+		Final:         e.retry == retryNone,
 	}); err != nil {
 		log.Printf("Failed to render error page: %v", err)
 		// Writer ensures proper header state
-		h.writeResponse(rw, status, fmt.Sprintf("%s: %s", title, message))
+		h.writeResponse(rw, e.status, fmt.Sprintf("%s: %s (%s)", e.title, e.message, e.code))
 	}
 }
 
 // renderVerify handles verify form rendering per RFC 8628 section 3.3
-func (h *Handler) renderVerify(w http.ResponseWriter, data templates.VerifyData) {
+func (h *Handler) renderVerify(w http.ResponseWriter, r *http.Request, data templates.VerifyData) {
+	if wantsJSON(r) {
+		if data.Error != "" {
+			e := errInvalidCode
+			e.message = data.Error
+			h.renderError(w, r, e)
+			return
+		}
+		h.writeJSON(w, http.StatusOK, formBody{
+			VerificationURI: data.VerificationURI,
+			UserCode:        data.PrefilledCode,
+			CSRFToken:       data.CSRFToken,
+			FormNonce:       data.FormNonce,
+		})
+		return
+	}
+
 	// Wrap the writer to ensure proper header handling
 	rw := newResponseWriter(w)
 
@@ -91,6 +134,48 @@ func (h *Handler) renderVerify(w http.ResponseWriter, data templates.VerifyData)
 	}
 }
 
+// errorBody is the JSON form of an error page
+type errorBody struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+	CorrelationID    string `json:"correlation_id"`
+	RetryURI         string `json:"retry_uri,omitempty"`
+}
+
+// formBody is the JSON form of the verification page, carrying the fields
+// an API consumer must post back with the user code
+type formBody struct {
+	VerificationURI string `json:"verification_uri"`
+	UserCode        string `json:"user_code,omitempty"`
+	CSRFToken       string `json:"csrf_token"`
+	FormNonce       string `json:"form_nonce"`
+}
+
+// wantsJSON reports whether the client asked for JSON rather than HTML.
+// Browsers list text/html, so only API consumers are answered with JSON.
+func wantsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+// correlationID identifies the request in server logs, generating an ID when
+// the request ID middleware did not assign one
+func correlationID(r *http.Request) string {
+	if id := middleware.GetReqID(r.Context()); id != "" {
+		return id
+	}
+	return newFormNonce()
+}
+
+// writeJSON sends a JSON response that is never cached
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, body any) {
+	common.SetJSONHeaders(w)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to write JSON response: %v", err)
+	}
+}
+
 // writeResponse writes a response safely per RFC 8628
 func (h *Handler) writeResponse(w http.ResponseWriter, status int, message string) {
 	// Ensure we have a properly wrapped writer
@@ -0,0 +1,36 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
+)
+
+// ShortLinkPrefix is the path of the shortened verification route, for use in
+// deviceflow complete URI templates such as BASE_URL + "/a/{user_code}"
+const ShortLinkPrefix = "/a/"
+
+// HandleShortLink serves shortened verification links such as /a/WDJB-MJHT.
+// Companion apps registered for the link intercept it; browsers are redirected
+// to the verification form with the code prefilled per RFC 8628 section 3.3.1.
+func (h *Handler) HandleShortLink(w http.ResponseWriter, r *http.Request) {
+	target, err := url.Parse(h.baseURL)
+	if err != nil {
+		h.renderError(w, r, errConfiguration)
+		return
+	}
+	target.Path = path.Join(target.Path, "device")
+
+	// Malformed codes fall back to manual entry rather than an error page. Links
+	// carry codes in the display format, which may differ from the issued one.
+	if code := chi.URLParam(r, "code"); validation.ValidateUserCode(validation.CanonicalCode(code)) == nil {
+		target.RawQuery = url.Values{"code": {code}}.Encode()
+	}
+
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
@@ -0,0 +1,59 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/oauth2"
+)
+
+func TestVerifyHandler_HandleShortLink(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		wantLocation string
+	}{
+		{
+			name:         "valid code is prefilled",
+			path:         "/a/BCDF-GHJK",
+			wantLocation: "https://example.com/device?code=BCDF-GHJK",
+		},
+		{
+			name:         "display format is kept",
+			path:         "/a/BC.DF.GH.JK",
+			wantLocation: "https://example.com/device?code=BC.DF.GH.JK",
+		},
+		{
+			name:         "malformed code falls back to manual entry",
+			path:         "/a/not-a-code",
+			wantLocation: "https://example.com/device",
+		},
+	}
+
+	handler := New(Config{
+		Flow:      &mockFlow{},
+		Templates: newMockTemplates().ToTemplates(),
+		CSRF:      newMockCSRF().ToManager(),
+		OAuth:     &oauth2.Config{},
+		BaseURL:   "https://example.com",
+	})
+	router := chi.NewRouter()
+	router.Get(ShortLinkPrefix+"{code}", handler.HandleShortLink)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if w.Code != http.StatusFound {
+				t.Errorf("status code = %d, want %d", w.Code, http.StatusFound)
+			}
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", got, tt.wantLocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
+)
+
+// statusUnknown is reported when the browser has no session for the code, so
+// the endpoint cannot be used to discover which user codes are in use
+const statusUnknown = "unknown"
+
+// statusResponse is the JSON body returned by HandleStatus
+type statusResponse struct {
+	Status string `json:"status"`
+}
+
+// HandleStatus reports the progress of the flow being verified in this browser
+// so that open verification pages can follow a sign-in finished in another tab.
+// Only the device code named by the session cookie is reported, and only when
+// the requested user code matches it.
+func (h *Handler) HandleStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userCode := r.URL.Query().Get("code")
+	if userCode == "" {
+		common.WriteError(w, deviceflow.ErrorCodeInvalidRequest, "Missing user code")
+		return
+	}
+
+	sess, err := h.sessions.Load(r)
+	if err != nil {
+		writeStatus(w, http.StatusNotFound, statusUnknown)
+		return
+	}
+
+	status, err := h.flow.GetStatus(ctx, sess.DeviceCode)
+	if err != nil {
+		common.WriteError(w, deviceflow.ErrorCodeServerError, "Unable to check authorization status")
+		return
+	}
+
+	// An expired code can no longer be matched to its user code, and reporting
+	// it reveals nothing beyond the session's own device
+	if status != deviceflow.StatusExpired {
+		dCode, err := h.flow.GetDeviceCode(ctx, sess.DeviceCode)
+		if err != nil || validation.NormalizeCode(dCode.UserCode) != validation.NormalizeCode(userCode) {
+			writeStatus(w, http.StatusNotFound, statusUnknown)
+			return
+		}
+	}
+
+	writeStatus(w, http.StatusOK, string(status))
+}
+
+// writeStatus sends a status response that is never cached
+func writeStatus(w http.ResponseWriter, code int, status string) {
+	common.SetJSONHeaders(w)
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(statusResponse{Status: status}); err != nil {
+		common.WriteJSONError(w, err)
+	}
+}
@@ -0,0 +1,144 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+func TestVerifyHandler_HandleStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      string
+		cookie     bool // Send the session cookie for device-123
+		status     deviceflow.Status
+		statusErr  error
+		wantCode   int
+		wantStatus string
+	}{
+		{
+			name:       "pending",
+			query:      "?code=BCDF-GHJK",
+			cookie:     true,
+			status:     deviceflow.StatusPending,
+			wantCode:   http.StatusOK,
+			wantStatus: "pending",
+		},
+		{
+			name:       "authorized in another tab",
+			query:      "?code=bcdfghjk",
+			cookie:     true,
+			status:     deviceflow.StatusAuthorized,
+			wantCode:   http.StatusOK,
+			wantStatus: "authorized",
+		},
+		{
+			name:       "denied",
+			query:      "?code=BCDF-GHJK",
+			cookie:     true,
+			status:     deviceflow.StatusDenied,
+			wantCode:   http.StatusOK,
+			wantStatus: "denied",
+		},
+		{
+			name:       "expired",
+			query:      "?code=BCDF-GHJK",
+			cookie:     true,
+			status:     deviceflow.StatusExpired,
+			wantCode:   http.StatusOK,
+			wantStatus: "expired",
+		},
+		{
+			name:       "no session",
+			query:      "?code=BCDF-GHJK",
+			status:     deviceflow.StatusPending,
+			wantCode:   http.StatusNotFound,
+			wantStatus: statusUnknown,
+		},
+		{
+			name:       "code from another flow",
+			query:      "?code=WXYZ-MNPQ",
+			cookie:     true,
+			status:     deviceflow.StatusAuthorized,
+			wantCode:   http.StatusNotFound,
+			wantStatus: statusUnknown,
+		},
+		{
+			name:     "missing code",
+			cookie:   true,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:      "store error",
+			query:     "?code=BCDF-GHJK",
+			cookie:    true,
+			statusErr: errors.New("redis down"),
+			wantCode:  http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			polled := false
+			flow := &mockFlow{
+				getDeviceCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+					return &deviceflow.DeviceCode{DeviceCode: code, UserCode: "BCDF-GHJK"}, nil
+				},
+				checkDeviceCode: func(ctx context.Context, deviceCode string) (*deviceflow.TokenResponse, error) {
+					polled = true
+					return nil, deviceflow.ErrPendingAuthorization
+				},
+			}
+			flow.GetStatusFunc = func(ctx context.Context, deviceCode string) (deviceflow.Status, error) {
+				if deviceCode != "device-123" {
+					t.Errorf("status requested for %q, want device-123", deviceCode)
+				}
+				return tt.status, tt.statusErr
+			}
+
+			handler := New(Config{
+				Flow:      flow,
+				Templates: newMockTemplates().ToTemplates(),
+				CSRF:      newMockCSRF().ToManager(),
+				OAuth:     &oauth2.Config{},
+				BaseURL:   "https://example.com",
+			})
+
+			_, cookie := startSession(t, handler, "device-123")
+			req := httptest.NewRequest(http.MethodGet, "/device/status"+tt.query, nil)
+			if tt.cookie {
+				req.AddCookie(cookie)
+			}
+			w := httptest.NewRecorder()
+			handler.HandleStatus(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantCode)
+			}
+			if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("Cache-Control = %q, want no-store", cc)
+			}
+			if polled {
+				t.Error("status lookup counted as a device poll")
+			}
+
+			if tt.wantStatus == "" {
+				return
+			}
+			var body statusResponse
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("decoding response: %v", err)
+			}
+			if body.Status != tt.wantStatus {
+				t.Errorf("status = %q, want %q", body.Status, tt.wantStatus)
+			}
+		})
+	}
+}
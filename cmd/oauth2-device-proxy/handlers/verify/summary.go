@@ -0,0 +1,132 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/notify"
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+)
+
+// maxUserinfoResponse bounds how much of a userinfo response is read
+const maxUserinfoResponse = 1 << 20
+
+// userClaims are the claims naming the signed-in user
+type userClaims struct {
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+}
+
+// completionSummary describes an authorization for the completion page, so
+// users can confirm they authorized the device they expected
+func (h *Handler) completionSummary(ctx context.Context, code *deviceflow.DeviceCode, token *deviceflow.TokenResponse) *templates.CompleteSummary {
+	user := h.signedInUser(ctx, token)
+	summary := &templates.CompleteSummary{
+		UserName:   user.Name,
+		UserEmail:  user.Email,
+		ClientName: h.clients.Metadata(ctx, code.ClientID).Name,
+		UserCode:   code.UserCode,
+		Origin:     requestOrigin(code),
+	}
+	if summary.UserName == "" {
+		summary.UserName = user.PreferredUsername
+	}
+	if code.Device != nil {
+		summary.DeviceID = code.Device.ID
+	}
+	return summary
+}
+
+// notifyAuthorized tells the user a device was linked to their account, with
+// a link to remove its access in case they were tricked into approving it
+func (h *Handler) notifyAuthorized(r *http.Request, code *deviceflow.DeviceCode, token *deviceflow.TokenResponse, summary *templates.CompleteSummary) {
+	if h.notifier == nil {
+		return
+	}
+
+	n := notify.Notification{
+		Recipient:       summary.UserEmail,
+		UserName:        summary.UserName,
+		ClientID:        code.ClientID,
+		ClientName:      summary.ClientName,
+		UserCode:        code.UserCode,
+		DeviceID:        summary.DeviceID,
+		RequestIP:       code.RequestIP,
+		RequestLocation: code.RequestLocation,
+		ApproverIP:      common.ClientIP(r),
+		AuthorizedAt:    code.AuthorizedAt,
+		RevokeURL:       h.revokeURL,
+	}
+	if token.Identity != nil {
+		n.Subject = token.Identity.Subject
+	} else if sub, ok := tokenClaims(token.AccessToken)["sub"].(string); ok {
+		n.Subject = sub
+	}
+	if n.AuthorizedAt.IsZero() {
+		n.AuthorizedAt = time.Now()
+	}
+	if err := h.notifier.Notify(r.Context(), n); err != nil {
+		log.Printf("Warning: authorization notification not sent: %v", err)
+	}
+}
+
+// signedInUser names the user who approved the device from the validated ID
+// token, the userinfo endpoint when configured, or the access token's claims
+func (h *Handler) signedInUser(ctx context.Context, token *deviceflow.TokenResponse) userClaims {
+	if token.Identity != nil && (token.Identity.Name != "" || token.Identity.Email != "") {
+		return userClaims{Name: token.Identity.Name, Email: token.Identity.Email}
+	}
+
+	if h.userinfoEndpoint != "" {
+		user, err := h.fetchUserinfo(ctx, token.AccessToken)
+		if err == nil {
+			return user
+		}
+		log.Printf("Warning: userinfo lookup failed: %v", err)
+	}
+
+	var user userClaims
+	if payload := tokenPayload(token.AccessToken); payload != nil {
+		_ = json.Unmarshal(payload, &user) // Opaque or malformed tokens leave the user unnamed
+	}
+	return user
+}
+
+// fetchUserinfo asks the OpenID Connect userinfo endpoint about the user per
+// OpenID Connect Core 1.0 section 5.3
+func (h *Handler) fetchUserinfo(ctx context.Context, accessToken string) (userClaims, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.userinfoEndpoint, nil)
+	if err != nil {
+		return userClaims{}, fmt.Errorf("creating userinfo request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	client := h.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return userClaims{}, fmt.Errorf("requesting userinfo: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return userClaims{}, fmt.Errorf("requesting userinfo: %s", resp.Status)
+	}
+
+	var user userClaims
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxUserinfoResponse)).Decode(&user); err != nil {
+		return userClaims{}, fmt.Errorf("parsing userinfo: %w", err)
+	}
+	return user, nil
+}
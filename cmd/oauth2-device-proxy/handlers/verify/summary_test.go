@@ -0,0 +1,138 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/notify"
+)
+
+func TestCompletionSummary(t *testing.T) {
+	userinfo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer opaque-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"sub":"u1","name":"Userinfo User","email":"info@example.com"}`))
+	}))
+	defer userinfo.Close()
+
+	jwt := "e30." + base64.RawURLEncoding.EncodeToString(
+		[]byte(`{"sub":"u1","preferred_username":"alice","email":"alice@example.com"}`)) + ".sig"
+
+	tests := []struct {
+		name      string
+		userinfo  string
+		token     *deviceflow.TokenResponse
+		wantName  string
+		wantEmail string
+	}{
+		{
+			name:      "validated ID token",
+			userinfo:  userinfo.URL,
+			token:     &deviceflow.TokenResponse{AccessToken: "opaque-token", Identity: &deviceflow.Identity{Subject: "u1", Name: "ID Token User", Email: "id@example.com"}},
+			wantName:  "ID Token User",
+			wantEmail: "id@example.com",
+		},
+		{
+			name:      "userinfo endpoint",
+			userinfo:  userinfo.URL,
+			token:     &deviceflow.TokenResponse{AccessToken: "opaque-token"},
+			wantName:  "Userinfo User",
+			wantEmail: "info@example.com",
+		},
+		{
+			name:      "access token claims when userinfo fails",
+			userinfo:  userinfo.URL,
+			token:     &deviceflow.TokenResponse{AccessToken: jwt},
+			wantName:  "alice",
+			wantEmail: "alice@example.com",
+		},
+		{
+			name:  "opaque token without userinfo",
+			token: &deviceflow.TokenResponse{AccessToken: "opaque-token"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := New(Config{
+				Flow:             &mockFlow{},
+				Templates:        newMockTemplates().ToTemplates(),
+				BaseURL:          "https://example.com",
+				UserinfoEndpoint: tt.userinfo,
+			})
+			code := &deviceflow.DeviceCode{
+				DeviceCode: "device-123",
+				UserCode:   "WDJB-MJHT",
+				ClientID:   "living-room-tv",
+				Device:     &deviceflow.DeviceIdentity{ID: "serial-42"},
+				RequestIP:  "203.0.113.7",
+			}
+
+			summary := handler.completionSummary(context.Background(), code, tt.token)
+			if summary.UserName != tt.wantName || summary.UserEmail != tt.wantEmail {
+				t.Errorf("user = %q <%s>, want %q <%s>", summary.UserName, summary.UserEmail, tt.wantName, tt.wantEmail)
+			}
+			if summary.ClientName != "living-room-tv" || summary.UserCode != "WDJB-MJHT" ||
+				summary.DeviceID != "serial-42" || summary.Origin != "203.0.113.7" {
+				t.Errorf("summary = %+v", summary)
+			}
+		})
+	}
+}
+
+// recordingNotifier captures notifications sent by the handler
+type recordingNotifier struct {
+	sent []notify.Notification
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, n notify.Notification) error {
+	r.sent = append(r.sent, n)
+	return nil
+}
+
+func TestNotifyAuthorized(t *testing.T) {
+	notifier := &recordingNotifier{}
+	handler := New(Config{
+		Flow:      &mockFlow{},
+		Templates: newMockTemplates().ToTemplates(),
+		BaseURL:   "https://example.com",
+		Notifier:  notifier,
+		RevokeURL: "https://sso.example.com/realms/main/account/applications",
+	})
+
+	code := &deviceflow.DeviceCode{
+		DeviceCode:      "device-123",
+		UserCode:        "WDJB-MJHT",
+		ClientID:        "living-room-tv",
+		RequestIP:       "203.0.113.7",
+		RequestLocation: "Berlin, DE",
+	}
+	token := &deviceflow.TokenResponse{
+		AccessToken: "opaque-token",
+		Identity:    &deviceflow.Identity{Subject: "user-1", Name: "Alice", Email: "alice@example.com"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/device/complete", nil)
+	req.RemoteAddr = "198.51.100.2:4321"
+
+	handler.notifyAuthorized(req, code, token, handler.completionSummary(req.Context(), code, token))
+
+	if len(notifier.sent) != 1 {
+		t.Fatalf("sent %d notifications, want 1", len(notifier.sent))
+	}
+	n := notifier.sent[0]
+	if n.Recipient != "alice@example.com" || n.UserName != "Alice" || n.Subject != "user-1" {
+		t.Errorf("notification user = %+v", n)
+	}
+	if n.ClientName != "living-room-tv" || n.RequestIP != "203.0.113.7" || n.RequestLocation != "Berlin, DE" ||
+		n.ApproverIP != "198.51.100.2" || n.RevokeURL == "" || n.AuthorizedAt.IsZero() {
+		t.Errorf("notification = %+v", n)
+	}
+}
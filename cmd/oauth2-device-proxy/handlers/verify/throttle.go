@@ -0,0 +1,61 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+)
+
+// checkThrottle applies the per-IP limits on the verification pages, showing
+// the lockout page and returning false when the request must not proceed.
+// Store failures let requests through so that an outage does not lock
+// everyone out; device code rate limits still apply.
+func (h *Handler) checkThrottle(w http.ResponseWriter, r *http.Request) bool {
+	if h.throttle == nil {
+		return true
+	}
+
+	wait, err := h.throttle.Allow(r.Context(), common.ClientIP(r))
+	if err != nil {
+		log.Printf("Warning: verification throttling unavailable: %v", err)
+		return true
+	}
+	if wait <= 0 {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds()+0.5)))
+	h.renderError(w, r, errTooManyAttempts)
+	return false
+}
+
+// recordFailedEntry counts a rejected user code against the requesting IP,
+// auditing the lockout when this failure triggers one
+func (h *Handler) recordFailedEntry(r *http.Request) {
+	if h.throttle == nil {
+		return
+	}
+
+	ip := common.ClientIP(r)
+	locked, err := h.throttle.Fail(r.Context(), ip)
+	if err != nil {
+		log.Printf("Warning: failed to count rejected user code: %v", err)
+		return
+	}
+	if !locked {
+		return
+	}
+
+	log.Printf("Verification locked out for %s after repeated invalid codes", ip)
+	if err := h.audit.Record(r.Context(), audit.Record{
+		Action:    audit.ActionLockedOut,
+		RemoteIP:  ip,
+		UserAgent: r.UserAgent(),
+	}); err != nil {
+		log.Printf("Error: failed to record %s audit entry: %v", audit.ActionLockedOut, err)
+	}
+}
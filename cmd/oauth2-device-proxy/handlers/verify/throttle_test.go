@@ -0,0 +1,87 @@
+// Package verify provides verification flow handlers per RFC 8628 section 3.3
+package verify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/throttle"
+)
+
+func TestVerifyHandler_Throttle(t *testing.T) {
+	flow := &mockFlow{
+		verifyUserCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+			return nil, deviceflow.ErrInvalidUserCode
+		},
+	}
+	auditLog := &recordingAudit{}
+	csrfManager := newMockCSRF().ToManager()
+	handler := New(Config{
+		Flow:      flow,
+		Templates: newMockTemplates().ToTemplates(),
+		CSRF:      csrfManager,
+		BaseURL:   "https://example.com",
+		Audit:     auditLog,
+		Throttle: throttle.New(throttle.Config{
+			Store:       throttle.NewMemoryStore(),
+			MaxRequests: 100,
+			MaxFailures: 3,
+			Lockout:     time.Minute,
+		}),
+	})
+
+	submit := func(remoteAddr string) *httptest.ResponseRecorder {
+		token, err := csrfManager.GenerateToken(context.Background())
+		if err != nil {
+			t.Fatalf("GenerateToken failed: %v", err)
+		}
+		form := url.Values{"code": {"BCDF-GHJK"}, "csrf_token": {token}}
+		req := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		handler.HandleSubmit(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := submit("192.0.2.1:1234"); w.Code == http.StatusTooManyRequests {
+			t.Fatalf("attempt %d locked out before the failure limit", i+1)
+		}
+	}
+
+	// The third failure locked the IP out of both pages
+	w := submit("192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status after lockout = %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("lockout page without Retry-After")
+	}
+	req := httptest.NewRequest(http.MethodGet, "/device", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	form := httptest.NewRecorder()
+	handler.HandleForm(form, req)
+	if form.Code != http.StatusTooManyRequests {
+		t.Errorf("form status after lockout = %d, want %d", form.Code, http.StatusTooManyRequests)
+	}
+
+	// Other networks are unaffected
+	if w := submit("198.51.100.7:1234"); w.Code == http.StatusTooManyRequests {
+		t.Error("lockout applied to another IP")
+	}
+
+	if len(auditLog.records) != 1 || auditLog.records[0].Action != audit.ActionLockedOut {
+		t.Fatalf("audit records = %+v, want one lockout", auditLog.records)
+	}
+	if got := auditLog.records[0].RemoteIP; got != "192.0.2.1" {
+		t.Errorf("lockout audit IP = %q, want 192.0.2.1", got)
+	}
+}
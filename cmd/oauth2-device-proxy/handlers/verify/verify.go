@@ -2,68 +2,275 @@
 package verify
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
 	"github.com/wrale/oauth2-device-proxy/internal/templates"
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
+)
+
+const (
+	// msgInvalidCode is shown when the submitted user code cannot be verified
+	msgInvalidCode = "The code you entered is invalid or has expired. Please check the code and try again."
+
+	// msgUsedCode and msgRevokedCode are shown for user codes that are known
+	// to be gone, so that users start over instead of retyping them
+	msgUsedCode    = "This code has already been used. Please start again on your device to get a new code."
+	msgRevokedCode = "This code is no longer valid. Please start again on your device to get a new code."
+
+	// msgSessionExpired is shown when a form's CSRF token is rejected
+	msgSessionExpired = "Your session has expired. Please try again."
+
+	// submissionWaitTimeout bounds how long a duplicate submission waits for the original
+	submissionWaitTimeout = 3 * time.Second
+
+	// submissionPollInterval is the delay between checks for the original result
+	submissionPollInterval = 100 * time.Millisecond
+
+	// callbackStateSeparator divides the session state from the callback nonce
+	callbackStateSeparator = "."
 )
 
 // HandleSubmit processes the verification form submission per RFC 8628 section 3.3
 func (h *Handler) HandleSubmit(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	// Parse form first to get input
-	if err := r.ParseForm(); err != nil {
-		// Client error (400) per RFC 8628 section 3.3
-		h.renderError(w, http.StatusBadRequest,
-			"Invalid Request",
-			"Unable to process form submission. Please try again.")
+	if !h.checkThrottle(w, r) {
 		return
 	}
 
-	// CSRF validation is input validation per RFC 8628 section 3.3
-	if err := h.csrf.ValidateToken(ctx, r.PostFormValue("csrf_token")); err != nil {
-		h.renderError(w, http.StatusBadRequest,
-			"Security Error",
-			"Your session has expired. Please try again.")
+	// Parse form first to get input
+	if err := r.ParseForm(); err != nil {
+		// Client error (400) per RFC 8628 section 3.3
+		h.renderError(w, r, errInvalidForm)
 		return
 	}
 
 	// Missing code is a client error per RFC 8628
 	code := r.PostFormValue("code")
 	if code == "" {
-		h.renderError(w, http.StatusBadRequest,
-			"Missing Code",
-			"Please enter the code shown on your device.")
+		h.renderError(w, r, errMissingCode)
+		return
+	}
+
+	// Answer duplicate posts of the same form with the original result so that
+	// double-clicks don't re-run verification or count twice against rate limits.
+	// This precedes CSRF validation because the original consumed the token, so
+	// duplicates are keyed by the token and only recognised where it was issued.
+	token := r.PostFormValue("csrf_token")
+	nonce := h.submissionKey(r, token)
+	if prior := h.awaitSubmission(ctx, nonce); prior != nil {
+		h.replaySubmission(w, r, code, prior)
+		return
+	}
+
+	// CSRF validation is input validation per RFC 8628 section 3.3. Tokens
+	// are single use, so a captured form post cannot be submitted again.
+	if err := h.csrf.ConsumeRequest(r, token); err != nil {
+		h.recordSubmission(ctx, nonce, &deviceflow.SubmissionResult{Error: msgSessionExpired})
+		h.renderError(w, r, errSessionExpired)
 		return
 	}
 
 	// Verify the user code
 	deviceCode, err := h.flow.VerifyUserCode(ctx, code)
 	if err != nil {
+		message := codeErrorMessage(err)
+		h.recordSubmission(ctx, nonce, &deviceflow.SubmissionResult{Error: message})
+		h.recordFailedEntry(r)
+
 		// Show form again for invalid/expired codes per RFC 8628 section 3.3
-		h.renderVerify(w, templates.VerifyData{
-			Error:         "The code you entered is invalid or has expired. Please check the code and try again.",
-			CSRFToken:     r.PostFormValue("csrf_token"), // Maintain CSRF token
-			PrefilledCode: code,                          // Keep code for user convenience
+		h.renderVerify(w, r, templates.VerifyData{
+			Error:         message,
+			CSRFToken:     h.freshCSRFToken(w, r), // Rotated on every rendering
+			FormNonce:     newFormNonce(),         // Corrected codes are a new submission
+			PrefilledCode: code,                   // Keep code for user convenience
 		})
 		return
 	}
 
-	// Build OAuth authorization URL per RFC 8628
+	h.recordSubmission(ctx, nonce, &deviceflow.SubmissionResult{DeviceCode: deviceCode.DeviceCode})
+	h.continueAuthorization(w, r, deviceCode)
+}
+
+// codeErrorMessage returns the message shown for a user code that failed
+// verification. Codes that were used or revoked are told apart from unknown
+// ones, which all share one message so entries cannot be probed.
+func codeErrorMessage(err error) string {
+	dferr, ok := deviceflow.AsDeviceFlowError(err)
+	if !ok {
+		return msgInvalidCode
+	}
+	switch dferr.Description {
+	case deviceflow.ErrorDescUserCodeConsumed:
+		return msgUsedCode
+	case deviceflow.ErrorDescUserCodeDeleted:
+		return msgRevokedCode
+	default:
+		return msgInvalidCode
+	}
+}
+
+// redirectToAuthorization sends the user to the OAuth authorization endpoint.
+// The state carries a single-use callback nonce after the session's state, so
+// a leaked callback URL cannot be replayed even with the session cookie.
+func (h *Handler) redirectToAuthorization(w http.ResponseWriter, r *http.Request, deviceCode *deviceflow.DeviceCode, sessionState string) {
+	if !h.checkPolicy(w, r, deviceCode, nil) {
+		return
+	}
+
+	nonce, err := h.flow.IssueCallbackNonce(r.Context(), deviceCode.DeviceCode)
+	if err != nil {
+		log.Printf("Error: failed to issue callback nonce: %v", err)
+		h.renderError(w, r, errSessionStart)
+		return
+	}
+
+	// Set location header before status code
+	w.Header().Set("Location", h.authorizationURL(deviceCode, callbackState(sessionState, nonce)))
+
+	// Successful verification returns 302 Found per RFC 8628 section 3.3
+	w.WriteHeader(http.StatusFound)
+}
+
+// callbackState joins the session state and callback nonce into the OAuth state
+func callbackState(sessionState, nonce string) string {
+	return sessionState + callbackStateSeparator + nonce
+}
+
+// splitCallbackState separates the OAuth state returned to the callback into
+// the session state and callback nonce
+func splitCallbackState(state string) (sessionState, nonce string) {
+	sessionState, nonce, _ = strings.Cut(state, callbackStateSeparator)
+	return sessionState, nonce
+}
+
+// authorizationURL builds the OAuth authorization URL for a verified device code.
+// The state holds random values only, never the device code itself.
+func (h *Handler) authorizationURL(deviceCode *deviceflow.DeviceCode, state string) string {
 	params := url.Values{}
+	// Forwarded parameters go first so the proxy's own always take precedence
+	for name, value := range deviceCode.AuthParams {
+		params.Set(name, value)
+	}
 	params.Set("response_type", "code")
 	params.Set("client_id", deviceCode.ClientID)
 	params.Set("redirect_uri", h.baseURL+"/device/complete")
-	params.Set("state", deviceCode.DeviceCode) // Use device code as state
-	if deviceCode.Scope != "" {
-		params.Set("scope", deviceCode.Scope)
+	params.Set("state", state)
+	if scope := h.requestedScope(deviceCode); scope != "" {
+		params.Set("scope", scope)
+	}
+	if deviceCode.Nonce != "" {
+		params.Set("nonce", deviceCode.Nonce)
+	}
+	if len(deviceCode.Resources) > 0 {
+		params["resource"] = deviceCode.Resources
+	}
+	// Keycloak sends users straight to a brokered identity provider when hinted
+	if hint := h.clients.IDPHint(deviceCode.ClientID); hint != "" {
+		params.Set("kc_idp_hint", hint)
 	}
 
-	// Set location header before status code
-	authURL := h.oauth.Endpoint.AuthURL + "?" + params.Encode()
-	w.Header().Set("Location", authURL)
+	return h.oauth.Endpoint.AuthURL + "?" + params.Encode()
+}
 
-	// Successful verification returns 302 Found per RFC 8628 section 3.3
-	w.WriteHeader(http.StatusFound)
+// requestedScope returns the scope requested from the authorization server,
+// adding offline_access for clients that should receive offline tokens
+func (h *Handler) requestedScope(deviceCode *deviceflow.DeviceCode) string {
+	if !h.clients.OfflineAccess(deviceCode.ClientID, h.offline) || oauth.HasScope(deviceCode.Scope, oauth.ScopeOfflineAccess) {
+		return deviceCode.Scope
+	}
+	return strings.TrimSpace(deviceCode.Scope + " " + oauth.ScopeOfflineAccess)
+}
+
+// submissionKey identifies a rendered form for deduplication by its nonce and
+// CSRF token. Forms without a nonce, or whose token was not issued to the
+// browser sending r, are not deduplicated and go on to fail CSRF validation.
+func (h *Handler) submissionKey(r *http.Request, token string) string {
+	nonce := r.PostFormValue("form_nonce")
+	if nonce == "" || h.csrf.CheckIssued(r, token) != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(token))
+	return nonce + "." + base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// awaitSubmission claims the form nonce and returns the earlier result if this is a
+// duplicate. Duplicates arriving while the original is still processing wait briefly
+// for it to finish. Store failures disable deduplication rather than the form.
+func (h *Handler) awaitSubmission(ctx context.Context, nonce string) *deviceflow.SubmissionResult {
+	if nonce == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, submissionWaitTimeout)
+	defer cancel()
+
+	for {
+		prior, err := h.flow.ClaimSubmission(ctx, nonce)
+		if err != nil {
+			log.Printf("Warning: form submission deduplication unavailable: %v", err)
+			return nil
+		}
+		if prior == nil || !prior.Pending {
+			return prior
+		}
+
+		select {
+		case <-ctx.Done():
+			return prior // Still pending, caller reports it as in progress
+		case <-time.After(submissionPollInterval):
+		}
+	}
+}
+
+// recordSubmission stores a submission result, logging failures since the
+// verification itself has already been processed
+func (h *Handler) recordSubmission(ctx context.Context, nonce string, result *deviceflow.SubmissionResult) {
+	if err := h.flow.RecordSubmission(ctx, nonce, result); err != nil {
+		log.Printf("Warning: failed to record form submission: %v", err)
+	}
+}
+
+// replaySubmission responds to a duplicate submission with the original result
+func (h *Handler) replaySubmission(w http.ResponseWriter, r *http.Request, code string, prior *deviceflow.SubmissionResult) {
+	switch {
+	case prior.Pending:
+		h.renderError(w, r, errSubmissionPending)
+
+	case prior.DeviceCode != "":
+		// Reload without re-verifying so rate limits are not counted twice
+		deviceCode, err := h.flow.GetDeviceCode(r.Context(), prior.DeviceCode)
+		if err != nil {
+			h.renderError(w, r, errInvalidDeviceCode)
+			return
+		}
+		// A post carrying another code is not a duplicate of the original
+		if validation.NormalizeCode(deviceCode.UserCode) != validation.NormalizeCode(code) {
+			h.renderVerify(w, r, templates.VerifyData{
+				Error:         msgSessionExpired,
+				CSRFToken:     h.freshCSRFToken(w, r),
+				FormNonce:     newFormNonce(),
+				PrefilledCode: code,
+			})
+			return
+		}
+		h.continueAuthorization(w, r, deviceCode)
+
+	default:
+		h.renderVerify(w, r, templates.VerifyData{
+			Error:         prior.Error,
+			CSRFToken:     h.freshCSRFToken(w, r),
+			FormNonce:     newFormNonce(),
+			PrefilledCode: code,
+		})
+	}
 }
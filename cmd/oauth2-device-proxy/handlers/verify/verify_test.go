@@ -13,12 +13,18 @@ import (
 
 	"golang.org/x/oauth2"
 
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/clients"
 	"github.com/wrale/oauth2-device-proxy/internal/csrf"
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+	"github.com/wrale/oauth2-device-proxy/internal/session"
 	"github.com/wrale/oauth2-device-proxy/internal/templates"
 )
 
 type mockFlow struct {
+	test.MockFlow
 	verifyUserCode        func(ctx context.Context, code string) (*deviceflow.DeviceCode, error)
 	getDeviceCode         func(ctx context.Context, code string) (*deviceflow.DeviceCode, error)
 	completeAuthorization func(ctx context.Context, code string, token *deviceflow.TokenResponse) error
@@ -54,7 +60,7 @@ func (m *mockFlow) CheckDeviceCode(ctx context.Context, deviceCode string) (*dev
 	return nil, deviceflow.ErrPendingAuthorization // Per RFC 8628 section 3.5
 }
 
-func (m *mockFlow) RequestDeviceCode(ctx context.Context, clientID string, scope string) (*deviceflow.DeviceCode, error) {
+func (m *mockFlow) RequestDeviceCode(ctx context.Context, clientID string, scope string, opts ...deviceflow.RequestOption) (*deviceflow.DeviceCode, error) {
 	if m.requestDeviceCode != nil {
 		return m.requestDeviceCode(ctx, clientID, scope)
 	}
@@ -97,10 +103,47 @@ func (s *mockCSRFStore) ValidateToken(ctx context.Context, token string) error {
 	return nil
 }
 
+func (s *mockCSRFStore) ConsumeToken(ctx context.Context, token string) error {
+	return nil
+}
+
 func (s *mockCSRFStore) CheckHealth(ctx context.Context) error {
 	return nil
 }
 
+// singleUseCSRFStore records tokens and lets each be consumed once
+type singleUseCSRFStore struct {
+	tokens map[string]bool
+}
+
+func newSingleUseCSRF() *csrf.Manager {
+	return csrf.NewManager(&singleUseCSRFStore{tokens: make(map[string]bool)}, []byte("test-secret"), time.Minute)
+}
+
+func (s *singleUseCSRFStore) SaveToken(ctx context.Context, token string, expiresIn time.Duration) error {
+	s.tokens[token] = true
+	return nil
+}
+
+func (s *singleUseCSRFStore) ValidateToken(ctx context.Context, token string) error {
+	if !s.tokens[token] {
+		return csrf.ErrInvalidToken
+	}
+	return nil
+}
+
+func (s *singleUseCSRFStore) ConsumeToken(ctx context.Context, token string) error {
+	if !s.tokens[token] {
+		return csrf.ErrInvalidToken
+	}
+	delete(s.tokens, token)
+	return nil
+}
+
+func (s *singleUseCSRFStore) CheckHealth(ctx context.Context) error {
+	return nil
+}
+
 func TestVerifyHandler_HandleForm(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -209,6 +252,41 @@ func TestVerifyHandler_HandleForm(t *testing.T) {
 	}
 }
 
+func TestVerifyHandler_HandleFormCompleteURIDisabled(t *testing.T) {
+	var data templates.VerifyData
+	tmpls := newMockTemplates().
+		WithRenderVerify(func(w http.ResponseWriter, d templates.VerifyData) error {
+			data = d
+			return nil
+		})
+
+	flow := &mockFlow{}
+	flow.AllowsCompleteURIFunc = func(ctx context.Context, userCode string) bool {
+		return false
+	}
+
+	handler := New(Config{
+		Flow:      flow,
+		Templates: tmpls.ToTemplates(),
+		CSRF:      newMockCSRF().ToManager(),
+		BaseURL:   "https://example.com",
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/device?code=BCDF-GHJK", nil)
+	w := httptest.NewRecorder()
+	handler.HandleForm(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if data.PrefilledCode != "" {
+		t.Errorf("prefilled code = %q, want manual entry", data.PrefilledCode)
+	}
+	if data.VerificationQRCodeSVG != "" {
+		t.Error("QR code rendered for client without verification_uri_complete")
+	}
+}
+
 func TestVerifyHandler_HandleSubmit(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -337,3 +415,901 @@ func TestVerifyHandler_HandleSubmit(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyHandler_HandleSubmitDuplicate(t *testing.T) {
+	deviceCode := &deviceflow.DeviceCode{DeviceCode: "device-123", UserCode: "BDFGHJKL", ClientID: "test", Scope: "openid"}
+
+	tests := []struct {
+		name        string
+		verifyError error
+		wantStatus  int
+	}{
+		{
+			name:       "duplicate of successful verification",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:        "duplicate of failed verification",
+			verifyError: deviceflow.ErrInvalidUserCode,
+			wantStatus:  http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verifyCalls int
+			var renderedErrors []string
+			submissions := make(map[string]*deviceflow.SubmissionResult)
+
+			flow := &mockFlow{
+				verifyUserCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+					verifyCalls++
+					if tt.verifyError != nil {
+						return nil, tt.verifyError
+					}
+					return deviceCode, nil
+				},
+				getDeviceCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+					return deviceCode, nil
+				},
+			}
+			flow.ClaimSubmissionFunc = func(ctx context.Context, nonce string) (*deviceflow.SubmissionResult, error) {
+				if prior, ok := submissions[nonce]; ok {
+					return prior, nil
+				}
+				submissions[nonce] = &deviceflow.SubmissionResult{Pending: true}
+				return nil, nil
+			}
+			flow.RecordSubmissionFunc = func(ctx context.Context, nonce string, result *deviceflow.SubmissionResult) error {
+				submissions[nonce] = result
+				return nil
+			}
+
+			tmpls := newMockTemplates().
+				WithRenderVerify(func(w http.ResponseWriter, data templates.VerifyData) error {
+					renderedErrors = append(renderedErrors, data.Error)
+					return nil
+				})
+
+			// Duplicates are answered even though the original consumed the token
+			csrfManager := newSingleUseCSRF()
+			token, err := csrfManager.GenerateToken(context.Background())
+			if err != nil {
+				t.Fatalf("GenerateToken failed: %v", err)
+			}
+
+			handler := New(Config{
+				Flow:      flow,
+				Templates: tmpls.ToTemplates(),
+				CSRF:      csrfManager,
+				OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}},
+				BaseURL:   "https://example.com",
+			})
+
+			values := url.Values{}
+			values.Set("code", "BDFG-HJKL")
+			values.Set("csrf_token", token)
+			values.Set("form_nonce", "nonce-123")
+
+			var locations []string
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(values.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				w := httptest.NewRecorder()
+				handler.HandleSubmit(w, req)
+
+				if w.Code != tt.wantStatus {
+					t.Errorf("submission %d: status code = %d, want %d", i+1, w.Code, tt.wantStatus)
+				}
+				locations = append(locations, w.Header().Get("Location"))
+			}
+
+			if verifyCalls != 1 {
+				t.Errorf("VerifyUserCode called %d times, want 1", verifyCalls)
+			}
+			// Each response starts its own session, so only the state differs
+			if withoutState(locations[0]) != withoutState(locations[1]) {
+				t.Errorf("duplicate redirect = %q, want %q", locations[1], locations[0])
+			}
+			if tt.verifyError != nil {
+				if len(renderedErrors) != 2 || renderedErrors[0] != renderedErrors[1] {
+					t.Errorf("duplicate should render the original error, got %q", renderedErrors)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_HandleSubmitReplayedToken(t *testing.T) {
+	var verifyCalls int
+	flow := &mockFlow{
+		verifyUserCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+			verifyCalls++
+			return &deviceflow.DeviceCode{DeviceCode: "device-123", ClientID: "test"}, nil
+		},
+	}
+
+	csrfManager := newSingleUseCSRF()
+	token, err := csrfManager.GenerateToken(context.Background())
+	if err != nil {
+		t.Fatalf("GenerateToken failed: %v", err)
+	}
+	handler := New(Config{
+		Flow:      flow,
+		Templates: newMockTemplates().ToTemplates(),
+		CSRF:      csrfManager,
+		OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}},
+		BaseURL:   "https://example.com",
+	})
+
+	// A captured post resubmitted as a new form (another nonce) is rejected
+	wantStatus := []int{http.StatusFound, http.StatusBadRequest}
+	for i, nonce := range []string{"nonce-1", "nonce-2"} {
+		values := url.Values{}
+		values.Set("code", "BDFG-HJKL")
+		values.Set("csrf_token", token)
+		values.Set("form_nonce", nonce)
+		req := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler.HandleSubmit(w, req)
+
+		if w.Code != wantStatus[i] {
+			t.Errorf("submission %d: status code = %d, want %d", i+1, w.Code, wantStatus[i])
+		}
+	}
+	if verifyCalls != 1 {
+		t.Errorf("VerifyUserCode called %d times, want 1", verifyCalls)
+	}
+}
+
+func TestVerifyHandler_HandleSubmitDuplicateBinding(t *testing.T) {
+	deviceCode := &deviceflow.DeviceCode{DeviceCode: "device-123", UserCode: "BDFGHJKL", ClientID: "test", Scope: "openid"}
+
+	tests := []struct {
+		name          string
+		code          string
+		otherBrowser  bool
+		wantStatus    int
+		wantFormRetry bool
+	}{
+		{
+			name:       "same browser and code",
+			code:       "bdfg hjkl",
+			wantStatus: http.StatusFound,
+		},
+		{
+			name:         "another browser",
+			code:         "BDFG-HJKL",
+			otherBrowser: true,
+			wantStatus:   http.StatusBadRequest,
+		},
+		{
+			name:          "another code",
+			code:          "CDFG-HJKL",
+			wantStatus:    http.StatusOK,
+			wantFormRetry: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verifyCalls int
+			var rendered templates.VerifyData
+			submissions := make(map[string]*deviceflow.SubmissionResult)
+
+			flow := &mockFlow{
+				verifyUserCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+					verifyCalls++
+					return deviceCode, nil
+				},
+				getDeviceCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+					return deviceCode, nil
+				},
+			}
+			flow.ClaimSubmissionFunc = func(ctx context.Context, nonce string) (*deviceflow.SubmissionResult, error) {
+				if prior, ok := submissions[nonce]; ok {
+					return prior, nil
+				}
+				submissions[nonce] = &deviceflow.SubmissionResult{Pending: true}
+				return nil, nil
+			}
+			flow.RecordSubmissionFunc = func(ctx context.Context, nonce string, result *deviceflow.SubmissionResult) error {
+				submissions[nonce] = result
+				return nil
+			}
+
+			tmpls := newMockTemplates().
+				WithRenderVerify(func(w http.ResponseWriter, data templates.VerifyData) error {
+					rendered = data
+					return nil
+				})
+			handler := New(Config{
+				Flow:      flow,
+				Templates: tmpls.ToTemplates(),
+				CSRF:      csrf.NewManager(nil, []byte("test-secret"), time.Minute, csrf.WithMode(csrf.ModeDoubleSubmit)),
+				OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}},
+				BaseURL:   "https://example.com",
+			})
+
+			browse := func() (*http.Cookie, templates.VerifyData) {
+				w := httptest.NewRecorder()
+				handler.HandleForm(w, httptest.NewRequest(http.MethodGet, "/device", nil))
+				return w.Result().Cookies()[0], rendered
+			}
+			submit := func(cookie *http.Cookie, form templates.VerifyData, code string) *httptest.ResponseRecorder {
+				values := url.Values{}
+				values.Set("code", code)
+				values.Set("csrf_token", form.CSRFToken)
+				values.Set("form_nonce", form.FormNonce)
+				req := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(values.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				req.AddCookie(cookie)
+				w := httptest.NewRecorder()
+				handler.HandleSubmit(w, req)
+				return w
+			}
+
+			cookie, form := browse()
+			if w := submit(cookie, form, "BDFG-HJKL"); w.Code != http.StatusFound {
+				t.Fatalf("original submission status = %d, want %d", w.Code, http.StatusFound)
+			}
+
+			// Posting the same form fields is only a duplicate from the same
+			// browser with the same code
+			if tt.otherBrowser {
+				cookie, _ = browse()
+			}
+			rendered = templates.VerifyData{}
+			w := submit(cookie, form, tt.code)
+			if w.Code != tt.wantStatus {
+				t.Errorf("duplicate status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantFormRetry && (rendered.FormNonce == "" || rendered.FormNonce == form.FormNonce) {
+				t.Errorf("re-rendered nonce = %q, want a fresh nonce", rendered.FormNonce)
+			}
+			if verifyCalls != 1 {
+				t.Errorf("VerifyUserCode called %d times, want 1", verifyCalls)
+			}
+		})
+	}
+}
+
+// withoutState strips the per-session state parameter from a redirect URL
+func withoutState(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	query := u.Query()
+	query.Del("state")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// startSession issues a verification session for the device code, returning
+// the session and the cookie a browser would send back
+func startSession(t *testing.T, h *Handler, deviceCode string) (*session.Session, *http.Cookie) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	sess, err := h.sessions.Start(w, deviceCode)
+	if err != nil {
+		t.Fatalf("starting session: %v", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("session cookies = %d, want 1", len(cookies))
+	}
+	return sess, cookies[0]
+}
+
+func TestVerifyHandler_HandleCompleteDenied(t *testing.T) {
+	tests := []struct {
+		name       string
+		errCode    string
+		wantDenied bool
+		wantFailed string
+		wantStatus int
+	}{
+		{
+			name:       "user denied access",
+			errCode:    "access_denied",
+			wantDenied: true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "other authorization error",
+			errCode:    "server_error",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid scope ends the flow",
+			errCode:    "invalid_scope",
+			wantFailed: deviceflow.ErrorCodeInvalidScope,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "temporarily unavailable leaves the flow pending",
+			errCode:    "temporarily_unavailable",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deniedCode string
+			flow := &mockFlow{
+				getDeviceCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+					return &deviceflow.DeviceCode{DeviceCode: code, ClientID: "test"}, nil
+				},
+			}
+			flow.DenyAuthFunc = func(ctx context.Context, deviceCode string) error {
+				deniedCode = deviceCode
+				return nil
+			}
+			var failedWith string
+			flow.FailAuthFunc = func(ctx context.Context, deviceCode string, failure *deviceflow.DeviceFlowError) error {
+				failedWith = failure.Code
+				return nil
+			}
+
+			var renderedTitle string
+			tmpls := newMockTemplates().
+				WithRenderError(func(w http.ResponseWriter, data templates.ErrorData) error {
+					renderedTitle = data.Title
+					return nil
+				})
+
+			auditLog := &recordingAudit{}
+			handler := New(Config{
+				Flow:      flow,
+				Templates: tmpls.ToTemplates(),
+				CSRF:      newMockCSRF().ToManager(),
+				OAuth:     &oauth2.Config{},
+				BaseURL:   "https://example.com",
+				Audit:     auditLog,
+			})
+
+			sess, cookie := startSession(t, handler, "device-123")
+			req := httptest.NewRequest(http.MethodGet, "/device/complete?state="+sess.State+"&error="+tt.errCode, nil)
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			handler.HandleComplete(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantDenied && deniedCode != "device-123" {
+				t.Errorf("denied device code = %q, want device-123", deniedCode)
+			}
+			if !tt.wantDenied && deniedCode != "" {
+				t.Errorf("unexpected denial of %q", deniedCode)
+			}
+			if failedWith != tt.wantFailed {
+				t.Errorf("flow failed with %q, want %q", failedWith, tt.wantFailed)
+			}
+			if tt.wantDenied {
+				if len(auditLog.records) != 1 || auditLog.records[0].Action != audit.ActionDenied {
+					t.Fatalf("audit records = %+v, want one denial", auditLog.records)
+				}
+				if got := auditLog.records[0].DeviceCodeHash; got != audit.HashDeviceCode("device-123") {
+					t.Errorf("audit device code hash = %q", got)
+				}
+			} else if len(auditLog.records) != 0 {
+				t.Errorf("unexpected audit records %+v", auditLog.records)
+			}
+			if renderedTitle == "" {
+				t.Error("expected error page to be rendered")
+			}
+		})
+	}
+}
+
+// recordingAudit captures audit records written by the handler
+type recordingAudit struct {
+	records []audit.Record
+}
+
+func (r *recordingAudit) Record(ctx context.Context, record audit.Record) error {
+	r.records = append(r.records, record)
+	return nil
+}
+
+func (r *recordingAudit) List(ctx context.Context, filter audit.Filter) ([]audit.Record, error) {
+	return r.records, nil
+}
+
+func TestVerifyHandler_HandleCompleteAlreadyAuthorized(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"second-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	flow := &mockFlow{
+		getDeviceCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+			return &deviceflow.DeviceCode{DeviceCode: code, ClientID: "test"}, nil
+		},
+		completeAuthorization: func(ctx context.Context, code string, token *deviceflow.TokenResponse) error {
+			return deviceflow.ErrAlreadyAuthorized
+		},
+	}
+
+	var message string
+	tmpls := newMockTemplates().
+		WithRenderComplete(func(w http.ResponseWriter, data templates.CompleteData) error {
+			message = data.Message
+			return nil
+		})
+
+	auditLog := &recordingAudit{}
+	handler := New(Config{
+		Flow:      flow,
+		Templates: tmpls.ToTemplates(),
+		CSRF:      newMockCSRF().ToManager(),
+		OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL}},
+		BaseURL:   "https://example.com",
+		Audit:     auditLog,
+	})
+
+	sess, cookie := startSession(t, handler, "device-123")
+	req := httptest.NewRequest(http.MethodGet, "/device/complete?state="+sess.State+"&code=auth-code", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	handler.HandleComplete(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if !strings.Contains(message, "already been authorized") {
+		t.Errorf("completion message = %q, want already authorized notice", message)
+	}
+	if len(auditLog.records) != 0 {
+		t.Errorf("unexpected audit records %+v", auditLog.records)
+	}
+}
+
+func TestVerifyHandler_HandleCompleteSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		cookie     bool   // Send the session cookie for device-123
+		state      string // Override the returned state
+		wantStatus int
+		wantLoaded bool
+	}{
+		{
+			name:       "matching session",
+			cookie:     true,
+			wantStatus: http.StatusOK,
+			wantLoaded: true,
+		},
+		{
+			name:       "missing session cookie",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "substituted state",
+			cookie:     true,
+			state:      "device-456",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var loaded string
+			flow := &mockFlow{
+				getDeviceCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+					loaded = code
+					return &deviceflow.DeviceCode{DeviceCode: code, ClientID: "test"}, nil
+				},
+			}
+			flow.DenyAuthFunc = func(ctx context.Context, deviceCode string) error { return nil }
+
+			handler := New(Config{
+				Flow:      flow,
+				Templates: newMockTemplates().ToTemplates(),
+				CSRF:      newMockCSRF().ToManager(),
+				OAuth:     &oauth2.Config{},
+				BaseURL:   "https://example.com",
+			})
+
+			sess, cookie := startSession(t, handler, "device-123")
+			state := sess.State
+			if tt.state != "" {
+				state = tt.state
+			}
+			req := httptest.NewRequest(http.MethodGet, "/device/complete?state="+state+"&error=access_denied", nil)
+			if tt.cookie {
+				req.AddCookie(cookie)
+			}
+			w := httptest.NewRecorder()
+			handler.HandleComplete(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantLoaded != (loaded == "device-123") {
+				t.Errorf("loaded device code = %q, want loaded %v", loaded, tt.wantLoaded)
+			}
+			if tt.wantLoaded {
+				finished := w.Result().Cookies()
+				if len(finished) != 1 || finished[0].Name != session.CookieName {
+					t.Fatalf("cookies = %+v, want finished session", finished)
+				}
+
+				// The finished session cannot complete another authorization
+				replay := httptest.NewRequest(http.MethodGet, "/device/complete?state="+sess.State+"&error=access_denied", nil)
+				replay.AddCookie(finished[0])
+				w := httptest.NewRecorder()
+				handler.HandleComplete(w, replay)
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("replayed status code = %d, want %d", w.Code, http.StatusBadRequest)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_HandleCompleteCallbackNonce(t *testing.T) {
+	issued := map[string]bool{"nonce-1": true}
+	flow := &mockFlow{
+		getDeviceCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+			return &deviceflow.DeviceCode{DeviceCode: code, ClientID: "test"}, nil
+		},
+	}
+	flow.DenyAuthFunc = func(ctx context.Context, deviceCode string) error { return nil }
+	flow.ConsumeCallbackFunc = func(ctx context.Context, deviceCode, nonce string) error {
+		if deviceCode != "device-123" || !issued[nonce] {
+			return deviceflow.NewDeviceFlowError(deviceflow.ErrorCodeInvalidRequest, "Unknown or already used callback nonce")
+		}
+		delete(issued, nonce)
+		return nil
+	}
+
+	handler := New(Config{
+		Flow:      flow,
+		Templates: newMockTemplates().ToTemplates(),
+		CSRF:      newMockCSRF().ToManager(),
+		OAuth:     &oauth2.Config{},
+		BaseURL:   "https://example.com",
+	})
+	sess, cookie := startSession(t, handler, "device-123")
+
+	tests := []struct {
+		name       string
+		nonce      string
+		wantStatus int
+	}{
+		{name: "missing nonce", wantStatus: http.StatusBadRequest},
+		{name: "issued nonce", nonce: "nonce-1", wantStatus: http.StatusOK},
+		// A leaked callback URL replayed with a copy of the original cookie
+		{name: "replayed nonce", nonce: "nonce-1", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := callbackState(sess.State, tt.nonce)
+			req := httptest.NewRequest(http.MethodGet, "/device/complete?state="+state+"&error=access_denied", nil)
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			handler.HandleComplete(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// stubIDTokens validates ID tokens by comparing them to a fixed value
+type stubIDTokens struct {
+	valid string
+	nonce string // Nonce passed by the last call
+}
+
+func (s *stubIDTokens) ValidateIDToken(ctx context.Context, token, nonce string) (*oauth.IDTokenClaims, error) {
+	s.nonce = nonce
+	if token != s.valid {
+		return nil, oauth.ErrInvalidToken
+	}
+	return &oauth.IDTokenClaims{Subject: "user-1", Email: "user@example.com"}, nil
+}
+
+func TestVerifyHandler_HandleCompleteIDToken(t *testing.T) {
+	withIDToken := `{"access_token":"opaque","token_type":"Bearer","expires_in":3600,"id_token":"signed-id-token"}`
+	withoutIDToken := `{"access_token":"opaque","token_type":"Bearer","expires_in":3600}`
+
+	tests := []struct {
+		name         string
+		response     string
+		validator    *stubIDTokens
+		wantStatus   int
+		wantIDToken  string
+		wantIdentity bool
+	}{
+		{
+			name:         "validated ID token is stored",
+			response:     withIDToken,
+			validator:    &stubIDTokens{valid: "signed-id-token"},
+			wantStatus:   http.StatusOK,
+			wantIDToken:  "signed-id-token",
+			wantIdentity: true,
+		},
+		{
+			name:       "invalid ID token fails the exchange",
+			response:   withIDToken,
+			validator:  &stubIDTokens{valid: "other"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing ID token fails the exchange",
+			response:   withoutIDToken,
+			validator:  &stubIDTokens{valid: "signed-id-token"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unvalidated ID token is discarded",
+			response:   withIDToken,
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.response))
+			}))
+			defer tokenServer.Close()
+
+			var stored *deviceflow.TokenResponse
+			flow := &mockFlow{
+				getDeviceCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+					return &deviceflow.DeviceCode{DeviceCode: code, ClientID: "test", Scope: "openid", Nonce: "nonce-1"}, nil
+				},
+				completeAuthorization: func(ctx context.Context, code string, token *deviceflow.TokenResponse) error {
+					stored = token
+					return nil
+				},
+			}
+
+			auditLog := &recordingAudit{}
+			cfg := Config{
+				Flow:      flow,
+				Templates: newMockTemplates().ToTemplates(),
+				CSRF:      newMockCSRF().ToManager(),
+				OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: tokenServer.URL}},
+				BaseURL:   "https://example.com",
+				Audit:     auditLog,
+			}
+			if tt.validator != nil {
+				cfg.IDTokens = tt.validator
+			}
+			handler := New(cfg)
+
+			sess, cookie := startSession(t, handler, "device-123")
+			req := httptest.NewRequest(http.MethodGet, "/device/complete?state="+sess.State+"&code=auth-code", nil)
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			handler.HandleComplete(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if stored != nil {
+					t.Error("authorization completed despite invalid ID token")
+				}
+				return
+			}
+			if stored == nil {
+				t.Fatal("authorization was not completed")
+			}
+			if stored.IDToken != tt.wantIDToken || (stored.Identity != nil) != tt.wantIdentity {
+				t.Errorf("stored token = %+v", stored)
+			}
+			if tt.validator != nil && tt.validator.nonce != "nonce-1" {
+				t.Errorf("validated with nonce %q, want nonce-1", tt.validator.nonce)
+			}
+			if tt.wantIdentity && (len(auditLog.records) != 1 || auditLog.records[0].Subject != "user-1") {
+				t.Errorf("audit records = %+v, want approval by user-1", auditLog.records)
+			}
+		})
+	}
+}
+
+func TestVerifyHandler_DoubleSubmitCSRF(t *testing.T) {
+	var rendered templates.VerifyData
+	tmpls := newMockTemplates().
+		WithRenderVerify(func(w http.ResponseWriter, data templates.VerifyData) error {
+			rendered = data
+			return nil
+		})
+
+	flow := &mockFlow{
+		verifyUserCode: func(ctx context.Context, code string) (*deviceflow.DeviceCode, error) {
+			return nil, deviceflow.ErrInvalidUserCode
+		},
+	}
+	handler := New(Config{
+		Flow:      flow,
+		Templates: tmpls.ToTemplates(),
+		CSRF:      csrf.NewManager(nil, []byte("test-secret"), time.Minute, csrf.WithMode(csrf.ModeDoubleSubmit)),
+		BaseURL:   "https://example.com",
+	})
+
+	// The form sets the binding cookie alongside its token
+	w := httptest.NewRecorder()
+	handler.HandleForm(w, httptest.NewRequest(http.MethodGet, "/device", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrf.CookieName || rendered.CSRFToken == "" {
+		t.Fatalf("form cookies = %+v, token %q", cookies, rendered.CSRFToken)
+	}
+	token := rendered.CSRFToken
+
+	submit := func(withCookie bool) *httptest.ResponseRecorder {
+		values := url.Values{}
+		values.Set("code", "BCDF-GHJK")
+		values.Set("csrf_token", token)
+		req := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if withCookie {
+			req.AddCookie(cookies[0])
+		}
+		w := httptest.NewRecorder()
+		handler.HandleSubmit(w, req)
+		return w
+	}
+
+	if w := submit(false); w.Code != http.StatusBadRequest {
+		t.Errorf("submission without cookie status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	// A wrong code re-renders the form with a rotated token
+	rendered = templates.VerifyData{}
+	submit(true)
+	if rendered.Error != msgInvalidCode {
+		t.Fatalf("submission with cookie error = %q, want %q", rendered.Error, msgInvalidCode)
+	}
+	if rendered.CSRFToken == "" || rendered.CSRFToken == token {
+		t.Errorf("re-rendered token = %q, want a fresh token", rendered.CSRFToken)
+	}
+}
+
+func TestAuthorizationURL_IDPHint(t *testing.T) {
+	registry, err := clients.NewRegistry([]clients.Client{{ID: "tv", IDPHint: "google"}})
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	handler := New(Config{
+		Flow:      &mockFlow{},
+		Templates: newMockTemplates().ToTemplates(),
+		CSRF:      newMockCSRF().ToManager(),
+		OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}},
+		BaseURL:   "https://example.com",
+		Clients:   registry,
+	})
+
+	tests := []struct {
+		clientID string
+		want     string
+	}{
+		{clientID: "tv", want: "google"},
+		{clientID: "cli", want: ""},
+	}
+	for _, tt := range tests {
+		location := handler.authorizationURL(&deviceflow.DeviceCode{ClientID: tt.clientID}, "state")
+		u, err := url.Parse(location)
+		if err != nil {
+			t.Fatalf("parsing authorization URL: %v", err)
+		}
+		if got := u.Query().Get("kc_idp_hint"); got != tt.want {
+			t.Errorf("%s: kc_idp_hint = %q, want %q", tt.clientID, got, tt.want)
+		}
+	}
+}
+
+func TestAuthorizationURL_ForwardedParams(t *testing.T) {
+	handler := New(Config{
+		Flow:      &mockFlow{},
+		Templates: newMockTemplates().ToTemplates(),
+		CSRF:      newMockCSRF().ToManager(),
+		OAuth:     &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}},
+		BaseURL:   "https://example.com",
+	})
+
+	location := handler.authorizationURL(&deviceflow.DeviceCode{
+		ClientID:   "tv",
+		AuthParams: map[string]string{"acr_values": "mfa", "client_id": "other"},
+	}, "state")
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatalf("parsing authorization URL: %v", err)
+	}
+	if got := u.Query().Get("acr_values"); got != "mfa" {
+		t.Errorf("acr_values = %q, want mfa", got)
+	}
+	if got := u.Query()["resource"]; len(got) != 0 {
+		t.Errorf("resource = %q, want none", got)
+	}
+
+	location = handler.authorizationURL(&deviceflow.DeviceCode{
+		ClientID:  "tv",
+		Resources: []string{"https://api.example.com", "urn:example:printer"},
+	}, "state")
+	if u, err = url.Parse(location); err != nil {
+		t.Fatalf("parsing authorization URL: %v", err)
+	}
+	if got := u.Query()["resource"]; len(got) != 2 {
+		t.Errorf("resource = %q, want both resources", got)
+	}
+	// Stored codes predating validation cannot override the proxy's parameters
+	if got := u.Query()["client_id"]; len(got) != 1 || got[0] != "tv" {
+		t.Errorf("client_id = %q, want tv", got)
+	}
+}
+
+// stubSigner returns a fixed client assertion
+type stubSigner string
+
+func (s stubSigner) Sign() (string, error) { return string(s), nil }
+
+func TestExchangeCode_ClientAssertion(t *testing.T) {
+	var form url.Values
+	var authHeader string
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = r.PostForm
+		authHeader = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"at","token_type":"Bearer","expires_in":300}`))
+	}))
+	defer tokenServer.Close()
+
+	handler := New(Config{
+		Flow:      &mockFlow{},
+		Templates: newMockTemplates().ToTemplates(),
+		CSRF:      newMockCSRF().ToManager(),
+		OAuth: &oauth2.Config{
+			ClientID:     "proxy",
+			ClientSecret: "secret",
+			Endpoint:     oauth2.Endpoint{TokenURL: tokenServer.URL},
+		},
+		BaseURL:    "https://example.com",
+		Assertions: stubSigner("signed-assertion"),
+	})
+
+	if _, err := handler.exchangeCode(context.Background(), "auth-code", &deviceflow.DeviceCode{ClientID: "tv"}); err != nil {
+		t.Fatalf("exchangeCode failed: %v", err)
+	}
+	if form.Get("client_assertion") != "signed-assertion" || form.Get("client_assertion_type") != oauth.ClientAssertionType {
+		t.Errorf("assertion params = %q, %q", form.Get("client_assertion"), form.Get("client_assertion_type"))
+	}
+	if form.Get("client_id") != "proxy" {
+		t.Errorf("client_id = %q, want proxy", form.Get("client_id"))
+	}
+	// The assertion replaces the secret
+	if form.Has("client_secret") || authHeader != "" {
+		t.Errorf("secret sent alongside assertion: client_secret=%q Authorization=%q", form.Get("client_secret"), authHeader)
+	}
+}
+
+func TestCodeErrorMessage(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{err: deviceflow.NewDeviceFlowError(deviceflow.ErrorCodeInvalidGrant, "The user code was not found"), want: msgInvalidCode},
+		{err: deviceflow.NewDeviceFlowError(deviceflow.ErrorCodeExpiredToken, "Code has expired"), want: msgInvalidCode},
+		{err: deviceflow.NewDeviceFlowError(deviceflow.ErrorCodeInvalidGrant, deviceflow.ErrorDescUserCodeConsumed), want: msgUsedCode},
+		{err: deviceflow.NewDeviceFlowError(deviceflow.ErrorCodeInvalidGrant, deviceflow.ErrorDescUserCodeDeleted), want: msgRevokedCode},
+		{err: context.Canceled, want: msgInvalidCode},
+	}
+
+	for _, tt := range tests {
+		if got := codeErrorMessage(tt.err); got != tt.want {
+			t.Errorf("codeErrorMessage(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/introspect"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/verify"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+)
+
+// newIntrospectFunc introspects tokens at the OAuth introspection endpoint per
+// RFC 7662, authenticating the proxy as newRefreshFunc does
+func newIntrospectFunc(cfg Config, client *http.Client, clientSecret func() string, assertions verify.AssertionSigner) introspect.IntrospectFunc {
+	return func(ctx context.Context, token, tokenTypeHint string) ([]byte, error) {
+		data := url.Values{
+			"token":     {token},
+			"client_id": {cfg.OAuth.ClientID},
+		}
+		if tokenTypeHint != "" {
+			data.Set("token_type_hint", tokenTypeHint)
+		}
+		if assertions != nil {
+			assertion, err := assertions.Sign()
+			if err != nil {
+				return nil, fmt.Errorf("signing client assertion: %w", err)
+			}
+			data.Set("client_assertion_type", oauth.ClientAssertionType)
+			data.Set("client_assertion", assertion)
+		} else {
+			data.Set("client_secret", clientSecret())
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, introspectionEndpoint(cfg), strings.NewReader(data.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("creating introspection request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("sending introspection request: %w", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponse))
+		if err != nil {
+			return nil, fmt.Errorf("reading introspection response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			var errResp oauth.ProviderError
+			if json.Unmarshal(body, &errResp) == nil && errResp.Code != "" {
+				return nil, fmt.Errorf("introspection request failed: %w", &errResp)
+			}
+			return nil, fmt.Errorf("introspection request failed: %s", resp.Status)
+		}
+		return body, nil
+	}
+}
+
+// introspectionEndpoint returns the OAuth token introspection endpoint,
+// defaulting to the Keycloak realm's
+func introspectionEndpoint(cfg Config) string {
+	if cfg.OAuth.IntrospectionEndpoint != "" {
+		return cfg.OAuth.IntrospectionEndpoint
+	}
+	return keycloakRealmURL(cfg) + "/protocol/openid-connect/token/introspect"
+}
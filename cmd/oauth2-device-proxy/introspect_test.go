@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/oauth/keycloaktest"
+)
+
+func TestIntrospectFunc(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   keycloaktest.Response
+		wantActive bool
+		wantErr    bool
+	}{
+		{name: "active", response: keycloaktest.IntrospectActive, wantActive: true},
+		{name: "inactive", response: keycloaktest.IntrospectInactive},
+		{name: "server error", response: keycloaktest.ServerError, wantErr: true},
+		{name: "proxy error page", response: keycloaktest.BadGateway, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kc := keycloaktest.NewServer("test")
+			defer kc.Close()
+			kc.Respond(keycloaktest.Introspect, tt.response)
+
+			cfg := renewalConfig(kc)
+			cfg.OAuth.IntrospectionEndpoint = kc.EndpointURL(keycloaktest.Introspect)
+			introspect := newIntrospectFunc(cfg, http.DefaultClient, func() string { return "secret" }, nil)
+			body, err := introspect(context.Background(), "access", "access_token")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("introspect error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil {
+				var resp struct {
+					Active bool `json:"active"`
+				}
+				if err := json.Unmarshal(body, &resp); err != nil || resp.Active != tt.wantActive {
+					t.Errorf("introspection response = %s, want active %v", body, tt.wantActive)
+				}
+			}
+
+			form := kc.Requests(keycloaktest.Introspect)[0]
+			if form.Get("token") != "access" || form.Get("token_type_hint") != "access_token" ||
+				form.Get("client_id") != "device-proxy" || form.Get("client_secret") != "secret" {
+				t.Errorf("introspection request = %v", form)
+			}
+		})
+	}
+}
+
+func TestIntrospectionEndpoint(t *testing.T) {
+	var cfg Config
+	cfg.KeycloakURL = "https://sso.example.com/"
+	cfg.KeycloakRealm = "main"
+	if got, want := introspectionEndpoint(cfg), "https://sso.example.com/realms/main/protocol/openid-connect/token/introspect"; got != want {
+		t.Errorf("introspectionEndpoint() = %q, want %q", got, want)
+	}
+	cfg.OAuth.IntrospectionEndpoint = "https://idp.example.com/introspect"
+	if got := introspectionEndpoint(cfg); got != cfg.OAuth.IntrospectionEndpoint {
+		t.Errorf("introspectionEndpoint() = %q, want the configured endpoint", got)
+	}
+}
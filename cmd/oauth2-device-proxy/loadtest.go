@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/testhook"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// Load test stages, in the order a simulated device goes through them
+const (
+	stageDeviceCode = "device_code"
+	stageApprove    = "approve"
+	stagePoll       = "poll"
+	stageFlow       = "flow"
+)
+
+// loadOptions configures the load test
+type loadOptions struct {
+	proxyURL     string
+	clientID     string
+	scope        string
+	flows        int
+	concurrency  int
+	pendingPolls int // Polls answered authorization_pending before each code is approved
+	approvePath  string
+	hookToken    string
+	http         *http.Client
+	stdout       io.Writer
+
+	// sleep waits out polling intervals, replaced in tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// loadResults collects latencies and failures per stage
+type loadResults struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	failures  map[string]int
+	slowDowns int
+	errors    map[string]int // Failure messages, to report what went wrong
+}
+
+// runLoadTest implements the "loadtest" subcommand, which simulates concurrent
+// devices going through the full flow against a running proxy and reports
+// latency percentiles per stage. Codes are approved through the proxy's test
+// hook, so the proxy must run with it enabled.
+func runLoadTest(args []string) error {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	opts := loadOptions{http: &http.Client{Timeout: 30 * time.Second}, stdout: os.Stdout, sleep: sleepContext}
+	fs.StringVar(&opts.proxyURL, "url", "http://localhost:8080", "Base URL of the device proxy")
+	fs.StringVar(&opts.clientID, "client-id", "loadtest", "OAuth client identifier of the simulated devices")
+	fs.StringVar(&opts.scope, "scope", "", "Space-separated scopes to request")
+	fs.IntVar(&opts.flows, "flows", 100, "Number of device flows to run")
+	fs.IntVar(&opts.concurrency, "concurrency", 10, "Number of device flows run at once")
+	fs.IntVar(&opts.pendingPolls, "pending-polls", 1, "Polls before each code is approved")
+	fs.StringVar(&opts.approvePath, "approve-path", testhook.ApprovePath, "Path of the proxy's test approval hook")
+	fs.StringVar(&opts.hookToken, "hook-token", os.Getenv("TEST_HOOK_TOKEN"), "Bearer token of the test approval hook")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.flows < 1 || opts.concurrency < 1 {
+		fs.Usage()
+		return errors.New("-flows and -concurrency must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	results, elapsed := runLoad(ctx, opts)
+	results.report(opts.stdout, opts.flows, elapsed)
+	if failed := results.failures[stageFlow]; failed > 0 {
+		return fmt.Errorf("%d of %d flows failed", failed, opts.flows)
+	}
+	return nil
+}
+
+// runLoad runs the simulated flows and returns their results
+func runLoad(ctx context.Context, opts loadOptions) (*loadResults, time.Duration) {
+	results := &loadResults{
+		latencies: make(map[string][]time.Duration),
+		failures:  make(map[string]int),
+		errors:    make(map[string]int),
+	}
+
+	start := time.Now()
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < min(opts.concurrency, opts.flows); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				flowStart := time.Now()
+				stage, err := simulateFlow(ctx, opts, results)
+				if err != nil {
+					results.fail(stage, err)
+					results.fail(stageFlow, err)
+					continue
+				}
+				results.record(stageFlow, time.Since(flowStart))
+			}
+		}()
+	}
+	for i := 0; i < opts.flows && ctx.Err() == nil; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	wg.Wait()
+	return results, time.Since(start)
+}
+
+// simulateFlow runs one device through the flow, returning the stage that
+// failed with the error
+func simulateFlow(ctx context.Context, opts loadOptions, results *loadResults) (string, error) {
+	base := strings.TrimSuffix(opts.proxyURL, "/")
+
+	form := url.Values{"client_id": {opts.clientID}}
+	if opts.scope != "" {
+		form.Set("scope", opts.scope)
+	}
+	var code deviceflow.DeviceCode
+	start := time.Now()
+	if err := postForm(ctx, opts.http, base+"/device/code", form, &code); err != nil {
+		return stageDeviceCode, err
+	}
+	results.record(stageDeviceCode, time.Since(start))
+
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second // Default per RFC 8628 section 3.2
+	}
+	poll := url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"device_code": {code.DeviceCode},
+		"client_id":   {opts.clientID},
+	}
+
+	for polls := 0; ; polls++ {
+		if polls == opts.pendingPolls {
+			start = time.Now()
+			if err := approve(ctx, opts, base, code.UserCode); err != nil {
+				return stageApprove, err
+			}
+			results.record(stageApprove, time.Since(start))
+		}
+
+		if err := opts.sleep(ctx, interval); err != nil {
+			return stagePoll, err
+		}
+		var token deviceflow.TokenResponse
+		start = time.Now()
+		err := postForm(ctx, opts.http, base+"/device/token", poll, &token)
+		results.record(stagePoll, time.Since(start))
+		if err == nil {
+			return "", nil
+		}
+
+		dfe, ok := deviceflow.AsDeviceFlowError(err)
+		if !ok {
+			return stagePoll, err
+		}
+		switch dfe.Code {
+		case deviceflow.ErrorCodeAuthorizationPending:
+			// Not yet approved, or the approval is not yet visible
+		case deviceflow.ErrorCodeSlowDown:
+			results.slowDown()
+			interval += 5 * time.Second // Required by RFC 8628 section 3.5
+		default:
+			return stagePoll, dfe
+		}
+	}
+}
+
+// approve marks a user code authorized through the proxy's test hook
+func approve(ctx context.Context, opts loadOptions, base, userCode string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+opts.approvePath,
+		strings.NewReader(url.Values{"user_code": {userCode}}.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if opts.hookToken != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.hookToken)
+	}
+
+	resp, err := opts.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return errors.New("test hook not found, is it enabled on the proxy?")
+	default:
+		return fmt.Errorf("test hook: %s", resp.Status)
+	}
+}
+
+// sleepContext waits for d or until the context ends
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (r *loadResults) record(stage string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies[stage] = append(r.latencies[stage], d)
+}
+
+func (r *loadResults) fail(stage string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[stage]++
+	if stage != stageFlow {
+		r.errors[stage+": "+err.Error()]++
+	}
+}
+
+func (r *loadResults) slowDown() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.slowDowns++
+}
+
+// report prints latency percentiles per stage and the most common failures
+func (r *loadResults) report(w io.Writer, flows int, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	completed := len(r.latencies[stageFlow])
+	fmt.Fprintf(w, "%d of %d flows completed in %s (%.1f flows/s), %d slow_down responses\n\n",
+		completed, flows, elapsed.Round(time.Millisecond), float64(completed)/elapsed.Seconds(), r.slowDowns)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "stage\tok\tfailed\tp50\tp90\tp99\tmax\t")
+	for _, stage := range []string{stageDeviceCode, stageApprove, stagePoll, stageFlow} {
+		latencies := r.latencies[stage]
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", stage, len(latencies), r.failures[stage],
+			percentile(latencies, 0.50), percentile(latencies, 0.90), percentile(latencies, 0.99), percentile(latencies, 1))
+	}
+	tw.Flush()
+
+	if len(r.errors) > 0 {
+		fmt.Fprintln(w, "\nFailures:")
+		messages := make([]string, 0, len(r.errors))
+		for message := range r.errors {
+			messages = append(messages, message)
+		}
+		sort.Slice(messages, func(i, j int) bool { return r.errors[messages[i]] > r.errors[messages[j]] })
+		for _, message := range messages {
+			fmt.Fprintf(w, "  %5d  %s\n", r.errors[message], message)
+		}
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(rank, 0)].Round(time.Microsecond)
+}
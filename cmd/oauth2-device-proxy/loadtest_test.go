@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/testhook"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+func TestRunLoad(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		issued   int
+		approved = make(map[string]bool) // By user code
+		polls    atomic.Int32
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device/code", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		issued++
+		n := issued
+		mu.Unlock()
+		json.NewEncoder(w).Encode(deviceflow.DeviceCode{
+			DeviceCode: fmt.Sprintf("dev-%d", n),
+			UserCode:   fmt.Sprintf("CODE-%d", n),
+			ExpiresIn:  600,
+			Interval:   5,
+		})
+	})
+	mux.HandleFunc(testhook.ApprovePath, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer hook-secret" {
+			t.Errorf("Authorization = %q, want the hook token", got)
+		}
+		mu.Lock()
+		approved[r.FormValue("user_code")] = true
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/device/token", func(w http.ResponseWriter, r *http.Request) {
+		polls.Add(1)
+		mu.Lock()
+		ok := approved["CODE-"+strings.TrimPrefix(r.FormValue("device_code"), "dev-")]
+		mu.Unlock()
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(deviceflow.ErrPendingAuthorization)
+			return
+		}
+		json.NewEncoder(w).Encode(deviceflow.TokenResponse{AccessToken: "at", TokenType: "Bearer"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	var slept atomic.Int64
+	opts := loadOptions{
+		proxyURL:     srv.URL,
+		clientID:     "loadtest",
+		flows:        8,
+		concurrency:  3,
+		pendingPolls: 1,
+		approvePath:  testhook.ApprovePath,
+		hookToken:    "hook-secret",
+		http:         srv.Client(),
+		sleep: func(ctx context.Context, d time.Duration) error {
+			slept.Add(int64(d))
+			return nil
+		},
+	}
+	results, elapsed := runLoad(context.Background(), opts)
+
+	if got := len(results.latencies[stageFlow]); got != 8 {
+		t.Errorf("completed flows = %d, want 8 (failures %v)", got, results.errors)
+	}
+	if got := polls.Load(); got != 16 {
+		t.Errorf("polls = %d, want one pending and one successful per flow", got)
+	}
+	if got := time.Duration(slept.Load()); got != 16*5*time.Second {
+		t.Errorf("slept %s, want the interval before every poll", got)
+	}
+
+	var out bytes.Buffer
+	results.report(&out, opts.flows, elapsed)
+	for _, want := range []string{"8 of 8 flows completed", "device_code", "approve", "p99"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunLoadHookMissing(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/device/code", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(deviceflow.DeviceCode{DeviceCode: "dev", UserCode: "CODE", Interval: 5})
+	})
+	mux.HandleFunc("/device/token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(deviceflow.ErrPendingAuthorization)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	results, elapsed := runLoad(context.Background(), loadOptions{
+		proxyURL:    srv.URL,
+		flows:       2,
+		concurrency: 2,
+		approvePath: testhook.ApprovePath,
+		http:        srv.Client(),
+		sleep:       func(context.Context, time.Duration) error { return nil },
+	})
+	if results.failures[stageApprove] != 2 || results.failures[stageFlow] != 2 {
+		t.Errorf("failures = %v, want both flows failing to approve", results.failures)
+	}
+
+	var out bytes.Buffer
+	results.report(&out, 2, elapsed)
+	if !strings.Contains(out.String(), "test hook not found") {
+		t.Errorf("report missing the hook failure:\n%s", out.String())
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+	tests := map[float64]time.Duration{0.5: 50 * time.Millisecond, 0.9: 90 * time.Millisecond, 0.99: 99 * time.Millisecond, 1: 100 * time.Millisecond}
+	for p, want := range tests {
+		if got := percentile(sorted, p); got != want {
+			t.Errorf("percentile(%v) = %s, want %s", p, got, want)
+		}
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile of no latencies = %s, want 0", got)
+	}
+}
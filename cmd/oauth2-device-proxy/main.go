@@ -2,36 +2,118 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
 
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/debug"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/device"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/grpcadmin"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/verify"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/callback"
+	"github.com/wrale/oauth2-device-proxy/internal/clients"
 	"github.com/wrale/oauth2-device-proxy/internal/csrf"
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/envelope"
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+	"github.com/wrale/oauth2-device-proxy/internal/features"
+	"github.com/wrale/oauth2-device-proxy/internal/httpclient"
+	"github.com/wrale/oauth2-device-proxy/internal/httpsonly"
+	"github.com/wrale/oauth2-device-proxy/internal/notify"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+	"github.com/wrale/oauth2-device-proxy/internal/policy"
+	"github.com/wrale/oauth2-device-proxy/internal/pow"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+	"github.com/wrale/oauth2-device-proxy/internal/session"
+	"github.com/wrale/oauth2-device-proxy/internal/stats"
+	"github.com/wrale/oauth2-device-proxy/internal/throttle"
+	"github.com/wrale/oauth2-device-proxy/internal/tokencache"
+	"github.com/wrale/oauth2-device-proxy/internal/ttl"
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
 )
 
-// Version is set by the build process
-var Version = "dev"
-
 func main() {
+	// The client subcommand runs a reference device against a running proxy
+	if len(os.Args) > 1 && os.Args[1] == "client" {
+		if err := runClient(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "client: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The migrate-keys subcommand moves existing keys into a new namespace
+	if len(os.Args) > 1 && os.Args[1] == "migrate-keys" {
+		if err := runMigrateKeys(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "migrate-keys: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The migrate-store subcommand copies the proxy's state to another Redis deployment
+	if len(os.Args) > 1 && os.Args[1] == "migrate-store" {
+		if err := runMigrateStore(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "migrate-store: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The loadtest subcommand simulates concurrent devices against a running proxy
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		if err := runLoadTest(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "loadtest: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// The preview-templates subcommand renders the hosted pages with sample data
+	if len(os.Args) > 1 && os.Args[1] == "preview-templates" {
+		if err := runPreviewTemplates(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "preview-templates: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Load configuration from environment
 	var cfg Config
 	if err := envconfig.Process("", &cfg); err != nil {
 		log.Fatalf("Error loading configuration: %v", err)
 	}
 
+	// Resolve secrets kept in files or Vault
+	secretsCtx, stopSecrets := context.WithCancel(context.Background())
+	defer stopSecrets()
+	rotatable, err := loadSecrets(secretsCtx, &cfg)
+	if err != nil {
+		log.Fatalf("Error loading secrets: %v", err)
+	}
+
 	// Create Redis client
 	redisOpts, err := redis.ParseURL(cfg.RedisURL)
 	if err != nil {
 		log.Fatalf("Error parsing Redis URL: %v", err)
 	}
+	applyRedisPool(redisOpts, cfg)
 	redisClient := redis.NewClient(redisOpts)
 
 	// Verify Redis connection
@@ -41,24 +123,366 @@ func main() {
 		log.Fatalf("Error connecting to Redis: %v", err)
 	}
 
+	// Initialize lifecycle webhooks if configured
+	var emitter events.Emitter = events.NopEmitter{}
+	var webhooks *events.WebhookEmitter
+	if cfg.WebhookURL != "" {
+		webhooks, err = events.NewWebhookEmitter(events.WebhookConfig{
+			URL:        cfg.WebhookURL,
+			Secret:     []byte(cfg.WebhookSecret),
+			MaxRetries: cfg.WebhookMaxRetries,
+			Timeout:    cfg.WebhookTimeout,
+		})
+		if err != nil {
+			log.Fatalf("Error configuring webhooks: %v", err)
+		}
+		emitter = webhooks
+	}
+
+	// Aggregate conversion stats for the admin API
+	var recorder *stats.Recorder
+	if cfg.AdminToken != "" {
+		recorder = stats.NewRecorder(stats.NewRedisStore(redisClient, stats.WithKeyPrefix(cfg.RedisKeyPrefix)))
+		emitter = events.MultiEmitter{emitter, recorder}
+	}
+
+	// Fan events out to gRPC management API streams
+	var broadcaster *events.Broadcaster
+	if cfg.GRPCAdminPort != 0 {
+		broadcaster = events.NewBroadcaster()
+		emitter = events.MultiEmitter{emitter, broadcaster}
+	}
+
+	// Load per-client settings
+	registry, err := newClientRegistry(cfg)
+	if err != nil {
+		log.Fatalf("Error loading clients: %v", err)
+	}
+	if cfg.RegisteredClientsOnly && cfg.ClientsFile == "" {
+		log.Fatalf("Error in REGISTERED_CLIENTS_ONLY: CLIENTS_FILE is required")
+	}
+
+	// Validate all state lifetimes together before wiring them into the stores
+	ttlPolicy := newTTLPolicy(cfg)
+	if err := ttlPolicy.Validate(); err != nil {
+		log.Fatalf("Error in TTL configuration: %v", err)
+	}
+	if err := deviceflow.ValidateForwardedParams(cfg.ForwardedAuthParams); err != nil {
+		log.Fatalf("Error in FORWARDED_AUTH_PARAMS: %v", err)
+	}
+	if err := deviceflow.ValidateWithheldMembers(cfg.WithheldTokens); err != nil {
+		log.Fatalf("Error in WITHHELD_TOKENS: %v", err)
+	}
+	if err := deviceflow.ValidateAllowedScopes(cfg.AllowedScopes); err != nil {
+		log.Fatalf("Error in ALLOWED_SCOPES: %v", err)
+	}
+	if cfg.CompleteURITemplate != "" {
+		if err := deviceflow.ValidateCompleteURITemplate(cfg.CompleteURITemplate); err != nil {
+			log.Fatalf("Error in VERIFICATION_URI_COMPLETE_TEMPLATE: %v", err)
+		}
+	}
+	if cfg.ErrorURIBase != "" {
+		if u, err := url.Parse(cfg.ErrorURIBase); err != nil || !u.IsAbs() {
+			log.Fatalf("Error in ERROR_URI_BASE: %q is not an absolute URI", cfg.ErrorURIBase)
+		}
+	}
+	codeFormat, err := validation.ParseDisplayFormat(cfg.UserCodeDisplayFormat)
+	if err != nil {
+		log.Fatalf("Error in USER_CODE_DISPLAY_FORMAT: %v", err)
+	}
+
+	// Wrap identity provider calls with retries and a circuit breaker
+	upstream := httpclient.New(httpclient.Config{
+		Timeout:          cfg.UpstreamTimeout,
+		MaxRetries:       cfg.UpstreamMaxRetries,
+		FailureThreshold: cfg.UpstreamBreakerThreshold,
+		Cooldown:         cfg.UpstreamBreakerCooldown,
+	})
+
+	// Look up client presentation in Keycloak before the local settings
+	if cfg.KeycloakAdminClientID != "" {
+		registry.UseMetadataSource(clients.NewKeycloakSource(clients.KeycloakConfig{
+			BaseURL:      cfg.KeycloakURL,
+			Realm:        cfg.KeycloakRealm,
+			ClientID:     cfg.KeycloakAdminClientID,
+			ClientSecret: cfg.KeycloakAdminClientSecret,
+			HTTPClient:   upstream.HTTPClient(),
+			CacheTTL:     cfg.ClientMetadataCacheTTL,
+		}))
+	}
+
+	// Authenticate to the identity provider with a key instead of the secret
+	assertions, err := newAssertionSigner(cfg)
+	if err != nil {
+		log.Fatalf("Error configuring client assertions: %v", err)
+	}
+
 	// Initialize device flow
-	store := deviceflow.NewRedisStore(redisClient)
-	flow := deviceflow.NewFlow(store, cfg.BaseURL,
-		deviceflow.WithExpiryDuration(cfg.CodeExpiry),
+	storeOpts := []deviceflow.StoreOption{
+		deviceflow.WithStoreTTLPolicy(ttlPolicy),
+		deviceflow.WithKeyPrefix(cfg.RedisKeyPrefix),
+	}
+	var sealer *envelope.Sealer
+	if cfg.StoreEncryptionKeys != "" {
+		keys, err := newKeyWrapper(cfg.StoreEncryptionKeys)
+		if err != nil {
+			log.Fatalf("Error configuring store encryption: %v", err)
+		}
+		sealer = envelope.NewSealer(keys)
+		rotatable.encryptionKeys.OnChange(func(spec string) {
+			keys, err := newKeyWrapper(spec)
+			if err != nil {
+				log.Printf("Warning: ignoring rotated store encryption keys: %v", err)
+				return
+			}
+			sealer.SetKeys(keys)
+		})
+		storeOpts = append(storeOpts, deviceflow.WithEncryption(sealer))
+	}
+	retryCfg := deviceflow.RetryConfig{
+		MaxRetries:     cfg.StoreRetries,
+		InitialBackoff: cfg.StoreRetryBackoff,
+		MaxBackoff:     cfg.StoreRetryMaxBackoff,
+	}
+	var store deviceflow.Store = deviceflow.NewRetryStore(deviceflow.NewRedisStore(redisClient, storeOpts...), retryCfg)
+
+	// Write device flow state to a second deployment while moving to it
+	var dualWriteClient *redis.Client
+	if cfg.DualWriteURL != "" {
+		dualWriteOpts, err := redis.ParseURL(cfg.DualWriteURL)
+		if err != nil {
+			log.Fatalf("Error in DUAL_WRITE_REDIS_URL: %v", err)
+		}
+		applyRedisPool(dualWriteOpts, cfg)
+		dualWriteClient = redis.NewClient(dualWriteOpts)
+		pingCtx, pingCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = dualWriteClient.Ping(pingCtx).Err()
+		pingCancel()
+		if err != nil {
+			log.Fatalf("Error connecting to DUAL_WRITE_REDIS_URL: %v", err)
+		}
+
+		prefix := cfg.DualWriteKeyPrefix
+		if prefix == "" {
+			prefix = cfg.RedisKeyPrefix
+		}
+		other := deviceflow.NewRetryStore(deviceflow.NewRedisStore(dualWriteClient, append(storeOpts, deviceflow.WithKeyPrefix(prefix))...), retryCfg)
+		switch cfg.DualWritePrimary {
+		case "current":
+			store = deviceflow.NewDualWriteStore(store, other)
+		case "new":
+			store = deviceflow.NewDualWriteStore(other, store)
+		default:
+			log.Fatalf("Error in DUAL_WRITE_PRIMARY: unknown store %q, want current or new", cfg.DualWritePrimary)
+		}
+		log.Printf("Dual writes to DUAL_WRITE_REDIS_URL enabled, reading from the %s store first", cfg.DualWritePrimary)
+	}
+	intervalGrowth, err := deviceflow.ParseIntervalGrowth(cfg.SlowDownStrategy, cfg.SlowDownFactor, cfg.SlowDownMaxInterval)
+	if err != nil {
+		log.Fatalf("Error in POLL_SLOWDOWN_STRATEGY: %v", err)
+	}
+
+	// Feature flags default to the settings they gate and may roll a feature
+	// out to a percentage of clients
+	flags, err := features.FromEnv(map[features.Flag]bool{
+		features.SSETokenDelivery: true,
+		features.ConsentScreen:    cfg.ConsentPage,
+		features.AnomalyReverify:  cfg.AnomalyReverify,
+	})
+	if err != nil {
+		log.Fatalf("Error in feature flags: %v", err)
+	}
+
+	// Keep refresh tokens on the proxy and renew access tokens for devices
+	var renewer *renewal.Renewer
+	if cfg.TokenRenewal {
+		renewalOpts := []renewal.RedisOption{renewal.WithKeyPrefix(cfg.RedisKeyPrefix)}
+		if sealer != nil {
+			renewalOpts = append(renewalOpts, renewal.WithEncryption(sealer))
+		}
+		renewer = renewal.New(renewal.Config{
+			Store:    renewal.NewRedisStore(redisClient, renewalOpts...),
+			Refresh:  newRefreshFunc(cfg, upstream.HTTPClient(), rotatable.clientSecret.Value, assertions),
+			Revoke:   newRevokeFunc(cfg, upstream.HTTPClient(), rotatable.clientSecret.Value, assertions),
+			GrantTTL: cfg.TokenRenewalGrantTTL,
+		})
+	}
+
+	flowOpts := []deviceflow.Option{
+		deviceflow.WithTTLPolicy(ttlPolicy),
 		deviceflow.WithPollInterval(cfg.PollInterval),
-		deviceflow.WithRateLimit(time.Minute, cfg.MaxPollsPerMinute),
-	)
+		deviceflow.WithMaxPollInterval(cfg.MaxPollInterval),
+		deviceflow.WithTimingPolicy(registry.Timing),
+		deviceflow.WithScopePolicy(func(clientID string) []string {
+			return registry.AllowedScopes(clientID, cfg.AllowedScopes)
+		}),
+		deviceflow.WithIntervalGrowth(intervalGrowth),
+		deviceflow.WithRateLimit(ttlPolicy.RateLimitWindow, cfg.MaxPollsPerMinute),
+		deviceflow.WithEventEmitter(emitter),
+		deviceflow.WithMaxOutstandingCodes(cfg.MaxOutstandingCodes),
+		deviceflow.WithMaxCodesPerClient(cfg.MaxCodesPerClient),
+		deviceflow.WithAnomalyReverification(cfg.AnomalyReverify),
+		deviceflow.WithFeatures(flags),
+		deviceflow.WithCompleteURITemplate(cfg.CompleteURITemplate),
+		deviceflow.WithCodeDisplayFormat(codeFormat),
+		deviceflow.WithTokenWithholding(func(clientID string) []string {
+			withheld := registry.WithheldTokens(clientID, cfg.WithheldTokens)
+			if cfg.TokenRenewal {
+				// Devices renew through the proxy instead
+				withheld = append(withheld, deviceflow.MemberRefreshToken)
+			}
+			return withheld
+		}),
+		deviceflow.WithCompleteURIPolicy(func(clientID string) bool {
+			if cfg.ShortCodeOnly {
+				// Users must type the code so a phished link cannot skip entry
+				return false
+			}
+			return registry.VerificationURIComplete(clientID, cfg.VerificationURIComplete)
+		}),
+	}
+	var enrichers deviceflow.TokenEnrichers
+	if renewer != nil {
+		enrichers = append(enrichers, renewer)
+	}
+
+	// Token exchange is limited to the client each token was delivered to
+	var issued *tokencache.IssuedTokens
+	if len(cfg.TokenExchangeAudiences) > 0 {
+		issued = tokencache.NewIssuedTokens(redisClient, cfg.RedisKeyPrefix)
+		enrichers = append(enrichers, issued)
+	}
+	if len(enrichers) > 0 {
+		flowOpts = append(flowOpts, deviceflow.WithTokenEnricher(enrichers))
+	}
+	var callbacks *callback.Dispatcher
+	if cfg.DeviceCallbacks {
+		callbacks = callback.New(callback.Config{
+			MaxRetries: cfg.DeviceCallbackMaxRetries,
+			Timeout:    cfg.DeviceCallbackTimeout,
+		})
+		flowOpts = append(flowOpts,
+			deviceflow.WithCallbackSender(callbacks),
+			deviceflow.WithCallbackOrigins(registry.CallbackOrigins),
+		)
+	}
+	var flowStore deviceflow.Store = store
+	var degraded *deviceflow.DegradedStore
+	if cfg.DegradedMode {
+		degraded = deviceflow.NewDegradedStore(store, deviceflow.DegradedConfig{
+			CacheTTL:    cfg.DegradedCacheTTL,
+			ErrorBudget: cfg.DegradedErrorBudget,
+			Window:      cfg.DegradedWindow,
+			Cooldown:    cfg.DegradedCooldown,
+		})
+		flowStore = degraded
+	}
+	if cfg.PollCache {
+		flowStore = deviceflow.NewPollCache(flowStore, cfg.PollCacheTTL)
+	}
+	flow := deviceflow.NewFlow(flowStore, cfg.BaseURL, flowOpts...)
+
+	// Sweep expired codes and orphaned references in the background
+	janitorCtx, stopJanitor := context.WithCancel(context.Background())
+	defer stopJanitor()
+	go deviceflow.NewJanitor(store, cfg.CleanupInterval, deviceflow.WithJanitorEvents(emitter)).Run(janitorCtx)
 
 	// Initialize CSRF protection
-	csrfStore := csrf.NewRedisStore(redisClient)
-	csrfManager := csrf.NewManager(csrfStore, []byte(cfg.CSRFSecret), cfg.CSRFTokenExpiry)
+	csrfMode, err := csrf.ParseMode(cfg.CSRFMode)
+	if err != nil {
+		log.Fatalf("Error in CSRF_MODE: %v", err)
+	}
+	csrfStore := csrf.NewRedisStore(redisClient, csrf.WithKeyPrefix(cfg.RedisKeyPrefix))
+	csrfManager := csrf.NewManager(csrfStore, []byte(cfg.CSRFSecret), ttlPolicy.CSRFToken,
+		csrf.WithMode(csrfMode),
+		csrf.WithSecureCookie(strings.HasPrefix(cfg.BaseURL, "https://")),
+	)
+	rotatable.csrfSecret.OnChange(func(secret string) {
+		csrfManager.Rotate([]byte(secret))
+	})
+
+	// Sign verification session cookies
+	sessionSecret := cfg.SessionSecret
+	if sessionSecret == "" {
+		sessionSecret = cfg.CSRFSecret
+	}
+	sessions := session.NewManager([]byte(sessionSecret), cfg.SessionTTL, strings.HasPrefix(cfg.BaseURL, "https://"))
+
+	// Initialize audit trail
+	auditLog, err := newAuditLogger(cfg, redisClient)
+	if err != nil {
+		log.Fatalf("Error configuring audit log: %v", err)
+	}
+
+	// Tell users about devices they authorize, delivering in the background
+	var notifications *notify.Queue
+	notifier, err := newNotifier(cfg)
+	if err != nil {
+		log.Fatalf("Error configuring notifications: %v", err)
+	}
+	if notifier != nil {
+		notifications = notify.NewQueue(notifier, 0, 0)
+		notifier = notifications
+	}
+
+	// Validate ID tokens against the realm's signing keys
+	idTokens, err := newIDTokenValidator(cfg, upstream)
+	if err != nil {
+		log.Fatalf("Error configuring ID token validation: %v", err)
+	}
+
+	// Exchange tokens with the realm under the proxy's own credentials
+	provider, err := newUpstreamProvider(cfg, upstream, rotatable.clientSecret.Value)
+	if err != nil {
+		log.Fatalf("Error configuring identity provider: %v", err)
+	}
+
+	https, err := newHTTPSConfig(cfg)
+	if err != nil {
+		log.Fatalf("Error in ENFORCE_HTTPS: %v", err)
+	}
+
+	if err := validateAdminListener(cfg); err != nil {
+		log.Fatalf("Error in ADMIN_PORT: %v", err)
+	}
+	if err := validateGRPCAdminListener(cfg); err != nil {
+		log.Fatalf("Error in GRPC_ADMIN_PORT: %v", err)
+	}
 
 	// Create and configure server
-	srv, err := newServer(cfg, flow, csrfManager)
+	srv, err := newServer(cfg, dependencies{
+		flow:     flow,
+		degraded: degraded,
+		csrf:     csrfManager,
+		sessions: sessions,
+		audit:    auditLog,
+		clients:  registry,
+		upstream: upstream,
+		idTokens: idTokens,
+		provider: provider,
+		stats:    recorder,
+		features: flags,
+
+		clientSecret: rotatable.clientSecret.Value,
+		assertions:   assertions,
+		clientAuth:   newClientAuthenticator(cfg, registry, redisClient),
+		proofOfWork:  newProofOfWork(cfg, registry, redisClient, rotatable),
+		renewer:      renewer,
+		issued:       issued,
+		throttle:     newThrottle(cfg, redisClient),
+		rateLimits:   throttle.NewRedisStore(redisClient, cfg.RedisKeyPrefix),
+		https:        https,
+		policy:       newPolicy(cfg),
+		notifier:     notifier,
+		storePool:    redisPoolStats(redisClient),
+	})
 	if err != nil {
 		log.Fatalf("Error creating server: %v", err)
 	}
 
+	// Pick up rotated secrets
+	go rotatable.watch(secretsCtx, cfg)
+
 	// Create HTTP server with proper timeout configurations
 	httpServer := &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.Port),
@@ -69,8 +493,8 @@ func main() {
 		IdleTimeout:       cfg.IdleTimeout,
 	}
 
-	// Channel to listen for errors coming from the server
-	serverErrors := make(chan error, 1)
+	// Channel to listen for errors coming from the servers
+	serverErrors := make(chan error, 3)
 
 	// Start server
 	go func() {
@@ -78,6 +502,43 @@ func main() {
 		serverErrors <- httpServer.ListenAndServe()
 	}()
 
+	// Start the internal listener for operator endpoints
+	var adminServer *http.Server
+	if srv.admin != nil {
+		adminServer = &http.Server{
+			Addr:              net.JoinHostPort(cfg.AdminBindAddress, strconv.Itoa(cfg.AdminPort)),
+			Handler:           srv.admin,
+			ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+			IdleTimeout:       cfg.IdleTimeout,
+		}
+		go func() {
+			log.Printf("Admin server listening on %s", adminServer.Addr)
+			serverErrors <- adminServer.ListenAndServe()
+		}()
+	}
+
+	// Start the mutual TLS listener for the gRPC management API
+	var grpcAdminServer *grpc.Server
+	if cfg.GRPCAdminPort != 0 {
+		grpcAdminServer, err = newGRPCAdminServer(cfg, grpcadmin.Config{
+			Flow:   flow,
+			Audit:  auditLog,
+			Events: broadcaster,
+		})
+		if err != nil {
+			log.Fatalf("Error configuring gRPC admin server: %v", err)
+		}
+		grpcAddr := net.JoinHostPort(cfg.GRPCAdminBindAddress, strconv.Itoa(cfg.GRPCAdminPort))
+		grpcListener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			log.Fatalf("Error starting gRPC admin server: %v", err)
+		}
+		go func() {
+			log.Printf("gRPC admin server listening on %s", grpcAddr)
+			serverErrors <- grpcAdminServer.Serve(grpcListener)
+		}()
+	}
+
 	// Channel to listen for interrupt signals
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
@@ -89,6 +550,8 @@ func main() {
 
 	case <-shutdown:
 		log.Println("Starting shutdown")
+		stopJanitor()
+		stopSecrets()
 
 		// Create context with timeout for shutdown
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -101,10 +564,353 @@ func main() {
 				log.Printf("Error closing server: %v", err)
 			}
 		}
+		if adminServer != nil {
+			if err := adminServer.Shutdown(ctx); err != nil {
+				log.Printf("Error shutting down admin server: %v", err)
+			}
+		}
+		if grpcAdminServer != nil {
+			// Event streams only end when their callers disconnect
+			stopped := make(chan struct{})
+			go func() {
+				grpcAdminServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				grpcAdminServer.Stop()
+			}
+		}
+
+		// Flush pending webhook deliveries
+		if webhooks != nil {
+			if err := webhooks.Close(ctx); err != nil {
+				log.Printf("Error flushing webhooks: %v", err)
+			}
+		}
+
+		// Deliver pending device callbacks
+		if callbacks != nil {
+			if err := callbacks.Close(ctx); err != nil {
+				log.Printf("Error flushing device callbacks: %v", err)
+			}
+		}
+
+		// Send queued authorization notifications
+		if notifications != nil {
+			if err := notifications.Close(ctx); err != nil {
+				log.Printf("Error flushing notifications: %v", err)
+			}
+		}
+
+		// Record queued stats events
+		if recorder != nil {
+			if err := recorder.Close(ctx); err != nil {
+				log.Printf("Error flushing stats: %v", err)
+			}
+		}
+
+		// Close file-backed audit log
+		if closer, ok := auditLog.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				log.Printf("Error closing audit log: %v", err)
+			}
+		}
 
 		// Close Redis connection
 		if err := redisClient.Close(); err != nil {
 			log.Printf("Error closing Redis connection: %v", err)
 		}
+		if dualWriteClient != nil {
+			if err := dualWriteClient.Close(); err != nil {
+				log.Printf("Error closing dual write Redis connection: %v", err)
+			}
+		}
+	}
+}
+
+// applyRedisPool sizes the Redis connection pool, leaving settings that are
+// not configured at their go-redis defaults
+func applyRedisPool(opts *redis.Options, cfg Config) {
+	if cfg.RedisPoolSize > 0 {
+		opts.PoolSize = cfg.RedisPoolSize
+	}
+	if cfg.RedisMinIdleConns > 0 {
+		opts.MinIdleConns = cfg.RedisMinIdleConns
+	}
+	if cfg.RedisPoolTimeout > 0 {
+		opts.PoolTimeout = cfg.RedisPoolTimeout
+	}
+	if cfg.RedisDialTimeout > 0 {
+		opts.DialTimeout = cfg.RedisDialTimeout
+	}
+	if cfg.RedisReadTimeout > 0 {
+		opts.ReadTimeout = cfg.RedisReadTimeout
+	}
+	if cfg.RedisWriteTimeout > 0 {
+		opts.WriteTimeout = cfg.RedisWriteTimeout
+	}
+}
+
+// newIDTokenValidator validates ID tokens issued by the Keycloak realm to the
+// proxy's OAuth client, fetching signing keys through the upstream client
+func newIDTokenValidator(cfg Config, upstream *httpclient.Client) (*tokencache.Cache, error) {
+	provider, err := oauth.NewKeycloakProvider(oauth.KeycloakConfig{
+		Config: oauth.Config{ClientID: cfg.OAuth.ClientID, BaseURL: cfg.KeycloakURL},
+		Realm:  cfg.KeycloakRealm,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tokencache.New(tokencache.Config{
+		JWKSURL:    provider.JWKSURL(),
+		Issuer:     provider.Issuer(),
+		ClientID:   cfg.OAuth.ClientID,
+		HTTPClient: upstream.HTTPClient(),
+	}), nil
+}
+
+// newUpstreamProvider calls the Keycloak realm on the proxy's behalf through the
+// upstream client, authenticating as the proxy's other upstream calls do
+func newUpstreamProvider(cfg Config, upstream *httpclient.Client, clientSecret func() string) (*oauth.KeycloakProvider, error) {
+	providerCfg := oauth.KeycloakConfig{
+		Config:         oauth.Config{ClientID: cfg.OAuth.ClientID, BaseURL: cfg.KeycloakURL},
+		Realm:          cfg.KeycloakRealm,
+		AssertionKeyID: cfg.OAuth.ClientAssertionKeyID,
+		TokenURL:       cfg.OAuth.TokenEndpoint,
+		HTTPClient:     upstream.HTTPClient(),
+		SecretSource:   clientSecret,
+	}
+	if cfg.OAuth.ClientAssertionKey != "" {
+		key, err := oauth.ParsePrivateKey([]byte(cfg.OAuth.ClientAssertionKey))
+		if err != nil {
+			return nil, fmt.Errorf("OAUTH_CLIENT_ASSERTION_KEY: %w", err)
+		}
+		providerCfg.AssertionKey = key
+	}
+	return oauth.NewKeycloakProvider(providerCfg)
+}
+
+// newAssertionSigner creates the signer of the proxy's private_key_jwt client
+// assertions, or nil when the proxy authenticates with its client secret
+func newAssertionSigner(cfg Config) (verify.AssertionSigner, error) {
+	if cfg.OAuth.ClientAssertionKey == "" {
+		if cfg.OAuth.ClientSecret == "" {
+			return nil, errors.New("OAUTH_CLIENT_SECRET or OAUTH_CLIENT_ASSERTION_KEY is required")
+		}
+		return nil, nil
+	}
+
+	key, err := oauth.ParsePrivateKey([]byte(cfg.OAuth.ClientAssertionKey))
+	if err != nil {
+		return nil, fmt.Errorf("OAUTH_CLIENT_ASSERTION_KEY: %w", err)
+	}
+	signer, err := oauth.NewAssertionSigner(oauth.AssertionConfig{
+		ClientID: cfg.OAuth.ClientID,
+		Audience: cfg.OAuth.TokenEndpoint,
+		Key:      key,
+		KeyID:    cfg.OAuth.ClientAssertionKeyID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return signer, nil
+}
+
+// redisPoolStats reports the Redis client's connection pool counters for
+// runtime diagnostics
+func redisPoolStats(client *redis.Client) func() debug.PoolStats {
+	return func() debug.PoolStats {
+		stats := client.PoolStats()
+		return debug.PoolStats{
+			Hits:       stats.Hits,
+			Misses:     stats.Misses,
+			Timeouts:   stats.Timeouts,
+			TotalConns: stats.TotalConns,
+			IdleConns:  stats.IdleConns,
+			StaleConns: stats.StaleConns,
+		}
+	}
+}
+
+// validateAdminListener checks the internal listener settings. Profiling is
+// only served on the internal listener, never on the public one.
+func validateAdminListener(cfg Config) error {
+	if cfg.AdminPort == 0 {
+		if cfg.Pprof {
+			return fmt.Errorf("PPROF requires ADMIN_PORT")
+		}
+		return nil
+	}
+	if cfg.AdminPort < 0 || cfg.AdminPort > 65535 {
+		return fmt.Errorf("invalid port %d", cfg.AdminPort)
+	}
+	if cfg.AdminPort == cfg.Port {
+		return fmt.Errorf("port %d is already used by PORT", cfg.AdminPort)
+	}
+	return nil
+}
+
+// newClientAuthenticator verifies the assertions of clients registered for
+// private_key_jwt, remembering assertion IDs in Redis so that no replica
+// accepts one twice
+func newClientAuthenticator(cfg Config, registry *clients.Registry, redisClient *redis.Client) *common.ClientAuthenticator {
+	var known func(clientID string) bool
+	if cfg.RegisteredClientsOnly {
+		known = registry.Registered
+	}
+	return &common.ClientAuthenticator{
+		Verifier: tokencache.NewAssertionVerifier(tokencache.AssertionConfig{
+			Keys: registry,
+			Audiences: []string{
+				cfg.BaseURL,
+				cfg.BaseURL + "/device/code",
+				cfg.BaseURL + "/device/code/refresh",
+				cfg.BaseURL + "/device/token",
+			},
+			Replay: tokencache.NewRedisReplayCache(redisClient, cfg.RedisKeyPrefix),
+		}),
+		Required: registry.RequiresAssertion,
+		Known:    known,
+
+		FailureDelay: cfg.ClientAuthFailureDelay,
+	}
+}
+
+// newProofOfWork challenges public clients that the clients file, or the
+// global setting, requires to solve a proof of work for device codes.
+// Challenges are signed with DEVICE_POW_SECRET, or the CSRF secret when it is
+// unset, and the issuer follows rotations of whichever secret signs them.
+func newProofOfWork(cfg Config, registry *clients.Registry, redisClient *redis.Client, rotatable *configSecrets) *device.ProofOfWork {
+	secret, source := cfg.ProofOfWorkSecret, rotatable.powSecret
+	if source == nil {
+		secret, source = cfg.CSRFSecret, rotatable.csrfSecret
+	}
+	issuer := pow.New(pow.Config{
+		Secret:     []byte(secret),
+		Difficulty: cfg.ProofOfWorkDifficulty,
+		TTL:        cfg.ProofOfWorkTTL,
+		Replay:     pow.NewRedisReplayCache(redisClient, cfg.RedisKeyPrefix),
+	})
+	source.OnChange(func(secret string) {
+		issuer.Rotate([]byte(secret))
+	})
+	return &device.ProofOfWork{
+		Issuer: issuer,
+		Required: func(clientID string) bool {
+			return registry.RequiresProofOfWork(clientID, cfg.ProofOfWork)
+		},
+	}
+}
+
+// newThrottle limits verification page requests and failed code entries per
+// IP across all proxy instances, or returns nil when disabled
+func newThrottle(cfg Config, redisClient *redis.Client) *throttle.Limiter {
+	if cfg.VerifyMaxRequests <= 0 {
+		return nil
+	}
+	return throttle.New(throttle.Config{
+		Store:       throttle.NewRedisStore(redisClient, cfg.RedisKeyPrefix),
+		Window:      cfg.VerifyRateWindow,
+		MaxRequests: cfg.VerifyMaxRequests,
+		MaxFailures: cfg.VerifyMaxFailures,
+		Lockout:     cfg.VerifyLockout,
+	})
+}
+
+// newPolicy creates the authorization policy configured by POLICY_URL, nil
+// when none is
+func newPolicy(cfg Config) policy.Policy {
+	if cfg.PolicyURL == "" {
+		return nil
+	}
+	return policy.NewOPA(policy.OPAConfig{
+		URL:        cfg.PolicyURL,
+		HTTPClient: &http.Client{Timeout: cfg.PolicyTimeout},
+	})
+}
+
+// newHTTPSConfig configures plain HTTP handling from ENFORCE_HTTPS and
+// TRUSTED_PROXIES. Redirects go to BASE_URL, which must then use https.
+func newHTTPSConfig(cfg Config) (httpsonly.Config, error) {
+	mode, err := httpsonly.ParseMode(cfg.EnforceHTTPS)
+	if err != nil {
+		return httpsonly.Config{}, err
+	}
+	proxies, err := httpsonly.ParseProxies(cfg.TrustedProxies)
+	if err != nil {
+		return httpsonly.Config{}, err
+	}
+	if mode != httpsonly.ModeOff && !strings.HasPrefix(cfg.BaseURL, "https://") {
+		return httpsonly.Config{}, fmt.Errorf("BASE_URL %q must use https when HTTPS is enforced", cfg.BaseURL)
+	}
+	return httpsonly.Config{
+		Mode:           mode,
+		BaseURL:        cfg.BaseURL,
+		TrustedProxies: proxies,
+		Exempt:         []string{"/health", "/metrics"}, // Probed over plain HTTP inside the network
+	}, nil
+}
+
+// newAuditLogger creates the audit logger selected by AUDIT_BACKEND
+func newAuditLogger(cfg Config, redisClient *redis.Client) (audit.Logger, error) {
+	switch cfg.AuditBackend {
+	case "redis":
+		return audit.NewRedisLogger(redisClient, audit.WithKeyPrefix(cfg.RedisKeyPrefix)), nil
+	case "file":
+		return audit.NewFileLogger(cfg.AuditFile)
+	case "none", "":
+		return audit.NopLogger{}, nil
+	default:
+		return nil, fmt.Errorf("unknown audit backend %q", cfg.AuditBackend)
+	}
+}
+
+// newNotifier creates the notifier selected by NOTIFY_BACKEND, nil for none
+func newNotifier(cfg Config) (notify.Notifier, error) {
+	switch cfg.NotifyBackend {
+	case "smtp":
+		return notify.NewSMTPNotifier(notify.SMTPConfig{
+			Addr:     cfg.SMTPAddr,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	case "webhook":
+		return notify.NewWebhookNotifier(notify.WebhookConfig{
+			URL:    cfg.NotifyWebhookURL,
+			Secret: []byte(cfg.NotifyWebhookSecret),
+		})
+	case "none", "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown notification backend %q", cfg.NotifyBackend)
+	}
+}
+
+// newTTLPolicy collects the configured lifetimes of device flow state
+func newTTLPolicy(cfg Config) ttl.Policy {
+	policy := ttl.Policy{
+		DeviceCode:      cfg.CodeExpiry,
+		MaxDeviceCode:   cfg.MaxCodeExpiry,
+		MaxBatchCode:    cfg.MaxBatchCodeExpiry,
+		Token:           cfg.TokenTTL,
+		RateLimitWindow: cfg.RateLimitWindow,
+		Submission:      cfg.SubmissionWindow,
+		CSRFToken:       cfg.CSRFTokenExpiry,
+		ExpiredGrace:    cfg.ExpiredTokenGrace,
+	}
+	if policy.Token == 0 {
+		policy.Token = policy.DeviceCode
+	}
+	return policy
+}
+
+// newClientRegistry loads per-client settings from CLIENTS_FILE when configured
+func newClientRegistry(cfg Config) (*clients.Registry, error) {
+	if cfg.ClientsFile == "" {
+		return clients.NewRegistry(nil)
 	}
+	return clients.LoadFile(cfg.ClientsFile)
 }
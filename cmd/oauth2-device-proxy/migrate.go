@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/wrale/oauth2-device-proxy/internal/keyspace"
+)
+
+// runMigrateKeys implements the "migrate-keys" subcommand, which renames the
+// proxy's existing Redis keys from one REDIS_KEY_PREFIX to another. Stop the
+// proxy before migrating and start it with the new prefix afterwards.
+func runMigrateKeys(args []string) error {
+	fs := flag.NewFlagSet("migrate-keys", flag.ContinueOnError)
+	redisURL := fs.String("redis-url", os.Getenv("REDIS_URL"), "Redis connection URL")
+	from := fs.String("from", "", "Current key prefix, empty for unprefixed keys")
+	to := fs.String("to", os.Getenv("REDIS_KEY_PREFIX"), "New key prefix")
+	dryRun := fs.Bool("dry-run", false, "Count the keys that would move without renaming them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *redisURL == "" {
+		fs.Usage()
+		return errors.New("-redis-url or REDIS_URL is required")
+	}
+
+	opts, err := redis.ParseURL(*redisURL)
+	if err != nil {
+		return fmt.Errorf("parsing Redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+	defer client.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	result, err := keyspace.Migrate(ctx, client, *from, *to, *dryRun)
+	if result != nil {
+		verb := "Renamed"
+		if *dryRun {
+			verb = "Would rename"
+		}
+		fmt.Printf("%s %d keys from %q to %q\n", verb, result.Renamed, *from, *to)
+		for _, key := range result.Skipped {
+			fmt.Printf("Skipped %s: target key already exists\n", key)
+		}
+	}
+	return err
+}
+
+// runMigrateStore implements the "migrate-store" subcommand, which copies the
+// proxy's state, including device codes in flight, their tokens and CSRF
+// tokens, from one Redis deployment to another, either of which may be a
+// Redis Cluster. Stop the proxy or let it drain first, then start it against
+// the target once the copy finishes.
+func runMigrateStore(args []string) error {
+	fs := flag.NewFlagSet("migrate-store", flag.ContinueOnError)
+	from := fs.String("from", os.Getenv("REDIS_URL"), "Source Redis connection URL")
+	to := fs.String("to", "", "Target Redis connection URL")
+	fromCluster := fs.Bool("from-cluster", false, "The source is a Redis Cluster, with further nodes given as addr parameters")
+	toCluster := fs.Bool("to-cluster", false, "The target is a Redis Cluster, with further nodes given as addr parameters")
+	prefix := fs.String("prefix", os.Getenv("REDIS_KEY_PREFIX"), "Key prefix in the source")
+	toPrefix := fs.String("to-prefix", os.Getenv("REDIS_KEY_PREFIX"), "Key prefix in the target")
+	replace := fs.Bool("replace", false, "Overwrite keys that already exist in the target")
+	dryRun := fs.Bool("dry-run", false, "Count the keys that would be copied without writing them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || *to == "" {
+		fs.Usage()
+		return errors.New("-from or REDIS_URL, and -to are required")
+	}
+	if *from == *to && *prefix == *toPrefix {
+		return errors.New("source and target are the same")
+	}
+
+	src, err := newStoreClient(*from, *fromCluster)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	defer src.Close()
+	dst, err := newStoreClient(*to, *toCluster)
+	if err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+	defer dst.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := src.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("connecting to source: %w", err)
+	}
+	if err := dst.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("connecting to target: %w", err)
+	}
+
+	result, err := keyspace.Copy(ctx, src, dst, keyspace.CopyOptions{
+		FromPrefix: *prefix,
+		ToPrefix:   *toPrefix,
+		Replace:    *replace,
+		DryRun:     *dryRun,
+	})
+	if result != nil {
+		verb := "Copied"
+		if *dryRun {
+			verb = "Would copy"
+		}
+		for _, pattern := range keyspace.Patterns {
+			if n := result.Copied[pattern]; n > 0 {
+				fmt.Printf("%s %d %s keys\n", verb, n, pattern)
+			}
+		}
+		fmt.Printf("%s %d keys in total\n", verb, result.Total())
+		for _, key := range result.Skipped {
+			fmt.Printf("Skipped %s: target key already exists\n", key)
+		}
+	}
+	return err
+}
+
+// newStoreClient connects to a store backend given by URL. The proxy keeps
+// its state in Redis only, so other databases are rejected by scheme.
+func newStoreClient(rawURL string, cluster bool) (redis.UniversalClient, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing URL: %w", err)
+	}
+	switch u.Scheme {
+	case "redis", "rediss", "unix":
+	default:
+		return nil, fmt.Errorf("unsupported store %q, the proxy stores its state in Redis", u.Scheme)
+	}
+
+	if cluster {
+		opts, err := redis.ParseClusterURL(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing Redis Cluster URL: %w", err)
+		}
+		return redis.NewClusterClient(opts), nil
+	}
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Redis URL: %w", err)
+	}
+	return redis.NewClient(opts), nil
+}
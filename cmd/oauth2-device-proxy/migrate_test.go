@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNewStoreClient(t *testing.T) {
+	standalone, err := newStoreClient("redis://localhost:6379/0", false)
+	if err != nil {
+		t.Fatalf("newStoreClient failed: %v", err)
+	}
+	defer standalone.Close()
+	if _, ok := standalone.(*redis.Client); !ok {
+		t.Errorf("standalone client is %T, want *redis.Client", standalone)
+	}
+
+	cluster, err := newStoreClient("redis://node-1:6379?addr=node-2:6379&addr=node-3:6379", true)
+	if err != nil {
+		t.Fatalf("newStoreClient failed: %v", err)
+	}
+	defer cluster.Close()
+	if _, ok := cluster.(*redis.ClusterClient); !ok {
+		t.Errorf("cluster client is %T, want *redis.ClusterClient", cluster)
+	}
+
+	if _, err := newStoreClient("postgres://localhost:5432/proxy", false); err == nil || !strings.Contains(err.Error(), "unsupported store") {
+		t.Errorf("newStoreClient(postgres) error = %v, want unsupported store", err)
+	}
+}
+
+func TestRunMigrateStoreArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "missing target", args: []string{"-from", "redis://localhost:6379"}, wantErr: "-to are required"},
+		{name: "same store", args: []string{"-from", "redis://localhost:6379", "-to", "redis://localhost:6379"}, wantErr: "the same"},
+		{name: "unsupported target", args: []string{"-from", "redis://localhost:6379", "-to", "postgresql://db/proxy"}, wantErr: "target: unsupported store"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REDIS_URL", "")
+			t.Setenv("REDIS_KEY_PREFIX", "")
+			err := runMigrateStore(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("runMigrateStore() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+)
+
+// previewEnv holds the branding settings the preview shares with the proxy,
+// read from the same environment variables
+type previewEnv struct {
+	BrandProductName  string   `envconfig:"BRAND_PRODUCT_NAME"`
+	BrandLogoURL      string   `envconfig:"BRAND_LOGO_URL"`
+	BrandPrimaryColor string   `envconfig:"BRAND_PRIMARY_COLOR"`
+	BrandFooterLinks  []string `envconfig:"BRAND_FOOTER_LINKS"`
+	CodeDisplayFormat string   `envconfig:"USER_CODE_DISPLAY_FORMAT"`
+	HighContrast      bool     `envconfig:"HIGH_CONTRAST"`
+	LargeCode         bool     `envconfig:"LARGE_CODE"`
+	SpellOutCode      bool     `envconfig:"SPELL_OUT_CODE"`
+}
+
+// previewPage renders one hosted page with sample data
+type previewPage struct {
+	name   string // File name without extension
+	render func(t *templates.Templates, w http.ResponseWriter) error
+}
+
+// Sample data shown on preview pages
+const (
+	sampleUserCode        = "WDJB-MJHT"
+	sampleVerificationURI = "https://example.com/device"
+)
+
+// previewPages lists the pages rendered by the preview, in index order
+var previewPages = []previewPage{
+	{name: "verify", render: func(t *templates.Templates, w http.ResponseWriter) error {
+		data := templates.VerifyData{
+			PrefilledCode:   sampleUserCode,
+			CSRFToken:       "preview",
+			VerificationURI: sampleVerificationURI,
+		}
+		// As on the proxy, the QR code is shown only when it can be generated
+		if svg, err := t.GenerateQRCode(sampleVerificationURI + "?code=" + sampleUserCode); err == nil {
+			data.VerificationQRCodeSVG = svg
+		}
+		return t.RenderVerify(w, data)
+	}},
+	{name: "verify-error", render: func(t *templates.Templates, w http.ResponseWriter) error {
+		return t.RenderVerify(w, templates.VerifyData{
+			PrefilledCode:   "WDJB-MJHX",
+			CSRFToken:       "preview",
+			Error:           "The code you entered is invalid or has expired. Check the code on your device and try again.",
+			VerificationURI: sampleVerificationURI,
+		})
+	}},
+	{name: "consent", render: func(t *templates.Templates, w http.ResponseWriter) error {
+		return t.RenderConsent(w, templates.ConsentData{
+			ClientName:  "Living Room TV",
+			ConsentText: "Living Room TV will be able to stream from your library.",
+			UserCode:    sampleUserCode,
+			Scopes: []templates.ConsentScope{
+				{Name: "profile", Description: "Your name and profile picture"},
+				{Name: "library:read", Description: "Read access to your library"},
+				{Name: "offline_access"},
+			},
+			Device:    &templates.ConsentDevice{ID: "tv-4821", Attested: true},
+			Origin:    "203.0.113.7 (Berlin, DE)",
+			CSRFToken: "preview",
+			Ticket:    "preview",
+		})
+	}},
+	{name: "complete", render: func(t *templates.Templates, w http.ResponseWriter) error {
+		return t.RenderComplete(w, templates.CompleteData{
+			Message: "Your device is now authorized. You can return to it to continue.",
+			Summary: &templates.CompleteSummary{
+				UserName:   "Alex Doe",
+				UserEmail:  "alex@example.com",
+				ClientName: "Living Room TV",
+				UserCode:   sampleUserCode,
+				DeviceID:   "tv-4821",
+				Origin:     "203.0.113.7 (Berlin, DE)",
+			},
+		})
+	}},
+	{name: "devices", render: func(t *templates.Templates, w http.ResponseWriter) error {
+		now := time.Now()
+		return t.RenderDevices(w, templates.DevicesData{
+			UserName: "Alex Doe",
+			Devices: []templates.Device{
+				{Ref: "a", ClientName: "Living Room TV", DeviceID: "tv-4821", Scope: "profile library:read",
+					AuthorizedAt: now.Add(-2 * time.Hour), ApprovedFrom: "203.0.113.7", Revocable: true},
+				{Ref: "b", ClientName: "Kitchen Speaker", Scope: "profile",
+					AuthorizedAt: now.Add(-72 * time.Hour), ApprovedFrom: "198.51.100.2", Revoked: true},
+			},
+			CSRFToken: "preview",
+		})
+	}},
+	{name: "error", render: func(t *templates.Templates, w http.ResponseWriter) error {
+		return t.RenderError(w, templates.ErrorData{
+			Title:         "Code Expired",
+			Message:       "This code has expired. Start again on your device to get a new code.",
+			Code:          deviceflow.ErrorCodeExpiredToken,
+			CorrelationID: "preview-0001",
+		})
+	}},
+}
+
+// previewOptions configures the template preview
+type previewOptions struct {
+	templateDir string // Page templates to load instead of the embedded ones
+	outDir      string
+	addr        string // Serve pages instead of writing them when set
+	brand       templates.Brand
+	stdout      io.Writer
+}
+
+// runPreviewTemplates implements the "preview-templates" subcommand, which
+// renders the hosted pages with sample data so that branding can be worked on
+// without a running proxy, Redis or identity provider. Branding is read from
+// the same BRAND_*, USER_CODE_DISPLAY_FORMAT and accessibility variables as
+// the proxy.
+func runPreviewTemplates(args []string) error {
+	fs := flag.NewFlagSet("preview-templates", flag.ContinueOnError)
+	opts := previewOptions{stdout: os.Stdout}
+	fs.StringVar(&opts.templateDir, "templates", "", "Directory of page templates to preview, like internal/templates/html; embedded templates if empty")
+	fs.StringVar(&opts.outDir, "out", "template-preview", "Directory the pages are written to")
+	fs.StringVar(&opts.addr, "serve", "", "Serve the pages at this address, e.g. localhost:8090, re-rendering them on every request")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var env previewEnv
+	if err := envconfig.Process("", &env); err != nil {
+		return fmt.Errorf("loading branding: %w", err)
+	}
+	brand, err := newBrand(Config{
+		BrandProductName:      env.BrandProductName,
+		BrandLogoURL:          env.BrandLogoURL,
+		BrandPrimaryColor:     env.BrandPrimaryColor,
+		BrandFooterLinks:      env.BrandFooterLinks,
+		UserCodeDisplayFormat: env.CodeDisplayFormat,
+		HighContrast:          env.HighContrast,
+		LargeCode:             env.LargeCode,
+		SpellOutCode:          env.SpellOutCode,
+	})
+	if err != nil {
+		return fmt.Errorf("configuring brand: %w", err)
+	}
+	opts.brand = brand
+
+	if opts.addr != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return servePreview(ctx, opts)
+	}
+	return writePreview(opts)
+}
+
+// loadPreviewTemplates loads the templates being previewed. Templates are
+// loaded afresh each time so that edits show without a restart.
+func loadPreviewTemplates(opts previewOptions) (*templates.Templates, error) {
+	var (
+		tmpls *templates.Templates
+		err   error
+	)
+	if opts.templateDir != "" {
+		tmpls, err = templates.LoadTemplatesFS(os.DirFS(opts.templateDir))
+	} else {
+		tmpls, err = templates.LoadTemplates()
+	}
+	if err != nil {
+		return nil, err
+	}
+	tmpls.SetBrand(opts.brand)
+	return tmpls, nil
+}
+
+// renderPreviewPage renders a page, failing rather than returning the
+// fallback error page when its template is broken
+func renderPreviewPage(tmpls *templates.Templates, page previewPage) ([]byte, error) {
+	w := httptest.NewRecorder()
+	if err := page.render(tmpls, w); err != nil {
+		return nil, fmt.Errorf("rendering %s page: %w", page.name, err)
+	}
+	return w.Body.Bytes(), nil
+}
+
+// writePreview writes every page to opts.outDir, with the static assets they
+// use linked relatively so the files open directly in a browser
+func writePreview(opts previewOptions) error {
+	tmpls, err := loadPreviewTemplates(opts)
+	if err != nil {
+		return err
+	}
+
+	assetDir := filepath.Join(opts.outDir, strings.Trim(templates.AssetPrefix, "/"))
+	if err := os.MkdirAll(assetDir, 0o755); err != nil {
+		return fmt.Errorf("creating output directory: %w", err)
+	}
+	for urlPath, content := range templates.AssetFiles() {
+		name := filepath.Join(assetDir, strings.TrimPrefix(urlPath, templates.AssetPrefix))
+		if err := os.WriteFile(name, content, 0o644); err != nil {
+			return fmt.Errorf("writing asset: %w", err)
+		}
+	}
+
+	relativeAssets := strings.TrimPrefix(templates.AssetPrefix, "/")
+	for _, page := range previewPages {
+		html, err := renderPreviewPage(tmpls, page)
+		if err != nil {
+			return err
+		}
+		html = []byte(strings.ReplaceAll(string(html), `"`+templates.AssetPrefix, `"`+relativeAssets))
+
+		name := filepath.Join(opts.outDir, page.name+".html")
+		if err := os.WriteFile(name, html, 0o644); err != nil {
+			return fmt.Errorf("writing %s page: %w", page.name, err)
+		}
+		fmt.Fprintf(opts.stdout, "Wrote %s\n", name)
+	}
+	return nil
+}
+
+// previewIndex lists the preview pages
+var previewIndex = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><title>Template preview</title></head>
+<body>
+<h1>Template preview</h1>
+<ul>{{range .}}<li><a href="/{{.}}.html">{{.}}</a></li>{{end}}</ul>
+</body>
+</html>
+`))
+
+// previewHandler serves the pages, an index of them at / and the static assets
+func previewHandler(opts previewOptions) http.Handler {
+	pages := make(map[string]previewPage, len(previewPages))
+	names := make([]string, 0, len(previewPages))
+	for _, page := range previewPages {
+		pages["/"+page.name+".html"] = page
+		names = append(names, page.name)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(templates.AssetPrefix, templates.AssetHandler())
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_ = previewIndex.Execute(w, names)
+			return
+		}
+		page, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		tmpls, err := loadPreviewTemplates(opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		html, err := renderPreviewPage(tmpls, page)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write(html)
+	})
+	return mux
+}
+
+// servePreview serves the pages at opts.addr until ctx is done
+func servePreview(ctx context.Context, opts previewOptions) error {
+	srv := &http.Server{
+		Addr:              opts.addr,
+		Handler:           previewHandler(opts),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	fmt.Fprintf(opts.stdout, "Serving template preview at http://%s/\n", opts.addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/templates"
+)
+
+func TestWritePreview(t *testing.T) {
+	out := t.TempDir()
+	opts := previewOptions{
+		outDir: out,
+		brand:  templates.Brand{ProductName: "Acme TV", PrimaryColor: "#ff6600"},
+		stdout: io.Discard,
+	}
+	if err := writePreview(opts); err != nil {
+		t.Fatalf("writePreview failed: %v", err)
+	}
+
+	assetLink := regexp.MustCompile(`(?:href|src)="(assets/[^"]+)"`)
+	for _, page := range previewPages {
+		html, err := os.ReadFile(filepath.Join(out, page.name+".html"))
+		if err != nil {
+			t.Fatalf("reading %s page: %v", page.name, err)
+		}
+		if !strings.Contains(string(html), "Acme TV") {
+			t.Errorf("%s page does not show the configured brand", page.name)
+		}
+		if strings.Contains(string(html), `"`+templates.AssetPrefix) {
+			t.Errorf("%s page links assets by absolute path", page.name)
+		}
+
+		// Linked assets are written next to the pages
+		links := assetLink.FindAllStringSubmatch(string(html), -1)
+		if len(links) == 0 {
+			t.Errorf("%s page links no assets", page.name)
+		}
+		for _, link := range links {
+			if _, err := os.Stat(filepath.Join(out, link[1])); err != nil {
+				t.Errorf("%s page links missing asset %s", page.name, link[1])
+			}
+		}
+	}
+}
+
+func TestPreviewHandler(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"layout.html", "verify.html", "consent.html", "complete.html", "devices.html", "error.html"} {
+		src, err := os.ReadFile(filepath.Join("..", "..", "internal", "templates", "html", name))
+		if err != nil {
+			t.Fatalf("reading template %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), src, 0o644); err != nil {
+			t.Fatalf("copying template %s: %v", name, err)
+		}
+	}
+	handler := previewHandler(previewOptions{templateDir: dir})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	if w := get("/"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `href="/complete.html"`) {
+		t.Errorf("index = %d %q, want a link to every page", w.Code, w.Body.String())
+	}
+	if w := get("/unknown.html"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown page status code = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	// Edits to the templates show on the next request
+	custom := `{{define "title"}}Done{{end}}{{define "content"}}<p id="edited">{{.Message}}</p>{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, "complete.html"), []byte(custom), 0o644); err != nil {
+		t.Fatalf("editing template: %v", err)
+	}
+	w := get("/complete.html")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<p id="edited">`) {
+		t.Errorf("edited page = %d %q, want the edited template", w.Code, w.Body.String())
+	}
+
+	// Broken templates are reported instead of rendering a fallback page
+	if err := os.WriteFile(filepath.Join(dir, "error.html"), []byte(`{{define "content"}}`), 0o644); err != nil {
+		t.Fatalf("editing template: %v", err)
+	}
+	if w := get("/error.html"); w.Code != http.StatusInternalServerError {
+		t.Errorf("broken template status code = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
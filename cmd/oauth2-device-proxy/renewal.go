@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/verify"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+)
+
+// maxTokenResponse bounds how much of a token endpoint response is read
+const maxTokenResponse = 1 << 20
+
+// newRefreshFunc redeems refresh tokens at the OAuth token endpoint per RFC
+// 6749 section 6, authenticating the proxy as the authorization code exchange
+// does with the current client secret or a client assertion
+func newRefreshFunc(cfg Config, client *http.Client, clientSecret func() string, assertions verify.AssertionSigner) renewal.RefreshFunc {
+	return func(ctx context.Context, refreshToken string) (*deviceflow.TokenResponse, error) {
+		data := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+			"client_id":     {cfg.OAuth.ClientID},
+		}
+		if assertions != nil {
+			assertion, err := assertions.Sign()
+			if err != nil {
+				return nil, fmt.Errorf("signing client assertion: %w", err)
+			}
+			data.Set("client_assertion_type", oauth.ClientAssertionType)
+			data.Set("client_assertion", assertion)
+		} else {
+			data.Set("client_secret", clientSecret())
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.OAuth.TokenEndpoint, strings.NewReader(data.Encode()))
+		if err != nil {
+			return nil, fmt.Errorf("creating refresh request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("sending refresh request: %w", err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponse))
+		if err != nil {
+			return nil, fmt.Errorf("reading refresh response: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			var errResp oauth.ProviderError
+			_ = json.Unmarshal(body, &errResp)
+			if errResp.Code == deviceflow.ErrorCodeInvalidGrant {
+				return nil, fmt.Errorf("%w: %s", renewal.ErrGrantRevoked, errResp.Description)
+			}
+			if errResp.Code != "" {
+				return nil, fmt.Errorf("refresh request failed: %w", &errResp)
+			}
+			return nil, fmt.Errorf("refresh request failed: %s", resp.Status)
+		}
+
+		var token deviceflow.TokenResponse
+		if err := json.Unmarshal(body, &token); err != nil {
+			return nil, fmt.Errorf("parsing refresh response: %w", err)
+		}
+		if token.AccessToken == "" {
+			return nil, fmt.Errorf("refresh response has no access token")
+		}
+		return &token, nil
+	}
+}
+
+// newRevokeFunc revokes refresh tokens at the OAuth revocation endpoint per
+// RFC 7009, authenticating the proxy as newRefreshFunc does
+func newRevokeFunc(cfg Config, client *http.Client, clientSecret func() string, assertions verify.AssertionSigner) renewal.RevokeFunc {
+	return func(ctx context.Context, refreshToken string) error {
+		data := url.Values{
+			"token":           {refreshToken},
+			"token_type_hint": {"refresh_token"},
+			"client_id":       {cfg.OAuth.ClientID},
+		}
+		if assertions != nil {
+			assertion, err := assertions.Sign()
+			if err != nil {
+				return fmt.Errorf("signing client assertion: %w", err)
+			}
+			data.Set("client_assertion_type", oauth.ClientAssertionType)
+			data.Set("client_assertion", assertion)
+		} else {
+			data.Set("client_secret", clientSecret())
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, revocationEndpoint(cfg), strings.NewReader(data.Encode()))
+		if err != nil {
+			return fmt.Errorf("creating revocation request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("sending revocation request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		// Unknown and already revoked tokens also yield 200 per RFC 7009 section 2.2
+		if resp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, maxTokenResponse))
+			var errResp oauth.ProviderError
+			if json.Unmarshal(body, &errResp) == nil && errResp.Code != "" {
+				return fmt.Errorf("revocation request failed: %w", &errResp)
+			}
+			return fmt.Errorf("revocation request failed: %s", resp.Status)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/oauth/keycloaktest"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+)
+
+// renewalConfig points the proxy's OAuth client at a mock Keycloak
+func renewalConfig(kc *keycloaktest.Server) Config {
+	var cfg Config
+	cfg.OAuth.ClientID = "device-proxy"
+	cfg.OAuth.TokenEndpoint = kc.EndpointURL(keycloaktest.Token)
+	cfg.OAuth.RevocationEndpoint = kc.EndpointURL(keycloaktest.Revoke)
+	return cfg
+}
+
+func TestRefreshFunc(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    keycloaktest.Response
+		wantRevoked bool
+		wantErr     bool
+	}{
+		{name: "success", response: keycloaktest.TokenSuccess},
+		{name: "invalid grant", response: keycloaktest.InvalidGrant, wantRevoked: true, wantErr: true},
+		{name: "server error", response: keycloaktest.ServerError, wantErr: true},
+		{name: "proxy error page", response: keycloaktest.BadGateway, wantErr: true},
+		{name: "malformed JSON", response: keycloaktest.MalformedJSON, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kc := keycloaktest.NewServer("test")
+			defer kc.Close()
+			kc.Respond(keycloaktest.Token, tt.response)
+
+			refresh := newRefreshFunc(renewalConfig(kc), http.DefaultClient, func() string { return "secret" }, nil)
+			token, err := refresh(context.Background(), "refresh")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("refresh error = %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, renewal.ErrGrantRevoked) != tt.wantRevoked {
+				t.Errorf("refresh error = %v, want ErrGrantRevoked %v", err, tt.wantRevoked)
+			}
+			if err == nil && (token.AccessToken == "" || token.RefreshToken == "") {
+				t.Errorf("token = %+v, want the recorded tokens", token)
+			}
+
+			form := kc.Requests(keycloaktest.Token)[0]
+			if form.Get("grant_type") != "refresh_token" || form.Get("client_secret") != "secret" {
+				t.Errorf("refresh request = %v", form)
+			}
+		})
+	}
+}
+
+func TestRevokeFunc(t *testing.T) {
+	tests := []struct {
+		name     string
+		response keycloaktest.Response
+		wantErr  bool
+	}{
+		{name: "revoked", response: keycloaktest.RevokeSuccess},
+		{name: "unsupported token type", response: keycloaktest.UnsupportedTokenType, wantErr: true},
+		{name: "server error", response: keycloaktest.ServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kc := keycloaktest.NewServer("test")
+			defer kc.Close()
+			kc.Respond(keycloaktest.Revoke, tt.response)
+
+			revoke := newRevokeFunc(renewalConfig(kc), http.DefaultClient, func() string { return "secret" }, nil)
+			if err := revoke(context.Background(), "refresh"); (err != nil) != tt.wantErr {
+				t.Errorf("revoke error = %v, want error %v", err, tt.wantErr)
+			}
+
+			form := kc.Requests(keycloaktest.Revoke)[0]
+			if form.Get("token") != "refresh" || form.Get("token_type_hint") != "refresh_token" {
+				t.Errorf("revocation request = %v", form)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wrale/oauth2-device-proxy/internal/envelope"
+	"github.com/wrale/oauth2-device-proxy/internal/secrets"
+)
+
+// configSecrets are the configuration secrets rotated while the proxy runs
+type configSecrets struct {
+	clientSecret   *secrets.Secret
+	csrfSecret     *secrets.Secret
+	encryptionKeys *secrets.Secret // Nil when store encryption is disabled
+	powSecret      *secrets.Secret // Nil when proof of work challenges are signed with the CSRF secret
+}
+
+// loadSecrets resolves secrets configured as file: or vault: references,
+// replacing the references in cfg with their current values. Secrets other
+// than the OAuth client secret, CSRF secret, store encryption keys and proof
+// of work secret are read once at startup.
+func loadSecrets(ctx context.Context, cfg *Config) (*configSecrets, error) {
+	resolver := &secrets.Resolver{VaultAddr: cfg.VaultAddr, VaultToken: cfg.VaultToken}
+
+	var loaded configSecrets
+	var err error
+	if loaded.clientSecret, err = resolver.Load(ctx, cfg.OAuth.ClientSecret); err != nil {
+		return nil, fmt.Errorf("OAUTH_CLIENT_SECRET: %w", err)
+	}
+	cfg.OAuth.ClientSecret = loaded.clientSecret.Value()
+
+	if loaded.csrfSecret, err = resolver.Load(ctx, cfg.CSRFSecret); err != nil {
+		return nil, fmt.Errorf("CSRF_SECRET: %w", err)
+	}
+	cfg.CSRFSecret = loaded.csrfSecret.Value()
+
+	if cfg.StoreEncryptionKeys != "" {
+		if loaded.encryptionKeys, err = resolver.Load(ctx, cfg.StoreEncryptionKeys); err != nil {
+			return nil, fmt.Errorf("STORE_ENCRYPTION_KEYS: %w", err)
+		}
+		cfg.StoreEncryptionKeys = loaded.encryptionKeys.Value()
+	}
+
+	if cfg.ProofOfWorkSecret != "" {
+		if loaded.powSecret, err = resolver.Load(ctx, cfg.ProofOfWorkSecret); err != nil {
+			return nil, fmt.Errorf("DEVICE_POW_SECRET: %w", err)
+		}
+		cfg.ProofOfWorkSecret = loaded.powSecret.Value()
+	}
+
+	static := []struct {
+		name  string
+		value *string
+	}{
+		{"SESSION_SECRET", &cfg.SessionSecret},
+		{"WEBHOOK_SECRET", &cfg.WebhookSecret},
+		{"ADMIN_TOKEN", &cfg.AdminToken},
+		{"OAUTH_CLIENT_ASSERTION_KEY", &cfg.OAuth.ClientAssertionKey},
+		{"KEYCLOAK_ADMIN_CLIENT_SECRET", &cfg.KeycloakAdminClientSecret},
+		{"SMTP_PASSWORD", &cfg.SMTPPassword},
+		{"NOTIFY_WEBHOOK_SECRET", &cfg.NotifyWebhookSecret},
+		{"INTROSPECTION_CLIENTS", &cfg.IntrospectionClients},
+	}
+	for _, s := range static {
+		if *s.value, err = resolver.Resolve(ctx, *s.value); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.name, err)
+		}
+	}
+
+	return &loaded, nil
+}
+
+// watch refreshes the rotatable secrets until ctx is done
+func (s *configSecrets) watch(ctx context.Context, cfg Config) {
+	watched := []*secrets.Secret{s.clientSecret, s.csrfSecret}
+	if s.encryptionKeys != nil {
+		watched = append(watched, s.encryptionKeys)
+	}
+	if s.powSecret != nil {
+		watched = append(watched, s.powSecret)
+	}
+	secrets.Watch(ctx, cfg.SecretsRefreshInterval, watched...)
+}
+
+// newKeyWrapper creates the wrapper of store encryption keys from a key list
+func newKeyWrapper(spec string) (*envelope.LocalKeys, error) {
+	keys, err := envelope.ParseKeys(spec)
+	if err != nil {
+		return nil, err
+	}
+	return envelope.NewLocalKeys(keys...)
+}
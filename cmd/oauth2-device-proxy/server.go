@@ -2,34 +2,98 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"net/http"
-	"time"
+	"net/url"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
+	chimw "github.com/go-chi/chi/v5/middleware"
 	"golang.org/x/oauth2"
 
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/admin"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/debug"
 	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/device"
 	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/health"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/introspect"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/passthrough"
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/testhook"
 	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/token"
 	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/verify"
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/buildinfo"
+	"github.com/wrale/oauth2-device-proxy/internal/clients"
 	"github.com/wrale/oauth2-device-proxy/internal/csrf"
 	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/features"
+	"github.com/wrale/oauth2-device-proxy/internal/geo"
+	"github.com/wrale/oauth2-device-proxy/internal/httpclient"
+	"github.com/wrale/oauth2-device-proxy/internal/httpsonly"
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+	"github.com/wrale/oauth2-device-proxy/internal/middleware"
+	"github.com/wrale/oauth2-device-proxy/internal/notify"
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+	"github.com/wrale/oauth2-device-proxy/internal/policy"
+	"github.com/wrale/oauth2-device-proxy/internal/redact"
+	"github.com/wrale/oauth2-device-proxy/internal/renewal"
+	"github.com/wrale/oauth2-device-proxy/internal/session"
+	"github.com/wrale/oauth2-device-proxy/internal/stats"
 	"github.com/wrale/oauth2-device-proxy/internal/templates"
+	"github.com/wrale/oauth2-device-proxy/internal/throttle"
+	"github.com/wrale/oauth2-device-proxy/internal/tokencache"
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
 )
 
 type server struct {
-	cfg Config
-	mux *chi.Mux
+	cfg   Config
+	mux   *chi.Mux
+	admin *chi.Mux // Internal listener for operator endpoints, nil when they are served by mux
+}
+
+// dependencies holds the services shared by the HTTP handlers
+type dependencies struct {
+	flow     deviceflow.Flow
+	degraded *deviceflow.DegradedStore // Keeps polls working while Redis fails, optional
+	csrf     *csrf.Manager
+	sessions *session.Manager
+	audit    audit.Logger
+	clients  *clients.Registry
+	upstream *httpclient.Client
+	idTokens verify.IDTokenValidator
+	provider oauth.Provider  // Calls the identity provider on the proxy's behalf, required for token exchange
+	stats    *stats.Recorder // Conversion stats, recorded only when the admin API is enabled
+	features *features.Set   // Feature flags checked per client
+
+	clientSecret func() string               // Current OAuth client secret, optional
+	assertions   verify.AssertionSigner      // Authenticates the proxy with private_key_jwt, optional
+	clientAuth   *common.ClientAuthenticator // Authenticates confidential device clients
+	proofOfWork  *device.ProofOfWork         // Challenges public device clients, optional
+	renewer      *renewal.Renewer            // Renews access tokens when refresh tokens stay on the proxy, optional
+	issued       *tokencache.IssuedTokens    // Records the client each token was delivered to, required for token exchange
+	throttle     *throttle.Limiter           // Limits code entry per IP, optional
+	rateLimits   throttle.Store              // Counts requests for the rate_limit middleware, in memory if nil
+	https        httpsonly.Config            // Plain HTTP handling, off when zero
+	policy       policy.Policy               // Decides whether authorizations may proceed, optional
+	notifier     notify.Notifier             // Tells users about devices they authorized, optional
+	storePool    func() debug.PoolStats      // Redis connection pool counters for runtime diagnostics, optional
 }
 
 // newServer creates a new HTTP server that implements RFC 8628 device authorization flows
-func newServer(cfg Config, flow deviceflow.Flow, csrfManager *csrf.Manager) (*server, error) {
+func newServer(cfg Config, deps dependencies) (*server, error) {
+	flow := deps.flow
+
 	// Load templates
 	tmpls, err := templates.LoadTemplates()
 	if err != nil {
 		return nil, fmt.Errorf("loading templates: %w", err)
 	}
+	brand, err := newBrand(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("configuring brand: %w", err)
+	}
+	tmpls.SetBrand(brand)
+	tmpls.SetQRCacheSize(cfg.QRCacheSize)
 
 	// Configure OAuth client
 	oauth := &oauth2.Config{
@@ -44,42 +108,252 @@ func newServer(cfg Config, flow deviceflow.Flow, csrfManager *csrf.Manager) (*se
 
 	// Initialize handlers per RFC 8628 requirements:
 	// - /health for server status
+	// - /version for build details
 	// - /device/code for authorization requests (§3.1-3.2)
+	// - /device/code/refresh for replacing an unused user code
 	// - /device/token for token requests (§3.4-3.5)
+	// - /token/current for renewing access tokens when the proxy keeps refresh tokens
+	// - /token/exchange for exchanging access tokens for other audiences (RFC 8693)
 	// - /device for user interaction (§3.3)
-	healthHandler := health.New(flow)
-	deviceHandler := device.New(flow)
-	tokenHandler := token.New(token.Config{Flow: flow})
-	verifyHandler := verify.New(verify.Config{
+	// - /my/devices for users managing the devices they authorized
+	// - /introspect for resource servers validating tokens (RFC 7662)
+	// - /token for provisioning tools using the client_credentials grant
+	build := buildinfo.Get().WithFeatures(append(enabledFeatures(cfg), deps.features.Names()...)...)
+	healthHandler := health.New(flow).
+		WithBuildInfo(build).
+		WithDependency("device_code_capacity", flow.CheckCapacity)
+	if deps.degraded != nil {
+		healthHandler.WithDependency("device_store", deps.degraded.CheckBackend)
+	}
+	if deps.csrf != nil {
+		healthHandler.WithDependency("csrf_store", deps.csrf.CheckHealth)
+	}
+	var upstreamClient *http.Client
+	if deps.upstream != nil {
+		upstreamClient = deps.upstream.HTTPClient()
+		healthHandler.WithDependency("identity_provider", deps.upstream.CheckHealth)
+	}
+	extensions, err := newExtensions(cfg, brand)
+	if err != nil {
+		return nil, fmt.Errorf("configuring response extensions: %w", err)
+	}
+	deviceHandler := device.New(flow).
+		WithLocator(newLocator(cfg)).
+		WithForwardedParams(cfg.ForwardedAuthParams).
+		WithClientAuth(deps.clientAuth).
+		WithExtensions(extensions).
+		WithProofOfWork(deps.proofOfWork)
+	tokenCfg := token.Config{
+		Flow:           flow,
+		IncludeIDToken: cfg.IncludeIDToken,
+		StreamTimeout:  cfg.TokenStreamTimeout,
+		ClientAuth:     deps.clientAuth,
+		Features:       deps.features,
+	}
+	if deps.renewer != nil {
+		tokenCfg.Renewer = deps.renewer
+	}
+
+	// Calls the proxy makes to the identity provider on behalf of devices and
+	// resource servers authenticate with its current client credentials
+	clientSecret := deps.clientSecret
+	if clientSecret == nil {
+		clientSecret = func() string { return cfg.OAuth.ClientSecret }
+	}
+	proxyClient := upstreamClient
+	if proxyClient == nil {
+		proxyClient = http.DefaultClient
+	}
+	if len(cfg.TokenExchangeAudiences) > 0 && deps.provider != nil {
+		tokenCfg.Exchange = deps.provider.ExchangeToken
+		tokenCfg.ExchangeAudiences = cfg.TokenExchangeAudiences
+		tokenCfg.SubjectClient = newSubjectClientFunc(deps.issued, deps.renewer)
+	}
+	tokenHandler := token.New(tokenCfg)
+	verifyCfg := verify.Config{
 		Flow:      flow,
 		Templates: tmpls,
-		CSRF:      csrfManager,
+		CSRF:      deps.csrf,
+		Sessions:  deps.sessions,
 		OAuth:     oauth,
 		BaseURL:   cfg.BaseURL,
-	})
+		Audit:     deps.audit,
+		Clients:   deps.clients,
+		Consent:   cfg.ShortCodeOnly, // Otherwise gated by the ConsentScreen flag
+		IDTokens:  deps.idTokens,
+		Throttle:  deps.throttle,
+		Offline:   cfg.OfflineAccess,
+		Features:  deps.features,
+		Policy:    deps.policy,
+		Notifier:  deps.notifier,
+		RevokeURL: notifyRevokeURL(cfg),
+
+		ClientSecret:     deps.clientSecret,
+		Assertions:       deps.assertions,
+		HTTPClient:       upstreamClient,
+		UserinfoEndpoint: cfg.OAuth.UserinfoEndpoint,
+	}
+	if deps.renewer != nil {
+		verifyCfg.DeviceGrants = deps.renewer
+	}
+	verifyHandler := verify.New(verifyCfg)
 
 	srv := &server{
 		cfg: cfg,
 		mux: chi.NewRouter(),
 	}
 
-	// Set up middleware stack
-	srv.mux.Use(middleware.Logger)
-	srv.mux.Use(middleware.Recoverer)
-	srv.mux.Use(middleware.RealIP)
-	srv.mux.Use(middleware.Timeout(30 * time.Second))
+	// Set up the middleware chain
+	chainCfg := middleware.Config{
+		Order:        cfg.Middlewares,
+		LogFormatter: redactingLogFormatter{middleware.DefaultLogFormatter()},
+		HTTPS:        deps.https,
+		Timeout:      cfg.RequestTimeout,
+		RateLimit: middleware.RateLimitConfig{
+			Store:       deps.rateLimits,
+			Window:      cfg.HTTPRateLimitWindow,
+			MaxRequests: cfg.HTTPRateLimit,
+			Exempt:      []string{"/health", "/metrics"},
+		},
+		SecurityHeaders: middleware.SecurityHeadersConfig{HSTSMaxAge: cfg.HSTSMaxAge},
+	}
+	chain, err := middleware.Chain(chainCfg)
+	if err != nil {
+		return nil, fmt.Errorf("configuring MIDDLEWARES: %w", err)
+	}
+	srv.mux.Use(chain...)
+
+	// Operator endpoints move to an internal listener when ADMIN_PORT is set,
+	// leaving the public one to the device flow. Its peers are operators and
+	// scrapers, so it neither enforces HTTPS nor limits request rates.
+	ops := srv.mux
+	if cfg.AdminPort != 0 {
+		chainCfg.Order = middleware.Without(cfg.Middlewares, middleware.NameHTTPS, middleware.NameRateLimit)
+		adminChain, err := middleware.Chain(chainCfg)
+		if err != nil {
+			return nil, fmt.Errorf("configuring MIDDLEWARES: %w", err)
+		}
+		srv.admin = chi.NewRouter()
+		srv.admin.Use(adminChain...)
+		srv.admin.Handle("/health", healthHandler)
+		ops = srv.admin
+	}
 
 	// Register routes
 	srv.mux.Handle("/health", healthHandler)
+	srv.mux.Handle("/version", buildinfo.Handler(build))
+	ops.Handle("/metrics", metrics.Default.Handler())
+	if srv.admin != nil {
+		runtimeHandler := debug.New()
+		if deps.storePool != nil {
+			runtimeHandler.WithPool("redis", deps.storePool)
+		}
+		srv.admin.Handle(debug.RuntimePath, runtimeHandler)
+	}
+	if cfg.Pprof {
+		ops.Mount("/debug", chimw.Profiler())
+	}
+	srv.mux.Handle(templates.AssetPrefix+"*", templates.AssetHandler())
+	srv.mux.Handle(templates.QRPrefix+"*", tmpls.QRCodeHandler())
+
+	// Device flow JSON errors follow the client's Accept-Language
+	deviceAPI := srv.mux.With(common.LocalizeErrors(cfg.ErrorURIBase))
+
+	// Service account tokens for provisioning tools enrolling devices without
+	// a user, forwarded for the clients the registry enables
+	if cfg.ClientCredentials {
+		credentialsHandler := passthrough.New(passthrough.Config{
+			TokenEndpoint: cfg.OAuth.TokenEndpoint,
+			HTTPClient:    upstreamClient,
+			Clients:       deps.clients,
+		})
+		deviceAPI.Post(passthrough.ClientCredentialsPath, credentialsHandler.ServeClientCredentials)
+	}
+
+	// Forward the device flow to an identity provider implementing it
+	// natively, or run it here
+	if cfg.DevicePassthrough {
+		passthroughHandler := passthrough.New(passthrough.Config{
+			DeviceEndpoint:  upstreamDeviceEndpoint(cfg),
+			TokenEndpoint:   cfg.OAuth.TokenEndpoint,
+			VerificationURI: upstreamVerificationURI(cfg),
+			BaseURL:         cfg.BaseURL,
+			Templates:       tmpls,
+			HTTPClient:      upstreamClient,
+		})
+		deviceAPI.Post("/device/code", passthroughHandler.ServeDeviceCode)
+		deviceAPI.Post("/device/token", passthroughHandler.ServeToken)
+		srv.mux.Get("/device", passthroughHandler.HandleForm)
+		srv.mux.Post("/device", passthroughHandler.HandleSubmit)
+	} else {
+		// Device authorization endpoints (RFC 8628)
+		deviceAPI.Handle("/device/code", deviceHandler) // §3.1-3.2
+		deviceAPI.Post("/device/code/refresh", deviceHandler.ServeRefresh)
+		deviceAPI.Handle("/device/token", tokenHandler) // §3.4-3.5
+		if cfg.TokenStream {
+			deviceAPI.Post("/device/token/stream", tokenHandler.ServeStream)
+		}
 
-	// Device authorization endpoints (RFC 8628)
-	srv.mux.Handle("/device/code", deviceHandler) // §3.1-3.2
-	srv.mux.Handle("/device/token", tokenHandler) // §3.4-3.5
+		// User verification endpoints - §3.3
+		srv.mux.Get("/device", verifyHandler.HandleForm)
+		srv.mux.Post("/device", verifyHandler.HandleSubmit)
+		srv.mux.Post("/device/consent", verifyHandler.HandleConsent)
+		srv.mux.Get("/device/complete", verifyHandler.HandleComplete)
+		srv.mux.Get("/device/status", verifyHandler.HandleStatus)
+		srv.mux.Get(verify.ShortLinkPrefix+"{code}", verifyHandler.HandleShortLink)
+	}
+
+	// Token endpoints for devices holding access tokens
+	if deps.renewer != nil {
+		deviceAPI.Get("/token/current", tokenHandler.ServeCurrent)
+	}
+	if len(cfg.TokenExchangeAudiences) > 0 {
+		deviceAPI.Post(token.ExchangePath, tokenHandler.ServeExchange) // RFC 8693
+	}
+
+	// Self-service management of the devices a user authorized
+	if cfg.DevicesPage {
+		srv.mux.Get(verify.DevicesPath, verifyHandler.HandleDevices)
+		srv.mux.Get(verify.DevicesCallbackPath, verifyHandler.HandleDevicesCallback)
+		srv.mux.Post(verify.DevicesRevokePath, verifyHandler.HandleRevokeDevice)
+	}
+
+	// Token introspection for resource servers, RFC 7662
+	if cfg.IntrospectionClients != "" {
+		resourceServers, err := introspect.ParseResourceServers(cfg.IntrospectionClients)
+		if err != nil {
+			return nil, fmt.Errorf("configuring INTROSPECTION_CLIENTS: %w", err)
+		}
+		deviceAPI.Handle(introspect.Path, introspect.New(introspect.Config{
+			Introspect:      newIntrospectFunc(cfg, proxyClient, clientSecret, deps.assertions),
+			ResourceServers: resourceServers,
+			CacheTTL:        cfg.IntrospectionCacheTTL,
+			MaxEntries:      cfg.IntrospectionCacheLimit,
+		}))
+	}
+
+	// Headless approval for automated tests, never enabled in production
+	if cfg.TestHook {
+		log.Printf("Warning: test hook enabled, %s approves device codes without authentication", testhook.ApprovePath)
+		hook := testhook.New(testhook.Config{Flow: flow, Token: cfg.TestHookToken, Audit: deps.audit})
+		srv.mux.Post(testhook.ApprovePath, hook.ServeApprove)
+	}
 
-	// User verification endpoints - §3.3
-	srv.mux.Get("/device", verifyHandler.HandleForm)
-	srv.mux.Post("/device", verifyHandler.HandleSubmit)
-	srv.mux.Get("/device/complete", verifyHandler.HandleComplete)
+	// Operator endpoints are only exposed when an admin token is configured
+	if cfg.AdminToken != "" {
+		adminCfg := admin.Config{
+			Token: cfg.AdminToken,
+			Flow:  flow,
+			Audit: deps.audit,
+		}
+		if deps.stats != nil {
+			adminCfg.Stats = deps.stats
+		}
+		if deps.renewer != nil {
+			adminCfg.Grants = deps.renewer
+		}
+		ops.Mount("/admin", admin.New(adminCfg))
+	}
 
 	return srv, nil
 }
@@ -88,3 +362,158 @@ func newServer(cfg Config, flow deviceflow.Flow, csrfManager *csrf.Manager) (*se
 func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.mux.ServeHTTP(w, r)
 }
+
+// upstreamDeviceEndpoint returns the identity provider's device authorization
+// endpoint, defaulting to the Keycloak realm's
+func upstreamDeviceEndpoint(cfg Config) string {
+	if cfg.UpstreamDeviceEndpoint != "" {
+		return cfg.UpstreamDeviceEndpoint
+	}
+	return keycloakRealmURL(cfg) + "/protocol/openid-connect/auth/device"
+}
+
+// upstreamVerificationURI returns the identity provider's verification page,
+// defaulting to the Keycloak realm's
+func upstreamVerificationURI(cfg Config) string {
+	if cfg.UpstreamVerificationURI != "" {
+		return cfg.UpstreamVerificationURI
+	}
+	return keycloakRealmURL(cfg) + "/device"
+}
+
+// redactingLogFormatter logs requests as chi's middleware.Logger does, with
+// device codes, user codes, tokens and credentials removed from request targets
+type redactingLogFormatter struct {
+	chimw.LogFormatter
+}
+
+// NewLogEntry implements middleware.LogFormatter
+func (f redactingLogFormatter) NewLogEntry(r *http.Request) chimw.LogEntry {
+	redacted := r.WithContext(r.Context())
+	redacted.RequestURI = redact.RequestURI(r.RequestURI, verify.ShortLinkPrefix)
+	return f.LogFormatter.NewLogEntry(redacted)
+}
+
+// enabledFeatures names the optional features turned on by configuration, as
+// reported by /version and /health alongside the feature flags
+func enabledFeatures(cfg Config) []string {
+	features := map[string]bool{
+		"admin_api":             cfg.AdminToken != "",
+		"admin_listener":        cfg.AdminPort != 0,
+		"client_credentials":    cfg.ClientCredentials,
+		"client_metadata":       cfg.KeycloakAdminClientID != "",
+		"degraded_mode":         cfg.DegradedMode,
+		"device_callbacks":      cfg.DeviceCallbacks,
+		"device_passthrough":    cfg.DevicePassthrough,
+		"devices_page":          cfg.DevicesPage,
+		"enforce_https":         cfg.EnforceHTTPS != "" && cfg.EnforceHTTPS != string(httpsonly.ModeOff),
+		"id_token":              cfg.IncludeIDToken,
+		"introspection":         cfg.IntrospectionClients != "",
+		"notifications":         cfg.NotifyBackend != "" && cfg.NotifyBackend != "none",
+		"offline_access":        cfg.OfflineAccess,
+		"poll_cache":            cfg.PollCache,
+		"policy":                cfg.PolicyURL != "",
+		"private_key_jwt":       cfg.OAuth.ClientAssertionKey != "",
+		"proof_of_work":         cfg.ProofOfWork,
+		"response_extensions":   cfg.ResponseExtensions,
+		"short_code_only":       cfg.ShortCodeOnly,
+		"test_hook":             cfg.TestHook,
+		"token_exchange":        len(cfg.TokenExchangeAudiences) > 0,
+		"token_renewal":         cfg.TokenRenewal,
+		"token_stream":          cfg.TokenStream,
+		"verification_complete": cfg.VerificationURIComplete && !cfg.ShortCodeOnly,
+	}
+	var enabled []string
+	for name, on := range features {
+		if on {
+			enabled = append(enabled, name)
+		}
+	}
+	return enabled
+}
+
+// revocationEndpoint returns the OAuth token revocation endpoint, defaulting
+// to the Keycloak realm's
+func revocationEndpoint(cfg Config) string {
+	if cfg.OAuth.RevocationEndpoint != "" {
+		return cfg.OAuth.RevocationEndpoint
+	}
+	return keycloakRealmURL(cfg) + "/protocol/openid-connect/revoke"
+}
+
+// notifyRevokeURL returns where notified users remove a device's access,
+// defaulting to the applications page of the Keycloak account console
+func notifyRevokeURL(cfg Config) string {
+	if cfg.NotifyRevokeURL != "" {
+		return cfg.NotifyRevokeURL
+	}
+	return keycloakRealmURL(cfg) + "/account/applications"
+}
+
+// keycloakRealmURL returns the base URL of the configured Keycloak realm
+func keycloakRealmURL(cfg Config) string {
+	return strings.TrimRight(cfg.KeycloakURL, "/") + "/realms/" + url.PathEscape(cfg.KeycloakRealm)
+}
+
+// newLocator reads request locations from edge proxy headers when configured
+func newLocator(cfg Config) geo.Locator {
+	if cfg.GeoCityHeader == "" && cfg.GeoRegionHeader == "" && cfg.GeoCountryHeader == "" {
+		return geo.NopLocator{}
+	}
+	return geo.HeaderLocator{
+		CityHeader:    cfg.GeoCityHeader,
+		RegionHeader:  cfg.GeoRegionHeader,
+		CountryHeader: cfg.GeoCountryHeader,
+	}
+}
+
+// newExtensions enables the optional device code response members when
+// configured, describing the hosted page branding to clients
+func newExtensions(cfg Config, brand templates.Brand) (*device.Extensions, error) {
+	if !cfg.ResponseExtensions {
+		return nil, nil
+	}
+	qr, err := templates.NewQRRenderer(cfg.ResponseQRFormat)
+	if err != nil {
+		return nil, err
+	}
+	ext := &device.Extensions{UserCodeFormat: true, QRRenderer: qr}
+	if brand.ProductName != "" || brand.LogoURL != "" || brand.PrimaryColor != "" {
+		logo := brand.LogoURL
+		if strings.HasPrefix(logo, "/") {
+			logo = cfg.BaseURL + logo // Paths are served by this proxy
+		}
+		ext.Branding = &device.Branding{
+			ProductName:  brand.ProductName,
+			LogoURI:      logo,
+			PrimaryColor: brand.PrimaryColor,
+		}
+	}
+	return ext, nil
+}
+
+// newBrand builds the hosted page branding from configuration
+func newBrand(cfg Config) (templates.Brand, error) {
+	links, err := templates.ParseFooterLinks(cfg.BrandFooterLinks)
+	if err != nil {
+		return templates.Brand{}, err
+	}
+	codeFormat, err := validation.ParseDisplayFormat(cfg.UserCodeDisplayFormat)
+	if err != nil {
+		return templates.Brand{}, err
+	}
+
+	brand := templates.Brand{
+		ProductName:  cfg.BrandProductName,
+		LogoURL:      cfg.BrandLogoURL,
+		PrimaryColor: cfg.BrandPrimaryColor,
+		FooterLinks:  links,
+		CodeFormat:   codeFormat,
+		Accessibility: templates.Accessibility{
+			HighContrast: cfg.HighContrast,
+			LargeCode:    cfg.LargeCode,
+			SpellOutCode: cfg.SpellOutCode,
+		},
+	}
+	return brand, brand.Validate()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/common/test"
+	"github.com/wrale/oauth2-device-proxy/internal/httpsonly"
+)
+
+func TestAdminListener(t *testing.T) {
+	cfg := Config{
+		BaseURL:    "https://example.com",
+		AdminToken: "secret",
+		AdminPort:  9090,
+		Pprof:      true,
+	}
+	srv, err := newServer(cfg, dependencies{
+		flow:  &test.MockFlow{},
+		https: httpsonly.Config{Mode: httpsonly.ModeReject, Exempt: []string{"/health"}},
+	})
+	if err != nil {
+		t.Fatalf("newServer failed: %v", err)
+	}
+	if srv.admin == nil {
+		t.Fatal("no admin listener with ADMIN_PORT set")
+	}
+
+	get := func(h http.Handler, path string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	// Operator endpoints leave the public listener
+	for _, path := range []string{"/metrics", "/admin/stats", "/debug/pprof/", "/debug/runtime"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.TLS = &tls.ConnectionState{}
+		w := httptest.NewRecorder()
+		srv.mux.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("public %s status code = %d, want %d", path, w.Code, http.StatusNotFound)
+		}
+	}
+
+	// The internal listener serves plain HTTP without the public chain's
+	// HTTPS enforcement
+	if code := get(srv.admin, "/metrics"); code != http.StatusOK {
+		t.Errorf("admin /metrics status code = %d, want %d", code, http.StatusOK)
+	}
+	if code := get(srv.admin, "/debug/pprof/"); code != http.StatusOK {
+		t.Errorf("admin /debug/pprof/ status code = %d, want %d", code, http.StatusOK)
+	}
+	if code := get(srv.admin, "/debug/runtime"); code != http.StatusOK {
+		t.Errorf("admin /debug/runtime status code = %d, want %d", code, http.StatusOK)
+	}
+	if code := get(srv.admin, "/admin/stats"); code != http.StatusUnauthorized {
+		t.Errorf("admin /admin/stats without token status code = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := get(srv.admin, "/device/code"); code != http.StatusNotFound {
+		t.Errorf("admin /device/code status code = %d, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestValidateAdminListener(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "disabled", cfg: Config{Port: 8080}},
+		{name: "separate port", cfg: Config{Port: 8080, AdminPort: 9090, Pprof: true}},
+		{name: "pprof on the public listener", cfg: Config{Port: 8080, Pprof: true}, wantErr: true},
+		{name: "same port", cfg: Config{Port: 8080, AdminPort: 8080}, wantErr: true},
+		{name: "invalid port", cfg: Config{Port: 8080, AdminPort: 70000}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateAdminListener(tt.cfg); (err != nil) != tt.wantErr {
+				t.Errorf("validateAdminListener() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPassthroughKeepsOperatorRoutes(t *testing.T) {
+	cfg := Config{
+		BaseURL:           "https://example.com",
+		AdminToken:        "secret",
+		DevicePassthrough: true,
+	}
+	srv, err := newServer(cfg, dependencies{flow: &test.MockFlow{}})
+	if err != nil {
+		t.Fatalf("newServer failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/clients/tv/codes", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("/admin status code = %d, want %d with DEVICE_PASSTHROUGH", w.Code, http.StatusOK)
+	}
+
+	// The proxy's own verification flow stays off
+	w = httptest.NewRecorder()
+	srv.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/device/status", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("/device/status status code = %d, want %d with DEVICE_PASSTHROUGH", w.Code, http.StatusNotFound)
+	}
+}
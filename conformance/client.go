@@ -0,0 +1,157 @@
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/cmd/oauth2-device-proxy/handlers/testhook"
+	"github.com/wrale/oauth2-device-proxy/internal/redact"
+)
+
+// Polling behavior specified by RFC 8628
+const (
+	// DefaultPollingInterval is the interval devices use when the device
+	// authorization response has none, per RFC 8628 section 3.2
+	DefaultPollingInterval = 5 * time.Second
+
+	// MaxPollingAttempts bounds polling so that a stuck flow fails the run
+	MaxPollingAttempts = 30
+)
+
+// Approver approves a user code as a user would at the verification URI, per
+// RFC 8628 section 3.3
+type Approver func(ctx context.Context, userCode string) error
+
+// TestHookApprover approves user codes through the proxy's test hook, which
+// the proxy serves with TEST_HOOK enabled. Token is TEST_HOOK_TOKEN, if set.
+func TestHookApprover(baseURL, token string) Approver {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(ctx context.Context, userCode string) error {
+		data := url.Values{"user_code": {userCode}}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+testhook.ApprovePath, strings.NewReader(data.Encode()))
+		if err != nil {
+			return fmt.Errorf("creating approval request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("approval request failed: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			body, _ := io.ReadAll(resp.Body)
+			return fmt.Errorf("approval failed with status %d: %s", resp.StatusCode, body)
+		}
+		return nil
+	}
+}
+
+// runner sends the requests of a conformance run
+type runner struct {
+	baseURL string
+	cfg     config
+}
+
+// post sends a form to the proxy, logging the exchange without codes or tokens
+func (r *runner) post(t *testing.T, path string, data url.Values) (*http.Response, []byte) {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, r.baseURL+path, strings.NewReader(data.Encode()))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if dump, err := httputil.DumpRequestOut(req, true); err == nil {
+		t.Logf("\n=== REQUEST ===\n%s\n", redact.Dump(dump))
+	}
+
+	resp, err := r.cfg.client.Do(req)
+	if err != nil {
+		t.Fatalf("Request to %s failed: %v", path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response from %s: %v", path, err)
+	}
+	t.Logf("\n=== RESPONSE %d ===\n%s\n", resp.StatusCode, redact.Body(resp.Header.Get("Content-Type"), body))
+	return resp, body
+}
+
+// requestDeviceCode sends a device authorization request per RFC 8628 section 3.1
+func (r *runner) requestDeviceCode(t *testing.T, data url.Values) (*http.Response, []byte) {
+	t.Helper()
+	return r.post(t, r.cfg.devicePath, data)
+}
+
+// requestToken sends a device access token request per RFC 8628 section 3.4
+func (r *runner) requestToken(t *testing.T, data url.Values) (*http.Response, []byte) {
+	t.Helper()
+	return r.post(t, r.cfg.tokenPath, data)
+}
+
+// tokenRequest builds a well-formed token request for a device code
+func (r *runner) tokenRequest(deviceCode string) url.Values {
+	return url.Values{
+		"grant_type":  {DeviceCodeGrantType},
+		"device_code": {deviceCode},
+		"client_id":   {r.cfg.clientID},
+	}
+}
+
+// pollForToken polls as RFC 8628 section 3.5 requires of devices until a
+// token is issued, the flow ends or MaxPollingAttempts is reached
+func (r *runner) pollForToken(t *testing.T, auth *DeviceAuthorizationResponse) (*TokenResponse, error) {
+	t.Helper()
+
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval == 0 {
+		interval = DefaultPollingInterval
+	}
+
+	for attempt := 1; attempt <= MaxPollingAttempts; attempt++ {
+		time.Sleep(interval)
+		t.Logf("Token polling attempt %d/%d", attempt, MaxPollingAttempts)
+
+		resp, body := r.requestToken(t, r.tokenRequest(auth.DeviceCode))
+		if resp.StatusCode == http.StatusOK {
+			var token TokenResponse
+			if err := json.Unmarshal(body, &token); err != nil {
+				return nil, fmt.Errorf("failed to decode token response: %w", err)
+			}
+			return &token, nil
+		}
+
+		var errResp ErrorResponse
+		if err := json.Unmarshal(body, &errResp); err != nil {
+			return nil, fmt.Errorf("failed to decode error response: %w", err)
+		}
+		switch errResp.Error {
+		case ErrAuthorizationPending:
+			continue
+		case ErrSlowDown:
+			// The interval grows by 5 seconds for this and all subsequent requests
+			interval += 5 * time.Second
+			continue
+		case ErrAccessDenied:
+			return nil, fmt.Errorf("user denied access")
+		case ErrExpiredToken:
+			return nil, fmt.Errorf("device code expired")
+		default:
+			return nil, fmt.Errorf("unexpected error: %s - %s", errResp.Error, errResp.ErrorDescription)
+		}
+	}
+	return nil, fmt.Errorf("exceeded maximum polling attempts (%d)", MaxPollingAttempts)
+}
@@ -0,0 +1,275 @@
+// Package conformance verifies a running device flow proxy against RFC 8628
+// OAuth 2.0 Device Authorization Grant. Deployments call RunConformance from
+// their own tests to check that their proxy configuration still behaves as
+// the specification requires:
+//
+//	func TestProxyConformance(t *testing.T) {
+//		conformance.RunConformance(t, "https://device.example.com")
+//	}
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Default endpoints of the proxy
+const (
+	DefaultDevicePath = "/device/code"
+	DefaultTokenPath  = "/device/token"
+)
+
+// config holds the settings of a conformance run
+type config struct {
+	clientID   string
+	scope      string
+	devicePath string
+	tokenPath  string
+	client     *http.Client
+	approver   Approver
+	slowDown   bool
+	expiry     bool
+}
+
+// Option configures a conformance run
+type Option func(*config)
+
+// WithClientID sets the client requesting device codes, "test-client" by
+// default. Proxies accepting only registered clients need a registered one.
+func WithClientID(clientID string) Option {
+	return func(c *config) {
+		c.clientID = clientID
+	}
+}
+
+// WithScope sets the scope of device authorization requests
+func WithScope(scope string) Option {
+	return func(c *config) {
+		c.scope = scope
+	}
+}
+
+// WithPaths sets the device authorization and token endpoint paths, for
+// proxies mounted below a prefix
+func WithPaths(devicePath, tokenPath string) Option {
+	return func(c *config) {
+		c.devicePath = devicePath
+		c.tokenPath = tokenPath
+	}
+}
+
+// WithHTTPClient sets the client used to reach the proxy
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *config) {
+		c.client = client
+	}
+}
+
+// WithApprover enables the token issuance checks, approving each user code
+// with approver. Without one they are skipped, since approving a code takes
+// a user signing in.
+func WithApprover(approver Approver) Option {
+	return func(c *config) {
+		c.approver = approver
+	}
+}
+
+// WithSlowDownCheck enables checking that polling faster than the interval
+// is answered with slow_down, per RFC 8628 section 3.5
+func WithSlowDownCheck() Option {
+	return func(c *config) {
+		c.slowDown = true
+	}
+}
+
+// WithExpiryCheck enables checking that device codes expire after
+// expires_in. The run waits out a full device code lifetime.
+func WithExpiryCheck() Option {
+	return func(c *config) {
+		c.expiry = true
+	}
+}
+
+// RunConformance runs the RFC 8628 conformance checks against the proxy at
+// baseURL as subtests of t, named after the sections they cover
+func RunConformance(t *testing.T, baseURL string, opts ...Option) {
+	t.Helper()
+
+	cfg := config{
+		clientID:   "test-client",
+		devicePath: DefaultDevicePath,
+		tokenPath:  DefaultTokenPath,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	r := &runner{baseURL: strings.TrimSuffix(baseURL, "/"), cfg: cfg}
+
+	t.Run("3.1 Request Validation", func(t *testing.T) {
+		t.Run("Missing client_id", func(t *testing.T) {
+			resp, body := r.requestDeviceCode(t, url.Values{})
+			reportIssues(t, "client_id is REQUIRED for public clients (Section 3.1)",
+				ValidateErrorResponse(resp, body, ErrInvalidRequest))
+		})
+
+		t.Run("Duplicate Parameters", func(t *testing.T) {
+			resp, body := r.requestDeviceCode(t, url.Values{"client_id": {cfg.clientID, cfg.clientID + "-2"}})
+			reportIssues(t, "Parameters MUST NOT be included more than once (Section 3.1)",
+				ValidateErrorResponse(resp, body, ErrInvalidRequest))
+		})
+	})
+
+	t.Run("3.2 Device Authorization Response", func(t *testing.T) {
+		auth := r.authorize(t)
+
+		t.Run("3.4 Token Request", func(t *testing.T) {
+			r.testTokenRequest(t, auth)
+		})
+		t.Run("3.5 Error Responses", func(t *testing.T) {
+			r.testErrorResponses(t, auth)
+		})
+		t.Run("3.5 Successful Token Issuance", func(t *testing.T) {
+			if cfg.approver == nil {
+				t.Skip("No approver configured")
+			}
+			r.testTokenIssuance(t, auth)
+		})
+	})
+
+	t.Run("3.5 expired_token", func(t *testing.T) {
+		if !cfg.expiry {
+			t.Skip("Expiry check not enabled")
+		}
+		r.testExpiry(t)
+	})
+}
+
+// authorize requests a device code, validating the response per RFC 8628
+// section 3.2
+func (r *runner) authorize(t *testing.T) *DeviceAuthorizationResponse {
+	t.Helper()
+
+	data := url.Values{"client_id": {r.cfg.clientID}}
+	if r.cfg.scope != "" {
+		data.Set("scope", r.cfg.scope)
+	}
+	resp, body := r.requestDeviceCode(t, data)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Device authorization failed with status %d: %s", resp.StatusCode, body)
+	}
+	reportIssues(t, "Device authorization response headers", ValidateResponseHeaders(resp))
+
+	var auth DeviceAuthorizationResponse
+	if err := json.Unmarshal(body, &auth); err != nil {
+		t.Fatalf("Response not valid JSON: %v", err)
+	}
+	reportIssues(t, "Device authorization response", ValidateDeviceAuthorizationResponse(&auth))
+	return &auth
+}
+
+// testTokenRequest checks that malformed token requests are rejected per RFC
+// 8628 section 3.4
+func (r *runner) testTokenRequest(t *testing.T, auth *DeviceAuthorizationResponse) {
+	t.Run("Missing grant_type", func(t *testing.T) {
+		data := r.tokenRequest(auth.DeviceCode)
+		data.Del("grant_type")
+		resp, body := r.requestToken(t, data)
+		reportIssues(t, "grant_type is REQUIRED (Section 3.4)",
+			ValidateErrorResponse(resp, body, ErrInvalidRequest))
+	})
+
+	t.Run("Missing device_code", func(t *testing.T) {
+		data := r.tokenRequest(auth.DeviceCode)
+		data.Del("device_code")
+		resp, body := r.requestToken(t, data)
+		reportIssues(t, "device_code is REQUIRED (Section 3.4)",
+			ValidateErrorResponse(resp, body, ErrInvalidRequest))
+	})
+
+	t.Run("Invalid grant_type", func(t *testing.T) {
+		data := r.tokenRequest(auth.DeviceCode)
+		data.Set("grant_type", "invalid_grant_type")
+		resp, body := r.requestToken(t, data)
+		reportIssues(t, "grant_type must be "+DeviceCodeGrantType,
+			ValidateErrorResponse(resp, body, ErrUnsupportedGrantType))
+	})
+}
+
+// testErrorResponses checks the errors of pending device codes per RFC 8628
+// section 3.5
+func (r *runner) testErrorResponses(t *testing.T, auth *DeviceAuthorizationResponse) {
+	t.Run("authorization_pending", func(t *testing.T) {
+		resp, body := r.requestToken(t, r.tokenRequest(auth.DeviceCode))
+		reportIssues(t, "Should return authorization_pending before user approval",
+			ValidateErrorResponse(resp, body, ErrAuthorizationPending))
+	})
+
+	t.Run("slow_down", func(t *testing.T) {
+		if !r.cfg.slowDown {
+			t.Skip("Slow down check not enabled")
+		}
+
+		interval := time.Duration(auth.Interval) * time.Second
+		if interval == 0 {
+			interval = DefaultPollingInterval
+		}
+
+		start := time.Now()
+		for i := 0; i < 20 && time.Since(start) < 10*time.Second; i++ {
+			_, body := r.requestToken(t, r.tokenRequest(auth.DeviceCode))
+			var errResp ErrorResponse
+			if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error != ErrSlowDown {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
+			// The increased interval must be enough to stop slow_down
+			time.Sleep(interval + 5*time.Second)
+			_, body = r.requestToken(t, r.tokenRequest(auth.DeviceCode))
+			if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error == ErrSlowDown {
+				t.Error("Got slow_down even after respecting increased interval")
+			}
+			return
+		}
+		t.Error("Rate limiting did not trigger slow_down error")
+	})
+}
+
+// testTokenIssuance approves the device code and polls for its token per RFC
+// 8628 sections 3.3 to 3.5
+func (r *runner) testTokenIssuance(t *testing.T, auth *DeviceAuthorizationResponse) {
+	if err := r.cfg.approver(context.Background(), auth.UserCode); err != nil {
+		t.Fatalf("User verification failed: %v", err)
+	}
+
+	token, err := r.pollForToken(t, auth)
+	if err != nil {
+		t.Fatalf("Token polling failed: %v", err)
+	}
+	reportIssues(t, "Token response", ValidateTokenResponse(token))
+}
+
+// testExpiry checks that a fresh device code is refused with expired_token
+// once expires_in has passed
+func (r *runner) testExpiry(t *testing.T) {
+	auth := r.authorize(t)
+	time.Sleep(time.Duration(auth.ExpiresIn+1) * time.Second)
+
+	resp, body := r.requestToken(t, r.tokenRequest(auth.DeviceCode))
+	reportIssues(t, "Device code should expire after expires_in seconds",
+		ValidateErrorResponse(resp, body, ErrExpiredToken))
+}
+
+// reportIssues fails t with the requirements a check found violated
+func reportIssues(t *testing.T, check string, issues []string) {
+	t.Helper()
+	if len(issues) > 0 {
+		t.Errorf("%s:\n%s", check, strings.Join(issues, "\n"))
+	}
+}
@@ -0,0 +1,135 @@
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeProxy is a minimal RFC 8628 authorization server
+type fakeProxy struct {
+	mu       sync.Mutex
+	approved map[string]bool // By device code
+	codes    map[string]string
+}
+
+func newFakeProxy() *fakeProxy {
+	return &fakeProxy{approved: make(map[string]bool), codes: make(map[string]string)}
+}
+
+func (p *fakeProxy) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (p *fakeProxy) fail(w http.ResponseWriter, code string) {
+	p.writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: code})
+}
+
+func (p *fakeProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		p.fail(w, ErrInvalidRequest)
+		return
+	}
+	for _, values := range r.PostForm {
+		if len(values) > 1 {
+			p.fail(w, ErrInvalidRequest)
+			return
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch r.URL.Path {
+	case DefaultDevicePath:
+		if r.PostForm.Get("client_id") == "" {
+			p.fail(w, ErrInvalidRequest)
+			return
+		}
+		deviceCode := "device-" + r.PostForm.Get("client_id")
+		p.codes["WDJB-MJHT"] = deviceCode
+		p.writeJSON(w, http.StatusOK, DeviceAuthorizationResponse{
+			DeviceCode:      deviceCode,
+			UserCode:        "WDJB-MJHT",
+			VerificationURI: "https://example.com/device",
+			ExpiresIn:       600,
+			Interval:        MinInterval,
+		})
+	case DefaultTokenPath:
+		grantType, deviceCode := r.PostForm.Get("grant_type"), r.PostForm.Get("device_code")
+		switch {
+		case grantType == "":
+			p.fail(w, ErrInvalidRequest)
+		case grantType != DeviceCodeGrantType:
+			p.fail(w, ErrUnsupportedGrantType)
+		case deviceCode == "":
+			p.fail(w, ErrInvalidRequest)
+		case p.approved[deviceCode]:
+			p.writeJSON(w, http.StatusOK, TokenResponse{AccessToken: "token", TokenType: "Bearer", ExpiresIn: 3600})
+		default:
+			p.fail(w, ErrAuthorizationPending)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (p *fakeProxy) approve(_ context.Context, userCode string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.approved[p.codes[userCode]] = true
+	return nil
+}
+
+func TestRunConformance(t *testing.T) {
+	proxy := newFakeProxy()
+	server := httptest.NewServer(proxy)
+	defer server.Close()
+
+	RunConformance(t, server.URL, WithClientID("tv"), WithApprover(proxy.approve))
+}
+
+func TestValidateDeviceAuthorizationResponse(t *testing.T) {
+	valid := DeviceAuthorizationResponse{
+		DeviceCode:      "device",
+		UserCode:        "WDJB-MJHT",
+		VerificationURI: "https://example.com/device",
+		ExpiresIn:       600,
+	}
+
+	tests := []struct {
+		name       string
+		modify     func(*DeviceAuthorizationResponse)
+		wantIssues int
+	}{
+		{name: "valid", modify: func(*DeviceAuthorizationResponse) {}},
+		{name: "missing codes", modify: func(r *DeviceAuthorizationResponse) {
+			r.DeviceCode, r.UserCode = "", ""
+		}, wantIssues: 2},
+		{name: "interval too short", modify: func(r *DeviceAuthorizationResponse) {
+			r.Interval = 1
+		}, wantIssues: 1},
+		{name: "complete URI without user code", modify: func(r *DeviceAuthorizationResponse) {
+			r.VerificationURIComplete = "https://example.com/device"
+		}, wantIssues: 1},
+		{name: "expired", modify: func(r *DeviceAuthorizationResponse) {
+			r.ExpiresIn = 0
+		}, wantIssues: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := valid
+			tt.modify(&resp)
+			if issues := ValidateDeviceAuthorizationResponse(&resp); len(issues) != tt.wantIssues {
+				t.Errorf("issues = %q, want %d", issues, tt.wantIssues)
+			}
+		})
+	}
+}
@@ -1,32 +1,32 @@
-// Package integration provides end-to-end testing for OAuth 2.0 Device Flow implementation
-package integration
+package conformance
 
-// Response types as defined in RFC 8628 Section 3.2
-type deviceAuthResponse struct {
+// DeviceAuthorizationResponse is the device authorization response of RFC
+// 8628 section 3.2
+type DeviceAuthorizationResponse struct {
 	DeviceCode              string `json:"device_code"`               // REQUIRED
 	UserCode                string `json:"user_code"`                 // REQUIRED
 	VerificationURI         string `json:"verification_uri"`          // REQUIRED
 	VerificationURIComplete string `json:"verification_uri_complete"` // OPTIONAL
 	ExpiresIn               int    `json:"expires_in"`                // REQUIRED
-	Interval                int    `json:"interval"`                  // REQUIRED if polling supported
+	Interval                int    `json:"interval"`                  // OPTIONAL, 5 seconds when absent
 }
 
-// Token response as defined in RFC 8628 Section 3.5
-type tokenResponse struct {
+// TokenResponse is the access token response of RFC 8628 section 3.5
+type TokenResponse struct {
 	AccessToken  string `json:"access_token"`  // REQUIRED
-	TokenType    string `json:"token_type"`    // REQUIRED - Value MUST be "Bearer"
+	TokenType    string `json:"token_type"`    // REQUIRED, Bearer per RFC 6750
 	ExpiresIn    int    `json:"expires_in"`    // RECOMMENDED
 	RefreshToken string `json:"refresh_token"` // OPTIONAL
-	Scope        string `json:"scope"`         // OPTIONAL if identical to request scope
+	Scope        string `json:"scope"`         // OPTIONAL if identical to the requested scope
 }
 
-// Error response format as defined in RFC 6749 Section 5.2
-type errorResponse struct {
+// ErrorResponse is the error response of RFC 6749 section 5.2
+type ErrorResponse struct {
 	Error            string `json:"error"`             // REQUIRED
 	ErrorDescription string `json:"error_description"` // OPTIONAL
 }
 
-// Known error codes defined in RFC 8628 Section 3.5
+// Error codes of RFC 6749 section 5.2 and RFC 8628 section 3.5
 const (
 	ErrAuthorizationPending = "authorization_pending"
 	ErrSlowDown             = "slow_down"
@@ -34,4 +34,8 @@ const (
 	ErrExpiredToken         = "expired_token"
 	ErrInvalidRequest       = "invalid_request"
 	ErrInvalidGrant         = "invalid_grant"
+	ErrUnsupportedGrantType = "unsupported_grant_type"
 )
+
+// DeviceCodeGrantType is the grant type of device access token requests
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
@@ -0,0 +1,98 @@
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MinInterval is the least polling interval, in seconds, a device
+// authorization response may ask for
+const MinInterval = 5
+
+// ValidateDeviceAuthorizationResponse checks a device authorization response
+// against RFC 8628 section 3.2, returning the requirements it violates
+func ValidateDeviceAuthorizationResponse(resp *DeviceAuthorizationResponse) []string {
+	var issues []string
+
+	// Check REQUIRED fields
+	if resp.DeviceCode == "" {
+		issues = append(issues, "device_code is REQUIRED (Section 3.2)")
+	}
+	if resp.UserCode == "" {
+		issues = append(issues, "user_code is REQUIRED (Section 3.2)")
+	}
+	if resp.VerificationURI == "" {
+		issues = append(issues, "verification_uri is REQUIRED (Section 3.2)")
+	}
+	if resp.ExpiresIn <= 0 {
+		issues = append(issues, fmt.Sprintf("expires_in MUST be positive, got %d (Section 3.2)", resp.ExpiresIn))
+	}
+
+	// The complete URI is OPTIONAL but must carry the user code when sent
+	if resp.VerificationURIComplete != "" && !strings.Contains(resp.VerificationURIComplete, resp.UserCode) {
+		issues = append(issues, "verification_uri_complete MUST include the user code (Section 3.2)")
+	}
+
+	// A missing interval means the 5 second default
+	if resp.Interval != 0 && resp.Interval < MinInterval {
+		issues = append(issues, fmt.Sprintf("interval MUST NOT be less than 5 seconds, got %d (Section 3.2)", resp.Interval))
+	}
+
+	return issues
+}
+
+// ValidateTokenResponse checks an access token response against RFC 8628
+// section 3.5 and RFC 6749 section 5.1, returning the requirements it violates
+func ValidateTokenResponse(token *TokenResponse) []string {
+	var issues []string
+
+	if token.AccessToken == "" {
+		issues = append(issues, "access_token is REQUIRED (Section 3.5)")
+	}
+	if token.TokenType == "" {
+		issues = append(issues, "token_type is REQUIRED (Section 3.5)")
+	} else if !strings.EqualFold(token.TokenType, "Bearer") {
+		issues = append(issues, "token_type MUST be 'Bearer' (case-insensitive per RFC 6750)")
+	}
+
+	return issues
+}
+
+// ValidateErrorResponse checks that a response is an RFC 6749 section 5.2
+// error response with the expected error code, returning the requirements it
+// violates
+func ValidateErrorResponse(resp *http.Response, body []byte, wantError string) []string {
+	var issues []string
+
+	if resp.StatusCode != http.StatusBadRequest {
+		issues = append(issues, fmt.Sprintf("expected status 400 for error response, got %d", resp.StatusCode))
+	}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "application/json") {
+		issues = append(issues, "error response must use application/json content type")
+	}
+
+	var errResp ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return append(issues, fmt.Sprintf("error response not valid JSON: %v", err))
+	}
+	if errResp.Error != wantError {
+		issues = append(issues, fmt.Sprintf("expected error %q, got %q", wantError, errResp.Error))
+	}
+
+	return issues
+}
+
+// ValidateResponseHeaders checks the headers RFC 8628 section 3.2 requires of
+// device authorization responses
+func ValidateResponseHeaders(resp *http.Response) []string {
+	var issues []string
+	if !strings.Contains(resp.Header.Get("Content-Type"), "application/json") {
+		issues = append(issues, "response MUST be JSON (Section 3.2)")
+	}
+	if resp.Header.Get("Cache-Control") != "no-store" {
+		issues = append(issues, "response MUST include Cache-Control: no-store (Section 3.2)")
+	}
+	return issues
+}
@@ -0,0 +1,156 @@
+// Package audit records an append-only trail of device authorization decisions
+package audit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Actions recorded in the audit trail
+const (
+	ActionApproved = "authorization.approved"
+	ActionDenied   = "authorization.denied"
+
+	// ActionLockedOut records an IP locked out of the verification pages
+	// after repeated invalid user codes
+	ActionLockedOut = "verification.locked_out"
+
+	// ActionOfflineRevoked records an operator revoking an offline grant
+	ActionOfflineRevoked = "grant.offline_revoked"
+
+	// ActionTestApproved records a device code approved through the test hook
+	ActionTestApproved = "authorization.test_approved"
+
+	// ActionPolicyDenied records an authorization refused by the
+	// authorization policy
+	ActionPolicyDenied = "authorization.policy_denied"
+
+	// ActionDeviceRevoked records a user revoking a device they authorized
+	// from the self-service devices page
+	ActionDeviceRevoked = "grant.user_revoked"
+
+	// ActionClientRevoked records an operator revoking every outstanding
+	// device code of a client
+	ActionClientRevoked = "client.revoked"
+
+	// ActionCodeRevoked records an operator ending a single pending flow
+	// through the gRPC management API
+	ActionCodeRevoked = "device_code.revoked"
+)
+
+// Default and maximum number of records returned by List
+const (
+	DefaultListLimit = 100
+	MaxListLimit     = 1000
+)
+
+// Record is a single audit trail entry. Device codes are bearer secrets until
+// redeemed, so records carry a truncated hash that can be correlated but not replayed.
+type Record struct {
+	ID             string    `json:"id"`
+	Time           time.Time `json:"time"`
+	Action         string    `json:"action"`
+	ClientID       string    `json:"client_id"`
+	UserCode       string    `json:"user_code,omitempty"`
+	DeviceCodeHash string    `json:"device_code_hash,omitempty"`
+	Subject        string    `json:"subject,omitempty"` // sub claim of the approving user when known
+	Scope          string    `json:"scope,omitempty"`
+	DeviceID       string    `json:"device_id,omitempty"` // Client-asserted device identifier
+	RemoteIP       string    `json:"remote_ip,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	Reason         string    `json:"reason,omitempty"` // Why a policy refused the authorization or an operator revoked a client or code
+	Actor          string    `json:"actor,omitempty"`  // Operator identity, such as a client certificate subject, when known
+
+	// Timeline of the device flow up to the decision, when known
+	RequestedAt  *time.Time `json:"requested_at,omitempty"`
+	VerifiedAt   *time.Time `json:"verified_at,omitempty"`
+	AuthorizedAt *time.Time `json:"authorized_at,omitempty"`
+}
+
+// OptionalTime returns t for an optional Record time, nil when it is zero
+func OptionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Filter selects records returned by List
+type Filter struct {
+	ClientID string    // Only records for this client when set
+	Subject  string    // Only records for this user when set
+	Action   string    // Only records of this action when set
+	Since    time.Time // Only records at or after this time when set
+	Limit    int       // Maximum records returned, most recent first
+}
+
+// Logger appends and queries audit records
+type Logger interface {
+	// Record appends an entry to the audit trail
+	Record(ctx context.Context, record Record) error
+
+	// List returns matching records, most recent first
+	List(ctx context.Context, filter Filter) ([]Record, error)
+}
+
+// NopLogger discards audit records
+type NopLogger struct{}
+
+// Record implements Logger
+func (NopLogger) Record(ctx context.Context, record Record) error { return nil }
+
+// List implements Logger
+func (NopLogger) List(ctx context.Context, filter Filter) ([]Record, error) { return nil, nil }
+
+// HashDeviceCode returns a correlation hash for a device code
+func HashDeviceCode(deviceCode string) string {
+	if deviceCode == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(deviceCode))
+	return hex.EncodeToString(sum[:8])
+}
+
+// prepare fills in the record ID and timestamp when not set
+func prepare(record *Record) {
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	if record.ID == "" {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err == nil {
+			record.ID = hex.EncodeToString(b)
+		}
+	}
+}
+
+// normalizeLimit applies default and maximum list limits
+func normalizeLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		return MaxListLimit
+	}
+	return limit
+}
+
+// matches reports whether a record satisfies the filter
+func (f Filter) matches(record Record) bool {
+	if f.ClientID != "" && record.ClientID != f.ClientID {
+		return false
+	}
+	if f.Subject != "" && record.Subject != f.Subject {
+		return false
+	}
+	if f.Action != "" && record.Action != f.Action {
+		return false
+	}
+	if !f.Since.IsZero() && record.Time.Before(f.Since) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/wrale/oauth2-device-proxy/internal/canonical"
+)
+
+// FileLogger appends audit records as JSON lines to a local file
+type FileLogger struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileLogger opens path for appending, creating it if needed
+func NewFileLogger(path string) (*FileLogger, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit file path is required")
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit file: %w", err)
+	}
+
+	return &FileLogger{path: path, file: file}, nil
+}
+
+// Record appends a record and syncs it to disk
+func (l *FileLogger) Record(ctx context.Context, record Record) error {
+	prepare(&record)
+
+	data, err := canonical.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshaling audit record: %w", err)
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.file.Write(data); err != nil {
+		return fmt.Errorf("writing audit record: %w", err)
+	}
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("syncing audit file: %w", err)
+	}
+
+	return nil
+}
+
+// List scans the file and returns matching records, most recent first
+func (l *FileLogger) List(ctx context.Context, filter Filter) ([]Record, error) {
+	limit := normalizeLimit(filter.Limit)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit file: %w", err)
+	}
+	defer file.Close()
+
+	// Keep a ring of the newest matches since the file is ordered oldest first
+	var matched []Record
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("parsing audit record: %w", err)
+		}
+		if !filter.matches(record) {
+			continue
+		}
+		matched = append(matched, record)
+		if len(matched) > limit {
+			matched = matched[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading audit file: %w", err)
+	}
+
+	// Reverse to most recent first
+	for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+		matched[i], matched[j] = matched[j], matched[i]
+	}
+
+	return matched, nil
+}
+
+// Close closes the underlying file
+func (l *FileLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileLogger(t *testing.T) {
+	ctx := context.Background()
+	logger, err := NewFileLogger(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatalf("NewFileLogger failed: %v", err)
+	}
+	defer logger.Close()
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Time: base, Action: ActionApproved, ClientID: "kiosk", UserCode: "AAAA-AAAA", Subject: "alice"},
+		{Time: base.Add(time.Minute), Action: ActionDenied, ClientID: "tv", UserCode: "BBBB-BBBB", Subject: "alice"},
+		{Time: base.Add(2 * time.Minute), Action: ActionApproved, ClientID: "kiosk", UserCode: "CCCC-CCCC", Subject: "bob"},
+	}
+	for _, record := range records {
+		if err := logger.Record(ctx, record); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "all newest first", filter: Filter{}, want: []string{"CCCC-CCCC", "BBBB-BBBB", "AAAA-AAAA"}},
+		{name: "by client", filter: Filter{ClientID: "kiosk"}, want: []string{"CCCC-CCCC", "AAAA-AAAA"}},
+		{name: "by subject", filter: Filter{Subject: "alice"}, want: []string{"BBBB-BBBB", "AAAA-AAAA"}},
+		{name: "by action", filter: Filter{Action: ActionApproved}, want: []string{"CCCC-CCCC", "AAAA-AAAA"}},
+		{name: "since", filter: Filter{Since: base.Add(time.Minute)}, want: []string{"CCCC-CCCC", "BBBB-BBBB"}},
+		{name: "limit", filter: Filter{Limit: 1}, want: []string{"CCCC-CCCC"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := logger.List(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("List returned %d records, want %d", len(got), len(tt.want))
+			}
+			for i, record := range got {
+				if record.UserCode != tt.want[i] {
+					t.Errorf("record %d user code = %q, want %q", i, record.UserCode, tt.want[i])
+				}
+				if record.ID == "" {
+					t.Errorf("record %d has no ID", i)
+				}
+			}
+		})
+	}
+}
+
+func TestHashDeviceCode(t *testing.T) {
+	hash := HashDeviceCode("device-code")
+	if hash == "" || hash == "device-code" {
+		t.Fatalf("HashDeviceCode returned %q", hash)
+	}
+	if hash != HashDeviceCode("device-code") {
+		t.Error("HashDeviceCode is not deterministic")
+	}
+	if HashDeviceCode("") != "" {
+		t.Error("HashDeviceCode of empty code should be empty")
+	}
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wrale/oauth2-device-proxy/internal/canonical"
+)
+
+// streamKey is the Redis stream holding audit records
+const streamKey = "audit:log"
+
+// scanBatch is the number of stream entries read per round trip when filtering
+const scanBatch = 500
+
+// RedisLogger appends audit records to a Redis stream
+type RedisLogger struct {
+	client *redis.Client
+	stream string
+}
+
+// RedisOption configures the Redis audit logger
+type RedisOption func(*RedisLogger)
+
+// WithKeyPrefix namespaces the audit stream so that several proxy environments
+// can share one Redis instance
+func WithKeyPrefix(prefix string) RedisOption {
+	return func(l *RedisLogger) {
+		l.stream = prefix + streamKey
+	}
+}
+
+// NewRedisLogger creates a Redis stream-backed audit logger
+func NewRedisLogger(client *redis.Client, opts ...RedisOption) *RedisLogger {
+	l := &RedisLogger{client: client, stream: streamKey}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Record appends a record to the audit stream
+func (l *RedisLogger) Record(ctx context.Context, record Record) error {
+	prepare(&record)
+
+	data, err := canonical.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshaling audit record: %w", err)
+	}
+
+	if err := l.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: l.stream,
+		Values: map[string]any{"record": data},
+	}).Err(); err != nil {
+		return fmt.Errorf("appending audit record: %w", err)
+	}
+
+	return nil
+}
+
+// List walks the stream newest first and returns matching records
+func (l *RedisLogger) List(ctx context.Context, filter Filter) ([]Record, error) {
+	limit := normalizeLimit(filter.Limit)
+	records := make([]Record, 0, limit)
+
+	end := "+"
+	for len(records) < limit {
+		entries, err := l.client.XRevRangeN(ctx, l.stream, end, "-", scanBatch).Result()
+		if err != nil {
+			return nil, fmt.Errorf("reading audit stream: %w", err)
+		}
+
+		for _, entry := range entries {
+			raw, ok := entry.Values["record"].(string)
+			if !ok {
+				continue
+			}
+			var record Record
+			if err := json.Unmarshal([]byte(raw), &record); err != nil {
+				return nil, fmt.Errorf("parsing audit record: %w", err)
+			}
+
+			// Entries are newest first, so nothing older can match
+			if !filter.Since.IsZero() && record.Time.Before(filter.Since) {
+				return records, nil
+			}
+			if !filter.matches(record) {
+				continue
+			}
+			records = append(records, record)
+			if len(records) == limit {
+				return records, nil
+			}
+		}
+
+		if len(entries) < scanBatch {
+			break
+		}
+		end = "(" + entries[len(entries)-1].ID // Exclusive range continues past last entry
+	}
+
+	return records, nil
+}
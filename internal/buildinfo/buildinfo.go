@@ -0,0 +1,87 @@
+// Package buildinfo describes the running binary. The version, commit and
+// build date are set at build time with ldflags, e.g.
+//
+//	-X github.com/wrale/oauth2-device-proxy/internal/buildinfo.Version=1.2.0
+//	-X github.com/wrale/oauth2-device-proxy/internal/buildinfo.Commit=$(git rev-parse HEAD)
+//	-X github.com/wrale/oauth2-device-proxy/internal/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+//
+// Binaries built without them fall back to the VCS details Go embeds.
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sort"
+)
+
+// Set by the build process
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// Info describes a build of the proxy
+type Info struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit,omitempty"`
+	BuildDate string   `json:"build_date,omitempty"`
+	Modified  bool     `json:"modified,omitempty"` // Built from a tree with uncommitted changes
+	GoVersion string   `json:"go_version"`
+	Features  []string `json:"features,omitempty"` // Optional features enabled by configuration
+}
+
+// Get returns the build details of the running binary
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	if info.Commit != "" && info.BuildDate != "" {
+		return info
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
+
+// WithFeatures returns a copy of the info listing the enabled features in
+// sorted order
+func (i Info) WithFeatures(features ...string) Info {
+	i.Features = append([]string(nil), features...)
+	sort.Strings(i.Features)
+	return i
+}
+
+// Handler serves the build details as JSON
+func Handler(info Info) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			http.Error(w, `{"error":"server_error","error_description":"Error encoding response"}`,
+				http.StatusInternalServerError)
+		}
+	})
+}
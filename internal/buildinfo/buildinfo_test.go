@@ -0,0 +1,41 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	defer func(version, commit, date string) {
+		Version, Commit, BuildDate = version, commit, date
+	}(Version, Commit, BuildDate)
+	Version, Commit, BuildDate = "1.2.0", "abc123", "2024-05-01T00:00:00Z"
+
+	info := Get()
+	if info.Version != "1.2.0" || info.Commit != "abc123" || info.BuildDate != "2024-05-01T00:00:00Z" {
+		t.Errorf("Get() = %+v, want the ldflags values", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("GoVersion = %q, want %q", info.GoVersion, runtime.Version())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	info := Info{Version: "1.2.0", Commit: "abc123", GoVersion: "go1.21.0"}.WithFeatures("token_renewal", "degraded_mode")
+
+	w := httptest.NewRecorder()
+	Handler(info).ServeHTTP(w, httptest.NewRequest("GET", "/version", nil))
+
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var got Info
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if got.Commit != "abc123" || len(got.Features) != 2 || got.Features[0] != "degraded_mode" {
+		t.Errorf("response = %+v, want the build info with sorted features", got)
+	}
+}
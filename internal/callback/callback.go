@@ -0,0 +1,121 @@
+// Package callback delivers authorization outcomes to devices that registered
+// a callback URI with their device authorization request
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/delivery"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+)
+
+// Callback request headers
+const (
+	HeaderTimestamp = "X-Callback-Timestamp"
+
+	// HeaderSignature carries an HMAC-SHA256 over the timestamp and body keyed
+	// with the device code, which only the device and the proxy know, in the
+	// format of events.Sign
+	HeaderSignature = "X-Callback-Signature"
+)
+
+// Callback delivery defaults
+const (
+	DefaultMaxRetries     = 3
+	DefaultInitialBackoff = delivery.DefaultInitialBackoff
+	DefaultMaxBackoff     = 30 * time.Second
+	DefaultTimeout        = delivery.DefaultTimeout
+	DefaultQueueSize      = delivery.DefaultQueueSize
+)
+
+// Config configures callback delivery
+type Config struct {
+	MaxRetries     int           // Retries after the first attempt
+	InitialBackoff time.Duration // Delay before the first retry, doubled per attempt
+	MaxBackoff     time.Duration // Upper bound on retry delay
+	Timeout        time.Duration // Per-attempt request timeout
+	QueueSize      int           // Callbacks buffered before new ones are dropped
+
+	// Client sends callbacks, delivery.PublicClient if nil. Callback URIs are
+	// device-supplied, so only tests should reach other hosts.
+	Client *http.Client
+}
+
+// Dispatcher implements deviceflow.CallbackSender, POSTing signed outcomes
+// from a background worker with retry and backoff
+type Dispatcher struct {
+	queue *delivery.Queue
+}
+
+// New creates a dispatcher and starts its delivery worker
+func New(cfg Config) *Dispatcher {
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.Client == nil {
+		cfg.Client = delivery.PublicClient(cfg.Timeout)
+	}
+
+	return &Dispatcher{queue: delivery.New(delivery.Config{
+		MaxRetries:     cfg.MaxRetries,
+		InitialBackoff: cfg.InitialBackoff,
+		MaxBackoff:     cfg.MaxBackoff,
+		Timeout:        cfg.Timeout,
+		QueueSize:      cfg.QueueSize,
+		Client:         cfg.Client,
+	})}
+}
+
+// SendCallback implements deviceflow.CallbackSender. The body is the token
+// response of RFC 8628 section 3.5, or its error response when failure is set.
+func (d *Dispatcher) SendCallback(ctx context.Context, code *deviceflow.DeviceCode, token *deviceflow.TokenResponse, failure *deviceflow.DeviceFlowError) {
+	var payload any = token
+	if failure != nil {
+		payload = failure
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error: encoding device callback: %v", err)
+		return
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Cache-Control", "no-store")
+	header.Set(HeaderTimestamp, timestamp)
+	header.Set(HeaderSignature, events.Sign([]byte(code.DeviceCode), timestamp, body))
+
+	// The URI is device-supplied, so it is not logged
+	err = d.queue.Enqueue(delivery.Request{
+		Name:   "device callback",
+		URL:    code.CallbackURI,
+		Header: header,
+		Body:   body,
+	})
+	switch {
+	case errors.Is(err, delivery.ErrClosed):
+		log.Printf("Warning: callback dispatcher closed, dropping device callback")
+	case errors.Is(err, delivery.ErrQueueFull):
+		log.Printf("Warning: callback queue full, dropping device callback")
+	}
+}
+
+// Close stops accepting callbacks and waits for queued deliveries to finish or ctx to expire
+func (d *Dispatcher) Close(ctx context.Context) error {
+	if err := d.queue.Close(ctx); err != nil {
+		return fmt.Errorf("waiting for device callbacks: %w", err)
+	}
+	return nil
+}
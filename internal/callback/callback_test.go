@@ -0,0 +1,104 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+)
+
+func TestDispatcherDelivery(t *testing.T) {
+	code := &deviceflow.DeviceCode{DeviceCode: "device-123"}
+
+	tests := []struct {
+		name    string
+		token   *deviceflow.TokenResponse
+		failure *deviceflow.DeviceFlowError
+		want    string
+	}{
+		{
+			name:  "token",
+			token: &deviceflow.TokenResponse{AccessToken: "access", TokenType: "Bearer", ExpiresIn: 300},
+			want:  `{"access_token":"access","token_type":"Bearer","expires_in":300}`,
+		},
+		{
+			name:    "denied",
+			failure: deviceflow.ErrAccessDenied,
+			want:    `{"error":"access_denied","error_description":"` + deviceflow.ErrorDescAccessDenied + `"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan string, 1)
+			srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if !events.VerifySignature([]byte("device-123"), r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)) {
+					t.Error("callback signature did not verify with the device code")
+				}
+				received <- string(body)
+			}))
+			defer srv.Close()
+
+			d := New(Config{Client: srv.Client()})
+			code.CallbackURI = srv.URL
+			d.SendCallback(context.Background(), code, tt.token, tt.failure)
+
+			select {
+			case body := <-received:
+				var got, want any
+				_ = json.Unmarshal([]byte(body), &got)
+				_ = json.Unmarshal([]byte(tt.want), &want)
+				gotJSON, _ := json.Marshal(got)
+				wantJSON, _ := json.Marshal(want)
+				if string(gotJSON) != string(wantJSON) {
+					t.Errorf("body = %s, want %s", body, tt.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("callback not delivered")
+			}
+			if err := d.Close(context.Background()); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+		})
+	}
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantAttempts int32
+	}{
+		{name: "transient failure is retried", status: http.StatusServiceUnavailable, wantAttempts: 3},
+		{name: "rejection is not retried", status: http.StatusNotFound, wantAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			d := New(Config{Client: srv.Client(), MaxRetries: 2, InitialBackoff: time.Millisecond})
+			d.SendCallback(context.Background(), &deviceflow.DeviceCode{DeviceCode: "device-123", CallbackURI: srv.URL},
+				&deviceflow.TokenResponse{AccessToken: "access"}, nil)
+			if err := d.Close(context.Background()); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Package canonical provides a deterministic JSON encoding for signed payloads.
+//
+// Values are first encoded with encoding/json, so struct tags and Marshaler
+// implementations are honored, then re-encoded with object keys sorted by their
+// UTF-8 bytes, no insignificant whitespace, no HTML escaping, and numbers in the
+// shortest ECMAScript-compatible form. Two semantically equal values therefore
+// always produce identical bytes, which keeps HMAC signatures and content hashes
+// stable across producers, consumers and replays.
+package canonical
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Marshal returns the canonical JSON encoding of v
+func Marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Canonicalize(data)
+}
+
+// Canonicalize rewrites a JSON document in canonical form
+func Canonicalize(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("decoding JSON: %w", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("decoding JSON: unexpected data after top-level value")
+	}
+
+	var buf bytes.Buffer
+	if err := encode(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Hash returns the hex SHA-256 digest of the canonical encoding of v, suitable
+// for deduplicating replayed payloads by content
+func Hash(v any) (string, error) {
+	data, err := Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// encode writes a decoded JSON value in canonical form
+func encode(buf *bytes.Buffer, value any) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		n, err := formatNumber(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(n)
+	case string:
+		encodeString(buf, v)
+	case []any:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := encode(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			encodeString(buf, k)
+			buf.WriteByte(':')
+			if err := encode(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported JSON value of type %T", value)
+	}
+	return nil
+}
+
+// encodeString writes a JSON string without HTML escaping
+func encodeString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)           // Strings always encode
+	buf.Truncate(buf.Len() - 1) // Drop the encoder's trailing newline
+}
+
+// formatNumber normalizes a JSON number so that equal values share one spelling,
+// e.g. 1, 1.0 and 1e0 all become 1
+func formatNumber(n json.Number) (string, error) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return strconv.FormatInt(i, 10), nil
+	}
+
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("invalid JSON number %q", n)
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return strconv.FormatInt(int64(f), 10), nil
+	}
+
+	// encoding/json formats floats like ECMAScript's Number.prototype.toString
+	data, err := json.Marshal(f)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
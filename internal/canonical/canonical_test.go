@@ -0,0 +1,71 @@
+package canonical
+
+import (
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "sorted keys", input: `{"b":1,"a":2,"c":{"z":true,"y":null}}`, want: `{"a":2,"b":1,"c":{"y":null,"z":true}}`},
+		{name: "whitespace removed", input: "{ \"a\" : [ 1 , 2 ] }", want: `{"a":[1,2]}`},
+		{name: "no HTML escaping", input: `{"url":"https://example.com/?a=1&b=<2>"}`, want: `{"url":"https://example.com/?a=1&b=<2>"}`},
+		{name: "integral numbers", input: `[1.0,1e0,-0,100E-2]`, want: `[1,1,0,1]`},
+		{name: "fractional numbers", input: `[0.50,1.5e-7,2.5E+2]`, want: `[0.5,1.5e-7,250]`},
+		{name: "large integers preserved", input: `[9007199254740993]`, want: `[9007199254740993]`},
+		{name: "unicode kept literal", input: `{"name":"café"}`, want: `{"name":"café"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Canonicalize([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("Canonicalize failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Canonicalize(%s) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeInvalid(t *testing.T) {
+	for _, input := range []string{`{`, `{"a":1} {"b":2}`, ``} {
+		if _, err := Canonicalize([]byte(input)); err == nil {
+			t.Errorf("Canonicalize(%q) succeeded, want error", input)
+		}
+	}
+}
+
+func TestMarshalStable(t *testing.T) {
+	type payload struct {
+		Zeta  string         `json:"zeta"`
+		Alpha int            `json:"alpha"`
+		Data  map[string]any `json:"data"`
+	}
+
+	a, err := Marshal(payload{Zeta: "<z>", Alpha: 1, Data: map[string]any{"n": 2.0, "m": "x"}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `{"alpha":1,"data":{"m":"x","n":2},"zeta":"<z>"}`
+	if string(a) != want {
+		t.Errorf("Marshal = %s, want %s", a, want)
+	}
+
+	// Semantically equal input in a different shape hashes identically
+	h1, err := Hash(payload{Zeta: "<z>", Alpha: 1, Data: map[string]any{"m": "x", "n": 2}})
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	h2, err := Hash(map[string]any{"zeta": "<z>", "data": map[string]any{"n": 2.0, "m": "x"}, "alpha": 1.0})
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if h1 != h2 {
+		t.Errorf("hashes differ for equal payloads: %s != %s", h1, h2)
+	}
+}
@@ -0,0 +1,348 @@
+// Package clients provides per-client settings for device authorization clients
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// defaultScopeDescriptions describes standard OpenID Connect scopes on the consent page
+var defaultScopeDescriptions = map[string]string{
+	"openid":         "Sign you in with your account",
+	"profile":        "View your basic profile information",
+	"email":          "View your email address",
+	"offline_access": "Stay signed in when you are not using the device",
+}
+
+// Client holds settings that override global behavior for one OAuth2 client
+type Client struct {
+	ID   string `json:"client_id"`
+	Name string `json:"name,omitempty"` // Display name shown on the consent page
+
+	// LogoURI and ConsentText are shown on the consent page when no metadata
+	// source provides them
+	LogoURI     string `json:"logo_uri,omitempty"`
+	ConsentText string `json:"consent_text,omitempty"`
+
+	// VerificationURIComplete controls whether verification_uri_complete and its QR
+	// code are offered per RFC 8628 section 3.3.1. Nil uses the global setting.
+	VerificationURIComplete *bool `json:"verification_uri_complete,omitempty"`
+
+	// ProofOfWork controls whether device code requests from the client must
+	// carry a solved proof of work challenge unless the client authenticates.
+	// Nil uses the global setting.
+	ProofOfWork *bool `json:"proof_of_work,omitempty"`
+
+	// WithheldTokens lists token response members, refresh_token or id_token,
+	// kept from the client's devices and held by the proxy only. Nil uses the
+	// global setting, an empty list withholds nothing.
+	WithheldTokens []string `json:"withheld_tokens,omitempty"`
+
+	// OfflineAccess controls whether offline_access is requested for the
+	// client, so that its refresh tokens outlive the user's session. Nil uses
+	// the global setting.
+	OfflineAccess *bool `json:"offline_access,omitempty"`
+
+	// ExpiresIn and Interval set the lifetime and polling interval, in
+	// seconds, of the client's device codes, for devices that need longer
+	// than the global settings allow. Zero uses the global settings. Both are
+	// bounded by the flow's limits.
+	ExpiresIn int `json:"expires_in,omitempty"`
+	Interval  int `json:"interval,omitempty"`
+
+	// AllowedScopes lists the scopes the client may request; requests for
+	// others are rejected with invalid_scope. Nil uses the global setting, an
+	// empty list allows no scopes.
+	AllowedScopes []string `json:"allowed_scopes,omitempty"`
+
+	// CallbackOrigins lists the https origins, such as
+	// https://device.example:8443, at which the client's devices may register
+	// callback URIs. Callback URIs are refused for clients without any.
+	CallbackOrigins []string `json:"callback_origins,omitempty"`
+
+	// IDPHint names the Keycloak identity provider users are sent to, such as
+	// google, skipping the realm's login page for brokered logins
+	IDPHint string `json:"idp_hint,omitempty"`
+
+	// ClientCredentials lets the client, a back-end provisioning tool rather
+	// than a device, obtain tokens with the client_credentials grant through
+	// the proxy's /token passthrough. It authenticates to the identity
+	// provider with its own credentials.
+	ClientCredentials bool `json:"client_credentials,omitempty"`
+
+	// AuthMethod is the client's token_endpoint_auth_method per RFC 7591
+	// section 2. Clients using AuthMethodPrivateKeyJWT must authenticate their
+	// device code and token requests with assertions signed by a key in JWKS.
+	AuthMethod string          `json:"token_endpoint_auth_method,omitempty"`
+	JWKS       json.RawMessage `json:"jwks,omitempty"`
+}
+
+// Client authentication methods
+const (
+	AuthMethodNone          = "none" // Public clients, the default
+	AuthMethodPrivateKeyJWT = "private_key_jwt"
+)
+
+// withholdableMembers are the token response members WithheldTokens may list
+var withholdableMembers = map[string]bool{
+	"refresh_token": true,
+	"id_token":      true,
+}
+
+// Metadata describes a client to users on the verification pages
+type Metadata struct {
+	Name        string
+	LogoURI     string // Absolute https URL
+	ConsentText string // Shown on the consent page in addition to the scopes
+}
+
+// MetadataSource looks up client metadata managed outside the registry, such
+// as in the identity provider. It reports whether the client is known.
+type MetadataSource interface {
+	ClientMetadata(ctx context.Context, clientID string) (Metadata, bool, error)
+}
+
+// Registry looks up per-client settings. A nil Registry has no clients.
+type Registry struct {
+	clients map[string]Client
+	scopes  map[string]string
+	source  MetadataSource // Optional, consulted before the local settings
+}
+
+// fileFormat is the JSON layout of a clients file
+type fileFormat struct {
+	Clients []Client          `json:"clients"`
+	Scopes  map[string]string `json:"scopes"` // Scope descriptions for the consent page
+}
+
+// NewRegistry creates a registry from the given clients
+func NewRegistry(clients []Client) (*Registry, error) {
+	r := &Registry{
+		clients: make(map[string]Client, len(clients)),
+		scopes:  make(map[string]string, len(defaultScopeDescriptions)),
+	}
+	for scope, description := range defaultScopeDescriptions {
+		r.scopes[scope] = description
+	}
+
+	for _, c := range clients {
+		if c.ID == "" {
+			return nil, fmt.Errorf("client entry is missing client_id")
+		}
+		if _, exists := r.clients[c.ID]; exists {
+			return nil, fmt.Errorf("duplicate client %q", c.ID)
+		}
+		switch c.AuthMethod {
+		case "", AuthMethodNone:
+		case AuthMethodPrivateKeyJWT:
+			if len(c.JWKS) == 0 {
+				return nil, fmt.Errorf("client %q uses private_key_jwt without jwks", c.ID)
+			}
+		default:
+			return nil, fmt.Errorf("client %q has unsupported token_endpoint_auth_method %q", c.ID, c.AuthMethod)
+		}
+		for _, member := range c.WithheldTokens {
+			if !withholdableMembers[member] {
+				return nil, fmt.Errorf("client %q cannot withhold token member %q", c.ID, member)
+			}
+		}
+		if err := deviceflow.ValidateAllowedScopes(c.AllowedScopes); err != nil {
+			return nil, fmt.Errorf("client %q allowed_scopes: %w", c.ID, err)
+		}
+		if err := deviceflow.ValidateCallbackOrigins(c.CallbackOrigins); err != nil {
+			return nil, fmt.Errorf("client %q callback_origins: %w", c.ID, err)
+		}
+		if c.ExpiresIn < 0 || c.Interval < 0 {
+			return nil, fmt.Errorf("client %q expires_in and interval must not be negative", c.ID)
+		}
+		if c.LogoURI != "" && !validLogoURI(c.LogoURI) {
+			return nil, fmt.Errorf("client %q logo_uri must be an absolute https URL", c.ID)
+		}
+		r.clients[c.ID] = c
+	}
+	return r, nil
+}
+
+// UseMetadataSource makes the registry look up client metadata in source
+// first, falling back to the local settings for anything it does not provide
+func (r *Registry) UseMetadataSource(source MetadataSource) {
+	r.source = source
+}
+
+// LoadFile reads a JSON clients file of the form {"clients": [...]}
+func LoadFile(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading clients file: %w", err)
+	}
+
+	var file fileFormat
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing clients file: %w", err)
+	}
+
+	r, err := NewRegistry(file.Clients)
+	if err != nil {
+		return nil, err
+	}
+	for scope, description := range file.Scopes {
+		r.scopes[scope] = description
+	}
+	return r, nil
+}
+
+// Lookup returns the settings for a client
+func (r *Registry) Lookup(clientID string) (Client, bool) {
+	if r == nil {
+		return Client{}, false
+	}
+	c, ok := r.clients[clientID]
+	return c, ok
+}
+
+// Registered reports whether the client is listed in the registry
+func (r *Registry) Registered(clientID string) bool {
+	_, ok := r.Lookup(clientID)
+	return ok
+}
+
+// DisplayName returns the client's display name, or its ID when none is configured
+func (r *Registry) DisplayName(clientID string) string {
+	if c, ok := r.Lookup(clientID); ok && c.Name != "" {
+		return c.Name
+	}
+	return clientID
+}
+
+// Metadata returns how a client is presented to users. Metadata from the
+// source takes precedence; missing fields, unknown clients and lookup failures
+// fall back to the local settings.
+func (r *Registry) Metadata(ctx context.Context, clientID string) Metadata {
+	c, _ := r.Lookup(clientID)
+	local := Metadata{Name: r.DisplayName(clientID), LogoURI: c.LogoURI, ConsentText: c.ConsentText}
+	if r == nil || r.source == nil {
+		return local
+	}
+
+	remote, found, err := r.source.ClientMetadata(ctx, clientID)
+	if err != nil {
+		log.Printf("Warning: client metadata lookup for %q failed, using local settings: %v", clientID, err)
+		return local
+	}
+	if !found {
+		return local
+	}
+	if remote.Name == "" {
+		remote.Name = local.Name
+	}
+	if remote.LogoURI == "" {
+		remote.LogoURI = local.LogoURI
+	}
+	if remote.ConsentText == "" {
+		remote.ConsentText = local.ConsentText
+	}
+	return remote
+}
+
+// ScopeDescription returns a user-facing description of a scope, or "" if unknown
+func (r *Registry) ScopeDescription(scope string) string {
+	if r == nil {
+		return defaultScopeDescriptions[scope]
+	}
+	return r.scopes[scope]
+}
+
+// VerificationURIComplete reports whether the complete verification URI is offered
+// for the client, falling back to the global setting when not overridden
+func (r *Registry) VerificationURIComplete(clientID string, fallback bool) bool {
+	if c, ok := r.Lookup(clientID); ok && c.VerificationURIComplete != nil {
+		return *c.VerificationURIComplete
+	}
+	return fallback
+}
+
+// RequiresProofOfWork reports whether unauthenticated device code requests
+// from the client must solve a proof of work challenge, falling back to the
+// global setting when not overridden
+func (r *Registry) RequiresProofOfWork(clientID string, fallback bool) bool {
+	if c, ok := r.Lookup(clientID); ok && c.ProofOfWork != nil {
+		return *c.ProofOfWork
+	}
+	return fallback
+}
+
+// WithheldTokens returns the token response members withheld from the
+// client's devices, falling back to the global setting when not overridden
+func (r *Registry) WithheldTokens(clientID string, fallback []string) []string {
+	if c, ok := r.Lookup(clientID); ok && c.WithheldTokens != nil {
+		return c.WithheldTokens
+	}
+	return fallback
+}
+
+// AllowedScopes returns the scopes the client may request, falling back to
+// the global setting when not overridden. Nil allows any scope.
+func (r *Registry) AllowedScopes(clientID string, fallback []string) []string {
+	if c, ok := r.Lookup(clientID); ok && c.AllowedScopes != nil {
+		return c.AllowedScopes
+	}
+	return fallback
+}
+
+// CallbackOrigins returns the origins at which the client's devices may
+// register callback URIs, none for unlisted clients
+func (r *Registry) CallbackOrigins(clientID string) []string {
+	c, _ := r.Lookup(clientID)
+	return c.CallbackOrigins
+}
+
+// OfflineAccess reports whether offline_access is requested for the client,
+// falling back to the global setting when not overridden
+func (r *Registry) OfflineAccess(clientID string, fallback bool) bool {
+	if c, ok := r.Lookup(clientID); ok && c.OfflineAccess != nil {
+		return *c.OfflineAccess
+	}
+	return fallback
+}
+
+// Timing returns the lifetime and polling interval of the client's device
+// codes, zero where the global settings apply
+func (r *Registry) Timing(clientID string) (expiry, interval time.Duration) {
+	c, _ := r.Lookup(clientID)
+	return time.Duration(c.ExpiresIn) * time.Second, time.Duration(c.Interval) * time.Second
+}
+
+// IDPHint returns the identity provider hint for a client, or "" if none is configured
+func (r *Registry) IDPHint(clientID string) string {
+	c, _ := r.Lookup(clientID)
+	return c.IDPHint
+}
+
+// RequiresAssertion reports whether the client must authenticate with a JWT
+// client assertion
+func (r *Registry) RequiresAssertion(clientID string) bool {
+	c, _ := r.Lookup(clientID)
+	return c.AuthMethod == AuthMethodPrivateKeyJWT
+}
+
+// AllowsClientCredentials reports whether the client may use the
+// client_credentials grant through the proxy. Unlisted clients may not.
+func (r *Registry) AllowsClientCredentials(clientID string) bool {
+	c, _ := r.Lookup(clientID)
+	return c.ClientCredentials
+}
+
+// ClientJWKS returns the JWK Set registered for the client's assertions
+func (r *Registry) ClientJWKS(clientID string) (json.RawMessage, bool) {
+	c, ok := r.Lookup(clientID)
+	if !ok || len(c.JWKS) == 0 {
+		return nil, false
+	}
+	return c.JWKS, true
+}
@@ -0,0 +1,192 @@
+package clients
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clients.json")
+	data := `{"clients": [
+		{"client_id": "kiosk", "name": "Lobby Kiosk", "verification_uri_complete": false},
+		{"client_id": "tv", "verification_uri_complete": true, "idp_hint": "google", "proof_of_work": true},
+		{"client_id": "cli"},
+		{"client_id": "provisioner", "client_credentials": true}
+	], "scopes": {"orders:read": "View your orders"}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("writing clients file: %v", err)
+	}
+
+	registry, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+
+	tests := []struct {
+		clientID string
+		fallback bool
+		want     bool
+	}{
+		{clientID: "kiosk", fallback: true, want: false},
+		{clientID: "tv", fallback: false, want: true},
+		{clientID: "cli", fallback: false, want: false},
+		{clientID: "unknown", fallback: true, want: true},
+	}
+	for _, tt := range tests {
+		if got := registry.VerificationURIComplete(tt.clientID, tt.fallback); got != tt.want {
+			t.Errorf("VerificationURIComplete(%q, %v) = %v, want %v", tt.clientID, tt.fallback, got, tt.want)
+		}
+	}
+
+	if !registry.RequiresProofOfWork("tv", false) || registry.RequiresProofOfWork("cli", false) || !registry.RequiresProofOfWork("cli", true) {
+		t.Error("RequiresProofOfWork does not apply the per-client override over the global setting")
+	}
+
+	if !registry.AllowsClientCredentials("provisioner") || registry.AllowsClientCredentials("cli") || registry.AllowsClientCredentials("unknown") {
+		t.Error("AllowsClientCredentials does not limit the grant to enabled clients")
+	}
+
+	if got := registry.DisplayName("kiosk"); got != "Lobby Kiosk" {
+		t.Errorf("DisplayName(kiosk) = %q, want Lobby Kiosk", got)
+	}
+	if got := registry.DisplayName("cli"); got != "cli" {
+		t.Errorf("DisplayName(cli) = %q, want client ID fallback", got)
+	}
+	if got := registry.IDPHint("tv"); got != "google" {
+		t.Errorf("IDPHint(tv) = %q, want google", got)
+	}
+	if got := registry.IDPHint("cli"); got != "" {
+		t.Errorf("IDPHint(cli) = %q, want none", got)
+	}
+	if got := registry.ScopeDescription("orders:read"); got != "View your orders" {
+		t.Errorf("ScopeDescription(orders:read) = %q", got)
+	}
+	if got := registry.ScopeDescription("openid"); got == "" {
+		t.Error("expected default description for openid")
+	}
+}
+
+func TestNewRegistryValidation(t *testing.T) {
+	if _, err := NewRegistry([]Client{{ID: ""}}); err == nil {
+		t.Error("expected error for missing client_id")
+	}
+	if _, err := NewRegistry([]Client{{ID: "a"}, {ID: "a"}}); err == nil {
+		t.Error("expected error for duplicate client")
+	}
+	if _, err := NewRegistry([]Client{{ID: "a", AuthMethod: AuthMethodPrivateKeyJWT}}); err == nil {
+		t.Error("expected error for private_key_jwt without jwks")
+	}
+	if _, err := NewRegistry([]Client{{ID: "a", AuthMethod: "client_secret_basic"}}); err == nil {
+		t.Error("expected error for unsupported auth method")
+	}
+	if _, err := NewRegistry([]Client{{ID: "a", WithheldTokens: []string{"access_token"}}}); err == nil {
+		t.Error("expected error for withholding the access token")
+	}
+	if _, err := NewRegistry([]Client{{ID: "a", ExpiresIn: -1}}); err == nil {
+		t.Error("expected error for a negative expires_in")
+	}
+	if _, err := NewRegistry([]Client{{ID: "a", AllowedScopes: []string{"openid profile"}}}); err == nil {
+		t.Error("expected error for an allowed scope holding two scope tokens")
+	}
+	if _, err := NewRegistry([]Client{{ID: "a", CallbackOrigins: []string{"https://device.example/cb"}}}); err == nil {
+		t.Error("expected error for a callback origin with a path")
+	}
+	if _, err := NewRegistry([]Client{{ID: "a", CallbackOrigins: []string{"http://device.example"}}}); err == nil {
+		t.Error("expected error for a plain http callback origin")
+	}
+}
+
+func TestAllowedScopes(t *testing.T) {
+	registry, err := NewRegistry([]Client{
+		{ID: "tv", AllowedScopes: []string{"openid", "profile"}},
+		{ID: "sensor", AllowedScopes: []string{}},
+		{ID: "cli"},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	fallback := []string{"openid"}
+	if got := registry.AllowedScopes("tv", fallback); len(got) != 2 {
+		t.Errorf("AllowedScopes(tv) = %v, want the client's scopes", got)
+	}
+	if got := registry.AllowedScopes("sensor", fallback); got == nil || len(got) != 0 {
+		t.Errorf("AllowedScopes(sensor) = %v, want no scopes", got)
+	}
+	if got := registry.AllowedScopes("cli", fallback); len(got) != 1 {
+		t.Errorf("AllowedScopes(cli) = %v, want the global setting", got)
+	}
+	if got := registry.AllowedScopes("unknown", nil); got != nil {
+		t.Errorf("AllowedScopes(unknown) = %v, want any scope", got)
+	}
+}
+
+func TestTiming(t *testing.T) {
+	registry, err := NewRegistry([]Client{{ID: "kiosk", ExpiresIn: 1800, Interval: 10}})
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	if expiry, interval := registry.Timing("kiosk"); expiry != 30*time.Minute || interval != 10*time.Second {
+		t.Errorf("kiosk timing = %s, %s; want 30m, 10s", expiry, interval)
+	}
+	if expiry, interval := registry.Timing("tv"); expiry != 0 || interval != 0 {
+		t.Errorf("tv timing = %s, %s; want the global settings", expiry, interval)
+	}
+}
+
+func TestWithheldTokens(t *testing.T) {
+	registry, err := NewRegistry([]Client{
+		{ID: "kiosk", WithheldTokens: []string{"refresh_token"}},
+		{ID: "tv", WithheldTokens: []string{}},
+		{ID: "printer"},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	global := []string{"id_token"}
+	if got := registry.WithheldTokens("kiosk", global); len(got) != 1 || got[0] != "refresh_token" {
+		t.Errorf("kiosk withholds %v, want [refresh_token]", got)
+	}
+	if got := registry.WithheldTokens("tv", global); len(got) != 0 {
+		t.Errorf("tv withholds %v, want nothing", got)
+	}
+	if got := registry.WithheldTokens("printer", global); len(got) != 1 || got[0] != "id_token" {
+		t.Errorf("printer withholds %v, want the global setting", got)
+	}
+}
+
+func TestRequiresAssertion(t *testing.T) {
+	registry, err := NewRegistry([]Client{
+		{ID: "tv", AuthMethod: AuthMethodPrivateKeyJWT, JWKS: []byte(`{"keys":[]}`)},
+		{ID: "printer"},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry failed: %v", err)
+	}
+	if !registry.RequiresAssertion("tv") || registry.RequiresAssertion("printer") {
+		t.Error("only the private_key_jwt client should require an assertion")
+	}
+	if _, ok := registry.ClientJWKS("tv"); !ok {
+		t.Error("expected keys for tv")
+	}
+	if _, ok := registry.ClientJWKS("printer"); ok {
+		t.Error("expected no keys for printer")
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	var registry *Registry
+	if _, ok := registry.Lookup("any"); ok {
+		t.Error("nil registry should have no clients")
+	}
+	if !registry.VerificationURIComplete("any", true) {
+		t.Error("nil registry should use the fallback")
+	}
+	if registry.DisplayName("any") != "any" {
+		t.Error("nil registry should display the client ID")
+	}
+	if registry.IDPHint("any") != "" {
+		t.Error("nil registry should have no identity provider hint")
+	}
+}
@@ -0,0 +1,218 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Keycloak metadata source defaults
+const (
+	DefaultMetadataCacheTTL = 5 * time.Minute
+	maxAdminResponse        = 1 << 20
+)
+
+// Keycloak client attributes holding presentation settings
+const (
+	attrLogoURI           = "logoUri"
+	attrConsentScreenText = "consent.screen.text"
+	attrDisplayOnConsent  = "display.on.consent.screen"
+)
+
+// KeycloakConfig configures a KeycloakSource
+type KeycloakConfig struct {
+	BaseURL      string // Keycloak server URL, e.g. https://sso.example.com
+	Realm        string
+	ClientID     string // Service account client granted the view-clients role
+	ClientSecret string
+	HTTPClient   *http.Client  // http.DefaultClient if nil
+	CacheTTL     time.Duration // How long looked up metadata is reused
+}
+
+// KeycloakSource looks up client metadata with Keycloak's admin API,
+// authenticating as a service account with the client credentials grant.
+// Results, including unknown clients, are cached for CacheTTL, and cached
+// metadata is served through failed refreshes.
+type KeycloakSource struct {
+	cfg      KeycloakConfig
+	tokenURL string
+	lookup   string
+	now      func() time.Time
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	cache       map[string]cachedMetadata
+}
+
+// cachedMetadata is a lookup result and when it was made
+type cachedMetadata struct {
+	metadata  Metadata
+	found     bool
+	fetchedAt time.Time
+}
+
+// keycloakClient is the part of Keycloak's ClientRepresentation shown to users
+type keycloakClient struct {
+	ClientID    string            `json:"clientId"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Attributes  map[string]string `json:"attributes"`
+}
+
+// NewKeycloakSource creates a metadata source for a Keycloak realm
+func NewKeycloakSource(cfg KeycloakConfig) *KeycloakSource {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultMetadataCacheTTL
+	}
+	base := strings.TrimRight(cfg.BaseURL, "/")
+	realm := url.PathEscape(cfg.Realm)
+	return &KeycloakSource{
+		cfg:      cfg,
+		tokenURL: base + "/realms/" + realm + "/protocol/openid-connect/token",
+		lookup:   base + "/admin/realms/" + realm + "/clients",
+		now:      time.Now,
+		cache:    make(map[string]cachedMetadata),
+	}
+}
+
+// ClientMetadata implements MetadataSource
+func (s *KeycloakSource) ClientMetadata(ctx context.Context, clientID string) (Metadata, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cached, ok := s.cache[clientID]
+	if ok && s.now().Sub(cached.fetchedAt) < s.cfg.CacheTTL {
+		return cached.metadata, cached.found, nil
+	}
+
+	metadata, found, err := s.fetch(ctx, clientID)
+	if err != nil {
+		// Serve stale metadata through a failed refresh
+		if ok {
+			return cached.metadata, cached.found, nil
+		}
+		return Metadata{}, false, err
+	}
+	s.cache[clientID] = cachedMetadata{metadata: metadata, found: found, fetchedAt: s.now()}
+	return metadata, found, nil
+}
+
+// fetch looks up a client by its client ID. Callers hold s.mu.
+func (s *KeycloakSource) fetch(ctx context.Context, clientID string) (Metadata, bool, error) {
+	token, err := s.accessToken(ctx)
+	if err != nil {
+		return Metadata{}, false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.lookup+"?"+url.Values{"clientId": {clientID}}.Encode(), nil)
+	if err != nil {
+		return Metadata{}, false, fmt.Errorf("creating client lookup: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return Metadata{}, false, fmt.Errorf("looking up client: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		s.token = "" // Revoked or expired early, fetch a new one next time
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Metadata{}, false, fmt.Errorf("looking up client: %s", resp.Status)
+	}
+
+	var found []keycloakClient
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxAdminResponse)).Decode(&found); err != nil {
+		return Metadata{}, false, fmt.Errorf("parsing client lookup: %w", err)
+	}
+	for _, c := range found {
+		if c.ClientID == clientID {
+			return c.metadata(), true, nil
+		}
+	}
+	return Metadata{}, false, nil
+}
+
+// metadata converts a client representation for display
+func (c keycloakClient) metadata() Metadata {
+	m := Metadata{Name: c.Name}
+	if logo := c.Attributes[attrLogoURI]; validLogoURI(logo) {
+		m.LogoURI = logo
+	}
+	if c.Attributes[attrDisplayOnConsent] == "true" {
+		m.ConsentText = c.Attributes[attrConsentScreenText]
+	}
+	// Names of the form ${key} are message bundle keys the proxy cannot localize
+	if strings.HasPrefix(m.Name, "${") {
+		m.Name = ""
+	}
+	return m
+}
+
+// accessToken returns the service account's access token, requesting a new
+// one when it is missing or about to expire. Callers hold s.mu.
+func (s *KeycloakSource) accessToken(ctx context.Context) (string, error) {
+	if s.token != "" && s.now().Before(s.tokenExpiry) {
+		return s.token, nil
+	}
+
+	data := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {s.cfg.ClientID},
+		"client_secret": {s.cfg.ClientSecret},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("creating service account token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting service account token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting service account token: %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxAdminResponse)).Decode(&token); err != nil {
+		return "", fmt.Errorf("parsing service account token: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("service account token response has no access token")
+	}
+
+	// Renew a little early so requests do not race the expiry
+	lifetime := time.Duration(token.ExpiresIn)*time.Second - 10*time.Second
+	if lifetime < 0 {
+		lifetime = 0
+	}
+	s.token = token.AccessToken
+	s.tokenExpiry = s.now().Add(lifetime)
+	return s.token, nil
+}
+
+// validLogoURI reports whether a logo may be shown on the consent page, which
+// only loads absolute https images
+func validLogoURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
@@ -0,0 +1,148 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeycloakSource(t *testing.T) {
+	var tokens, lookups atomic.Int32
+	var down atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/realms/devices/protocol/openid-connect/token":
+			tokens.Add(1)
+			if r.FormValue("grant_type") != "client_credentials" || r.FormValue("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "admin-token", "expires_in": 300})
+		case "/admin/realms/devices/clients":
+			lookups.Add(1)
+			if down.Load() {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			if r.Header.Get("Authorization") != "Bearer admin-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var found []keycloakClient
+			if r.URL.Query().Get("clientId") == "tv" {
+				found = append(found, keycloakClient{
+					ClientID: "tv",
+					Name:     "Living Room TV",
+					Attributes: map[string]string{
+						attrLogoURI:           "https://cdn.example.com/tv.png",
+						attrDisplayOnConsent:  "true",
+						attrConsentScreenText: "Stream your library",
+					},
+				})
+			}
+			_ = json.NewEncoder(w).Encode(found)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	source := NewKeycloakSource(KeycloakConfig{
+		BaseURL:      server.URL,
+		Realm:        "devices",
+		ClientID:     "device-proxy-admin",
+		ClientSecret: "secret",
+		CacheTTL:     time.Minute,
+	})
+	now := time.Now()
+	source.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	metadata, found, err := source.ClientMetadata(ctx, "tv")
+	if err != nil || !found {
+		t.Fatalf("ClientMetadata(tv) = %v, %v", found, err)
+	}
+	want := Metadata{Name: "Living Room TV", LogoURI: "https://cdn.example.com/tv.png", ConsentText: "Stream your library"}
+	if metadata != want {
+		t.Errorf("ClientMetadata(tv) = %+v, want %+v", metadata, want)
+	}
+	if _, found, err := source.ClientMetadata(ctx, "unknown"); err != nil || found {
+		t.Errorf("ClientMetadata(unknown) = %v, %v, want not found", found, err)
+	}
+
+	// Lookups are cached and the service account token reused
+	if _, _, err := source.ClientMetadata(ctx, "tv"); err != nil {
+		t.Fatal(err)
+	}
+	if got := lookups.Load(); got != 2 {
+		t.Errorf("admin API called %d times, want 2", got)
+	}
+	if got := tokens.Load(); got != 1 {
+		t.Errorf("token requested %d times, want 1", got)
+	}
+
+	// Stale metadata is served while Keycloak fails
+	down.Store(true)
+	now = now.Add(2 * time.Minute)
+	if metadata, found, err := source.ClientMetadata(ctx, "tv"); err != nil || !found || metadata != want {
+		t.Errorf("ClientMetadata(tv) during outage = %+v, %v, %v", metadata, found, err)
+	}
+	if _, _, err := source.ClientMetadata(ctx, "kiosk"); err == nil {
+		t.Error("ClientMetadata(kiosk) during outage succeeded without a cached entry")
+	}
+}
+
+func TestKeycloakClientMetadata(t *testing.T) {
+	c := keycloakClient{
+		Name: "${client_account}",
+		Attributes: map[string]string{
+			attrLogoURI:           "http://cdn.example.com/logo.png",
+			attrConsentScreenText: "Hidden unless enabled",
+		},
+	}
+	if got := c.metadata(); got != (Metadata{}) {
+		t.Errorf("metadata() = %+v, want message keys, insecure logos and disabled consent text dropped", got)
+	}
+}
+
+// staticSource is a MetadataSource with fixed answers
+type staticSource struct {
+	metadata Metadata
+	found    bool
+	err      error
+}
+
+func (s staticSource) ClientMetadata(ctx context.Context, clientID string) (Metadata, bool, error) {
+	return s.metadata, s.found, s.err
+}
+
+func TestRegistryMetadata(t *testing.T) {
+	registry, err := NewRegistry([]Client{{ID: "tv", Name: "TV", LogoURI: "https://cdn.example.com/local.png"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if got := registry.Metadata(ctx, "tv"); got.Name != "TV" || got.LogoURI != "https://cdn.example.com/local.png" {
+		t.Errorf("local Metadata = %+v", got)
+	}
+
+	registry.UseMetadataSource(staticSource{metadata: Metadata{Name: "Living Room TV"}, found: true})
+	if got := registry.Metadata(ctx, "tv"); got.Name != "Living Room TV" || got.LogoURI != "https://cdn.example.com/local.png" {
+		t.Errorf("remote Metadata = %+v, want the remote name and the local logo", got)
+	}
+
+	registry.UseMetadataSource(staticSource{err: context.DeadlineExceeded})
+	if got := registry.Metadata(ctx, "tv"); got.Name != "TV" {
+		t.Errorf("Metadata after a failed lookup = %+v, want the local settings", got)
+	}
+
+	registry.UseMetadataSource(staticSource{})
+	if got := registry.Metadata(ctx, "cli"); got.Name != "cli" {
+		t.Errorf("Metadata of an unknown client = %+v, want its ID as name", got)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -28,6 +29,10 @@ type Store interface {
 	// ValidateToken checks if a token exists and is valid
 	ValidateToken(ctx context.Context, token string) error
 
+	// ConsumeToken checks a token like ValidateToken and removes it, so only
+	// one of several concurrent calls for the same token succeeds
+	ConsumeToken(ctx context.Context, token string) error
+
 	// CheckHealth verifies the store is operational
 	CheckHealth(ctx context.Context) error
 }
@@ -35,17 +40,27 @@ type Store interface {
 // Manager handles CSRF token generation and validation
 type Manager struct {
 	store     Store
-	secret    []byte
 	expiresIn time.Duration
+	mode      Mode
+	secure    bool // Send the double-submit cookie over HTTPS only
+
+	mu       sync.RWMutex
+	secret   []byte
+	previous []byte // Secret before the last rotation, still accepted
 }
 
-// NewManager creates a new CSRF token manager
-func NewManager(store Store, secret []byte, expiresIn time.Duration) *Manager {
-	return &Manager{
+// NewManager creates a new CSRF token manager. The store may be nil in
+// double-submit mode.
+func NewManager(store Store, secret []byte, expiresIn time.Duration, opts ...Option) *Manager {
+	m := &Manager{
 		store:     store,
 		secret:    secret,
 		expiresIn: expiresIn,
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 // GenerateToken creates and stores a new CSRF token
@@ -60,9 +75,8 @@ func (m *Manager) GenerateToken(ctx context.Context) (string, error) {
 	token := base64.URLEncoding.EncodeToString(tokenBytes)
 
 	// Create HMAC signature
-	h := hmac.New(sha256.New, m.secret)
-	h.Write([]byte(token))
-	sig := h.Sum(nil)
+	secret, _ := m.secrets()
+	sig := sign(secret, token)
 
 	// Combine token and signature
 	fullToken := fmt.Sprintf("%s.%s",
@@ -80,6 +94,35 @@ func (m *Manager) GenerateToken(ctx context.Context) (string, error) {
 
 // ValidateToken checks if a token is valid
 func (m *Manager) ValidateToken(ctx context.Context, token string) error {
+	if err := m.verifySignature(token); err != nil {
+		return err
+	}
+
+	// Check token validity in store
+	if err := m.store.ValidateToken(ctx, token); err != nil {
+		return fmt.Errorf("validating token: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeToken checks a token like ValidateToken and invalidates it, so that
+// a submitted form cannot be replayed with the same token
+func (m *Manager) ConsumeToken(ctx context.Context, token string) error {
+	if err := m.verifySignature(token); err != nil {
+		return err
+	}
+
+	if err := m.store.ConsumeToken(ctx, token); err != nil {
+		return fmt.Errorf("consuming token: %w", err)
+	}
+
+	return nil
+}
+
+// verifySignature checks the HMAC signature of a store-mode token, accepting
+// tokens issued before a rotation
+func (m *Manager) verifySignature(token string) error {
 	// Validate token format
 	if token == "" {
 		return ErrInvalidToken
@@ -91,30 +134,47 @@ func (m *Manager) ValidateToken(ctx context.Context, token string) error {
 		return ErrInvalidToken
 	}
 
-	// Verify HMAC signature
-	h := hmac.New(sha256.New, m.secret)
-	h.Write([]byte(parts[0])) // token
-	expectedSig := h.Sum(nil)
-
 	actualSig, err := base64.URLEncoding.DecodeString(parts[1])
 	if err != nil {
 		return ErrInvalidToken
 	}
 
-	if !hmac.Equal(expectedSig, actualSig) {
+	secret, previous := m.secrets()
+	if !hmac.Equal(sign(secret, parts[0]), actualSig) &&
+		(previous == nil || !hmac.Equal(sign(previous, parts[0]), actualSig)) {
 		return ErrInvalidToken
 	}
 
-	// Check token validity in store
-	if err := m.store.ValidateToken(ctx, token); err != nil {
-		return fmt.Errorf("validating token: %w", err)
-	}
-
 	return nil
 }
 
+// Rotate replaces the signing secret. Tokens signed with the secret being
+// replaced remain valid until they expire or the secret is rotated again.
+func (m *Manager) Rotate(secret []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.previous, m.secret = m.secret, secret
+}
+
+// secrets returns the current and previous signing secrets
+func (m *Manager) secrets() (current, previous []byte) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.secret, m.previous
+}
+
+// sign computes the HMAC signature of a token
+func sign(secret []byte, token string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(token))
+	return h.Sum(nil)
+}
+
 // CheckHealth verifies the CSRF manager is operational
 func (m *Manager) CheckHealth(ctx context.Context) error {
+	if m.mode == ModeDoubleSubmit {
+		return nil // Tokens do not depend on the store
+	}
 	if err := m.store.CheckHealth(ctx); err != nil {
 		return fmt.Errorf("csrf store health check failed: %w", err)
 	}
@@ -43,6 +43,14 @@ func (m *mockStore) ValidateToken(ctx context.Context, token string) error {
 	return nil
 }
 
+func (m *mockStore) ConsumeToken(ctx context.Context, token string) error {
+	if err := m.ValidateToken(ctx, token); err != nil {
+		return err
+	}
+	delete(m.tokens, token)
+	return nil
+}
+
 func (m *mockStore) CheckHealth(ctx context.Context) error {
 	return m.err
 }
@@ -148,6 +156,61 @@ func TestManager_ValidateToken(t *testing.T) {
 	})
 }
 
+func TestManager_ConsumeToken(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(newMockStore(), []byte("secret"), 15*time.Minute)
+
+	token, err := manager.GenerateToken(ctx)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	if err := manager.ConsumeToken(ctx, token); err != nil {
+		t.Fatalf("ConsumeToken() error = %v", err)
+	}
+	if err := manager.ConsumeToken(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("second ConsumeToken() error = %v, want %v", err, ErrInvalidToken)
+	}
+	if err := manager.ValidateToken(ctx, token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidateToken() after consumption error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	// Forged tokens are rejected before reaching the store
+	if err := manager.ConsumeToken(ctx, "forged.c2lnbmF0dXJl"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("forged ConsumeToken() error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestManager_Rotate(t *testing.T) {
+	ctx := context.Background()
+	manager := NewManager(newMockStore(), []byte("first-secret"), 15*time.Minute)
+
+	before, err := manager.GenerateToken(ctx)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+
+	manager.Rotate([]byte("second-secret"))
+	after, err := manager.GenerateToken(ctx)
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	if err := manager.ValidateToken(ctx, after); err != nil {
+		t.Errorf("token signed with new secret rejected: %v", err)
+	}
+	if err := manager.ValidateToken(ctx, before); err != nil {
+		t.Errorf("token issued before rotation rejected: %v", err)
+	}
+
+	// Only the secret immediately before the current one is accepted
+	manager.Rotate([]byte("third-secret"))
+	if err := manager.ValidateToken(ctx, before); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token from two rotations ago error = %v, want %v", err, ErrInvalidToken)
+	}
+	if err := manager.ValidateToken(ctx, after); err != nil {
+		t.Errorf("token from previous secret rejected: %v", err)
+	}
+}
+
 func TestManager_CheckHealth(t *testing.T) {
 	ctx := context.Background()
 	store := newMockStore()
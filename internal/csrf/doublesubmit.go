@@ -0,0 +1,179 @@
+// Package csrf binds CSRF tokens to a browser cookie in double-submit mode
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode selects how CSRF tokens are checked
+type Mode int
+
+const (
+	// ModeStore records every token in the Store and accepts only recorded tokens
+	ModeStore Mode = iota
+
+	// ModeDoubleSubmit binds tokens to a random cookie, so any instance can
+	// check them without shared storage. A forged request cannot carry a
+	// token matching the victim's cookie.
+	ModeDoubleSubmit
+)
+
+// CookieName is the cookie binding double-submit tokens to a browser
+const CookieName = "device_csrf"
+
+// Option configures a Manager
+type Option func(*Manager)
+
+// WithMode selects how tokens are checked, ModeStore by default
+func WithMode(mode Mode) Option {
+	return func(m *Manager) {
+		m.mode = mode
+	}
+}
+
+// WithSecureCookie restricts the double-submit cookie to HTTPS
+func WithSecureCookie(secure bool) Option {
+	return func(m *Manager) {
+		m.secure = secure
+	}
+}
+
+// ParseMode parses a mode name: "store" or "double_submit"
+func ParseMode(name string) (Mode, error) {
+	switch name {
+	case "store", "":
+		return ModeStore, nil
+	case "double_submit":
+		return ModeDoubleSubmit, nil
+	default:
+		return ModeStore, fmt.Errorf("unknown csrf mode %q", name)
+	}
+}
+
+// IssueToken returns a fresh token for a form rendered in response to r.
+// Every rendering gets its own token, so a token exposed in one page does not
+// outlive that page's expiry. In double-submit mode the binding cookie is set
+// on w when r does not carry one, so call IssueToken before writing the body.
+func (m *Manager) IssueToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if m.mode != ModeDoubleSubmit {
+		return m.GenerateToken(r.Context())
+	}
+
+	binding := bindingFrom(r)
+	if binding == "" {
+		var err error
+		if binding, err = randomString(32); err != nil {
+			return "", err
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     CookieName,
+			Value:    binding,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   m.secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	nonce, err := randomString(16)
+	if err != nil {
+		return "", err
+	}
+	payload := nonce + "." + strconv.FormatInt(time.Now().Add(m.expiresIn).Unix(), 10)
+
+	secret, _ := m.secrets()
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signBound(secret, binding, payload)), nil
+}
+
+// ValidateRequest checks the token submitted with r
+func (m *Manager) ValidateRequest(r *http.Request, token string) error {
+	if m.mode != ModeDoubleSubmit {
+		return m.ValidateToken(r.Context(), token)
+	}
+
+	binding := bindingFrom(r)
+	if binding == "" {
+		return ErrInvalidToken
+	}
+
+	idx := strings.LastIndex(token, ".")
+	if idx < 0 {
+		return ErrInvalidToken
+	}
+	payload := token[:idx]
+	sig, err := base64.RawURLEncoding.DecodeString(token[idx+1:])
+	if err != nil {
+		return ErrInvalidToken
+	}
+
+	secret, previous := m.secrets()
+	if !hmac.Equal(signBound(secret, binding, payload), sig) &&
+		(previous == nil || !hmac.Equal(signBound(previous, binding, payload), sig)) {
+		return ErrInvalidToken
+	}
+
+	_, expiry, ok := strings.Cut(payload, ".")
+	if !ok {
+		return ErrInvalidToken
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrTokenExpired
+	}
+
+	return nil
+}
+
+// ConsumeRequest checks the token submitted with r and, in store mode,
+// invalidates it so the submission cannot be replayed. Double-submit tokens
+// are not recorded anywhere and stay valid until they expire.
+func (m *Manager) ConsumeRequest(r *http.Request, token string) error {
+	if m.mode != ModeDoubleSubmit {
+		return m.ConsumeToken(r.Context(), token)
+	}
+	return m.ValidateRequest(r, token)
+}
+
+// CheckIssued checks that token was issued for the browser sending r without
+// requiring it to be unused, so that a resubmitted form can be recognised after
+// the original submission consumed its token. It offers no replay protection.
+func (m *Manager) CheckIssued(r *http.Request, token string) error {
+	if m.mode != ModeDoubleSubmit {
+		return m.verifySignature(token)
+	}
+	return m.ValidateRequest(r, token)
+}
+
+// bindingFrom returns the double-submit cookie value of r, if any
+func bindingFrom(r *http.Request) string {
+	c, err := r.Cookie(CookieName)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// signBound signs a token payload for the browser holding binding. The mode
+// is included so double-submit signatures never verify as store tokens.
+func signBound(secret []byte, binding, payload string) []byte {
+	return sign(secret, "double-submit|"+binding+"|"+payload)
+}
+
+// randomString returns n random bytes encoded for use in cookies and forms
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating random bytes: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
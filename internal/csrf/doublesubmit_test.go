@@ -0,0 +1,167 @@
+package csrf
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// issue issues a double-submit token and returns it with the cookies a
+// browser would send back
+func issue(t *testing.T, m *Manager, r *http.Request) (string, []*http.Cookie) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	token, err := m.IssueToken(w, r)
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+	return token, w.Result().Cookies()
+}
+
+// withCookies returns a form post carrying cookies
+func withCookies(cookies []*http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/device", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func TestManager_DoubleSubmit(t *testing.T) {
+	// No store: tokens must validate without shared storage
+	manager := NewManager(nil, []byte("secret"), time.Minute, WithMode(ModeDoubleSubmit), WithSecureCookie(true))
+
+	token, cookies := issue(t, manager, httptest.NewRequest(http.MethodGet, "/device", nil))
+	if len(cookies) != 1 || cookies[0].Name != CookieName || !cookies[0].HttpOnly || !cookies[0].Secure {
+		t.Fatalf("cookies = %+v, want one secure HttpOnly %s cookie", cookies, CookieName)
+	}
+	if strings.Contains(token, cookies[0].Value) {
+		t.Error("token exposes the cookie value")
+	}
+
+	browser := withCookies(cookies)
+	if err := manager.ValidateRequest(browser, token); err != nil {
+		t.Errorf("ValidateRequest() error = %v", err)
+	}
+
+	// Later renderings reuse the cookie but get their own token
+	second, more := issue(t, manager, browser)
+	if len(more) != 0 {
+		t.Errorf("reissued cookie %+v for a browser that has one", more)
+	}
+	if second == token {
+		t.Error("token was not rotated")
+	}
+	if err := manager.ValidateRequest(browser, second); err != nil {
+		t.Errorf("ValidateRequest(second) error = %v", err)
+	}
+
+	if err := manager.CheckHealth(browser.Context()); err != nil {
+		t.Errorf("CheckHealth() error = %v, want nil without a store", err)
+	}
+}
+
+func TestManager_DoubleSubmitRejects(t *testing.T) {
+	manager := NewManager(nil, []byte("secret"), time.Minute, WithMode(ModeDoubleSubmit))
+	token, cookies := issue(t, manager, httptest.NewRequest(http.MethodGet, "/device", nil))
+	_, otherCookies := issue(t, manager, httptest.NewRequest(http.MethodGet, "/device", nil))
+
+	expiring := NewManager(nil, []byte("secret"), -time.Minute, WithMode(ModeDoubleSubmit))
+	expired, expiredCookies := issue(t, expiring, httptest.NewRequest(http.MethodGet, "/device", nil))
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		token   string
+		wantErr error
+	}{
+		{name: "missing cookie", request: withCookies(nil), token: token, wantErr: ErrInvalidToken},
+		{name: "another browser's cookie", request: withCookies(otherCookies), token: token, wantErr: ErrInvalidToken},
+		{name: "tampered token", request: withCookies(cookies), token: token + "x", wantErr: ErrInvalidToken},
+		{name: "empty token", request: withCookies(cookies), token: "", wantErr: ErrInvalidToken},
+		{name: "expired token", request: withCookies(expiredCookies), token: expired, wantErr: ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := manager.ValidateRequest(tt.request, tt.token); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateRequest() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManager_DoubleSubmitRotate(t *testing.T) {
+	manager := NewManager(nil, []byte("first"), time.Minute, WithMode(ModeDoubleSubmit))
+	token, cookies := issue(t, manager, httptest.NewRequest(http.MethodGet, "/device", nil))
+
+	manager.Rotate([]byte("second"))
+	if err := manager.ValidateRequest(withCookies(cookies), token); err != nil {
+		t.Errorf("token issued before rotation rejected: %v", err)
+	}
+}
+
+func TestManager_StoreModeIssue(t *testing.T) {
+	store := newMockStore()
+	manager := NewManager(store, []byte("secret"), time.Minute)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/device", nil)
+	token, err := manager.IssueToken(w, r)
+	if err != nil {
+		t.Fatalf("IssueToken() error = %v", err)
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("store mode set a cookie")
+	}
+	if _, ok := store.tokens[token]; !ok {
+		t.Error("store mode token was not recorded")
+	}
+	if err := manager.ValidateRequest(r, token); err != nil {
+		t.Errorf("ValidateRequest() error = %v", err)
+	}
+}
+
+func TestManager_CheckIssued(t *testing.T) {
+	// Consumed store tokens are still recognised as issued
+	store := NewManager(newMockStore(), []byte("secret"), time.Minute)
+	r := httptest.NewRequest(http.MethodPost, "/device", nil)
+	token, err := store.GenerateToken(r.Context())
+	if err != nil {
+		t.Fatalf("GenerateToken() error = %v", err)
+	}
+	if err := store.ConsumeRequest(r, token); err != nil {
+		t.Fatalf("ConsumeRequest() error = %v", err)
+	}
+	if err := store.CheckIssued(r, token); err != nil {
+		t.Errorf("CheckIssued(consumed) error = %v", err)
+	}
+	if err := store.CheckIssued(r, "forged.c2lnbmF0dXJl"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("CheckIssued(forged) error = %v, want %v", err, ErrInvalidToken)
+	}
+
+	// Double-submit tokens stay bound to the browser they were issued for
+	manager := NewManager(nil, []byte("secret"), time.Minute, WithMode(ModeDoubleSubmit))
+	bound, cookies := issue(t, manager, httptest.NewRequest(http.MethodGet, "/device", nil))
+	_, otherCookies := issue(t, manager, httptest.NewRequest(http.MethodGet, "/device", nil))
+	if err := manager.CheckIssued(withCookies(cookies), bound); err != nil {
+		t.Errorf("CheckIssued() error = %v", err)
+	}
+	if err := manager.CheckIssued(withCookies(otherCookies), bound); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("CheckIssued(another browser) error = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for name, want := range map[string]Mode{"": ModeStore, "store": ModeStore, "double_submit": ModeDoubleSubmit} {
+		if got, err := ParseMode(name); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseMode("cookie"); err == nil {
+		t.Error("ParseMode accepted an unknown mode")
+	}
+}
@@ -14,11 +14,27 @@ const tokenPrefix = "csrf:"
 // RedisStore implements the Store interface using Redis
 type RedisStore struct {
 	client *redis.Client
+	prefix string // Namespace prepended to every key
+}
+
+// RedisOption configures the Redis store
+type RedisOption func(*RedisStore)
+
+// WithKeyPrefix namespaces the token keys so that several proxy environments
+// can share one Redis instance
+func WithKeyPrefix(prefix string) RedisOption {
+	return func(s *RedisStore) {
+		s.prefix = prefix
+	}
 }
 
 // NewRedisStore creates a new Redis-backed CSRF token store
-func NewRedisStore(client *redis.Client) Store {
-	return &RedisStore{client: client}
+func NewRedisStore(client *redis.Client, opts ...RedisOption) Store {
+	s := &RedisStore{client: client}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // SaveToken stores a CSRF token with expiration
@@ -26,10 +42,12 @@ func (s *RedisStore) SaveToken(ctx context.Context, token string, expiresIn time
 	if token == "" {
 		return errors.New("empty token")
 	}
+	if expiresIn <= 0 {
+		return errors.New("token expiry must be positive")
+	}
 
 	// Store the token with expiration
-	key := tokenPrefix + token
-	if err := s.client.Set(ctx, key, "1", expiresIn).Err(); err != nil {
+	if err := s.client.Set(ctx, s.key(token), "1", expiresIn).Err(); err != nil {
 		return fmt.Errorf("storing token: %w", err)
 	}
 
@@ -42,26 +60,60 @@ func (s *RedisStore) ValidateToken(ctx context.Context, token string) error {
 		return ErrInvalidToken
 	}
 
-	// Check if token exists
-	key := tokenPrefix + token
-	exists, err := s.client.Exists(ctx, key).Result()
+	status, err := checkToken.Run(ctx, s.client, []string{s.key(token)}, 0).Int()
 	if err != nil {
 		return fmt.Errorf("checking token: %w", err)
 	}
-	if exists == 0 {
+	return tokenStatus(status)
+}
+
+// ConsumeToken validates a token and deletes it in the same step, so that
+// concurrent submissions of one token cannot both succeed
+func (s *RedisStore) ConsumeToken(ctx context.Context, token string) error {
+	if token == "" {
 		return ErrInvalidToken
 	}
 
-	// Get TTL to check expiration
-	ttl, err := s.client.TTL(ctx, key).Result()
+	status, err := checkToken.Run(ctx, s.client, []string{s.key(token)}, 1).Int()
 	if err != nil {
-		return fmt.Errorf("checking token TTL: %w", err)
+		return fmt.Errorf("consuming token: %w", err)
 	}
-	if ttl <= 0 {
+	return tokenStatus(status)
+}
+
+// key returns the Redis key of a token
+func (s *RedisStore) key(token string) string {
+	return s.prefix + tokenPrefix + token
+}
+
+// checkToken reports a token's state from its remaining lifetime in one round
+// trip: 1 when valid, 0 when missing and -1 when stored without an expiry,
+// which SaveToken never does. Tokens are deleted when ARGV[1] is 1, and
+// tokens without an expiry are always deleted so they cannot linger.
+var checkToken = redis.NewScript(`
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl == -2 then
+	return 0
+end
+if ttl == -1 or ARGV[1] == "1" then
+	redis.call("DEL", KEYS[1])
+end
+if ttl == -1 then
+	return -1
+end
+return 1
+`)
+
+// tokenStatus maps a checkToken result to an error
+func tokenStatus(status int) error {
+	switch status {
+	case 1:
+		return nil
+	case -1:
 		return ErrTokenExpired
+	default:
+		return ErrInvalidToken
 	}
-
-	return nil
 }
 
 // CheckHealth verifies Redis connectivity
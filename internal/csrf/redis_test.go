@@ -0,0 +1,30 @@
+package csrf
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTokenStatus(t *testing.T) {
+	tests := []struct {
+		status  int
+		wantErr error
+	}{
+		{status: 1},
+		{status: 0, wantErr: ErrInvalidToken},
+		{status: -1, wantErr: ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		if err := tokenStatus(tt.status); !errors.Is(err, tt.wantErr) {
+			t.Errorf("tokenStatus(%d) = %v, want %v", tt.status, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRedisStoreKey(t *testing.T) {
+	s := NewRedisStore(nil, WithKeyPrefix("staging:")).(*RedisStore)
+	if got := s.key("abc"); got != "staging:csrf:abc" {
+		t.Errorf("key = %q, want staging:csrf:abc", got)
+	}
+}
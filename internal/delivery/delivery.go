@@ -0,0 +1,209 @@
+// Package delivery POSTs signed payloads to receivers from a background
+// worker, retrying transient failures with exponential backoff so that slow
+// or unavailable receivers never hold up the device flow
+package delivery
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Delivery defaults
+const (
+	DefaultInitialBackoff = time.Second
+	DefaultMaxBackoff     = time.Minute
+	DefaultTimeout        = 5 * time.Second
+	DefaultQueueSize      = 256
+)
+
+var (
+	// ErrClosed is returned for requests enqueued after Close
+	ErrClosed = errors.New("delivery queue closed")
+
+	// ErrQueueFull is returned when the queue has no room for a request
+	ErrQueueFull = errors.New("delivery queue full")
+
+	// errPermanent marks delivery failures that retrying cannot fix
+	errPermanent = errors.New("permanent delivery failure")
+)
+
+// Config configures delivery
+type Config struct {
+	MaxRetries     int           // Retries after the first attempt
+	InitialBackoff time.Duration // Delay before the first retry, doubled per attempt
+	MaxBackoff     time.Duration // Upper bound on retry delay
+	Timeout        time.Duration // Per-attempt request timeout
+	QueueSize      int           // Requests buffered before new ones are dropped
+	Client         *http.Client  // Optional HTTP client
+}
+
+// Request is a POST ready to be delivered, signed by the caller
+type Request struct {
+	Name   string // Describes the request in logs, so must not reveal secrets
+	URL    string
+	Header http.Header
+	Body   []byte
+}
+
+// Queue delivers requests one at a time from a background worker
+type Queue struct {
+	cfg    Config
+	client *http.Client
+	queue  chan Request
+	done   chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+}
+
+// New creates a queue and starts its delivery worker
+func New(cfg Config) *Queue {
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = DefaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: cfg.Timeout}
+	}
+
+	q := &Queue{
+		cfg:    cfg,
+		client: client,
+		queue:  make(chan Request, cfg.QueueSize),
+		done:   make(chan struct{}),
+	}
+
+	q.wg.Add(1)
+	go q.run()
+
+	return q
+}
+
+// Enqueue queues a request for delivery without waiting for it
+func (q *Queue) Enqueue(req Request) error {
+	select {
+	case <-q.done:
+		return ErrClosed
+	default:
+	}
+
+	select {
+	case q.queue <- req:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting requests and waits for queued deliveries to finish or
+// ctx to expire, returning ctx's error in that case
+func (q *Queue) Close(ctx context.Context) error {
+	q.once.Do(func() { close(q.done) })
+
+	finished := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run delivers queued requests until the queue is closed and drained
+func (q *Queue) run() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case req := <-q.queue:
+			q.deliverWithRetry(req)
+		case <-q.done:
+			for {
+				select {
+				case req := <-q.queue:
+					q.deliverWithRetry(req)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliverWithRetry attempts delivery with exponential backoff on transient failures
+func (q *Queue) deliverWithRetry(req Request) {
+	backoff := q.cfg.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := q.deliver(req)
+		if err == nil {
+			return
+		}
+		if errors.Is(err, errPermanent) || attempt >= q.cfg.MaxRetries {
+			log.Printf("Error: delivering %s after %d attempts: %v", req.Name, attempt+1, err)
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > q.cfg.MaxBackoff {
+			backoff = q.cfg.MaxBackoff
+		}
+	}
+}
+
+// deliver performs a single POST of the request
+func (q *Queue) deliver(r Request) error {
+	ctx, cancel := context.WithTimeout(context.Background(), q.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(r.Body))
+	if err != nil {
+		return fmt.Errorf("%w: creating request: %v", errPermanent, err)
+	}
+	for name, values := range r.Header {
+		req.Header[name] = values
+	}
+
+	resp, err := q.client.Do(req)
+	if errors.Is(err, ErrForbiddenAddress) {
+		return fmt.Errorf("%w: %v", errPermanent, err)
+	}
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("receiver returned %s", resp.Status)
+	default:
+		return fmt.Errorf("%w: receiver returned %s", errPermanent, resp.Status)
+	}
+}
@@ -0,0 +1,59 @@
+package delivery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for connections to addresses PublicClient refuses
+var ErrForbiddenAddress = errors.New("address is not publicly routable")
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, which
+// netip does not count as private
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// PublicClient returns a client for receivers named by untrusted parties, such
+// as devices registering callback URIs. Addresses are checked when dialing,
+// after name resolution, so DNS cannot point the client at loopback, private
+// or link-local hosts. Redirects are not followed, and the 3xx response is
+// returned as the result instead.
+func PublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+			}
+			if !Public(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Public reports whether addr is a globally routable unicast address
+func Public(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!sharedAddressSpace.Contains(addr)
+}
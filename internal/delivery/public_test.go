@@ -0,0 +1,64 @@
+package delivery
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "10.0.0.5"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "100.64.0.1"},
+		{addr: "169.254.169.254"},
+		{addr: "fe80::1"},
+		{addr: "fd00::1"},
+		{addr: "0.0.0.0"},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "224.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := Public(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("Public(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublicClientRefusesLoopback(t *testing.T) {
+	var reached bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer srv.Close()
+
+	_, err := PublicClient(time.Second).Get(srv.URL)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("Get error = %v, want %v", err, ErrForbiddenAddress)
+	}
+	if reached {
+		t.Error("request reached a loopback server")
+	}
+}
+
+func TestPublicClientRefusesRedirects(t *testing.T) {
+	client := PublicClient(time.Second)
+	req := httptest.NewRequest(http.MethodPost, "https://device.example/cb", nil)
+	if err := client.CheckRedirect(req, []*http.Request{req}); !errors.Is(err, http.ErrUseLastResponse) {
+		t.Errorf("CheckRedirect() = %v, want %v", err, http.ErrUseLastResponse)
+	}
+}
@@ -0,0 +1,153 @@
+// Package deviceflow detects device codes polled by unexpected clients
+package deviceflow
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+	"github.com/wrale/oauth2-device-proxy/internal/features"
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// Poll anomaly reasons
+const (
+	AnomalyNetworkChanged   = "network_changed"    // Poller is outside the requester's network
+	AnomalyUserAgentChanged = "user_agent_changed" // Poller sent a different User-Agent
+)
+
+// Prefix lengths within which a poller's address counts as the requester's
+// network, tolerating address changes inside one site or provider allocation
+const (
+	AnomalyIPv4PrefixLength = 24
+	AnomalyIPv6PrefixLength = 48
+)
+
+// MaxUserAgentLength bounds the User-Agent stored with a device code
+const MaxUserAgentLength = 512
+
+// Poller describes the client making a token request
+type Poller struct {
+	IP        string
+	UserAgent string
+}
+
+// pollerKey is the context key under which the current Poller is stored
+type pollerKey struct{}
+
+// WithPoller returns a context carrying the client making a token request, so
+// that CheckDeviceCode can compare it with the device that requested the code
+func WithPoller(ctx context.Context, poller Poller) context.Context {
+	return context.WithValue(ctx, pollerKey{}, poller)
+}
+
+// pollerFrom returns the Poller carried by ctx, if any
+func pollerFrom(ctx context.Context) (Poller, bool) {
+	poller, ok := ctx.Value(pollerKey{}).(Poller)
+	return poller, ok
+}
+
+// PollAnomaly records a token request from a client that did not match the
+// device that requested the code, which can indicate a leaked device code
+type PollAnomaly struct {
+	Reasons    []string  `json:"reasons"`
+	IP         string    `json:"ip,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	DetectedAt time.Time `json:"detected_at"`
+
+	// Reverify requires the user to approve again with the anomaly shown
+	Reverify bool `json:"reverify,omitempty"`
+}
+
+// pollAnomalies counts device codes flagged for polls from unexpected clients
+var pollAnomalies = metrics.Default.NewCounter(
+	"device_proxy_poll_anomalies_total",
+	"Device codes flagged because they were polled from a different network or User-Agent than requested them.",
+)
+
+// checkPoller flags the device code the first time it is polled by a client
+// that differs from the requester, persisting the flag and emitting an event
+func (f *flowImpl) checkPoller(ctx context.Context, code *DeviceCode) {
+	if code.Anomaly != nil {
+		return // Flagged once per flow
+	}
+	poller, ok := pollerFrom(ctx)
+	if !ok {
+		return
+	}
+
+	reasons := anomalyReasons(code, poller)
+	if len(reasons) == 0 {
+		return
+	}
+
+	code.Anomaly = &PollAnomaly{
+		Reasons:    reasons,
+		IP:         poller.IP,
+		UserAgent:  truncate(poller.UserAgent, MaxUserAgentLength),
+		DetectedAt: time.Now().UTC(),
+		Reverify:   f.reverifyAnomalies(code.ClientID),
+	}
+	if err := f.store.SaveDeviceCode(ctx, code); err != nil {
+		log.Printf("Warning: failed to persist poll anomaly: %v", err)
+	}
+
+	pollAnomalies.Inc()
+	f.emit(ctx, events.TypePollAnomaly, code, map[string]any{
+		"reasons":    reasons,
+		"request_ip": code.RequestIP,
+		"poller_ip":  poller.IP,
+	})
+}
+
+// anomalyReasons compares a poller with the client that requested the code.
+// Attributes the requester did not record are not compared.
+func anomalyReasons(code *DeviceCode, poller Poller) []string {
+	var reasons []string
+	if code.RequestIP != "" && poller.IP != "" && !sameNetwork(code.RequestIP, poller.IP) {
+		reasons = append(reasons, AnomalyNetworkChanged)
+	}
+	if code.RequestUserAgent != "" && truncate(poller.UserAgent, MaxUserAgentLength) != code.RequestUserAgent {
+		reasons = append(reasons, AnomalyUserAgentChanged)
+	}
+	return reasons
+}
+
+// sameNetwork reports whether two addresses share a network prefix. Addresses
+// that do not parse are compared as strings.
+func sameNetwork(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if ipA == nil || ipB == nil {
+		return a == b
+	}
+
+	if v4A, v4B := ipA.To4(), ipB.To4(); v4A != nil || v4B != nil {
+		if v4A == nil || v4B == nil {
+			return false
+		}
+		mask := net.CIDRMask(AnomalyIPv4PrefixLength, 32)
+		return v4A.Mask(mask).Equal(v4B.Mask(mask))
+	}
+
+	mask := net.CIDRMask(AnomalyIPv6PrefixLength, 128)
+	return ipA.Mask(mask).Equal(ipB.Mask(mask))
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// reverifyAnomalies reports whether anomalous codes of a client require
+// approval again
+func (f *flowImpl) reverifyAnomalies(clientID string) bool {
+	if f.features != nil {
+		return f.features.EnabledFor(features.AnomalyReverify, clientID)
+	}
+	return f.anomalyReverify
+}
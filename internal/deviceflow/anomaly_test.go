@@ -0,0 +1,130 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+	"github.com/wrale/oauth2-device-proxy/internal/features"
+)
+
+func TestSameNetwork(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"203.0.113.7", "203.0.113.7", true},
+		{"203.0.113.7", "203.0.113.200", true},
+		{"203.0.113.7", "198.51.100.7", false},
+		{"2001:db8:1::1", "2001:db8:1:ffff::2", true},
+		{"2001:db8:1::1", "2001:db8:2::1", false},
+		{"203.0.113.7", "::ffff:203.0.113.9", true},
+		{"203.0.113.7", "2001:db8::1", false},
+		{"unix-socket", "unix-socket", true},
+		{"unix-socket", "203.0.113.7", false},
+	}
+
+	for _, tt := range tests {
+		if got := sameNetwork(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameNetwork(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestPollAnomaly(t *testing.T) {
+	tests := []struct {
+		name         string
+		poller       *Poller
+		reverify     bool
+		flags        *features.Set
+		wantReverify bool
+		wantReasons  []string
+	}{
+		{
+			name:   "same client",
+			poller: &Poller{IP: "203.0.113.50", UserAgent: "tv-app/1.0"},
+		},
+		{
+			name: "no poller information",
+		},
+		{
+			name:        "different network",
+			poller:      &Poller{IP: "198.51.100.7", UserAgent: "tv-app/1.0"},
+			wantReasons: []string{AnomalyNetworkChanged},
+		},
+		{
+			name:         "different network and user agent",
+			poller:       &Poller{IP: "198.51.100.7", UserAgent: "curl/8.0"},
+			reverify:     true,
+			wantReverify: true,
+			wantReasons:  []string{AnomalyNetworkChanged, AnomalyUserAgentChanged},
+		},
+		{
+			name:         "feature flag overrides the option",
+			poller:       &Poller{IP: "198.51.100.7", UserAgent: "tv-app/1.0"},
+			flags:        features.New(features.AnomalyReverify),
+			wantReverify: true,
+			wantReasons:  []string{AnomalyNetworkChanged},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMockStore()
+			emitter := &recordingEmitter{}
+			flow := NewFlow(store, "https://example.com",
+				WithEventEmitter(emitter), WithAnomalyReverification(tt.reverify), WithFeatures(tt.flags))
+
+			code, err := flow.RequestDeviceCode(context.Background(), "tv", "",
+				WithRequestOrigin("203.0.113.7", ""), WithRequestUserAgent("tv-app/1.0"))
+			if err != nil {
+				t.Fatalf("RequestDeviceCode failed: %v", err)
+			}
+
+			// Poll twice; the anomaly is flagged once
+			for i := 0; i < 2; i++ {
+				store.deviceCodes[code.DeviceCode].LastPoll = time.Now().Add(-time.Minute)
+				ctx := context.Background()
+				if tt.poller != nil {
+					ctx = WithPoller(ctx, *tt.poller)
+				}
+				if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrPendingAuthorization) {
+					t.Fatalf("CheckDeviceCode error = %v, want %v", err, ErrPendingAuthorization)
+				}
+			}
+
+			stored, err := store.GetDeviceCode(context.Background(), code.DeviceCode)
+			if err != nil {
+				t.Fatalf("GetDeviceCode failed: %v", err)
+			}
+			var flagged int
+			for _, e := range emitter.events {
+				if e.Type == events.TypePollAnomaly {
+					flagged++
+				}
+			}
+
+			if tt.wantReasons == nil {
+				if stored.Anomaly != nil || flagged != 0 {
+					t.Errorf("anomaly = %+v with %d events, want none", stored.Anomaly, flagged)
+				}
+				return
+			}
+			if stored.Anomaly == nil {
+				t.Fatal("device code was not flagged")
+			}
+			if got := strings.Join(stored.Anomaly.Reasons, ","); got != strings.Join(tt.wantReasons, ",") {
+				t.Errorf("reasons = %s, want %v", got, tt.wantReasons)
+			}
+			if stored.Anomaly.IP != tt.poller.IP || stored.Anomaly.Reverify != tt.wantReverify {
+				t.Errorf("anomaly = %+v", stored.Anomaly)
+			}
+			if flagged != 1 {
+				t.Errorf("emitted %d anomaly events, want 1", flagged)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package deviceflow
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MaxAuthParamLength bounds each forwarded authorization parameter value
+const MaxAuthParamLength = 1024
+
+// reservedAuthParams are set by the proxy itself, so forwarding them would let
+// a device override the flow's own request to the authorization server
+var reservedAuthParams = map[string]bool{
+	"response_type":         true,
+	"response_mode":         true,
+	"client_id":             true,
+	"client_secret":         true,
+	"client_assertion":      true,
+	"client_assertion_type": true,
+	"redirect_uri":          true,
+	"state":                 true,
+	"scope":                 true,
+	"nonce":                 true,
+	"code":                  true,
+	"code_verifier":         true,
+	"code_challenge":        true,
+	"code_challenge_method": true,
+	"grant_type":            true,
+	"device_code":           true,
+	"request":               true,
+	"request_uri":           true,
+	"kc_idp_hint":           true,
+	"resource":              true, // Handled natively per RFC 8707
+	"pow_challenge":         true, // Proof of work, consumed by the proxy
+	"pow_nonce":             true,
+}
+
+// ValidateForwardedParams checks an allowlist of device authorization request
+// parameters to forward upstream, such as audience, resource, acr_values and
+// prompt. Parameters the proxy sets itself cannot be allowed.
+func ValidateForwardedParams(names []string) error {
+	for _, name := range names {
+		if name == "" {
+			return fmt.Errorf("forwarded parameter names must not be empty")
+		}
+		if reservedAuthParams[name] {
+			return fmt.Errorf("parameter %q is set by the proxy and cannot be forwarded", name)
+		}
+	}
+	return nil
+}
+
+// WithAuthParams records extra parameters of the device authorization request
+// that are forwarded to the authorization and token requests made for it.
+// Callers pass only allowlisted parameters; empty values are ignored.
+func WithAuthParams(params map[string]string) RequestOption {
+	return func(code *DeviceCode) {
+		for name, value := range params {
+			if value == "" {
+				continue
+			}
+			if code.AuthParams == nil {
+				code.AuthParams = make(map[string]string, len(params))
+			}
+			code.AuthParams[name] = value
+		}
+	}
+}
+
+// validateAuthParams rejects forwarded parameters that would override the
+// proxy's own or that are too large to store
+func validateAuthParams(params map[string]string) error {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if reservedAuthParams[name] {
+			return NewDeviceFlowError(ErrorCodeInvalidRequest, "The "+name+" parameter cannot be forwarded")
+		}
+		if len(params[name]) > MaxAuthParamLength {
+			return NewDeviceFlowError(ErrorCodeInvalidRequest, "The "+name+" parameter is too long")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package deviceflow
+
+import (
+	"fmt"
+	"time"
+)
+
+// IntervalGrowth returns the polling interval required of a device after it
+// is told to slow down, given the interval it was previously required to use
+type IntervalGrowth func(current time.Duration) time.Duration
+
+// Slow down strategy names accepted by ParseIntervalGrowth
+const (
+	GrowthFixed       = "fixed"
+	GrowthExponential = "exponential"
+)
+
+// FixedGrowth adds step to the interval on each slow_down. FixedGrowth with
+// SlowDownIncrement is the behavior RFC 8628 section 3.5 requires.
+func FixedGrowth(step time.Duration) IntervalGrowth {
+	return func(current time.Duration) time.Duration {
+		return current + step
+	}
+}
+
+// ExponentialGrowth multiplies the interval by factor on each slow_down,
+// always growing by at least SlowDownIncrement so that RFC 8628 section 3.5
+// is still met for short intervals
+func ExponentialGrowth(factor float64) IntervalGrowth {
+	return func(current time.Duration) time.Duration {
+		next := time.Duration(float64(current) * factor)
+		if next < current+SlowDownIncrement {
+			next = current + SlowDownIncrement
+		}
+		return next
+	}
+}
+
+// CappedGrowth limits the interval produced by growth to max. Intervals
+// already above the cap are left unchanged.
+func CappedGrowth(growth IntervalGrowth, max time.Duration) IntervalGrowth {
+	return func(current time.Duration) time.Duration {
+		next := growth(current)
+		if next > max {
+			next = max
+		}
+		if next < current {
+			next = current
+		}
+		return next
+	}
+}
+
+// ParseIntervalGrowth returns the named slow_down strategy, capped at max when
+// it is positive. An empty name selects the fixed RFC 8628 increment.
+func ParseIntervalGrowth(name string, factor float64, max time.Duration) (IntervalGrowth, error) {
+	var growth IntervalGrowth
+	switch name {
+	case "", GrowthFixed:
+		growth = FixedGrowth(SlowDownIncrement)
+	case GrowthExponential:
+		if factor <= 1 {
+			return nil, fmt.Errorf("exponential slow_down factor must be greater than 1, got %g", factor)
+		}
+		growth = ExponentialGrowth(factor)
+	default:
+		return nil, fmt.Errorf("unknown slow_down strategy %q", name)
+	}
+
+	if max > 0 {
+		growth = CappedGrowth(growth, max)
+	}
+	return growth, nil
+}
@@ -0,0 +1,120 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIntervalGrowth(t *testing.T) {
+	tests := []struct {
+		name    string
+		growth  IntervalGrowth
+		current time.Duration
+		want    time.Duration
+	}{
+		{name: "fixed", growth: FixedGrowth(SlowDownIncrement), current: 5 * time.Second, want: 10 * time.Second},
+		{name: "exponential", growth: ExponentialGrowth(2), current: 20 * time.Second, want: 40 * time.Second},
+		{name: "exponential grows by at least the RFC increment", growth: ExponentialGrowth(1.1), current: 5 * time.Second, want: 10 * time.Second},
+		{name: "capped", growth: CappedGrowth(ExponentialGrowth(2), time.Minute), current: 40 * time.Second, want: time.Minute},
+		{name: "capped never shrinks", growth: CappedGrowth(FixedGrowth(SlowDownIncrement), time.Minute), current: 2 * time.Minute, want: 2 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.growth(tt.current); got != tt.want {
+				t.Errorf("growth(%v) = %v, want %v", tt.current, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseIntervalGrowth(t *testing.T) {
+	for _, name := range []string{"", GrowthFixed, GrowthExponential} {
+		if _, err := ParseIntervalGrowth(name, 2, 0); err != nil {
+			t.Errorf("ParseIntervalGrowth(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := ParseIntervalGrowth("linear", 2, 0); err == nil {
+		t.Error("unknown strategy accepted")
+	}
+	if _, err := ParseIntervalGrowth(GrowthExponential, 1, 0); err == nil {
+		t.Error("exponential factor of 1 accepted")
+	}
+
+	growth, err := ParseIntervalGrowth(GrowthExponential, 3, 30*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := growth(20 * time.Second); got != 30*time.Second {
+		t.Errorf("capped exponential growth = %v, want 30s", got)
+	}
+}
+
+func TestSlowDownUsesIntervalGrowth(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com",
+		WithPollInterval(5*time.Second),
+		WithIntervalGrowth(CappedGrowth(ExponentialGrowth(3), 20*time.Second)),
+	)
+	code, err := flow.RequestDeviceCode(ctx, "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	for _, want := range []time.Duration{15 * time.Second, 20 * time.Second, 20 * time.Second} {
+		_, err := flow.CheckDeviceCode(ctx, code.DeviceCode)
+		if !errors.Is(err, ErrSlowDown) {
+			t.Fatalf("poll error = %v, want %v", err, ErrSlowDown)
+		}
+		if wait, ok := RetryAfter(err); !ok || wait != want {
+			t.Errorf("RetryAfter = %v, %v; want %v", wait, ok, want)
+		}
+	}
+}
+
+// racingStore runs race when a poll is refused, between the flow reading the
+// device code and persisting its slowed down interval
+type racingStore struct {
+	*mockStore
+	race func()
+}
+
+func (s *racingStore) RecordPoll(ctx context.Context, code *DeviceCode, limit PollLimit) (bool, error) {
+	allowed, err := s.mockStore.RecordPoll(ctx, code, limit)
+	if err == nil && !allowed && s.race != nil {
+		s.race()
+		s.race = nil
+	}
+	return allowed, err
+}
+
+func TestSlowDownKeepsConcurrentDenial(t *testing.T) {
+	ctx := context.Background()
+	store := &racingStore{mockStore: newMockStore()}
+	flow := NewFlow(store, "https://example.com", WithPollInterval(5*time.Second))
+	code, err := flow.RequestDeviceCode(ctx, "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	// The user denies the request while the device is told to slow down
+	store.race = func() {
+		if err := flow.DenyAuthorization(ctx, code.DeviceCode); err != nil {
+			t.Errorf("DenyAuthorization failed: %v", err)
+		}
+	}
+	if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrSlowDown) {
+		t.Fatalf("poll error = %v, want %v", err, ErrSlowDown)
+	}
+
+	stored, _ := store.GetDeviceCode(ctx, code.DeviceCode)
+	if !stored.Denied || stored.Interval != 10 {
+		t.Errorf("stored code denied = %v, interval = %d; want the denial kept and the interval raised to 10", stored.Denied, stored.Interval)
+	}
+	if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("next poll error = %v, want %v", err, ErrAccessDenied)
+	}
+}
@@ -0,0 +1,179 @@
+// Package deviceflow implements pre-generated device code batches for offline devices
+package deviceflow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+	"github.com/wrale/oauth2-device-proxy/internal/ttl"
+)
+
+const (
+	// MaxBatchSize is the maximum number of device codes generated in one batch
+	MaxBatchSize = 1000
+
+	// MaxBatchExpiry bounds how long pre-generated device codes may be allowed
+	// to remain redeemable with WithMaxBatchExpiry
+	MaxBatchExpiry = ttl.MaxBatchCode
+
+	// batchIDLength is the length of batch identifiers in hex characters
+	batchIDLength = 16
+)
+
+// Batch tracks device codes pre-generated together, typically embedded in kiosks
+// that poll the token endpoint once connectivity returns
+type Batch struct {
+	ID          string    `json:"id"`
+	ClientID    string    `json:"client_id"`
+	Scope       string    `json:"scope,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	DeviceCodes []string  `json:"device_codes"`
+	Invalidated bool      `json:"invalidated,omitempty"`
+}
+
+// CreateBatch generates count device codes sharing an extended expiry, up to
+// the batch expiry cap. Each code behaves like one issued by RequestDeviceCode
+// once the device starts polling.
+func (f *flowImpl) CreateBatch(ctx context.Context, clientID, scope string, count int, expiry time.Duration) (*Batch, []*DeviceCode, error) {
+	if clientID == "" {
+		return nil, nil, NewDeviceFlowError(ErrorCodeInvalidRequest, "The client_id parameter is REQUIRED")
+	}
+	if count <= 0 || count > MaxBatchSize {
+		return nil, nil, NewDeviceFlowError(ErrorCodeInvalidRequest, "Batch size must be between 1 and 1000")
+	}
+	if expiry > f.maxBatchExpiry {
+		return nil, nil, NewDeviceFlowError(ErrorCodeInvalidRequest, fmt.Sprintf("Batch expiry exceeds the maximum of %s", f.maxBatchExpiry))
+	}
+	if expiry < f.expiryDuration {
+		expiry = f.expiryDuration
+	}
+	scope, err := f.checkScope(clientID, scope)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	batchID, err := generateSecureCode(batchIDLength)
+	if err != nil {
+		return nil, nil, NewDeviceFlowError(ErrorCodeServerError, "Failed to generate batch identifier")
+	}
+
+	batch := &Batch{
+		ID:          batchID,
+		ClientID:    clientID,
+		Scope:       scope,
+		CreatedAt:   time.Now(),
+		DeviceCodes: make([]string, 0, count),
+	}
+
+	codes := make([]*DeviceCode, 0, count)
+	for i := 0; i < count; i++ {
+		code, err := f.newDeviceCode(clientID, scope, expiry)
+		if err != nil {
+			return nil, nil, NewDeviceFlowError(ErrorCodeServerError, "Failed to generate device code")
+		}
+		code.BatchID = batchID
+
+		codes = append(codes, code)
+		batch.DeviceCodes = append(batch.DeviceCodes, code.DeviceCode)
+		if code.ExpiresAt.After(batch.ExpiresAt) {
+			batch.ExpiresAt = code.ExpiresAt
+		}
+	}
+
+	// Save the codes and the batch together so a failure leaves no partial batch
+	err = transact(ctx, f.store, func(tx Tx) error {
+		for _, code := range codes {
+			if err := tx.SaveDeviceCode(code); err != nil {
+				return err
+			}
+		}
+		return tx.SaveBatch(batch)
+	})
+	if err != nil {
+		f.discardCodes(ctx, codes)
+		return nil, nil, NewDeviceFlowError(ErrorCodeServerError, "Failed to save batch")
+	}
+
+	for _, code := range codes {
+		f.emit(ctx, events.TypeDeviceCodeCreated, code, map[string]any{
+			"expires_at": code.ExpiresAt,
+			"batch_id":   batchID,
+		})
+	}
+
+	return batch, codes, nil
+}
+
+// GetBatch returns the batch and those of its device codes that are still stored.
+// Codes that expired or were invalidated are omitted.
+func (f *flowImpl) GetBatch(ctx context.Context, batchID string) (*Batch, []*DeviceCode, error) {
+	batch, err := f.store.GetBatch(ctx, batchID)
+	if err != nil {
+		return nil, nil, NewDeviceFlowError(ErrorCodeServerError, "Failed to get batch")
+	}
+	if batch == nil {
+		return nil, nil, nil
+	}
+
+	stored, err := f.store.BatchGetDeviceCodes(ctx, batch.DeviceCodes)
+	if err != nil {
+		return nil, nil, NewDeviceFlowError(ErrorCodeServerError, "Failed to get device codes")
+	}
+
+	codes := make([]*DeviceCode, 0, len(stored))
+	for _, code := range stored {
+		if code == nil || time.Now().After(code.ExpiresAt) {
+			continue
+		}
+		code.ExpiresIn = int(time.Until(code.ExpiresAt).Seconds())
+		codes = append(codes, code)
+	}
+
+	return batch, codes, nil
+}
+
+// InvalidateBatch deletes every outstanding device code in the batch, returning how
+// many were revoked. Devices polling with a revoked code are rejected as unknown.
+func (f *flowImpl) InvalidateBatch(ctx context.Context, batchID string) (int, error) {
+	batch, err := f.store.GetBatch(ctx, batchID)
+	if err != nil {
+		return 0, NewDeviceFlowError(ErrorCodeServerError, "Failed to get batch")
+	}
+	if batch == nil {
+		return 0, NewDeviceFlowError(ErrorCodeInvalidRequest, "Unknown batch")
+	}
+
+	stored, err := f.store.BatchGetDeviceCodes(ctx, batch.DeviceCodes)
+	if err != nil {
+		return 0, NewDeviceFlowError(ErrorCodeServerError, "Failed to get device codes")
+	}
+	revoked := 0
+	for _, code := range stored {
+		if code != nil {
+			revoked++
+		}
+	}
+	if err := f.store.BatchDeleteDeviceCodes(ctx, batch.DeviceCodes); err != nil {
+		return 0, NewDeviceFlowError(ErrorCodeServerError, "Failed to delete device codes")
+	}
+
+	batch.Invalidated = true
+	if err := f.store.SaveBatch(ctx, batch); err != nil {
+		return revoked, NewDeviceFlowError(ErrorCodeServerError, "Failed to save batch")
+	}
+
+	return revoked, nil
+}
+
+// discardCodes removes codes a store without transactions saved before a
+// batch failed to complete
+func (f *flowImpl) discardCodes(ctx context.Context, codes []*DeviceCode) {
+	deviceCodes := make([]string, len(codes))
+	for i, code := range codes {
+		deviceCodes[i] = code.DeviceCode
+	}
+	_ = f.store.BatchDeleteDeviceCodes(ctx, deviceCodes) // Best effort, codes expire regardless
+}
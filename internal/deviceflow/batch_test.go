@@ -0,0 +1,129 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCreateBatch(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com", WithMaxBatchExpiry(MaxBatchExpiry))
+
+	batch, codes, err := flow.CreateBatch(ctx, "kiosk", "openid", 3, 7*24*time.Hour)
+	if err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+
+	if len(codes) != 3 || len(batch.DeviceCodes) != 3 {
+		t.Fatalf("batch has %d codes (%d tracked), want 3", len(codes), len(batch.DeviceCodes))
+	}
+	for _, code := range codes {
+		if code.BatchID != batch.ID {
+			t.Errorf("code batch ID = %q, want %q", code.BatchID, batch.ID)
+		}
+		if time.Until(code.ExpiresAt) < 6*24*time.Hour {
+			t.Errorf("code expires at %v, want extended expiry", code.ExpiresAt)
+		}
+
+		// Pre-generated codes are redeemable through the normal verification path
+		verified, err := flow.VerifyUserCode(ctx, code.UserCode)
+		if err != nil {
+			t.Fatalf("VerifyUserCode failed: %v", err)
+		}
+		if verified.DeviceCode != code.DeviceCode {
+			t.Errorf("verified device code = %q, want %q", verified.DeviceCode, code.DeviceCode)
+		}
+	}
+
+	got, outstanding, err := flow.GetBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatalf("GetBatch failed: %v", err)
+	}
+	if got.ClientID != "kiosk" || len(outstanding) != 3 {
+		t.Errorf("GetBatch returned client %q with %d codes", got.ClientID, len(outstanding))
+	}
+}
+
+func TestCreateBatchValidation(t *testing.T) {
+	flow := NewFlow(newMockStore(), "https://example.com", WithMaxBatchExpiry(7*24*time.Hour))
+
+	tests := []struct {
+		name     string
+		clientID string
+		count    int
+		expiry   time.Duration
+	}{
+		{name: "missing client", count: 1, expiry: time.Hour},
+		{name: "zero count", clientID: "kiosk", expiry: time.Hour},
+		{name: "count too large", clientID: "kiosk", count: MaxBatchSize + 1, expiry: time.Hour},
+		{name: "expiry too long", clientID: "kiosk", count: 1, expiry: 8 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := flow.CreateBatch(context.Background(), tt.clientID, "", tt.count, tt.expiry)
+			var dferr *DeviceFlowError
+			if !errors.As(err, &dferr) || dferr.Code != ErrorCodeInvalidRequest {
+				t.Errorf("CreateBatch error = %v, want invalid_request", err)
+			}
+		})
+	}
+}
+
+func TestCreateBatchExpiryCap(t *testing.T) {
+	ctx := context.Background()
+
+	// Without an explicit batch cap, batch codes are capped like any other code
+	flow := NewFlow(newMockStore(), "https://example.com", WithMaxExpiryDuration(20*time.Minute))
+	if _, _, err := flow.CreateBatch(ctx, "kiosk", "", 1, 20*time.Minute); err != nil {
+		t.Errorf("CreateBatch at the code cap failed: %v", err)
+	}
+	if _, _, err := flow.CreateBatch(ctx, "kiosk", "", 1, time.Hour); err == nil {
+		t.Error("CreateBatch beyond the code cap succeeded")
+	}
+
+	// The batch cap itself never exceeds MaxBatchExpiry
+	flow = NewFlow(newMockStore(), "https://example.com", WithMaxBatchExpiry(MaxBatchExpiry+time.Hour))
+	if _, _, err := flow.CreateBatch(ctx, "kiosk", "", 1, MaxBatchExpiry+time.Hour); err == nil {
+		t.Error("CreateBatch beyond MaxBatchExpiry succeeded")
+	}
+}
+
+func TestInvalidateBatch(t *testing.T) {
+	ctx := context.Background()
+	flow := NewFlow(newMockStore(), "https://example.com")
+
+	batch, codes, err := flow.CreateBatch(ctx, "kiosk", "", 2, 0)
+	if err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+
+	revoked, err := flow.InvalidateBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatalf("InvalidateBatch failed: %v", err)
+	}
+	if revoked != 2 {
+		t.Errorf("revoked = %d, want 2", revoked)
+	}
+
+	for _, code := range codes {
+		if _, err := flow.VerifyUserCode(ctx, code.UserCode); err == nil {
+			t.Errorf("user code %s still verifies after invalidation", code.UserCode)
+		}
+	}
+
+	got, outstanding, err := flow.GetBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatalf("GetBatch failed: %v", err)
+	}
+	if !got.Invalidated || len(outstanding) != 0 {
+		t.Errorf("batch invalidated = %v with %d outstanding codes", got.Invalidated, len(outstanding))
+	}
+
+	if _, err := flow.InvalidateBatch(ctx, "unknown"); err == nil {
+		t.Error("expected error invalidating unknown batch")
+	}
+}
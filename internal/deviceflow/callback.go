@@ -0,0 +1,114 @@
+package deviceflow
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// MaxCallbackURILength bounds the callback_uri of a device authorization request
+const MaxCallbackURILength = 512
+
+// CallbackSender delivers the outcome of an authorization to the callback URI
+// its device registered, so that devices reachable from the proxy need not
+// wait for their next poll. Delivery is asynchronous and must not block or
+// fail the flow; devices keep polling as the fallback.
+type CallbackSender interface {
+	// SendCallback delivers the token response, or the error ending the
+	// flow when token is nil, to code.CallbackURI
+	SendCallback(ctx context.Context, code *DeviceCode, token *TokenResponse, failure *DeviceFlowError)
+}
+
+// WithCallbackSender enables the callback_uri request parameter, delivering
+// authorization outcomes through sender. Without it requests naming a
+// callback URI are rejected.
+func WithCallbackSender(sender CallbackSender) Option {
+	return func(f *flowImpl) {
+		f.callbacks = sender
+	}
+}
+
+// CallbackOriginPolicy returns the origins, such as https://device.example:8443,
+// at which a client's devices may register callback URIs
+type CallbackOriginPolicy func(clientID string) []string
+
+// WithCallbackOrigins limits callback URIs to the origins registered for each
+// client. Without it, or for clients without origins, callback URIs are
+// refused, so that devices cannot point the proxy at arbitrary hosts.
+func WithCallbackOrigins(policy CallbackOriginPolicy) Option {
+	return func(f *flowImpl) {
+		f.origins = policy
+	}
+}
+
+// WithCallbackURI records the URI the device wants the authorization outcome
+// POSTed to. An empty URI is ignored.
+func WithCallbackURI(uri string) RequestOption {
+	return func(code *DeviceCode) {
+		code.CallbackURI = uri
+	}
+}
+
+// validateCallbackURI checks that a callback URI is an absolute HTTPS URL at
+// one of the client's registered origins. Plain HTTP is refused because the
+// callback carries the access token.
+func (f *flowImpl) validateCallbackURI(clientID, uri string) error {
+	if uri == "" {
+		return nil
+	}
+	if f.callbacks == nil {
+		return NewDeviceFlowError(ErrorCodeInvalidRequest, "The callback_uri parameter is not supported")
+	}
+	if len(uri) > MaxCallbackURILength {
+		return NewDeviceFlowError(ErrorCodeInvalidRequest, "The callback_uri parameter is too long")
+	}
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil || u.Fragment != "" {
+		return NewDeviceFlowError(ErrorCodeInvalidRequest, "The callback_uri parameter must be an absolute https URL")
+	}
+	if f.origins != nil {
+		origin := callbackOrigin(u)
+		for _, allowed := range f.origins(clientID) {
+			if a, err := url.Parse(allowed); err == nil && callbackOrigin(a) == origin {
+				return nil
+			}
+		}
+	}
+	return NewDeviceFlowError(ErrorCodeInvalidRequest, "The callback_uri parameter is not at an origin registered for the client")
+}
+
+// ValidateCallbackOrigins checks a list of callback origins, each an https
+// URL of a host and optional port only
+func ValidateCallbackOrigins(origins []string) error {
+	for _, origin := range origins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("%q is not an https origin", origin)
+		}
+	}
+	return nil
+}
+
+// callbackOrigin returns the origin of an https URL with the host lowercased
+// and the default port left out, so that equal origins compare alike
+func callbackOrigin(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port := u.Port(); port != "" && port != "443" {
+		host += ":" + port
+	}
+	return "https://" + host
+}
+
+// sendCallback delivers an authorization outcome to the device when it
+// registered a callback URI
+func (f *flowImpl) sendCallback(ctx context.Context, code *DeviceCode, token *TokenResponse, failure *DeviceFlowError) {
+	if f.callbacks == nil || code.CallbackURI == "" {
+		return
+	}
+	f.callbacks.SendCallback(ctx, code, f.deliver(code, token), failure)
+}
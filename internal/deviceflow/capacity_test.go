@@ -0,0 +1,102 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestMaxOutstandingCodes(t *testing.T) {
+	ctx := context.Background()
+	flow := NewFlow(newMockStore(), "https://example.com", WithMaxOutstandingCodes(2))
+
+	first, err := flow.RequestDeviceCode(ctx, "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if _, err := flow.RequestDeviceCode(ctx, "tv", ""); err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	before := shedRequests.Value()
+	if _, err := flow.RequestDeviceCode(ctx, "tv", ""); !errors.Is(err, ErrCapacityExceeded) {
+		t.Fatalf("RequestDeviceCode over cap = %v, want %v", err, ErrCapacityExceeded)
+	}
+	if got := shedRequests.Value() - before; got != 1 {
+		t.Errorf("shed counter increased by %v, want 1", got)
+	}
+	if err := flow.CheckCapacity(ctx); !errors.Is(err, ErrCapacityExceeded) {
+		t.Errorf("CheckCapacity() = %v, want %v", err, ErrCapacityExceeded)
+	}
+
+	// Completed codes no longer count against the cap
+	if err := flow.CompleteAuthorization(ctx, first.DeviceCode, &TokenResponse{AccessToken: "at"}); err != nil {
+		t.Fatalf("CompleteAuthorization failed: %v", err)
+	}
+	if err := flow.CheckCapacity(ctx); err != nil {
+		t.Errorf("CheckCapacity() after completion = %v, want nil", err)
+	}
+	if _, err := flow.RequestDeviceCode(ctx, "tv", ""); err != nil {
+		t.Errorf("RequestDeviceCode after completion failed: %v", err)
+	}
+}
+
+func TestMaxOutstandingCodesExcludesBatches(t *testing.T) {
+	ctx := context.Background()
+	flow := NewFlow(newMockStore(), "https://example.com", WithMaxOutstandingCodes(2))
+
+	// Pre-generated codes wait for their devices without holding up others
+	if _, _, err := flow.CreateBatch(ctx, "kiosk", "", 5, 0); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+	if err := flow.CheckCapacity(ctx); err != nil {
+		t.Errorf("CheckCapacity() with a batch outstanding = %v, want nil", err)
+	}
+	if _, err := flow.RequestDeviceCode(ctx, "tv", ""); err != nil {
+		t.Errorf("RequestDeviceCode with a batch outstanding failed: %v", err)
+	}
+}
+
+func TestMaxOutstandingCodesDisabled(t *testing.T) {
+	flow := NewFlow(newMockStore(), "https://example.com")
+	for i := 0; i < 5; i++ {
+		if _, err := flow.RequestDeviceCode(context.Background(), "tv", ""); err != nil {
+			t.Fatalf("RequestDeviceCode failed: %v", err)
+		}
+	}
+}
+
+func TestMaxCodesPerClient(t *testing.T) {
+	ctx := context.Background()
+	flow := NewFlow(newMockStore(), "https://example.com", WithMaxCodesPerClient(2))
+
+	first, err := flow.RequestDeviceCode(ctx, "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if _, err := flow.RequestDeviceCode(ctx, "tv", ""); err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if _, err := flow.RequestDeviceCode(ctx, "tv", ""); !errors.Is(err, ErrClientQuotaExceeded) {
+		t.Fatalf("RequestDeviceCode over quota = %v, want %v", err, ErrClientQuotaExceeded)
+	}
+
+	// Other clients have their own quota
+	if _, err := flow.RequestDeviceCode(ctx, "kiosk", ""); err != nil {
+		t.Errorf("RequestDeviceCode for another client failed: %v", err)
+	}
+
+	// Authorized codes count until they are removed
+	if err := flow.CompleteAuthorization(ctx, first.DeviceCode, &TokenResponse{AccessToken: "at"}); err != nil {
+		t.Fatalf("CompleteAuthorization failed: %v", err)
+	}
+	if _, err := flow.RequestDeviceCode(ctx, "tv", ""); !errors.Is(err, ErrClientQuotaExceeded) {
+		t.Errorf("RequestDeviceCode with a token awaiting pickup = %v, want %v", err, ErrClientQuotaExceeded)
+	}
+	if _, err := flow.RevokeClient(ctx, "tv"); err != nil {
+		t.Fatalf("RevokeClient failed: %v", err)
+	}
+	if _, err := flow.RequestDeviceCode(ctx, "tv", ""); err != nil {
+		t.Errorf("RequestDeviceCode after revocation failed: %v", err)
+	}
+}
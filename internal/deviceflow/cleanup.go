@@ -0,0 +1,255 @@
+package deviceflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// DefaultCleanupInterval is how often the janitor sweeps the store
+const DefaultCleanupInterval = time.Minute
+
+// cleanupScanCount is the SCAN batch size used when sweeping keys
+const cleanupScanCount = 100
+
+// CleanupResult reports what a store sweep removed
+type CleanupResult struct {
+	ExpiredCodes         int           // Expired device codes removed
+	Expired              []*DeviceCode // Codes that expired awaiting authorization, as their tombstones record them
+	OrphanedUserCodes    int           // User code references whose device code no longer exists
+	OrphanedPollCounters int           // Poll and rate limit keys whose device code no longer exists
+	Pending              int           // Pending authorizations remaining after the sweep
+}
+
+// Cleanup removes expired device codes from the pending sets along with any
+// records still attached to them, then sweeps user code references and poll
+// counters left behind by expiry races or partial writes
+func (s *RedisStore) Cleanup(ctx context.Context) (*CleanupResult, error) {
+	result := &CleanupResult{}
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+
+	for _, set := range []string{s.key(pendingKey), s.key(batchPendingKey)} {
+		expired, err := s.client.ZRangeByScore(ctx, set, &redis.ZRangeBy{Min: "-inf", Max: now}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("listing expired device codes: %w", err)
+		}
+		for _, deviceCode := range expired {
+			// Only the sweep that takes the code out of the pending set reports
+			// it, so replicas sweeping concurrently report each code once
+			removed, err := s.client.ZRem(ctx, set, deviceCode).Result()
+			if err != nil {
+				return nil, fmt.Errorf("removing expired device code: %w", err)
+			}
+			if removed == 0 {
+				continue
+			}
+			code, err := s.expiredCode(ctx, deviceCode)
+			if err != nil {
+				return nil, err
+			}
+			if err := s.DeleteDeviceCode(ctx, deviceCode); err != nil {
+				return nil, err
+			}
+			result.ExpiredCodes++
+			result.Expired = append(result.Expired, code)
+		}
+	}
+
+	// Client indexes only need their expired entries pruned
+	iter := s.client.Scan(ctx, 0, s.pattern(clientPrefix, "*"), cleanupScanCount).Iterator()
+	for iter.Next(ctx) {
+		if err := s.client.ZRemRangeByScore(ctx, iter.Val(), "-inf", now).Err(); err != nil {
+			return nil, fmt.Errorf("pruning client index: %w", err)
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("scanning client indexes: %w", err)
+	}
+
+	// User code references hold the device code as their value
+	var err error
+	result.OrphanedUserCodes, err = s.sweep(ctx, s.pattern(userPrefix, "*"), func(key string) (string, bool) {
+		deviceCode, err := s.client.Get(ctx, key).Result()
+		return deviceCode, err == nil
+	}, func(key, deviceCode string) error {
+		return repairUserMapping.Run(ctx, s.client, []string{key}, deviceCode).Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sweeping user codes: %w", err)
+	}
+
+	// Poll counters and rate limit timestamps embed the device code in their key
+	dropKey := func(key, _ string) error { return s.client.Del(ctx, key).Err() }
+	for _, pattern := range []string{s.pattern(pollPrefix, "*"), s.pattern(ratePrefix, "*:time")} {
+		n, err := s.sweep(ctx, pattern, s.counterDeviceCode, dropKey)
+		if err != nil {
+			return nil, fmt.Errorf("sweeping poll counters: %w", err)
+		}
+		result.OrphanedPollCounters += n
+	}
+
+	pending, err := s.CountPendingDeviceCodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result.Pending = pending
+
+	return result, nil
+}
+
+// expiredCode returns the record of a code leaving the pending set, from the
+// expired tombstone written with it, or just the device code when tombstones
+// are disabled
+func (s *RedisStore) expiredCode(ctx context.Context, deviceCode string) (*DeviceCode, error) {
+	data, err := s.client.Get(ctx, s.expiredKey(deviceCode)).Result()
+	if err == redis.Nil {
+		return &DeviceCode{DeviceCode: deviceCode, Tombstone: TombstoneExpired}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting tombstone: %w", err)
+	}
+
+	var t tombstone
+	if err := json.Unmarshal([]byte(data), &t); err != nil {
+		return nil, fmt.Errorf("unmarshaling tombstone: %w", err)
+	}
+	return &DeviceCode{
+		DeviceCode: t.DeviceCode,
+		UserCode:   t.UserCode,
+		ClientID:   t.ClientID,
+		ExpiresAt:  t.ExpiresAt,
+		Tombstone:  t.Reason,
+	}, nil
+}
+
+// sweep scans keys matching pattern and removes those whose device code no
+// longer exists, returning the number removed
+func (s *RedisStore) sweep(ctx context.Context, pattern string,
+	deviceCodeOf func(key string) (string, bool), remove func(key, deviceCode string) error) (int, error) {
+	removed := 0
+	iter := s.client.Scan(ctx, 0, pattern, cleanupScanCount).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		deviceCode, ok := deviceCodeOf(key)
+		if !ok {
+			continue
+		}
+
+		exists, err := s.client.Exists(ctx, s.key(devicePrefix, deviceCode), s.key(tokenPrefix, deviceCode)).Result()
+		if err != nil {
+			return removed, err
+		}
+		if exists > 0 {
+			continue
+		}
+
+		if err := remove(key, deviceCode); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, iter.Err()
+}
+
+// counterDeviceCode extracts the device code from a poll counter or rate limit key
+func (s *RedisStore) counterDeviceCode(key string) (string, bool) {
+	key = strings.TrimPrefix(key, s.prefix)
+	var deviceCode string
+	switch {
+	case strings.HasPrefix(key, pollPrefix):
+		deviceCode = strings.TrimPrefix(key, pollPrefix)
+	case strings.HasPrefix(key, ratePrefix) && strings.HasSuffix(key, ":time"):
+		deviceCode = strings.TrimSuffix(strings.TrimPrefix(key, ratePrefix), ":time")
+	}
+	return deviceCode, deviceCode != ""
+}
+
+// Janitor metrics
+var (
+	pendingAuthorizations = metrics.Default.NewGauge(
+		"device_proxy_pending_authorizations",
+		"Device codes awaiting user authorization, as of the last cleanup.",
+	)
+	cleanupRemoved = metrics.Default.NewCounter(
+		"device_proxy_cleanup_removed_total",
+		"Records removed by the background janitor, by kind.",
+		"kind",
+	)
+)
+
+// Janitor periodically sweeps expired and orphaned records from the store
+type Janitor struct {
+	store    Store
+	interval time.Duration
+	events   events.Emitter
+}
+
+// JanitorOption configures a Janitor
+type JanitorOption func(*Janitor)
+
+// WithJanitorEvents emits code.expired for each code a sweep retires to its
+// expired tombstone, rather than each time an expired code is read
+func WithJanitorEvents(emitter events.Emitter) JanitorOption {
+	return func(j *Janitor) {
+		if emitter != nil {
+			j.events = emitter
+		}
+	}
+}
+
+// NewJanitor creates a janitor that cleans the store at the given interval,
+// or DefaultCleanupInterval when the interval is not positive
+func NewJanitor(store Store, interval time.Duration, opts ...JanitorOption) *Janitor {
+	if interval <= 0 {
+		interval = DefaultCleanupInterval
+	}
+	j := &Janitor{store: store, interval: interval, events: events.NopEmitter{}}
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// Run sweeps the store until the context is cancelled
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Warning: store cleanup failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce performs a single sweep and updates the janitor metrics
+func (j *Janitor) RunOnce(ctx context.Context) (*CleanupResult, error) {
+	result, err := j.store.Cleanup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cleanupRemoved.Add(float64(result.ExpiredCodes), "expired_code")
+	cleanupRemoved.Add(float64(result.OrphanedUserCodes), "orphaned_user_code")
+	cleanupRemoved.Add(float64(result.OrphanedPollCounters), "orphaned_poll_counter")
+	pendingAuthorizations.Set(float64(result.Pending))
+	for _, code := range result.Expired {
+		j.events.Emit(ctx, codeEvent(events.TypeCodeExpired, code, nil))
+	}
+
+	return result, nil
+}
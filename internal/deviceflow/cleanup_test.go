@@ -0,0 +1,90 @@
+package deviceflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+)
+
+func TestCounterDeviceCode(t *testing.T) {
+	tests := []struct {
+		prefix string
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{key: "poll:abc123", want: "abc123", wantOK: true},
+		{key: "rate:abc123:time", want: "abc123", wantOK: true},
+		{key: "rate:abc123", wantOK: false},
+		{key: "poll:", wantOK: false},
+		{key: "device:abc123", wantOK: false},
+		{prefix: "staging:", key: "staging:poll:abc123", want: "abc123", wantOK: true},
+		{prefix: "staging:", key: "staging:rate:abc123:time", want: "abc123", wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			s := &RedisStore{prefix: tt.prefix}
+			got, ok := s.counterDeviceCode(tt.key)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("counterDeviceCode(%q) = %q, %v, want %q, %v", tt.key, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestKeyPrefix(t *testing.T) {
+	s := NewRedisStore(nil, WithKeyPrefix("env[1]:")).(*RedisStore)
+
+	if got := s.key(devicePrefix, "abc"); got != "env[1]:device:abc" {
+		t.Errorf("key() = %q", got)
+	}
+	if got := s.timeKey("abc"); got != "env[1]:rate:abc:time" {
+		t.Errorf("timeKey() = %q", got)
+	}
+	if got := s.pattern(userPrefix, "*"); got != `env\[1\]:user:*` {
+		t.Errorf("pattern() = %q", got)
+	}
+}
+
+func TestJanitorRunOnce(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	now := time.Now()
+
+	store.deviceCodes["live"] = &DeviceCode{DeviceCode: "live", UserCode: "BCDF-GHJK", ExpiresAt: now.Add(time.Minute)}
+	store.deviceCodes["expired"] = &DeviceCode{DeviceCode: "expired", UserCode: "LMNP-QRST", ExpiresAt: now.Add(-time.Minute)}
+	store.userCodes["BCDFGHJK"] = "live"
+	store.userCodes["LMNPQRST"] = "expired"
+	store.polls["live"] = []time.Time{now}
+	store.polls["gone"] = []time.Time{now}
+
+	emitter := &recordingEmitter{}
+	result, err := NewJanitor(store, 0, WithJanitorEvents(emitter)).RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+
+	if result.ExpiredCodes != 1 || result.OrphanedUserCodes != 1 || result.OrphanedPollCounters != 1 || result.Pending != 1 {
+		t.Errorf("RunOnce() = %+v, want 1 of each", *result)
+	}
+	if len(result.Expired) != 1 || result.Expired[0].DeviceCode != "expired" {
+		t.Errorf("Expired = %v, want the expired code", result.Expired)
+	}
+	if got := emitter.types(); len(got) != 1 || got[0] != events.TypeCodeExpired {
+		t.Errorf("events = %v, want [%s]", got, events.TypeCodeExpired)
+	}
+	if _, ok := store.userCodes["BCDFGHJK"]; !ok {
+		t.Error("live user code reference was removed")
+	}
+	if got := pendingAuthorizations.Value(); got != 1 {
+		t.Errorf("pending gauge = %v, want 1", got)
+	}
+
+	store.healthy = false
+	if _, err := NewJanitor(store, 0).RunOnce(ctx); err == nil {
+		t.Error("RunOnce() on unhealthy store returned nil error")
+	}
+}
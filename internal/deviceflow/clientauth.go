@@ -0,0 +1,40 @@
+package deviceflow
+
+import "context"
+
+// WithClientAuthentication records that the device authorization request was
+// made by an authenticated client, such as one presenting a JWT assertion per
+// RFC 7523. Token requests for the code must then be authenticated as well.
+func WithClientAuthentication(authenticated bool) RequestOption {
+	return func(code *DeviceCode) {
+		code.ClientAuthenticated = authenticated
+	}
+}
+
+// authenticatedClientKey is the context key under which the authenticated client is stored
+type authenticatedClientKey struct{}
+
+// WithAuthenticatedClient returns a context carrying the client that
+// authenticated a token request, which CheckDeviceCode requires to match the
+// client the device code was issued to
+func WithAuthenticatedClient(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, authenticatedClientKey{}, clientID)
+}
+
+// checkClientAuthentication rejects token requests that do not authenticate
+// as the client the device code was issued to. Codes issued to public clients
+// may still be polled by an authenticated one only if it is the same client.
+func checkClientAuthentication(ctx context.Context, code *DeviceCode) error {
+	clientID, _ := ctx.Value(authenticatedClientKey{}).(string)
+	if clientID == "" {
+		if code.ClientAuthenticated {
+			return ErrInvalidClient
+		}
+		return nil
+	}
+	if clientID != code.ClientID {
+		// RFC 6749 section 5.2: the grant was issued to another client
+		return ErrInvalidDeviceCode
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClientAuthentication(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	confidential, err := flow.RequestDeviceCode(ctx, "tv", "", WithClientAuthentication(true))
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	public, err := flow.RequestDeviceCode(ctx, "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		code    *DeviceCode
+		client  string
+		wantErr error
+	}{
+		{name: "authenticated client", code: confidential, client: "tv", wantErr: ErrPendingAuthorization},
+		{name: "unauthenticated poll", code: confidential, wantErr: ErrInvalidClient},
+		{name: "other client", code: confidential, client: "printer", wantErr: ErrInvalidDeviceCode},
+		{name: "public code", code: public, wantErr: ErrPendingAuthorization},
+		{name: "public code polled by other client", code: public, client: "printer", wantErr: ErrInvalidDeviceCode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.deviceCodes[tt.code.DeviceCode].LastPoll = time.Now().Add(-time.Minute)
+			pollCtx := ctx
+			if tt.client != "" {
+				pollCtx = WithAuthenticatedClient(ctx, tt.client)
+			}
+			_, err := flow.CheckDeviceCode(pollCtx, tt.code.DeviceCode)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckDeviceCode error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+// Package deviceflow implements listing and revoking the outstanding device codes of a client
+package deviceflow
+
+import (
+	"context"
+)
+
+// clientChunkSize bounds how many device codes are read or deleted per round trip
+const clientChunkSize = 500
+
+// ListClientCodes returns the unexpired device codes issued to a client,
+// including authorized codes whose token awaits pickup
+func (f *flowImpl) ListClientCodes(ctx context.Context, clientID string) ([]*DeviceCode, error) {
+	var codes []*DeviceCode
+	err := f.eachClientChunk(ctx, clientID, func(chunk []*DeviceCode) error {
+		codes = append(codes, chunk...)
+		return nil
+	})
+	return codes, err
+}
+
+// RevokeClient deletes every outstanding device code issued to a client, along
+// with any token awaiting pickup, returning how many were revoked. Devices
+// polling with a revoked code are rejected as unknown.
+func (f *flowImpl) RevokeClient(ctx context.Context, clientID string) (int, error) {
+	revoked := 0
+	err := f.eachClientChunk(ctx, clientID, func(chunk []*DeviceCode) error {
+		deviceCodes := make([]string, len(chunk))
+		for i, code := range chunk {
+			deviceCodes[i] = code.DeviceCode
+		}
+		if err := f.store.BatchDeleteDeviceCodes(ctx, deviceCodes); err != nil {
+			return NewDeviceFlowError(ErrorCodeServerError, "Failed to delete device codes")
+		}
+		revoked += len(chunk)
+		return nil
+	})
+	return revoked, err
+}
+
+// eachClientChunk reads the device codes in a client's index in chunks,
+// skipping codes that expired since they were listed
+func (f *flowImpl) eachClientChunk(ctx context.Context, clientID string, fn func([]*DeviceCode) error) error {
+	if clientID == "" {
+		return ErrMissingClientID
+	}
+
+	deviceCodes, err := f.store.ListDeviceCodesByClient(ctx, clientID)
+	if err != nil {
+		return NewDeviceFlowError(ErrorCodeServerError, "Failed to list device codes")
+	}
+
+	for start := 0; start < len(deviceCodes); start += clientChunkSize {
+		stored, err := f.store.BatchGetDeviceCodes(ctx, deviceCodes[start:min(start+clientChunkSize, len(deviceCodes))])
+		if err != nil {
+			return NewDeviceFlowError(ErrorCodeServerError, "Failed to get device codes")
+		}
+
+		chunk := make([]*DeviceCode, 0, len(stored))
+		for _, code := range stored {
+			if code != nil && code.ClientID == clientID {
+				chunk = append(chunk, code)
+			}
+		}
+		if len(chunk) == 0 {
+			continue
+		}
+		if err := fn(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package deviceflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestClientCodes(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	pending, err := flow.RequestDeviceCode(ctx, "tv", "openid")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	authorized, err := flow.RequestDeviceCode(ctx, "tv", "openid")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if err := store.SaveTokenResponse(ctx, authorized.DeviceCode, &TokenResponse{AccessToken: "at", TokenType: "Bearer"}); err != nil {
+		t.Fatal(err)
+	}
+	_, batchCodes, err := flow.CreateBatch(ctx, "tv", "", 2, 0)
+	if err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+	other, err := flow.RequestDeviceCode(ctx, "kiosk", "openid")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	listed, err := flow.ListClientCodes(ctx, "tv")
+	if err != nil {
+		t.Fatalf("ListClientCodes failed: %v", err)
+	}
+	if want := 2 + len(batchCodes); len(listed) != want {
+		t.Errorf("listed %d codes, want %d", len(listed), want)
+	}
+	if count, err := store.CountDeviceCodesByClient(ctx, "tv"); err != nil || count != len(listed) {
+		t.Errorf("CountDeviceCodesByClient = %d, %v; want %d", count, err, len(listed))
+	}
+
+	revoked, err := flow.RevokeClient(ctx, "tv")
+	if err != nil {
+		t.Fatalf("RevokeClient failed: %v", err)
+	}
+	if want := 2 + len(batchCodes); revoked != want {
+		t.Errorf("revoked = %d, want %d", revoked, want)
+	}
+
+	if _, err := flow.VerifyUserCode(ctx, pending.UserCode); err == nil {
+		t.Error("pending user code still verifies after revocation")
+	}
+	if _, err := flow.CheckDeviceCode(ctx, authorized.DeviceCode); err == nil {
+		t.Error("undelivered token still redeemable after revocation")
+	}
+	if token, _ := store.GetTokenResponse(ctx, authorized.DeviceCode); token != nil {
+		t.Error("token response kept after revocation")
+	}
+	if _, err := flow.VerifyUserCode(ctx, other.UserCode); err != nil {
+		t.Errorf("other client's code revoked: %v", err)
+	}
+
+	if revoked, err := flow.RevokeClient(ctx, "tv"); err != nil || revoked != 0 {
+		t.Errorf("repeated RevokeClient = %d, %v; want nothing left to revoke", revoked, err)
+	}
+	if _, err := flow.RevokeClient(ctx, ""); err == nil {
+		t.Error("expected error revoking without a client ID")
+	}
+}
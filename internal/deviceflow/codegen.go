@@ -25,6 +25,17 @@ func generateSecureCode(length int) (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
+// RequestsOpenID reports whether a space-delimited scope includes openid,
+// making the request an OpenID Connect authentication request
+func RequestsOpenID(scope string) bool {
+	for _, s := range strings.Fields(scope) {
+		if s == "openid" {
+			return true
+		}
+	}
+	return false
+}
+
 // selectRandomChar selects a random character from available set without modulo bias.
 // This implements the guidance in RFC 8628 section 6.1 by ensuring an unbiased
 // selection from the available character set. The algorithm rejects values that
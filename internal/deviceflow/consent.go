@@ -0,0 +1,97 @@
+// Package deviceflow implements the consent step of the verification flow
+package deviceflow
+
+import (
+	"context"
+	"time"
+)
+
+// consentTicketLength is the length of consent tickets in hex characters
+const consentTicketLength = 32
+
+// BeginConsent records that a verified device code awaits the user's approval and
+// returns an opaque ticket for the consent form, keeping the device code itself
+// out of the page
+func (f *flowImpl) BeginConsent(ctx context.Context, deviceCode string) (string, error) {
+	code, err := f.GetDeviceCode(ctx, deviceCode)
+	if err != nil {
+		return "", err // Already wrapped in DeviceFlowError
+	}
+
+	ticket, err := generateSecureCode(consentTicketLength)
+	if err != nil {
+		return "", NewDeviceFlowError(ErrorCodeServerError, "Failed to generate consent ticket")
+	}
+
+	if err := f.store.SaveConsentTicket(ctx, ticket, code.DeviceCode, time.Until(code.ExpiresAt)); err != nil {
+		return "", NewDeviceFlowError(ErrorCodeServerError, "Failed to save consent ticket")
+	}
+
+	return ticket, nil
+}
+
+// ResolveConsent returns the device code awaiting consent for a ticket. Each
+// ticket decides one consent form post; pages shown again issue a new one.
+func (f *flowImpl) ResolveConsent(ctx context.Context, ticket string) (*DeviceCode, error) {
+	if ticket == "" {
+		return nil, NewDeviceFlowError(ErrorCodeInvalidRequest, "Missing consent ticket")
+	}
+
+	deviceCode, err := f.store.ConsumeConsentTicket(ctx, ticket)
+	if err != nil {
+		return nil, NewDeviceFlowError(ErrorCodeServerError, "Failed to consume consent ticket")
+	}
+	if deviceCode == "" {
+		return nil, NewDeviceFlowError(ErrorCodeInvalidRequest, "Unknown, expired or already used consent ticket")
+	}
+
+	code, err := f.GetDeviceCode(ctx, deviceCode)
+	if err != nil {
+		return nil, err // Already wrapped in DeviceFlowError
+	}
+	if code.Denied {
+		return nil, ErrAccessDenied
+	}
+
+	return code, nil
+}
+
+// IssueCallbackNonce issues a nonce that the authorization callback for the
+// device code must present. The nonce is stored server-side until the code
+// expires and can be consumed once, so a leaked callback URL cannot be
+// replayed to complete the flow again or to complete another flow.
+func (f *flowImpl) IssueCallbackNonce(ctx context.Context, deviceCode string) (string, error) {
+	code, err := f.GetDeviceCode(ctx, deviceCode)
+	if err != nil {
+		return "", err // Already wrapped in DeviceFlowError
+	}
+
+	nonce, err := generateSecureCode(consentTicketLength)
+	if err != nil {
+		return "", NewDeviceFlowError(ErrorCodeServerError, "Failed to generate callback nonce")
+	}
+
+	if err := f.store.SaveCallbackNonce(ctx, nonce, code.DeviceCode, time.Until(code.ExpiresAt)); err != nil {
+		return "", NewDeviceFlowError(ErrorCodeServerError, "Failed to save callback nonce")
+	}
+
+	return nonce, nil
+}
+
+// ConsumeCallbackNonce consumes a callback nonce, failing unless it was issued
+// for the device code and has not been used
+func (f *flowImpl) ConsumeCallbackNonce(ctx context.Context, deviceCode, nonce string) error {
+	if nonce == "" {
+		return NewDeviceFlowError(ErrorCodeInvalidRequest, "Missing callback nonce")
+	}
+
+	issuedFor, err := f.store.ConsumeCallbackNonce(ctx, nonce)
+	if err != nil {
+		return NewDeviceFlowError(ErrorCodeServerError, "Failed to consume callback nonce")
+	}
+	if issuedFor == "" || issuedFor != deviceCode {
+		return NewDeviceFlowError(ErrorCodeInvalidRequest, "Unknown or already used callback nonce")
+	}
+
+	return nil
+}
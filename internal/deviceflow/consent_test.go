@@ -0,0 +1,102 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestConsentTicket(t *testing.T) {
+	ctx := context.Background()
+	flow := NewFlow(newMockStore(), "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "kiosk", "openid")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	ticket, err := flow.BeginConsent(ctx, code.DeviceCode)
+	if err != nil {
+		t.Fatalf("BeginConsent failed: %v", err)
+	}
+	if ticket == "" || ticket == code.DeviceCode {
+		t.Fatalf("BeginConsent returned ticket %q", ticket)
+	}
+
+	resolved, err := flow.ResolveConsent(ctx, ticket)
+	if err != nil {
+		t.Fatalf("ResolveConsent failed: %v", err)
+	}
+	if resolved.DeviceCode != code.DeviceCode {
+		t.Errorf("resolved device code = %q, want %q", resolved.DeviceCode, code.DeviceCode)
+	}
+
+	// A ticket decides one form post and cannot be replayed
+	if _, err := flow.ResolveConsent(ctx, ticket); err == nil {
+		t.Error("expected error for replayed ticket")
+	}
+
+	if _, err := flow.ResolveConsent(ctx, "unknown"); err == nil {
+		t.Error("expected error for unknown ticket")
+	}
+	if _, err := flow.ResolveConsent(ctx, ""); err == nil {
+		t.Error("expected error for missing ticket")
+	}
+
+	// Denied requests cannot be approved afterwards
+	ticket, err = flow.BeginConsent(ctx, code.DeviceCode)
+	if err != nil {
+		t.Fatalf("BeginConsent failed: %v", err)
+	}
+	if err := flow.DenyAuthorization(ctx, code.DeviceCode); err != nil {
+		t.Fatalf("DenyAuthorization failed: %v", err)
+	}
+	if _, err := flow.ResolveConsent(ctx, ticket); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("ResolveConsent after denial = %v, want %v", err, ErrAccessDenied)
+	}
+}
+
+func TestBeginConsentUnknownCode(t *testing.T) {
+	flow := NewFlow(newMockStore(), "https://example.com")
+	if _, err := flow.BeginConsent(context.Background(), "missing"); err == nil {
+		t.Error("expected error for unknown device code")
+	}
+}
+
+func TestCallbackNonce(t *testing.T) {
+	ctx := context.Background()
+	flow := NewFlow(newMockStore(), "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "kiosk", "openid")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	other, err := flow.RequestDeviceCode(ctx, "kiosk", "openid")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	nonce, err := flow.IssueCallbackNonce(ctx, code.DeviceCode)
+	if err != nil {
+		t.Fatalf("IssueCallbackNonce failed: %v", err)
+	}
+
+	if err := flow.ConsumeCallbackNonce(ctx, code.DeviceCode, ""); err == nil {
+		t.Error("expected error for missing nonce")
+	}
+	// A nonce issued for one device code cannot complete another
+	if err := flow.ConsumeCallbackNonce(ctx, other.DeviceCode, nonce); err == nil {
+		t.Error("expected error for nonce of another device code")
+	}
+
+	second, err := flow.IssueCallbackNonce(ctx, code.DeviceCode)
+	if err != nil {
+		t.Fatalf("IssueCallbackNonce failed: %v", err)
+	}
+	if err := flow.ConsumeCallbackNonce(ctx, code.DeviceCode, second); err != nil {
+		t.Fatalf("ConsumeCallbackNonce failed: %v", err)
+	}
+	if err := flow.ConsumeCallbackNonce(ctx, code.DeviceCode, second); err == nil {
+		t.Error("expected error for replayed nonce")
+	}
+}
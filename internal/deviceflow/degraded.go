@@ -0,0 +1,306 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// Degraded mode defaults
+const (
+	DefaultDegradedCacheTTL    = 30 * time.Second
+	DefaultDegradedErrorBudget = 5
+	DefaultDegradedWindow      = 10 * time.Second
+	DefaultDegradedCooldown    = 5 * time.Second
+)
+
+// ErrStoreUnavailable wraps the backend errors of a DegradedStore so that the
+// flow can report them as retriable
+var ErrStoreUnavailable = errors.New("device flow store unavailable")
+
+// DegradedConfig configures a DegradedStore. Zero values use the defaults.
+type DegradedConfig struct {
+	CacheTTL    time.Duration // How long poll state read from the backend may answer polls
+	ErrorBudget int           // Backend failures tolerated per Window before degrading
+	Window      time.Duration // Window over which failures count against the budget
+	Cooldown    time.Duration // How long the backend is skipped before it is probed again
+}
+
+// DegradedStore keeps polling working while its backend is failing. Poll
+// state read from the backend is cached in memory for a short time; when the
+// backend fails, polls are answered from the cache with their interval
+// enforced in memory. Once failures exhaust the error budget the backend is
+// skipped for a cooldown so that polls do not each wait for a timeout.
+// Operations outside the polling path fail with ErrStoreUnavailable, so no
+// new device codes are issued while degraded.
+type DegradedStore struct {
+	Store
+
+	cacheTTL time.Duration
+	budget   int
+	window   time.Duration
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu            sync.Mutex
+	states        map[string]*cachedPollState
+	failures      []time.Time
+	degradedUntil time.Time
+}
+
+// cachedPollState is poll state last read from the backend
+type cachedPollState struct {
+	code      DeviceCode
+	token     *TokenResponse
+	expiresAt time.Time
+	lastPoll  time.Time // Polls answered from the cache, for interval enforcement
+}
+
+// NewDegradedStore wraps a store with degraded mode
+func NewDegradedStore(store Store, cfg DegradedConfig) *DegradedStore {
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultDegradedCacheTTL
+	}
+	if cfg.ErrorBudget <= 0 {
+		cfg.ErrorBudget = DefaultDegradedErrorBudget
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultDegradedWindow
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = DefaultDegradedCooldown
+	}
+	return &DegradedStore{
+		Store:    store,
+		cacheTTL: cfg.CacheTTL,
+		budget:   cfg.ErrorBudget,
+		window:   cfg.Window,
+		cooldown: cfg.Cooldown,
+		now:      time.Now,
+		states:   make(map[string]*cachedPollState),
+	}
+}
+
+// Degraded reports whether the error budget is exhausted and the backend is
+// being skipped
+func (s *DegradedStore) Degraded() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now().Before(s.degradedUntil)
+}
+
+// GetPollState implements Store, answering from the cache when the backend fails
+func (s *DegradedStore) GetPollState(ctx context.Context, deviceCode string) (*DeviceCode, *TokenResponse, error) {
+	if !s.Degraded() {
+		code, token, err := s.Store.GetPollState(ctx, deviceCode)
+		if err == nil {
+			s.cache(deviceCode, code, token)
+			return code, token, nil
+		}
+		s.fail()
+		if code, token, ok := s.cached(deviceCode); ok {
+			degradedPolls.Inc()
+			return code, token, nil
+		}
+		return nil, nil, fmt.Errorf("%w: %w", ErrStoreUnavailable, err)
+	}
+
+	if code, token, ok := s.cached(deviceCode); ok {
+		degradedPolls.Inc()
+		return code, token, nil
+	}
+	return nil, nil, ErrStoreUnavailable
+}
+
+// RecordPoll implements Store, enforcing the polling interval in memory for
+// codes answered from the cache. The rate limit window needs shared counters
+// and is not applied while degraded.
+func (s *DegradedStore) RecordPoll(ctx context.Context, code *DeviceCode, limit PollLimit) (bool, error) {
+	if !s.Degraded() {
+		allowed, err := s.Store.RecordPoll(ctx, code, limit)
+		if err == nil {
+			return allowed, nil
+		}
+		s.fail()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, ok := s.states[code.DeviceCode]
+	if !ok {
+		return false, ErrStoreUnavailable
+	}
+	now := s.now()
+	if now.Sub(state.lastPoll) < limit.Interval {
+		return false, nil
+	}
+	state.lastPoll = now
+	return true, nil
+}
+
+// SaveDeviceCode implements Store, failing fast while degraded
+func (s *DegradedStore) SaveDeviceCode(ctx context.Context, code *DeviceCode) error {
+	if s.Degraded() {
+		return ErrStoreUnavailable
+	}
+	if err := s.Store.SaveDeviceCode(ctx, code); err != nil {
+		s.fail()
+		return fmt.Errorf("%w: %w", ErrStoreUnavailable, err)
+	}
+	return nil
+}
+
+// SavePollInterval implements Store, failing fast while degraded
+func (s *DegradedStore) SavePollInterval(ctx context.Context, code *DeviceCode) error {
+	if s.Degraded() {
+		return ErrStoreUnavailable
+	}
+	if err := s.Store.SavePollInterval(ctx, code); err != nil {
+		s.fail()
+		return fmt.Errorf("%w: %w", ErrStoreUnavailable, err)
+	}
+	return nil
+}
+
+// EndAuthorization implements Store, failing fast while degraded. Refusals
+// to end a flow that already ended do not count as backend failures.
+func (s *DegradedStore) EndAuthorization(ctx context.Context, deviceCode string, failure *DeviceFlowError) (*DeviceCode, error) {
+	if s.Degraded() {
+		return nil, ErrStoreUnavailable
+	}
+	code, err := s.Store.EndAuthorization(ctx, deviceCode, failure)
+	if errors.Is(err, ErrAlreadyAuthorized) || errors.Is(err, ErrAuthorizationEnded) {
+		return nil, err
+	}
+	if err != nil {
+		s.fail()
+		return nil, fmt.Errorf("%w: %w", ErrStoreUnavailable, err)
+	}
+	return code, nil
+}
+
+// Watch implements Watcher when the wrapped store does
+func (s *DegradedStore) Watch(ctx context.Context, deviceCode string) (<-chan struct{}, error) {
+	if watcher, ok := s.Store.(Watcher); ok {
+		return watcher.Watch(ctx, deviceCode)
+	}
+	return nil, errWatchUnsupported
+}
+
+// Transact implements Transactor, failing fast while degraded. Backends
+// without transactions apply each write as it is queued.
+func (s *DegradedStore) Transact(ctx context.Context, fn func(tx Tx) error) error {
+	if s.Degraded() {
+		return ErrStoreUnavailable
+	}
+	if err := transact(ctx, s.Store, fn); err != nil {
+		s.fail()
+		return fmt.Errorf("%w: %w", ErrStoreUnavailable, err)
+	}
+	return nil
+}
+
+// CountPendingDeviceCodes implements Store, failing fast while degraded
+func (s *DegradedStore) CountPendingDeviceCodes(ctx context.Context) (int, error) {
+	if s.Degraded() {
+		return 0, ErrStoreUnavailable
+	}
+	count, err := s.Store.CountPendingDeviceCodes(ctx)
+	if err != nil {
+		s.fail()
+		return 0, fmt.Errorf("%w: %w", ErrStoreUnavailable, err)
+	}
+	return count, nil
+}
+
+// CheckHealth implements Store. A failing backend does not fail the check
+// while degraded mode keeps polls working; CheckBackend reports it.
+func (s *DegradedStore) CheckHealth(ctx context.Context) error {
+	return nil
+}
+
+// CheckBackend verifies the wrapped store is healthy
+func (s *DegradedStore) CheckBackend(ctx context.Context) error {
+	return s.Store.CheckHealth(ctx)
+}
+
+// cache remembers poll state read from the backend, dropping expired entries
+func (s *DegradedStore) cache(deviceCode string, code *DeviceCode, token *TokenResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, state := range s.states {
+		if !now.Before(state.expiresAt) {
+			delete(s.states, key)
+		}
+	}
+	if code == nil {
+		delete(s.states, deviceCode)
+		return
+	}
+
+	state := &cachedPollState{code: *code, expiresAt: now.Add(s.cacheTTL), lastPoll: now}
+	if token != nil {
+		copied := *token
+		state.token = &copied
+	}
+	s.states[deviceCode] = state
+}
+
+// cached returns copies of the cached poll state of a device code
+func (s *DegradedStore) cached(deviceCode string) (*DeviceCode, *TokenResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[deviceCode]
+	if !ok || !s.now().Before(state.expiresAt) {
+		return nil, nil, false
+	}
+	code := state.code
+	var token *TokenResponse
+	if state.token != nil {
+		copied := *state.token
+		token = &copied
+	}
+	return &code, token, true
+}
+
+// fail counts a backend failure against the error budget, degrading once it
+// is exhausted
+func (s *DegradedStore) fail() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	recent := s.failures[:0]
+	for _, at := range s.failures {
+		if now.Sub(at) < s.window {
+			recent = append(recent, at)
+		}
+	}
+	s.failures = append(recent, now)
+	if len(s.failures) >= s.budget {
+		s.degradedUntil = now.Add(s.cooldown)
+		s.failures = s.failures[:0]
+		degradedPeriods.Inc()
+	}
+}
+
+var (
+	// degradedPolls counts polls answered from the degraded mode cache
+	degradedPolls = metrics.Default.NewCounter(
+		"device_proxy_degraded_polls_total",
+		"Token polls answered from memory while the store was failing.",
+	)
+
+	// degradedPeriods counts how often the error budget was exhausted
+	degradedPeriods = metrics.Default.NewCounter(
+		"device_proxy_degraded_periods_total",
+		"Times the store error budget was exhausted and the backend skipped for a cooldown.",
+	)
+)
@@ -0,0 +1,105 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDegradedStore(t *testing.T) {
+	ctx := context.Background()
+	backend := newMockStore()
+	store := NewDegradedStore(backend, DegradedConfig{ErrorBudget: 2, Cooldown: time.Minute})
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	flow := NewFlow(store, "https://example.com", WithPollInterval(5*time.Second))
+
+	code, err := flow.RequestDeviceCode(ctx, "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	unknown, err := flow.RequestDeviceCode(ctx, "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	// A healthy poll caches the code's state. The mock store limits polls by
+	// wall clock time, so this one may be told to slow down.
+	now = now.Add(5 * time.Second)
+	if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrPendingAuthorization) && !errors.Is(err, ErrSlowDown) {
+		t.Fatalf("healthy poll = %v, want authorization_pending or slow_down", err)
+	}
+
+	backend.healthy = false
+
+	// Cached codes keep polling with their interval enforced in memory
+	now = now.Add(5 * time.Second)
+	if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrPendingAuthorization) {
+		t.Errorf("degraded poll = %v, want authorization_pending", err)
+	}
+	now = now.Add(time.Second)
+	if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrSlowDown) {
+		t.Errorf("early degraded poll = %v, want slow_down", err)
+	}
+
+	// Codes never polled while healthy cannot be answered
+	if _, err := flow.CheckDeviceCode(ctx, unknown.DeviceCode); err == nil || errors.Is(err, ErrPendingAuthorization) {
+		t.Errorf("uncached degraded poll = %v, want a server error", err)
+	}
+
+	// New codes are refused with a retriable error
+	if _, err := flow.RequestDeviceCode(ctx, "tv", ""); !errors.Is(err, ErrStoreDegraded) {
+		t.Errorf("degraded RequestDeviceCode = %v, want ErrStoreDegraded", err)
+	}
+	if !store.Degraded() {
+		t.Error("store not degraded after exhausting the error budget")
+	}
+	if err := flow.CheckHealth(ctx); err != nil {
+		t.Errorf("CheckHealth while degraded = %v, want nil", err)
+	}
+	if err := store.CheckBackend(ctx); err == nil {
+		t.Error("CheckBackend = nil, want the backend failure")
+	}
+
+	// The cache expires
+	now = now.Add(DefaultDegradedCacheTTL)
+	if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); err == nil || errors.Is(err, ErrPendingAuthorization) {
+		t.Errorf("poll after cache expiry = %v, want a server error", err)
+	}
+
+	// The backend is probed again after the cooldown
+	backend.healthy = true
+	now = now.Add(time.Minute)
+	if store.Degraded() {
+		t.Error("store still degraded after the cooldown")
+	}
+	if _, err := flow.RequestDeviceCode(ctx, "tv", ""); err != nil {
+		t.Errorf("RequestDeviceCode after recovery = %v", err)
+	}
+}
+
+func TestDegradedStoreWatch(t *testing.T) {
+	ctx := context.Background()
+	backend := newWatchingStore()
+	var store Store = NewDegradedStore(backend, DegradedConfig{})
+
+	watcher, ok := store.(Watcher)
+	if !ok {
+		t.Fatal("DegradedStore does not implement Watcher")
+	}
+	changed, err := watcher.Watch(ctx, "dc")
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	backend.notify("dc")
+	select {
+	case <-changed:
+	default:
+		t.Error("change to the wrapped store was not announced")
+	}
+
+	if _, err := NewDegradedStore(newMockStore(), DegradedConfig{}).Watch(ctx, "dc"); err == nil {
+		t.Error("Watch succeeded on a store that cannot watch")
+	}
+}
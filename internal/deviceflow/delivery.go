@@ -0,0 +1,56 @@
+package deviceflow
+
+import "fmt"
+
+// Token response members that may be withheld from devices
+const (
+	MemberRefreshToken = "refresh_token"
+	MemberIDToken      = "id_token"
+)
+
+// TokenWithholding returns the token response members, MemberRefreshToken or
+// MemberIDToken, that are withheld from the devices of a client. Withheld
+// members stay in the stored token response for use by the proxy.
+type TokenWithholding func(clientID string) []string
+
+// WithTokenWithholding sets which token response members each client's
+// devices are denied, for deployments that prohibit long-lived credentials on
+// devices. Members must pass ValidateWithheldMembers.
+func WithTokenWithholding(withholding TokenWithholding) Option {
+	return func(f *flowImpl) {
+		f.withholding = withholding
+	}
+}
+
+// ValidateWithheldMembers checks a list of token response members to withhold
+func ValidateWithheldMembers(members []string) error {
+	for _, member := range members {
+		if member != MemberRefreshToken && member != MemberIDToken {
+			return fmt.Errorf("token member %q cannot be withheld, only %s and %s", member, MemberRefreshToken, MemberIDToken)
+		}
+	}
+	return nil
+}
+
+// deliver returns the token response as the device of a code may receive it,
+// leaving the stored response untouched
+func (f *flowImpl) deliver(code *DeviceCode, token *TokenResponse) *TokenResponse {
+	if token == nil || f.withholding == nil {
+		return token
+	}
+	members := f.withholding(code.ClientID)
+	if len(members) == 0 {
+		return token
+	}
+
+	delivered := *token
+	for _, member := range members {
+		switch member {
+		case MemberRefreshToken:
+			delivered.RefreshToken = ""
+		case MemberIDToken:
+			delivered.IDToken = ""
+		}
+	}
+	return &delivered
+}
@@ -0,0 +1,63 @@
+package deviceflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTokenWithholding(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com",
+		WithTokenWithholding(func(clientID string) []string {
+			if clientID == "kiosk" {
+				return []string{MemberRefreshToken}
+			}
+			return nil
+		}),
+	)
+
+	issue := func(clientID string) *DeviceCode {
+		t.Helper()
+		code, err := flow.RequestDeviceCode(ctx, clientID, "openid")
+		if err != nil {
+			t.Fatalf("RequestDeviceCode failed: %v", err)
+		}
+		if err := store.SaveTokenResponse(ctx, code.DeviceCode, &TokenResponse{
+			AccessToken:  "at",
+			TokenType:    "Bearer",
+			RefreshToken: "rt",
+			IDToken:      "id",
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	kiosk := issue("kiosk")
+	token, err := flow.CheckDeviceCode(ctx, kiosk.DeviceCode)
+	if err != nil {
+		t.Fatalf("CheckDeviceCode failed: %v", err)
+	}
+	if token.RefreshToken != "" || token.IDToken != "id" || token.AccessToken != "at" {
+		t.Errorf("kiosk token = %+v, want refresh token withheld", token)
+	}
+	stored, _ := store.GetTokenResponse(ctx, kiosk.DeviceCode)
+	if stored.RefreshToken != "rt" {
+		t.Errorf("stored refresh token = %q, want it kept for the proxy", stored.RefreshToken)
+	}
+
+	tv := issue("tv")
+	if token, err := flow.CheckDeviceCode(ctx, tv.DeviceCode); err != nil || token.RefreshToken != "rt" {
+		t.Errorf("tv token = %+v, %v; want refresh token delivered", token, err)
+	}
+}
+
+func TestValidateWithheldMembers(t *testing.T) {
+	if err := ValidateWithheldMembers([]string{MemberRefreshToken, MemberIDToken}); err != nil {
+		t.Errorf("valid members rejected: %v", err)
+	}
+	if err := ValidateWithheldMembers([]string{"access_token"}); err == nil {
+		t.Error("access_token accepted as a withheld member")
+	}
+}
@@ -0,0 +1,382 @@
+// Package deviceflow implements writing to two stores during a backend migration
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// DualWriteStore writes to a primary and a secondary store so that the proxy
+// can move between backends without dropping flows in progress. The primary
+// is authoritative: its errors fail the operation, while secondary writes are
+// best effort and only counted and logged when they fail. Reads go to the
+// primary and fall back to the secondary for records the primary lacks or
+// cannot read, such as codes issued before dual writes began.
+//
+// A cutover writes to both stores with the old one as primary until every
+// code in flight has been written to the new one, swaps them, then drops the
+// old store. Rate limit state recorded by RecordPoll, claims and store
+// maintenance stay with the primary.
+type DualWriteStore struct {
+	Store // Primary
+
+	secondary Store
+}
+
+// NewDualWriteStore wraps a primary store, mirroring its writes to a secondary
+func NewDualWriteStore(primary, secondary Store) *DualWriteStore {
+	return &DualWriteStore{Store: primary, secondary: secondary}
+}
+
+// mirror applies a write to the secondary store, counting failures
+func (s *DualWriteStore) mirror(name string, op func(store Store) error) {
+	if err := op(s.secondary); err != nil {
+		dualWriteFailures.Inc(name)
+		log.Printf("Warning: secondary store %s failed: %v", name, err)
+	}
+}
+
+// fallback reports whether a read the primary failed or found nothing for
+// should go to the secondary, counting those that do
+func (s *DualWriteStore) fallback(name string, missing bool, err error) bool {
+	if err == nil && !missing {
+		return false
+	}
+	dualWriteFallbacks.Inc(name)
+	return true
+}
+
+// SaveDeviceCode implements Store
+func (s *DualWriteStore) SaveDeviceCode(ctx context.Context, code *DeviceCode) error {
+	if err := s.Store.SaveDeviceCode(ctx, code); err != nil {
+		return err
+	}
+	s.mirror("SaveDeviceCode", func(store Store) error { return store.SaveDeviceCode(ctx, code) })
+	return nil
+}
+
+// ReplaceUserCode implements Store
+func (s *DualWriteStore) ReplaceUserCode(ctx context.Context, code *DeviceCode, previousUserCode string) error {
+	if err := s.Store.ReplaceUserCode(ctx, code, previousUserCode); err != nil {
+		return err
+	}
+	s.mirror("ReplaceUserCode", func(store Store) error { return store.ReplaceUserCode(ctx, code, previousUserCode) })
+	return nil
+}
+
+// SaveTokenResponse implements Store. A code only the secondary holds has its
+// token saved there, where reads fall back to find it.
+func (s *DualWriteStore) SaveTokenResponse(ctx context.Context, deviceCode string, token *TokenResponse) error {
+	err := s.Store.SaveTokenResponse(ctx, deviceCode, token)
+	if errors.Is(err, ErrInvalidDeviceCode) {
+		return s.secondary.SaveTokenResponse(ctx, deviceCode, token)
+	}
+	if err != nil {
+		return err
+	}
+	s.mirror("SaveTokenResponse", func(store Store) error {
+		err := store.SaveTokenResponse(ctx, deviceCode, token)
+		if errors.Is(err, ErrAlreadyAuthorized) || errors.Is(err, ErrInvalidDeviceCode) {
+			return nil // Saved before, or the code never reached the secondary
+		}
+		return err
+	})
+	return nil
+}
+
+// DeleteDeviceCode implements Store
+func (s *DualWriteStore) DeleteDeviceCode(ctx context.Context, deviceCode string) error {
+	if err := s.Store.DeleteDeviceCode(ctx, deviceCode); err != nil {
+		return err
+	}
+	s.mirror("DeleteDeviceCode", func(store Store) error { return store.DeleteDeviceCode(ctx, deviceCode) })
+	return nil
+}
+
+// BatchDeleteDeviceCodes implements Store
+func (s *DualWriteStore) BatchDeleteDeviceCodes(ctx context.Context, deviceCodes []string) error {
+	if err := s.Store.BatchDeleteDeviceCodes(ctx, deviceCodes); err != nil {
+		return err
+	}
+	s.mirror("BatchDeleteDeviceCodes", func(store Store) error { return store.BatchDeleteDeviceCodes(ctx, deviceCodes) })
+	return nil
+}
+
+// SavePollInterval implements Store
+func (s *DualWriteStore) SavePollInterval(ctx context.Context, code *DeviceCode) error {
+	if err := s.Store.SavePollInterval(ctx, code); err != nil {
+		return err
+	}
+	s.mirror("SavePollInterval", func(store Store) error { return store.SavePollInterval(ctx, code) })
+	return nil
+}
+
+// EndAuthorization implements Store. A code only the secondary holds is
+// ended there, where reads fall back to find it.
+func (s *DualWriteStore) EndAuthorization(ctx context.Context, deviceCode string, failure *DeviceFlowError) (*DeviceCode, error) {
+	code, err := s.Store.EndAuthorization(ctx, deviceCode, failure)
+	if err != nil {
+		return nil, err
+	}
+	if code == nil {
+		return s.secondary.EndAuthorization(ctx, deviceCode, failure)
+	}
+	s.mirror("EndAuthorization", func(store Store) error {
+		_, err := store.EndAuthorization(ctx, deviceCode, failure)
+		if errors.Is(err, ErrAlreadyAuthorized) || errors.Is(err, ErrAuthorizationEnded) {
+			return nil // Ended or authorized there before
+		}
+		return err
+	})
+	return code, nil
+}
+
+// IncrementPollCount implements Store, mirroring verification attempts so the
+// brute force limit holds across a cutover
+func (s *DualWriteStore) IncrementPollCount(ctx context.Context, deviceCode string) error {
+	if err := s.Store.IncrementPollCount(ctx, deviceCode); err != nil {
+		return err
+	}
+	s.mirror("IncrementPollCount", func(store Store) error { return store.IncrementPollCount(ctx, deviceCode) })
+	return nil
+}
+
+// SaveSubmission implements Store
+func (s *DualWriteStore) SaveSubmission(ctx context.Context, nonce string, result *SubmissionResult, ttl time.Duration) error {
+	if err := s.Store.SaveSubmission(ctx, nonce, result, ttl); err != nil {
+		return err
+	}
+	s.mirror("SaveSubmission", func(store Store) error { return store.SaveSubmission(ctx, nonce, result, ttl) })
+	return nil
+}
+
+// SaveBatch implements Store
+func (s *DualWriteStore) SaveBatch(ctx context.Context, batch *Batch) error {
+	if err := s.Store.SaveBatch(ctx, batch); err != nil {
+		return err
+	}
+	s.mirror("SaveBatch", func(store Store) error { return store.SaveBatch(ctx, batch) })
+	return nil
+}
+
+// SaveConsentTicket implements Store
+func (s *DualWriteStore) SaveConsentTicket(ctx context.Context, ticket, deviceCode string, ttl time.Duration) error {
+	if err := s.Store.SaveConsentTicket(ctx, ticket, deviceCode, ttl); err != nil {
+		return err
+	}
+	s.mirror("SaveConsentTicket", func(store Store) error { return store.SaveConsentTicket(ctx, ticket, deviceCode, ttl) })
+	return nil
+}
+
+// SaveCallbackNonce implements Store
+func (s *DualWriteStore) SaveCallbackNonce(ctx context.Context, nonce, deviceCode string, ttl time.Duration) error {
+	if err := s.Store.SaveCallbackNonce(ctx, nonce, deviceCode, ttl); err != nil {
+		return err
+	}
+	s.mirror("SaveCallbackNonce", func(store Store) error { return store.SaveCallbackNonce(ctx, nonce, deviceCode, ttl) })
+	return nil
+}
+
+// ConsumeCallbackNonce implements Store. The nonce is consumed from both
+// stores, so that a nonce used once cannot be replayed through the fallback.
+func (s *DualWriteStore) ConsumeCallbackNonce(ctx context.Context, nonce string) (string, error) {
+	deviceCode, err := s.Store.ConsumeCallbackNonce(ctx, nonce)
+	if err != nil {
+		return "", err
+	}
+	secondary, secondaryErr := s.secondary.ConsumeCallbackNonce(ctx, nonce)
+	if secondaryErr != nil {
+		dualWriteFailures.Inc("ConsumeCallbackNonce")
+		log.Printf("Warning: secondary store ConsumeCallbackNonce failed: %v", secondaryErr)
+	}
+	if deviceCode == "" && secondary != "" {
+		dualWriteFallbacks.Inc("ConsumeCallbackNonce")
+		return secondary, nil
+	}
+	return deviceCode, nil
+}
+
+// ConsumeConsentTicket implements Store. The ticket is consumed from both
+// stores, so that a ticket used once cannot be replayed through the fallback.
+func (s *DualWriteStore) ConsumeConsentTicket(ctx context.Context, ticket string) (string, error) {
+	deviceCode, err := s.Store.ConsumeConsentTicket(ctx, ticket)
+	if err != nil {
+		return "", err
+	}
+	secondary, secondaryErr := s.secondary.ConsumeConsentTicket(ctx, ticket)
+	if secondaryErr != nil {
+		dualWriteFailures.Inc("ConsumeConsentTicket")
+		log.Printf("Warning: secondary store ConsumeConsentTicket failed: %v", secondaryErr)
+	}
+	if deviceCode == "" && secondary != "" {
+		dualWriteFallbacks.Inc("ConsumeConsentTicket")
+		return secondary, nil
+	}
+	return deviceCode, nil
+}
+
+// Transact implements Transactor, replaying the transaction on the secondary
+// once the primary committed it
+func (s *DualWriteStore) Transact(ctx context.Context, fn func(tx Tx) error) error {
+	if err := transact(ctx, s.Store, fn); err != nil {
+		return err
+	}
+	s.mirror("Transact", func(store Store) error { return transact(ctx, store, fn) })
+	return nil
+}
+
+// GetDeviceCode implements Store
+func (s *DualWriteStore) GetDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error) {
+	code, err := s.Store.GetDeviceCode(ctx, deviceCode)
+	if s.fallback("GetDeviceCode", code == nil, err) {
+		if fallback, fallbackErr := s.secondary.GetDeviceCode(ctx, deviceCode); fallbackErr == nil && fallback != nil {
+			return fallback, nil
+		}
+	}
+	return code, err
+}
+
+// GetDeviceCodeByUserCode implements Store
+func (s *DualWriteStore) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*DeviceCode, error) {
+	code, err := s.Store.GetDeviceCodeByUserCode(ctx, userCode)
+	if s.fallback("GetDeviceCodeByUserCode", code == nil || code.Tombstone != "", err) {
+		if fallback, fallbackErr := s.secondary.GetDeviceCodeByUserCode(ctx, userCode); fallbackErr == nil && fallback != nil && fallback.Tombstone == "" {
+			return fallback, nil
+		}
+	}
+	return code, err
+}
+
+// GetTokenResponse implements Store
+func (s *DualWriteStore) GetTokenResponse(ctx context.Context, deviceCode string) (*TokenResponse, error) {
+	token, err := s.Store.GetTokenResponse(ctx, deviceCode)
+	if s.fallback("GetTokenResponse", token == nil, err) {
+		if fallback, fallbackErr := s.secondary.GetTokenResponse(ctx, deviceCode); fallbackErr == nil && fallback != nil {
+			return fallback, nil
+		}
+	}
+	return token, err
+}
+
+// GetPollState implements Store
+func (s *DualWriteStore) GetPollState(ctx context.Context, deviceCode string) (*DeviceCode, *TokenResponse, error) {
+	code, token, err := s.Store.GetPollState(ctx, deviceCode)
+	if s.fallback("GetPollState", code == nil || code.Tombstone != "", err) {
+		fallback, fallbackToken, fallbackErr := s.secondary.GetPollState(ctx, deviceCode)
+		if fallbackErr == nil && fallback != nil && fallback.Tombstone == "" {
+			return fallback, fallbackToken, nil
+		}
+	}
+	return code, token, err
+}
+
+// BatchGetDeviceCodes implements Store, reading codes the primary lacks from
+// the secondary
+func (s *DualWriteStore) BatchGetDeviceCodes(ctx context.Context, deviceCodes []string) ([]*DeviceCode, error) {
+	codes, err := s.Store.BatchGetDeviceCodes(ctx, deviceCodes)
+	if err != nil {
+		dualWriteFallbacks.Inc("BatchGetDeviceCodes")
+		if fallback, fallbackErr := s.secondary.BatchGetDeviceCodes(ctx, deviceCodes); fallbackErr == nil {
+			return fallback, nil
+		}
+		return nil, err
+	}
+
+	var missing []string
+	for i, code := range codes {
+		if code == nil {
+			missing = append(missing, deviceCodes[i])
+		}
+	}
+	if len(missing) == 0 {
+		return codes, nil
+	}
+	dualWriteFallbacks.Inc("BatchGetDeviceCodes")
+	fallback, err := s.secondary.BatchGetDeviceCodes(ctx, missing)
+	if err != nil {
+		return codes, nil // The primary's answer stands
+	}
+	for i, j := 0, 0; i < len(codes) && j < len(fallback); i++ {
+		if codes[i] == nil {
+			codes[i] = fallback[j]
+			j++
+		}
+	}
+	return codes, nil
+}
+
+// GetBatch implements Store
+func (s *DualWriteStore) GetBatch(ctx context.Context, batchID string) (*Batch, error) {
+	batch, err := s.Store.GetBatch(ctx, batchID)
+	if s.fallback("GetBatch", batch == nil, err) {
+		if fallback, fallbackErr := s.secondary.GetBatch(ctx, batchID); fallbackErr == nil && fallback != nil {
+			return fallback, nil
+		}
+	}
+	return batch, err
+}
+
+// ListDeviceCodesByClient implements Store, listing the codes of either store
+// so that revoking a client's codes reaches those issued before the cutover
+func (s *DualWriteStore) ListDeviceCodesByClient(ctx context.Context, clientID string) ([]string, error) {
+	deviceCodes, err := s.Store.ListDeviceCodesByClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := s.secondary.ListDeviceCodesByClient(ctx, clientID)
+	if err != nil {
+		dualWriteFailures.Inc("ListDeviceCodesByClient")
+		log.Printf("Warning: secondary store ListDeviceCodesByClient failed: %v", err)
+		return deviceCodes, nil
+	}
+
+	seen := make(map[string]bool, len(deviceCodes))
+	for _, deviceCode := range deviceCodes {
+		seen[deviceCode] = true
+	}
+	for _, deviceCode := range secondary {
+		if !seen[deviceCode] {
+			deviceCodes = append(deviceCodes, deviceCode)
+		}
+	}
+	return deviceCodes, nil
+}
+
+// Cleanup implements Store, sweeping both stores and reporting the primary
+func (s *DualWriteStore) Cleanup(ctx context.Context) (*CleanupResult, error) {
+	result, err := s.Store.Cleanup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mirror("Cleanup", func(store Store) error {
+		_, err := store.Cleanup(ctx)
+		return err
+	})
+	return result, nil
+}
+
+// Watch implements Watcher when the primary does
+func (s *DualWriteStore) Watch(ctx context.Context, deviceCode string) (<-chan struct{}, error) {
+	if watcher, ok := s.Store.(Watcher); ok {
+		return watcher.Watch(ctx, deviceCode)
+	}
+	return nil, errWatchUnsupported
+}
+
+// Dual write metrics
+var (
+	dualWriteFailures = metrics.Default.NewCounter(
+		"device_proxy_dual_write_failures_total",
+		"Writes the secondary store failed to apply during a backend migration, by operation.",
+		"operation",
+	)
+	dualWriteFallbacks = metrics.Default.NewCounter(
+		"device_proxy_dual_write_fallbacks_total",
+		"Reads the primary store could not answer that went to the secondary, by operation.",
+		"operation",
+	)
+)
@@ -0,0 +1,78 @@
+package deviceflow
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDualWriteStore(t *testing.T) {
+	ctx := context.Background()
+	primary, secondary := newMockStore(), newMockStore()
+	store := NewDualWriteStore(primary, secondary)
+	expiresAt := time.Now().Add(10 * time.Minute)
+
+	// Writes reach both stores
+	code := &DeviceCode{DeviceCode: "dev-new", UserCode: "BCDF-GHJK", ClientID: "tv", ExpiresAt: expiresAt}
+	if err := store.SaveDeviceCode(ctx, code); err != nil {
+		t.Fatalf("SaveDeviceCode failed: %v", err)
+	}
+	for name, backend := range map[string]*mockStore{"primary": primary, "secondary": secondary} {
+		if got, _ := backend.GetDeviceCode(ctx, "dev-new"); got == nil {
+			t.Errorf("SaveDeviceCode did not write to the %s store", name)
+		}
+	}
+
+	// Codes written before dual writes began are read from the secondary
+	old := &DeviceCode{DeviceCode: "dev-old", UserCode: "LMNP-QRST", ClientID: "tv", ExpiresAt: expiresAt}
+	if err := secondary.SaveDeviceCode(ctx, old); err != nil {
+		t.Fatalf("SaveDeviceCode failed: %v", err)
+	}
+	if got, err := store.GetDeviceCode(ctx, "dev-old"); err != nil || got == nil {
+		t.Errorf("GetDeviceCode() = %v, %v, want the secondary's code", got, err)
+	}
+	if got, err := store.GetDeviceCodeByUserCode(ctx, "LMNP-QRST"); err != nil || got == nil {
+		t.Errorf("GetDeviceCodeByUserCode() = %v, %v, want the secondary's code", got, err)
+	}
+	codes, err := store.BatchGetDeviceCodes(ctx, []string{"dev-new", "dev-old", "dev-none"})
+	if err != nil || len(codes) != 3 || codes[0] == nil || codes[1] == nil || codes[2] != nil {
+		t.Errorf("BatchGetDeviceCodes() = %v, %v, want both stores' codes in order", codes, err)
+	}
+
+	// Client listings cover both stores
+	listed, err := store.ListDeviceCodesByClient(ctx, "tv")
+	sort.Strings(listed)
+	if diff := cmp.Diff([]string{"dev-new", "dev-old"}, listed); err != nil || diff != "" {
+		t.Errorf("ListDeviceCodesByClient() mismatch (-want +got), err = %v:\n%s", err, diff)
+	}
+
+	// A nonce consumed once cannot be replayed through the secondary
+	if err := store.SaveCallbackNonce(ctx, "nonce", "dev-new", time.Minute); err != nil {
+		t.Fatalf("SaveCallbackNonce failed: %v", err)
+	}
+	if got, err := store.ConsumeCallbackNonce(ctx, "nonce"); err != nil || got != "dev-new" {
+		t.Errorf("ConsumeCallbackNonce() = %q, %v, want dev-new", got, err)
+	}
+	if got, _ := store.ConsumeCallbackNonce(ctx, "nonce"); got != "" {
+		t.Errorf("ConsumeCallbackNonce() replayed = %q, want none", got)
+	}
+
+	// A failing secondary does not fail writes
+	secondary.healthy = false
+	if err := store.SaveDeviceCode(ctx, &DeviceCode{DeviceCode: "dev-2", UserCode: "VWXZ-BCDF", ExpiresAt: expiresAt}); err != nil {
+		t.Errorf("SaveDeviceCode with a failing secondary = %v, want nil", err)
+	}
+	secondary.healthy = true
+
+	// A failing primary fails writes, but reads fall back
+	primary.healthy = false
+	if err := store.SaveDeviceCode(ctx, &DeviceCode{DeviceCode: "dev-3", UserCode: "GHJK-LMNP", ExpiresAt: expiresAt}); err == nil {
+		t.Error("SaveDeviceCode with a failing primary succeeded")
+	}
+	if got, err := store.GetDeviceCode(ctx, "dev-new"); err != nil || got == nil {
+		t.Errorf("GetDeviceCode() with a failing primary = %v, %v, want the secondary's code", got, err)
+	}
+}
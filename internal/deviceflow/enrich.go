@@ -0,0 +1,65 @@
+// Package deviceflow implements the token enrichment hook
+package deviceflow
+
+import (
+	"context"
+	"log"
+)
+
+// TokenEnricher can augment or replace the token response obtained from the
+// authorization server before it is stored for the polling device, for
+// example to wrap the upstream token in a proxy-issued JWT carrying device
+// metadata. A terminal DeviceFlowError, such as access_denied, ends the flow
+// with that error; any other error fails the completion with server_error.
+type TokenEnricher interface {
+	EnrichToken(ctx context.Context, code *DeviceCode, token *TokenResponse) (*TokenResponse, error)
+}
+
+// TokenEnricherFunc adapts a function to the TokenEnricher interface
+type TokenEnricherFunc func(ctx context.Context, code *DeviceCode, token *TokenResponse) (*TokenResponse, error)
+
+// EnrichToken implements TokenEnricher
+func (fn TokenEnricherFunc) EnrichToken(ctx context.Context, code *DeviceCode, token *TokenResponse) (*TokenResponse, error) {
+	return fn(ctx, code, token)
+}
+
+// TokenEnrichers applies several enrichers in order, each receiving the
+// response of the one before it
+type TokenEnrichers []TokenEnricher
+
+// EnrichToken implements TokenEnricher
+func (e TokenEnrichers) EnrichToken(ctx context.Context, code *DeviceCode, token *TokenResponse) (*TokenResponse, error) {
+	for _, enricher := range e {
+		var err error
+		if token, err = enricher.EnrichToken(ctx, code, token); err != nil {
+			return nil, err
+		}
+	}
+	return token, nil
+}
+
+// enrichToken applies the configured enricher, if any
+func (f *flowImpl) enrichToken(ctx context.Context, code *DeviceCode, token *TokenResponse) (*TokenResponse, error) {
+	if f.enricher == nil {
+		return token, nil
+	}
+
+	enriched, err := f.enricher.EnrichToken(ctx, code, token)
+	if err != nil {
+		if dfe, ok := AsDeviceFlowError(err); ok {
+			return nil, dfe
+		}
+		log.Printf("Error: token enrichment failed: %v", err)
+		return nil, NewDeviceFlowError(ErrorCodeServerError, "Failed to prepare token response")
+	}
+	if enriched == nil || enriched.AccessToken == "" {
+		return nil, NewDeviceFlowError(ErrorCodeServerError, "Token enrichment returned no access token")
+	}
+
+	// Keep the authorizing identity unless the enricher chose its own
+	if enriched.Identity == nil {
+		enriched.Identity = token.Identity
+	}
+	enriched.Offline = enriched.Offline || token.Offline
+	return enriched, nil
+}
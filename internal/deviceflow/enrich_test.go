@@ -0,0 +1,118 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenEnricher(t *testing.T) {
+	identity := &Identity{Subject: "user-1"}
+
+	tests := []struct {
+		name       string
+		enrich     TokenEnricherFunc
+		wantToken  string
+		wantErr    string // Error code returned by CompleteAuthorization
+		wantPolled error  // Error the device receives afterwards
+	}{
+		{
+			name: "wraps the upstream token",
+			enrich: func(ctx context.Context, code *DeviceCode, token *TokenResponse) (*TokenResponse, error) {
+				return &TokenResponse{AccessToken: "proxy:" + code.ClientID + ":" + token.AccessToken, TokenType: "Bearer"}, nil
+			},
+			wantToken: "proxy:tv:upstream",
+		},
+		{
+			name: "refusal ends the flow",
+			enrich: func(ctx context.Context, code *DeviceCode, token *TokenResponse) (*TokenResponse, error) {
+				return nil, ErrAccessDenied
+			},
+			wantErr:    ErrorCodeAccessDenied,
+			wantPolled: ErrAccessDenied,
+		},
+		{
+			name: "failure leaves the flow pending",
+			enrich: func(ctx context.Context, code *DeviceCode, token *TokenResponse) (*TokenResponse, error) {
+				return nil, errors.New("signing key unavailable")
+			},
+			wantErr:    ErrorCodeServerError,
+			wantPolled: ErrPendingAuthorization,
+		},
+		{
+			name: "empty result is rejected",
+			enrich: func(ctx context.Context, code *DeviceCode, token *TokenResponse) (*TokenResponse, error) {
+				return &TokenResponse{}, nil
+			},
+			wantErr:    ErrorCodeServerError,
+			wantPolled: ErrPendingAuthorization,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := newMockStore()
+			flow := NewFlow(store, "https://example.com", WithTokenEnricher(tt.enrich))
+
+			code, err := flow.RequestDeviceCode(ctx, "tv", "")
+			if err != nil {
+				t.Fatalf("RequestDeviceCode failed: %v", err)
+			}
+
+			err = flow.CompleteAuthorization(ctx, code.DeviceCode, &TokenResponse{AccessToken: "upstream", Identity: identity})
+			if tt.wantErr != "" {
+				if dfe, ok := AsDeviceFlowError(err); !ok || dfe.Code != tt.wantErr {
+					t.Fatalf("CompleteAuthorization error = %v, want %s", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("CompleteAuthorization failed: %v", err)
+			}
+
+			store.deviceCodes[code.DeviceCode].LastPoll = time.Now().Add(-time.Minute)
+			token, err := flow.CheckDeviceCode(ctx, code.DeviceCode)
+			if tt.wantPolled != nil {
+				if !errors.Is(err, tt.wantPolled) {
+					t.Errorf("CheckDeviceCode error = %v, want %v", err, tt.wantPolled)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckDeviceCode failed: %v", err)
+			}
+			if token.AccessToken != tt.wantToken {
+				t.Errorf("access token = %q, want %q", token.AccessToken, tt.wantToken)
+			}
+			if token.Identity == nil || token.Identity.Subject != "user-1" {
+				t.Errorf("identity = %+v, want the authorizing user kept", token.Identity)
+			}
+		})
+	}
+}
+
+func TestTokenEnrichers(t *testing.T) {
+	var seen []string
+	record := func(name string) TokenEnricherFunc {
+		return func(ctx context.Context, code *DeviceCode, token *TokenResponse) (*TokenResponse, error) {
+			seen = append(seen, name+":"+token.AccessToken)
+			return &TokenResponse{AccessToken: name}, nil
+		}
+	}
+	refuse := TokenEnricherFunc(func(ctx context.Context, code *DeviceCode, token *TokenResponse) (*TokenResponse, error) {
+		return nil, ErrAccessDenied
+	})
+
+	token, err := TokenEnrichers{record("first"), record("second")}.EnrichToken(context.Background(), &DeviceCode{}, &TokenResponse{AccessToken: "upstream"})
+	if err != nil || token.AccessToken != "second" {
+		t.Fatalf("EnrichToken = %+v, %v, want the last enricher's token", token, err)
+	}
+	if len(seen) != 2 || seen[0] != "first:upstream" || seen[1] != "second:first" {
+		t.Errorf("enrichers saw %v, want each to receive the previous response", seen)
+	}
+
+	seen = nil
+	if _, err := (TokenEnrichers{refuse, record("after")}).EnrichToken(context.Background(), &DeviceCode{}, &TokenResponse{AccessToken: "upstream"}); !errors.Is(err, ErrAccessDenied) || len(seen) != 0 {
+		t.Errorf("EnrichToken error = %v after %v, want the refusal without running later enrichers", err, seen)
+	}
+}
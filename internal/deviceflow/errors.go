@@ -4,6 +4,7 @@ package deviceflow
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // Error codes defined by RFC 8628 section 3.5
@@ -15,7 +16,29 @@ const (
 	ErrorCodeInvalidGrant         = "invalid_grant"
 	ErrorCodeInvalidRequest       = "invalid_request"
 	ErrorCodeUnsupportedGrant     = "unsupported_grant_type"
-	ErrorCodeServerError          = "server_error" // For internal server errors
+
+	// Authorization server errors per RFC 6749 sections 4.1.2.1 and 5.2 that
+	// may be relayed to the polling device. In JSON responses server_error is
+	// answered with 500 Internal Server Error and temporarily_unavailable
+	// with 503 Service Unavailable.
+	ErrorCodeInvalidScope           = "invalid_scope"
+	ErrorCodeServerError            = "server_error"
+	ErrorCodeTemporarilyUnavailable = "temporarily_unavailable"
+
+	// ErrorCodeInvalidTarget rejects resource indicators per RFC 8707 section 2
+	ErrorCodeInvalidTarget = "invalid_target"
+
+	// ErrorCodeInvalidClient reports failed client authentication per RFC 6749
+	// section 5.2, answered with 401 Unauthorized
+	ErrorCodeInvalidClient = "invalid_client"
+
+	// ErrorCodeUnauthorizedClient rejects a grant type the client may not use
+	// per RFC 6749 section 5.2
+	ErrorCodeUnauthorizedClient = "unauthorized_client"
+
+	// ErrorCodeInvalidToken rejects a bearer token that is expired, revoked
+	// or malformed per RFC 6750 section 3.1, answered with 401 Unauthorized
+	ErrorCodeInvalidToken = "invalid_token"
 )
 
 // Error descriptions defined by RFC 8628
@@ -32,12 +55,31 @@ const (
 
 	// Section 3.5 error descriptions
 	ErrorDescAuthorizationPending = "The authorization request is still pending"
-	ErrorDescSlowDown             = "Polling interval must be increased by 5 seconds"
+	ErrorDescSlowDown             = "Polling interval must be increased to the interval given"
 	ErrorDescAccessDenied         = "The user denied the authorization request"
 	ErrorDescExpiredToken         = "The device_code has expired"
 	ErrorDescInvalidDeviceCode    = "The device_code is invalid or malformed"
 	ErrorDescServerError          = "An unexpected error occurred"
 
+	// Upstream authorization server error descriptions
+	ErrorDescInvalidScope           = "The requested scope is invalid, unknown, or malformed"
+	ErrorDescTemporarilyUnavailable = "The authorization server is temporarily unavailable"
+	ErrorDescUpstreamError          = "The authorization server rejected the request"
+	ErrorDescCapacityExceeded       = "Too many pending authorization requests, try again later"
+	ErrorDescClientQuotaExceeded    = "Too many pending authorization requests for this client, try again later"
+	ErrorDescStoreUnavailable       = "Device authorization is temporarily unavailable, try again later"
+	ErrorDescAlreadyAuthorized      = "The device_code has already been authorized"
+	ErrorDescAuthorizationEnded     = "The authorization request has already been denied or failed"
+	ErrorDescInvalidTarget          = "The requested resource is invalid, unknown, or malformed"
+	ErrorDescInvalidClient          = "Client authentication failed"
+	ErrorDescRefreshLimitReached    = "The user code cannot be refreshed again; request a new device code"
+
+	// Tombstone error descriptions, for codes that are gone but not unknown
+	ErrorDescCodeConsumed     = "The device_code has already been used"
+	ErrorDescCodeDeleted      = "The device_code is no longer valid"
+	ErrorDescUserCodeConsumed = "The user code has already been used"
+	ErrorDescUserCodeDeleted  = "The user code is no longer valid"
+
 	// Section 6.1 error descriptions
 	ErrorDescInvalidUserCode   = "Invalid user code format"
 	ErrorDescRateLimitExceeded = "Too many verification attempts"
@@ -47,6 +89,9 @@ const (
 type DeviceFlowError struct {
 	Code        string `json:"error"`
 	Description string `json:"error_description,omitempty"`
+
+	// URI identifies a page describing the error per RFC 6749 section 5.2
+	URI string `json:"error_uri,omitempty"`
 }
 
 // Error implements the error interface
@@ -75,6 +120,36 @@ var (
 	ErrAccessDenied         = NewDeviceFlowError(ErrorCodeAccessDenied, ErrorDescAccessDenied)
 	ErrServerError          = NewDeviceFlowError(ErrorCodeServerError, ErrorDescServerError)
 
+	// ErrAlreadyAuthorized rejects a second completion of the same device code,
+	// so only the first of two concurrent approvals stores a token
+	ErrAlreadyAuthorized = NewDeviceFlowError(ErrorCodeInvalidGrant, ErrorDescAlreadyAuthorized)
+
+	// ErrAuthorizationEnded rejects a denial or failure of a device code
+	// whose flow was already denied or failed
+	ErrAuthorizationEnded = NewDeviceFlowError(ErrorCodeInvalidGrant, ErrorDescAuthorizationEnded)
+
+	// ErrCodeConsumed and ErrCodeDeleted answer polls for codes whose token
+	// was already collected or that were revoked, found by their tombstone
+	ErrCodeConsumed = NewDeviceFlowError(ErrorCodeInvalidGrant, ErrorDescCodeConsumed)
+	ErrCodeDeleted  = NewDeviceFlowError(ErrorCodeInvalidGrant, ErrorDescCodeDeleted)
+
+	// ErrCapacityExceeded sheds device authorization requests beyond the outstanding code cap
+	ErrCapacityExceeded = NewDeviceFlowError(ErrorCodeTemporarilyUnavailable, ErrorDescCapacityExceeded)
+
+	// ErrClientQuotaExceeded sheds device authorization requests from a client
+	// holding as many device codes as the per-client quota allows
+	ErrClientQuotaExceeded = NewDeviceFlowError(ErrorCodeTemporarilyUnavailable, ErrorDescClientQuotaExceeded)
+
+	// ErrStoreDegraded rejects new device authorization requests while the
+	// store is failing, so that devices retry instead of giving up
+	ErrStoreDegraded = NewDeviceFlowError(ErrorCodeTemporarilyUnavailable, ErrorDescStoreUnavailable)
+
+	// ErrInvalidTarget rejects resource indicators per RFC 8707 section 2
+	ErrInvalidTarget = NewDeviceFlowError(ErrorCodeInvalidTarget, ErrorDescInvalidTarget)
+
+	// ErrInvalidClient rejects requests from clients that failed to authenticate
+	ErrInvalidClient = NewDeviceFlowError(ErrorCodeInvalidClient, ErrorDescInvalidClient)
+
 	// Request validation errors per RFC 8628 section 3.1
 	ErrMissingClientID = NewDeviceFlowError(ErrorCodeInvalidRequest, ErrorDescMissingClientID)
 	ErrDuplicateParams = NewDeviceFlowError(ErrorCodeInvalidRequest, ErrorDescDuplicateParams)
@@ -90,6 +165,71 @@ var (
 	ErrRateLimitExceeded = NewDeviceFlowError(ErrorCodeSlowDown, ErrorDescRateLimitExceeded)
 )
 
+// PollBackoffError is an authorization_pending or slow_down error carrying
+// the interval the device must now wait between polls, which grows with each
+// slow_down per RFC 8628 section 3.5. It matches the wrapped error, so
+// errors.Is(err, ErrSlowDown) holds for a slow_down backoff.
+type PollBackoffError struct {
+	*DeviceFlowError
+	Interval time.Duration
+}
+
+// Unwrap returns the underlying device flow error
+func (e *PollBackoffError) Unwrap() error {
+	return e.DeviceFlowError
+}
+
+// RetryAfter returns how long a device should wait before polling again after
+// receiving err, if the error says
+func RetryAfter(err error) (time.Duration, bool) {
+	var backoff *PollBackoffError
+	if errors.As(err, &backoff) && backoff.Interval > 0 {
+		return backoff.Interval, true
+	}
+	return 0, false
+}
+
+// MapUpstreamError converts an error code returned by the authorization server,
+// either on the authorization redirect or from the token endpoint, into the
+// DeviceFlowError relayed to the polling device
+func MapUpstreamError(code, description string) *DeviceFlowError {
+	switch code {
+	case ErrorCodeAccessDenied:
+		return ErrAccessDenied
+	case ErrorCodeInvalidScope:
+		return NewDeviceFlowError(ErrorCodeInvalidScope, withDefault(description, ErrorDescInvalidScope))
+	case ErrorCodeInvalidTarget:
+		return NewDeviceFlowError(ErrorCodeInvalidTarget, withDefault(description, ErrorDescInvalidTarget))
+	case ErrorCodeTemporarilyUnavailable:
+		return NewDeviceFlowError(ErrorCodeTemporarilyUnavailable, withDefault(description, ErrorDescTemporarilyUnavailable))
+	case ErrorCodeInvalidGrant:
+		// The authorization code was rejected, not the device code
+		return NewDeviceFlowError(ErrorCodeInvalidGrant, withDefault(description, "The authorization code is invalid or expired"))
+	default:
+		return NewDeviceFlowError(ErrorCodeServerError, ErrorDescUpstreamError)
+	}
+}
+
+// Terminal reports whether the error ends the device flow. The device receives
+// terminal errors instead of authorization_pending per RFC 8628 section 3.5;
+// other upstream errors leave the flow pending so the user can retry.
+func (e *DeviceFlowError) Terminal() bool {
+	switch e.Code {
+	case ErrorCodeAccessDenied, ErrorCodeExpiredToken, ErrorCodeInvalidScope, ErrorCodeInvalidTarget:
+		return true
+	default:
+		return false
+	}
+}
+
+// withDefault returns s, or fallback when s is empty
+func withDefault(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
 // AsDeviceFlowError attempts to convert an error to a DeviceFlowError
 func AsDeviceFlowError(err error) (*DeviceFlowError, bool) {
 	var dfe *DeviceFlowError
@@ -0,0 +1,177 @@
+// Package deviceflow implements lifecycle event tests
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+)
+
+// recordingEmitter captures emitted events for assertions
+type recordingEmitter struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (r *recordingEmitter) Emit(ctx context.Context, event events.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *recordingEmitter) types() []events.Type {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []events.Type
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestFlowEmitsLifecycleEvents(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	store.mockUserCode = "BDFG-HJKL"
+	emitter := &recordingEmitter{}
+	flow := NewFlow(store, "https://example.com", WithEventEmitter(emitter))
+
+	code, err := flow.RequestDeviceCode(ctx, "test-client", "openid")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if _, err := flow.VerifyUserCode(ctx, code.UserCode); err != nil {
+		t.Fatalf("VerifyUserCode failed: %v", err)
+	}
+	if err := flow.CompleteAuthorization(ctx, code.DeviceCode, &TokenResponse{AccessToken: "token"}); err != nil {
+		t.Fatalf("CompleteAuthorization failed: %v", err)
+	}
+
+	want := []events.Type{
+		events.TypeDeviceCodeCreated,
+		events.TypeUserVerified,
+		events.TypeAuthorizationCompleted,
+	}
+	got := emitter.types()
+	if len(got) != len(want) {
+		t.Fatalf("events = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	for _, e := range emitter.events {
+		if e.ClientID != "test-client" {
+			t.Errorf("%s event client_id = %q, want test-client", e.Type, e.ClientID)
+		}
+		for _, v := range e.Data {
+			if v == code.DeviceCode {
+				t.Errorf("%s event leaks device code", e.Type)
+			}
+		}
+	}
+}
+
+func TestDenyAuthorization(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	emitter := &recordingEmitter{}
+	flow := NewFlow(store, "https://example.com", WithEventEmitter(emitter))
+
+	code := &DeviceCode{
+		DeviceCode: "denied",
+		ClientID:   "test-client",
+		ExpiresAt:  time.Now().Add(time.Hour),
+		LastPoll:   time.Now().Add(-time.Minute),
+	}
+	if err := store.SaveDeviceCode(ctx, code); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	if err := flow.DenyAuthorization(ctx, "denied"); err != nil {
+		t.Fatalf("DenyAuthorization failed: %v", err)
+	}
+
+	if _, err := flow.CheckDeviceCode(ctx, "denied"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("CheckDeviceCode error = %v, want %v", err, ErrAccessDenied)
+	}
+
+	if got := emitter.types(); len(got) != 1 || got[0] != events.TypeAuthorizationDenied {
+		t.Errorf("events = %v, want [%s]", got, events.TypeAuthorizationDenied)
+	}
+}
+
+func TestDenyAuthorizationKeepsOutcome(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	emitter := &recordingEmitter{}
+	flow := NewFlow(store, "https://example.com", WithEventEmitter(emitter))
+
+	for _, deviceCode := range []string{"approved", "failed"} {
+		code := &DeviceCode{DeviceCode: deviceCode, ClientID: "test-client", ExpiresAt: time.Now().Add(time.Hour)}
+		if err := store.SaveDeviceCode(ctx, code); err != nil {
+			t.Fatalf("setup failed: %v", err)
+		}
+	}
+
+	// The user approves the request after it was read for the denial
+	if err := store.SaveTokenResponse(ctx, "approved", &TokenResponse{AccessToken: "token"}); err != nil {
+		t.Fatalf("SaveTokenResponse failed: %v", err)
+	}
+	if err := flow.DenyAuthorization(ctx, "approved"); !errors.Is(err, ErrAlreadyAuthorized) {
+		t.Errorf("DenyAuthorization error = %v, want %v", err, ErrAlreadyAuthorized)
+	}
+	if stored, _ := store.GetDeviceCode(ctx, "approved"); stored.Denied {
+		t.Error("denial overwrote an approved code")
+	}
+
+	failure := NewDeviceFlowError(ErrorCodeAccessDenied, "Denied upstream")
+	if err := flow.FailAuthorization(ctx, "failed", failure); err != nil {
+		t.Fatalf("FailAuthorization failed: %v", err)
+	}
+	if err := flow.DenyAuthorization(ctx, "failed"); !errors.Is(err, ErrAuthorizationEnded) {
+		t.Errorf("DenyAuthorization error = %v, want %v", err, ErrAuthorizationEnded)
+	}
+	if stored, _ := store.GetDeviceCode(ctx, "failed"); stored.Denied || stored.Failure != failure {
+		t.Errorf("stored code denied = %v, failure = %v; want the failure kept", stored.Denied, stored.Failure)
+	}
+
+	if got := emitter.types(); len(got) != 1 || got[0] != events.TypeAuthorizationFailed {
+		t.Errorf("events = %v, want [%s]", got, events.TypeAuthorizationFailed)
+	}
+}
+
+func TestExpiredCodeEvent(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	emitter := &recordingEmitter{}
+	flow := NewFlow(store, "https://example.com", WithEventEmitter(emitter))
+
+	code := &DeviceCode{DeviceCode: "expired", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := store.SaveDeviceCode(ctx, code); err != nil {
+		t.Fatalf("setup failed: %v", err)
+	}
+
+	// Reads of an expired code leave the event to the janitor sweep
+	for i := 0; i < 2; i++ {
+		if _, err := flow.GetDeviceCode(ctx, "expired"); err == nil {
+			t.Fatal("expected expiry error")
+		}
+	}
+	if got := emitter.types(); len(got) != 0 {
+		t.Errorf("events after reads = %v, want none", got)
+	}
+
+	if _, err := NewJanitor(store, 0, WithJanitorEvents(emitter)).RunOnce(ctx); err != nil {
+		t.Fatalf("RunOnce() error = %v", err)
+	}
+	if got := emitter.types(); len(got) != 1 || got[0] != events.TypeCodeExpired {
+		t.Errorf("events = %v, want [%s]", got, events.TypeCodeExpired)
+	}
+}
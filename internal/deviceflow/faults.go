@@ -0,0 +1,355 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjectedFault is returned by a FaultStore for the failures it injects
+var ErrInjectedFault = errors.New("injected store fault")
+
+// FaultConfig selects the faults a FaultStore injects. The zero value injects none.
+type FaultConfig struct {
+	Latency   time.Duration // Delay before each operation, failing it if the context ends first
+	ErrorRate float64       // Fraction of operations failing with ErrInjectedFault, 1 fails all
+
+	// Operations limits latency and errors to the named Store methods, such
+	// as GetPollState. Empty applies them to every method.
+	Operations []string
+
+	// PartialTransactions makes transactions apply only their first queued
+	// write before failing, as a pipeline cut off by a dropped connection would
+	PartialTransactions bool
+}
+
+// FaultStore wraps a store with injected latency, intermittent errors and
+// partially applied transactions, for testing how the flow and its callers
+// behave while the backend misbehaves. Faults can be changed while in use.
+type FaultStore struct {
+	Store
+
+	mu     sync.Mutex
+	cfg    FaultConfig
+	ops    map[string]bool
+	random *rand.Rand
+}
+
+// NewFaultStore wraps a store with fault injection. Errors are drawn from a
+// fixed seed so that runs are reproducible.
+func NewFaultStore(store Store, cfg FaultConfig) *FaultStore {
+	s := &FaultStore{Store: store, random: rand.New(rand.NewSource(1))}
+	s.SetFaults(cfg)
+	return s
+}
+
+// SetFaults replaces the injected faults
+func (s *FaultStore) SetFaults(cfg FaultConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	s.ops = nil
+	if len(cfg.Operations) > 0 {
+		s.ops = make(map[string]bool, len(cfg.Operations))
+		for _, op := range cfg.Operations {
+			s.ops[op] = true
+		}
+	}
+}
+
+// inject applies the configured latency and errors to an operation
+func (s *FaultStore) inject(ctx context.Context, op string) error {
+	s.mu.Lock()
+	targeted := s.ops == nil || s.ops[op]
+	latency := s.cfg.Latency
+	fail := targeted && s.cfg.ErrorRate > 0 && s.random.Float64() < s.cfg.ErrorRate
+	s.mu.Unlock()
+	if !targeted {
+		return nil
+	}
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", op, ctx.Err())
+		}
+	}
+	if fail {
+		return fmt.Errorf("%s: %w", op, ErrInjectedFault)
+	}
+	return nil
+}
+
+// partialTransactions reports whether transactions are cut off
+func (s *FaultStore) partialTransactions() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.PartialTransactions
+}
+
+// Transact implements Transactor. With PartialTransactions only the first
+// queued write reaches the backend before the transaction fails.
+func (s *FaultStore) Transact(ctx context.Context, fn func(tx Tx) error) error {
+	if err := s.inject(ctx, "Transact"); err != nil {
+		return err
+	}
+	if !s.partialTransactions() {
+		return transact(ctx, s.Store, fn)
+	}
+
+	queued := &queuedTx{}
+	if err := fn(queued); err != nil {
+		return err
+	}
+	if len(queued.writes) > 0 {
+		if err := queued.writes[0](directTx{ctx: ctx, store: s.Store}); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("transaction applied 1 of %d writes: %w", len(queued.writes), ErrInjectedFault)
+}
+
+// queuedTx records the writes of a transaction without applying them
+type queuedTx struct {
+	writes []func(tx Tx) error
+}
+
+func (tx *queuedTx) SaveDeviceCode(code *DeviceCode) error {
+	tx.writes = append(tx.writes, func(apply Tx) error { return apply.SaveDeviceCode(code) })
+	return nil
+}
+
+func (tx *queuedTx) SaveBatch(batch *Batch) error {
+	tx.writes = append(tx.writes, func(apply Tx) error { return apply.SaveBatch(batch) })
+	return nil
+}
+
+// SaveDeviceCode implements Store
+func (s *FaultStore) SaveDeviceCode(ctx context.Context, code *DeviceCode) error {
+	if err := s.inject(ctx, "SaveDeviceCode"); err != nil {
+		return err
+	}
+	return s.Store.SaveDeviceCode(ctx, code)
+}
+
+// GetDeviceCode implements Store
+func (s *FaultStore) GetDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error) {
+	if err := s.inject(ctx, "GetDeviceCode"); err != nil {
+		return nil, err
+	}
+	return s.Store.GetDeviceCode(ctx, deviceCode)
+}
+
+// ReplaceUserCode implements Store
+func (s *FaultStore) ReplaceUserCode(ctx context.Context, code *DeviceCode, previousUserCode string) error {
+	if err := s.inject(ctx, "ReplaceUserCode"); err != nil {
+		return err
+	}
+	return s.Store.ReplaceUserCode(ctx, code, previousUserCode)
+}
+
+// GetDeviceCodeByUserCode implements Store
+func (s *FaultStore) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*DeviceCode, error) {
+	if err := s.inject(ctx, "GetDeviceCodeByUserCode"); err != nil {
+		return nil, err
+	}
+	return s.Store.GetDeviceCodeByUserCode(ctx, userCode)
+}
+
+// GetTokenResponse implements Store
+func (s *FaultStore) GetTokenResponse(ctx context.Context, deviceCode string) (*TokenResponse, error) {
+	if err := s.inject(ctx, "GetTokenResponse"); err != nil {
+		return nil, err
+	}
+	return s.Store.GetTokenResponse(ctx, deviceCode)
+}
+
+// GetPollState implements Store
+func (s *FaultStore) GetPollState(ctx context.Context, deviceCode string) (*DeviceCode, *TokenResponse, error) {
+	if err := s.inject(ctx, "GetPollState"); err != nil {
+		return nil, nil, err
+	}
+	return s.Store.GetPollState(ctx, deviceCode)
+}
+
+// SaveTokenResponse implements Store
+func (s *FaultStore) SaveTokenResponse(ctx context.Context, deviceCode string, token *TokenResponse) error {
+	if err := s.inject(ctx, "SaveTokenResponse"); err != nil {
+		return err
+	}
+	return s.Store.SaveTokenResponse(ctx, deviceCode, token)
+}
+
+// DeleteDeviceCode implements Store
+func (s *FaultStore) DeleteDeviceCode(ctx context.Context, deviceCode string) error {
+	if err := s.inject(ctx, "DeleteDeviceCode"); err != nil {
+		return err
+	}
+	return s.Store.DeleteDeviceCode(ctx, deviceCode)
+}
+
+// BatchGetDeviceCodes implements Store
+func (s *FaultStore) BatchGetDeviceCodes(ctx context.Context, deviceCodes []string) ([]*DeviceCode, error) {
+	if err := s.inject(ctx, "BatchGetDeviceCodes"); err != nil {
+		return nil, err
+	}
+	return s.Store.BatchGetDeviceCodes(ctx, deviceCodes)
+}
+
+// BatchDeleteDeviceCodes implements Store
+func (s *FaultStore) BatchDeleteDeviceCodes(ctx context.Context, deviceCodes []string) error {
+	if err := s.inject(ctx, "BatchDeleteDeviceCodes"); err != nil {
+		return err
+	}
+	return s.Store.BatchDeleteDeviceCodes(ctx, deviceCodes)
+}
+
+// GetPollCount implements Store
+func (s *FaultStore) GetPollCount(ctx context.Context, deviceCode string, window time.Duration) (int, error) {
+	if err := s.inject(ctx, "GetPollCount"); err != nil {
+		return 0, err
+	}
+	return s.Store.GetPollCount(ctx, deviceCode, window)
+}
+
+// RecordPoll implements Store
+func (s *FaultStore) RecordPoll(ctx context.Context, code *DeviceCode, limit PollLimit) (bool, error) {
+	if err := s.inject(ctx, "RecordPoll"); err != nil {
+		return false, err
+	}
+	return s.Store.RecordPoll(ctx, code, limit)
+}
+
+// SavePollInterval implements Store
+func (s *FaultStore) SavePollInterval(ctx context.Context, code *DeviceCode) error {
+	if err := s.inject(ctx, "SavePollInterval"); err != nil {
+		return err
+	}
+	return s.Store.SavePollInterval(ctx, code)
+}
+
+// EndAuthorization implements Store
+func (s *FaultStore) EndAuthorization(ctx context.Context, deviceCode string, failure *DeviceFlowError) (*DeviceCode, error) {
+	if err := s.inject(ctx, "EndAuthorization"); err != nil {
+		return nil, err
+	}
+	return s.Store.EndAuthorization(ctx, deviceCode, failure)
+}
+
+// IncrementPollCount implements Store
+func (s *FaultStore) IncrementPollCount(ctx context.Context, deviceCode string) error {
+	if err := s.inject(ctx, "IncrementPollCount"); err != nil {
+		return err
+	}
+	return s.Store.IncrementPollCount(ctx, deviceCode)
+}
+
+// ClaimSubmission implements Store
+func (s *FaultStore) ClaimSubmission(ctx context.Context, nonce string, ttl time.Duration) (*SubmissionResult, error) {
+	if err := s.inject(ctx, "ClaimSubmission"); err != nil {
+		return nil, err
+	}
+	return s.Store.ClaimSubmission(ctx, nonce, ttl)
+}
+
+// SaveSubmission implements Store
+func (s *FaultStore) SaveSubmission(ctx context.Context, nonce string, result *SubmissionResult, ttl time.Duration) error {
+	if err := s.inject(ctx, "SaveSubmission"); err != nil {
+		return err
+	}
+	return s.Store.SaveSubmission(ctx, nonce, result, ttl)
+}
+
+// SaveBatch implements Store
+func (s *FaultStore) SaveBatch(ctx context.Context, batch *Batch) error {
+	if err := s.inject(ctx, "SaveBatch"); err != nil {
+		return err
+	}
+	return s.Store.SaveBatch(ctx, batch)
+}
+
+// GetBatch implements Store
+func (s *FaultStore) GetBatch(ctx context.Context, batchID string) (*Batch, error) {
+	if err := s.inject(ctx, "GetBatch"); err != nil {
+		return nil, err
+	}
+	return s.Store.GetBatch(ctx, batchID)
+}
+
+// SaveConsentTicket implements Store
+func (s *FaultStore) SaveConsentTicket(ctx context.Context, ticket, deviceCode string, ttl time.Duration) error {
+	if err := s.inject(ctx, "SaveConsentTicket"); err != nil {
+		return err
+	}
+	return s.Store.SaveConsentTicket(ctx, ticket, deviceCode, ttl)
+}
+
+// ConsumeConsentTicket implements Store
+func (s *FaultStore) ConsumeConsentTicket(ctx context.Context, ticket string) (string, error) {
+	if err := s.inject(ctx, "ConsumeConsentTicket"); err != nil {
+		return "", err
+	}
+	return s.Store.ConsumeConsentTicket(ctx, ticket)
+}
+
+// SaveCallbackNonce implements Store
+func (s *FaultStore) SaveCallbackNonce(ctx context.Context, nonce, deviceCode string, ttl time.Duration) error {
+	if err := s.inject(ctx, "SaveCallbackNonce"); err != nil {
+		return err
+	}
+	return s.Store.SaveCallbackNonce(ctx, nonce, deviceCode, ttl)
+}
+
+// ConsumeCallbackNonce implements Store
+func (s *FaultStore) ConsumeCallbackNonce(ctx context.Context, nonce string) (string, error) {
+	if err := s.inject(ctx, "ConsumeCallbackNonce"); err != nil {
+		return "", err
+	}
+	return s.Store.ConsumeCallbackNonce(ctx, nonce)
+}
+
+// ListDeviceCodesByClient implements Store
+func (s *FaultStore) ListDeviceCodesByClient(ctx context.Context, clientID string) ([]string, error) {
+	if err := s.inject(ctx, "ListDeviceCodesByClient"); err != nil {
+		return nil, err
+	}
+	return s.Store.ListDeviceCodesByClient(ctx, clientID)
+}
+
+// CountDeviceCodesByClient implements Store
+func (s *FaultStore) CountDeviceCodesByClient(ctx context.Context, clientID string) (int, error) {
+	if err := s.inject(ctx, "CountDeviceCodesByClient"); err != nil {
+		return 0, err
+	}
+	return s.Store.CountDeviceCodesByClient(ctx, clientID)
+}
+
+// CountPendingDeviceCodes implements Store
+func (s *FaultStore) CountPendingDeviceCodes(ctx context.Context) (int, error) {
+	if err := s.inject(ctx, "CountPendingDeviceCodes"); err != nil {
+		return 0, err
+	}
+	return s.Store.CountPendingDeviceCodes(ctx)
+}
+
+// Cleanup implements Store
+func (s *FaultStore) Cleanup(ctx context.Context) (*CleanupResult, error) {
+	if err := s.inject(ctx, "Cleanup"); err != nil {
+		return nil, err
+	}
+	return s.Store.Cleanup(ctx)
+}
+
+// CheckHealth implements Store
+func (s *FaultStore) CheckHealth(ctx context.Context) error {
+	if err := s.inject(ctx, "CheckHealth"); err != nil {
+		return err
+	}
+	return s.Store.CheckHealth(ctx)
+}
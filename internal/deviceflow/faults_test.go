@@ -0,0 +1,183 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// errorCode returns the RFC 8628 error code of a flow error
+func errorCode(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	dfe, ok := AsDeviceFlowError(err)
+	if !ok {
+		t.Fatalf("error %v is not a DeviceFlowError", err)
+	}
+	return dfe.Code
+}
+
+func TestFaultStoreFlowErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		faults FaultConfig
+		run    func(ctx context.Context, flow Flow, code *DeviceCode) error
+		want   string
+	}{
+		{
+			name:   "device code save fails",
+			faults: FaultConfig{ErrorRate: 1, Operations: []string{"SaveDeviceCode"}},
+			run: func(ctx context.Context, flow Flow, code *DeviceCode) error {
+				_, err := flow.RequestDeviceCode(ctx, "tv", "")
+				return err
+			},
+			want: ErrorCodeServerError,
+		},
+		{
+			name:   "capacity check fails",
+			faults: FaultConfig{ErrorRate: 1, Operations: []string{"CountPendingDeviceCodes"}},
+			run: func(ctx context.Context, flow Flow, code *DeviceCode) error {
+				_, err := flow.RequestDeviceCode(ctx, "tv", "")
+				return err
+			},
+			want: ErrorCodeServerError,
+		},
+		{
+			name:   "poll read fails",
+			faults: FaultConfig{ErrorRate: 1, Operations: []string{"GetPollState"}},
+			run: func(ctx context.Context, flow Flow, code *DeviceCode) error {
+				_, err := flow.CheckDeviceCode(ctx, code.DeviceCode)
+				return err
+			},
+			want: ErrorCodeServerError,
+		},
+		{
+			name:   "rate limit fails",
+			faults: FaultConfig{ErrorRate: 1, Operations: []string{"RecordPoll"}},
+			run: func(ctx context.Context, flow Flow, code *DeviceCode) error {
+				_, err := flow.CheckDeviceCode(ctx, code.DeviceCode)
+				return err
+			},
+			want: ErrorCodeServerError,
+		},
+		{
+			name:   "poll read outlasts the request",
+			faults: FaultConfig{Latency: time.Second, Operations: []string{"GetPollState"}},
+			run: func(ctx context.Context, flow Flow, code *DeviceCode) error {
+				ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+				defer cancel()
+				_, err := flow.CheckDeviceCode(ctx, code.DeviceCode)
+				return err
+			},
+			want: ErrorCodeServerError,
+		},
+		{
+			name:   "slow poll within the deadline",
+			faults: FaultConfig{Latency: 5 * time.Millisecond},
+			run: func(ctx context.Context, flow Flow, code *DeviceCode) error {
+				_, err := flow.CheckDeviceCode(ctx, code.DeviceCode)
+				return err
+			},
+			want: ErrorCodeAuthorizationPending,
+		},
+		{
+			name:   "token save fails",
+			faults: FaultConfig{ErrorRate: 1, Operations: []string{"SaveTokenResponse"}},
+			run: func(ctx context.Context, flow Flow, code *DeviceCode) error {
+				return flow.CompleteAuthorization(ctx, code.DeviceCode, &TokenResponse{AccessToken: "access", TokenType: "Bearer"})
+			},
+			want: ErrorCodeServerError,
+		},
+		{
+			name:   "user code lookup fails",
+			faults: FaultConfig{ErrorRate: 1, Operations: []string{"GetDeviceCodeByUserCode"}},
+			run: func(ctx context.Context, flow Flow, code *DeviceCode) error {
+				_, err := flow.VerifyUserCode(ctx, code.UserCode)
+				return err
+			},
+			want: ErrorCodeInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			backend := newMockStore()
+			store := NewFaultStore(backend, FaultConfig{})
+			flow := NewFlow(store, "https://example.com", WithMaxOutstandingCodes(100))
+			code, err := flow.RequestDeviceCode(ctx, "tv", "")
+			if err != nil {
+				t.Fatalf("RequestDeviceCode failed: %v", err)
+			}
+			backend.deviceCodes[code.DeviceCode].LastPoll = time.Now().Add(-time.Minute)
+
+			store.SetFaults(tt.faults)
+			if got := errorCode(t, tt.run(ctx, flow, code)); got != tt.want {
+				t.Errorf("error code = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFaultStoreIntermittentErrors(t *testing.T) {
+	ctx := context.Background()
+	store := NewFaultStore(newMockStore(), FaultConfig{})
+	flow := NewFlow(store, "https://example.com")
+	code, err := flow.RequestDeviceCode(ctx, "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	// Failed polls report server_error and never end the flow
+	store.SetFaults(FaultConfig{ErrorRate: 0.5})
+	seen := make(map[string]int)
+	for i := 0; i < 50; i++ {
+		_, err := flow.CheckDeviceCode(ctx, code.DeviceCode)
+		seen[errorCode(t, err)]++
+	}
+	for got := range seen {
+		switch got {
+		case ErrorCodeAuthorizationPending, ErrorCodeSlowDown, ErrorCodeServerError:
+		default:
+			t.Errorf("poll returned %q under intermittent faults", got)
+		}
+	}
+	if seen[ErrorCodeServerError] == 0 {
+		t.Error("no injected failures surfaced as server_error")
+	}
+
+	// The flow completes once the faults stop
+	store.SetFaults(FaultConfig{})
+	if err := flow.CompleteAuthorization(ctx, code.DeviceCode, &TokenResponse{AccessToken: "access", TokenType: "Bearer"}); err != nil {
+		t.Fatalf("CompleteAuthorization failed: %v", err)
+	}
+	if token, err := flow.CheckDeviceCode(ctx, code.DeviceCode); err != nil || token.AccessToken != "access" {
+		t.Errorf("CheckDeviceCode = %v, %v, want the token", token, err)
+	}
+}
+
+func TestFaultStorePartialTransactions(t *testing.T) {
+	ctx := context.Background()
+	backend := newMockStore()
+	store := NewFaultStore(backend, FaultConfig{PartialTransactions: true})
+	flow := NewFlow(store, "https://example.com")
+
+	_, _, err := flow.CreateBatch(ctx, "kiosk", "openid", 3, 0)
+	if got := errorCode(t, err); got != ErrorCodeServerError {
+		t.Fatalf("CreateBatch error code = %q, want %q", got, ErrorCodeServerError)
+	}
+	if !errors.Is(store.Transact(ctx, func(tx Tx) error { return nil }), ErrInjectedFault) {
+		t.Error("Transact did not report the injected fault")
+	}
+
+	// Writes applied before the cut are cleaned up
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if len(backend.deviceCodes) != 0 || len(backend.batches) != 0 {
+		t.Errorf("%d device codes and %d batches left after a failed batch, want none",
+			len(backend.deviceCodes), len(backend.batches))
+	}
+}
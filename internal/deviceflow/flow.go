@@ -3,10 +3,18 @@ package deviceflow
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/url"
 	"path"
 	"time"
 
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+	"github.com/wrale/oauth2-device-proxy/internal/features"
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+	"github.com/wrale/oauth2-device-proxy/internal/ttl"
 	"github.com/wrale/oauth2-device-proxy/internal/validation"
 )
 
@@ -17,27 +25,98 @@ const (
 	// MinPollInterval is the minimum interval between polling requests
 	MinPollInterval = 5 * time.Second
 
+	// SlowDownIncrement is added to a device's polling interval on each slow_down per RFC 8628 section 3.5
+	SlowDownIncrement = 5 * time.Second
+
 	// DeviceCodeLength is the required length of the device code in hex characters
 	DeviceCodeLength = 64 // 32 bytes hex encoded per tests
+
+	// NonceLength is the length of OpenID Connect nonces in hex characters
+	NonceLength = 32
+
+	// ShedRetryAfter is the delay suggested to clients whose device authorization
+	// request was shed because the outstanding code cap was reached
+	ShedRetryAfter = 30 * time.Second
+
+	// DefaultSubmissionWindow is how long verification form results are kept for
+	// answering duplicate submissions of the same form
+	DefaultSubmissionWindow = 30 * time.Second
 )
 
 // Flow defines the interface for device authorization grant flow per RFC 8628
 type Flow interface {
 	// RequestDeviceCode initiates a new device authorization request
-	RequestDeviceCode(ctx context.Context, clientID string, scope string) (*DeviceCode, error)
+	RequestDeviceCode(ctx context.Context, clientID string, scope string, opts ...RequestOption) (*DeviceCode, error)
 
 	// GetDeviceCode retrieves and validates a device code
 	GetDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error)
 
+	// RefreshUserCode issues a new user code for a pending device code
+	RefreshUserCode(ctx context.Context, deviceCode, clientID string) (*DeviceCode, error)
+
 	// CheckDeviceCode validates device code and returns token if authorized
 	CheckDeviceCode(ctx context.Context, deviceCode string) (*TokenResponse, error)
 
+	// WaitForToken behaves like CheckDeviceCode but, while authorization is
+	// pending, holds the request open until the flow ends or ctx is done
+	WaitForToken(ctx context.Context, deviceCode string) (*TokenResponse, error)
+
+	// GetStatus reports the state of the flow without counting as a device poll
+	GetStatus(ctx context.Context, deviceCode string) (Status, error)
+
 	// VerifyUserCode validates user code and returns associated device code
 	VerifyUserCode(ctx context.Context, userCode string) (*DeviceCode, error)
 
 	// CompleteAuthorization completes the authorization flow for a device code
 	CompleteAuthorization(ctx context.Context, deviceCode string, token *TokenResponse) error
 
+	// DenyAuthorization records that the user denied the authorization request
+	DenyAuthorization(ctx context.Context, deviceCode string) error
+
+	// FailAuthorization ends the flow with a terminal upstream error
+	FailAuthorization(ctx context.Context, deviceCode string, failure *DeviceFlowError) error
+
+	// ClaimSubmission claims a verification form nonce, returning any earlier result
+	ClaimSubmission(ctx context.Context, nonce string) (*SubmissionResult, error)
+
+	// RecordSubmission stores the result of a claimed verification form submission
+	RecordSubmission(ctx context.Context, nonce string, result *SubmissionResult) error
+
+	// CreateBatch pre-generates device codes for devices that cannot reach the proxy
+	CreateBatch(ctx context.Context, clientID, scope string, count int, expiry time.Duration) (*Batch, []*DeviceCode, error)
+
+	// GetBatch retrieves a batch and its outstanding device codes
+	GetBatch(ctx context.Context, batchID string) (*Batch, []*DeviceCode, error)
+
+	// InvalidateBatch revokes all outstanding device codes in a batch
+	InvalidateBatch(ctx context.Context, batchID string) (int, error)
+
+	// ListClientCodes returns the unexpired device codes issued to a client
+	ListClientCodes(ctx context.Context, clientID string) ([]*DeviceCode, error)
+
+	// RevokeClient revokes all outstanding device codes and undelivered tokens of a client
+	RevokeClient(ctx context.Context, clientID string) (int, error)
+
+	// BeginConsent issues a ticket for a verified device code awaiting user approval
+	BeginConsent(ctx context.Context, deviceCode string) (string, error)
+
+	// ResolveConsent returns the device code awaiting approval for a consent ticket
+	ResolveConsent(ctx context.Context, ticket string) (*DeviceCode, error)
+
+	// IssueCallbackNonce issues a single-use nonce binding the authorization
+	// callback to a verified device code
+	IssueCallbackNonce(ctx context.Context, deviceCode string) (string, error)
+
+	// ConsumeCallbackNonce validates and consumes the callback nonce of a device code
+	ConsumeCallbackNonce(ctx context.Context, deviceCode, nonce string) error
+
+	// AllowsCompleteURI reports whether verification_uri_complete may be offered
+	// for the client that requested the given user code
+	AllowsCompleteURI(ctx context.Context, userCode string) bool
+
+	// CheckCapacity reports an error while new device codes are being shed
+	CheckCapacity(ctx context.Context) error
+
 	// CheckHealth verifies the flow manager's storage backend is healthy
 	CheckHealth(ctx context.Context) error
 }
@@ -51,8 +130,36 @@ type flowImpl struct {
 	userCodeLength  int
 	rateLimitWindow time.Duration
 	maxPollsPerMin  int
+	intervalGrowth  IntervalGrowth
+
+	maxExpiryDuration time.Duration
+	maxBatchExpiry    time.Duration // Cap on batch code expiry, maxExpiryDuration when lower
+	maxPollInterval   time.Duration
+	timing            TimingPolicy
+	scopes            ScopePolicy
+	submissionWindow  time.Duration
+
+	events events.Emitter
+
+	completeURIPolicy   CompleteURIPolicy
+	completeURITemplate string
+	codeFormat          validation.DisplayFormat // Layout of codes in verification_uri_complete
+
+	maxOutstanding int
+	maxPerClient   int
+
+	anomalyReverify bool
+	features        *features.Set
+
+	enricher    TokenEnricher
+	withholding TokenWithholding
+	callbacks   CallbackSender
+	origins     CallbackOriginPolicy
 }
 
+// CompleteURIPolicy reports whether verification_uri_complete is issued to a client
+type CompleteURIPolicy func(clientID string) bool
+
 // NewFlow creates a new device flow manager with provided options
 func NewFlow(store Store, baseURL string, opts ...Option) Flow {
 	f := newDefaultFlow(store, baseURL)
@@ -60,10 +167,20 @@ func NewFlow(store Store, baseURL string, opts ...Option) Flow {
 		opt(f)
 	}
 
-	// Ensure minimum durations per RFC 8628
+	// Ensure minimum durations per RFC 8628 and the expiry cap. Startup
+	// validates both with ttl.Policy, so clamping here is a last resort.
 	if f.expiryDuration < MinExpiryDuration {
+		log.Printf("Warning: code expiry %s raised to the minimum of %s", f.expiryDuration, MinExpiryDuration)
 		f.expiryDuration = MinExpiryDuration
 	}
+	if f.maxExpiryDuration < MinExpiryDuration {
+		f.maxExpiryDuration = MinExpiryDuration
+	}
+	if f.expiryDuration > f.maxExpiryDuration {
+		log.Printf("Warning: code expiry %s lowered to the maximum of %s", f.expiryDuration, f.maxExpiryDuration)
+		f.expiryDuration = f.maxExpiryDuration
+	}
+	f.maxBatchExpiry = min(max(f.maxBatchExpiry, f.maxExpiryDuration), MaxBatchExpiry)
 	if f.pollInterval < MinPollInterval {
 		f.pollInterval = MinPollInterval
 	}
@@ -80,13 +197,129 @@ func newDefaultFlow(store Store, baseURL string) *flowImpl {
 		userCodeLength:  8,
 		rateLimitWindow: time.Minute,
 		maxPollsPerMin:  12,
+		intervalGrowth:  FixedGrowth(SlowDownIncrement),
+
+		maxExpiryDuration: ttl.DefaultMaxDeviceCode,
+		maxPollInterval:   DefaultMaxPollInterval,
+		submissionWindow:  DefaultSubmissionWindow,
+
+		events: events.NopEmitter{},
+
+		completeURIPolicy: func(string) bool { return true },
 	}
 }
 
 // RequestDeviceCode initiates a new device authorization flow
-func (f *flowImpl) RequestDeviceCode(ctx context.Context, clientID, scope string) (*DeviceCode, error) {
+func (f *flowImpl) RequestDeviceCode(ctx context.Context, clientID, scope string, opts ...RequestOption) (*DeviceCode, error) {
+	if err := f.CheckCapacity(ctx); err != nil {
+		if errors.Is(err, ErrCapacityExceeded) {
+			shedRequests.Inc()
+			return nil, ErrCapacityExceeded
+		}
+		if errors.Is(err, ErrStoreUnavailable) {
+			return nil, ErrStoreDegraded
+		}
+		return nil, NewDeviceFlowError(
+			ErrorCodeServerError,
+			"Failed to check outstanding device codes",
+		)
+	}
+
+	scope, err := f.checkScope(clientID, scope)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.checkClientQuota(ctx, clientID); err != nil {
+		return nil, err
+	}
+
+	code, err := f.newDeviceCode(clientID, scope, f.expiryDuration)
+	if err != nil {
+		return nil, err
+	}
+	f.applyClientTiming(code)
+	for _, opt := range opts {
+		opt(code)
+	}
+	f.boundTiming(code)
+	if err := validateDeviceIdentity(code.Device); err != nil {
+		return nil, err
+	}
+	if err := validateAuthParams(code.AuthParams); err != nil {
+		return nil, err
+	}
+	if err := validateResources(code.Resources); err != nil {
+		return nil, err
+	}
+	if err := f.validateCallbackURI(code.ClientID, code.CallbackURI); err != nil {
+		return nil, err
+	}
+
+	// Save the code first to handle storage errors
+	if err := f.store.SaveDeviceCode(ctx, code); err != nil {
+		if errors.Is(err, ErrStoreUnavailable) {
+			return nil, ErrStoreDegraded
+		}
+		return nil, NewDeviceFlowError(
+			ErrorCodeServerError,
+			"Failed to save device code",
+		)
+	}
+
+	f.emit(ctx, events.TypeDeviceCodeCreated, code, map[string]any{
+		"expires_at": code.ExpiresAt,
+	})
+
+	return code, nil
+}
+
+// CheckCapacity returns ErrCapacityExceeded once the outstanding code cap is reached
+func (f *flowImpl) CheckCapacity(ctx context.Context) error {
+	if f.maxOutstanding <= 0 {
+		return nil
+	}
+
+	pending, err := f.store.CountPendingDeviceCodes(ctx)
+	if err != nil {
+		return err
+	}
+	if pending >= f.maxOutstanding {
+		return fmt.Errorf("%d of %d device codes outstanding: %w", pending, f.maxOutstanding, ErrCapacityExceeded)
+	}
+	return nil
+}
+
+// checkClientQuota returns ErrClientQuotaExceeded once the client holds as
+// many unexpired device codes as the per-client quota allows
+func (f *flowImpl) checkClientQuota(ctx context.Context, clientID string) error {
+	if f.maxPerClient <= 0 {
+		return nil
+	}
+
+	count, err := f.store.CountDeviceCodesByClient(ctx, clientID)
+	if err != nil {
+		if errors.Is(err, ErrStoreUnavailable) {
+			return ErrStoreDegraded
+		}
+		return NewDeviceFlowError(ErrorCodeServerError, "Failed to check outstanding device codes")
+	}
+	if count >= f.maxPerClient {
+		shedRequests.Inc()
+		return ErrClientQuotaExceeded
+	}
+	return nil
+}
+
+// shedRequests counts device authorization requests rejected by the outstanding code cap
+var shedRequests = metrics.Default.NewCounter(
+	"device_proxy_device_code_requests_shed_total",
+	"Device authorization requests rejected because the outstanding code cap was reached.",
+)
+
+// newDeviceCode generates an unsaved device code expiring after the given duration
+func (f *flowImpl) newDeviceCode(clientID, scope string, expiry time.Duration) (*DeviceCode, error) {
 	// Calculate expiry time - must be at least 10 minutes per RFC 8628
-	expiresIn := int(f.expiryDuration.Seconds())
+	expiresIn := int(expiry.Seconds())
 	if expiresIn < int(MinExpiryDuration.Seconds()) {
 		expiresIn = int(MinExpiryDuration.Seconds())
 	}
@@ -106,10 +339,22 @@ func (f *flowImpl) RequestDeviceCode(ctx context.Context, clientID, scope string
 		return nil, err
 	}
 
-	// Build verification URIs
+	// OpenID Connect requests carry a nonce bound into the ID token
+	var nonce string
+	if RequestsOpenID(scope) {
+		if nonce, err = generateSecureCode(NonceLength); err != nil {
+			return nil, err
+		}
+	}
+
+	// Build verification URIs, omitting the complete URI when policy forces
+	// users to compare the code manually per RFC 8628 section 5.4
 	verificationURI, verificationURIComplete := f.buildVerificationURIs(userCode)
+	if !f.completeURIPolicy(clientID) {
+		verificationURIComplete = ""
+	}
 
-	code := &DeviceCode{
+	return &DeviceCode{
 		DeviceCode:              deviceCode,
 		UserCode:                userCode,
 		VerificationURI:         verificationURI,
@@ -117,27 +362,25 @@ func (f *flowImpl) RequestDeviceCode(ctx context.Context, clientID, scope string
 		ExpiresIn:               expiresIn,
 		Interval:                int(f.pollInterval.Seconds()),
 		ExpiresAt:               expiresAt,
+		RequestedAt:             now,
 		ClientID:                clientID,
 		Scope:                   scope,
 		LastPoll:                now,
-	}
-
-	// Save the code first to handle storage errors
-	if err := f.store.SaveDeviceCode(ctx, code); err != nil {
-		return nil, NewDeviceFlowError(
-			ErrorCodeServerError,
-			"Failed to save device code",
-		)
-	}
-
-	return code, nil
+		Nonce:                   nonce,
+	}, nil
 }
 
 // GetDeviceCode retrieves and validates a device code per RFC 8628.
 // It enforces consistent validation and expiry handling across all device code operations.
 func (f *flowImpl) GetDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error) {
-	// First check store errors - these take precedence
 	code, err := f.store.GetDeviceCode(ctx, deviceCode)
+	return f.validateDeviceCode(ctx, code, err)
+}
+
+// validateDeviceCode turns the result of a device code lookup into the code or
+// the error reported for it
+func (f *flowImpl) validateDeviceCode(ctx context.Context, code *DeviceCode, err error) (*DeviceCode, error) {
+	// First check store errors - these take precedence
 	if err != nil {
 		return nil, NewDeviceFlowError(
 			ErrorCodeServerError,
@@ -153,6 +396,10 @@ func (f *flowImpl) GetDeviceCode(ctx context.Context, deviceCode string) (*Devic
 		)
 	}
 
+	if err := f.checkTombstone(ctx, code, tombstoneViaPoll); err != nil {
+		return nil, err
+	}
+
 	// Check expiration using direct time comparison for precision
 	if time.Now().After(code.ExpiresAt) {
 		return nil, NewDeviceFlowError(
@@ -169,62 +416,107 @@ func (f *flowImpl) GetDeviceCode(ctx context.Context, deviceCode string) (*Devic
 
 // CheckDeviceCode validates device code and returns token if authorized
 func (f *flowImpl) CheckDeviceCode(ctx context.Context, deviceCode string) (*TokenResponse, error) {
-	// Get and validate device code - ensures consistent validation
-	code, err := f.GetDeviceCode(ctx, deviceCode)
+	// Read the code and any token together - ensures consistent validation
+	// while keeping each poll to one read and one rate limit round trip
+	code, token, err := f.store.GetPollState(ctx, deviceCode)
+	code, err = f.validateDeviceCode(ctx, code, err)
 	if err != nil {
 		return nil, err // Already wrapped in DeviceFlowError
 	}
 
-	// Get cached token response if it exists
-	token, err := f.store.GetTokenResponse(ctx, deviceCode)
-	if err != nil {
-		return nil, NewDeviceFlowError(
-			ErrorCodeServerError,
-			"Internal server error",
-		)
+	if err := checkClientAuthentication(ctx, code); err != nil {
+		return nil, err
+	}
+	if err := checkRequestedResources(ctx, code); err != nil {
+		return nil, err
 	}
 
-	// If no token yet, check rate limiting
-	if token == nil {
-		// Ensure minimum polling interval
-		if time.Since(code.LastPoll) < f.pollInterval {
-			return nil, ErrSlowDown
-		}
+	f.checkPoller(ctx, code)
 
-		// Check rate limit window
-		if f.maxPollsPerMin > 0 {
-			count, err := f.store.GetPollCount(ctx, deviceCode, f.rateLimitWindow)
-			if err != nil {
-				return nil, NewDeviceFlowError(
-					ErrorCodeServerError,
-					"Failed to check rate limit",
-				)
-			}
-			if count >= f.maxPollsPerMin {
-				return nil, ErrSlowDown
-			}
-		}
+	token, err = tokenOutcome(code, token)
+	if err != nil {
+		return nil, err
+	}
 
-		// Update poll timestamp and count
-		if err := f.store.UpdatePollTimestamp(ctx, deviceCode); err != nil {
+	// If no token yet, enforce the polling interval and rate limit window
+	if token == nil {
+		allowed, err := f.store.RecordPoll(ctx, code, PollLimit{
+			Interval: f.effectiveInterval(code),
+			Window:   f.rateLimitWindow,
+			MaxPolls: f.maxPollsPerMin,
+		})
+		if err != nil {
 			return nil, NewDeviceFlowError(
 				ErrorCodeServerError,
-				"Failed to update poll timestamp",
+				"Failed to check rate limit",
 			)
 		}
-		if err := f.store.IncrementPollCount(ctx, deviceCode); err != nil {
-			return nil, NewDeviceFlowError(
-				ErrorCodeServerError,
-				"Failed to increment poll count",
-			)
+		if !allowed {
+			return nil, &PollBackoffError{ErrSlowDown, f.slowDown(ctx, code)}
 		}
 
 		// Return pending error
-		return nil, ErrPendingAuthorization
+		return nil, &PollBackoffError{ErrPendingAuthorization, f.effectiveInterval(code)}
 	}
 
 	// Return successful token response
-	return token, nil
+	f.markConsumed(ctx, code)
+	return f.deliver(code, token), nil
+}
+
+// resolveToken returns the token for a device code once authorized, the error
+// ending the flow if it was denied or failed, and nil while it is still pending
+func (f *flowImpl) resolveToken(ctx context.Context, code *DeviceCode) (*TokenResponse, error) {
+	// Get cached token response if it exists
+	token, err := f.store.GetTokenResponse(ctx, code.DeviceCode)
+	if err != nil {
+		return nil, NewDeviceFlowError(
+			ErrorCodeServerError,
+			"Internal server error",
+		)
+	}
+	return tokenOutcome(code, token)
+}
+
+// tokenOutcome resolves a device code and its stored token, if any, to the
+// token or the error ending the flow
+func tokenOutcome(code *DeviceCode, token *TokenResponse) (*TokenResponse, error) {
+	if token != nil {
+		return token, nil
+	}
+
+	// Denied requests end the flow per RFC 8628 section 3.5
+	if code.Denied {
+		return nil, ErrAccessDenied
+	}
+	if code.Failure != nil {
+		return nil, code.Failure
+	}
+	return nil, nil
+}
+
+// effectiveInterval returns the polling interval currently required of a device,
+// which grows each time it is told to slow down
+func (f *flowImpl) effectiveInterval(code *DeviceCode) time.Duration {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval < f.pollInterval {
+		return f.pollInterval
+	}
+	return interval
+}
+
+// slowDown raises the device's required interval using the flow's growth
+// strategy, by SlowDownIncrement unless configured otherwise as RFC 8628
+// section 3.5 requires for this and all subsequent requests, and returns the
+// new interval
+func (f *flowImpl) slowDown(ctx context.Context, code *DeviceCode) time.Duration {
+	next := f.intervalGrowth(f.effectiveInterval(code))
+	code.Interval = int((next + time.Second - 1) / time.Second) // Whole seconds, rounded up
+	if err := f.store.SavePollInterval(ctx, code); err != nil {
+		// The device is still told to slow down, only enforcement lags
+		log.Printf("Warning: failed to persist poll interval: %v", err)
+	}
+	return f.effectiveInterval(code)
 }
 
 // CompleteAuthorization completes the flow with token response
@@ -235,22 +527,137 @@ func (f *flowImpl) CompleteAuthorization(ctx context.Context, deviceCode string,
 		return err // Already wrapped in DeviceFlowError
 	}
 
-	// Save the token response
+	token, err = f.enrichToken(ctx, code, token)
+	if err != nil {
+		// Terminal errors end the flow so the device stops polling
+		if dfe, ok := AsDeviceFlowError(err); ok && dfe.Terminal() {
+			if failErr := f.FailAuthorization(ctx, code.DeviceCode, dfe); failErr != nil {
+				log.Printf("Error: failed to record rejected token: %v", failErr)
+			}
+		}
+		return err
+	}
+
+	// Save the token response, failing if another completion won the race
 	if err := f.store.SaveTokenResponse(ctx, code.DeviceCode, token); err != nil {
+		if errors.Is(err, ErrAlreadyAuthorized) {
+			return ErrAlreadyAuthorized
+		}
 		return NewDeviceFlowError(
 			ErrorCodeServerError,
 			"Failed to save token response",
 		)
 	}
 
+	f.markAuthorized(ctx, code)
+
+	var data map[string]any
+	if !code.RequestedAt.IsZero() {
+		data = map[string]any{"issued_at": code.RequestedAt}
+	}
+	if !code.VerifiedAt.IsZero() {
+		if data == nil {
+			data = make(map[string]any)
+		}
+		data["verified_at"] = code.VerifiedAt
+	}
+	f.emit(ctx, events.TypeAuthorizationCompleted, code, data)
+	f.sendCallback(ctx, code, token, nil)
+
+	return nil
+}
+
+// DenyAuthorization marks the device code as denied so that polling devices
+// receive access_denied per RFC 8628 section 3.5
+func (f *flowImpl) DenyAuthorization(ctx context.Context, deviceCode string) error {
+	code, err := f.endAuthorization(ctx, deviceCode, nil)
+	if err != nil {
+		return err // Already wrapped in DeviceFlowError
+	}
+
+	f.emit(ctx, events.TypeAuthorizationDenied, code, nil)
+	f.sendCallback(ctx, code, nil, ErrAccessDenied)
+
+	return nil
+}
+
+// FailAuthorization records a terminal error reported by the authorization server
+// so that polling devices receive it in place of authorization_pending
+func (f *flowImpl) FailAuthorization(ctx context.Context, deviceCode string, failure *DeviceFlowError) error {
+	if failure == nil || !failure.Terminal() {
+		return NewDeviceFlowError(ErrorCodeInvalidRequest, "Only terminal errors end the device flow")
+	}
+
+	code, err := f.endAuthorization(ctx, deviceCode, failure)
+	if err != nil {
+		return err // Already wrapped in DeviceFlowError
+	}
+
+	f.emit(ctx, events.TypeAuthorizationFailed, code, map[string]any{"error": failure.Code})
+	f.sendCallback(ctx, code, nil, failure)
+
 	return nil
 }
 
+// endAuthorization denies or fails a live device code in the store, unless a
+// token was stored for it or its flow already ended in the meantime
+func (f *flowImpl) endAuthorization(ctx context.Context, deviceCode string, failure *DeviceFlowError) (*DeviceCode, error) {
+	if _, err := f.GetDeviceCode(ctx, deviceCode); err != nil {
+		return nil, err
+	}
+
+	code, err := f.store.EndAuthorization(ctx, deviceCode, failure)
+	switch {
+	case errors.Is(err, ErrAlreadyAuthorized) || errors.Is(err, ErrAuthorizationEnded):
+		return nil, err
+	case err != nil:
+		return nil, NewDeviceFlowError(
+			ErrorCodeServerError,
+			"Failed to save authorization outcome",
+		)
+	case code == nil:
+		return nil, NewDeviceFlowError(
+			ErrorCodeInvalidRequest,
+			"Invalid device code: code not found",
+		)
+	}
+	return code, nil
+}
+
+// AllowsCompleteURI applies the complete URI policy to the client owning the user
+// code. Unknown codes fall back to the policy for an anonymous client.
+func (f *flowImpl) AllowsCompleteURI(ctx context.Context, userCode string) bool {
+	var clientID string
+	if code, err := f.store.GetDeviceCodeByUserCode(ctx, validation.NormalizeCode(userCode)); err == nil && code != nil {
+		clientID = code.ClientID
+	}
+	return f.completeURIPolicy(clientID)
+}
+
 // CheckHealth verifies the storage backend is healthy
 func (f *flowImpl) CheckHealth(ctx context.Context) error {
 	return f.store.CheckHealth(ctx)
 }
 
+// emit publishes a lifecycle event for the device code
+func (f *flowImpl) emit(ctx context.Context, eventType events.Type, code *DeviceCode, data map[string]any) {
+	f.events.Emit(ctx, codeEvent(eventType, code, data))
+}
+
+// codeEvent builds a lifecycle event describing the device code
+func codeEvent(eventType events.Type, code *DeviceCode, data map[string]any) events.Event {
+	event := events.New(eventType)
+	event.ClientID = code.ClientID
+	event.UserCode = code.UserCode
+	event.DeviceCodeHash = audit.HashDeviceCode(code.DeviceCode)
+	event.Scope = code.Scope
+	if code.Device != nil {
+		event.DeviceID = code.Device.ID
+	}
+	event.Data = data
+	return event
+}
+
 // buildVerificationURIs creates the verification URIs per RFC 8628 sections 3.2 and 3.3.1
 func (f *flowImpl) buildVerificationURIs(userCode string) (string, string) {
 	// Parse the base URL to properly handle existing paths
@@ -275,10 +682,18 @@ func (f *flowImpl) buildVerificationURIs(userCode string) (string, string) {
 		return verificationURI, "" // Return base URI only if code invalid
 	}
 
+	// Codes in links are laid out as the pages show them
+	displayCode := f.codeFormat.Format(userCode)
+
+	// Deep links let companion apps intercept the code per RFC 8628 section 3.3.1
+	if f.completeURITemplate != "" {
+		return verificationURI, expandCompleteURI(f.completeURITemplate, displayCode)
+	}
+
 	// Create verification URI with code per RFC section 3.3.1
 	completeURL := *baseURL // Make a copy for the complete URI
 	q := completeURL.Query()
-	q.Set("code", userCode) // Use display format per RFC section 6.1
+	q.Set("code", displayCode) // Use display format per RFC section 6.1
 	completeURL.RawQuery = q.Encode()
 
 	return verificationURI, completeURL.String()
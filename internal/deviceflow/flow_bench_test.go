@@ -0,0 +1,71 @@
+package deviceflow
+
+import (
+	"context"
+	"testing"
+)
+
+// Flow benchmarks run against the in-memory mockStore to measure the flow's
+// own overhead. Run them with make bench, which also covers the Redis store.
+
+func BenchmarkRequestDeviceCode(b *testing.B) {
+	ctx := context.Background()
+	flow := NewFlow(newMockStore(), "https://example.com")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := flow.RequestDeviceCode(ctx, "bench", "openid profile"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCheckDeviceCode(b *testing.B) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "bench", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := flow.CompleteAuthorization(ctx, code.DeviceCode, &TokenResponse{AccessToken: "token", TokenType: "Bearer"}); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCheckDeviceCodeContention polls a small set of pending codes from
+// many goroutines, as a fleet of devices sharing one proxy would
+func BenchmarkCheckDeviceCodeContention(b *testing.B) {
+	ctx := context.Background()
+	flow := NewFlow(newMockStore(), "https://example.com")
+
+	codes := make([]string, 16)
+	for i := range codes {
+		code, err := flow.RequestDeviceCode(ctx, "bench", "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		codes[i] = code.DeviceCode
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			// Pending and slow_down responses are the expected outcome
+			_, _ = flow.CheckDeviceCode(ctx, codes[i%len(codes)])
+			i++
+		}
+	})
+}
@@ -5,6 +5,9 @@ import (
 	"context"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/ttl"
 )
 
 // TestRequestDeviceCode tests the primary RFC 8628 section 3.1-3.3 endpoint
@@ -98,3 +101,95 @@ func TestRequestDeviceCode(t *testing.T) {
 		})
 	}
 }
+
+// readCountingStore counts the reads made against the wrapped store
+type readCountingStore struct {
+	*mockStore
+	reads int
+}
+
+func (s *readCountingStore) GetDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error) {
+	s.reads++
+	return s.mockStore.GetDeviceCode(ctx, deviceCode)
+}
+
+func (s *readCountingStore) GetTokenResponse(ctx context.Context, deviceCode string) (*TokenResponse, error) {
+	s.reads++
+	return s.mockStore.GetTokenResponse(ctx, deviceCode)
+}
+
+func (s *readCountingStore) GetPollState(ctx context.Context, deviceCode string) (*DeviceCode, *TokenResponse, error) {
+	s.reads++
+	return s.mockStore.GetPollState(ctx, deviceCode)
+}
+
+// TestCheckDeviceCodeSingleRead ensures each poll reads the code and token together
+func TestCheckDeviceCodeSingleRead(t *testing.T) {
+	ctx := context.Background()
+	store := &readCountingStore{mockStore: newMockStore()}
+	flow := NewFlow(store, "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "tv", "openid")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	store.reads = 0
+	if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); err == nil {
+		t.Fatal("pending code returned a token")
+	}
+	if store.reads != 1 {
+		t.Errorf("pending poll made %d reads, want 1", store.reads)
+	}
+
+	if err := store.SaveTokenResponse(ctx, code.DeviceCode, &TokenResponse{AccessToken: "at", TokenType: "Bearer"}); err != nil {
+		t.Fatal(err)
+	}
+	store.reads = 0
+	token, err := flow.CheckDeviceCode(ctx, code.DeviceCode)
+	if err != nil {
+		t.Fatalf("CheckDeviceCode failed: %v", err)
+	}
+	if token.AccessToken != "at" {
+		t.Errorf("access token = %q, want at", token.AccessToken)
+	}
+	if store.reads != 1 {
+		t.Errorf("authorized poll made %d reads, want 1", store.reads)
+	}
+}
+
+func TestExpiryBounds(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want time.Duration
+	}{
+		{name: "default", want: MinExpiryDuration},
+		{name: "within bounds", opts: []Option{WithExpiryDuration(20 * time.Minute)}, want: 20 * time.Minute},
+		{name: "below minimum", opts: []Option{WithExpiryDuration(time.Minute)}, want: MinExpiryDuration},
+		{name: "above default cap", opts: []Option{WithExpiryDuration(6 * time.Hour)}, want: ttl.DefaultMaxDeviceCode},
+		{
+			name: "raised cap",
+			opts: []Option{WithExpiryDuration(time.Hour), WithMaxExpiryDuration(2 * time.Hour)},
+			want: time.Hour,
+		},
+		{
+			name: "policy cap",
+			opts: []Option{WithTTLPolicy(ttl.Policy{DeviceCode: time.Hour, MaxDeviceCode: 45 * time.Minute})},
+			want: 45 * time.Minute,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flow := NewFlow(newMockStore(), "https://example.com", tt.opts...)
+			code, err := flow.RequestDeviceCode(context.Background(), "tv", "")
+			if err != nil {
+				t.Fatalf("RequestDeviceCode failed: %v", err)
+			}
+			if got := time.Duration(code.ExpiresIn) * time.Second; got != tt.want {
+				t.Errorf("expires_in = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package deviceflow
+
+import "strings"
+
+// descriptionTranslations translates the standard error descriptions, keyed
+// by primary language subtag. Descriptions without a translation, such as
+// those relayed from the authorization server, are sent in English.
+var descriptionTranslations = map[string]map[string]string{
+	"de": {
+		ErrorDescMissingClientID:        "Der Parameter client_id ist ERFORDERLICH",
+		ErrorDescDuplicateParams:        "Parameter DÜRFEN NICHT mehrfach angegeben werden",
+		ErrorDescInvalidRequestFormat:   "Ungültiges Anfrageformat",
+		ErrorDescMissingGrantType:       "Der Parameter grant_type ist ERFORDERLICH",
+		ErrorDescMissingDeviceCode:      "Der Parameter device_code ist ERFORDERLICH",
+		ErrorDescUnsupportedGrant:       "Nur urn:ietf:params:oauth:grant-type:device_code wird unterstützt",
+		ErrorDescAuthorizationPending:   "Die Autorisierungsanfrage steht noch aus",
+		ErrorDescSlowDown:               "Das Abfrageintervall muss auf das angegebene Intervall erhöht werden",
+		ErrorDescAccessDenied:           "Der Benutzer hat die Autorisierungsanfrage abgelehnt",
+		ErrorDescExpiredToken:           "Der device_code ist abgelaufen",
+		ErrorDescInvalidDeviceCode:      "Der device_code ist ungültig oder fehlerhaft",
+		ErrorDescServerError:            "Ein unerwarteter Fehler ist aufgetreten",
+		ErrorDescInvalidScope:           "Der angeforderte Scope ist ungültig, unbekannt oder fehlerhaft",
+		ErrorDescTemporarilyUnavailable: "Der Autorisierungsserver ist vorübergehend nicht verfügbar",
+		ErrorDescCapacityExceeded:       "Zu viele ausstehende Autorisierungsanfragen, bitte später erneut versuchen",
+		ErrorDescStoreUnavailable:       "Die Geräteautorisierung ist vorübergehend nicht verfügbar, bitte später erneut versuchen",
+		ErrorDescInvalidClient:          "Die Client-Authentifizierung ist fehlgeschlagen",
+		ErrorDescCodeConsumed:           "Der device_code wurde bereits verwendet",
+		ErrorDescCodeDeleted:            "Der device_code ist nicht mehr gültig",
+	},
+	"es": {
+		ErrorDescMissingClientID:        "El parámetro client_id es OBLIGATORIO",
+		ErrorDescDuplicateParams:        "Los parámetros NO DEBEN incluirse más de una vez",
+		ErrorDescInvalidRequestFormat:   "Formato de solicitud no válido",
+		ErrorDescMissingGrantType:       "El parámetro grant_type es OBLIGATORIO",
+		ErrorDescMissingDeviceCode:      "El parámetro device_code es OBLIGATORIO",
+		ErrorDescUnsupportedGrant:       "Solo se admite urn:ietf:params:oauth:grant-type:device_code",
+		ErrorDescAuthorizationPending:   "La solicitud de autorización sigue pendiente",
+		ErrorDescSlowDown:               "El intervalo de sondeo debe aumentarse al intervalo indicado",
+		ErrorDescAccessDenied:           "El usuario denegó la solicitud de autorización",
+		ErrorDescExpiredToken:           "El device_code ha caducado",
+		ErrorDescInvalidDeviceCode:      "El device_code no es válido o está mal formado",
+		ErrorDescServerError:            "Se produjo un error inesperado",
+		ErrorDescInvalidScope:           "El alcance solicitado no es válido, es desconocido o está mal formado",
+		ErrorDescTemporarilyUnavailable: "El servidor de autorización no está disponible temporalmente",
+		ErrorDescCapacityExceeded:       "Demasiadas solicitudes de autorización pendientes, inténtelo más tarde",
+		ErrorDescStoreUnavailable:       "La autorización de dispositivos no está disponible temporalmente, inténtelo más tarde",
+		ErrorDescInvalidClient:          "La autenticación del cliente falló",
+		ErrorDescCodeConsumed:           "El device_code ya se ha utilizado",
+		ErrorDescCodeDeleted:            "El device_code ya no es válido",
+	},
+	"fr": {
+		ErrorDescMissingClientID:        "Le paramètre client_id est OBLIGATOIRE",
+		ErrorDescDuplicateParams:        "Les paramètres NE DOIVENT PAS être inclus plus d'une fois",
+		ErrorDescInvalidRequestFormat:   "Format de requête non valide",
+		ErrorDescMissingGrantType:       "Le paramètre grant_type est OBLIGATOIRE",
+		ErrorDescMissingDeviceCode:      "Le paramètre device_code est OBLIGATOIRE",
+		ErrorDescUnsupportedGrant:       "Seul urn:ietf:params:oauth:grant-type:device_code est pris en charge",
+		ErrorDescAuthorizationPending:   "La demande d'autorisation est toujours en attente",
+		ErrorDescSlowDown:               "L'intervalle d'interrogation doit être augmenté à l'intervalle indiqué",
+		ErrorDescAccessDenied:           "L'utilisateur a refusé la demande d'autorisation",
+		ErrorDescExpiredToken:           "Le device_code a expiré",
+		ErrorDescInvalidDeviceCode:      "Le device_code est invalide ou mal formé",
+		ErrorDescServerError:            "Une erreur inattendue s'est produite",
+		ErrorDescInvalidScope:           "La portée demandée est invalide, inconnue ou mal formée",
+		ErrorDescTemporarilyUnavailable: "Le serveur d'autorisation est temporairement indisponible",
+		ErrorDescCapacityExceeded:       "Trop de demandes d'autorisation en attente, réessayez plus tard",
+		ErrorDescStoreUnavailable:       "L'autorisation des appareils est temporairement indisponible, réessayez plus tard",
+		ErrorDescInvalidClient:          "L'authentification du client a échoué",
+		ErrorDescCodeConsumed:           "Le device_code a déjà été utilisé",
+		ErrorDescCodeDeleted:            "Le device_code n'est plus valide",
+	},
+}
+
+// LocalizeDescription translates a standard error description into the first
+// of the preferred languages, most preferred first, that the proxy supports.
+// It reports the language of the description returned, which is "en" when the
+// description is left untranslated.
+func LocalizeDescription(description string, languages []string) (string, string) {
+	for _, lang := range languages {
+		primary, _, _ := strings.Cut(strings.ToLower(lang), "-")
+		if primary == "en" {
+			break
+		}
+		translations, ok := descriptionTranslations[primary]
+		if !ok {
+			continue
+		}
+		if translated, ok := translations[description]; ok {
+			return translated, primary
+		}
+		break
+	}
+	return description, "en"
+}
+
+// Localized returns a copy of the error with its description translated into
+// the first supported of the preferred languages
+func (e *DeviceFlowError) Localized(languages []string) *DeviceFlowError {
+	localized := *e
+	localized.Description, _ = LocalizeDescription(e.Description, languages)
+	return &localized
+}
+
+// ErrorURI returns the help page for an error code under base, which is
+// joined to the code as is, or "" when there is no base
+func ErrorURI(base, code string) string {
+	if base == "" || code == "" {
+		return ""
+	}
+	return base + code
+}
+
+// WithURIBase returns a copy of the error linking to its help page under base
+// per RFC 6749 section 5.2, keeping a URI the error already carries
+func (e *DeviceFlowError) WithURIBase(base string) *DeviceFlowError {
+	linked := *e
+	if linked.URI == "" {
+		linked.URI = ErrorURI(base, e.Code)
+	}
+	return &linked
+}
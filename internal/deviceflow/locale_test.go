@@ -0,0 +1,21 @@
+package deviceflow
+
+import "testing"
+
+func TestDeviceFlowErrorLocalized(t *testing.T) {
+	localized := ErrAccessDenied.Localized([]string{"it", "es-MX"}).WithURIBase("https://docs.example.com/errors/")
+	if want := "El usuario denegó la solicitud de autorización"; localized.Description != want {
+		t.Errorf("Description = %q, want %q", localized.Description, want)
+	}
+	if want := "https://docs.example.com/errors/access_denied"; localized.URI != want {
+		t.Errorf("URI = %q, want %q", localized.URI, want)
+	}
+	if ErrAccessDenied.Description != ErrorDescAccessDenied || ErrAccessDenied.URI != "" {
+		t.Errorf("Localized modified the shared error: %+v", ErrAccessDenied)
+	}
+
+	linked := &DeviceFlowError{Code: ErrorCodeExpiredToken, URI: "https://idp.example.com/help"}
+	if got := linked.WithURIBase("https://docs.example.com/errors/").URI; got != linked.URI {
+		t.Errorf("WithURIBase replaced URI %q with %q", linked.URI, got)
+	}
+}
@@ -22,7 +22,73 @@ type DeviceCode struct {
 	ExpiresAt time.Time `json:"expires_at"` // Absolute expiry time
 	ClientID  string    `json:"client_id"`  // OAuth2 client identifier
 	Scope     string    `json:"scope"`      // OAuth2 scope
-	LastPoll  time.Time `json:"last_poll"`  // Last poll timestamp
+	LastPoll  time.Time `json:"last_poll"`  // Polling baseline at creation, later polls are tracked by the store
+
+	// Timeline of the flow, each zero until the step happens. RequestedAt is
+	// stored as issued_at for compatibility and is zero for codes stored by
+	// older versions.
+	RequestedAt  time.Time `json:"issued_at"`               // Device authorization request
+	VerifiedAt   time.Time `json:"verified_at,omitempty"`   // User code first entered
+	AuthorizedAt time.Time `json:"authorized_at,omitempty"` // Token stored after user approval
+	ConsumedAt   time.Time `json:"consumed_at,omitempty"`   // Token first delivered to the device
+
+	// Tombstone is set on records read back from a tombstone after the code
+	// itself is gone, giving the reason, e.g. TombstoneConsumed
+	Tombstone string `json:"-"`
+
+	// Denied is set when the user rejects the request at the authorization server
+	Denied bool `json:"denied,omitempty"`
+
+	// Failure holds a terminal upstream error relayed to the polling device
+	Failure *DeviceFlowError `json:"failure,omitempty"`
+
+	// RequestIP and RequestLocation describe where the device authorization request
+	// came from, shown to users to help spot remote phishing per RFC 8628 section 5.4
+	RequestIP       string `json:"request_ip,omitempty"`
+	RequestLocation string `json:"request_location,omitempty"`
+
+	// RequestUserAgent is the User-Agent of the device authorization request,
+	// compared with later token requests to detect leaked device codes
+	RequestUserAgent string `json:"request_user_agent,omitempty"`
+
+	// Anomaly is set when the code was polled by a client unlike the requester
+	Anomaly *PollAnomaly `json:"anomaly,omitempty"`
+
+	// Device identifies the requesting hardware when the client supplied it
+	Device *DeviceIdentity `json:"device,omitempty"`
+
+	// Resources are the RFC 8707 resource indicators the tokens are requested for
+	Resources []string `json:"resources,omitempty"`
+
+	// AuthParams are allowlisted parameters of the device authorization request
+	// forwarded to the upstream authorization and token requests
+	AuthParams map[string]string `json:"auth_params,omitempty"`
+
+	// ClientAuthenticated is set when the device authorization request was
+	// authenticated, so that token requests for the code must be too
+	ClientAuthenticated bool `json:"client_authenticated,omitempty"`
+
+	// UserCodeRefreshes counts how often the device replaced its user code
+	UserCodeRefreshes int `json:"user_code_refreshes,omitempty"`
+
+	// CallbackURI receives the authorization outcome when the device asked for it
+	CallbackURI string `json:"callback_uri,omitempty"`
+
+	// BatchID links codes pre-generated through the admin API to their batch
+	BatchID string `json:"batch_id,omitempty"`
+
+	// Nonce is sent with the authorization request and must be echoed in the
+	// ID token per OpenID Connect Core 1.0 section 3.1.2.1. Set only when the
+	// scope includes openid.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// DeviceIdentity is a device identifier and optional attestation asserted by the
+// client in its device authorization request. The proxy records and displays it
+// but does not verify the attestation; consumers that trust its issuer can.
+type DeviceIdentity struct {
+	ID          string `json:"id,omitempty"`          // Hardware serial or similar identifier
+	Attestation string `json:"attestation,omitempty"` // Opaque attestation such as a signed JWT
 }
 
 // TokenResponse represents the OAuth2 token response per RFC 8628 section 3.5
@@ -32,4 +98,29 @@ type TokenResponse struct {
 	ExpiresIn    int    `json:"expires_in"`              // Token validity in seconds
 	RefreshToken string `json:"refresh_token,omitempty"` // Optional refresh token
 	Scope        string `json:"scope,omitempty"`         // OAuth2 scope granted
+	IDToken      string `json:"id_token,omitempty"`      // OpenID Connect ID token, when requested
+
+	// Identity holds claims from the validated ID token. It is kept with the
+	// authorization but never sent to the device.
+	Identity *Identity `json:"-"`
+
+	// Offline marks a refresh token that outlives the user's session, such as
+	// a Keycloak offline token. It is recorded with the authorization but
+	// never sent to the device.
+	Offline bool `json:"-"`
+}
+
+// Identity is the authorizing user as asserted by a validated ID token
+type Identity struct {
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+// SubmissionResult records the outcome of a verification form submission so that
+// duplicate posts of the same form can be answered without re-processing
+type SubmissionResult struct {
+	Pending    bool   `json:"pending,omitempty"`     // Submission is still being processed
+	DeviceCode string `json:"device_code,omitempty"` // Set when the user code was verified
+	Error      string `json:"error,omitempty"`       // Set when verification failed
 }
@@ -3,6 +3,11 @@ package deviceflow
 
 import (
 	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+	"github.com/wrale/oauth2-device-proxy/internal/features"
+	"github.com/wrale/oauth2-device-proxy/internal/ttl"
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
 )
 
 // Option configures the device flow implementation
@@ -16,6 +21,25 @@ func WithExpiryDuration(d time.Duration) Option {
 	}
 }
 
+// WithMaxExpiryDuration caps the code expiry duration, ttl.DefaultMaxDeviceCode
+// by default. Batch codes are capped the same way unless WithMaxBatchExpiry
+// allows them longer.
+func WithMaxExpiryDuration(d time.Duration) Option {
+	return func(f *flowImpl) {
+		if d > 0 {
+			f.maxExpiryDuration = d
+		}
+	}
+}
+
+// WithMaxBatchExpiry lets pre-generated batch codes outlive the code expiry
+// cap, up to MaxBatchExpiry
+func WithMaxBatchExpiry(d time.Duration) Option {
+	return func(f *flowImpl) {
+		f.maxBatchExpiry = d
+	}
+}
+
 // WithPollInterval sets the minimum polling interval
 // per RFC 8628 section 3.5, clients must wait between polling attempts
 func WithPollInterval(d time.Duration) Option {
@@ -24,6 +48,16 @@ func WithPollInterval(d time.Duration) Option {
 	}
 }
 
+// WithIntervalGrowth sets how a device's polling interval grows each time it
+// is told to slow down, FixedGrowth(SlowDownIncrement) by default
+func WithIntervalGrowth(growth IntervalGrowth) Option {
+	return func(f *flowImpl) {
+		if growth != nil {
+			f.intervalGrowth = growth
+		}
+	}
+}
+
 // WithUserCodeLength sets the user code length
 // length must be compatible with RFC 8628 section 6.1 requirements
 func WithUserCodeLength(length int) Option {
@@ -40,3 +74,107 @@ func WithRateLimit(window time.Duration, maxPolls int) Option {
 		f.maxPollsPerMin = maxPolls
 	}
 }
+
+// WithSubmissionWindow sets how long verification form results are retained
+// so that duplicate submissions return the original result
+func WithSubmissionWindow(d time.Duration) Option {
+	return func(f *flowImpl) {
+		f.submissionWindow = d
+	}
+}
+
+// WithEventEmitter sets the emitter notified of flow lifecycle events
+func WithEventEmitter(emitter events.Emitter) Option {
+	return func(f *flowImpl) {
+		if emitter != nil {
+			f.events = emitter
+		}
+	}
+}
+
+// WithCompleteURIPolicy sets which clients receive verification_uri_complete.
+// Per RFC 8628 section 3.3.1 the complete URI is optional, and omitting it forces
+// users to type and compare the code shown on the device.
+func WithCompleteURIPolicy(policy CompleteURIPolicy) Option {
+	return func(f *flowImpl) {
+		if policy != nil {
+			f.completeURIPolicy = policy
+		}
+	}
+}
+
+// WithCompleteURITemplate issues verification_uri_complete from a template
+// containing UserCodePlaceholder, such as a universal link
+// "https://auth.example.com/a/{user_code}" or a custom scheme
+// "exampleapp://device?code={user_code}", so that mobile apps can intercept the
+// link and prefill the code. Validate templates with ValidateCompleteURITemplate.
+func WithCompleteURITemplate(tmpl string) Option {
+	return func(f *flowImpl) {
+		f.completeURITemplate = tmpl
+	}
+}
+
+// WithCodeDisplayFormat sets how user codes are laid out in
+// verification_uri_complete. Codes are stored and issued as user_code in the
+// canonical XXXX-XXXX format whatever the display format.
+func WithCodeDisplayFormat(format validation.DisplayFormat) Option {
+	return func(f *flowImpl) {
+		f.codeFormat = format
+	}
+}
+
+// WithMaxOutstandingCodes caps the number of pending device codes across all
+// clients. Requests beyond the cap are shed with ErrCapacityExceeded. Zero
+// disables the cap.
+func WithMaxOutstandingCodes(n int) Option {
+	return func(f *flowImpl) {
+		f.maxOutstanding = n
+	}
+}
+
+// WithMaxCodesPerClient caps the number of unexpired device codes each client
+// may hold, including authorized codes whose token awaits pickup. Requests
+// beyond the quota are shed with ErrClientQuotaExceeded. Zero disables it.
+func WithMaxCodesPerClient(n int) Option {
+	return func(f *flowImpl) {
+		f.maxPerClient = n
+	}
+}
+
+// WithTTLPolicy applies the code expiry and its caps, rate limit window and
+// submission window from a TTL policy, keeping the flow consistent with the store
+func WithTTLPolicy(policy ttl.Policy) Option {
+	return func(f *flowImpl) {
+		f.expiryDuration = policy.DeviceCode
+		f.maxExpiryDuration = policy.DeviceCodeCap()
+		f.maxBatchExpiry = policy.BatchCodeCap()
+		f.rateLimitWindow = policy.RateLimitWindow
+		f.submissionWindow = policy.Submission
+	}
+}
+
+// WithAnomalyReverification requires users to approve again, with a warning,
+// when a device code is polled by a client unlike the one that requested it.
+// Without it such codes are only flagged and reported.
+func WithAnomalyReverification(enabled bool) Option {
+	return func(f *flowImpl) {
+		f.anomalyReverify = enabled
+	}
+}
+
+// WithFeatures gates flow behavior behind feature flags. When set, the
+// AnomalyReverify flag decides per client whether anomalous codes require
+// approval again, in place of WithAnomalyReverification.
+func WithFeatures(flags *features.Set) Option {
+	return func(f *flowImpl) {
+		f.features = flags
+	}
+}
+
+// WithTokenEnricher sets a hook that may augment or replace each token
+// response in CompleteAuthorization before it is stored for the device
+func WithTokenEnricher(enricher TokenEnricher) Option {
+	return func(f *flowImpl) {
+		f.enricher = enricher
+	}
+}
@@ -0,0 +1,64 @@
+package deviceflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/ttl"
+)
+
+func TestCompleteURIPolicy(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com",
+		WithCompleteURIPolicy(func(clientID string) bool {
+			return clientID != "kiosk"
+		}),
+	)
+
+	tests := []struct {
+		clientID     string
+		wantComplete bool
+	}{
+		{clientID: "kiosk", wantComplete: false},
+		{clientID: "tv", wantComplete: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.clientID, func(t *testing.T) {
+			code, err := flow.RequestDeviceCode(ctx, tt.clientID, "")
+			if err != nil {
+				t.Fatalf("RequestDeviceCode failed: %v", err)
+			}
+			if got := code.VerificationURIComplete != ""; got != tt.wantComplete {
+				t.Errorf("verification_uri_complete present = %v, want %v", got, tt.wantComplete)
+			}
+			if code.VerificationURI == "" {
+				t.Error("verification_uri is required regardless of policy")
+			}
+			if got := flow.AllowsCompleteURI(ctx, code.UserCode); got != tt.wantComplete {
+				t.Errorf("AllowsCompleteURI = %v, want %v", got, tt.wantComplete)
+			}
+		})
+	}
+
+	// Unknown codes use the policy for an anonymous client
+	if !flow.AllowsCompleteURI(ctx, "BCDF-GHJK") {
+		t.Error("AllowsCompleteURI should follow the default policy for unknown codes")
+	}
+}
+
+func TestTTLPolicy(t *testing.T) {
+	policy := ttl.Default()
+	policy.DeviceCode = 20 * time.Minute
+
+	flow := NewFlow(newMockStore(), "https://example.com", WithTTLPolicy(policy))
+	code, err := flow.RequestDeviceCode(context.Background(), "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if code.ExpiresIn != int(policy.DeviceCode.Seconds()) {
+		t.Errorf("ExpiresIn = %d, want %d", code.ExpiresIn, int(policy.DeviceCode.Seconds()))
+	}
+}
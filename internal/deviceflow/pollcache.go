@@ -0,0 +1,106 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// DefaultPollCacheTTL is how long a PollCache remembers allowed polls
+const DefaultPollCacheTTL = time.Minute
+
+// errWatchUnsupported is returned by Watch when the backend cannot watch codes
+var errWatchUnsupported = errors.New("store does not support watching device codes")
+
+// PollCache answers polls that arrive before their interval has elapsed from
+// memory, without a store round trip. It remembers when the store last
+// allowed a poll of each code through this replica; since the store only ever
+// moves that time forward, a poll too early against the remembered time is
+// too early against the store's as well. Polls that may be allowed always go
+// to the store, so the interval and window limits stay shared by all replicas.
+type PollCache struct {
+	Store
+
+	ttl time.Duration
+	now func() time.Time
+
+	mu     sync.Mutex
+	polls  map[string]time.Time
+	pruned time.Time
+}
+
+// NewPollCache wraps a store with a poll decision cache. Entries are kept for
+// ttl, DefaultPollCacheTTL when zero, so intervals longer than it always go
+// to the store.
+func NewPollCache(store Store, ttl time.Duration) *PollCache {
+	if ttl <= 0 {
+		ttl = DefaultPollCacheTTL
+	}
+	return &PollCache{
+		Store: store,
+		ttl:   ttl,
+		now:   time.Now,
+		polls: make(map[string]time.Time),
+	}
+}
+
+// RecordPoll implements Store, refusing polls within the interval of the last
+// poll allowed through this replica without asking the store
+func (c *PollCache) RecordPoll(ctx context.Context, code *DeviceCode, limit PollLimit) (bool, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	last, ok := c.polls[code.DeviceCode]
+	c.mu.Unlock()
+	if ok && now.Sub(last) < min(limit.Interval, c.ttl) {
+		cachedPollDecisions.Inc()
+		return false, nil
+	}
+
+	allowed, err := c.Store.RecordPoll(ctx, code, limit)
+	if err == nil && allowed {
+		c.remember(code.DeviceCode, now)
+	}
+	return allowed, err
+}
+
+// Transact implements Transactor, using the backend's transactions when it
+// has them
+func (c *PollCache) Transact(ctx context.Context, fn func(tx Tx) error) error {
+	return transact(ctx, c.Store, fn)
+}
+
+// Watch implements Watcher when the backend does
+func (c *PollCache) Watch(ctx context.Context, deviceCode string) (<-chan struct{}, error) {
+	if watcher, ok := c.Store.(Watcher); ok {
+		return watcher.Watch(ctx, deviceCode)
+	}
+	return nil, errWatchUnsupported
+}
+
+// remember records an allowed poll, dropping entries older than the TTL at
+// most once per TTL. The time is taken before the store call, so it never runs
+// ahead of the store's.
+func (c *PollCache) remember(deviceCode string, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if at.Sub(c.pruned) >= c.ttl {
+		for key, last := range c.polls {
+			if at.Sub(last) >= c.ttl {
+				delete(c.polls, key)
+			}
+		}
+		c.pruned = at
+	}
+	c.polls[deviceCode] = at
+}
+
+// cachedPollDecisions counts polls refused from memory
+var cachedPollDecisions = metrics.Default.NewCounter(
+	"device_proxy_cached_poll_decisions_total",
+	"Token polls told to slow down from the local poll cache without a store round trip.",
+)
@@ -0,0 +1,83 @@
+package deviceflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// countingStore counts the polls that reach the backend
+type countingStore struct {
+	*mockStore
+	polls int
+}
+
+func (s *countingStore) RecordPoll(ctx context.Context, code *DeviceCode, limit PollLimit) (bool, error) {
+	s.polls++
+	return s.mockStore.RecordPoll(ctx, code, limit)
+}
+
+func TestPollCache(t *testing.T) {
+	ctx := context.Background()
+	backend := &countingStore{mockStore: newMockStore()}
+	cache := NewPollCache(backend, 30*time.Second)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	code := &DeviceCode{DeviceCode: "dev-1", ExpiresAt: now.Add(10 * time.Minute), LastPoll: now.Add(-time.Minute)}
+	if err := backend.SaveDeviceCode(ctx, code); err != nil {
+		t.Fatalf("SaveDeviceCode failed: %v", err)
+	}
+	limit := PollLimit{Interval: 5 * time.Second}
+
+	poll := func(want bool, wantBackend int) {
+		t.Helper()
+		allowed, err := cache.RecordPoll(ctx, code, limit)
+		if err != nil {
+			t.Fatalf("RecordPoll failed: %v", err)
+		}
+		if allowed != want || backend.polls != wantBackend {
+			t.Errorf("RecordPoll = %v with %d backend polls, want %v with %d", allowed, backend.polls, want, wantBackend)
+		}
+	}
+
+	// The first poll goes to the store, and early polls after it do not
+	poll(true, 1)
+	now = now.Add(time.Second)
+	poll(false, 1)
+	now = now.Add(2 * time.Second)
+	poll(false, 1)
+
+	// Once the interval has passed the store decides again. The mock store
+	// limits polls by wall clock time, so backdate its record of the last poll.
+	now = now.Add(5 * time.Second)
+	backend.deviceCodes["dev-1"].LastPoll = time.Now().Add(-time.Minute)
+	poll(true, 2)
+
+	// Intervals longer than the TTL are not answered from memory
+	limit.Interval = time.Minute
+	now = now.Add(45 * time.Second)
+	poll(false, 3)
+}
+
+func TestPollCachePrunes(t *testing.T) {
+	ctx := context.Background()
+	cache := NewPollCache(newMockStore(), time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	for _, deviceCode := range []string{"dev-1", "dev-2"} {
+		code := &DeviceCode{DeviceCode: deviceCode, ExpiresAt: now.Add(10 * time.Minute), LastPoll: time.Now().Add(-time.Minute)}
+		if err := cache.SaveDeviceCode(ctx, code); err != nil {
+			t.Fatalf("SaveDeviceCode failed: %v", err)
+		}
+		if allowed, err := cache.RecordPoll(ctx, code, PollLimit{Interval: 5 * time.Second}); err != nil || !allowed {
+			t.Fatalf("RecordPoll = %v, %v, want allowed", allowed, err)
+		}
+		now = now.Add(2 * time.Minute)
+	}
+
+	if _, ok := cache.polls["dev-1"]; ok || len(cache.polls) != 1 {
+		t.Errorf("cached polls = %v, want only dev-2", cache.polls)
+	}
+}
@@ -7,9 +7,14 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/wrale/oauth2-device-proxy/internal/envelope"
+	"github.com/wrale/oauth2-device-proxy/internal/keyspace"
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+	"github.com/wrale/oauth2-device-proxy/internal/ttl"
 	"github.com/wrale/oauth2-device-proxy/internal/validation"
 )
 
@@ -19,19 +24,73 @@ const (
 	tokenPrefix     = "token:"
 	ratePrefix      = "rate:"
 	pollPrefix      = "poll:"
-	maxAttempts     = 50  // Maximum verification attempts per device code per RFC 8628 section 5.2
-	rateLimitWindow = 5   // Time window in minutes for rate limit tracking
-	errorBackoff    = 300 // Error backoff in seconds when rate limit exceeded (per RFC 8628)
+	submitPrefix    = "submit:"
+	batchPrefix     = "batch:"
+	consentPrefix   = "consent:"
+	callbackPrefix  = "callback:"
+	clientPrefix    = "client:"
+	pendingKey      = "pending"       // Sorted set of pending device codes scored by expiry
+	batchPendingKey = "pending:batch" // As pendingKey for batch codes, kept out of the outstanding code cap
+	maxAttempts     = 50              // Maximum verification attempts per device code per RFC 8628 section 5.2
+	errorBackoff    = 300             // Error backoff in seconds when rate limit exceeded (per RFC 8628)
 )
 
 // RedisStore implements the Store interface using Redis
 type RedisStore struct {
 	client *redis.Client
+	ttl    ttl.Policy
+	prefix string           // Namespace prepended to every key
+	sealer *envelope.Sealer // Encrypts sensitive records, nil stores plaintext
+
+	watches *watchHub
+}
+
+// StoreOption configures the Redis store
+type StoreOption func(*RedisStore)
+
+// WithStoreTTLPolicy sets the lifetimes applied to stored tokens and poll history
+func WithStoreTTLPolicy(policy ttl.Policy) StoreOption {
+	return func(s *RedisStore) {
+		s.ttl = policy
+	}
+}
+
+// WithKeyPrefix namespaces every key written by the store, so that several
+// proxy environments can share one Redis instance
+func WithKeyPrefix(prefix string) StoreOption {
+	return func(s *RedisStore) {
+		s.prefix = prefix
+	}
 }
 
 // NewRedisStore creates a new Redis-backed store
-func NewRedisStore(client *redis.Client) Store {
-	return &RedisStore{client: client}
+func NewRedisStore(client *redis.Client, opts ...StoreOption) Store {
+	s := &RedisStore{client: client, ttl: ttl.Default(), watches: &watchHub{}}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// key builds a namespaced key from its parts
+func (s *RedisStore) key(parts ...string) string {
+	return s.prefix + strings.Join(parts, "")
+}
+
+// timeKey holds the last poll time of a device code in milliseconds
+func (s *RedisStore) timeKey(deviceCode string) string {
+	return s.key(ratePrefix, deviceCode, ":time")
+}
+
+// pollKey holds the poll and verification attempt history of a device code
+func (s *RedisStore) pollKey(deviceCode string) string {
+	return s.key(pollPrefix, deviceCode)
+}
+
+// pattern builds a SCAN match pattern within the namespace, escaping any glob
+// characters in the prefix itself
+func (s *RedisStore) pattern(parts ...string) string {
+	return keyspace.EscapeGlob(s.prefix) + strings.Join(parts, "")
 }
 
 // CheckHealth verifies Redis connectivity
@@ -42,8 +101,66 @@ func (s *RedisStore) CheckHealth(ctx context.Context) error {
 	return nil
 }
 
-// SaveDeviceCode stores a device code with expiration
+// SaveDeviceCode stores a device code with expiration. The record, its user
+// code reference and its rate limit baseline are written in one transaction.
 func (s *RedisStore) SaveDeviceCode(ctx context.Context, code *DeviceCode) error {
+	return s.Transact(ctx, func(tx Tx) error {
+		return tx.SaveDeviceCode(code)
+	})
+}
+
+// Transact implements Transactor by queueing writes on a MULTI/EXEC pipeline
+func (s *RedisStore) Transact(ctx context.Context, fn func(tx Tx) error) error {
+	pipe := s.client.TxPipeline()
+	if err := fn(&redisTx{ctx: ctx, store: s, pipe: pipe}); err != nil {
+		pipe.Discard()
+		return err
+	}
+	if pipe.Len() == 0 {
+		return nil
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// redisTx queues writes on a transaction pipeline
+type redisTx struct {
+	ctx   context.Context
+	store *RedisStore
+	pipe  redis.Pipeliner
+}
+
+func (tx *redisTx) SaveDeviceCode(code *DeviceCode) error {
+	return tx.store.queueDeviceCode(tx.ctx, tx.pipe, code)
+}
+
+func (tx *redisTx) SaveBatch(batch *Batch) error {
+	return tx.store.queueBatch(tx.ctx, tx.pipe, batch)
+}
+
+// ReplaceUserCode saves a device code with a new user code, removing the
+// previous user code reference in the same transaction
+func (s *RedisStore) ReplaceUserCode(ctx context.Context, code *DeviceCode, previousUserCode string) error {
+	pipe := s.client.TxPipeline()
+	pipe.Del(ctx, s.key(userPrefix, validation.NormalizeCode(previousUserCode)), s.expiredUserKey(previousUserCode))
+	if err := s.queueDeviceCode(ctx, pipe, code); err != nil {
+		return err
+	}
+
+	// The rate limit baseline must live as long as the extended code
+	pipe.Expire(ctx, s.timeKey(code.DeviceCode), time.Until(code.ExpiresAt))
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("replacing user code: %w", err)
+	}
+	return nil
+}
+
+// queueDeviceCode adds the commands saving a device code to a pipeline
+func (s *RedisStore) queueDeviceCode(ctx context.Context, pipe redis.Pipeliner, code *DeviceCode) error {
 	// Calculate TTL based on expiry time
 	ttl := time.Until(code.ExpiresAt)
 	if ttl <= 0 {
@@ -51,37 +168,63 @@ func (s *RedisStore) SaveDeviceCode(ctx context.Context, code *DeviceCode) error
 	}
 
 	// Marshal the device code
-	data, err := json.Marshal(code)
+	data, err := s.encodeDeviceCode(ctx, code)
 	if err != nil {
 		return fmt.Errorf("marshaling device code: %w", err)
 	}
 
-	// Use pipeline to set all keys atomically
-	pipe := s.client.Pipeline()
-
 	// Set device code with expiry
-	deviceKey := devicePrefix + code.DeviceCode
+	deviceKey := s.key(devicePrefix, code.DeviceCode)
 	pipe.Set(ctx, deviceKey, data, ttl)
 
 	// Set user code reference
-	userKey := userPrefix + validation.NormalizeCode(code.UserCode)
+	userKey := s.key(userPrefix, validation.NormalizeCode(code.UserCode))
 	pipe.Set(ctx, userKey, code.DeviceCode, ttl)
 
-	// Initialize rate limit tracking
-	timeKey := fmt.Sprintf("%s%s:time", ratePrefix, code.DeviceCode)
-	pipe.Expire(ctx, timeKey, ttl) // Ensure cleanup
+	// Initialize rate limit tracking from the creation time, keeping any later
+	// poll. Authorized codes had their tracking removed with the token save.
+	authorized := !code.AuthorizedAt.IsZero() || !code.ConsumedAt.IsZero()
+	if !authorized {
+		pipe.SetNX(ctx, s.timeKey(code.DeviceCode), code.LastPoll.UnixMilli(), ttl)
+	}
 
-	// Execute all operations
-	if _, err := pipe.Exec(ctx); err != nil {
-		return fmt.Errorf("saving device code: %w", err)
+	// Track pending codes for the outstanding code cap and the janitor
+	pending := !code.Denied && code.Failure == nil && !authorized
+	if pending {
+		pipe.ZAdd(ctx, s.pendingSet(code), redis.Z{Score: float64(code.ExpiresAt.Unix()), Member: code.DeviceCode})
+	} else {
+		pipe.ZRem(ctx, s.pendingSet(code), code.DeviceCode)
+	}
+	if err := s.queueTombstone(ctx, pipe, code, tombstoneReason(code, false), ttl+s.ttl.ExpiredGrace); err != nil {
+		return err
+	}
+
+	// Index codes by client for listing and revoking a client's outstanding codes
+	if code.ClientID != "" {
+		pipe.ZAdd(ctx, s.key(clientPrefix, code.ClientID), redis.Z{Score: float64(code.ExpiresAt.Unix()), Member: code.DeviceCode})
+	}
+
+	// Wake devices waiting for the flow to end
+	if code.Denied || code.Failure != nil {
+		pipe.Publish(ctx, s.key(notifyPrefix, code.DeviceCode), "")
 	}
 
 	return nil
 }
 
+// pendingSet returns the sorted set tracking a code while it is pending.
+// Pre-generated batch codes may wait weeks for their device, so they are
+// tracked apart from the codes the outstanding code cap counts.
+func (s *RedisStore) pendingSet(code *DeviceCode) string {
+	if code.BatchID != "" {
+		return s.key(batchPendingKey)
+	}
+	return s.key(pendingKey)
+}
+
 // GetDeviceCode retrieves a device code
 func (s *RedisStore) GetDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error) {
-	data, err := s.client.Get(ctx, devicePrefix+deviceCode).Bytes()
+	data, err := s.client.Get(ctx, s.key(devicePrefix, deviceCode)).Bytes()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return nil, nil
@@ -89,26 +232,68 @@ func (s *RedisStore) GetDeviceCode(ctx context.Context, deviceCode string) (*Dev
 		return nil, fmt.Errorf("getting device code: %w", err)
 	}
 
-	var code DeviceCode
-	if err := json.Unmarshal(data, &code); err != nil {
-		return nil, fmt.Errorf("unmarshaling device code: %w", err)
-	}
-
-	return &code, nil
+	return s.decodeDeviceCode(ctx, deviceCode, data)
 }
 
 // GetDeviceCodeByUserCode retrieves a device code using the user code
 func (s *RedisStore) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*DeviceCode, error) {
-	// Get device code from user code reference
-	deviceCode, err := s.client.Get(ctx, userPrefix+validation.NormalizeCode(userCode)).Result()
+	// Get device code from user code reference, or the tombstone left by it
+	values, err := s.client.MGet(ctx, s.key(userPrefix, validation.NormalizeCode(userCode)), s.expiredUserKey(userCode)).Result()
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return nil, nil
-		}
 		return nil, fmt.Errorf("getting user code reference: %w", err)
 	}
+	deviceCode, ok := values[0].(string)
+	if !ok {
+		if data, ok := values[1].(string); ok {
+			return tombstoneCode(data, time.Now())
+		}
+		return nil, nil
+	}
+
+	code, err := s.GetDeviceCode(ctx, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read-repair: drop mappings left behind by partial writes or expiry races
+	if reason := mappingInconsistency(userCode, code); reason != "" {
+		storeInconsistencies.Inc(reason)
+		if err := repairUserMapping.Run(ctx, s.client,
+			[]string{s.key(userPrefix, validation.NormalizeCode(userCode))}, deviceCode).Err(); err != nil {
+			return nil, fmt.Errorf("repairing user code reference: %w", err)
+		}
+		return nil, nil
+	}
 
-	return s.GetDeviceCode(ctx, deviceCode)
+	return code, nil
+}
+
+// storeInconsistencies counts user code mappings found pointing at missing or mismatched records
+var storeInconsistencies = metrics.Default.NewCounter(
+	"device_proxy_store_inconsistencies_total",
+	"User code mappings repaired on read, by reason.",
+	"reason",
+)
+
+// repairUserMapping deletes a user code mapping only if it still references the
+// device code that was found to be inconsistent, so concurrent rewrites survive
+var repairUserMapping = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// mappingInconsistency reports why a user code mapping no longer matches the
+// device code record it resolves to, or "" when the pair is consistent
+func mappingInconsistency(userCode string, code *DeviceCode) string {
+	if code == nil {
+		return "missing_device_code"
+	}
+	if validation.NormalizeCode(code.UserCode) != validation.NormalizeCode(userCode) {
+		return "user_code_mismatch"
+	}
+	return ""
 }
 
 // SaveTokenResponse stores a token response for a device code per RFC 8628
@@ -122,40 +307,58 @@ func (s *RedisStore) SaveTokenResponse(ctx context.Context, deviceCode string, t
 		return ErrInvalidDeviceCode
 	}
 
-	// Check expiry
-	ttl := time.Until(code.ExpiresAt)
-	if ttl <= 0 {
+	// Keep the token no longer than the policy allows or the code lives
+	tokenTTL := s.ttl.TokenTTL(code.ExpiresAt)
+	if tokenTTL <= 0 {
 		return ErrExpiredCode
 	}
 
-	// Marshal token
-	data, err := json.Marshal(token)
+	// Marshal token along with the server-side identity claims
+	data, err := s.encodeToken(ctx, deviceCode, token)
 	if err != nil {
 		return fmt.Errorf("marshaling token response: %w", err)
 	}
 
-	// Use pipeline for atomic update
-	pipe := s.client.Pipeline()
-
-	// Save token with device code expiry
-	tokenKey := tokenPrefix + deviceCode
-	pipe.Set(ctx, tokenKey, data, ttl)
-
-	// Clean up rate limit data on success
-	timeKey := fmt.Sprintf("%s%s:time", ratePrefix, deviceCode)
-	pollKey := fmt.Sprintf("%s%s", pollPrefix, deviceCode)
-	pipe.Del(ctx, timeKey, pollKey)
-
-	if _, err := pipe.Exec(ctx); err != nil {
+	// Save the token only if none exists yet, cleaning up rate limit data on success
+	tokenKey := s.key(tokenPrefix, deviceCode)
+	timeKey := s.timeKey(deviceCode)
+	pollKey := s.pollKey(deviceCode)
+	saved, err := saveTokenOnce.Run(ctx, s.client,
+		[]string{tokenKey, timeKey, pollKey, s.pendingSet(code)},
+		data, tokenTTL.Milliseconds(), deviceCode).Int()
+	if err != nil {
 		return fmt.Errorf("saving token response: %w", err)
 	}
+	if saved == 0 {
+		return ErrAlreadyAuthorized
+	}
 
+	s.notify(ctx, deviceCode)
 	return nil
 }
 
+// storedToken is the persisted form of a token response, which also records
+// the identity claims excluded from the response sent to devices
+type storedToken struct {
+	*TokenResponse
+	Identity *Identity `json:"identity,omitempty"`
+	Offline  bool      `json:"offline,omitempty"`
+}
+
+// saveTokenOnce stores a token response unless one already exists, so that
+// concurrent completions of the same device code cannot both succeed
+var saveTokenOnce = redis.NewScript(`
+if not redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2], "NX") then
+	return 0
+end
+redis.call("DEL", KEYS[2], KEYS[3])
+redis.call("ZREM", KEYS[4], ARGV[3])
+return 1
+`)
+
 // GetTokenResponse retrieves a stored token response for a device code
 func (s *RedisStore) GetTokenResponse(ctx context.Context, deviceCode string) (*TokenResponse, error) {
-	data, err := s.client.Get(ctx, tokenPrefix+deviceCode).Bytes()
+	data, err := s.client.Get(ctx, s.key(tokenPrefix, deviceCode)).Bytes()
 	if err != nil {
 		if errors.Is(err, redis.Nil) {
 			return nil, nil
@@ -163,48 +366,162 @@ func (s *RedisStore) GetTokenResponse(ctx context.Context, deviceCode string) (*
 		return nil, fmt.Errorf("getting token response: %w", err)
 	}
 
-	var token TokenResponse
-	if err := json.Unmarshal(data, &token); err != nil {
-		return nil, fmt.Errorf("unmarshaling token response: %w", err)
+	return s.decodeToken(ctx, deviceCode, data)
+}
+
+// GetPollState reads a device code and its token response with a single MGET,
+// saving a round trip on every token request. A code that is gone is read
+// back from its tombstone within the grace window.
+func (s *RedisStore) GetPollState(ctx context.Context, deviceCode string) (*DeviceCode, *TokenResponse, error) {
+	values, err := s.client.MGet(ctx, s.key(devicePrefix, deviceCode), s.key(tokenPrefix, deviceCode), s.expiredKey(deviceCode)).Result()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting poll state: %w", err)
+	}
+
+	codeData, ok := values[0].(string)
+	if !ok {
+		if data, ok := values[2].(string); ok {
+			code, err := tombstoneCode(data, time.Now())
+			return code, nil, err
+		}
+		return nil, nil, nil
+	}
+	code, err := s.decodeDeviceCode(ctx, deviceCode, []byte(codeData))
+	if err != nil || code == nil {
+		return nil, nil, err
 	}
 
-	return &token, nil
+	tokenData, ok := values[1].(string)
+	if !ok {
+		return code, nil, nil
+	}
+	token, err := s.decodeToken(ctx, deviceCode, []byte(tokenData))
+	if err != nil {
+		return nil, nil, err
+	}
+	return code, token, nil
 }
 
 // DeleteDeviceCode removes a device code and associated data
 func (s *RedisStore) DeleteDeviceCode(ctx context.Context, deviceCode string) error {
-	// Get code first for user code cleanup
-	code, err := s.GetDeviceCode(ctx, deviceCode)
+	return s.BatchDeleteDeviceCodes(ctx, []string{deviceCode})
+}
+
+// BatchGetDeviceCodes reads several device codes with a single MGET
+func (s *RedisStore) BatchGetDeviceCodes(ctx context.Context, deviceCodes []string) ([]*DeviceCode, error) {
+	if len(deviceCodes) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(deviceCodes))
+	for i, deviceCode := range deviceCodes {
+		keys[i] = s.key(devicePrefix, deviceCode)
+	}
+	values, err := s.client.MGet(ctx, keys...).Result()
 	if err != nil {
-		return fmt.Errorf("getting device code: %w", err)
+		return nil, fmt.Errorf("getting device codes: %w", err)
 	}
-	if code == nil {
-		return nil // Already deleted
+
+	codes := make([]*DeviceCode, len(deviceCodes))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if codes[i], err = s.decodeDeviceCode(ctx, deviceCodes[i], []byte(data)); err != nil {
+			return nil, err
+		}
 	}
+	return codes, nil
+}
 
-	// Delete all associated keys atomically
-	pipe := s.client.Pipeline()
+// BatchDeleteDeviceCodes removes several device codes and their associated
+// keys in one transaction
+func (s *RedisStore) BatchDeleteDeviceCodes(ctx context.Context, deviceCodes []string) error {
+	// Get codes first for user code cleanup
+	codes, err := s.BatchGetDeviceCodes(ctx, deviceCodes)
+	if err != nil {
+		return fmt.Errorf("getting device codes: %w", err)
+	}
 
-	// Main keys
-	pipe.Del(ctx, devicePrefix+deviceCode)
-	pipe.Del(ctx, userPrefix+validation.NormalizeCode(code.UserCode))
-	pipe.Del(ctx, tokenPrefix+deviceCode)
+	pipe := s.client.TxPipeline()
+	for _, code := range codes {
+		if code == nil {
+			continue // Already deleted
+		}
+		deviceCode := code.DeviceCode
+
+		// Main keys
+		pipe.Del(ctx, s.key(devicePrefix, deviceCode))
+		pipe.Del(ctx, s.key(userPrefix, validation.NormalizeCode(code.UserCode)))
+		pipe.Del(ctx, s.key(tokenPrefix, deviceCode))
+		pipe.ZRem(ctx, s.pendingSet(code), deviceCode)
+		if time.Now().Before(code.ExpiresAt) {
+			// Expired codes swept by Cleanup keep the tombstone they have
+			if err := s.queueTombstone(ctx, pipe, code, tombstoneReason(code, true), s.ttl.ExpiredGrace); err != nil {
+				pipe.Discard()
+				return err
+			}
+		}
+		if code.ClientID != "" {
+			pipe.ZRem(ctx, s.key(clientPrefix, code.ClientID), deviceCode)
+		}
 
-	// Rate limit keys
-	timeKey := fmt.Sprintf("%s%s:time", ratePrefix, deviceCode)
-	pollKey := fmt.Sprintf("%s%s", pollPrefix, deviceCode)
-	pipe.Del(ctx, timeKey, pollKey)
+		// Rate limit keys
+		pipe.Del(ctx, s.timeKey(deviceCode), s.pollKey(deviceCode))
 
-	if _, err := pipe.Exec(ctx); err != nil {
-		return fmt.Errorf("deleting device code: %w", err)
+		// Wake devices waiting on the removed code
+		pipe.Publish(ctx, s.key(notifyPrefix, deviceCode), "")
+	}
+	if pipe.Len() == 0 {
+		return nil
 	}
 
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("deleting device codes: %w", err)
+	}
 	return nil
 }
 
+// ListDeviceCodesByClient prunes expired entries from the client's index and
+// returns the device codes left in it
+func (s *RedisStore) ListDeviceCodesByClient(ctx context.Context, clientID string) ([]string, error) {
+	indexKey := s.key(clientPrefix, clientID)
+	pipe := s.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, indexKey, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+	members := pipe.ZRange(ctx, indexKey, 0, -1)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("listing client device codes: %w", err)
+	}
+	return members.Val(), nil
+}
+
+// CountDeviceCodesByClient prunes expired entries from the client's index and
+// returns its size
+func (s *RedisStore) CountDeviceCodesByClient(ctx context.Context, clientID string) (int, error) {
+	indexKey := s.key(clientPrefix, clientID)
+	pipe := s.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, indexKey, "-inf", strconv.FormatInt(time.Now().Unix(), 10))
+	count := pipe.ZCard(ctx, indexKey)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("counting client device codes: %w", err)
+	}
+	return int(count.Val()), nil
+}
+
+// CountPendingDeviceCodes counts the unexpired entries of the pending set.
+// Expired entries are left for Cleanup, which reports them as it removes them.
+func (s *RedisStore) CountPendingDeviceCodes(ctx context.Context) (int, error) {
+	count, err := s.client.ZCount(ctx, s.key(pendingKey), "("+strconv.FormatInt(time.Now().Unix(), 10), "+inf").Result()
+	if err != nil {
+		return 0, fmt.Errorf("counting pending device codes: %w", err)
+	}
+	return int(count), nil
+}
+
 // GetPollCount gets the number of polls in the given window
 func (s *RedisStore) GetPollCount(ctx context.Context, deviceCode string, window time.Duration) (int, error) {
-	pollKey := fmt.Sprintf("%s%s", pollPrefix, deviceCode)
+	pollKey := s.pollKey(deviceCode)
 	windowSecs := int64(window.Seconds())
 	now := time.Now().Unix()
 	min := fmt.Sprintf("%d", now-windowSecs)
@@ -218,23 +535,165 @@ func (s *RedisStore) GetPollCount(ctx context.Context, deviceCode string, window
 	return int(count), nil
 }
 
-// UpdatePollTimestamp updates the last poll timestamp
-func (s *RedisStore) UpdatePollTimestamp(ctx context.Context, deviceCode string) error {
-	code, err := s.GetDeviceCode(ctx, deviceCode)
+// RecordPoll checks the poll interval and window limit and records the poll in
+// a single round trip, without rewriting the device code record
+func (s *RedisStore) RecordPoll(ctx context.Context, code *DeviceCode, limit PollLimit) (bool, error) {
+	timeKey := s.timeKey(code.DeviceCode)
+	pollKey := s.pollKey(code.DeviceCode)
+
+	ttl := time.Until(code.ExpiresAt)
+	if ttl <= 0 {
+		return false, ErrExpiredCode
+	}
+
+	allowed, err := recordPoll.Run(ctx, s.client,
+		[]string{timeKey, pollKey},
+		time.Now().UnixMilli(),
+		limit.Interval.Milliseconds(),
+		limit.Window.Milliseconds(),
+		limit.MaxPolls,
+		ttl.Milliseconds(),
+		s.ttl.RateLimitWindow.Milliseconds(),
+	).Int()
 	if err != nil {
-		return fmt.Errorf("getting device code: %w", err)
+		return false, fmt.Errorf("recording poll: %w", err)
 	}
-	if code == nil {
-		return ErrInvalidDeviceCode
+
+	return allowed == 1, nil
+}
+
+// recordPoll enforces the minimum poll interval against the last poll time in
+// KEYS[1] and the per-window limit against the poll history in KEYS[2],
+// recording the poll only when both allow it. Times are in milliseconds.
+var recordPoll = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local last = tonumber(redis.call("GET", KEYS[1]) or "0")
+if now - last < tonumber(ARGV[2]) then
+	return 0
+end
+
+local maxPolls = tonumber(ARGV[4])
+if maxPolls > 0 then
+	-- Poll history is scored in seconds, shared with verification attempts
+	local since = math.floor((now - tonumber(ARGV[3])) / 1000)
+	local count = redis.call("ZCOUNT", KEYS[2], since, "+inf")
+	if count >= maxPolls then
+		return 0
+	end
+end
+
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[5])
+redis.call("ZADD", KEYS[2], math.floor(now / 1000), ARGV[1])
+redis.call("PEXPIRE", KEYS[2], ARGV[6])
+return 1
+`)
+
+// maxWatchRetries bounds how often SavePollInterval and EndAuthorization
+// retry after another writer changed the device code under them
+const maxWatchRetries = 5
+
+// SavePollInterval raises the stored polling interval to code.Interval. The
+// record is read and rewritten under WATCH, so a write racing it, such as a
+// denial, is kept and the update retried on top of it.
+func (s *RedisStore) SavePollInterval(ctx context.Context, code *DeviceCode) error {
+	deviceKey := s.key(devicePrefix, code.DeviceCode)
+	update := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, deviceKey).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return nil // Gone, nothing left to slow down
+		}
+		if err != nil {
+			return err
+		}
+		stored, err := s.decodeDeviceCode(ctx, code.DeviceCode, data)
+		if err != nil || stored == nil || stored.Interval >= code.Interval {
+			return err
+		}
+		stored.Interval = code.Interval
+		data, err = s.encodeDeviceCode(ctx, stored)
+		if err != nil {
+			return fmt.Errorf("marshaling device code: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, deviceKey, data, redis.KeepTTL)
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < maxWatchRetries; attempt++ {
+		err := s.client.Watch(ctx, update, deviceKey)
+		if !errors.Is(err, redis.TxFailedErr) {
+			if err != nil {
+				return fmt.Errorf("saving poll interval: %w", err)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("saving poll interval: %w", redis.TxFailedErr)
+}
+
+// EndAuthorization denies or fails a pending device code. The record and
+// token are read under WATCH, so a token saved while the outcome is written
+// aborts the write and the retry finds it.
+func (s *RedisStore) EndAuthorization(ctx context.Context, deviceCode string, failure *DeviceFlowError) (*DeviceCode, error) {
+	deviceKey := s.key(devicePrefix, deviceCode)
+	tokenKey := s.key(tokenPrefix, deviceCode)
+	var ended *DeviceCode
+	update := func(tx *redis.Tx) error {
+		ended = nil
+		authorized, err := tx.Exists(ctx, tokenKey).Result()
+		if err != nil {
+			return err
+		}
+		if authorized > 0 {
+			return ErrAlreadyAuthorized
+		}
+
+		data, err := tx.Get(ctx, deviceKey).Bytes()
+		if errors.Is(err, redis.Nil) {
+			return nil // Gone, nothing left to end
+		}
+		if err != nil {
+			return err
+		}
+		stored, err := s.decodeDeviceCode(ctx, deviceCode, data)
+		if err != nil || stored == nil {
+			return err
+		}
+		if stored.Denied || stored.Failure != nil {
+			return ErrAuthorizationEnded
+		}
+		stored.Denied = failure == nil
+		stored.Failure = failure
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return s.queueDeviceCode(ctx, pipe, stored)
+		})
+		if err == nil {
+			ended = stored
+		}
+		return err
 	}
 
-	code.LastPoll = time.Now()
-	return s.SaveDeviceCode(ctx, code)
+	for attempt := 0; attempt < maxWatchRetries; attempt++ {
+		err := s.client.Watch(ctx, update, deviceKey, tokenKey)
+		if errors.Is(err, ErrAlreadyAuthorized) || errors.Is(err, ErrAuthorizationEnded) {
+			return nil, err
+		}
+		if !errors.Is(err, redis.TxFailedErr) {
+			if err != nil {
+				return nil, fmt.Errorf("ending authorization: %w", err)
+			}
+			return ended, nil
+		}
+	}
+	return nil, fmt.Errorf("ending authorization: %w", redis.TxFailedErr)
 }
 
 // IncrementPollCount increments the poll counter with timestamp
 func (s *RedisStore) IncrementPollCount(ctx context.Context, deviceCode string) error {
-	pollKey := fmt.Sprintf("%s%s", pollPrefix, deviceCode)
+	pollKey := s.pollKey(deviceCode)
 	now := time.Now().Unix()
 
 	// Add poll with score = timestamp
@@ -247,9 +706,150 @@ func (s *RedisStore) IncrementPollCount(ctx context.Context, deviceCode string)
 	}
 
 	// Set expiry on first poll
-	if err := s.client.Expire(ctx, pollKey, rateLimitWindow*time.Minute).Err(); err != nil {
+	if err := s.client.Expire(ctx, pollKey, s.ttl.RateLimitWindow).Err(); err != nil {
 		return fmt.Errorf("setting poll key expiry: %w", err)
 	}
 
 	return nil
 }
+
+// ClaimSubmission atomically claims a form nonce or returns its stored result
+func (s *RedisStore) ClaimSubmission(ctx context.Context, nonce string, ttl time.Duration) (*SubmissionResult, error) {
+	data, err := json.Marshal(&SubmissionResult{Pending: true})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling submission: %w", err)
+	}
+
+	key := s.key(submitPrefix, nonce)
+	claimed, err := s.client.SetNX(ctx, key, data, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("claiming submission: %w", err)
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	existing, err := s.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil // Expired between calls, treat as newly claimed
+		}
+		return nil, fmt.Errorf("getting submission: %w", err)
+	}
+
+	var result SubmissionResult
+	if err := json.Unmarshal(existing, &result); err != nil {
+		return nil, fmt.Errorf("unmarshaling submission: %w", err)
+	}
+
+	return &result, nil
+}
+
+// SaveSubmission stores the result of a form submission
+func (s *RedisStore) SaveSubmission(ctx context.Context, nonce string, result *SubmissionResult, ttl time.Duration) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("marshaling submission: %w", err)
+	}
+
+	if err := s.client.Set(ctx, s.key(submitPrefix, nonce), data, ttl).Err(); err != nil {
+		return fmt.Errorf("saving submission: %w", err)
+	}
+
+	return nil
+}
+
+// SaveBatch stores a batch until its latest code expires
+func (s *RedisStore) SaveBatch(ctx context.Context, batch *Batch) error {
+	return s.Transact(ctx, func(tx Tx) error {
+		return tx.SaveBatch(batch)
+	})
+}
+
+// queueBatch adds the command saving a batch to a pipeline
+func (s *RedisStore) queueBatch(ctx context.Context, pipe redis.Pipeliner, batch *Batch) error {
+	ttl := time.Until(batch.ExpiresAt)
+	if ttl <= 0 {
+		return errors.New("batch has already expired")
+	}
+
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("marshaling batch: %w", err)
+	}
+
+	pipe.Set(ctx, s.key(batchPrefix, batch.ID), data, ttl)
+	return nil
+}
+
+// GetBatch retrieves a batch
+func (s *RedisStore) GetBatch(ctx context.Context, batchID string) (*Batch, error) {
+	data, err := s.client.Get(ctx, s.key(batchPrefix, batchID)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("getting batch: %w", err)
+	}
+
+	var batch Batch
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return nil, fmt.Errorf("unmarshaling batch: %w", err)
+	}
+
+	return &batch, nil
+}
+
+// SaveConsentTicket stores a consent ticket for the device code
+func (s *RedisStore) SaveConsentTicket(ctx context.Context, ticket, deviceCode string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.New("code has already expired")
+	}
+
+	if err := s.client.Set(ctx, s.key(consentPrefix, ticket), deviceCode, ttl).Err(); err != nil {
+		return fmt.Errorf("saving consent ticket: %w", err)
+	}
+
+	return nil
+}
+
+// SaveCallbackNonce stores a callback nonce for the device code
+func (s *RedisStore) SaveCallbackNonce(ctx context.Context, nonce, deviceCode string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.New("code has already expired")
+	}
+
+	if err := s.client.Set(ctx, s.key(callbackPrefix, nonce), deviceCode, ttl).Err(); err != nil {
+		return fmt.Errorf("saving callback nonce: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeCallbackNonce deletes a callback nonce and returns its device code.
+// GETDEL guarantees that concurrent callbacks cannot both consume it.
+func (s *RedisStore) ConsumeCallbackNonce(ctx context.Context, nonce string) (string, error) {
+	deviceCode, err := s.client.GetDel(ctx, s.key(callbackPrefix, nonce)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", fmt.Errorf("consuming callback nonce: %w", err)
+	}
+
+	return deviceCode, nil
+}
+
+// ConsumeConsentTicket deletes a consent ticket and returns its device code.
+// GETDEL guarantees that a ticket decides a single consent form post.
+func (s *RedisStore) ConsumeConsentTicket(ctx context.Context, ticket string) (string, error) {
+	deviceCode, err := s.client.GetDel(ctx, s.key(consentPrefix, ticket)).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return "", nil
+		}
+		return "", fmt.Errorf("consuming consent ticket: %w", err)
+	}
+
+	return deviceCode, nil
+}
@@ -0,0 +1,135 @@
+package deviceflow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// benchRedisURLEnv names the Redis used by the store benchmarks, such as the
+// one started by make test-deps. They are skipped when it is unset.
+const benchRedisURLEnv = "BENCH_REDIS_URL"
+
+// newBenchRedisStore connects to the benchmark Redis, namespacing keys per
+// benchmark and removing them afterwards
+func newBenchRedisStore(b *testing.B) Store {
+	b.Helper()
+	url := os.Getenv(benchRedisURLEnv)
+	if url == "" {
+		b.Skipf("%s not set", benchRedisURLEnv)
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		b.Fatalf("parsing %s: %v", benchRedisURLEnv, err)
+	}
+	client := redis.NewClient(opts)
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		b.Fatalf("connecting to Redis: %v", err)
+	}
+
+	prefix := fmt.Sprintf("bench:%s:%d:", b.Name(), time.Now().UnixNano())
+	b.Cleanup(func() {
+		iter := client.Scan(ctx, 0, prefix+"*", 1000).Iterator()
+		for iter.Next(ctx) {
+			client.Del(ctx, iter.Val())
+		}
+		client.Close()
+	})
+	return NewRedisStore(client, WithKeyPrefix(prefix))
+}
+
+// benchDeviceCode creates an unsaved pending device code
+func benchDeviceCode(b *testing.B, i int) *DeviceCode {
+	b.Helper()
+	now := time.Now()
+	return &DeviceCode{
+		DeviceCode:  fmt.Sprintf("device-%064d", i),
+		UserCode:    "BCDF-GHJK",
+		ExpiresAt:   now.Add(MinExpiryDuration),
+		RequestedAt: now,
+		ClientID:    "bench",
+		Interval:    5,
+		LastPoll:    now,
+	}
+}
+
+func BenchmarkRedisSaveDeviceCode(b *testing.B) {
+	store := newBenchRedisStore(b)
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := store.SaveDeviceCode(ctx, benchDeviceCode(b, i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRedisGetDeviceCode(b *testing.B) {
+	store := newBenchRedisStore(b)
+	ctx := context.Background()
+	code := benchDeviceCode(b, 0)
+	if err := store.SaveDeviceCode(ctx, code); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.GetDeviceCode(ctx, code.DeviceCode); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRedisRecordPoll(b *testing.B) {
+	store := newBenchRedisStore(b)
+	ctx := context.Background()
+	code := benchDeviceCode(b, 0)
+	if err := store.SaveDeviceCode(ctx, code); err != nil {
+		b.Fatal(err)
+	}
+	// Limits that never reject, so every poll runs the full script
+	limit := PollLimit{Window: time.Minute}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.RecordPoll(ctx, code, limit); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkRedisCheckDeviceCodeContention polls pending codes concurrently
+// through the flow, measuring the store's round trips under contention
+func BenchmarkRedisCheckDeviceCodeContention(b *testing.B) {
+	store := newBenchRedisStore(b)
+	ctx := context.Background()
+	flow := NewFlow(store, "https://example.com")
+
+	codes := make([]string, 16)
+	for i := range codes {
+		code, err := flow.RequestDeviceCode(ctx, "bench", "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		codes[i] = code.DeviceCode
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			_, _ = flow.CheckDeviceCode(ctx, codes[i%len(codes)])
+			i++
+		}
+	})
+}
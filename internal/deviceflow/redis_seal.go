@@ -0,0 +1,149 @@
+// Package deviceflow encrypts sensitive Redis records at rest
+package deviceflow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/wrale/oauth2-device-proxy/internal/envelope"
+)
+
+// errNoSealer indicates an encrypted record was read by a store configured
+// without encryption keys
+var errNoSealer = errors.New("record is encrypted but no encryption keys are configured")
+
+// WithEncryption seals token responses and the sensitive fields of device
+// codes before they are written to Redis. Records written before encryption
+// was enabled remain readable, so it can be turned on without interrupting
+// flows in progress.
+func WithEncryption(sealer *envelope.Sealer) StoreOption {
+	return func(s *RedisStore) {
+		s.sealer = sealer
+	}
+}
+
+// deviceSecrets are the device code fields sealed when encryption is enabled:
+// client network details and values that must not be disclosed to anyone
+// able to read Redis
+type deviceSecrets struct {
+	RequestIP        string          `json:"request_ip,omitempty"`
+	RequestLocation  string          `json:"request_location,omitempty"`
+	RequestUserAgent string          `json:"request_user_agent,omitempty"`
+	Anomaly          *PollAnomaly    `json:"anomaly,omitempty"`
+	Device           *DeviceIdentity `json:"device,omitempty"`
+	Nonce            string          `json:"nonce,omitempty"`
+}
+
+// storedDeviceCode is the persisted form of a device code, with its secrets
+// moved into Sealed when encryption is enabled
+type storedDeviceCode struct {
+	*DeviceCode
+	Sealed []byte `json:"sealed,omitempty"`
+}
+
+// sealContext binds a sealed record to the record kind and device code it
+// belongs to. The key prefix is left out so records survive migrate-keys.
+func sealContext(kind, deviceCode string) []byte {
+	return []byte(kind + deviceCode)
+}
+
+// encodeDeviceCode marshals a device code for storage
+func (s *RedisStore) encodeDeviceCode(ctx context.Context, code *DeviceCode) ([]byte, error) {
+	if s.sealer == nil {
+		return json.Marshal(code)
+	}
+
+	secrets, err := json.Marshal(deviceSecrets{
+		RequestIP:        code.RequestIP,
+		RequestLocation:  code.RequestLocation,
+		RequestUserAgent: code.RequestUserAgent,
+		Anomaly:          code.Anomaly,
+		Device:           code.Device,
+		Nonce:            code.Nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.sealer.Seal(ctx, secrets, sealContext(devicePrefix, code.DeviceCode))
+	if err != nil {
+		return nil, fmt.Errorf("encrypting device code: %w", err)
+	}
+
+	public := *code
+	public.RequestIP, public.RequestLocation, public.RequestUserAgent = "", "", ""
+	public.Anomaly, public.Device, public.Nonce = nil, nil, ""
+	return json.Marshal(storedDeviceCode{DeviceCode: &public, Sealed: sealed})
+}
+
+// decodeDeviceCode unmarshals a stored device code, opening its secrets
+func (s *RedisStore) decodeDeviceCode(ctx context.Context, deviceCode string, data []byte) (*DeviceCode, error) {
+	var code DeviceCode
+	stored := storedDeviceCode{DeviceCode: &code}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("unmarshaling device code: %w", err)
+	}
+	if stored.Sealed == nil {
+		return &code, nil
+	}
+
+	if s.sealer == nil {
+		return nil, fmt.Errorf("reading device code: %w", errNoSealer)
+	}
+	plaintext, err := s.sealer.Open(ctx, stored.Sealed, sealContext(devicePrefix, deviceCode))
+	if err != nil {
+		return nil, fmt.Errorf("decrypting device code: %w", err)
+	}
+	var secrets deviceSecrets
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("unmarshaling device code secrets: %w", err)
+	}
+
+	code.RequestIP = secrets.RequestIP
+	code.RequestLocation = secrets.RequestLocation
+	code.RequestUserAgent = secrets.RequestUserAgent
+	code.Anomaly = secrets.Anomaly
+	code.Device = secrets.Device
+	code.Nonce = secrets.Nonce
+	return &code, nil
+}
+
+// encodeToken marshals a token response for storage, sealing it entirely
+// when encryption is enabled
+func (s *RedisStore) encodeToken(ctx context.Context, deviceCode string, token *TokenResponse) ([]byte, error) {
+	data, err := json.Marshal(storedToken{TokenResponse: token, Identity: token.Identity, Offline: token.Offline})
+	if err != nil || s.sealer == nil {
+		return data, err
+	}
+
+	sealed, err := s.sealer.Seal(ctx, data, sealContext(tokenPrefix, deviceCode))
+	if err != nil {
+		return nil, fmt.Errorf("encrypting token response: %w", err)
+	}
+	return sealed, nil
+}
+
+// decodeToken unmarshals a stored token response
+func (s *RedisStore) decodeToken(ctx context.Context, deviceCode string, data []byte) (*TokenResponse, error) {
+	if envelope.IsSealed(data) {
+		if s.sealer == nil {
+			return nil, fmt.Errorf("reading token response: %w", errNoSealer)
+		}
+		plaintext, err := s.sealer.Open(ctx, data, sealContext(tokenPrefix, deviceCode))
+		if err != nil {
+			return nil, fmt.Errorf("decrypting token response: %w", err)
+		}
+		data = plaintext
+	}
+
+	var token TokenResponse
+	stored := storedToken{TokenResponse: &token}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("unmarshaling token response: %w", err)
+	}
+	token.Identity = stored.Identity
+	token.Offline = stored.Offline
+
+	return &token, nil
+}
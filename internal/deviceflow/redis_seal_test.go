@@ -0,0 +1,130 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/envelope"
+)
+
+// sealedStore returns a Redis store that encrypts records without a client,
+// enough to exercise record encoding
+func sealedStore(t *testing.T) *RedisStore {
+	t.Helper()
+	keys, err := envelope.NewLocalKeys(envelope.Key{ID: "k1", Secret: make([]byte, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRedisStore(nil, WithEncryption(envelope.NewSealer(keys))).(*RedisStore)
+}
+
+func TestSealedDeviceCode(t *testing.T) {
+	ctx := context.Background()
+	store := sealedStore(t)
+	code := &DeviceCode{
+		DeviceCode:       "device-123",
+		UserCode:         "ABCD-EFGH",
+		ClientID:         "tv",
+		ExpiresAt:        time.Now().Add(time.Minute).UTC(),
+		RequestIP:        "203.0.113.7",
+		RequestLocation:  "Lisbon",
+		RequestUserAgent: "tv-app/1.0",
+		Device:           &DeviceIdentity{ID: "serial-42"},
+		Nonce:            "nonce-value",
+	}
+
+	data, err := store.encodeDeviceCode(ctx, code)
+	if err != nil {
+		t.Fatalf("encodeDeviceCode failed: %v", err)
+	}
+	for _, secret := range []string{"203.0.113.7", "Lisbon", "tv-app", "serial-42", "nonce-value"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("stored record exposes %q: %s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), `"user_code":"ABCD-EFGH"`) {
+		t.Errorf("stored record lacks plaintext lookup fields: %s", data)
+	}
+
+	got, err := store.decodeDeviceCode(ctx, code.DeviceCode, data)
+	if err != nil {
+		t.Fatalf("decodeDeviceCode failed: %v", err)
+	}
+	if got.RequestIP != code.RequestIP || got.RequestLocation != code.RequestLocation ||
+		got.RequestUserAgent != code.RequestUserAgent || got.Nonce != code.Nonce ||
+		got.Device == nil || got.Device.ID != "serial-42" || got.ClientID != "tv" {
+		t.Errorf("decoded device code = %+v", got)
+	}
+	if code.RequestIP == "" {
+		t.Error("encoding cleared the caller's device code")
+	}
+
+	// A record copied under another device code does not open
+	if _, err := store.decodeDeviceCode(ctx, "device-456", data); !errors.Is(err, envelope.ErrDecrypt) {
+		t.Errorf("moved record error = %v, want %v", err, envelope.ErrDecrypt)
+	}
+
+	// Without keys the record is reported rather than returned incomplete
+	plain := NewRedisStore(nil).(*RedisStore)
+	if _, err := plain.decodeDeviceCode(ctx, code.DeviceCode, data); !errors.Is(err, errNoSealer) {
+		t.Errorf("unkeyed read error = %v, want %v", err, errNoSealer)
+	}
+}
+
+func TestSealedTokenResponse(t *testing.T) {
+	ctx := context.Background()
+	store := sealedStore(t)
+	token := &TokenResponse{
+		AccessToken: "access-secret",
+		TokenType:   "Bearer",
+		Identity:    &Identity{Subject: "user-1", Email: "user@example.com"},
+	}
+
+	data, err := store.encodeToken(ctx, "device-123", token)
+	if err != nil {
+		t.Fatalf("encodeToken failed: %v", err)
+	}
+	if strings.Contains(string(data), "access-secret") || strings.Contains(string(data), "user@example.com") {
+		t.Errorf("stored token is not encrypted: %s", data)
+	}
+
+	got, err := store.decodeToken(ctx, "device-123", data)
+	if err != nil {
+		t.Fatalf("decodeToken failed: %v", err)
+	}
+	if got.AccessToken != "access-secret" || got.Identity == nil || got.Identity.Email != "user@example.com" {
+		t.Errorf("decoded token = %+v, identity %+v", got, got.Identity)
+	}
+
+	if _, err := store.decodeToken(ctx, "device-456", data); !errors.Is(err, envelope.ErrDecrypt) {
+		t.Errorf("moved token error = %v, want %v", err, envelope.ErrDecrypt)
+	}
+}
+
+func TestSealedStoreReadsPlaintext(t *testing.T) {
+	ctx := context.Background()
+	plain := NewRedisStore(nil).(*RedisStore)
+	store := sealedStore(t)
+
+	// Records written before encryption was enabled stay readable
+	codeData, err := plain.encodeDeviceCode(ctx, &DeviceCode{DeviceCode: "device-123", RequestIP: "203.0.113.7"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := store.decodeDeviceCode(ctx, "device-123", codeData)
+	if err != nil || code.RequestIP != "203.0.113.7" {
+		t.Errorf("plaintext device code = %+v, %v", code, err)
+	}
+
+	tokenData, err := plain.encodeToken(ctx, "device-123", &TokenResponse{AccessToken: "access"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := store.decodeToken(ctx, "device-123", tokenData)
+	if err != nil || token.AccessToken != "access" {
+		t.Errorf("plaintext token = %+v, %v", token, err)
+	}
+}
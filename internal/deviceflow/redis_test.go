@@ -0,0 +1,59 @@
+package deviceflow
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMappingInconsistency(t *testing.T) {
+	tests := []struct {
+		name     string
+		userCode string
+		code     *DeviceCode
+		want     string
+	}{
+		{name: "consistent", userCode: "ABCD-EFGH", code: &DeviceCode{UserCode: "ABCD-EFGH"}},
+		{name: "consistent after normalization", userCode: "abcdefgh", code: &DeviceCode{UserCode: "ABCD-EFGH"}},
+		{name: "missing record", userCode: "ABCD-EFGH", want: "missing_device_code"},
+		{name: "embedded code differs", userCode: "ABCD-EFGH", code: &DeviceCode{UserCode: "WXYZ-KLMN"}, want: "user_code_mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mappingInconsistency(tt.userCode, tt.code); got != tt.want {
+				t.Errorf("mappingInconsistency() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStoredTokenIdentity(t *testing.T) {
+	token := &TokenResponse{
+		AccessToken: "access",
+		TokenType:   "Bearer",
+		IDToken:     "id",
+		Identity:    &Identity{Subject: "user-1", Email: "user@example.com"},
+	}
+
+	wire, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(wire), "user@example.com") {
+		t.Errorf("device response %s exposes identity claims", wire)
+	}
+
+	data, err := json.Marshal(storedToken{TokenResponse: token, Identity: token.Identity})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got TokenResponse
+	stored := storedToken{TokenResponse: &got}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if got.AccessToken != "access" || got.IDToken != "id" || stored.Identity == nil || stored.Identity.Email != "user@example.com" {
+		t.Errorf("stored token = %+v, identity %+v", got, stored.Identity)
+	}
+}
@@ -0,0 +1,106 @@
+// Package deviceflow implements device code tombstones for the Redis store
+package deviceflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
+)
+
+// expiredPrefix holds tombstones by device code, and by user code under
+// expiredPrefix+userPrefix
+const expiredPrefix = "expired:"
+
+// Reasons a device code record was replaced by its tombstone
+const (
+	TombstoneExpired  = "expired"  // The code expired before the device collected a token
+	TombstoneConsumed = "consumed" // The device already collected its token
+	TombstoneDeleted  = "deleted"  // The code was revoked before it expired
+)
+
+// tombstone outlives a device code by the tombstone grace window, so that a
+// device polling late or a user entering an old code is told why the code is
+// gone rather than that it is unknown. Denied and failed codes leave none.
+type tombstone struct {
+	Reason     string    `json:"reason"`
+	DeviceCode string    `json:"device_code"`
+	UserCode   string    `json:"user_code,omitempty"`
+	ClientID   string    `json:"client_id,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// expiredKey holds the tombstone of a device code
+func (s *RedisStore) expiredKey(deviceCode string) string {
+	return s.key(expiredPrefix, deviceCode)
+}
+
+// expiredUserKey holds the tombstone of a device code by its user code
+func (s *RedisStore) expiredUserKey(userCode string) string {
+	return s.key(expiredPrefix, userPrefix, validation.NormalizeCode(userCode))
+}
+
+// tombstoneReason returns why a code saved or deleted now would later be
+// gone, or "" when it should leave no tombstone
+func tombstoneReason(code *DeviceCode, deleted bool) string {
+	switch {
+	case !code.ConsumedAt.IsZero():
+		return TombstoneConsumed
+	case code.Denied || code.Failure != nil:
+		return ""
+	case deleted:
+		return TombstoneDeleted
+	default:
+		return TombstoneExpired
+	}
+}
+
+// queueTombstone adds the commands keeping the tombstone of a code for ttl, or
+// dropping it when there is no reason to keep one, to a pipeline
+func (s *RedisStore) queueTombstone(ctx context.Context, pipe redis.Pipeliner, code *DeviceCode, reason string, ttl time.Duration) error {
+	keys := []string{s.expiredKey(code.DeviceCode), s.expiredUserKey(code.UserCode)}
+	if reason == "" || s.ttl.ExpiredGrace <= 0 || ttl <= 0 {
+		pipe.Del(ctx, keys...)
+		return nil
+	}
+
+	data, err := json.Marshal(tombstone{
+		Reason:     reason,
+		DeviceCode: code.DeviceCode,
+		UserCode:   code.UserCode,
+		ClientID:   code.ClientID,
+		ExpiresAt:  code.ExpiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling tombstone: %w", err)
+	}
+	for _, key := range keys {
+		pipe.Set(ctx, key, data, ttl)
+	}
+	return nil
+}
+
+// tombstoneCode decodes a tombstone into a device code record marked with the
+// reason it is gone, or returns nil for the tombstone of a code that has not
+// expired yet
+func tombstoneCode(data string, now time.Time) (*DeviceCode, error) {
+	var t tombstone
+	if err := json.Unmarshal([]byte(data), &t); err != nil {
+		return nil, fmt.Errorf("unmarshaling tombstone: %w", err)
+	}
+
+	// A code can expire in Redis a moment before its recorded expiry passes
+	if t.Reason == TombstoneExpired && now.Before(t.ExpiresAt) {
+		return nil, nil
+	}
+	return &DeviceCode{
+		DeviceCode: t.DeviceCode,
+		UserCode:   t.UserCode,
+		ClientID:   t.ClientID,
+		ExpiresAt:  t.ExpiresAt,
+		Tombstone:  t.Reason,
+	}, nil
+}
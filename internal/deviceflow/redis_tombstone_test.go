@@ -0,0 +1,120 @@
+package deviceflow
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTombstoneReason(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    DeviceCode
+		deleted bool
+		want    string
+	}{
+		{name: "pending code expires", want: TombstoneExpired},
+		{name: "authorized code expires uncollected", code: DeviceCode{AuthorizedAt: time.Now()}, want: TombstoneExpired},
+		{name: "collected code", code: DeviceCode{ConsumedAt: time.Now()}, want: TombstoneConsumed},
+		{name: "collected code deleted", code: DeviceCode{ConsumedAt: time.Now()}, deleted: true, want: TombstoneConsumed},
+		{name: "pending code deleted", deleted: true, want: TombstoneDeleted},
+		{name: "denied code", code: DeviceCode{Denied: true}},
+		{name: "failed code", code: DeviceCode{Failure: ErrAccessDenied}, deleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tombstoneReason(&tt.code, tt.deleted); got != tt.want {
+				t.Errorf("tombstoneReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTombstoneCode(t *testing.T) {
+	now := time.Now()
+	encode := func(reason string, expiresAt time.Time) string {
+		data, err := json.Marshal(tombstone{Reason: reason, DeviceCode: "device-123", UserCode: "BCDF-GHJK", ClientID: "tv", ExpiresAt: expiresAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	code, err := tombstoneCode(encode(TombstoneExpired, now.Add(-time.Minute)), now)
+	if err != nil {
+		t.Fatalf("tombstoneCode failed: %v", err)
+	}
+	if code == nil || code.DeviceCode != "device-123" || code.UserCode != "BCDF-GHJK" || code.ClientID != "tv" || code.Tombstone != TombstoneExpired {
+		t.Fatalf("tombstoneCode() = %+v, want an expired record for device-123", code)
+	}
+
+	// A code can expire in Redis a moment before its recorded expiry passes
+	if code, err := tombstoneCode(encode(TombstoneExpired, now.Add(time.Minute)), now); err != nil || code != nil {
+		t.Errorf("tombstoneCode() before expiry = %+v, %v, want nil", code, err)
+	}
+
+	// Revoked codes are gone before they expire
+	if code, err := tombstoneCode(encode(TombstoneDeleted, now.Add(time.Minute)), now); err != nil || code == nil || code.Tombstone != TombstoneDeleted {
+		t.Errorf("tombstoneCode() for a deleted code = %+v, %v, want a deleted record", code, err)
+	}
+	if _, err := tombstoneCode("{", now); err == nil {
+		t.Error("tombstoneCode accepted a malformed tombstone")
+	}
+}
+
+// tombstoneStore answers every lookup with a tombstone
+type tombstoneStore struct {
+	*mockStore
+	reason string
+}
+
+func (s *tombstoneStore) tombstone() *DeviceCode {
+	return &DeviceCode{DeviceCode: "device-123", UserCode: "BCDF-GHJK", ExpiresAt: time.Now().Add(-time.Minute), Tombstone: s.reason}
+}
+
+func (s *tombstoneStore) GetPollState(ctx context.Context, deviceCode string) (*DeviceCode, *TokenResponse, error) {
+	return s.tombstone(), nil, nil
+}
+
+func (s *tombstoneStore) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*DeviceCode, error) {
+	return s.tombstone(), nil
+}
+
+// TestTombstones ensures polls and verifications of codes that are gone are
+// told why rather than that the code is unknown
+func TestTombstones(t *testing.T) {
+	tests := []struct {
+		reason         string
+		wantPoll       string
+		wantVerifyDesc string
+	}{
+		{reason: TombstoneExpired, wantVerifyDesc: "Code has expired"},
+		{reason: TombstoneConsumed, wantPoll: ErrorDescCodeConsumed, wantVerifyDesc: ErrorDescUserCodeConsumed},
+		{reason: TombstoneDeleted, wantPoll: ErrorDescCodeDeleted, wantVerifyDesc: ErrorDescUserCodeDeleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reason, func(t *testing.T) {
+			ctx := context.Background()
+			flow := NewFlow(&tombstoneStore{mockStore: newMockStore(), reason: tt.reason}, "https://example.com")
+
+			_, err := flow.CheckDeviceCode(ctx, "device-123")
+			dferr, ok := AsDeviceFlowError(err)
+			switch {
+			case !ok:
+				t.Errorf("CheckDeviceCode() error = %v, want a device flow error", err)
+			case tt.reason == TombstoneExpired && dferr.Code != ErrorCodeExpiredToken:
+				t.Errorf("CheckDeviceCode() error = %v, want %s", err, ErrorCodeExpiredToken)
+			case tt.reason != TombstoneExpired && (dferr.Code != ErrorCodeInvalidGrant || dferr.Description != tt.wantPoll):
+				t.Errorf("CheckDeviceCode() error = %v, want %s: %s", err, ErrorCodeInvalidGrant, tt.wantPoll)
+			}
+
+			_, err = flow.VerifyUserCode(ctx, "BCDF-GHJK")
+			if dferr, ok := AsDeviceFlowError(err); !ok || dferr.Description != tt.wantVerifyDesc {
+				t.Errorf("VerifyUserCode() error = %v, want %q", err, tt.wantVerifyDesc)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Package deviceflow implements change announcements for the Redis store
+package deviceflow
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// notifyPrefix names the pub/sub channels announcing device code changes
+const notifyPrefix = "notify:"
+
+// watchHub shares one pattern subscription among all devices waiting on this
+// instance, so holding requests open does not cost a connection each
+type watchHub struct {
+	mu      sync.Mutex
+	sub     *redis.PubSub
+	waiters map[string]map[chan struct{}]struct{} // Device code to waiting channels
+}
+
+// notify announces that the flow for a device code changed. Announcements are
+// best effort since waiting devices also recheck periodically.
+func (s *RedisStore) notify(ctx context.Context, deviceCode string) {
+	if err := s.client.Publish(ctx, s.key(notifyPrefix, deviceCode), "").Err(); err != nil {
+		log.Printf("Warning: failed to announce device code change: %v", err)
+	}
+}
+
+// Watch implements Watcher using Redis pub/sub, subscribing while at least
+// one device on this instance is waiting
+func (s *RedisStore) Watch(ctx context.Context, deviceCode string) (<-chan struct{}, error) {
+	h := s.watches
+	ch := make(chan struct{}, 1)
+
+	h.mu.Lock()
+	if h.sub == nil {
+		// The subscription outlives the request that happened to start it
+		sub := s.client.PSubscribe(context.WithoutCancel(ctx), s.pattern(notifyPrefix, "*"))
+		if _, err := sub.Receive(ctx); err != nil {
+			h.mu.Unlock()
+			_ = sub.Close()
+			return nil, fmt.Errorf("subscribing to device code changes: %w", err)
+		}
+		h.sub = sub
+		h.waiters = make(map[string]map[chan struct{}]struct{})
+		go h.dispatch(sub.Channel(), s.key(notifyPrefix))
+	}
+	if h.waiters[deviceCode] == nil {
+		h.waiters[deviceCode] = make(map[chan struct{}]struct{})
+	}
+	h.waiters[deviceCode][ch] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.remove(deviceCode, ch)
+	}()
+
+	return ch, nil
+}
+
+// dispatch wakes the devices waiting on each announced device code until the
+// subscription is closed
+func (h *watchHub) dispatch(messages <-chan *redis.Message, channelPrefix string) {
+	for msg := range messages {
+		deviceCode := strings.TrimPrefix(msg.Channel, channelPrefix)
+
+		h.mu.Lock()
+		for ch := range h.waiters[deviceCode] {
+			select {
+			case ch <- struct{}{}:
+			default: // A wake-up is already pending
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+// remove stops delivery to a waiter, closing the subscription after the last
+func (h *watchHub) remove(deviceCode string, ch chan struct{}) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.waiters[deviceCode], ch)
+	if len(h.waiters[deviceCode]) == 0 {
+		delete(h.waiters, deviceCode)
+	}
+	if len(h.waiters) == 0 && h.sub != nil {
+		if err := h.sub.Close(); err != nil {
+			log.Printf("Warning: failed to close device code subscription: %v", err)
+		}
+		h.sub = nil
+	}
+}
@@ -0,0 +1,84 @@
+package deviceflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+)
+
+// MaxUserCodeRefreshes bounds how often a device may replace its user code,
+// which also bounds the total lifetime of a device code
+const MaxUserCodeRefreshes = 3
+
+// ErrRefreshLimitReached rejects user code refreshes beyond MaxUserCodeRefreshes
+var ErrRefreshLimitReached = NewDeviceFlowError(ErrorCodeInvalidRequest, ErrorDescRefreshLimitReached)
+
+// RefreshUserCode issues a new user code and verification URIs for a pending
+// device code and restarts its expiry window, so a device whose code ran out
+// before the user entered it can keep polling with the same device code. The
+// previous user code stops working. Refreshes must happen before the device
+// code expires, and only the client the code was issued to may refresh it.
+func (f *flowImpl) RefreshUserCode(ctx context.Context, deviceCode, clientID string) (*DeviceCode, error) {
+	code, err := f.GetDeviceCode(ctx, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkClientAuthentication(ctx, code); err != nil {
+		return nil, err
+	}
+	if clientID != code.ClientID {
+		return nil, ErrInvalidDeviceCode
+	}
+
+	// Only flows still waiting for the user get a new code
+	token, err := f.resolveToken(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if token != nil {
+		return nil, ErrAlreadyAuthorized
+	}
+	if code.UserCodeRefreshes >= MaxUserCodeRefreshes {
+		return nil, ErrRefreshLimitReached
+	}
+
+	userCode, err := generateUserCode()
+	if err != nil {
+		return nil, err
+	}
+	previous := code.UserCode
+
+	now := time.Now()
+	code.UserCode = userCode
+	code.VerificationURI, code.VerificationURIComplete = f.buildVerificationURIs(userCode)
+	if !f.completeURIPolicy(code.ClientID) {
+		code.VerificationURIComplete = ""
+	}
+	code.ExpiresAt = now.Add(f.refreshExpiry())
+	code.ExpiresIn = int(time.Until(code.ExpiresAt).Seconds())
+	code.UserCodeRefreshes++
+
+	if err := f.store.ReplaceUserCode(ctx, code, previous); err != nil {
+		return nil, NewDeviceFlowError(
+			ErrorCodeServerError,
+			"Failed to save device code",
+		)
+	}
+
+	f.emit(ctx, events.TypeUserCodeRefreshed, code, map[string]any{
+		"expires_at": code.ExpiresAt,
+		"refreshes":  code.UserCodeRefreshes,
+	})
+
+	return code, nil
+}
+
+// refreshExpiry is the lifetime of a refreshed user code, at least the
+// minimum required by RFC 8628
+func (f *flowImpl) refreshExpiry() time.Duration {
+	if f.expiryDuration < MinExpiryDuration {
+		return MinExpiryDuration
+	}
+	return f.expiryDuration
+}
@@ -0,0 +1,73 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRefreshUserCode(t *testing.T) {
+	ctx := context.Background()
+	flow := NewFlow(newMockStore(), "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "kiosk", "openid")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	original := code.UserCode
+	originalExpiry := code.ExpiresAt
+
+	if _, err := flow.RefreshUserCode(ctx, code.DeviceCode, "printer"); !errors.Is(err, ErrInvalidDeviceCode) {
+		t.Errorf("refresh by another client = %v, want %v", err, ErrInvalidDeviceCode)
+	}
+
+	refreshed, err := flow.RefreshUserCode(ctx, code.DeviceCode, "kiosk")
+	if err != nil {
+		t.Fatalf("RefreshUserCode failed: %v", err)
+	}
+	if refreshed.DeviceCode != code.DeviceCode {
+		t.Errorf("device code = %q, want %q", refreshed.DeviceCode, code.DeviceCode)
+	}
+	if refreshed.UserCode == original {
+		t.Error("user code was not replaced")
+	}
+	if refreshed.ExpiresAt.Before(originalExpiry) {
+		t.Errorf("expiry moved back from %v to %v", originalExpiry, refreshed.ExpiresAt)
+	}
+
+	// Only the new user code verifies
+	if _, err := flow.VerifyUserCode(ctx, original); err == nil {
+		t.Error("previous user code still verifies")
+	}
+	if _, err := flow.VerifyUserCode(ctx, refreshed.UserCode); err != nil {
+		t.Errorf("VerifyUserCode with new code failed: %v", err)
+	}
+
+	for i := 1; i < MaxUserCodeRefreshes; i++ {
+		if _, err := flow.RefreshUserCode(ctx, code.DeviceCode, "kiosk"); err != nil {
+			t.Fatalf("refresh %d failed: %v", i+1, err)
+		}
+	}
+	if _, err := flow.RefreshUserCode(ctx, code.DeviceCode, "kiosk"); !errors.Is(err, ErrRefreshLimitReached) {
+		t.Errorf("refresh beyond limit = %v, want %v", err, ErrRefreshLimitReached)
+	}
+}
+
+func TestRefreshUserCodeEndedFlow(t *testing.T) {
+	ctx := context.Background()
+	flow := NewFlow(newMockStore(), "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "kiosk", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if err := flow.DenyAuthorization(ctx, code.DeviceCode); err != nil {
+		t.Fatalf("DenyAuthorization failed: %v", err)
+	}
+	if _, err := flow.RefreshUserCode(ctx, code.DeviceCode, "kiosk"); !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("refresh after denial = %v, want %v", err, ErrAccessDenied)
+	}
+	if _, err := flow.RefreshUserCode(ctx, "unknown", "kiosk"); err == nil {
+		t.Error("expected error for unknown device code")
+	}
+}
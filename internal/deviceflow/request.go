@@ -0,0 +1,70 @@
+package deviceflow
+
+import (
+	"unicode"
+)
+
+// Device identity limits
+const (
+	MaxDeviceIDLength          = 128
+	MaxDeviceAttestationLength = 8192
+)
+
+// RequestOption sets optional parameters of a device authorization request
+type RequestOption func(*DeviceCode)
+
+// WithDeviceIdentity binds a client-asserted device identifier and attestation
+// to the device code. Empty values are ignored.
+func WithDeviceIdentity(id, attestation string) RequestOption {
+	return func(code *DeviceCode) {
+		if id == "" && attestation == "" {
+			return
+		}
+		code.Device = &DeviceIdentity{ID: id, Attestation: attestation}
+	}
+}
+
+// WithRequestOrigin records the requesting IP address and its approximate
+// location so that users can compare it with their own before approving
+func WithRequestOrigin(ip, location string) RequestOption {
+	return func(code *DeviceCode) {
+		code.RequestIP = ip
+		code.RequestLocation = location
+	}
+}
+
+// WithRequestUserAgent records the User-Agent of the device authorization
+// request, truncated to MaxUserAgentLength
+func WithRequestUserAgent(userAgent string) RequestOption {
+	return func(code *DeviceCode) {
+		code.RequestUserAgent = truncate(userAgent, MaxUserAgentLength)
+	}
+}
+
+// validateDeviceIdentity rejects identifiers that cannot be shown safely to
+// users or stored compactly
+func validateDeviceIdentity(device *DeviceIdentity) error {
+	if device == nil {
+		return nil
+	}
+
+	if len(device.ID) > MaxDeviceIDLength {
+		return NewDeviceFlowError(ErrorCodeInvalidRequest, "The device_id parameter is too long")
+	}
+	for _, r := range device.ID {
+		if !unicode.IsPrint(r) {
+			return NewDeviceFlowError(ErrorCodeInvalidRequest, "The device_id parameter contains invalid characters")
+		}
+	}
+
+	if len(device.Attestation) > MaxDeviceAttestationLength {
+		return NewDeviceFlowError(ErrorCodeInvalidRequest, "The device_attestation parameter is too long")
+	}
+	for _, r := range device.Attestation {
+		if r <= ' ' || r > '~' {
+			return NewDeviceFlowError(ErrorCodeInvalidRequest, "The device_attestation parameter contains invalid characters")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package deviceflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestWithDeviceIdentity(t *testing.T) {
+	ctx := context.Background()
+	emitter := &recordingEmitter{}
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com", WithEventEmitter(emitter))
+
+	code, err := flow.RequestDeviceCode(ctx, "tv", "openid", WithDeviceIdentity("SN-4411-A", "eyJhbGciOiJFUzI1NiJ9.e30.c2ln"))
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	stored, err := store.GetDeviceCode(ctx, code.DeviceCode)
+	if err != nil || stored == nil {
+		t.Fatalf("GetDeviceCode() = %v, %v", stored, err)
+	}
+	if stored.Device == nil || stored.Device.ID != "SN-4411-A" || stored.Device.Attestation == "" {
+		t.Errorf("stored device identity = %+v", stored.Device)
+	}
+	if len(emitter.events) != 1 || emitter.events[0].DeviceID != "SN-4411-A" {
+		t.Errorf("events = %+v, want device_id on creation event", emitter.events)
+	}
+
+	// Requests without identity leave the field unset
+	code, err = flow.RequestDeviceCode(ctx, "tv", "openid", WithDeviceIdentity("", ""))
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if code.Device != nil {
+		t.Errorf("Device = %+v, want nil", code.Device)
+	}
+}
+
+func TestWithDeviceIdentityValidation(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		attestation string
+	}{
+		{name: "id too long", id: strings.Repeat("a", MaxDeviceIDLength+1)},
+		{name: "id with control characters", id: "SN\n1"},
+		{name: "attestation too long", attestation: strings.Repeat("a", MaxDeviceAttestationLength+1)},
+		{name: "attestation with whitespace", attestation: "eyJ hbGc"},
+	}
+
+	flow := NewFlow(newMockStore(), "https://example.com")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := flow.RequestDeviceCode(context.Background(), "tv", "", WithDeviceIdentity(tt.id, tt.attestation))
+			dfe, ok := AsDeviceFlowError(err)
+			if !ok || dfe.Code != ErrorCodeInvalidRequest {
+				t.Errorf("RequestDeviceCode() error = %v, want invalid_request", err)
+			}
+		})
+	}
+}
+
+func TestWithRequestOrigin(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "tv", "openid", WithRequestOrigin("203.0.113.7", "Berlin, DE"))
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	stored, err := store.GetDeviceCode(ctx, code.DeviceCode)
+	if err != nil || stored == nil {
+		t.Fatalf("GetDeviceCode() = %v, %v", stored, err)
+	}
+	if stored.RequestIP != "203.0.113.7" || stored.RequestLocation != "Berlin, DE" {
+		t.Errorf("stored origin = %q, %q", stored.RequestIP, stored.RequestLocation)
+	}
+}
+
+func TestRequestNonce(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	tests := []struct {
+		scope     string
+		wantNonce bool
+	}{
+		{scope: "openid profile", wantNonce: true},
+		{scope: "profile openid", wantNonce: true},
+		{scope: "orders:read"},
+		{scope: "openidish"},
+	}
+
+	for _, tt := range tests {
+		code, err := flow.RequestDeviceCode(ctx, "tv", tt.scope)
+		if err != nil {
+			t.Fatalf("RequestDeviceCode(%q) failed: %v", tt.scope, err)
+		}
+		if (len(code.Nonce) == NonceLength) != tt.wantNonce {
+			t.Errorf("scope %q: nonce = %q, want nonce %v", tt.scope, code.Nonce, tt.wantNonce)
+		}
+
+		stored, err := store.GetDeviceCode(ctx, code.DeviceCode)
+		if err != nil || stored == nil || stored.Nonce != code.Nonce {
+			t.Errorf("scope %q: stored nonce = %+v, %v", tt.scope, stored, err)
+		}
+	}
+}
+
+func TestWithAuthParams(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "tv", "", WithAuthParams(map[string]string{"audience": "api", "prompt": ""}))
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	stored, _ := store.GetDeviceCode(ctx, code.DeviceCode)
+	if stored == nil || len(stored.AuthParams) != 1 || stored.AuthParams["audience"] != "api" {
+		t.Errorf("stored auth params = %+v, want audience only", stored)
+	}
+
+	tests := map[string]map[string]string{
+		"reserved parameter": {"redirect_uri": "https://evil.example"},
+		"value too long":     {"audience": strings.Repeat("a", MaxAuthParamLength+1)},
+	}
+	for name, params := range tests {
+		_, err := flow.RequestDeviceCode(ctx, "tv", "", WithAuthParams(params))
+		if dfe, ok := AsDeviceFlowError(err); !ok || dfe.Code != ErrorCodeInvalidRequest {
+			t.Errorf("%s: RequestDeviceCode() error = %v, want invalid_request", name, err)
+		}
+	}
+}
+
+func TestValidateForwardedParams(t *testing.T) {
+	if err := ValidateForwardedParams([]string{"audience", "acr_values", "prompt"}); err != nil {
+		t.Errorf("ValidateForwardedParams failed: %v", err)
+	}
+	for _, names := range [][]string{{"state"}, {"audience", "client_id"}, {"resource"}, {""}} {
+		if err := ValidateForwardedParams(names); err == nil {
+			t.Errorf("ValidateForwardedParams(%q) succeeded, want error", names)
+		}
+	}
+}
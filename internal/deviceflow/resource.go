@@ -0,0 +1,64 @@
+package deviceflow
+
+import (
+	"context"
+	"net/url"
+	"slices"
+)
+
+// Resource indicator limits
+const (
+	MaxResources      = 10
+	MaxResourceLength = 512
+)
+
+// WithResources records the RFC 8707 resource indicators of the device
+// authorization request, naming the protected resources the tokens are for
+func WithResources(resources []string) RequestOption {
+	return func(code *DeviceCode) {
+		if len(resources) > 0 {
+			code.Resources = resources
+		}
+	}
+}
+
+// validateResources checks resource indicators per RFC 8707 section 2: each
+// must be an absolute URI without a fragment
+func validateResources(resources []string) error {
+	if len(resources) > MaxResources {
+		return ErrInvalidTarget
+	}
+	for _, resource := range resources {
+		if len(resource) > MaxResourceLength {
+			return ErrInvalidTarget
+		}
+		u, err := url.Parse(resource)
+		if err != nil || !u.IsAbs() || u.Fragment != "" || u.RawFragment != "" {
+			return ErrInvalidTarget
+		}
+	}
+	return nil
+}
+
+// resourcesKey is the context key under which token request resources are stored
+type resourcesKey struct{}
+
+// WithRequestedResources returns a context carrying the resource indicators
+// of a token request, which CheckDeviceCode requires to have been authorized.
+// Tokens are issued for the resources of the device authorization request, so
+// a token request can name those resources but not others.
+func WithRequestedResources(ctx context.Context, resources []string) context.Context {
+	return context.WithValue(ctx, resourcesKey{}, resources)
+}
+
+// checkRequestedResources rejects token requests for resources the device
+// code was not authorized for
+func checkRequestedResources(ctx context.Context, code *DeviceCode) error {
+	requested, _ := ctx.Value(resourcesKey{}).([]string)
+	for _, resource := range requested {
+		if !slices.Contains(code.Resources, resource) {
+			return ErrInvalidTarget
+		}
+	}
+	return nil
+}
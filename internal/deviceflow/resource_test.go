@@ -0,0 +1,67 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateResources(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []string
+		wantErr   bool
+	}{
+		{name: "none"},
+		{name: "single", resources: []string{"https://api.example.com"}},
+		{name: "several", resources: []string{"https://api.example.com", "urn:example:printer"}},
+		{name: "relative", resources: []string{"/api"}, wantErr: true},
+		{name: "fragment", resources: []string{"https://api.example.com/#x"}, wantErr: true},
+		{name: "too long", resources: []string{"https://api.example.com/" + strings.Repeat("a", MaxResourceLength)}, wantErr: true},
+		{name: "too many", resources: make([]string, MaxResources+1), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateResources(tt.resources)
+			if tt.wantErr != errors.Is(err, ErrInvalidTarget) {
+				t.Errorf("validateResources(%q) = %v, wantErr %v", tt.resources, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestedResources(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "tv", "", WithResources([]string{"https://api.example.com"}))
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if stored, _ := store.GetDeviceCode(ctx, code.DeviceCode); len(stored.Resources) != 1 {
+		t.Errorf("stored resources = %q, want the requested resource", stored.Resources)
+	}
+
+	tests := []struct {
+		name      string
+		requested []string
+		wantErr   error
+	}{
+		{name: "no resource", wantErr: ErrPendingAuthorization},
+		{name: "authorized resource", requested: []string{"https://api.example.com"}, wantErr: ErrPendingAuthorization},
+		{name: "other resource", requested: []string{"https://other.example.com"}, wantErr: ErrInvalidTarget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.deviceCodes[code.DeviceCode].LastPoll = time.Now().Add(-time.Minute)
+			_, err := flow.CheckDeviceCode(WithRequestedResources(ctx, tt.requested), code.DeviceCode)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckDeviceCode error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
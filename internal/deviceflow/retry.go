@@ -0,0 +1,309 @@
+// Package deviceflow implements retrying store operations through Redis failovers
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// Retry defaults
+const (
+	DefaultStoreRetries      = 2
+	DefaultStoreRetryBackoff = 50 * time.Millisecond
+	DefaultStoreMaxBackoff   = time.Second
+)
+
+// RetryConfig configures a RetryStore. Zero values use the package defaults.
+type RetryConfig struct {
+	MaxRetries     int           // Retries after the first attempt, negative disables retries
+	InitialBackoff time.Duration // Delay before the first retry, before jitter
+	MaxBackoff     time.Duration // Upper bound for retry delays
+}
+
+// RetryStore wraps a store, retrying operations that failed transiently, as
+// while a replica is promoted or a cluster slot moves, with exponential
+// backoff and jitter. Only idempotent operations are retried: polls,
+// submission claims, consent tickets, callback nonces and token saves may
+// have been applied before the connection dropped, so they fail on the first
+// error.
+type RetryStore struct {
+	Store
+
+	cfg   RetryConfig
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryStore wraps a store with retries for transient errors
+func NewRetryStore(store Store, cfg RetryConfig) *RetryStore {
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultStoreRetries
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = DefaultStoreRetryBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultStoreMaxBackoff
+	}
+	return &RetryStore{Store: store, cfg: cfg, sleep: sleepContext}
+}
+
+// withRetry runs op, retrying transient errors within the configured budget
+func withRetry[T any](ctx context.Context, s *RetryStore, name string, op func() (T, error)) (T, error) {
+	backoff := s.cfg.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		result, err := op()
+		if err == nil {
+			return result, nil
+		}
+		if !IsTransientStoreError(err) || ctx.Err() != nil {
+			storeOperationsFailed.Inc(name)
+			return result, err
+		}
+		if attempt >= s.cfg.MaxRetries {
+			storeOperationsFailed.Inc(name)
+			return result, err
+		}
+
+		// Full jitter in the upper half of the backoff spreads out replicas
+		// reconnecting to a promoted primary
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		if err := s.sleep(ctx, delay); err != nil {
+			storeOperationsFailed.Inc(name)
+			return result, err
+		}
+		storeRetries.Inc(name)
+		backoff = min(backoff*2, s.cfg.MaxBackoff)
+	}
+}
+
+// retryErr runs an operation returning only an error through withRetry
+func retryErr(ctx context.Context, s *RetryStore, name string, op func() error) error {
+	_, err := withRetry(ctx, s, name, func() (struct{}, error) {
+		return struct{}{}, op()
+	})
+	return err
+}
+
+// IsTransientStoreError reports whether a store error is likely to clear on
+// retry: dropped or refused connections, timeouts, and the replies Redis
+// sends while a failover or slot migration is in progress
+func IsTransientStoreError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, redis.Nil) || errors.Is(err, redis.ErrClosed) {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	// Timeouts, including waiting for a pooled connection
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	for _, prefix := range []string{"MOVED ", "ASK ", "TRYAGAIN ", "CLUSTERDOWN ", "LOADING ", "READONLY ", "MASTERDOWN "} {
+		if redis.HasErrorPrefix(err, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sleepContext waits for d, returning early with the context's error
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// SaveDeviceCode implements Store
+func (s *RetryStore) SaveDeviceCode(ctx context.Context, code *DeviceCode) error {
+	return retryErr(ctx, s, "SaveDeviceCode", func() error {
+		return s.Store.SaveDeviceCode(ctx, code)
+	})
+}
+
+// GetDeviceCode implements Store
+func (s *RetryStore) GetDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error) {
+	return withRetry(ctx, s, "GetDeviceCode", func() (*DeviceCode, error) {
+		return s.Store.GetDeviceCode(ctx, deviceCode)
+	})
+}
+
+// ReplaceUserCode implements Store
+func (s *RetryStore) ReplaceUserCode(ctx context.Context, code *DeviceCode, previousUserCode string) error {
+	return retryErr(ctx, s, "ReplaceUserCode", func() error {
+		return s.Store.ReplaceUserCode(ctx, code, previousUserCode)
+	})
+}
+
+// GetDeviceCodeByUserCode implements Store
+func (s *RetryStore) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*DeviceCode, error) {
+	return withRetry(ctx, s, "GetDeviceCodeByUserCode", func() (*DeviceCode, error) {
+		return s.Store.GetDeviceCodeByUserCode(ctx, userCode)
+	})
+}
+
+// GetTokenResponse implements Store
+func (s *RetryStore) GetTokenResponse(ctx context.Context, deviceCode string) (*TokenResponse, error) {
+	return withRetry(ctx, s, "GetTokenResponse", func() (*TokenResponse, error) {
+		return s.Store.GetTokenResponse(ctx, deviceCode)
+	})
+}
+
+// GetPollState implements Store
+func (s *RetryStore) GetPollState(ctx context.Context, deviceCode string) (*DeviceCode, *TokenResponse, error) {
+	var token *TokenResponse
+	code, err := withRetry(ctx, s, "GetPollState", func() (*DeviceCode, error) {
+		code, t, err := s.Store.GetPollState(ctx, deviceCode)
+		token = t
+		return code, err
+	})
+	return code, token, err
+}
+
+// DeleteDeviceCode implements Store
+func (s *RetryStore) DeleteDeviceCode(ctx context.Context, deviceCode string) error {
+	return retryErr(ctx, s, "DeleteDeviceCode", func() error {
+		return s.Store.DeleteDeviceCode(ctx, deviceCode)
+	})
+}
+
+// BatchGetDeviceCodes implements Store
+func (s *RetryStore) BatchGetDeviceCodes(ctx context.Context, deviceCodes []string) ([]*DeviceCode, error) {
+	return withRetry(ctx, s, "BatchGetDeviceCodes", func() ([]*DeviceCode, error) {
+		return s.Store.BatchGetDeviceCodes(ctx, deviceCodes)
+	})
+}
+
+// BatchDeleteDeviceCodes implements Store
+func (s *RetryStore) BatchDeleteDeviceCodes(ctx context.Context, deviceCodes []string) error {
+	return retryErr(ctx, s, "BatchDeleteDeviceCodes", func() error {
+		return s.Store.BatchDeleteDeviceCodes(ctx, deviceCodes)
+	})
+}
+
+// GetPollCount implements Store
+func (s *RetryStore) GetPollCount(ctx context.Context, deviceCode string, window time.Duration) (int, error) {
+	return withRetry(ctx, s, "GetPollCount", func() (int, error) {
+		return s.Store.GetPollCount(ctx, deviceCode, window)
+	})
+}
+
+// SavePollInterval implements Store
+func (s *RetryStore) SavePollInterval(ctx context.Context, code *DeviceCode) error {
+	return retryErr(ctx, s, "SavePollInterval", func() error {
+		return s.Store.SavePollInterval(ctx, code)
+	})
+}
+
+// EndAuthorization implements Store
+func (s *RetryStore) EndAuthorization(ctx context.Context, deviceCode string, failure *DeviceFlowError) (*DeviceCode, error) {
+	return withRetry(ctx, s, "EndAuthorization", func() (*DeviceCode, error) {
+		return s.Store.EndAuthorization(ctx, deviceCode, failure)
+	})
+}
+
+// SaveSubmission implements Store
+func (s *RetryStore) SaveSubmission(ctx context.Context, nonce string, result *SubmissionResult, ttl time.Duration) error {
+	return retryErr(ctx, s, "SaveSubmission", func() error {
+		return s.Store.SaveSubmission(ctx, nonce, result, ttl)
+	})
+}
+
+// SaveBatch implements Store
+func (s *RetryStore) SaveBatch(ctx context.Context, batch *Batch) error {
+	return retryErr(ctx, s, "SaveBatch", func() error {
+		return s.Store.SaveBatch(ctx, batch)
+	})
+}
+
+// GetBatch implements Store
+func (s *RetryStore) GetBatch(ctx context.Context, batchID string) (*Batch, error) {
+	return withRetry(ctx, s, "GetBatch", func() (*Batch, error) {
+		return s.Store.GetBatch(ctx, batchID)
+	})
+}
+
+// SaveConsentTicket implements Store
+func (s *RetryStore) SaveConsentTicket(ctx context.Context, ticket, deviceCode string, ttl time.Duration) error {
+	return retryErr(ctx, s, "SaveConsentTicket", func() error {
+		return s.Store.SaveConsentTicket(ctx, ticket, deviceCode, ttl)
+	})
+}
+
+// SaveCallbackNonce implements Store
+func (s *RetryStore) SaveCallbackNonce(ctx context.Context, nonce, deviceCode string, ttl time.Duration) error {
+	return retryErr(ctx, s, "SaveCallbackNonce", func() error {
+		return s.Store.SaveCallbackNonce(ctx, nonce, deviceCode, ttl)
+	})
+}
+
+// ListDeviceCodesByClient implements Store
+func (s *RetryStore) ListDeviceCodesByClient(ctx context.Context, clientID string) ([]string, error) {
+	return withRetry(ctx, s, "ListDeviceCodesByClient", func() ([]string, error) {
+		return s.Store.ListDeviceCodesByClient(ctx, clientID)
+	})
+}
+
+// CountDeviceCodesByClient implements Store
+func (s *RetryStore) CountDeviceCodesByClient(ctx context.Context, clientID string) (int, error) {
+	return withRetry(ctx, s, "CountDeviceCodesByClient", func() (int, error) {
+		return s.Store.CountDeviceCodesByClient(ctx, clientID)
+	})
+}
+
+// CountPendingDeviceCodes implements Store
+func (s *RetryStore) CountPendingDeviceCodes(ctx context.Context) (int, error) {
+	return withRetry(ctx, s, "CountPendingDeviceCodes", func() (int, error) {
+		return s.Store.CountPendingDeviceCodes(ctx)
+	})
+}
+
+// Transact implements Transactor. Transactions only queue idempotent writes,
+// so a failed commit is retried as a whole.
+func (s *RetryStore) Transact(ctx context.Context, fn func(tx Tx) error) error {
+	return retryErr(ctx, s, "Transact", func() error {
+		return transact(ctx, s.Store, fn)
+	})
+}
+
+// Watch implements Watcher when the wrapped store does
+func (s *RetryStore) Watch(ctx context.Context, deviceCode string) (<-chan struct{}, error) {
+	if watcher, ok := s.Store.(Watcher); ok {
+		return watcher.Watch(ctx, deviceCode)
+	}
+	return nil, errWatchUnsupported
+}
+
+// Store retry metrics
+var (
+	storeRetries = metrics.Default.NewCounter(
+		"device_proxy_store_retries_total",
+		"Store operations retried after a transient error, by operation.",
+		"operation",
+	)
+	storeOperationsFailed = metrics.Default.NewCounter(
+		"device_proxy_store_operations_failed_total",
+		"Retriable store operations that failed, after any retries, by operation.",
+		"operation",
+	)
+)
@@ -0,0 +1,139 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// flakyStore fails its next operations with a given error
+type flakyStore struct {
+	*mockStore
+	failures int
+	err      error
+	calls    int
+}
+
+func (s *flakyStore) fail() error {
+	s.calls++
+	if s.failures > 0 {
+		s.failures--
+		return s.err
+	}
+	return nil
+}
+
+func (s *flakyStore) GetDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error) {
+	if err := s.fail(); err != nil {
+		return nil, err
+	}
+	return s.mockStore.GetDeviceCode(ctx, deviceCode)
+}
+
+func (s *flakyStore) RecordPoll(ctx context.Context, code *DeviceCode, limit PollLimit) (bool, error) {
+	if err := s.fail(); err != nil {
+		return false, err
+	}
+	return s.mockStore.RecordPoll(ctx, code, limit)
+}
+
+// redisReply is an error reply as Redis sends it
+type redisReply string
+
+func (e redisReply) Error() string { return string(e) }
+func (e redisReply) RedisError()   {}
+
+func newTestRetryStore(flaky *flakyStore, retries int) (*RetryStore, *[]time.Duration) {
+	var delays []time.Duration
+	store := NewRetryStore(flaky, RetryConfig{MaxRetries: retries, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 150 * time.Millisecond})
+	store.sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+	return store, &delays
+}
+
+func TestRetryStore(t *testing.T) {
+	ctx := context.Background()
+	code := &DeviceCode{DeviceCode: "device-1", UserCode: "BCDF-GHJK", ClientID: "tv", ExpiresAt: time.Now().Add(time.Hour)}
+	flaky := &flakyStore{mockStore: newMockStore(), failures: 2, err: fmt.Errorf("getting device code: %w", syscall.ECONNRESET)}
+	if err := flaky.SaveDeviceCode(ctx, code); err != nil {
+		t.Fatal(err)
+	}
+	store, delays := newTestRetryStore(flaky, 2)
+
+	retriesBefore := storeRetries.Value("GetDeviceCode")
+	got, err := store.GetDeviceCode(ctx, "device-1")
+	if err != nil || got == nil {
+		t.Fatalf("GetDeviceCode() = %v, %v; want the code after retries", got, err)
+	}
+	if flaky.calls != 3 {
+		t.Errorf("attempts = %d, want 3", flaky.calls)
+	}
+	if retried := storeRetries.Value("GetDeviceCode") - retriesBefore; retried != 2 {
+		t.Errorf("retries counted = %v, want 2", retried)
+	}
+	if len(*delays) != 2 || (*delays)[0] < 50*time.Millisecond || (*delays)[0] > 100*time.Millisecond ||
+		(*delays)[1] < 75*time.Millisecond || (*delays)[1] > 150*time.Millisecond {
+		t.Errorf("delays = %v, want jittered backoff capped at the maximum", *delays)
+	}
+
+	// The retry budget is bounded
+	flaky.calls, flaky.failures = 0, 5
+	failedBefore := storeOperationsFailed.Value("GetDeviceCode")
+	if _, err := store.GetDeviceCode(ctx, "device-1"); !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("GetDeviceCode() error = %v, want the transient error once retries run out", err)
+	}
+	if flaky.calls != 3 {
+		t.Errorf("attempts = %d, want 3", flaky.calls)
+	}
+	if failed := storeOperationsFailed.Value("GetDeviceCode") - failedBefore; failed != 1 {
+		t.Errorf("failures counted = %v, want 1", failed)
+	}
+
+	// Polls may have been recorded before the connection dropped
+	flaky.calls, flaky.failures = 0, 1
+	if _, err := store.RecordPoll(ctx, code, PollLimit{Interval: time.Second}); err == nil || flaky.calls != 1 {
+		t.Errorf("RecordPoll() = %v after %d attempts, want the first error", err, flaky.calls)
+	}
+
+	// Permanent errors are not retried
+	flaky.calls, flaky.failures, flaky.err = 0, 1, errors.New("decoding device code")
+	if _, err := store.GetDeviceCode(ctx, "device-1"); err == nil || flaky.calls != 1 {
+		t.Errorf("GetDeviceCode() = %v after %d attempts, want the first error", err, flaky.calls)
+	}
+}
+
+func TestIsTransientStoreError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "connection reset", err: fmt.Errorf("saving: %w", syscall.ECONNRESET), want: true},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, want: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, want: true},
+		{name: "moved slot", err: redisReply("MOVED 3999 10.0.0.2:6379"), want: true},
+		{name: "replica promoted", err: redisReply("READONLY You can't write against a read only replica."), want: true},
+		{name: "loading", err: redisReply("LOADING Redis is loading the dataset in memory"), want: true},
+		{name: "wrong type", err: redisReply("WRONGTYPE Operation against a key holding the wrong kind of value"), want: false},
+		{name: "missing key", err: redis.Nil, want: false},
+		{name: "closed client", err: redis.ErrClosed, want: false},
+		{name: "cancelled", err: context.Canceled, want: false},
+		{name: "other", err: errors.New("parsing device code"), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransientStoreError(tt.err); got != tt.want {
+				t.Errorf("IsTransientStoreError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package deviceflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxScopeLength bounds the scope parameter of a device authorization request
+const MaxScopeLength = 2048
+
+// ScopePolicy returns the scopes a client may request, nil for any
+type ScopePolicy func(clientID string) []string
+
+// WithScopePolicy limits the scopes each client may request. Requests for
+// other scopes are rejected with invalid_scope rather than narrowed, so that
+// a misconfigured device fails when it is set up instead of when its token
+// is first refused.
+func WithScopePolicy(policy ScopePolicy) Option {
+	return func(f *flowImpl) {
+		f.scopes = policy
+	}
+}
+
+// NormalizeScope validates a scope parameter per RFC 6749 section 3.3 and
+// returns its scope tokens deduplicated, sorted and separated by single
+// spaces, so that equal requests compare and display alike. Scope tokens are
+// case-sensitive and are kept as given.
+func NormalizeScope(scope string) (string, error) {
+	if len(scope) > MaxScopeLength {
+		return "", NewDeviceFlowError(ErrorCodeInvalidScope,
+			fmt.Sprintf("The scope parameter exceeds %d characters", MaxScopeLength))
+	}
+
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, token := range strings.Split(scope, " ") {
+		if token == "" || seen[token] {
+			continue
+		}
+		for _, c := range token {
+			// scope-token = 1*( %x21 / %x23-5B / %x5D-7E )
+			if c < 0x21 || c > 0x7e || c == '"' || c == '\\' {
+				return "", NewDeviceFlowError(ErrorCodeInvalidScope,
+					fmt.Sprintf("The scope %q contains a character not allowed by RFC 6749 section 3.3", token))
+			}
+		}
+		seen[token] = true
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return strings.Join(tokens, " "), nil
+}
+
+// ValidateAllowedScopes checks a list of allowed scopes, each of which must be
+// a single scope token
+func ValidateAllowedScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if normalized, err := NormalizeScope(scope); err != nil || normalized != scope || strings.Contains(scope, " ") {
+			return fmt.Errorf("%q is not a single scope token", scope)
+		}
+	}
+	return nil
+}
+
+// checkScope normalizes the scope a client requests and rejects scopes the
+// client may not request
+func (f *flowImpl) checkScope(clientID, scope string) (string, error) {
+	normalized, err := NormalizeScope(scope)
+	if err != nil {
+		return "", err
+	}
+	if f.scopes == nil || normalized == "" {
+		return normalized, nil
+	}
+	allowed := f.scopes(clientID)
+	if allowed == nil {
+		return normalized, nil
+	}
+
+	permitted := make(map[string]bool, len(allowed))
+	for _, s := range allowed {
+		permitted[s] = true
+	}
+	var denied []string
+	for _, token := range strings.Split(normalized, " ") {
+		if !permitted[token] {
+			denied = append(denied, token)
+		}
+	}
+	if len(denied) > 0 {
+		return "", NewDeviceFlowError(ErrorCodeInvalidScope, deniedScopeDescription(denied, allowed))
+	}
+	return normalized, nil
+}
+
+// deniedScopeDescription tells a client which of its scopes were refused and
+// which it may request instead
+func deniedScopeDescription(denied, allowed []string) string {
+	description := fmt.Sprintf("The scope %s is not allowed for this client", denied[0])
+	if len(denied) > 1 {
+		description = fmt.Sprintf("The scopes %s are not allowed for this client", strings.Join(denied, ", "))
+	}
+	if len(allowed) == 0 {
+		return description + "; request no scope"
+	}
+	sorted := append([]string(nil), allowed...)
+	sort.Strings(sorted)
+	return description + "; allowed scopes are " + strings.Join(sorted, " ")
+}
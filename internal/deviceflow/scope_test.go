@@ -0,0 +1,87 @@
+package deviceflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeScope(t *testing.T) {
+	tests := []struct {
+		scope   string
+		want    string
+		wantErr bool
+	}{
+		{scope: "", want: ""},
+		{scope: "openid", want: "openid"},
+		{scope: "profile openid email openid", want: "email openid profile"},
+		{scope: "  openid   profile ", want: "openid profile"},
+		{scope: "Orders:Read orders:read", want: "Orders:Read orders:read"},
+		{scope: "https://api.example.com/read!#$", want: "https://api.example.com/read!#$"},
+		{scope: "openid\tprofile", wantErr: true},
+		{scope: `say"hi"`, wantErr: true},
+		{scope: `back\slash`, wantErr: true},
+		{scope: "café", wantErr: true},
+		{scope: strings.Repeat("a", MaxScopeLength+1), wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeScope(tt.scope)
+		if tt.wantErr {
+			if dferr, ok := AsDeviceFlowError(err); !ok || dferr.Code != ErrorCodeInvalidScope {
+				t.Errorf("NormalizeScope(%q) error = %v, want invalid_scope", tt.scope, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("NormalizeScope(%q) = %q, %v, want %q", tt.scope, got, err, tt.want)
+		}
+	}
+}
+
+func TestScopePolicy(t *testing.T) {
+	ctx := context.Background()
+	policy := func(clientID string) []string {
+		switch clientID {
+		case "tv":
+			return []string{"profile", "openid"}
+		case "sensor":
+			return []string{}
+		default:
+			return nil
+		}
+	}
+	flow := NewFlow(newMockStore(), "https://example.com", WithScopePolicy(policy))
+
+	code, err := flow.RequestDeviceCode(ctx, "tv", "profile openid profile")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if code.Scope != "openid profile" {
+		t.Errorf("Scope = %q, want the normalized scope", code.Scope)
+	}
+
+	tests := []struct {
+		clientID string
+		scope    string
+		wantDesc string // Empty expects success
+	}{
+		{clientID: "tv", scope: "openid admin", wantDesc: "The scope admin is not allowed for this client; allowed scopes are openid profile"},
+		{clientID: "tv", scope: "write admin", wantDesc: "The scopes admin, write are not allowed for this client; allowed scopes are openid profile"},
+		{clientID: "sensor", scope: "openid", wantDesc: "The scope openid is not allowed for this client; request no scope"},
+		{clientID: "sensor", scope: ""},
+		{clientID: "cli", scope: "anything goes"},
+	}
+	for _, tt := range tests {
+		_, err := flow.RequestDeviceCode(ctx, tt.clientID, tt.scope)
+		if tt.wantDesc == "" {
+			if err != nil {
+				t.Errorf("RequestDeviceCode(%q, %q) failed: %v", tt.clientID, tt.scope, err)
+			}
+			continue
+		}
+		dferr, ok := AsDeviceFlowError(err)
+		if !ok || dferr.Code != ErrorCodeInvalidScope || dferr.Description != tt.wantDesc {
+			t.Errorf("RequestDeviceCode(%q, %q) error = %v, want invalid_scope: %s", tt.clientID, tt.scope, err, tt.wantDesc)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Package deviceflow reports the progress of device authorization flows
+package deviceflow
+
+import (
+	"context"
+	"time"
+)
+
+// Status describes how far a device authorization flow has progressed
+type Status string
+
+const (
+	// StatusPending means the user has not yet approved or denied the request
+	StatusPending Status = "pending"
+
+	// StatusAuthorized means a token is waiting for, or was delivered to, the device
+	StatusAuthorized Status = "authorized"
+
+	// StatusDenied means the user denied the request
+	StatusDenied Status = "denied"
+
+	// StatusFailed means the authorization server ended the flow with an error
+	StatusFailed Status = "failed"
+
+	// StatusExpired means the device code has expired or no longer exists
+	StatusExpired Status = "expired"
+)
+
+// Finished reports whether the flow can no longer change state
+func (s Status) Finished() bool {
+	return s != StatusPending
+}
+
+// GetStatus reports the state of the flow for a device code. Unlike
+// CheckDeviceCode it does not count as a poll, so the verification pages can
+// call it without affecting the device's polling interval.
+func (f *flowImpl) GetStatus(ctx context.Context, deviceCode string) (Status, error) {
+	code, err := f.store.GetDeviceCode(ctx, deviceCode)
+	if err != nil {
+		return "", NewDeviceFlowError(ErrorCodeServerError, "Failed to get device code")
+	}
+	if code == nil || time.Now().After(code.ExpiresAt) {
+		return StatusExpired, nil
+	}
+
+	token, err := f.store.GetTokenResponse(ctx, deviceCode)
+	if err != nil {
+		return "", NewDeviceFlowError(ErrorCodeServerError, "Failed to get token response")
+	}
+
+	switch {
+	case token != nil:
+		return StatusAuthorized, nil
+	case code.Denied:
+		return StatusDenied, nil
+	case code.Failure != nil:
+		return StatusFailed, nil
+	default:
+		return StatusPending, nil
+	}
+}
@@ -0,0 +1,114 @@
+package deviceflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetStatus(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		update func(t *testing.T, flow Flow, store *mockStore, code *DeviceCode)
+		want   Status
+	}{
+		{
+			name: "pending",
+			want: StatusPending,
+		},
+		{
+			name: "authorized",
+			update: func(t *testing.T, flow Flow, store *mockStore, code *DeviceCode) {
+				if err := flow.CompleteAuthorization(ctx, code.DeviceCode, &TokenResponse{AccessToken: "token"}); err != nil {
+					t.Fatalf("CompleteAuthorization failed: %v", err)
+				}
+			},
+			want: StatusAuthorized,
+		},
+		{
+			name: "denied",
+			update: func(t *testing.T, flow Flow, store *mockStore, code *DeviceCode) {
+				if err := flow.DenyAuthorization(ctx, code.DeviceCode); err != nil {
+					t.Fatalf("DenyAuthorization failed: %v", err)
+				}
+			},
+			want: StatusDenied,
+		},
+		{
+			name: "failed",
+			update: func(t *testing.T, flow Flow, store *mockStore, code *DeviceCode) {
+				failure := NewDeviceFlowError(ErrorCodeInvalidScope, "Scope not allowed")
+				if err := flow.FailAuthorization(ctx, code.DeviceCode, failure); err != nil {
+					t.Fatalf("FailAuthorization failed: %v", err)
+				}
+			},
+			want: StatusFailed,
+		},
+		{
+			name: "expired",
+			update: func(t *testing.T, flow Flow, store *mockStore, code *DeviceCode) {
+				code.ExpiresAt = time.Now().Add(-time.Second)
+				if err := store.SaveDeviceCode(ctx, code); err != nil {
+					t.Fatalf("SaveDeviceCode failed: %v", err)
+				}
+			},
+			want: StatusExpired,
+		},
+		{
+			name: "deleted",
+			update: func(t *testing.T, flow Flow, store *mockStore, code *DeviceCode) {
+				if err := store.DeleteDeviceCode(ctx, code.DeviceCode); err != nil {
+					t.Fatalf("DeleteDeviceCode failed: %v", err)
+				}
+			},
+			want: StatusExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMockStore()
+			flow := NewFlow(store, "https://example.com")
+
+			code, err := flow.RequestDeviceCode(ctx, "kiosk", "openid")
+			if err != nil {
+				t.Fatalf("RequestDeviceCode failed: %v", err)
+			}
+			if tt.update != nil {
+				tt.update(t, flow, store, code)
+			}
+
+			got, err := flow.GetStatus(ctx, code.DeviceCode)
+			if err != nil {
+				t.Fatalf("GetStatus failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetStatus() = %q, want %q", got, tt.want)
+			}
+			if got.Finished() != (tt.want != StatusPending) {
+				t.Errorf("Finished() = %v for %q", got.Finished(), got)
+			}
+		})
+	}
+}
+
+func TestGetStatusDoesNotCountAsPoll(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "kiosk", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := flow.GetStatus(ctx, code.DeviceCode); err != nil {
+			t.Fatalf("GetStatus failed: %v", err)
+		}
+	}
+	if polls := len(store.polls[code.DeviceCode]); polls != 0 {
+		t.Errorf("recorded %d polls, want none", polls)
+	}
+}
@@ -6,6 +6,13 @@ import (
 	"time"
 )
 
+// PollLimit bounds how often a device may poll the token endpoint per RFC 8628 section 3.5
+type PollLimit struct {
+	Interval time.Duration // Minimum time between polls
+	Window   time.Duration // Window over which MaxPolls applies
+	MaxPolls int           // Maximum polls per window, 0 disables the window check
+}
+
 // Store defines the interface for device flow storage
 type Store interface {
 	// SaveDeviceCode stores a device code with its associated data
@@ -14,27 +21,102 @@ type Store interface {
 	// GetDeviceCode retrieves a device code by its device code string
 	GetDeviceCode(ctx context.Context, deviceCode string) (*DeviceCode, error)
 
-	// GetDeviceCodeByUserCode retrieves a device code by its user code
+	// ReplaceUserCode saves a device code whose user code changed and removes
+	// the reference from its previous user code
+	ReplaceUserCode(ctx context.Context, code *DeviceCode, previousUserCode string) error
+
+	// GetDeviceCodeByUserCode retrieves a device code by its user code. Stores
+	// keeping tombstones return one with Tombstone set once the code is gone.
 	GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*DeviceCode, error)
 
 	// GetTokenResponse retrieves token response for a device code
 	GetTokenResponse(ctx context.Context, deviceCode string) (*TokenResponse, error)
 
-	// SaveTokenResponse stores token response for a device code
+	// GetPollState retrieves a device code together with its token response, if
+	// any, in a single round trip for the token endpoint's polling path. Like
+	// GetDeviceCodeByUserCode it may return a tombstone for a code that is gone.
+	GetPollState(ctx context.Context, deviceCode string) (*DeviceCode, *TokenResponse, error)
+
+	// SaveTokenResponse stores token response for a device code, returning
+	// ErrAlreadyAuthorized if a token was already stored for it
 	SaveTokenResponse(ctx context.Context, deviceCode string, token *TokenResponse) error
 
 	// DeleteDeviceCode removes a device code and its associated data
 	DeleteDeviceCode(ctx context.Context, deviceCode string) error
 
+	// BatchGetDeviceCodes retrieves several device codes in one round trip,
+	// returning them in order with nil for codes that are not stored
+	BatchGetDeviceCodes(ctx context.Context, deviceCodes []string) ([]*DeviceCode, error)
+
+	// BatchDeleteDeviceCodes removes several device codes and their associated
+	// data, skipping codes that are not stored
+	BatchDeleteDeviceCodes(ctx context.Context, deviceCodes []string) error
+
 	// GetPollCount gets the number of polls in the given window
 	GetPollCount(ctx context.Context, deviceCode string, window time.Duration) (int, error)
 
-	// UpdatePollTimestamp updates the last poll timestamp for rate limiting
-	UpdatePollTimestamp(ctx context.Context, deviceCode string) error
+	// RecordPoll atomically applies the polling limits to a token request and,
+	// when it is allowed, records the poll. It reports whether the poll was allowed.
+	RecordPoll(ctx context.Context, code *DeviceCode, limit PollLimit) (bool, error)
+
+	// SavePollInterval raises the stored polling interval of a device code to
+	// code.Interval without rewriting the rest of the record, which others may
+	// have changed since code was read
+	SavePollInterval(ctx context.Context, code *DeviceCode) error
+
+	// EndAuthorization marks a pending device code denied, or failed with
+	// failure when it is not nil, and returns the updated record, or nil when
+	// the code is gone. The check and the write are atomic, so it returns
+	// ErrAlreadyAuthorized rather than ending a flow a token was stored for,
+	// and ErrAuthorizationEnded for a flow already denied or failed.
+	EndAuthorization(ctx context.Context, deviceCode string, failure *DeviceFlowError) (*DeviceCode, error)
 
 	// IncrementPollCount increments the poll counter for rate limiting
 	IncrementPollCount(ctx context.Context, deviceCode string) error
 
+	// ClaimSubmission stores a pending result for a form nonce if none exists yet,
+	// returning nil when claimed or the previously stored result otherwise
+	ClaimSubmission(ctx context.Context, nonce string, ttl time.Duration) (*SubmissionResult, error)
+
+	// SaveSubmission stores the result of a claimed form submission
+	SaveSubmission(ctx context.Context, nonce string, result *SubmissionResult, ttl time.Duration) error
+
+	// SaveBatch stores a device code batch until its codes expire
+	SaveBatch(ctx context.Context, batch *Batch) error
+
+	// GetBatch retrieves a device code batch by its identifier
+	GetBatch(ctx context.Context, batchID string) (*Batch, error)
+
+	// SaveConsentTicket maps a consent ticket to the device code awaiting approval
+	SaveConsentTicket(ctx context.Context, ticket, deviceCode string, ttl time.Duration) error
+
+	// ConsumeConsentTicket atomically removes a consent ticket, returning its
+	// device code or "" if it is unknown or was already consumed
+	ConsumeConsentTicket(ctx context.Context, ticket string) (string, error)
+
+	// SaveCallbackNonce maps a single-use authorization callback nonce to its device code
+	SaveCallbackNonce(ctx context.Context, nonce, deviceCode string, ttl time.Duration) error
+
+	// ConsumeCallbackNonce atomically removes a callback nonce, returning its
+	// device code or "" if it is unknown or was already consumed
+	ConsumeCallbackNonce(ctx context.Context, nonce string) (string, error)
+
+	// ListDeviceCodesByClient returns the unexpired device codes issued to a
+	// client, including authorized codes whose token awaits pickup
+	ListDeviceCodesByClient(ctx context.Context, clientID string) ([]string, error)
+
+	// CountDeviceCodesByClient returns the number of unexpired device codes
+	// issued to a client, as ListDeviceCodesByClient would list
+	CountDeviceCodesByClient(ctx context.Context, clientID string) (int, error)
+
+	// CountPendingDeviceCodes returns the number of unexpired device codes that
+	// have not yet been authorized, denied or failed, leaving out pre-generated
+	// batch codes
+	CountPendingDeviceCodes(ctx context.Context) (int, error)
+
+	// Cleanup removes expired device codes and orphaned references to them
+	Cleanup(ctx context.Context) (*CleanupResult, error)
+
 	// CheckHealth verifies the storage backend is healthy
 	CheckHealth(ctx context.Context) error
 }
@@ -0,0 +1,42 @@
+// Package deviceflow implements duplicate form submission handling for OAuth 2.0 Device Flow
+package deviceflow
+
+import (
+	"context"
+)
+
+// ClaimSubmission claims a verification form nonce for processing. It returns nil when
+// the caller owns the submission, or the earlier result when the form was already posted.
+// Results may still be pending if the original request has not finished processing.
+func (f *flowImpl) ClaimSubmission(ctx context.Context, nonce string) (*SubmissionResult, error) {
+	if nonce == "" {
+		return nil, nil // Nothing to deduplicate against
+	}
+
+	result, err := f.store.ClaimSubmission(ctx, nonce, f.submissionWindow)
+	if err != nil {
+		return nil, NewDeviceFlowError(
+			ErrorCodeServerError,
+			"Failed to claim form submission",
+		)
+	}
+
+	return result, nil
+}
+
+// RecordSubmission stores the outcome of a claimed form submission so that duplicate
+// posts within the submission window can be answered with the same result
+func (f *flowImpl) RecordSubmission(ctx context.Context, nonce string, result *SubmissionResult) error {
+	if nonce == "" || result == nil {
+		return nil
+	}
+
+	if err := f.store.SaveSubmission(ctx, nonce, result, f.submissionWindow); err != nil {
+		return NewDeviceFlowError(
+			ErrorCodeServerError,
+			"Failed to record form submission",
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+// Package deviceflow implements duplicate form submission tests
+package deviceflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSubmissionDeduplication(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	// First claim owns the submission
+	prior, err := flow.ClaimSubmission(ctx, "nonce-1")
+	if err != nil {
+		t.Fatalf("ClaimSubmission failed: %v", err)
+	}
+	if prior != nil {
+		t.Fatalf("first claim should return nil, got %+v", prior)
+	}
+
+	// Duplicate before completion sees a pending result
+	prior, err = flow.ClaimSubmission(ctx, "nonce-1")
+	if err != nil {
+		t.Fatalf("ClaimSubmission failed: %v", err)
+	}
+	if prior == nil || !prior.Pending {
+		t.Fatalf("duplicate claim should be pending, got %+v", prior)
+	}
+
+	// Duplicate after completion sees the recorded result
+	if err := flow.RecordSubmission(ctx, "nonce-1", &SubmissionResult{DeviceCode: "device-123"}); err != nil {
+		t.Fatalf("RecordSubmission failed: %v", err)
+	}
+	prior, err = flow.ClaimSubmission(ctx, "nonce-1")
+	if err != nil {
+		t.Fatalf("ClaimSubmission failed: %v", err)
+	}
+	if prior == nil || prior.Pending || prior.DeviceCode != "device-123" {
+		t.Errorf("duplicate claim should return recorded result, got %+v", prior)
+	}
+
+	// Other nonces are independent
+	prior, err = flow.ClaimSubmission(ctx, "nonce-2")
+	if err != nil {
+		t.Fatalf("ClaimSubmission failed: %v", err)
+	}
+	if prior != nil {
+		t.Errorf("unrelated nonce should be claimable, got %+v", prior)
+	}
+}
+
+func TestSubmissionEmptyNonce(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	for i := 0; i < 2; i++ {
+		prior, err := flow.ClaimSubmission(ctx, "")
+		if err != nil || prior != nil {
+			t.Errorf("empty nonce should never deduplicate, got %+v, %v", prior, err)
+		}
+	}
+	if len(store.submissions) != 0 {
+		t.Errorf("empty nonce should not be stored, got %d entries", len(store.submissions))
+	}
+}
+
+func TestSubmissionStoreError(t *testing.T) {
+	store := newMockStore()
+	store.healthy = false
+	flow := NewFlow(store, "https://example.com")
+
+	_, err := flow.ClaimSubmission(context.Background(), "nonce")
+	dferr, ok := AsDeviceFlowError(err)
+	if !ok || dferr.Code != ErrorCodeServerError {
+		t.Errorf("expected server_error, got %v", err)
+	}
+}
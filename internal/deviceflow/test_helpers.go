@@ -21,6 +21,10 @@ type mockStore struct {
 	tokens       map[string]*TokenResponse
 	polls        map[string][]time.Time // device code -> poll timestamps
 	attempts     map[string]int         // device code -> verification attempts
+	submissions  map[string]*SubmissionResult
+	batches      map[string]*Batch
+	consents     map[string]string // consent ticket -> device code
+	callbacks    map[string]string // callback nonce -> device code
 	healthy      bool
 	mockUserCode string // For testing specific user code scenarios
 }
@@ -32,6 +36,10 @@ func newMockStore() *mockStore {
 		tokens:      make(map[string]*TokenResponse),
 		polls:       make(map[string][]time.Time),
 		attempts:    make(map[string]int),
+		submissions: make(map[string]*SubmissionResult),
+		batches:     make(map[string]*Batch),
+		consents:    make(map[string]string),
+		callbacks:   make(map[string]string),
 		healthy:     true,
 	}
 }
@@ -43,6 +51,11 @@ func (m *mockStore) SaveDeviceCode(ctx context.Context, code *DeviceCode) error
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.saveDeviceCodeLocked(code)
+	return nil
+}
+
+func (m *mockStore) saveDeviceCodeLocked(code *DeviceCode) {
 	// Override user code for testing if mock code is set
 	if m.mockUserCode != "" {
 		code.UserCode = m.mockUserCode
@@ -50,6 +63,55 @@ func (m *mockStore) SaveDeviceCode(ctx context.Context, code *DeviceCode) error
 
 	m.deviceCodes[code.DeviceCode] = code
 	m.userCodes[validation.NormalizeCode(code.UserCode)] = code.DeviceCode
+}
+
+// Transact applies the queued writes under one lock, and none of them if fn fails
+func (m *mockStore) Transact(ctx context.Context, fn func(tx Tx) error) error {
+	if !m.healthy {
+		return ErrStoreUnhealthy
+	}
+	tx := &mockTx{}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, code := range tx.codes {
+		m.saveDeviceCodeLocked(code)
+	}
+	for _, batch := range tx.batches {
+		m.saveBatchLocked(batch)
+	}
+	return nil
+}
+
+// mockTx collects the writes of a mockStore transaction
+type mockTx struct {
+	codes   []*DeviceCode
+	batches []*Batch
+}
+
+func (tx *mockTx) SaveDeviceCode(code *DeviceCode) error {
+	tx.codes = append(tx.codes, code)
+	return nil
+}
+
+func (tx *mockTx) SaveBatch(batch *Batch) error {
+	tx.batches = append(tx.batches, batch)
+	return nil
+}
+
+func (m *mockStore) ReplaceUserCode(ctx context.Context, code *DeviceCode, previousUserCode string) error {
+	if !m.healthy {
+		return ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.userCodes, validation.NormalizeCode(previousUserCode))
+	m.deviceCodes[code.DeviceCode] = code
+	m.userCodes[validation.NormalizeCode(code.UserCode)] = code.DeviceCode
 	return nil
 }
 
@@ -65,19 +127,9 @@ func (m *mockStore) GetDeviceCode(ctx context.Context, deviceCode string) (*Devi
 		return nil, nil
 	}
 
-	// Return a copy to prevent mutation, only copying fields defined in models.DeviceCode
-	return &DeviceCode{
-		DeviceCode:              code.DeviceCode,
-		UserCode:                code.UserCode,
-		VerificationURI:         code.VerificationURI,
-		VerificationURIComplete: code.VerificationURIComplete,
-		ExpiresIn:               code.ExpiresIn,
-		Interval:                code.Interval,
-		ExpiresAt:               code.ExpiresAt,
-		ClientID:                code.ClientID,
-		Scope:                   code.Scope,
-		LastPoll:                code.LastPoll,
-	}, nil
+	// Return a copy to prevent mutation
+	copied := *code
+	return &copied, nil
 }
 
 func (m *mockStore) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (*DeviceCode, error) {
@@ -97,19 +149,21 @@ func (m *mockStore) GetDeviceCodeByUserCode(ctx context.Context, userCode string
 		return nil, nil
 	}
 
-	// Return a copy to prevent mutation, only copying fields defined in models.DeviceCode
-	return &DeviceCode{
-		DeviceCode:              code.DeviceCode,
-		UserCode:                code.UserCode,
-		VerificationURI:         code.VerificationURI,
-		VerificationURIComplete: code.VerificationURIComplete,
-		ExpiresIn:               code.ExpiresIn,
-		Interval:                code.Interval,
-		ExpiresAt:               code.ExpiresAt,
-		ClientID:                code.ClientID,
-		Scope:                   code.Scope,
-		LastPoll:                code.LastPoll,
-	}, nil
+	// Return a copy to prevent mutation
+	copied := *code
+	return &copied, nil
+}
+
+func (m *mockStore) GetPollState(ctx context.Context, deviceCode string) (*DeviceCode, *TokenResponse, error) {
+	code, err := m.GetDeviceCode(ctx, deviceCode)
+	if err != nil || code == nil {
+		return nil, nil, err
+	}
+	token, err := m.GetTokenResponse(ctx, deviceCode)
+	if err != nil {
+		return nil, nil, err
+	}
+	return code, token, nil
 }
 
 func (m *mockStore) GetTokenResponse(ctx context.Context, deviceCode string) (*TokenResponse, error) {
@@ -131,6 +185,9 @@ func (m *mockStore) GetTokenResponse(ctx context.Context, deviceCode string) (*T
 		ExpiresIn:    token.ExpiresIn,
 		RefreshToken: token.RefreshToken,
 		Scope:        token.Scope,
+		IDToken:      token.IDToken,
+		Identity:     token.Identity,
+		Offline:      token.Offline,
 	}, nil
 }
 
@@ -141,6 +198,10 @@ func (m *mockStore) SaveTokenResponse(ctx context.Context, deviceCode string, to
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if _, ok := m.tokens[deviceCode]; ok {
+		return ErrAlreadyAuthorized
+	}
+
 	// Save a copy to prevent mutation
 	m.tokens[deviceCode] = &TokenResponse{
 		AccessToken:  token.AccessToken,
@@ -148,6 +209,9 @@ func (m *mockStore) SaveTokenResponse(ctx context.Context, deviceCode string, to
 		ExpiresIn:    token.ExpiresIn,
 		RefreshToken: token.RefreshToken,
 		Scope:        token.Scope,
+		IDToken:      token.IDToken,
+		Identity:     token.Identity,
+		Offline:      token.Offline,
 	}
 	return nil
 }
@@ -159,16 +223,45 @@ func (m *mockStore) DeleteDeviceCode(ctx context.Context, deviceCode string) err
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.deleteDeviceCodeLocked(deviceCode)
+	return nil
+}
+
+func (m *mockStore) BatchGetDeviceCodes(ctx context.Context, deviceCodes []string) ([]*DeviceCode, error) {
+	codes := make([]*DeviceCode, len(deviceCodes))
+	for i, deviceCode := range deviceCodes {
+		code, err := m.GetDeviceCode(ctx, deviceCode)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+	return codes, nil
+}
+
+func (m *mockStore) BatchDeleteDeviceCodes(ctx context.Context, deviceCodes []string) error {
+	if !m.healthy {
+		return ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, deviceCode := range deviceCodes {
+		m.deleteDeviceCodeLocked(deviceCode)
+	}
+	return nil
+}
+
+func (m *mockStore) deleteDeviceCodeLocked(deviceCode string) {
 	code, exists := m.deviceCodes[deviceCode]
 	if !exists {
-		return nil
+		return
 	}
 	delete(m.deviceCodes, deviceCode)
 	delete(m.userCodes, validation.NormalizeCode(code.UserCode))
 	delete(m.tokens, deviceCode)
 	delete(m.polls, deviceCode)
 	delete(m.attempts, deviceCode) // Also clean up attempts
-	return nil
 }
 
 func (m *mockStore) GetPollCount(ctx context.Context, deviceCode string, window time.Duration) (int, error) {
@@ -194,22 +287,77 @@ func (m *mockStore) GetPollCount(ctx context.Context, deviceCode string, window
 	return count, nil
 }
 
-func (m *mockStore) UpdatePollTimestamp(ctx context.Context, deviceCode string) error {
+func (m *mockStore) RecordPoll(ctx context.Context, code *DeviceCode, limit PollLimit) (bool, error) {
 	if !m.healthy {
-		return ErrStoreUnhealthy
+		return false, ErrStoreUnhealthy
 	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	code, exists := m.deviceCodes[deviceCode]
+	now := time.Now()
+	stored, exists := m.deviceCodes[code.DeviceCode]
 	if !exists {
-		return nil
+		return false, ErrInvalidDeviceCode
+	}
+	if now.Sub(stored.LastPoll) < limit.Interval {
+		return false, nil
 	}
 
-	code.LastPoll = time.Now()
+	if limit.MaxPolls > 0 {
+		cutoff := now.Add(-limit.Window)
+		count := 0
+		for _, ts := range m.polls[code.DeviceCode] {
+			if ts.After(cutoff) {
+				count++
+			}
+		}
+		if count >= limit.MaxPolls {
+			return false, nil
+		}
+	}
+
+	stored.LastPoll = now
+	m.polls[code.DeviceCode] = append(m.polls[code.DeviceCode], now)
+	return true, nil
+}
+
+func (m *mockStore) SavePollInterval(ctx context.Context, code *DeviceCode) error {
+	if !m.healthy {
+		return ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stored, exists := m.deviceCodes[code.DeviceCode]; exists && stored.Interval < code.Interval {
+		stored.Interval = code.Interval
+	}
 	return nil
 }
 
+func (m *mockStore) EndAuthorization(ctx context.Context, deviceCode string, failure *DeviceFlowError) (*DeviceCode, error) {
+	if !m.healthy {
+		return nil, ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.tokens[deviceCode]; ok {
+		return nil, ErrAlreadyAuthorized
+	}
+	stored, exists := m.deviceCodes[deviceCode]
+	if !exists {
+		return nil, nil
+	}
+	if stored.Denied || stored.Failure != nil {
+		return nil, ErrAuthorizationEnded
+	}
+	stored.Denied = failure == nil
+	stored.Failure = failure
+
+	ended := *stored
+	return &ended, nil
+}
+
 func (m *mockStore) IncrementPollCount(ctx context.Context, deviceCode string) error {
 	if !m.healthy {
 		return ErrStoreUnhealthy
@@ -233,6 +381,191 @@ func (m *mockStore) GetVerificationAttempts(deviceCode string) int {
 	return m.attempts[deviceCode]
 }
 
+func (m *mockStore) ClaimSubmission(ctx context.Context, nonce string, ttl time.Duration) (*SubmissionResult, error) {
+	if !m.healthy {
+		return nil, ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, exists := m.submissions[nonce]; exists {
+		result := *existing
+		return &result, nil
+	}
+	m.submissions[nonce] = &SubmissionResult{Pending: true}
+	return nil, nil
+}
+
+func (m *mockStore) SaveSubmission(ctx context.Context, nonce string, result *SubmissionResult, ttl time.Duration) error {
+	if !m.healthy {
+		return ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	saved := *result
+	m.submissions[nonce] = &saved
+	return nil
+}
+
+func (m *mockStore) SaveBatch(ctx context.Context, batch *Batch) error {
+	if !m.healthy {
+		return ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.saveBatchLocked(batch)
+	return nil
+}
+
+func (m *mockStore) saveBatchLocked(batch *Batch) {
+	saved := *batch
+	saved.DeviceCodes = append([]string(nil), batch.DeviceCodes...)
+	m.batches[batch.ID] = &saved
+}
+
+func (m *mockStore) GetBatch(ctx context.Context, batchID string) (*Batch, error) {
+	if !m.healthy {
+		return nil, ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	batch, exists := m.batches[batchID]
+	if !exists {
+		return nil, nil
+	}
+
+	copied := *batch
+	copied.DeviceCodes = append([]string(nil), batch.DeviceCodes...)
+	return &copied, nil
+}
+
+func (m *mockStore) SaveConsentTicket(ctx context.Context, ticket, deviceCode string, ttl time.Duration) error {
+	if !m.healthy {
+		return ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.consents[ticket] = deviceCode
+	return nil
+}
+
+func (m *mockStore) SaveCallbackNonce(ctx context.Context, nonce, deviceCode string, ttl time.Duration) error {
+	if !m.healthy {
+		return ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.callbacks[nonce] = deviceCode
+	return nil
+}
+
+func (m *mockStore) ConsumeCallbackNonce(ctx context.Context, nonce string) (string, error) {
+	if !m.healthy {
+		return "", ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deviceCode := m.callbacks[nonce]
+	delete(m.callbacks, nonce)
+	return deviceCode, nil
+}
+
+func (m *mockStore) ConsumeConsentTicket(ctx context.Context, ticket string) (string, error) {
+	if !m.healthy {
+		return "", ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deviceCode := m.consents[ticket]
+	delete(m.consents, ticket)
+	return deviceCode, nil
+}
+
+func (m *mockStore) ListDeviceCodesByClient(ctx context.Context, clientID string) ([]string, error) {
+	if !m.healthy {
+		return nil, ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deviceCodes []string
+	now := time.Now()
+	for deviceCode, code := range m.deviceCodes {
+		if code.ClientID == clientID && now.Before(code.ExpiresAt) {
+			deviceCodes = append(deviceCodes, deviceCode)
+		}
+	}
+	return deviceCodes, nil
+}
+
+func (m *mockStore) CountDeviceCodesByClient(ctx context.Context, clientID string) (int, error) {
+	deviceCodes, err := m.ListDeviceCodesByClient(ctx, clientID)
+	return len(deviceCodes), err
+}
+
+func (m *mockStore) CountPendingDeviceCodes(ctx context.Context) (int, error) {
+	if !m.healthy {
+		return 0, ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	now := time.Now()
+	for deviceCode, code := range m.deviceCodes {
+		if _, done := m.tokens[deviceCode]; done || code.Denied || code.Failure != nil || now.After(code.ExpiresAt) || code.BatchID != "" {
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (m *mockStore) Cleanup(ctx context.Context) (*CleanupResult, error) {
+	if !m.healthy {
+		return nil, ErrStoreUnhealthy
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := &CleanupResult{}
+	now := time.Now()
+	for deviceCode, code := range m.deviceCodes {
+		if now.After(code.ExpiresAt) {
+			delete(m.deviceCodes, deviceCode)
+			result.ExpiredCodes++
+			if _, done := m.tokens[deviceCode]; !done && !code.Denied && code.Failure == nil {
+				result.Expired = append(result.Expired, code)
+			}
+		}
+	}
+	for userCode, deviceCode := range m.userCodes {
+		if _, ok := m.deviceCodes[deviceCode]; !ok {
+			delete(m.userCodes, userCode)
+			result.OrphanedUserCodes++
+		}
+	}
+	for deviceCode := range m.polls {
+		if _, ok := m.deviceCodes[deviceCode]; !ok {
+			delete(m.polls, deviceCode)
+			result.OrphanedPollCounters++
+		}
+	}
+	for deviceCode, code := range m.deviceCodes {
+		if _, done := m.tokens[deviceCode]; !done && !code.Denied && code.Failure == nil {
+			result.Pending++
+		}
+	}
+	return result, nil
+}
+
 func (m *mockStore) CheckHealth(ctx context.Context) error {
 	if !m.healthy {
 		return ErrStoreUnhealthy
@@ -0,0 +1,84 @@
+package deviceflow
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// Flow stages measured from the timestamps of device codes
+const (
+	stageVerification  = "verification"  // Requested until the user code was entered
+	stageAuthorization = "authorization" // Verified until the user approved
+	stageDelivery      = "delivery"      // Approved until the device received the token
+	stageTotal         = "total"         // Requested until the device received the token
+)
+
+// markVerified records when the user code was first entered
+func (f *flowImpl) markVerified(ctx context.Context, code *DeviceCode) {
+	if !code.VerifiedAt.IsZero() {
+		return
+	}
+	code.VerifiedAt = time.Now()
+	if f.saveTimeline(ctx, code, "verification") {
+		observeStage(stageVerification, code.RequestedAt, code.VerifiedAt)
+	}
+}
+
+// markAuthorized records when the token was stored after user approval
+func (f *flowImpl) markAuthorized(ctx context.Context, code *DeviceCode) {
+	code.AuthorizedAt = time.Now()
+	if f.saveTimeline(ctx, code, "authorization") {
+		observeStage(stageAuthorization, code.VerifiedAt, code.AuthorizedAt)
+	}
+}
+
+// markConsumed records when the token was first delivered to the device
+func (f *flowImpl) markConsumed(ctx context.Context, code *DeviceCode) {
+	if !code.ConsumedAt.IsZero() {
+		return
+	}
+	code.ConsumedAt = time.Now()
+	if f.saveTimeline(ctx, code, "consumption") {
+		observeStage(stageDelivery, code.AuthorizedAt, code.ConsumedAt)
+		observeStage(stageTotal, code.RequestedAt, code.ConsumedAt)
+	}
+}
+
+// saveTimeline persists a timestamp added to a code. The flow carries on
+// without it, so failures are only logged and the stage is not measured.
+func (f *flowImpl) saveTimeline(ctx context.Context, code *DeviceCode, step string) bool {
+	if err := f.store.SaveDeviceCode(ctx, code); err != nil {
+		log.Printf("Warning: failed to persist %s time: %v", step, err)
+		return false
+	}
+	return true
+}
+
+// observeStage adds the duration of a completed stage to the flow metrics.
+// Stages of codes stored before the timeline was recorded are skipped.
+func observeStage(stage string, from, to time.Time) {
+	if from.IsZero() || to.Before(from) {
+		return
+	}
+	flowStageSeconds.Add(to.Sub(from).Seconds(), stage)
+	flowStageCount.Inc(stage)
+}
+
+var (
+	// flowStageSeconds sums the time device flows spent in each stage
+	flowStageSeconds = metrics.Default.NewCounter(
+		"device_proxy_flow_stage_seconds_total",
+		"Time device flows spent in each stage; divide by device_proxy_flow_stages_total for the mean.",
+		"stage",
+	)
+
+	// flowStageCount counts the measured stages
+	flowStageCount = metrics.Default.NewCounter(
+		"device_proxy_flow_stages_total",
+		"Device flow stages completed with a measured duration.",
+		"stage",
+	)
+)
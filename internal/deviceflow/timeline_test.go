@@ -0,0 +1,70 @@
+package deviceflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFlowTimeline(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	stored := func(deviceCode string) DeviceCode {
+		t.Helper()
+		code, err := store.GetDeviceCode(ctx, deviceCode)
+		if err != nil || code == nil {
+			t.Fatalf("GetDeviceCode = %v, %v", code, err)
+		}
+		return *code
+	}
+
+	code, err := flow.RequestDeviceCode(ctx, "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if code.RequestedAt.IsZero() || !code.VerifiedAt.IsZero() {
+		t.Fatalf("new code timeline = %v/%v, want only requested", code.RequestedAt, code.VerifiedAt)
+	}
+
+	if _, err := flow.VerifyUserCode(ctx, code.UserCode); err != nil {
+		t.Fatalf("VerifyUserCode failed: %v", err)
+	}
+	verifiedAt := stored(code.DeviceCode).VerifiedAt
+	if verifiedAt.IsZero() {
+		t.Fatal("VerifiedAt not recorded")
+	}
+
+	// Entering the code again keeps the first verification time
+	if _, err := flow.VerifyUserCode(ctx, code.UserCode); err != nil {
+		t.Fatalf("VerifyUserCode failed: %v", err)
+	}
+	if got := stored(code.DeviceCode).VerifiedAt; !got.Equal(verifiedAt) {
+		t.Errorf("VerifiedAt = %v after reentry, want %v", got, verifiedAt)
+	}
+
+	totals := flowStageCount.Value(stageTotal)
+	if err := flow.CompleteAuthorization(ctx, code.DeviceCode, &TokenResponse{AccessToken: "at", TokenType: "Bearer"}); err != nil {
+		t.Fatalf("CompleteAuthorization failed: %v", err)
+	}
+	authorized := stored(code.DeviceCode)
+	if authorized.AuthorizedAt.Before(verifiedAt) || !authorized.ConsumedAt.IsZero() {
+		t.Errorf("timeline after approval = %v/%v, want authorized only", authorized.AuthorizedAt, authorized.ConsumedAt)
+	}
+	if pending, _ := store.CountPendingDeviceCodes(ctx); pending != 0 {
+		t.Errorf("pending codes = %d after approval, want 0", pending)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); err != nil {
+			t.Fatalf("CheckDeviceCode failed: %v", err)
+		}
+	}
+	consumed := stored(code.DeviceCode)
+	if consumed.ConsumedAt.Before(consumed.AuthorizedAt) {
+		t.Errorf("ConsumedAt = %v, want after AuthorizedAt %v", consumed.ConsumedAt, consumed.AuthorizedAt)
+	}
+	if got := flowStageCount.Value(stageTotal) - totals; got != 1 {
+		t.Errorf("total stage measured %v times, want once per flow", got)
+	}
+}
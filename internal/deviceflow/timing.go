@@ -0,0 +1,70 @@
+package deviceflow
+
+import "time"
+
+// DefaultMaxPollInterval bounds the polling interval a client may ask for
+const DefaultMaxPollInterval = time.Minute
+
+// TimingPolicy returns a client's code lifetime and polling interval, zero
+// for the flow's defaults
+type TimingPolicy func(clientID string) (expiry, interval time.Duration)
+
+// WithTimingPolicy sets per-client code lifetimes and polling intervals, such
+// as longer windows for kiosks that take a while to provision. Values are
+// bounded like requested ones.
+func WithTimingPolicy(policy TimingPolicy) Option {
+	return func(f *flowImpl) {
+		f.timing = policy
+	}
+}
+
+// WithMaxPollInterval bounds the polling interval a client may ask for,
+// DefaultMaxPollInterval by default. Intervals grown by slow_down are not capped.
+func WithMaxPollInterval(d time.Duration) Option {
+	return func(f *flowImpl) {
+		if d > 0 {
+			f.maxPollInterval = d
+		}
+	}
+}
+
+// WithRequestedTiming asks for a code lifetime and polling interval other
+// than the client's defaults. Zero values are ignored. Requests are hints: the
+// lifetime is kept between MinExpiryDuration and the flow's expiry cap, and
+// the interval between the flow's poll interval and its maximum.
+func WithRequestedTiming(expiry, interval time.Duration) RequestOption {
+	return func(code *DeviceCode) {
+		setTiming(code, expiry, interval)
+	}
+}
+
+// setTiming overrides the lifetime and interval of a new code in whole seconds
+func setTiming(code *DeviceCode, expiry, interval time.Duration) {
+	if expiry > 0 {
+		code.ExpiresIn = int(expiry / time.Second)
+	}
+	if interval > 0 {
+		code.Interval = int((interval + time.Second - 1) / time.Second) // Rounded up
+	}
+}
+
+// applyClientTiming sets a new code's lifetime and interval from the timing policy
+func (f *flowImpl) applyClientTiming(code *DeviceCode) {
+	if f.timing == nil {
+		return
+	}
+	expiry, interval := f.timing(code.ClientID)
+	setTiming(code, expiry, interval)
+}
+
+// boundTiming clamps a new code's lifetime and interval to the flow's bounds
+// and recomputes its expiry time
+func (f *flowImpl) boundTiming(code *DeviceCode) {
+	minExpiry, maxExpiry := int(MinExpiryDuration.Seconds()), int(f.maxExpiryDuration.Seconds())
+	code.ExpiresIn = min(max(code.ExpiresIn, minExpiry), maxExpiry)
+	code.ExpiresAt = code.RequestedAt.Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	minInterval := int((f.pollInterval + time.Second - 1) / time.Second)
+	maxInterval := max(int(f.maxPollInterval.Seconds()), minInterval)
+	code.Interval = min(max(code.Interval, minInterval), maxInterval)
+}
@@ -0,0 +1,69 @@
+package deviceflow
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRequestTiming(t *testing.T) {
+	policy := func(clientID string) (time.Duration, time.Duration) {
+		if clientID == "kiosk" {
+			return 25 * time.Minute, 10 * time.Second
+		}
+		return 0, 0
+	}
+	flow := NewFlow(newMockStore(), "https://example.com",
+		WithExpiryDuration(15*time.Minute),
+		WithMaxExpiryDuration(30*time.Minute),
+		WithPollInterval(5*time.Second),
+		WithMaxPollInterval(30*time.Second),
+		WithTimingPolicy(policy))
+
+	tests := []struct {
+		name         string
+		clientID     string
+		opts         []RequestOption
+		wantExpiry   int
+		wantInterval int
+	}{
+		{name: "defaults", clientID: "tv", wantExpiry: 900, wantInterval: 5},
+		{name: "client settings", clientID: "kiosk", wantExpiry: 1500, wantInterval: 10},
+		{
+			name:         "requested within bounds",
+			clientID:     "kiosk",
+			opts:         []RequestOption{WithRequestedTiming(20*time.Minute, 15*time.Second)},
+			wantExpiry:   1200,
+			wantInterval: 15,
+		},
+		{
+			name:         "requested above bounds",
+			clientID:     "tv",
+			opts:         []RequestOption{WithRequestedTiming(2*time.Hour, time.Hour)},
+			wantExpiry:   1800,
+			wantInterval: 30,
+		},
+		{
+			name:         "requested below bounds",
+			clientID:     "tv",
+			opts:         []RequestOption{WithRequestedTiming(time.Minute, time.Second)},
+			wantExpiry:   600,
+			wantInterval: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := flow.RequestDeviceCode(context.Background(), tt.clientID, "", tt.opts...)
+			if err != nil {
+				t.Fatalf("RequestDeviceCode failed: %v", err)
+			}
+			if code.ExpiresIn != tt.wantExpiry || code.Interval != tt.wantInterval {
+				t.Errorf("expires_in, interval = %d, %d; want %d, %d", code.ExpiresIn, code.Interval, tt.wantExpiry, tt.wantInterval)
+			}
+			if got := code.ExpiresAt.Sub(code.RequestedAt); got != time.Duration(tt.wantExpiry)*time.Second {
+				t.Errorf("code expires %s after the request, want %ds", got, tt.wantExpiry)
+			}
+		})
+	}
+}
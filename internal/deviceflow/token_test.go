@@ -249,3 +249,114 @@ func TestCompleteAuthorization(t *testing.T) {
 		})
 	}
 }
+
+func TestCompleteAuthorizationOnce(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "tv", "openid")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	if err := flow.CompleteAuthorization(ctx, code.DeviceCode, &TokenResponse{AccessToken: "first"}); err != nil {
+		t.Fatalf("first CompleteAuthorization() error = %v", err)
+	}
+	err = flow.CompleteAuthorization(ctx, code.DeviceCode, &TokenResponse{AccessToken: "second"})
+	if !errors.Is(err, ErrAlreadyAuthorized) {
+		t.Errorf("second CompleteAuthorization() error = %v, want %v", err, ErrAlreadyAuthorized)
+	}
+
+	token, err := store.GetTokenResponse(ctx, code.DeviceCode)
+	if err != nil || token == nil || token.AccessToken != "first" {
+		t.Errorf("stored token = %+v, %v, want first completion", token, err)
+	}
+}
+
+func TestCheckDeviceCodePollLimits(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("interval", func(t *testing.T) {
+		store := newMockStore()
+		flow := NewFlow(store, "https://example.com", WithPollInterval(5*time.Second))
+		code, err := flow.RequestDeviceCode(ctx, "tv", "")
+		if err != nil {
+			t.Fatalf("RequestDeviceCode failed: %v", err)
+		}
+
+		// Polling right after issuance is too fast
+		if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrSlowDown) {
+			t.Errorf("first poll error = %v, want %v", err, ErrSlowDown)
+		}
+
+		store.deviceCodes[code.DeviceCode].LastPoll = time.Now().Add(-20 * time.Second)
+		if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrPendingAuthorization) {
+			t.Errorf("poll after interval error = %v, want %v", err, ErrPendingAuthorization)
+		}
+		if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrSlowDown) {
+			t.Errorf("repeated poll error = %v, want %v", err, ErrSlowDown)
+		}
+	})
+
+	t.Run("slow_down escalates interval", func(t *testing.T) {
+		store := newMockStore()
+		flow := NewFlow(store, "https://example.com", WithPollInterval(5*time.Second))
+		code, err := flow.RequestDeviceCode(ctx, "tv", "")
+		if err != nil {
+			t.Fatalf("RequestDeviceCode failed: %v", err)
+		}
+
+		if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrSlowDown) {
+			t.Fatalf("first poll error = %v, want %v", err, ErrSlowDown)
+		}
+		if got := store.deviceCodes[code.DeviceCode].Interval; got != 10 {
+			t.Errorf("interval after slow_down = %d, want 10", got)
+		}
+
+		// Waiting the original interval is no longer enough
+		store.deviceCodes[code.DeviceCode].LastPoll = time.Now().Add(-7 * time.Second)
+		_, err = flow.CheckDeviceCode(ctx, code.DeviceCode)
+		if !errors.Is(err, ErrSlowDown) {
+			t.Errorf("poll after original interval error = %v, want %v", err, ErrSlowDown)
+		}
+		if got := store.deviceCodes[code.DeviceCode].Interval; got != 15 {
+			t.Errorf("interval after second slow_down = %d, want 15", got)
+		}
+		if wait, ok := RetryAfter(err); !ok || wait != 15*time.Second {
+			t.Errorf("RetryAfter after second slow_down = %v, %v; want 15s", wait, ok)
+		}
+
+		store.deviceCodes[code.DeviceCode].LastPoll = time.Now().Add(-20 * time.Second)
+		_, err = flow.CheckDeviceCode(ctx, code.DeviceCode)
+		if !errors.Is(err, ErrPendingAuthorization) {
+			t.Errorf("poll after escalated interval error = %v, want %v", err, ErrPendingAuthorization)
+		}
+		if wait, ok := RetryAfter(err); !ok || wait != 15*time.Second {
+			t.Errorf("RetryAfter while pending = %v, %v; want 15s", wait, ok)
+		}
+	})
+
+	t.Run("window", func(t *testing.T) {
+		store := newMockStore()
+		flow := NewFlow(store, "https://example.com", WithRateLimit(time.Minute, 2))
+		code, err := flow.RequestDeviceCode(ctx, "tv", "")
+		if err != nil {
+			t.Fatalf("RequestDeviceCode failed: %v", err)
+		}
+		skipInterval := func() {
+			store.deviceCodes[code.DeviceCode].LastPoll = time.Now().Add(-20 * time.Second)
+		}
+
+		for i := 0; i < 2; i++ {
+			skipInterval()
+			if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrPendingAuthorization) {
+				t.Fatalf("poll %d error = %v, want %v", i+1, err, ErrPendingAuthorization)
+			}
+		}
+		skipInterval()
+		if _, err := flow.CheckDeviceCode(ctx, code.DeviceCode); !errors.Is(err, ErrSlowDown) {
+			t.Errorf("poll over limit error = %v, want %v", err, ErrSlowDown)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package deviceflow
+
+import (
+	"context"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// Operations that can find a tombstone in place of a device code
+const (
+	tombstoneViaPoll         = "poll"
+	tombstoneViaVerification = "verification"
+)
+
+// checkTombstone rejects a code read back from a tombstone because it was
+// consumed or deleted, reporting the replay. Expired codes are left to the
+// expiry check, as are live codes.
+func (f *flowImpl) checkTombstone(ctx context.Context, code *DeviceCode, via string) error {
+	if code.Tombstone == "" {
+		return nil
+	}
+	tombstoneHits.Inc(code.Tombstone, via)
+
+	var err *DeviceFlowError
+	switch {
+	case code.Tombstone == TombstoneConsumed && via == tombstoneViaPoll:
+		err = ErrCodeConsumed
+	case code.Tombstone == TombstoneConsumed:
+		err = NewDeviceFlowError(ErrorCodeInvalidGrant, ErrorDescUserCodeConsumed)
+	case code.Tombstone == TombstoneDeleted && via == tombstoneViaPoll:
+		err = ErrCodeDeleted
+	case code.Tombstone == TombstoneDeleted:
+		err = NewDeviceFlowError(ErrorCodeInvalidGrant, ErrorDescUserCodeDeleted)
+	default:
+		return nil
+	}
+
+	// A used code presented again may have leaked
+	f.emit(ctx, events.TypeCodeReplayed, code, map[string]any{
+		"reason": code.Tombstone,
+		"via":    via,
+	})
+	return err
+}
+
+// tombstoneHits counts polls and verifications of codes that are gone
+var tombstoneHits = metrics.Default.NewCounter(
+	"device_proxy_tombstone_hits_total",
+	"Polls and verifications of device codes found only as tombstones, by reason and operation.",
+	"reason", "operation",
+)
@@ -0,0 +1,45 @@
+// Package deviceflow implements atomic multi-record writes to the store
+package deviceflow
+
+import "context"
+
+// Tx queues writes that are applied together when a transaction commits
+type Tx interface {
+	// SaveDeviceCode queues saving a device code together with its user code
+	// reference, rate limit baseline and pending code entry
+	SaveDeviceCode(code *DeviceCode) error
+
+	// SaveBatch queues saving a device code batch
+	SaveBatch(batch *Batch) error
+}
+
+// Transactor is implemented by stores that can apply several writes as one
+// atomic unit. Writes queued by fn are applied only if fn returns nil, and
+// either all of them are applied or none are.
+type Transactor interface {
+	Transact(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// transact runs fn in a transaction when the store supports them. Other stores
+// apply each write as it is queued, so a failure may leave earlier writes in
+// place and callers must still clean up after one.
+func transact(ctx context.Context, store Store, fn func(tx Tx) error) error {
+	if transactor, ok := store.(Transactor); ok {
+		return transactor.Transact(ctx, fn)
+	}
+	return fn(directTx{ctx: ctx, store: store})
+}
+
+// directTx applies writes immediately for stores without transactions
+type directTx struct {
+	ctx   context.Context
+	store Store
+}
+
+func (tx directTx) SaveDeviceCode(code *DeviceCode) error {
+	return tx.store.SaveDeviceCode(tx.ctx, code)
+}
+
+func (tx directTx) SaveBatch(batch *Batch) error {
+	return tx.store.SaveBatch(tx.ctx, batch)
+}
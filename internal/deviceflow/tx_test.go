@@ -0,0 +1,79 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// plainStore hides the Transactor implementation of the mock store
+type plainStore struct{ Store }
+
+func TestTransact(t *testing.T) {
+	ctx := context.Background()
+	errQueue := errors.New("queue failed")
+	code := &DeviceCode{DeviceCode: "dc", UserCode: "ABCD-EFGH", ExpiresAt: time.Now().Add(time.Minute)}
+
+	// A failed transaction writes nothing
+	store := newMockStore()
+	err := transact(ctx, store, func(tx Tx) error {
+		if err := tx.SaveDeviceCode(code); err != nil {
+			return err
+		}
+		return errQueue
+	})
+	if !errors.Is(err, errQueue) {
+		t.Fatalf("transact error = %v, want %v", err, errQueue)
+	}
+	if got, _ := store.GetDeviceCodeByUserCode(ctx, code.UserCode); got != nil {
+		t.Error("failed transaction saved its device code")
+	}
+
+	// Stores without transactions apply writes as they are queued
+	err = transact(ctx, plainStore{store}, func(tx Tx) error {
+		if err := tx.SaveDeviceCode(code); err != nil {
+			return err
+		}
+		return errQueue
+	})
+	if !errors.Is(err, errQueue) {
+		t.Fatalf("transact error = %v, want %v", err, errQueue)
+	}
+	if got, _ := store.GetDeviceCodeByUserCode(ctx, code.UserCode); got == nil {
+		t.Error("direct write was not applied")
+	}
+}
+
+func TestBatchDeviceCodes(t *testing.T) {
+	ctx := context.Background()
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com")
+
+	first, err := flow.RequestDeviceCode(ctx, "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	second, err := flow.RequestDeviceCode(ctx, "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	codes, err := store.BatchGetDeviceCodes(ctx, []string{first.DeviceCode, "missing", second.DeviceCode})
+	if err != nil {
+		t.Fatalf("BatchGetDeviceCodes failed: %v", err)
+	}
+	if len(codes) != 3 || codes[0] == nil || codes[1] != nil || codes[2] == nil || codes[2].DeviceCode != second.DeviceCode {
+		t.Fatalf("BatchGetDeviceCodes = %v, want the stored codes in order", codes)
+	}
+
+	if err := store.BatchDeleteDeviceCodes(ctx, []string{first.DeviceCode, "missing"}); err != nil {
+		t.Fatalf("BatchDeleteDeviceCodes failed: %v", err)
+	}
+	if got, _ := store.GetDeviceCodeByUserCode(ctx, first.UserCode); got != nil {
+		t.Error("deleted code still resolves by user code")
+	}
+	if got, _ := store.GetDeviceCode(ctx, second.DeviceCode); got == nil {
+		t.Error("code not in the batch was deleted")
+	}
+}
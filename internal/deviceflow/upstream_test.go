@@ -0,0 +1,60 @@
+package deviceflow
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMapUpstreamError(t *testing.T) {
+	tests := []struct {
+		code         string
+		description  string
+		wantCode     string
+		wantDesc     string
+		wantTerminal bool
+	}{
+		{code: "access_denied", wantCode: ErrorCodeAccessDenied, wantDesc: ErrorDescAccessDenied, wantTerminal: true},
+		{code: "invalid_scope", description: "Scope admin is not allowed", wantCode: ErrorCodeInvalidScope, wantDesc: "Scope admin is not allowed", wantTerminal: true},
+		{code: "invalid_scope", wantCode: ErrorCodeInvalidScope, wantDesc: ErrorDescInvalidScope, wantTerminal: true},
+		{code: "temporarily_unavailable", wantCode: ErrorCodeTemporarilyUnavailable, wantDesc: ErrorDescTemporarilyUnavailable},
+		{code: "invalid_grant", description: "Code not valid", wantCode: ErrorCodeInvalidGrant, wantDesc: "Code not valid"},
+		{code: "unauthorized_client", description: "leaks internals", wantCode: ErrorCodeServerError, wantDesc: ErrorDescUpstreamError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			got := MapUpstreamError(tt.code, tt.description)
+			if got.Code != tt.wantCode || got.Description != tt.wantDesc {
+				t.Errorf("MapUpstreamError() = %q/%q, want %q/%q", got.Code, got.Description, tt.wantCode, tt.wantDesc)
+			}
+			if got.Terminal() != tt.wantTerminal {
+				t.Errorf("Terminal() = %v, want %v", got.Terminal(), tt.wantTerminal)
+			}
+		})
+	}
+}
+
+func TestFailAuthorization(t *testing.T) {
+	ctx := context.Background()
+	flow := NewFlow(newMockStore(), "https://example.com")
+
+	code, err := flow.RequestDeviceCode(ctx, "kiosk", "openid admin")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	if err := flow.FailAuthorization(ctx, code.DeviceCode, MapUpstreamError("temporarily_unavailable", "")); err == nil {
+		t.Error("expected error when failing with a non-terminal error")
+	}
+
+	failure := MapUpstreamError("invalid_scope", "Scope admin is not allowed")
+	if err := flow.FailAuthorization(ctx, code.DeviceCode, failure); err != nil {
+		t.Fatalf("FailAuthorization failed: %v", err)
+	}
+
+	_, err = flow.CheckDeviceCode(ctx, code.DeviceCode)
+	dfe, ok := AsDeviceFlowError(err)
+	if !ok || dfe.Code != ErrorCodeInvalidScope || dfe.Description != failure.Description {
+		t.Errorf("CheckDeviceCode() error = %v, want %v", err, failure)
+	}
+}
@@ -1,5 +1,40 @@
 // Package deviceflow implements URI handling for OAuth 2.0 Device Flow
 package deviceflow
 
-// NOTE: URI functionality has been moved to flow.go to maintain a single implementation
-// and prevent duplicate method declarations while preserving RFC 8628 compliance.
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// NOTE: The default verification URIs are built in flow.go. This file holds the
+// templated verification_uri_complete used for mobile deep links.
+
+// UserCodePlaceholder marks where the user code goes in a complete URI template
+const UserCodePlaceholder = "{user_code}"
+
+// ValidateCompleteURITemplate checks that a verification_uri_complete template
+// contains the user code placeholder and expands to an absolute URI
+func ValidateCompleteURITemplate(tmpl string) error {
+	if strings.Count(tmpl, UserCodePlaceholder) != 1 {
+		return fmt.Errorf("complete URI template must contain %s exactly once", UserCodePlaceholder)
+	}
+
+	u, err := url.Parse(expandCompleteURI(tmpl, "WDJB-MJHT"))
+	if err != nil {
+		return fmt.Errorf("invalid complete URI template: %w", err)
+	}
+	if !u.IsAbs() {
+		return errors.New("complete URI template must be an absolute URI with a scheme")
+	}
+	if (u.Scheme == "https" || u.Scheme == "http") && u.Host == "" {
+		return errors.New("complete URI template must include a host")
+	}
+	return nil
+}
+
+// expandCompleteURI substitutes the escaped user code into a template
+func expandCompleteURI(tmpl, userCode string) string {
+	return strings.Replace(tmpl, UserCodePlaceholder, url.PathEscape(userCode), 1)
+}
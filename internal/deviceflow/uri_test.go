@@ -0,0 +1,76 @@
+package deviceflow
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
+)
+
+func TestValidateCompleteURITemplate(t *testing.T) {
+	tests := []struct {
+		tmpl    string
+		wantErr bool
+	}{
+		{tmpl: "https://auth.example.com/a/{user_code}"},
+		{tmpl: "exampleapp://device?code={user_code}"},
+		{tmpl: "https://auth.example.com/a/", wantErr: true},
+		{tmpl: "https://auth.example.com/{user_code}/{user_code}", wantErr: true},
+		{tmpl: "/a/{user_code}", wantErr: true},
+		{tmpl: "https:///a/{user_code}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			err := ValidateCompleteURITemplate(tt.tmpl)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCompleteURITemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithCompleteURITemplate(t *testing.T) {
+	flow := NewFlow(newMockStore(), "https://example.com",
+		WithCompleteURITemplate("https://auth.example.com/a/{user_code}"))
+
+	code, err := flow.RequestDeviceCode(context.Background(), "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+
+	if code.VerificationURI != "https://example.com/device" {
+		t.Errorf("VerificationURI = %q, want the standard form", code.VerificationURI)
+	}
+	if want := "https://auth.example.com/a/" + code.UserCode; code.VerificationURIComplete != want {
+		t.Errorf("VerificationURIComplete = %q, want %q", code.VerificationURIComplete, want)
+	}
+}
+
+func TestWithCodeDisplayFormat(t *testing.T) {
+	store := newMockStore()
+	flow := NewFlow(store, "https://example.com", WithCodeDisplayFormat("XXXX XXXX"))
+
+	code, err := flow.RequestDeviceCode(context.Background(), "tv", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	if err := validation.ValidateUserCode(code.UserCode); err != nil {
+		t.Errorf("UserCode = %q, want the issued XXXX-XXXX format", code.UserCode)
+	}
+
+	displayed := strings.Replace(code.UserCode, "-", " ", 1)
+	want := "https://example.com/device?code=" + url.QueryEscape(displayed)
+	if code.VerificationURIComplete != want {
+		t.Errorf("VerificationURIComplete = %q, want %q", code.VerificationURIComplete, want)
+	}
+
+	// Codes are verified in whichever layout they were entered
+	for _, entered := range []string{displayed, strings.ToLower(displayed), validation.NormalizeCode(code.UserCode)} {
+		if _, err := flow.VerifyUserCode(context.Background(), entered); err != nil {
+			t.Errorf("VerifyUserCode(%q) failed: %v", entered, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/wrale/oauth2-device-proxy/internal/events"
 	"github.com/wrale/oauth2-device-proxy/internal/validation"
 )
 
@@ -15,6 +16,9 @@ import (
 // 3. Code state (expired, not found)
 // 4. Rate limiting
 func (f *flowImpl) VerifyUserCode(ctx context.Context, userCode string) (*DeviceCode, error) {
+	// Accept the code in any layout it may have been displayed or typed in
+	userCode = validation.CanonicalCode(userCode)
+
 	// Run format validation first
 	if err := validation.ValidateUserCode(userCode); err != nil {
 		return nil, NewDeviceFlowError(
@@ -44,6 +48,10 @@ func (f *flowImpl) VerifyUserCode(ctx context.Context, userCode string) (*Device
 		)
 	}
 
+	if err := f.checkTombstone(ctx, code, tombstoneViaVerification); err != nil {
+		return nil, err
+	}
+
 	// Check expiration third
 	if time.Now().After(code.ExpiresAt) {
 		return nil, NewDeviceFlowError(
@@ -80,5 +88,8 @@ func (f *flowImpl) VerifyUserCode(ctx context.Context, userCode string) (*Device
 	remaining := time.Until(code.ExpiresAt).Seconds()
 	code.ExpiresIn = int(remaining)
 
+	f.markVerified(ctx, code)
+	f.emit(ctx, events.TypeUserVerified, code, nil)
+
 	return code, nil
 }
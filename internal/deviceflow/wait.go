@@ -0,0 +1,79 @@
+// Package deviceflow implements waiting for device authorization to finish
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WatchRecheckInterval is how often a waiting device's flow is rechecked even
+// when the store announces changes, since announcements may be lost
+const WatchRecheckInterval = 30 * time.Second
+
+// Watcher is implemented by stores that can announce changes to a device
+// code, letting waiting devices be woken instead of rechecking the store
+type Watcher interface {
+	// Watch returns a channel that receives a value when the flow for the
+	// device code may have changed. Delivery stops once ctx is done.
+	Watch(ctx context.Context, deviceCode string) (<-chan struct{}, error)
+}
+
+// WaitForToken checks the device code like a token request and, while it is
+// pending, waits for the user to finish. Only the initial check counts as a
+// poll, so a device holding the request open saves the store from interval
+// polling. When ctx is done first, ErrPendingAuthorization is returned and the
+// device is expected to wait again.
+func (f *flowImpl) WaitForToken(ctx context.Context, deviceCode string) (*TokenResponse, error) {
+	token, err := f.CheckDeviceCode(ctx, deviceCode)
+	if !errors.Is(err, ErrPendingAuthorization) {
+		return token, err
+	}
+
+	recheck := f.pollInterval
+	var changed <-chan struct{}
+	if watcher, ok := f.store.(Watcher); ok {
+		if changed, err = watcher.Watch(ctx, deviceCode); err == nil {
+			recheck = WatchRecheckInterval
+		}
+	}
+	ticker := time.NewTicker(recheck)
+	defer ticker.Stop()
+
+	for {
+		// Check before the first wait too, in case the flow ended while the
+		// watch was being set up
+		token, err := f.pendingToken(ctx, deviceCode)
+		if err != nil || token != nil {
+			return token, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ErrPendingAuthorization
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+}
+
+// pendingToken rechecks a waiting device code without counting a poll,
+// returning nil while authorization is still pending
+func (f *flowImpl) pendingToken(ctx context.Context, deviceCode string) (*TokenResponse, error) {
+	code, err := f.GetDeviceCode(ctx, deviceCode)
+	if err == nil {
+		var token *TokenResponse
+		if token, err = f.resolveToken(ctx, code); err == nil {
+			if token != nil {
+				f.markConsumed(ctx, code)
+			}
+			return f.deliver(code, token), nil
+		}
+	}
+
+	// Store errors caused by the wait ending are not the device's concern
+	if ctx.Err() != nil {
+		return nil, ErrPendingAuthorization
+	}
+	return nil, err
+}
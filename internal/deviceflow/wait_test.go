@@ -0,0 +1,167 @@
+package deviceflow
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// watchingStore adds change announcements to the mock store
+type watchingStore struct {
+	*mockStore
+
+	mu       sync.Mutex
+	watchers map[string][]chan struct{}
+}
+
+func newWatchingStore() *watchingStore {
+	return &watchingStore{mockStore: newMockStore(), watchers: make(map[string][]chan struct{})}
+}
+
+func (s *watchingStore) Watch(ctx context.Context, deviceCode string) (<-chan struct{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch := make(chan struct{}, 1)
+	s.watchers[deviceCode] = append(s.watchers[deviceCode], ch)
+	return ch, nil
+}
+
+func (s *watchingStore) notify(deviceCode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.watchers[deviceCode] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *watchingStore) SaveTokenResponse(ctx context.Context, deviceCode string, token *TokenResponse) error {
+	if err := s.mockStore.SaveTokenResponse(ctx, deviceCode, token); err != nil {
+		return err
+	}
+	s.notify(deviceCode)
+	return nil
+}
+
+func (s *watchingStore) SaveDeviceCode(ctx context.Context, code *DeviceCode) error {
+	if err := s.mockStore.SaveDeviceCode(ctx, code); err != nil {
+		return err
+	}
+	s.notify(code.DeviceCode)
+	return nil
+}
+
+func (s *watchingStore) EndAuthorization(ctx context.Context, deviceCode string, failure *DeviceFlowError) (*DeviceCode, error) {
+	code, err := s.mockStore.EndAuthorization(ctx, deviceCode, failure)
+	if err != nil {
+		return nil, err
+	}
+	s.notify(deviceCode)
+	return code, nil
+}
+
+func TestWaitForToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		watch   bool // Announce changes instead of relying on rechecks
+		finish  func(ctx context.Context, flow Flow, deviceCode string) error
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "authorized while watching",
+			watch: true,
+			finish: func(ctx context.Context, flow Flow, deviceCode string) error {
+				return flow.CompleteAuthorization(ctx, deviceCode, &TokenResponse{AccessToken: "token"})
+			},
+			want: "token",
+		},
+		{
+			name:  "denied while watching",
+			watch: true,
+			finish: func(ctx context.Context, flow Flow, deviceCode string) error {
+				return flow.DenyAuthorization(ctx, deviceCode)
+			},
+			wantErr: ErrAccessDenied,
+		},
+		{
+			name: "authorized without watcher",
+			finish: func(ctx context.Context, flow Flow, deviceCode string) error {
+				return flow.CompleteAuthorization(ctx, deviceCode, &TokenResponse{AccessToken: "token"})
+			},
+			want: "token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			mock := newMockStore()
+			var store Store = mock
+			if tt.watch {
+				watching := newWatchingStore()
+				store, mock = watching, watching.mockStore
+			}
+			flow := newDefaultFlow(store, "https://example.com")
+			flow.pollInterval = 10 * time.Millisecond // Rechecks without a watcher
+
+			code, err := flow.RequestDeviceCode(ctx, "kiosk", "")
+			if err != nil {
+				t.Fatalf("RequestDeviceCode failed: %v", err)
+			}
+			mock.deviceCodes[code.DeviceCode].LastPoll = time.Now().Add(-time.Minute)
+
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				if err := tt.finish(ctx, flow, code.DeviceCode); err != nil {
+					t.Errorf("finishing flow: %v", err)
+				}
+			}()
+
+			token, err := flow.WaitForToken(ctx, code.DeviceCode)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("WaitForToken error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WaitForToken failed: %v", err)
+			}
+			if token.AccessToken != tt.want {
+				t.Errorf("access token = %q, want %q", token.AccessToken, tt.want)
+			}
+		})
+	}
+}
+
+func TestWaitForTokenTimeout(t *testing.T) {
+	store := newWatchingStore()
+	flow := NewFlow(store, "https://example.com")
+
+	code, err := flow.RequestDeviceCode(context.Background(), "kiosk", "")
+	if err != nil {
+		t.Fatalf("RequestDeviceCode failed: %v", err)
+	}
+	store.deviceCodes[code.DeviceCode].LastPoll = time.Now().Add(-time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := flow.WaitForToken(ctx, code.DeviceCode); !errors.Is(err, ErrPendingAuthorization) {
+		t.Errorf("WaitForToken error = %v, want %v", err, ErrPendingAuthorization)
+	}
+
+	// Waiting counts as a single poll, so an immediate retry must slow down
+	if polls := len(store.polls[code.DeviceCode]); polls != 1 {
+		t.Errorf("recorded %d polls, want 1", polls)
+	}
+	if _, err := flow.WaitForToken(context.Background(), code.DeviceCode); !errors.Is(err, ErrSlowDown) {
+		t.Errorf("immediate retry error = %v, want %v", err, ErrSlowDown)
+	}
+}
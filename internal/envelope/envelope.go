@@ -0,0 +1,266 @@
+// Package envelope encrypts records at rest. Each record is sealed with its own
+// AES-GCM data key, which is in turn wrapped by a key encryption key held by a
+// KeyWrapper, so key encryption keys can live in a key management service and
+// be rotated without rewriting stored records.
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// prefix marks sealed records, distinguishing them from plaintext written
+// before encryption was enabled
+var prefix = []byte("enc:v1:")
+
+// dataKeySize is the length of per-record AES-256 data keys
+const dataKeySize = 32
+
+var (
+	// ErrDecrypt indicates a sealed record could not be opened, because it was
+	// tampered with, moved to another record or sealed under an unknown key
+	ErrDecrypt = errors.New("envelope: cannot decrypt record")
+
+	// ErrUnknownKey indicates a record was sealed under a key that is not configured
+	ErrUnknownKey = errors.New("envelope: unknown key")
+)
+
+// KeyWrapper protects data keys with a key encryption key. Implementations
+// may hold the key in memory, as LocalKeys does, or delegate to a key
+// management service.
+type KeyWrapper interface {
+	// KeyID identifies the key that wraps new data keys
+	KeyID() string
+
+	// WrapKey encrypts a data key under the current key
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+
+	// UnwrapKey decrypts a data key wrapped under the identified key
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// sealed is the stored form of an encrypted record
+type sealed struct {
+	KeyID   string `json:"kid"`
+	DataKey []byte `json:"key"`   // Data key wrapped by KeyID
+	Nonce   []byte `json:"nonce"` // AES-GCM nonce of Data
+	Data    []byte `json:"data"`
+}
+
+// Sealer encrypts and decrypts records
+type Sealer struct {
+	mu   sync.RWMutex
+	keys KeyWrapper
+}
+
+// NewSealer creates a sealer wrapping data keys with keys
+func NewSealer(keys KeyWrapper) *Sealer {
+	return &Sealer{keys: keys}
+}
+
+// SetKeys replaces the key wrapper, such as after key encryption keys were
+// rotated. Records sealed under keys the new wrapper lacks can no longer be
+// opened.
+func (s *Sealer) SetKeys(keys KeyWrapper) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+// wrapper returns the current key wrapper
+func (s *Sealer) wrapper() KeyWrapper {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys
+}
+
+// Seal encrypts plaintext under a new data key. The additional data is
+// authenticated but not stored; Open must be given the same value, which
+// binds the record to where it is stored.
+func (s *Sealer) Seal(ctx context.Context, plaintext, additionalData []byte) ([]byte, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, fmt.Errorf("generating data key: %w", err)
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+
+	keys := s.wrapper()
+	wrapped, err := keys.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("wrapping data key: %w", err)
+	}
+
+	data, err := json.Marshal(sealed{
+		KeyID:   keys.KeyID(),
+		DataKey: wrapped,
+		Nonce:   nonce,
+		Data:    aead.Seal(nil, nonce, plaintext, additionalData),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshaling sealed record: %w", err)
+	}
+
+	return append(append([]byte{}, prefix...), data...), nil
+}
+
+// Open decrypts a record produced by Seal with the same additional data
+func (s *Sealer) Open(ctx context.Context, record, additionalData []byte) ([]byte, error) {
+	if !IsSealed(record) {
+		return nil, ErrDecrypt
+	}
+
+	var env sealed
+	if err := json.Unmarshal(record[len(prefix):], &env); err != nil {
+		return nil, ErrDecrypt
+	}
+
+	dataKey, err := s.wrapper().UnwrapKey(ctx, env.KeyID, env.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key: %w", err)
+	}
+
+	aead, err := newGCM(dataKey)
+	if err != nil || len(env.Nonce) != aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Data, additionalData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+
+	return plaintext, nil
+}
+
+// IsSealed reports whether record was produced by Seal
+func IsSealed(record []byte) bool {
+	return bytes.HasPrefix(record, prefix)
+}
+
+// Key is a key encryption key held in memory
+type Key struct {
+	ID     string
+	Secret []byte // 16, 24 or 32 bytes for AES-128, AES-192 or AES-256
+}
+
+// LocalKeys wraps data keys with AES-GCM keys held in memory. The first key
+// wraps new data keys; the rest only unwrap existing ones, so a key can be
+// rotated by adding its replacement in front and removing it once the
+// records it protects have expired.
+type LocalKeys struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewLocalKeys creates a key wrapper from one or more keys
+func NewLocalKeys(keys ...Key) (*LocalKeys, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("envelope: at least one key is required")
+	}
+
+	l := &LocalKeys{current: keys[0].ID, keys: make(map[string]cipher.AEAD, len(keys))}
+	for _, key := range keys {
+		if key.ID == "" {
+			return nil, errors.New("envelope: key ID is required")
+		}
+		if _, dup := l.keys[key.ID]; dup {
+			return nil, fmt.Errorf("envelope: duplicate key ID %q", key.ID)
+		}
+		aead, err := newGCM(key.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("envelope: key %q: %w", key.ID, err)
+		}
+		l.keys[key.ID] = aead
+	}
+
+	return l, nil
+}
+
+// KeyID implements KeyWrapper
+func (l *LocalKeys) KeyID() string {
+	return l.current
+}
+
+// WrapKey implements KeyWrapper
+func (l *LocalKeys) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	aead := l.keys[l.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+	// The key ID is authenticated so a wrapped key cannot be relabeled
+	return aead.Seal(nonce, nonce, dataKey, []byte(l.current)), nil
+}
+
+// UnwrapKey implements KeyWrapper
+func (l *LocalKeys) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := l.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKey, keyID)
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+
+	nonce, ciphertext := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dataKey, err := aead.Open(nil, nonce, ciphertext, []byte(keyID))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return dataKey, nil
+}
+
+// ParseKeys parses a list of id:key pairs with base64-encoded keys, separated
+// by commas or newlines, such as "2024-06:q83v...,2024-01:Zm9v...". The first
+// key is current.
+func ParseKeys(spec string) ([]Key, error) {
+	var keys []Key
+	entries := strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' })
+	for i, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			// The entry itself is left out since it may be a bare key
+			return nil, fmt.Errorf("envelope: key %d must be in id:base64 form", i+1)
+		}
+		secret, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("envelope: key %q is not valid base64: %w", id, err)
+		}
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("envelope: no keys given")
+	}
+	return keys, nil
+}
+
+// newGCM creates an AES-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
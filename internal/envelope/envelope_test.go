@@ -0,0 +1,161 @@
+package envelope
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// testKeys creates a key wrapper whose secrets are derived from the key IDs
+func testKeys(t *testing.T, ids ...string) *LocalKeys {
+	t.Helper()
+	var keys []Key
+	for _, id := range ids {
+		secret := make([]byte, 32)
+		copy(secret, id)
+		keys = append(keys, Key{ID: id, Secret: secret})
+	}
+	l, err := NewLocalKeys(keys...)
+	if err != nil {
+		t.Fatalf("NewLocalKeys failed: %v", err)
+	}
+	return l
+}
+
+func TestSealer(t *testing.T) {
+	ctx := context.Background()
+	sealer := NewSealer(testKeys(t, "k1"))
+	plaintext := []byte(`{"access_token":"secret"}`)
+
+	record, err := sealer.Seal(ctx, plaintext, []byte("token:abc"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if !IsSealed(record) {
+		t.Error("sealed record not recognized")
+	}
+	if bytes.Contains(record, []byte("secret")) {
+		t.Errorf("sealed record contains plaintext: %s", record)
+	}
+
+	got, err := sealer.Open(ctx, record, []byte("token:abc"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("Open = %s, want %s", got, plaintext)
+	}
+
+	// Each record gets its own data key and nonce
+	again, _ := sealer.Seal(ctx, plaintext, []byte("token:abc"))
+	if bytes.Equal(record, again) {
+		t.Error("sealing twice produced identical records")
+	}
+}
+
+func TestSealerRejects(t *testing.T) {
+	ctx := context.Background()
+	sealer := NewSealer(testKeys(t, "k1"))
+	record, err := sealer.Seal(ctx, []byte("payload"), []byte("token:abc"))
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	tampered := append([]byte{}, record...)
+	tampered[len(tampered)-4] ^= 0x01
+
+	tests := []struct {
+		name    string
+		sealer  *Sealer
+		record  []byte
+		aad     string
+		wantErr error
+	}{
+		{name: "moved record", sealer: sealer, record: record, aad: "token:other", wantErr: ErrDecrypt},
+		{name: "tampered record", sealer: sealer, record: tampered, aad: "token:abc", wantErr: ErrDecrypt},
+		{name: "plaintext record", sealer: sealer, record: []byte(`{"a":1}`), aad: "token:abc", wantErr: ErrDecrypt},
+		{name: "unknown key", sealer: NewSealer(testKeys(t, "k2")), record: record, aad: "token:abc", wantErr: ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.sealer.Open(ctx, tt.record, []byte(tt.aad)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Open error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLocalKeysRotation(t *testing.T) {
+	ctx := context.Background()
+	old := NewSealer(testKeys(t, "old"))
+	record, err := old.Seal(ctx, []byte("payload"), nil)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+
+	// The replacement key goes first; the old one still opens existing records
+	rotated := testKeys(t, "new", "old")
+	if rotated.KeyID() != "new" {
+		t.Errorf("current key = %q, want new", rotated.KeyID())
+	}
+	got, err := NewSealer(rotated).Open(ctx, record, nil)
+	if err != nil || string(got) != "payload" {
+		t.Errorf("Open after rotation = %q, %v", got, err)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantIDs []string
+		wantErr bool
+	}{
+		{name: "single key", spec: "k1:AAAAAAAAAAAAAAAAAAAAAA==", wantIDs: []string{"k1"}},
+		{name: "rotation", spec: "k2:AAAAAAAAAAAAAAAAAAAAAA==, k1:AAAAAAAAAAAAAAAAAAAAAA==", wantIDs: []string{"k2", "k1"}},
+		{name: "newline separated", spec: "k2:AAAAAAAAAAAAAAAAAAAAAA==\nk1:AAAAAAAAAAAAAAAAAAAAAA==\n", wantIDs: []string{"k2", "k1"}},
+		{name: "empty", spec: " , ", wantErr: true},
+		{name: "missing ID", spec: "AAAAAAAAAAAAAAAAAAAAAA==", wantErr: true},
+		{name: "bad base64", spec: "k1:not base64", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseKeys(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseKeys(%q) succeeded, want error", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKeys failed: %v", err)
+			}
+			if len(keys) != len(tt.wantIDs) {
+				t.Fatalf("got %d keys, want %d", len(keys), len(tt.wantIDs))
+			}
+			for i, id := range tt.wantIDs {
+				if keys[i].ID != id {
+					t.Errorf("key %d ID = %q, want %q", i, keys[i].ID, id)
+				}
+			}
+		})
+	}
+}
+
+func TestNewLocalKeysRejects(t *testing.T) {
+	secret := bytes.Repeat([]byte{1}, 32)
+	tests := map[string][]Key{
+		"no keys":      nil,
+		"missing ID":   {{Secret: secret}},
+		"duplicate ID": {{ID: "k", Secret: secret}, {ID: "k", Secret: secret}},
+		"short secret": {{ID: "k", Secret: []byte("short")}},
+	}
+	for name, keys := range tests {
+		if _, err := NewLocalKeys(keys...); err == nil {
+			t.Errorf("%s: NewLocalKeys succeeded, want error", name)
+		}
+	}
+}
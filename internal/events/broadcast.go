@@ -0,0 +1,68 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind by before
+// further events are dropped for it
+const subscriberBuffer = 64
+
+// Broadcaster fans events out to in-process subscribers, such as the gRPC
+// management API's event streams. A subscriber that falls behind misses
+// events rather than holding up the flow operation that emitted them.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	types  map[Type]bool // Nil for all types
+	events chan Event
+}
+
+// NewBroadcaster creates a broadcaster without subscribers
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[*subscriber]struct{})}
+}
+
+// Emit implements Emitter
+func (b *Broadcaster) Emit(ctx context.Context, event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers {
+		if sub.types != nil && !sub.types[event.Type] {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe delivers events of the given types, or all events when none are
+// given, until cancel is called
+func (b *Broadcaster) Subscribe(types ...Type) (events <-chan Event, cancel func()) {
+	sub := &subscriber{events: make(chan Event, subscriberBuffer)}
+	if len(types) > 0 {
+		sub.types = make(map[Type]bool, len(types))
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return sub.events, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, sub)
+			b.mu.Unlock()
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package events
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBroadcaster(t *testing.T) {
+	b := NewBroadcaster()
+	all, cancelAll := b.Subscribe()
+	defer cancelAll()
+	denials, cancelDenials := b.Subscribe(TypeAuthorizationDenied)
+
+	b.Emit(context.Background(), New(TypeDeviceCodeCreated))
+	b.Emit(context.Background(), New(TypeAuthorizationDenied))
+
+	for _, want := range []Type{TypeDeviceCodeCreated, TypeAuthorizationDenied} {
+		if got := (<-all).Type; got != want {
+			t.Errorf("unfiltered subscriber got %s, want %s", got, want)
+		}
+	}
+	if got := (<-denials).Type; got != TypeAuthorizationDenied {
+		t.Errorf("filtered subscriber got %s, want %s", got, TypeAuthorizationDenied)
+	}
+	select {
+	case event := <-denials:
+		t.Errorf("filtered subscriber got unexpected %s", event.Type)
+	default:
+	}
+
+	// Cancelled subscribers receive nothing further
+	cancelDenials()
+	cancelDenials()
+	b.Emit(context.Background(), New(TypeAuthorizationDenied))
+	select {
+	case event := <-denials:
+		t.Errorf("cancelled subscriber got %s", event.Type)
+	default:
+	}
+
+	// A subscriber that falls behind drops events instead of blocking Emit
+	for i := 0; i < subscriberBuffer*2; i++ {
+		b.Emit(context.Background(), New(TypeDeviceCodeCreated))
+	}
+	if got := len(all); got != subscriberBuffer {
+		t.Errorf("slow subscriber buffered %d events, want %d", got, subscriberBuffer)
+	}
+}
@@ -0,0 +1,82 @@
+// Package events provides lifecycle notifications for OAuth 2.0 device authorization flows
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Type identifies a device flow lifecycle event
+type Type string
+
+// Lifecycle events emitted during the device authorization flow
+const (
+	TypeDeviceCodeCreated      Type = "device_code.created"
+	TypeUserVerified           Type = "user.verified"
+	TypeAuthorizationCompleted Type = "authorization.completed"
+	TypeAuthorizationDenied    Type = "authorization.denied"
+	TypeAuthorizationFailed    Type = "authorization.failed"
+	TypeCodeExpired            Type = "code.expired"
+	TypePollAnomaly            Type = "device_code.poll_anomaly"
+	TypeUserCodeRefreshed      Type = "device_code.user_code_refreshed"
+	TypeCodeReplayed           Type = "device_code.replayed" // A consumed or revoked code was presented again
+)
+
+// Event describes a single lifecycle occurrence. Device codes are bearer secrets
+// until redeemed per RFC 8628 section 5.2, so they are never included in events,
+// only the hash audit records carry for correlation.
+type Event struct {
+	ID             string         `json:"id"`
+	Type           Type           `json:"type"`
+	Timestamp      time.Time      `json:"timestamp"`
+	ClientID       string         `json:"client_id,omitempty"`
+	UserCode       string         `json:"user_code,omitempty"`
+	DeviceCodeHash string         `json:"device_code_hash,omitempty"`
+	Scope          string         `json:"scope,omitempty"`
+	DeviceID       string         `json:"device_id,omitempty"`
+	Data           map[string]any `json:"data,omitempty"`
+}
+
+// Emitter publishes lifecycle events. Delivery is asynchronous and must not
+// block or fail the flow operation that produced the event.
+type Emitter interface {
+	// Emit queues an event for delivery
+	Emit(ctx context.Context, event Event)
+}
+
+// NopEmitter discards all events
+type NopEmitter struct{}
+
+// Emit implements Emitter
+func (NopEmitter) Emit(ctx context.Context, event Event) {}
+
+// MultiEmitter publishes events to several emitters
+type MultiEmitter []Emitter
+
+// Emit implements Emitter
+func (m MultiEmitter) Emit(ctx context.Context, event Event) {
+	for _, e := range m {
+		e.Emit(ctx, event)
+	}
+}
+
+// New creates an event of the given type with a unique ID and current timestamp
+func New(eventType Type) Event {
+	return Event{
+		ID:        newEventID(),
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+// newEventID generates a random identifier consumers can use to deduplicate retries
+func newEventID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Fall back to a timestamp, uniqueness is best effort for consumers
+		return hex.EncodeToString([]byte(time.Now().UTC().Format(time.RFC3339Nano)))
+	}
+	return hex.EncodeToString(b)
+}
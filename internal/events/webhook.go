@@ -0,0 +1,136 @@
+package events
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/canonical"
+	"github.com/wrale/oauth2-device-proxy/internal/delivery"
+)
+
+// Webhook request headers
+const (
+	HeaderEventID   = "X-Webhook-Id"
+	HeaderEventType = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+
+	// HeaderContentHash carries the hex SHA-256 of the canonical JSON body so that
+	// receivers can deduplicate retried deliveries by content
+	HeaderContentHash = "X-Webhook-Content-SHA256"
+)
+
+// Webhook delivery defaults
+const (
+	DefaultMaxRetries     = 5
+	DefaultInitialBackoff = delivery.DefaultInitialBackoff
+	DefaultMaxBackoff     = delivery.DefaultMaxBackoff
+	DefaultTimeout        = delivery.DefaultTimeout
+	DefaultQueueSize      = delivery.DefaultQueueSize
+)
+
+// WebhookConfig configures webhook delivery
+type WebhookConfig struct {
+	URL            string        // Endpoint receiving event POSTs
+	Secret         []byte        // HMAC-SHA256 signing key, unsigned when empty
+	MaxRetries     int           // Retries after the first attempt
+	InitialBackoff time.Duration // Delay before the first retry, doubled per attempt
+	MaxBackoff     time.Duration // Upper bound on retry delay
+	Timeout        time.Duration // Per-attempt request timeout
+	QueueSize      int           // Events buffered before new ones are dropped
+	Client         *http.Client  // Optional HTTP client
+}
+
+// WebhookEmitter delivers events as HMAC-signed JSON POSTs with retry and backoff
+type WebhookEmitter struct {
+	cfg   WebhookConfig
+	queue *delivery.Queue
+}
+
+// NewWebhookEmitter creates a webhook emitter and starts its delivery worker
+func NewWebhookEmitter(cfg WebhookConfig) (*WebhookEmitter, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+
+	return &WebhookEmitter{
+		cfg: cfg,
+		queue: delivery.New(delivery.Config{
+			MaxRetries:     cfg.MaxRetries,
+			InitialBackoff: cfg.InitialBackoff,
+			MaxBackoff:     cfg.MaxBackoff,
+			Timeout:        cfg.Timeout,
+			QueueSize:      cfg.QueueSize,
+			Client:         cfg.Client,
+		}),
+	}, nil
+}
+
+// Emit queues an event for delivery, dropping it if the queue is full so that
+// a slow or unavailable receiver cannot stall the device flow
+func (e *WebhookEmitter) Emit(ctx context.Context, event Event) {
+	// Canonical encoding keeps signatures and content hashes stable for receivers
+	// that re-serialize the payload before verifying it
+	body, err := canonical.Marshal(event)
+	if err != nil {
+		log.Printf("Error: encoding %s event %s: %v", event.Type, event.ID, err)
+		return
+	}
+
+	timestamp := strconv.FormatInt(event.Timestamp.Unix(), 10)
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(HeaderEventID, event.ID)
+	header.Set(HeaderEventType, string(event.Type))
+	header.Set(HeaderTimestamp, timestamp)
+	if len(e.cfg.Secret) > 0 {
+		header.Set(HeaderSignature, Sign(e.cfg.Secret, timestamp, body))
+	}
+	sum := sha256.Sum256(body)
+	header.Set(HeaderContentHash, hex.EncodeToString(sum[:]))
+
+	err = e.queue.Enqueue(delivery.Request{
+		Name:   fmt.Sprintf("%s event %s", event.Type, event.ID),
+		URL:    e.cfg.URL,
+		Header: header,
+		Body:   body,
+	})
+	switch {
+	case errors.Is(err, delivery.ErrClosed):
+		log.Printf("Warning: webhook emitter closed, dropping %s event %s", event.Type, event.ID)
+	case errors.Is(err, delivery.ErrQueueFull):
+		log.Printf("Warning: webhook queue full, dropping %s event %s", event.Type, event.ID)
+	}
+}
+
+// Close stops accepting events and waits for queued deliveries to finish or ctx to expire
+func (e *WebhookEmitter) Close(ctx context.Context) error {
+	if err := e.queue.Close(ctx); err != nil {
+		return fmt.Errorf("waiting for webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// Sign computes the webhook signature over the timestamp and body. Including the
+// timestamp lets receivers reject replayed deliveries outside their tolerance.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks a webhook signature in constant time
+func VerifySignature(secret []byte, timestamp string, body []byte, signature string) bool {
+	expected := Sign(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,153 @@
+package events
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/canonical"
+)
+
+func TestWebhookDelivery(t *testing.T) {
+	secret := []byte("webhook-secret")
+
+	var mu sync.Mutex
+	var received []Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading body: %v", err)
+		}
+
+		if !VerifySignature(secret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)) {
+			t.Error("webhook signature did not verify")
+		}
+		if canonicalBody, err := canonical.Canonicalize(body); err != nil || string(canonicalBody) != string(body) {
+			t.Errorf("webhook body is not canonical JSON: %s", body)
+		}
+		sum := sha256.Sum256(body)
+		if got := r.Header.Get(HeaderContentHash); got != hex.EncodeToString(sum[:]) {
+			t.Errorf("content hash header = %q", got)
+		}
+		if got := r.Header.Get(HeaderEventType); got != string(TypeDeviceCodeCreated) {
+			t.Errorf("event type header = %q, want %q", got, TypeDeviceCodeCreated)
+		}
+
+		var event Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Errorf("decoding event: %v", err)
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	emitter, err := NewWebhookEmitter(WebhookConfig{URL: srv.URL, Secret: secret})
+	if err != nil {
+		t.Fatalf("NewWebhookEmitter failed: %v", err)
+	}
+
+	event := New(TypeDeviceCodeCreated)
+	event.ClientID = "test-client"
+	emitter.Emit(context.Background(), event)
+
+	if err := emitter.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("received %d events, want 1", len(received))
+	}
+	if received[0].ID != event.ID || received[0].ClientID != "test-client" {
+		t.Errorf("received event = %+v, want %+v", received[0], event)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int32
+	}{
+		{
+			name:         "retries server errors",
+			statuses:     []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK},
+			wantAttempts: 3,
+		},
+		{
+			name:         "retries rate limiting",
+			statuses:     []int{http.StatusTooManyRequests, http.StatusOK},
+			wantAttempts: 2,
+		},
+		{
+			name:         "client errors are permanent",
+			statuses:     []int{http.StatusBadRequest, http.StatusOK},
+			wantAttempts: 1,
+		},
+		{
+			name:         "gives up after max retries",
+			statuses:     []int{500, 500, 500, 500, 500, 500},
+			wantAttempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(tt.statuses[n-1])
+			}))
+			defer srv.Close()
+
+			emitter, err := NewWebhookEmitter(WebhookConfig{
+				URL:            srv.URL,
+				MaxRetries:     2,
+				InitialBackoff: time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("NewWebhookEmitter failed: %v", err)
+			}
+
+			emitter.Emit(context.Background(), New(TypeUserVerified))
+			if err := emitter.Close(context.Background()); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+
+			if got := atomic.LoadInt32(&attempts); got != tt.wantAttempts {
+				t.Errorf("delivery attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestWebhookRequiresURL(t *testing.T) {
+	if _, err := NewWebhookEmitter(WebhookConfig{}); err == nil {
+		t.Error("expected error for missing URL")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"type":"user.verified"}`)
+	sig := Sign(secret, "1700000000", body)
+
+	if !VerifySignature(secret, "1700000000", body, sig) {
+		t.Error("valid signature rejected")
+	}
+	if VerifySignature(secret, "1700000001", body, sig) {
+		t.Error("signature accepted with different timestamp")
+	}
+	if VerifySignature([]byte("other"), "1700000000", body, sig) {
+		t.Error("signature accepted with different secret")
+	}
+}
@@ -0,0 +1,156 @@
+// Package features gates risky features behind flags so that they can be
+// rolled out incrementally. Each flag is read from an ENABLE_<FLAG>
+// environment variable and is on for every client, for none, or for a
+// stable percentage of clients chosen by hashing the client ID, e.g.
+// ENABLE_SSE_TOKEN_DELIVERY=25%.
+package features
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Flag names a feature gated by a flag
+type Flag string
+
+// Defined flags
+const (
+	// SSETokenDelivery answers /device/token/stream requests asking for
+	// text/event-stream with Server-Sent Events instead of a long poll
+	SSETokenDelivery Flag = "SSE_TOKEN_DELIVERY"
+
+	// ConsentScreen shows the client and scopes for approval before users are
+	// sent to the identity provider
+	ConsentScreen Flag = "CONSENT_SCREEN"
+
+	// AnomalyReverify requires approval again when a code is polled from
+	// another network or User-Agent than the one that requested it
+	AnomalyReverify Flag = "ANOMALY_REVERIFY"
+)
+
+// Known lists the defined flags
+var Known = []Flag{SSETokenDelivery, ConsentScreen, AnomalyReverify}
+
+// envPrefix prefixes the environment variable of each flag
+const envPrefix = "ENABLE_"
+
+// Set holds the rollout percentage of each flag. A nil Set has every flag off.
+type Set struct {
+	rollout map[Flag]int // 0 to 100
+}
+
+// New creates a set with the given flags fully on and all others off
+func New(enabled ...Flag) *Set {
+	s := &Set{rollout: make(map[Flag]int)}
+	for _, flag := range enabled {
+		s.rollout[flag] = 100
+	}
+	return s
+}
+
+// FromEnv reads the ENABLE_<FLAG> variable of each known flag, using the
+// defaults for unset flags
+func FromEnv(defaults map[Flag]bool) (*Set, error) {
+	return fromLookup(os.LookupEnv, defaults)
+}
+
+// fromLookup reads flags with the given environment lookup
+func fromLookup(lookup func(string) (string, bool), defaults map[Flag]bool) (*Set, error) {
+	s := New()
+	for _, flag := range Known {
+		if defaults[flag] {
+			s.rollout[flag] = 100
+		}
+		value, ok := lookup(envPrefix + string(flag))
+		if !ok || strings.TrimSpace(value) == "" {
+			continue
+		}
+		percent, err := parseRollout(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s%s: %w", envPrefix, flag, err)
+		}
+		s.rollout[flag] = percent
+	}
+	return s, nil
+}
+
+// parseRollout parses a boolean or a percentage such as 25%
+func parseRollout(value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if percent, ok := strings.CutSuffix(value, "%"); ok {
+		n, err := strconv.Atoi(strings.TrimSpace(percent))
+		if err != nil || n < 0 || n > 100 {
+			return 0, fmt.Errorf("invalid rollout percentage %q", value)
+		}
+		return n, nil
+	}
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q, want true, false or a percentage", value)
+	}
+	if on {
+		return 100, nil
+	}
+	return 0, nil
+}
+
+// Set sets a flag's rollout percentage, clamped to 0 through 100
+func (s *Set) Set(flag Flag, percent int) *Set {
+	s.rollout[flag] = min(max(percent, 0), 100)
+	return s
+}
+
+// Enabled reports whether a flag is on for every client
+func (s *Set) Enabled(flag Flag) bool {
+	return s != nil && s.rollout[flag] >= 100
+}
+
+// EnabledFor reports whether a flag is on for a client. Partially rolled out
+// flags stay on or off for a client across requests and restarts.
+func (s *Set) EnabledFor(flag Flag, clientID string) bool {
+	if s == nil {
+		return false
+	}
+	percent := s.rollout[flag]
+	switch {
+	case percent >= 100:
+		return true
+	case percent <= 0:
+		return false
+	}
+	return bucket(flag, clientID) < percent
+}
+
+// Names lists the flags that are on for any client in lower case, with the
+// percentage of partially rolled out flags, e.g. consent_screen=25%
+func (s *Set) Names() []string {
+	if s == nil {
+		return nil
+	}
+	var names []string
+	for flag, percent := range s.rollout {
+		name := strings.ToLower(string(flag))
+		switch {
+		case percent >= 100:
+			names = append(names, name)
+		case percent > 0:
+			names = append(names, fmt.Sprintf("%s=%d%%", name, percent))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bucket places a client in one of 100 buckets, independently for each flag
+// so that the same clients are not always the first to receive new features
+func bucket(flag Flag, clientID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(flag))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(clientID))
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,68 @@
+package features
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFromLookup(t *testing.T) {
+	env := map[string]string{
+		"ENABLE_SSE_TOKEN_DELIVERY": "false",
+		"ENABLE_CONSENT_SCREEN":     "25%",
+	}
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	s, err := fromLookup(lookup, map[Flag]bool{SSETokenDelivery: true, AnomalyReverify: true})
+	if err != nil {
+		t.Fatalf("fromLookup failed: %v", err)
+	}
+	if s.Enabled(SSETokenDelivery) {
+		t.Error("SSE_TOKEN_DELIVERY enabled, want the environment to override the default")
+	}
+	if !s.Enabled(AnomalyReverify) {
+		t.Error("ANOMALY_REVERIFY disabled, want the default")
+	}
+	if s.Enabled(ConsentScreen) {
+		t.Error("partially rolled out CONSENT_SCREEN reported enabled for every client")
+	}
+	if got, want := fmt.Sprint(s.Names()), "[anomaly_reverify consent_screen=25%]"; got != want {
+		t.Errorf("Names() = %s, want %s", got, want)
+	}
+
+	for _, value := range []string{"maybe", "101%", "-1%"} {
+		env["ENABLE_CONSENT_SCREEN"] = value
+		if _, err := fromLookup(lookup, nil); err == nil {
+			t.Errorf("fromLookup accepted %q", value)
+		}
+	}
+}
+
+func TestEnabledFor(t *testing.T) {
+	s := New().Set(ConsentScreen, 30)
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		clientID := fmt.Sprintf("client-%d", i)
+		on := s.EnabledFor(ConsentScreen, clientID)
+		if on != s.EnabledFor(ConsentScreen, clientID) {
+			t.Fatalf("rollout for %s is not stable", clientID)
+		}
+		if on {
+			enabled++
+		}
+	}
+	if enabled < 200 || enabled > 400 {
+		t.Errorf("flag on for %d of 1000 clients, want about 300", enabled)
+	}
+
+	if New(ConsentScreen).EnabledFor(SSETokenDelivery, "tv") {
+		t.Error("unset flag enabled")
+	}
+	var none *Set
+	if none.EnabledFor(ConsentScreen, "tv") || none.Enabled(ConsentScreen) {
+		t.Error("nil set enabled a flag")
+	}
+}
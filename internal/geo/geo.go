@@ -0,0 +1,74 @@
+// Package geo resolves the approximate location of a request for display to
+// users approving device authorization, per RFC 8628 section 5.4
+package geo
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Location is an approximate request location
+type Location struct {
+	City    string
+	Region  string
+	Country string
+}
+
+// String formats the location from most to least specific, or "" when unknown
+func (l Location) String() string {
+	parts := make([]string, 0, 3)
+	for _, p := range []string{l.City, l.Region, l.Country} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Locator resolves the location of a request
+type Locator interface {
+	Locate(r *http.Request) Location
+}
+
+// NopLocator never resolves a location
+type NopLocator struct{}
+
+// Locate implements Locator
+func (NopLocator) Locate(r *http.Request) Location { return Location{} }
+
+// HeaderLocator reads a location set by a trusted edge proxy or CDN, such as
+// Cloudflare's CF-IPCountry. Only use it when clients cannot reach the proxy
+// directly, since the headers are otherwise client controlled.
+type HeaderLocator struct {
+	CityHeader    string
+	RegionHeader  string
+	CountryHeader string
+}
+
+// Locate implements Locator
+func (l HeaderLocator) Locate(r *http.Request) Location {
+	return Location{
+		City:    header(r, l.CityHeader),
+		Region:  header(r, l.RegionHeader),
+		Country: header(r, l.CountryHeader),
+	}
+}
+
+// maxHeaderValue bounds location values copied into stored device codes
+const maxHeaderValue = 64
+
+// header returns a trimmed, bounded header value, or "" when unset
+func header(r *http.Request, name string) string {
+	if name == "" {
+		return ""
+	}
+	v := strings.TrimSpace(r.Header.Get(name))
+	if len(v) > maxHeaderValue {
+		v = v[:maxHeaderValue]
+	}
+	// Cloudflare reports unknown and Tor origins with placeholder codes
+	if v == "XX" || v == "T1" {
+		return ""
+	}
+	return v
+}
@@ -0,0 +1,37 @@
+package geo
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHeaderLocator(t *testing.T) {
+	locator := HeaderLocator{
+		CityHeader:    "X-Geo-City",
+		RegionHeader:  "X-Geo-Region",
+		CountryHeader: "CF-IPCountry",
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{name: "full location", headers: map[string]string{"X-Geo-City": "Berlin", "X-Geo-Region": "BE", "CF-IPCountry": "DE"}, want: "Berlin, BE, DE"},
+		{name: "country only", headers: map[string]string{"CF-IPCountry": "DE"}, want: "DE"},
+		{name: "unknown country", headers: map[string]string{"CF-IPCountry": "XX"}, want: ""},
+		{name: "no headers", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/device/code", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := locator.Locate(r).String(); got != tt.want {
+				t.Errorf("Locate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the circuit breaker state
+type State int
+
+// Circuit breaker states
+const (
+	StateClosed   State = iota // Requests flow normally
+	StateOpen                  // Requests fail fast until the cooldown elapses
+	StateHalfOpen              // A single probe request is allowed through
+)
+
+// String returns the state name used in health details
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// breaker opens after consecutive failures and probes recovery after a cooldown
+type breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	state    State
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// allow reports whether a request may proceed
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return true
+	case StateHalfOpen:
+		if b.probing {
+			return false // Only one probe at a time
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// record updates the breaker with the outcome of an allowed request
+func (b *breaker) record(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if success {
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// current returns the breaker state, reporting an expired open state as half-open
+func (b *breaker) current() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return StateHalfOpen
+	}
+	return b.state
+}
@@ -0,0 +1,190 @@
+// Package httpclient provides a resilient HTTP client for identity provider calls
+// with per-attempt timeouts, retries with exponential backoff for idempotent
+// requests, and a circuit breaker that fails fast while the upstream is down.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Client defaults
+const (
+	DefaultTimeout          = 10 * time.Second
+	DefaultMaxRetries       = 2
+	DefaultInitialBackoff   = 200 * time.Millisecond
+	DefaultMaxBackoff       = 2 * time.Second
+	DefaultFailureThreshold = 5
+	DefaultCooldown         = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without contacting the upstream while the breaker is open
+var ErrCircuitOpen = errors.New("upstream circuit breaker is open")
+
+// Config configures a resilient client. Zero values use the package defaults.
+type Config struct {
+	Timeout          time.Duration     // Per-attempt timeout
+	MaxRetries       int               // Retries after the first attempt, idempotent requests only
+	InitialBackoff   time.Duration     // Delay before the first retry
+	MaxBackoff       time.Duration     // Upper bound for retry delays
+	FailureThreshold int               // Consecutive failures that open the breaker
+	Cooldown         time.Duration     // Time the breaker stays open before probing
+	Transport        http.RoundTripper // Underlying transport, http.DefaultTransport if nil
+}
+
+// Client is an http.RoundTripper adding timeouts, retries and circuit breaking
+type Client struct {
+	cfg       Config
+	transport http.RoundTripper
+	breaker   *breaker
+}
+
+// New creates a resilient client
+func New(cfg Config) *Client {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = DefaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultFailureThreshold
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = DefaultCooldown
+	}
+
+	transport := cfg.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &Client{
+		cfg:       cfg,
+		transport: transport,
+		breaker:   newBreaker(cfg.FailureThreshold, cfg.Cooldown),
+	}
+}
+
+// HTTPClient returns an *http.Client using this client as its transport
+func (c *Client) HTTPClient() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// State returns the current circuit breaker state
+func (c *Client) State() State {
+	return c.breaker.current()
+}
+
+// CheckHealth reports an error while the circuit breaker is open
+func (c *Client) CheckHealth(ctx context.Context) error {
+	if c.State() == StateOpen {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (c *Client) RoundTrip(req *http.Request) (*http.Response, error) {
+	retries := 0
+	if isIdempotent(req) {
+		retries = c.cfg.MaxRetries
+	}
+
+	backoff := c.cfg.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(req, attempt)
+		if !shouldRetry(resp, err) || attempt >= retries {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > c.cfg.MaxBackoff {
+			backoff = c.cfg.MaxBackoff
+		}
+	}
+}
+
+// attempt performs a single request through the breaker with the per-attempt timeout
+func (c *Client) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	if attempt > 0 && req.Body != nil {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("request body cannot be replayed for retry")
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("replaying request body: %w", err)
+		}
+		req = req.Clone(req.Context())
+		req.Body = body
+	}
+
+	if !c.breaker.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), c.cfg.Timeout)
+	resp, err := c.transport.RoundTrip(req.WithContext(ctx))
+	c.breaker.record(err == nil && resp.StatusCode < http.StatusInternalServerError)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// Keep the timeout context alive until the caller finishes reading the body
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// isIdempotent reports whether a request can be safely retried per RFC 9110 section 9.2.2
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// shouldRetry reports whether an attempt failed transiently
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, ErrCircuitOpen) && !errors.Is(err, context.Canceled)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// cancelBody releases the attempt's timeout context when the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and releases its context
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
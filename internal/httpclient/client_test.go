@@ -0,0 +1,158 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetriesIdempotentRequests(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	client := New(Config{MaxRetries: 3, InitialBackoff: time.Millisecond}).HTTPClient()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" {
+		t.Errorf("response = %d %q, want 200 ok", resp.StatusCode, body)
+	}
+	if got := atomic.LoadInt32(&calls); got != 3 {
+		t.Errorf("upstream called %d times, want 3", got)
+	}
+}
+
+func TestDoesNotRetryNonIdempotentRequests(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := New(Config{MaxRetries: 3, InitialBackoff: time.Millisecond}).HTTPClient()
+	resp, err := client.Post(srv.URL, "application/x-www-form-urlencoded", strings.NewReader("code=abc"))
+	if err != nil {
+		t.Fatalf("Post failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("upstream called %d times, want 1", got)
+	}
+}
+
+func TestRetriesReplayBody(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	client := New(Config{MaxRetries: 1, InitialBackoff: time.Millisecond}).HTTPClient()
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+	req.Header.Set("Idempotency-Key", "key-1")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if len(bodies) != 2 || bodies[1] != "payload" {
+		t.Errorf("bodies = %q, want payload replayed", bodies)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var calls int32
+	var healthy atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(Config{MaxRetries: 0, FailureThreshold: 2, Cooldown: time.Hour})
+	now := time.Now()
+	c.breaker.now = func() time.Time { return now }
+	client := c.HTTPClient()
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get %d failed: %v", i, err)
+		}
+		resp.Body.Close()
+	}
+
+	if c.State() != StateOpen {
+		t.Fatalf("state = %v, want open", c.State())
+	}
+	if err := c.CheckHealth(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("CheckHealth = %v, want %v", err, ErrCircuitOpen)
+	}
+
+	// Open breaker fails fast without contacting the upstream
+	if _, err := client.Get(srv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Get with open breaker = %v, want %v", err, ErrCircuitOpen)
+	}
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Errorf("upstream called %d times, want 2", got)
+	}
+
+	// After the cooldown a successful probe closes the breaker
+	now = now.Add(2 * time.Hour)
+	healthy.Store(true)
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("probe failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if c.State() != StateClosed {
+		t.Errorf("state = %v, want closed", c.State())
+	}
+}
+
+func TestClientErrorsDoNotTripBreaker(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	c := New(Config{FailureThreshold: 1})
+	for i := 0; i < 3; i++ {
+		resp, err := c.HTTPClient().Get(srv.URL)
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if c.State() != StateClosed {
+		t.Errorf("state = %v, want closed", c.State())
+	}
+}
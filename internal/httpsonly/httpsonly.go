@@ -0,0 +1,158 @@
+// Package httpsonly keeps plain HTTP requests away from the proxy's endpoints.
+// Device codes, user codes and tokens cross the device and verification
+// endpoints, which RFC 6749 section 1.6 and RFC 8628 require to use TLS.
+package httpsonly
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// Mode selects how plain HTTP requests are handled
+type Mode string
+
+// Enforcement modes
+const (
+	ModeOff      Mode = "off"      // Plain HTTP is served
+	ModeRedirect Mode = "redirect" // GET and HEAD are redirected to HTTPS, other methods rejected
+	ModeReject   Mode = "reject"   // Every plain HTTP request is rejected
+)
+
+// ParseMode parses a mode name: "off", "redirect" or "reject"
+func ParseMode(name string) (Mode, error) {
+	switch Mode(name) {
+	case ModeOff, "":
+		return ModeOff, nil
+	case ModeRedirect, ModeReject:
+		return Mode(name), nil
+	default:
+		return ModeOff, fmt.Errorf("unknown HTTPS enforcement mode %q", name)
+	}
+}
+
+// ParseProxies parses the addresses or CIDR ranges of trusted proxies
+func ParseProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Config configures the middleware
+type Config struct {
+	Mode Mode
+
+	// BaseURL is the public HTTPS origin redirects point to. The Host header
+	// is not trusted for redirects.
+	BaseURL string
+
+	// TrustedProxies may assert the original scheme with X-Forwarded-Proto.
+	// The header is ignored from other peers.
+	TrustedProxies []netip.Prefix
+
+	// Exempt paths are served over plain HTTP, such as health checks made
+	// directly to the listener
+	Exempt []string
+}
+
+// Middleware enforces HTTPS according to cfg. It must run before any
+// middleware rewriting the request's remote address from forwarding headers.
+func Middleware(cfg Config) func(http.Handler) http.Handler {
+	origin := ""
+	if u, err := url.Parse(cfg.BaseURL); err == nil && u.Host != "" {
+		origin = "https://" + u.Host
+	}
+	exempt := make(map[string]bool, len(cfg.Exempt))
+	for _, path := range cfg.Exempt {
+		exempt[path] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		if cfg.Mode == ModeOff || cfg.Mode == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempt[r.URL.Path] || secure(r, cfg.TrustedProxies) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// Only safe methods are redirected; anything sent with other
+			// methods has already crossed the network in the clear
+			if cfg.Mode == ModeRedirect && origin != "" && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+				insecureRequests.Inc("redirected")
+				http.Redirect(w, r, origin+r.URL.RequestURI(), http.StatusMovedPermanently)
+				return
+			}
+
+			insecureRequests.Inc("rejected")
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"error":"invalid_request","error_description":"HTTPS is required"}` + "\n"))
+		})
+	}
+}
+
+// secure reports whether a request arrived over TLS, directly or at a trusted
+// proxy that says so in X-Forwarded-Proto
+func secure(r *http.Request, trusted []netip.Prefix) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if len(trusted) == 0 || !trustedPeer(r.RemoteAddr, trusted) {
+		return false
+	}
+	// The first value was set by the proxy closest to the client
+	proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
+
+// trustedPeer reports whether the connection's peer is a trusted proxy
+func trustedPeer(remoteAddr string, trusted []netip.Prefix) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// insecureRequests counts plain HTTP requests by how they were handled
+var insecureRequests = metrics.Default.NewCounter(
+	"device_proxy_insecure_requests_total",
+	"Plain HTTP requests redirected to HTTPS or rejected.",
+	"action",
+)
@@ -0,0 +1,167 @@
+package httpsonly
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseMode(t *testing.T) {
+	for _, name := range []string{"", "off", "redirect", "reject"} {
+		if _, err := ParseMode(name); err != nil {
+			t.Errorf("ParseMode(%q) error = %v", name, err)
+		}
+	}
+	if _, err := ParseMode("strict"); err == nil {
+		t.Error("ParseMode(strict) expected error")
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	prefixes, err := ParseProxies([]string{"10.0.0.0/8", " 192.168.1.10 ", "::1", ""})
+	if err != nil {
+		t.Fatalf("ParseProxies() error = %v", err)
+	}
+	if len(prefixes) != 3 {
+		t.Fatalf("ParseProxies() = %v, want 3 prefixes", prefixes)
+	}
+	if got := prefixes[1].String(); got != "192.168.1.10/32" {
+		t.Errorf("single address parsed as %s", got)
+	}
+
+	if _, err := ParseProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+	if _, err := ParseProxies([]string{"proxy.internal"}); err == nil {
+		t.Error("expected error for host name")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	proxies, err := ParseProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		mode       Mode
+		method     string
+		target     string
+		remoteAddr string
+		proto      string
+		tls        bool
+		wantStatus int
+		wantTarget string
+	}{
+		{
+			name:       "off serves plain HTTP",
+			mode:       ModeOff,
+			method:     http.MethodPost,
+			target:     "/device/token",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "direct TLS",
+			mode:       ModeReject,
+			method:     http.MethodPost,
+			target:     "/device/token",
+			tls:        true,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "reject plain HTTP",
+			mode:       ModeReject,
+			method:     http.MethodPost,
+			target:     "/device/token",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "trusted proxy terminating TLS",
+			mode:       ModeReject,
+			method:     http.MethodPost,
+			target:     "/device/token",
+			remoteAddr: "10.1.2.3:4567",
+			proto:      "https",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "first forwarded scheme wins",
+			mode:       ModeReject,
+			method:     http.MethodPost,
+			target:     "/device/token",
+			remoteAddr: "10.1.2.3:4567",
+			proto:      "http, https",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "untrusted peer cannot claim https",
+			mode:       ModeReject,
+			method:     http.MethodPost,
+			target:     "/device/token",
+			remoteAddr: "203.0.113.7:4567",
+			proto:      "https",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "redirect GET",
+			mode:       ModeRedirect,
+			method:     http.MethodGet,
+			target:     "/device?code=ABCD-EFGH",
+			wantStatus: http.StatusMovedPermanently,
+			wantTarget: "https://proxy.example.com/device?code=ABCD-EFGH",
+		},
+		{
+			name:       "redirect mode rejects POST",
+			mode:       ModeRedirect,
+			method:     http.MethodPost,
+			target:     "/device/token",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "exempt path",
+			mode:       ModeReject,
+			method:     http.MethodGet,
+			target:     "/health",
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Middleware(Config{
+				Mode:           tt.mode,
+				BaseURL:        "https://proxy.example.com",
+				TrustedProxies: proxies,
+				Exempt:         []string{"/health"},
+			})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.Host = "attacker.example.com" // Redirects must not follow the Host header
+			req.TLS = nil
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantTarget != "" {
+				if got := rec.Header().Get("Location"); got != tt.wantTarget {
+					t.Errorf("Location = %q, want %q", got, tt.wantTarget)
+				}
+			}
+		})
+	}
+}
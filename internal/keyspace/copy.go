@@ -0,0 +1,110 @@
+package keyspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CopyOptions selects what Copy writes to the target deployment
+type CopyOptions struct {
+	FromPrefix string // Namespace of the keys in the source
+	ToPrefix   string // Namespace the keys are written under in the target
+	Replace    bool   // Overwrite keys that already exist in the target
+	DryRun     bool   // Count the keys that would be copied without writing them
+}
+
+// CopyResult reports the outcome of copying keys between deployments
+type CopyResult struct {
+	Copied  map[string]int // Keys copied, by pattern
+	Skipped []string       // Source keys left out because the target already has them
+}
+
+// Total returns the number of keys copied
+func (r *CopyResult) Total() int {
+	total := 0
+	for _, n := range r.Copied {
+		total += n
+	}
+	return total
+}
+
+// Copy copies every proxy key from one Redis deployment to another, either of
+// which may be a cluster, so that a deployment can move without dropping the
+// device flows in progress. Values are copied with DUMP and RESTORE, which
+// keeps their remaining lifetime and leaves sealed records sealed; the target
+// must run the same or a newer Redis version than the source. Stop the proxy
+// or let it drain before copying, since keys written mid-copy may be missed.
+func Copy(ctx context.Context, src, dst redis.UniversalClient, opts CopyOptions) (*CopyResult, error) {
+	result := &CopyResult{Copied: make(map[string]int)}
+	for _, pattern := range Patterns {
+		keys, err := scan(ctx, src, EscapeGlob(opts.FromPrefix)+pattern)
+		if err != nil {
+			return result, fmt.Errorf("listing %s keys: %w", pattern, err)
+		}
+		for _, key := range keys {
+			copied, exists, err := copyKey(ctx, src, dst, key, opts.ToPrefix+strings.TrimPrefix(key, opts.FromPrefix), opts)
+			switch {
+			case err != nil:
+				return result, err
+			case exists:
+				result.Skipped = append(result.Skipped, key)
+			case copied:
+				result.Copied[pattern]++
+			}
+		}
+	}
+	return result, nil
+}
+
+// copyKey copies one key with its remaining lifetime, reporting whether it
+// was copied and whether it was left out because the target already holds it.
+// Keys that expire while being copied are neither.
+func copyKey(ctx context.Context, src, dst redis.UniversalClient, key, target string, opts CopyOptions) (copied, exists bool, err error) {
+	ttl, err := src.PTTL(ctx, key).Result()
+	if err != nil {
+		return false, false, fmt.Errorf("reading lifetime of %s: %w", key, err)
+	}
+	switch {
+	case ttl == -2 || ttl == 0:
+		// Expired since it was listed, or about to. RESTORE would make a
+		// key with no lifetime left persistent.
+		return false, false, nil
+	case ttl == -1:
+		ttl = 0 // Persistent
+	}
+	if opts.DryRun {
+		if opts.Replace {
+			return true, false, nil
+		}
+		n, err := dst.Exists(ctx, target).Result()
+		if err != nil {
+			return false, false, fmt.Errorf("checking %s: %w", target, err)
+		}
+		return n == 0, n > 0, nil
+	}
+
+	value, err := src.Dump(ctx, key).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return false, false, nil
+		}
+		return false, false, fmt.Errorf("dumping %s: %w", key, err)
+	}
+
+	if opts.Replace {
+		err = dst.RestoreReplace(ctx, target, ttl, value).Err()
+	} else {
+		err = dst.Restore(ctx, target, ttl, value).Err()
+	}
+	if err != nil {
+		if strings.HasPrefix(err.Error(), "BUSYKEY") {
+			return false, true, nil
+		}
+		return false, false, fmt.Errorf("restoring %s: %w", target, err)
+	}
+	return true, false, nil
+}
@@ -0,0 +1,135 @@
+// Package keyspace moves the proxy's Redis keys between namespaces, so that an
+// existing deployment can adopt a key prefix and share its Redis instance, and
+// copies them between Redis deployments
+package keyspace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Patterns lists the keys written by the proxy's Redis stores, relative to
+// their namespace. They mirror the key prefixes of the deviceflow, csrf,
+// audit, stats and renewal stores, the client assertion and proof of work
+// replay caches, the record of issued tokens and the verification page
+// throttle.
+var Patterns = []string{
+	"device:*",
+	"user:*",
+	"token:*",
+	"rate:*",
+	"poll:*",
+	"submit:*",
+	"batch:*",
+	"consent:*",
+	"callback:*",
+	"client:*",
+	"expired:*",
+	"pending",
+	"csrf:*",
+	"audit:log",
+	"stats:*",
+	"assertion:*",
+	"pow:*",
+	"renewal:*",
+	"issued:*",
+	"throttle:*",
+}
+
+// scanCount is the SCAN batch size used when listing keys
+const scanCount = 100
+
+// MigrationResult reports the outcome of a namespace migration
+type MigrationResult struct {
+	Renamed int      // Keys moved to the new namespace
+	Skipped []string // Keys left in place because the target already exists
+}
+
+// Migrate renames every proxy key under the from prefix to the to prefix.
+// Renaming keeps values and expiry intact. Keys whose target already exists
+// are skipped rather than overwritten. A dry run counts keys without moving
+// them. Run it while the proxy is stopped so no key is written mid-migration.
+func Migrate(ctx context.Context, client *redis.Client, from, to string, dryRun bool) (*MigrationResult, error) {
+	if from == to {
+		return nil, errors.New("source and target prefixes are the same")
+	}
+
+	result := &MigrationResult{}
+	for _, pattern := range Patterns {
+		keys, err := scan(ctx, client, EscapeGlob(from)+pattern)
+		if err != nil {
+			return result, fmt.Errorf("listing %s keys: %w", pattern, err)
+		}
+
+		for _, key := range keys {
+			target := to + strings.TrimPrefix(key, from)
+
+			if dryRun {
+				result.Renamed++
+				continue
+			}
+			renamed, err := client.RenameNX(ctx, key, target).Result()
+			if err != nil {
+				if errors.Is(err, redis.Nil) {
+					continue // Expired since it was listed
+				}
+				return result, fmt.Errorf("renaming %s: %w", key, err)
+			}
+			if !renamed {
+				result.Skipped = append(result.Skipped, key)
+				continue
+			}
+			result.Renamed++
+		}
+	}
+
+	return result, nil
+}
+
+// scan lists the keys matching pattern, on every master of a cluster
+func scan(ctx context.Context, client redis.UniversalClient, pattern string) ([]string, error) {
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, client, pattern)
+	}
+
+	var mu sync.Mutex
+	var keys []string
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		found, err := scanNode(ctx, node, pattern)
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, found...)
+		return err
+	})
+	return keys, err
+}
+
+// scanNode lists the keys matching pattern on a single server
+func scanNode(ctx context.Context, client redis.UniversalClient, pattern string) ([]string, error) {
+	var keys []string
+	iter := client.Scan(ctx, 0, pattern, scanCount).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+// EscapeGlob escapes the characters SCAN MATCH treats as wildcards, so that a
+// prefix can be used literally in a pattern
+func EscapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
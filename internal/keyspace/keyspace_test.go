@@ -0,0 +1,33 @@
+package keyspace
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEscapeGlob(t *testing.T) {
+	tests := map[string]string{
+		"":          "",
+		"staging:":  "staging:",
+		"env[1]:":   `env\[1\]:`,
+		"a*b?c\\d:": `a\*b\?c\\d:`,
+	}
+	for in, want := range tests {
+		if got := EscapeGlob(in); got != want {
+			t.Errorf("EscapeGlob(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMigrateSamePrefix(t *testing.T) {
+	if _, err := Migrate(context.Background(), nil, "staging:", "staging:", false); err == nil {
+		t.Error("expected error migrating to the same prefix")
+	}
+}
+
+func TestCopyResultTotal(t *testing.T) {
+	result := &CopyResult{Copied: map[string]int{"device:*": 3, "token:*": 1, "csrf:*": 2}}
+	if got := result.Total(); got != 6 {
+		t.Errorf("Total() = %d, want 6", got)
+	}
+}
@@ -0,0 +1,226 @@
+// Package metrics provides lightweight counters and gauges exposed in the
+// Prometheus text format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default is the registry served by the proxy's /metrics endpoint
+var Default = NewRegistry()
+
+// Registry holds named metrics for exposition
+type Registry struct {
+	mu       sync.Mutex
+	counters map[string]*CounterVec
+	gauges   map[string]*GaugeVec
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		counters: make(map[string]*CounterVec),
+		gauges:   make(map[string]*GaugeVec),
+	}
+}
+
+// NewCounter registers a counter with the given label names. Registering the
+// same name twice returns the existing counter.
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if c, ok := r.counters[name]; ok {
+		return c
+	}
+
+	c := &CounterVec{vec: newVec(name, help, "counter", labels)}
+	r.counters[name] = c
+	return c
+}
+
+// NewGauge registers a gauge with the given label names. Registering the
+// same name twice returns the existing gauge.
+func (r *Registry) NewGauge(name, help string, labels ...string) *GaugeVec {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if g, ok := r.gauges[name]; ok {
+		return g
+	}
+
+	g := &GaugeVec{vec: newVec(name, help, "gauge", labels)}
+	r.gauges[name] = g
+	return g
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	vecs := make([]*vec, 0, len(r.counters)+len(r.gauges))
+	for _, c := range r.counters {
+		vecs = append(vecs, c.vec)
+	}
+	for _, g := range r.gauges {
+		vecs = append(vecs, g.vec)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(vecs, func(i, j int) bool { return vecs[i].name < vecs[j].name })
+
+	var b strings.Builder
+	for _, v := range vecs {
+		v.write(&b)
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler serves the registry in the Prometheus text format
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// CounterVec is a monotonically increasing counter partitioned by labels
+type CounterVec struct {
+	*vec
+}
+
+// GaugeVec is a value that can go up and down, partitioned by labels
+type GaugeVec struct {
+	*vec
+}
+
+// vec holds the labelled samples shared by counters and gauges
+type vec struct {
+	name   string
+	help   string
+	kind   string // Prometheus TYPE, counter or gauge
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*sample
+}
+
+// newVec creates an empty labelled metric
+func newVec(name, help, kind string, labels []string) *vec {
+	return &vec{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		labels: labels,
+		values: make(map[string]*sample),
+	}
+}
+
+// sample is a single labelled counter value
+type sample struct {
+	labelValues []string
+	value       float64
+}
+
+// Inc increments the counter for the given label values by one
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increases the counter for the given label values. Negative values are ignored.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.update(labelValues, func(s *sample) { s.value += v })
+}
+
+// Set sets the gauge for the given label values
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.update(labelValues, func(s *sample) { s.value = v })
+}
+
+// Add changes the gauge for the given label values by v, which may be negative
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	g.update(labelValues, func(s *sample) { s.value += v })
+}
+
+// update applies fn to the sample for the given label values, creating it if needed
+func (m *vec) update(labelValues []string, fn func(*sample)) {
+	if len(labelValues) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", m.name, len(m.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.values[key]
+	if !ok {
+		s = &sample{labelValues: append([]string(nil), labelValues...)}
+		m.values[key] = s
+	}
+	fn(s)
+}
+
+// Value returns the current value for the given label values
+func (m *vec) Value(labelValues ...string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s, ok := m.values[strings.Join(labelValues, "\xff")]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// write appends the metric's HELP, TYPE and sample lines
+func (m *vec) write(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintf(b, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", m.name, m.kind)
+
+	keys := make([]string, 0, len(m.values))
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := m.values[key]
+		b.WriteString(m.name)
+		if len(m.labels) > 0 {
+			b.WriteByte('{')
+			for i, label := range m.labels {
+				if i > 0 {
+					b.WriteByte(',')
+				}
+				fmt.Fprintf(b, "%s=\"%s\"", label, escapeLabel(s.labelValues[i]))
+			}
+			b.WriteByte('}')
+		}
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+		b.WriteByte('\n')
+	}
+}
+
+// escapeHelp escapes backslashes and newlines in HELP text
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+// escapeLabel escapes backslashes, quotes and newlines in label values
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCounterExposition(t *testing.T) {
+	reg := NewRegistry()
+	repairs := reg.NewCounter("store_repairs_total", "Repaired records.", "reason")
+	requests := reg.NewCounter("requests_total", "Handled requests.")
+
+	repairs.Inc("missing")
+	repairs.Add(2, "mismatch")
+	repairs.Inc("missing")
+	repairs.Add(-1, "missing") // Ignored
+	requests.Inc()
+
+	if got := repairs.Value("missing"); got != 2 {
+		t.Errorf("Value(missing) = %v, want 2", got)
+	}
+	if got := repairs.Value("unknown"); got != 0 {
+		t.Errorf("Value(unknown) = %v, want 0", got)
+	}
+
+	w := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	want := strings.Join([]string{
+		"# HELP requests_total Handled requests.",
+		"# TYPE requests_total counter",
+		"requests_total 1",
+		"# HELP store_repairs_total Repaired records.",
+		"# TYPE store_repairs_total counter",
+		`store_repairs_total{reason="mismatch"} 2`,
+		`store_repairs_total{reason="missing"} 2`,
+		"",
+	}, "\n")
+	if diff := cmp.Diff(want, w.Body.String()); diff != "" {
+		t.Errorf("exposition mismatch (-want +got):\n%s", diff)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+}
+
+func TestNewCounterReturnsExisting(t *testing.T) {
+	reg := NewRegistry()
+	a := reg.NewCounter("events_total", "Events.", "kind")
+	b := reg.NewCounter("events_total", "Events.", "kind")
+	a.Inc("x")
+	if got := b.Value("x"); got != 1 {
+		t.Errorf("Value(x) = %v, want 1", got)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("odd_total", "Odd labels.", "v").Inc("a\"b\\c\nd")
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	if want := `odd_total{v="a\"b\\c\nd"} 1`; !strings.Contains(b.String(), want) {
+		t.Errorf("output missing %q:\n%s", want, b.String())
+	}
+}
+
+func TestGaugeExposition(t *testing.T) {
+	reg := NewRegistry()
+	pending := reg.NewGauge("pending", "Pending items.")
+
+	pending.Set(5)
+	pending.Add(-2)
+	if got := pending.Value(); got != 3 {
+		t.Errorf("Value() = %v, want 3", got)
+	}
+
+	var b strings.Builder
+	if _, err := reg.WriteTo(&b); err != nil {
+		t.Fatalf("WriteTo() error = %v", err)
+	}
+	want := "# HELP pending Pending items.\n# TYPE pending gauge\npending 3\n"
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("exposition mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,150 @@
+// Package middleware assembles the HTTP middleware chain shared by the proxy's
+// servers. Each middleware is enabled by listing its name, and runs in the
+// order listed, outermost first.
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+
+	"github.com/wrale/oauth2-device-proxy/internal/httpsonly"
+)
+
+// Middleware names
+const (
+	NameRequestID       = "request_id"       // Correlation IDs shown on error pages
+	NameLogging         = "logging"          // Request log
+	NameRecovery        = "recovery"         // Turns handler panics into 500 responses
+	NameHTTPS           = "https"            // HTTPS enforcement
+	NameRealIP          = "real_ip"          // Remote address from forwarding headers
+	NameTimeout         = "timeout"          // Request deadline
+	NameRateLimit       = "rate_limit"       // Requests per client IP
+	NameSecurityHeaders = "security_headers" // Browser hardening response headers
+)
+
+// DefaultTimeout is the request deadline when none is configured
+const DefaultTimeout = 30 * time.Second
+
+// DefaultOrder lists the middlewares enabled when no order is configured
+var DefaultOrder = []string{NameRequestID, NameLogging, NameRecovery, NameHTTPS, NameRealIP, NameTimeout}
+
+// mustPrecede lists pairs of middlewares whose relative order is fixed when
+// both are enabled
+var mustPrecede = [][2]string{
+	// HTTPS enforcement trusts X-Forwarded-Proto by the proxy's own address,
+	// which RealIP replaces
+	{NameHTTPS, NameRealIP},
+	// Requests are limited by the client's address, not the proxy's
+	{NameRealIP, NameRateLimit},
+}
+
+// Config configures a middleware chain. Settings of middlewares that are not
+// enabled are ignored.
+type Config struct {
+	Order []string // Enabled middlewares, outermost first. Nil uses DefaultOrder.
+
+	// LogFormatter formats the request log, chi's default format on stdout if nil
+	LogFormatter chimw.LogFormatter
+
+	HTTPS           httpsonly.Config
+	Timeout         time.Duration // DefaultTimeout if zero
+	RateLimit       RateLimitConfig
+	SecurityHeaders SecurityHeadersConfig
+}
+
+// Chain returns the configured middlewares in order, or an error naming an
+// unknown, repeated or misplaced middleware
+func Chain(cfg Config) ([]func(http.Handler) http.Handler, error) {
+	order := cfg.Order
+	if order == nil {
+		order = DefaultOrder
+	}
+
+	position := make(map[string]int, len(order))
+	chain := make([]func(http.Handler) http.Handler, 0, len(order))
+	for i, name := range order {
+		name = strings.TrimSpace(name)
+		if _, dup := position[name]; dup {
+			return nil, fmt.Errorf("middleware %q is listed more than once", name)
+		}
+		position[name] = i
+
+		mw, err := build(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, mw)
+	}
+
+	for _, pair := range mustPrecede {
+		first, ok1 := position[pair[0]]
+		second, ok2 := position[pair[1]]
+		if ok1 && ok2 && first > second {
+			return nil, fmt.Errorf("middleware %q must come before %q", pair[0], pair[1])
+		}
+	}
+	return chain, nil
+}
+
+// Without returns an order, DefaultOrder if nil, with the named middlewares
+// removed, such as for a second listener sharing the main chain
+func Without(order []string, names ...string) []string {
+	if order == nil {
+		order = DefaultOrder
+	}
+	kept := make([]string, 0, len(order))
+	for _, name := range order {
+		if !slices.Contains(names, strings.TrimSpace(name)) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// build creates the named middleware
+func build(name string, cfg Config) (func(http.Handler) http.Handler, error) {
+	switch name {
+	case NameRequestID:
+		return chimw.RequestID, nil
+	case NameLogging:
+		formatter := cfg.LogFormatter
+		if formatter == nil {
+			formatter = DefaultLogFormatter()
+		}
+		return chimw.RequestLogger(formatter), nil
+	case NameRecovery:
+		return chimw.Recoverer, nil
+	case NameHTTPS:
+		return httpsonly.Middleware(cfg.HTTPS), nil
+	case NameRealIP:
+		return chimw.RealIP, nil
+	case NameTimeout:
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		return chimw.Timeout(timeout), nil
+	case NameRateLimit:
+		return RateLimit(cfg.RateLimit), nil
+	case NameSecurityHeaders:
+		return SecurityHeaders(cfg.SecurityHeaders), nil
+	default:
+		return nil, fmt.Errorf("unknown middleware %q", name)
+	}
+}
+
+// DefaultLogFormatter logs requests in chi's default format on stdout
+func DefaultLogFormatter() *chimw.DefaultLogFormatter {
+	return &chimw.DefaultLogFormatter{
+		Logger:  log.New(os.Stdout, "", log.LstdFlags),
+		NoColor: runtime.GOOS == "windows",
+	}
+}
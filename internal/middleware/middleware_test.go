@@ -0,0 +1,125 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/throttle"
+)
+
+func TestChain(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   []string
+		wantLen int
+		wantErr string
+	}{
+		{name: "default", wantLen: len(DefaultOrder)},
+		{name: "everything", order: []string{NameRequestID, NameLogging, NameRecovery, NameHTTPS, NameRealIP,
+			NameRateLimit, NameTimeout, NameSecurityHeaders}, wantLen: 8},
+		{name: "nothing", order: []string{}, wantLen: 0},
+		{name: "unknown", order: []string{"gzip"}, wantErr: "unknown"},
+		{name: "repeated", order: []string{NameRecovery, NameRecovery}, wantErr: "more than once"},
+		{name: "real IP before HTTPS", order: []string{NameRealIP, NameHTTPS}, wantErr: "must come before"},
+		{name: "rate limit before real IP", order: []string{NameRateLimit, NameRealIP}, wantErr: "must come before"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := Chain(Config{Order: tt.order})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Chain error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Chain failed: %v", err)
+			}
+			if len(chain) != tt.wantLen {
+				t.Errorf("chain has %d middlewares, want %d", len(chain), tt.wantLen)
+			}
+		})
+	}
+}
+
+// serve runs a request through the chain built from cfg
+func serve(t *testing.T, cfg Config, r *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	chain, err := Chain(cfg)
+	if err != nil {
+		t.Fatalf("Chain failed: %v", err)
+	}
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for i := len(chain) - 1; i >= 0; i-- {
+		handler = chain[i](handler)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg := Config{
+		Order: []string{NameRealIP, NameRateLimit},
+		RateLimit: RateLimitConfig{
+			Store:       throttle.NewMemoryStore(),
+			Window:      time.Minute,
+			MaxRequests: 2,
+			Exempt:      []string{"/health"},
+		},
+	}
+	request := func(path, clientIP string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = "10.0.0.1:1234"
+		r.Header.Set("X-Real-IP", clientIP)
+		return serve(t, cfg, r)
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request("/device", "203.0.113.7"); w.Code != http.StatusOK {
+			t.Fatalf("request %d status code = %d, want %d", i+1, w.Code, http.StatusOK)
+		}
+	}
+	w := request("/device", "203.0.113.7")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("over limit = %d with Retry-After %q, want 429 with 60", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// Clients are counted by their own address, not the proxy's
+	if w := request("/device", "198.51.100.2"); w.Code != http.StatusOK {
+		t.Errorf("other client status code = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w := request("/health", "203.0.113.7"); w.Code != http.StatusOK {
+		t.Errorf("exempt path status code = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	w := serve(t, Config{
+		Order:           []string{NameSecurityHeaders},
+		SecurityHeaders: SecurityHeadersConfig{HSTSMaxAge: 24 * time.Hour},
+	}, httptest.NewRequest(http.MethodGet, "/device", nil))
+
+	want := map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Referrer-Policy":           "no-referrer",
+		"Strict-Transport-Security": "max-age=86400",
+	}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	w = serve(t, Config{Order: []string{NameSecurityHeaders}}, httptest.NewRequest(http.MethodGet, "/device", nil))
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("Strict-Transport-Security without max age = %q, want none", got)
+	}
+}
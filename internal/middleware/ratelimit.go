@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+	"github.com/wrale/oauth2-device-proxy/internal/throttle"
+)
+
+// Rate limit defaults
+const (
+	DefaultRateLimitWindow      = time.Minute
+	DefaultRateLimitMaxRequests = 120
+)
+
+// RateLimitConfig configures the rate_limit middleware. Zero values use the
+// package defaults.
+type RateLimitConfig struct {
+	// Store counts requests, in process memory if nil. Deployments with
+	// several replicas should share a throttle.RedisStore.
+	Store       throttle.Store
+	Window      time.Duration // Length of the counting window
+	MaxRequests int           // Requests allowed per client IP per window
+	Exempt      []string      // Paths never limited, such as health checks
+}
+
+// RateLimit limits how many requests each client IP may make per window,
+// answering the excess with 429 Too Many Requests. Requests are let through
+// when the store fails, as the per-endpoint limits still apply.
+func RateLimit(cfg RateLimitConfig) func(http.Handler) http.Handler {
+	if cfg.Store == nil {
+		cfg.Store = throttle.NewMemoryStore()
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultRateLimitWindow
+	}
+	if cfg.MaxRequests <= 0 {
+		cfg.MaxRequests = DefaultRateLimitMaxRequests
+	}
+	exempt := make(map[string]bool, len(cfg.Exempt))
+	for _, path := range cfg.Exempt {
+		exempt[path] = true
+	}
+	retryAfter := strconv.Itoa(int((cfg.Window + time.Second - 1) / time.Second))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if exempt[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			requests, err := cfg.Store.Hit(r.Context(), "http:"+clientIP(r), cfg.Window)
+			if err != nil {
+				log.Printf("Warning: rate limit check failed: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if requests > int64(cfg.MaxRequests) {
+				rateLimited.Inc()
+				w.Header().Set("Retry-After", retryAfter)
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Cache-Control", "no-store")
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte(`{"error":"slow_down","error_description":"Too many requests"}` + "\n"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the address of the request's peer without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// rateLimited counts requests rejected by the rate_limit middleware
+var rateLimited = metrics.Default.NewCounter(
+	"device_proxy_http_rate_limited_total",
+	"Requests rejected because the client IP exceeded the request rate limit.",
+)
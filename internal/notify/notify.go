@@ -0,0 +1,165 @@
+// Package notify tells users when a device is linked to their account, so that
+// an authorization they did not intend, such as one phished with a user code,
+// is noticed and revoked
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// Queue defaults
+const (
+	DefaultQueueSize = 256
+	DefaultTimeout   = 10 * time.Second
+)
+
+// Notification describes a newly authorized device
+type Notification struct {
+	Recipient string `json:"recipient,omitempty"` // Authorizing user's email address, if known
+	UserName  string `json:"user_name,omitempty"`
+	Subject   string `json:"subject,omitempty"` // Authorizing user's identifier at the identity provider
+
+	ClientID        string    `json:"client_id"`
+	ClientName      string    `json:"client_name,omitempty"`
+	UserCode        string    `json:"user_code,omitempty"`
+	DeviceID        string    `json:"device_id,omitempty"`        // Client-asserted device identifier
+	RequestIP       string    `json:"request_ip,omitempty"`       // Where the device requested its code
+	RequestLocation string    `json:"request_location,omitempty"` // Approximate location of RequestIP
+	ApproverIP      string    `json:"approver_ip,omitempty"`      // Browser that approved the device
+	AuthorizedAt    time.Time `json:"authorized_at"`
+	RevokeURL       string    `json:"revoke_url,omitempty"` // Where the user can remove the device's access
+}
+
+// Notifier delivers device authorization notifications
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// NopNotifier discards notifications
+type NopNotifier struct{}
+
+// Notify implements Notifier
+func (NopNotifier) Notify(ctx context.Context, n Notification) error { return nil }
+
+// Queue delivers notifications in the background so that a slow mail server
+// or receiver cannot hold up the completion page. Notifications are dropped
+// when the queue is full.
+type Queue struct {
+	notifier Notifier
+	timeout  time.Duration
+	queue    chan Notification
+	done     chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewQueue starts delivering notifications through notifier. Zero values use
+// the defaults.
+func NewQueue(notifier Notifier, size int, timeout time.Duration) *Queue {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	q := &Queue{
+		notifier: notifier,
+		timeout:  timeout,
+		queue:    make(chan Notification, size),
+		done:     make(chan struct{}),
+	}
+	q.wg.Add(1)
+	go q.run()
+	return q
+}
+
+// Notify implements Notifier, queueing the notification without waiting for
+// delivery
+func (q *Queue) Notify(ctx context.Context, n Notification) error {
+	select {
+	case <-q.done:
+		notifications.Inc("dropped")
+		return fmt.Errorf("notification queue closed")
+	default:
+	}
+
+	select {
+	case q.queue <- n:
+		return nil
+	default:
+		notifications.Inc("dropped")
+		return fmt.Errorf("notification queue full")
+	}
+}
+
+// Close stops accepting notifications and waits for queued ones to be
+// delivered or ctx to expire
+func (q *Queue) Close(ctx context.Context) error {
+	q.once.Do(func() { close(q.done) })
+
+	finished := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for notifications: %w", ctx.Err())
+	}
+}
+
+// run delivers queued notifications until the queue is closed and drained
+func (q *Queue) run() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case n := <-q.queue:
+			q.deliver(n)
+		case <-q.done:
+			for {
+				select {
+				case n := <-q.queue:
+					q.deliver(n)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// deliver sends one notification, logging failures
+func (q *Queue) deliver(n Notification) {
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+	defer cancel()
+
+	err := q.notifier.Notify(ctx, n)
+	if errors.Is(err, ErrNoRecipient) {
+		notifications.Inc("skipped")
+		return
+	}
+	if err != nil {
+		notifications.Inc("failed")
+		log.Printf("Error: sending authorization notification for client %s: %v", n.ClientID, err)
+		return
+	}
+	notifications.Inc("sent")
+}
+
+// notifications counts device authorization notifications by outcome
+var notifications = metrics.Default.NewCounter(
+	"device_proxy_notifications_total",
+	"Device authorization notifications by outcome.",
+	"result",
+)
@@ -0,0 +1,154 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+)
+
+func testNotification() Notification {
+	return Notification{
+		Recipient:       "alice@example.com",
+		UserName:        "Alice",
+		Subject:         "user-1",
+		ClientID:        "living-room-tv",
+		ClientName:      "Living Room TV",
+		DeviceID:        "serial-42",
+		RequestIP:       "203.0.113.7",
+		RequestLocation: "Berlin, DE",
+		ApproverIP:      "198.51.100.2",
+		AuthorizedAt:    time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC),
+		RevokeURL:       "https://sso.example.com/realms/main/account/applications",
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	n, err := NewSMTPNotifier(SMTPConfig{Addr: "mail.example.com:587", From: "Device Sign-in <noreply@example.com>"})
+	if err != nil {
+		t.Fatalf("NewSMTPNotifier() error = %v", err)
+	}
+
+	var gotFrom string
+	var gotTo []string
+	var gotMsg string
+	n.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotFrom, gotTo, gotMsg = from, to, string(msg)
+		return nil
+	}
+
+	notification := testNotification()
+	notification.ClientName = "Evil\r\nBcc: victim@example.com"
+	if err := n.Notify(context.Background(), notification); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+
+	if gotFrom != "noreply@example.com" || len(gotTo) != 1 || gotTo[0] != "alice@example.com" {
+		t.Errorf("envelope from %q to %v", gotFrom, gotTo)
+	}
+	for _, want := range []string{
+		"To: <alice@example.com>\r\n",
+		"Hello Alice,",
+		"Device ID:      serial-42",
+		"Device address: 203.0.113.7 (Berlin, DE)",
+		"Approved from:  198.51.100.2",
+		"2024-05-01 12:30 UTC",
+		"https://sso.example.com/realms/main/account/applications",
+	} {
+		if !strings.Contains(gotMsg, want) {
+			t.Errorf("message missing %q:\n%s", want, gotMsg)
+		}
+	}
+	if strings.Contains(gotMsg, "\nBcc:") || !strings.Contains(gotMsg, "Application:    Evil Bcc: victim@example.com") {
+		t.Errorf("client name line breaks kept:\n%s", gotMsg)
+	}
+
+	notification.Recipient = ""
+	if err := n.Notify(context.Background(), notification); !errors.Is(err, ErrNoRecipient) {
+		t.Errorf("Notify() without recipient error = %v, want ErrNoRecipient", err)
+	}
+}
+
+func TestNewSMTPNotifierValidation(t *testing.T) {
+	if _, err := NewSMTPNotifier(SMTPConfig{Addr: "mail.example.com", From: "noreply@example.com"}); err == nil {
+		t.Error("expected error for address without port")
+	}
+	if _, err := NewSMTPNotifier(SMTPConfig{Addr: "mail.example.com:25", From: "not an address"}); err == nil {
+		t.Error("expected error for invalid sender")
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	secret := []byte("notify-secret")
+	var got Notification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !events.VerifySignature(secret, r.Header.Get(HeaderTimestamp), body, r.Header.Get(HeaderSignature)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("decoding notification: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	n, err := NewWebhookNotifier(WebhookConfig{URL: srv.URL, Secret: secret})
+	if err != nil {
+		t.Fatalf("NewWebhookNotifier() error = %v", err)
+	}
+	if err := n.Notify(context.Background(), testNotification()); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if got.Subject != "user-1" || got.ClientName != "Living Room TV" || got.RevokeURL == "" {
+		t.Errorf("received %+v", got)
+	}
+
+	rejecting, _ := NewWebhookNotifier(WebhookConfig{URL: srv.URL, Secret: []byte("wrong")})
+	if err := rejecting.Notify(context.Background(), testNotification()); err == nil {
+		t.Error("expected error when the receiver rejects the notification")
+	}
+}
+
+// recordingNotifier collects delivered notifications
+type recordingNotifier struct {
+	mu   sync.Mutex
+	sent []Notification
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, n Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, n)
+	return nil
+}
+
+func TestQueue(t *testing.T) {
+	recorder := &recordingNotifier{}
+	q := NewQueue(recorder, 4, time.Second)
+
+	for i := 0; i < 3; i++ {
+		if err := q.Notify(context.Background(), testNotification()); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+	if err := q.Close(context.Background()); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if len(recorder.sent) != 3 {
+		t.Errorf("delivered %d notifications, want 3", len(recorder.sent))
+	}
+	if err := q.Notify(context.Background(), testNotification()); err == nil {
+		t.Error("expected error after Close")
+	}
+}
@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// ErrNoRecipient is returned by notifiers that address users directly when
+// the authorizing user's email address is unknown
+var ErrNoRecipient = errors.New("no notification recipient")
+
+// SMTPConfig configures an SMTP notifier
+type SMTPConfig struct {
+	Addr     string // Mail server host:port; STARTTLS is used when offered
+	Username string // Optional, PLAIN authentication requires TLS or localhost
+	Password string
+	From     string // Sender address, e.g. "Device Sign-in <noreply@example.com>"
+}
+
+// SMTPNotifier emails the authorizing user
+type SMTPNotifier struct {
+	cfg  SMTPConfig
+	from *mail.Address
+	auth smtp.Auth
+
+	// send delivers a message, replaced in tests
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPNotifier creates an SMTP notifier
+func NewSMTPNotifier(cfg SMTPConfig) (*SMTPNotifier, error) {
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %q: %w", cfg.Addr, err)
+	}
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", cfg.From, err)
+	}
+
+	n := &SMTPNotifier{cfg: cfg, from: from, send: smtp.SendMail}
+	if cfg.Username != "" {
+		n.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	return n, nil
+}
+
+// Notify implements Notifier. net/smtp cannot be cancelled, so ctx only
+// prevents sending after it has ended.
+func (s *SMTPNotifier) Notify(ctx context.Context, n Notification) error {
+	if n.Recipient == "" {
+		return ErrNoRecipient
+	}
+	to, err := mail.ParseAddress(n.Recipient)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	msg, err := s.message(to, n)
+	if err != nil {
+		return err
+	}
+	if err := s.send(s.cfg.Addr, s.auth, s.from.Address, []string{to.Address}, msg); err != nil {
+		return fmt.Errorf("sending mail: %w", err)
+	}
+	return nil
+}
+
+// message renders the notification as a plain text email
+func (s *SMTPNotifier) message(to *mail.Address, n Notification) ([]byte, error) {
+	// Names come from clients and the identity provider, so line breaks that
+	// could forge headers or text are dropped
+	n.UserName = oneLine(n.UserName)
+	n.ClientName = oneLine(n.ClientName)
+	n.DeviceID = oneLine(n.DeviceID)
+	n.RequestLocation = oneLine(n.RequestLocation)
+
+	var body bytes.Buffer
+	if err := mailBody.Execute(&body, n); err != nil {
+		return nil, fmt.Errorf("rendering mail: %w", err)
+	}
+
+	device := n.ClientName
+	if device == "" {
+		device = n.ClientID
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", to.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "New device signed in: "+device))
+	fmt.Fprintf(&msg, "Date: %s\r\n", n.AuthorizedAt.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body.String(), "\n", "\r\n"))
+	return msg.Bytes(), nil
+}
+
+// oneLine drops line breaks from a header value
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// mailBody is the text of the notification email
+var mailBody = template.Must(template.New("mail").Parse(`Hello{{with .UserName}} {{.}}{{end}},
+
+A device was just given access to your account.
+
+  Application:    {{if .ClientName}}{{.ClientName}}{{else}}{{.ClientID}}{{end}}
+{{- with .DeviceID}}
+  Device ID:      {{.}}{{end}}
+{{- with .RequestIP}}
+  Device address: {{.}}{{with $.RequestLocation}} ({{.}}){{end}}{{end}}
+{{- with .ApproverIP}}
+  Approved from:  {{.}}{{end}}
+  Time:           {{.AuthorizedAt.UTC.Format "2006-01-02 15:04 MST"}}
+
+If this was you, no action is needed.
+
+If you did not approve this device, someone may have tricked you into
+entering their code. Remove its access now{{with .RevokeURL}}:
+
+  {{.}}{{else}} and contact your administrator.{{end}}
+`))
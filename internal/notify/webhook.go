@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+)
+
+// Webhook notification request headers
+const (
+	HeaderTimestamp = "X-Notification-Timestamp"
+
+	// HeaderSignature carries an HMAC-SHA256 over the timestamp and body in
+	// the format of events.Sign
+	HeaderSignature = "X-Notification-Signature"
+)
+
+// WebhookConfig configures a webhook notifier
+type WebhookConfig struct {
+	URL    string       // Endpoint receiving notification POSTs, such as a messaging gateway
+	Secret []byte       // HMAC-SHA256 signing key, unsigned when empty
+	Client *http.Client // http.DefaultClient if nil
+}
+
+// WebhookNotifier posts notifications as signed JSON to a service that
+// reaches users its own way, such as by push message or chat. Notifications
+// are sent without a recipient address too, since the receiver may look the
+// user up by subject.
+type WebhookNotifier struct {
+	cfg WebhookConfig
+}
+
+// NewWebhookNotifier creates a webhook notifier
+func NewWebhookNotifier(cfg WebhookConfig) (*WebhookNotifier, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("notification webhook URL is required")
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &WebhookNotifier{cfg: cfg}, nil
+}
+
+// Notify implements Notifier
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("encoding notification: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating notification request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if len(w.cfg.Secret) > 0 {
+		req.Header.Set(HeaderSignature, events.Sign(w.cfg.Secret, timestamp, body))
+	}
+
+	resp, err := w.cfg.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending notification: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification receiver returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package oauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ClientAssertionType identifies a JWT client assertion per RFC 7523 section 2.2
+const ClientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// DefaultAssertionLifetime is how long generated client assertions are valid.
+// Assertions are created per request, so a short lifetime limits replay.
+const DefaultAssertionLifetime = time.Minute
+
+// ParsePrivateKey decodes a PEM encoded RSA or EC private key in PKCS #8,
+// PKCS #1 or SEC 1 form for signing client assertions
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, nil
+	case *ecdsa.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+}
+
+// AssertionConfig configures client assertion generation
+type AssertionConfig struct {
+	ClientID string        // Issuer and subject of the assertions
+	Audience string        // Token endpoint of the authorization server
+	Key      crypto.Signer // RSA or EC private key registered with the authorization server
+	KeyID    string        // Optional kid header selecting the registered key
+	Lifetime time.Duration // DefaultAssertionLifetime if zero
+}
+
+// AssertionSigner creates client assertions for private_key_jwt client
+// authentication per RFC 7523 section 2.2, in place of a client secret
+type AssertionSigner struct {
+	cfg AssertionConfig
+	alg string
+	now func() time.Time
+}
+
+// NewAssertionSigner creates a signer, choosing RS256 for RSA keys and the
+// ECDSA algorithm matching the curve for EC keys
+func NewAssertionSigner(cfg AssertionConfig) (*AssertionSigner, error) {
+	if cfg.ClientID == "" {
+		return nil, errors.New("client ID is required")
+	}
+	if cfg.Audience == "" {
+		return nil, errors.New("audience is required")
+	}
+	if cfg.Lifetime <= 0 {
+		cfg.Lifetime = DefaultAssertionLifetime
+	}
+
+	alg, err := signingAlgorithm(cfg.Key)
+	if err != nil {
+		return nil, err
+	}
+	return &AssertionSigner{cfg: cfg, alg: alg, now: time.Now}, nil
+}
+
+// signingAlgorithm returns the JWS algorithm used with the key per RFC 7518 section 3.1
+func signingAlgorithm(key crypto.Signer) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return "", errors.New("RSA keys must be at least 2048 bits")
+		}
+		return "RS256", nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return "ES256", nil
+		case elliptic.P384():
+			return "ES384", nil
+		case elliptic.P521():
+			return "ES512", nil
+		}
+		return "", fmt.Errorf("unsupported curve %s", k.Curve.Params().Name)
+	case nil:
+		return "", errors.New("signing key is required")
+	default:
+		return "", fmt.Errorf("unsupported signing key type %T", key)
+	}
+}
+
+// assertionClaims are the claims required by RFC 7523 section 3
+type assertionClaims struct {
+	Issuer    string `json:"iss"`
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud"`
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Sign creates a new single-use client assertion
+func (s *AssertionSigner) Sign() (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("generating assertion ID: %w", err)
+	}
+
+	now := s.now()
+	header := map[string]string{"alg": s.alg, "typ": "JWT"}
+	if s.cfg.KeyID != "" {
+		header["kid"] = s.cfg.KeyID
+	}
+	claims := assertionClaims{
+		Issuer:    s.cfg.ClientID,
+		Subject:   s.cfg.ClientID,
+		Audience:  s.cfg.Audience,
+		ID:        hex.EncodeToString(jti),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(s.cfg.Lifetime).Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("encoding assertion header: %w", err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("encoding assertion claims: %w", err)
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	sig, err := s.signature(signingInput)
+	if err != nil {
+		return "", fmt.Errorf("signing assertion: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// signature signs the JWS signing input, encoding ECDSA signatures as the
+// fixed-size R || S pair required by RFC 7518 section 3.4
+func (s *AssertionSigner) signature(signingInput string) ([]byte, error) {
+	var hash crypto.Hash
+	var digest []byte
+	switch s.alg {
+	case "RS256", "ES256":
+		sum := sha256.Sum256([]byte(signingInput))
+		hash, digest = crypto.SHA256, sum[:]
+	case "ES384":
+		sum := sha512.Sum384([]byte(signingInput))
+		hash, digest = crypto.SHA384, sum[:]
+	case "ES512":
+		sum := sha512.Sum512([]byte(signingInput))
+		hash, digest = crypto.SHA512, sum[:]
+	}
+
+	switch k := s.cfg.Key.(type) {
+	case *rsa.PrivateKey:
+		return rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+	case *ecdsa.PrivateKey:
+		r, sv, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return nil, err
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*size)
+		r.FillBytes(sig[:size])
+		sv.FillBytes(sig[size:])
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unsupported signing key type %T", s.cfg.Key)
+}
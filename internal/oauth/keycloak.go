@@ -2,6 +2,7 @@ package oauth
 
 import (
 	"context"
+	"crypto"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,6 +17,7 @@ const (
 	tokenPath       = "/protocol/openid-connect/token"
 	tokenInfoPath   = "/protocol/openid-connect/token/introspect"
 	revocationPath  = "/protocol/openid-connect/revoke"
+	jwksPath        = "/protocol/openid-connect/certs"
 	healthCheckPath = "/.well-known/openid-configuration"
 
 	// HTTP request timeouts
@@ -26,17 +28,29 @@ const (
 type KeycloakProvider struct {
 	client        *http.Client
 	clientID      string
-	clientSecret  string
+	clientSecret  func() string
 	tokenURL      string
 	tokenInfoURL  string
 	revocationURL string
+	jwksURL       string
 	healthURL     string
+	issuer        string
+	assertions    *AssertionSigner // Replaces the client secret when set
 }
 
 // KeycloakConfig extends Config with Keycloak-specific settings
 type KeycloakConfig struct {
 	Config
 	Realm string
+
+	// AssertionKey switches client authentication to private_key_jwt per
+	// RFC 7523, signing assertions instead of sending ClientSecret
+	AssertionKey   crypto.Signer
+	AssertionKeyID string
+
+	TokenURL     string        // Overrides the realm's token endpoint, optional
+	HTTPClient   *http.Client  // Sends requests to Keycloak, a plain client with a timeout if nil
+	SecretSource func() string // Returns the current ClientSecret when it can be rotated, optional
 }
 
 // NewKeycloakProvider creates a new Keycloak provider
@@ -62,26 +76,80 @@ func NewKeycloakProvider(cfg KeycloakConfig) (*KeycloakProvider, error) {
 	realmURL := fmt.Sprintf("%s/realms/%s", baseURL, cfg.Realm)
 
 	// Create provider with configured client
-	return &KeycloakProvider{
-		client:        &http.Client{Timeout: defaultTimeout},
+	p := &KeycloakProvider{
+		client:        cfg.HTTPClient,
 		clientID:      cfg.ClientID,
-		clientSecret:  cfg.ClientSecret,
-		tokenURL:      realmURL + tokenPath,
+		clientSecret:  cfg.SecretSource,
+		tokenURL:      cfg.TokenURL,
 		tokenInfoURL:  realmURL + tokenInfoPath,
 		revocationURL: realmURL + revocationPath,
+		jwksURL:       realmURL + jwksPath,
 		healthURL:     realmURL + healthCheckPath,
-	}, nil
+		issuer:        realmURL,
+	}
+	if p.client == nil {
+		p.client = &http.Client{Timeout: defaultTimeout}
+	}
+	if p.clientSecret == nil {
+		secret := cfg.ClientSecret
+		p.clientSecret = func() string { return secret }
+	}
+	if p.tokenURL == "" {
+		p.tokenURL = realmURL + tokenPath
+	}
+	if cfg.AssertionKey != nil {
+		signer, err := NewAssertionSigner(AssertionConfig{
+			ClientID: cfg.ClientID,
+			Audience: p.tokenURL,
+			Key:      cfg.AssertionKey,
+			KeyID:    cfg.AssertionKeyID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("configuring client assertions: %w", err)
+		}
+		p.assertions = signer
+	}
+	return p, nil
+}
+
+// authenticate adds the client's credentials to a token, introspection or
+// revocation request per RFC 6749 section 2.3.1 or RFC 7523 section 2.2
+func (p *KeycloakProvider) authenticate(data url.Values) error {
+	data.Set("client_id", p.clientID)
+	if p.assertions == nil {
+		data.Set("client_secret", p.clientSecret())
+		return nil
+	}
+
+	assertion, err := p.assertions.Sign()
+	if err != nil {
+		return err
+	}
+	data.Set("client_assertion_type", ClientAssertionType)
+	data.Set("client_assertion", assertion)
+	return nil
+}
+
+// JWKSURL returns the realm's signing key endpoint for local token validation
+func (p *KeycloakProvider) JWKSURL() string {
+	return p.jwksURL
+}
+
+// Issuer returns the realm URL that Keycloak uses as the iss claim of its tokens
+func (p *KeycloakProvider) Issuer() string {
+	return p.issuer
 }
 
 // ExchangeCode exchanges an authorization code for tokens
 func (p *KeycloakProvider) ExchangeCode(ctx context.Context, code, redirectURI string) (*Token, error) {
 	// Prepare token request
 	data := url.Values{
-		"grant_type":    {"authorization_code"},
-		"code":          {code},
-		"redirect_uri":  {redirectURI},
-		"client_id":     {p.clientID},
-		"client_secret": {p.clientSecret},
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectURI},
+	}
+	if err := p.authenticate(data); err != nil {
+		return nil, fmt.Errorf("authenticating token request: %w", err)
 	}
 
 	// Make request
@@ -106,19 +174,7 @@ func (p *KeycloakProvider) ExchangeCode(ctx context.Context, code, redirectURI s
 
 	// Check for error responses
 	if resp.StatusCode != http.StatusOK {
-		var errResp struct {
-			Error            string `json:"error"`
-			ErrorDescription string `json:"error_description"`
-		}
-		if err := json.Unmarshal(body, &errResp); err != nil {
-			return nil, fmt.Errorf("invalid error response: %w", err)
-		}
-		switch errResp.Error {
-		case "invalid_grant":
-			return nil, ErrInvalidGrant
-		default:
-			return nil, fmt.Errorf("token request failed: %s: %s", errResp.Error, errResp.ErrorDescription)
-		}
+		return nil, tokenError("token", resp, body)
 	}
 
 	// Parse successful response
@@ -148,10 +204,9 @@ func (p *KeycloakProvider) ExchangeCode(ctx context.Context, code, redirectURI s
 // ValidateToken validates an access token and returns its info
 func (p *KeycloakProvider) ValidateToken(ctx context.Context, token string) (*TokenInfo, error) {
 	// Prepare introspection request
-	data := url.Values{
-		"token":         {token},
-		"client_id":     {p.clientID},
-		"client_secret": {p.clientSecret},
+	data := url.Values{"token": {token}}
+	if err := p.authenticate(data); err != nil {
+		return nil, fmt.Errorf("authenticating token info request: %w", err)
 	}
 
 	// Make request
@@ -168,11 +223,38 @@ func (p *KeycloakProvider) ValidateToken(ctx context.Context, token string) (*To
 	}
 	defer resp.Body.Close()
 
-	// Read and parse response
-	var info TokenInfo
-	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+	// Check response status
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("token info request failed: %s: %w", resp.Status, ErrProviderUnavailable)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token info request failed: %s", resp.Status)
+	}
+
+	// Read and parse response, whose exp and iat are seconds since the epoch per RFC 7662 section 2.2
+	var claims struct {
+		Active    bool   `json:"active"`
+		Subject   string `json:"sub"`
+		ClientID  string `json:"client_id"`
+		Username  string `json:"username"`
+		Scope     string `json:"scope"`
+		ExpiresAt int64  `json:"exp"`
+		IssuedAt  int64  `json:"iat"`
+		Issuer    string `json:"iss"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&claims); err != nil {
 		return nil, fmt.Errorf("parsing token info response: %w", err)
 	}
+	info := TokenInfo{
+		Active:    claims.Active,
+		Subject:   claims.Subject,
+		ClientID:  claims.ClientID,
+		Username:  claims.Username,
+		Scope:     claims.Scope,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+		IssuedAt:  time.Unix(claims.IssuedAt, 0),
+		Issuer:    claims.Issuer,
+	}
 
 	// Check token state
 	if !info.Active {
@@ -191,8 +273,9 @@ func (p *KeycloakProvider) RefreshToken(ctx context.Context, refreshToken string
 	data := url.Values{
 		"grant_type":    {"refresh_token"},
 		"refresh_token": {refreshToken},
-		"client_id":     {p.clientID},
-		"client_secret": {p.clientSecret},
+	}
+	if err := p.authenticate(data); err != nil {
+		return nil, fmt.Errorf("authenticating refresh request: %w", err)
 	}
 
 	// Make request
@@ -217,19 +300,7 @@ func (p *KeycloakProvider) RefreshToken(ctx context.Context, refreshToken string
 
 	// Check for error responses
 	if resp.StatusCode != http.StatusOK {
-		var errResp struct {
-			Error            string `json:"error"`
-			ErrorDescription string `json:"error_description"`
-		}
-		if err := json.Unmarshal(body, &errResp); err != nil {
-			return nil, fmt.Errorf("invalid error response: %w", err)
-		}
-		switch errResp.Error {
-		case "invalid_grant":
-			return nil, ErrInvalidGrant
-		default:
-			return nil, fmt.Errorf("refresh request failed: %s: %s", errResp.Error, errResp.ErrorDescription)
-		}
+		return nil, tokenError("refresh", resp, body)
 	}
 
 	// Parse successful response
@@ -256,13 +327,97 @@ func (p *KeycloakProvider) RefreshToken(ctx context.Context, refreshToken string
 	return token, nil
 }
 
+// ExchangeToken exchanges a token for one targeted at another audience per
+// RFC 8693. Keycloak must allow the proxy's client to exchange tokens for the
+// audience, which its token exchange feature controls per target client.
+func (p *KeycloakProvider) ExchangeToken(ctx context.Context, exchange ExchangeRequest) (*Token, error) {
+	// Prepare exchange request
+	if exchange.SubjectToken == "" {
+		return nil, fmt.Errorf("subject token is required")
+	}
+	if exchange.SubjectTokenType == "" {
+		exchange.SubjectTokenType = TokenTypeAccessToken
+	}
+	data := url.Values{
+		"grant_type":         {GrantTypeTokenExchange},
+		"subject_token":      {exchange.SubjectToken},
+		"subject_token_type": {exchange.SubjectTokenType},
+	}
+	for name, value := range map[string]string{
+		"audience":             exchange.Audience,
+		"scope":                exchange.Scope,
+		"requested_token_type": exchange.RequestedTokenType,
+	} {
+		if value != "" {
+			data.Set(name, value)
+		}
+	}
+	if err := p.authenticate(data); err != nil {
+		return nil, fmt.Errorf("authenticating token exchange request: %w", err)
+	}
+
+	// Make request
+	req, err := http.NewRequestWithContext(ctx, "POST", p.tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("creating token exchange request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	// Send request and handle response
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending token exchange request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading token exchange response: %w", err)
+	}
+
+	// Check for error responses
+	if resp.StatusCode != http.StatusOK {
+		return nil, tokenError("token exchange", resp, body)
+	}
+
+	// Parse successful response
+	var tokenResp struct {
+		AccessToken     string `json:"access_token"`
+		TokenType       string `json:"token_type"`
+		RefreshToken    string `json:"refresh_token"`
+		ExpiresIn       int    `json:"expires_in"`
+		Scope           string `json:"scope"`
+		IssuedTokenType string `json:"issued_token_type"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("parsing token exchange response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("token exchange response has no access token")
+	}
+
+	// Exchanged tokens may leave their lifetime unstated
+	token := &Token{
+		AccessToken:     tokenResp.AccessToken,
+		TokenType:       tokenResp.TokenType,
+		RefreshToken:    tokenResp.RefreshToken,
+		Scope:           tokenResp.Scope,
+		IssuedTokenType: tokenResp.IssuedTokenType,
+	}
+	if tokenResp.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
+
 // RevokeToken revokes an access or refresh token
 func (p *KeycloakProvider) RevokeToken(ctx context.Context, token string) error {
 	// Prepare revocation request
-	data := url.Values{
-		"token":         {token},
-		"client_id":     {p.clientID},
-		"client_secret": {p.clientSecret},
+	data := url.Values{"token": {token}}
+	if err := p.authenticate(data); err != nil {
+		return fmt.Errorf("authenticating revocation request: %w", err)
 	}
 
 	// Make request
@@ -280,6 +435,9 @@ func (p *KeycloakProvider) RevokeToken(ctx context.Context, token string) error
 	defer resp.Body.Close()
 
 	// Check response status
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("revocation request failed: %s: %w", resp.Status, ErrProviderUnavailable)
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("revocation request failed: %s: %s", resp.Status, body)
@@ -288,6 +446,28 @@ func (p *KeycloakProvider) RevokeToken(ctx context.Context, token string) error
 	return nil
 }
 
+// tokenError converts an error response from the token endpoint. Server
+// errors, which proxies in front of Keycloak may answer with HTML, report the
+// provider as unavailable.
+func tokenError(op string, resp *http.Response, body []byte) error {
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%s request failed: %s: %w", op, resp.Status, ErrProviderUnavailable)
+	}
+
+	var errResp ProviderError
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return fmt.Errorf("invalid error response: %w", err)
+	}
+	switch errResp.Code {
+	case "invalid_grant":
+		return ErrInvalidGrant
+	case "":
+		return fmt.Errorf("%s request failed: %s", op, resp.Status)
+	default:
+		return fmt.Errorf("%s request failed: %w", op, &errResp)
+	}
+}
+
 // CheckHealth verifies the provider is accessible
 func (p *KeycloakProvider) CheckHealth(ctx context.Context) error {
 	// Create request with context
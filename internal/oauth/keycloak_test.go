@@ -0,0 +1,218 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/oauth/keycloaktest"
+)
+
+// newTestProvider creates a provider for a mock Keycloak realm
+func newTestProvider(t *testing.T) (*KeycloakProvider, *keycloaktest.Server) {
+	t.Helper()
+	kc := keycloaktest.NewServer("test")
+	t.Cleanup(kc.Close)
+
+	p, err := NewKeycloakProvider(KeycloakConfig{
+		Config: Config{ClientID: "device-proxy", ClientSecret: "secret", BaseURL: kc.URL},
+		Realm:  kc.Realm,
+	})
+	if err != nil {
+		t.Fatalf("NewKeycloakProvider failed: %v", err)
+	}
+	return p, kc
+}
+
+// tokenContract lists how the token endpoint's recorded responses surface
+var tokenContract = []struct {
+	name     string
+	response keycloaktest.Response
+	wantErr  error // Matched with errors.Is, nil expects success
+	wantAny  bool  // Expect some error not matching a sentinel
+}{
+	{name: "success", response: keycloaktest.TokenSuccess},
+	{name: "invalid grant", response: keycloaktest.InvalidGrant, wantErr: ErrInvalidGrant},
+	{name: "invalid client", response: keycloaktest.InvalidClient, wantAny: true},
+	{name: "server error", response: keycloaktest.ServerError, wantErr: ErrProviderUnavailable},
+	{name: "proxy error page", response: keycloaktest.BadGateway, wantErr: ErrProviderUnavailable},
+	{name: "malformed JSON", response: keycloaktest.MalformedJSON, wantAny: true},
+}
+
+func TestKeycloakExchangeCode(t *testing.T) {
+	for _, tt := range tokenContract {
+		t.Run(tt.name, func(t *testing.T) {
+			p, kc := newTestProvider(t)
+			kc.Respond(keycloaktest.Token, tt.response)
+
+			token, err := p.ExchangeCode(context.Background(), "code", "https://proxy/device/complete")
+			checkContract(t, err, tt.wantErr, tt.wantAny)
+			if err == nil && (token.AccessToken == "" || token.RefreshToken == "" || token.Scope != "openid profile email") {
+				t.Errorf("token = %+v, want the recorded tokens and scope", token)
+			}
+
+			form := kc.Requests(keycloaktest.Token)[0]
+			if form.Get("grant_type") != "authorization_code" || form.Get("code") != "code" || form.Get("client_secret") != "secret" {
+				t.Errorf("token request = %v", form)
+			}
+		})
+	}
+}
+
+func TestKeycloakRefreshToken(t *testing.T) {
+	for _, tt := range tokenContract {
+		t.Run(tt.name, func(t *testing.T) {
+			p, kc := newTestProvider(t)
+			kc.Respond(keycloaktest.Token, tt.response)
+
+			token, err := p.RefreshToken(context.Background(), "refresh")
+			checkContract(t, err, tt.wantErr, tt.wantAny)
+			if err == nil && token.AccessToken == "" {
+				t.Errorf("token = %+v, want the recorded tokens", token)
+			}
+
+			form := kc.Requests(keycloaktest.Token)[0]
+			if form.Get("grant_type") != "refresh_token" || form.Get("refresh_token") != "refresh" {
+				t.Errorf("refresh request = %v", form)
+			}
+		})
+	}
+}
+
+func TestKeycloakExchangeToken(t *testing.T) {
+	// Exchanges succeed with their own response and may be refused outright
+	contract := append(tokenContract[:0:0], tokenContract...)
+	contract[0].response = keycloaktest.ExchangeSuccess
+	refused := contract[2]
+	refused.name, refused.response = "exchange not allowed", keycloaktest.ExchangeForbidden
+	contract = append(contract, refused)
+
+	for _, tt := range contract {
+		t.Run(tt.name, func(t *testing.T) {
+			p, kc := newTestProvider(t)
+			kc.Respond(keycloaktest.Token, tt.response)
+
+			token, err := p.ExchangeToken(context.Background(), ExchangeRequest{SubjectToken: "access", Audience: "api"})
+			checkContract(t, err, tt.wantErr, tt.wantAny)
+			if err == nil && (token.AccessToken == "" || token.IssuedTokenType != TokenTypeAccessToken) {
+				t.Errorf("token = %+v, want the exchanged access token", token)
+			}
+
+			form := kc.Requests(keycloaktest.Token)[0]
+			if form.Get("grant_type") != GrantTypeTokenExchange || form.Get("subject_token") != "access" ||
+				form.Get("subject_token_type") != TokenTypeAccessToken || form.Get("audience") != "api" || form.Has("scope") {
+				t.Errorf("token exchange request = %v", form)
+			}
+		})
+	}
+}
+
+func TestKeycloakRotatedSecret(t *testing.T) {
+	kc := keycloaktest.NewServer("test")
+	defer kc.Close()
+	kc.Respond(keycloaktest.Token, keycloaktest.ExchangeSuccess)
+
+	// Requests go to the configured token endpoint with the current secret
+	secret := "first"
+	p, err := NewKeycloakProvider(KeycloakConfig{
+		Config:       Config{ClientID: "device-proxy", BaseURL: "https://keycloak.invalid"},
+		Realm:        kc.Realm,
+		TokenURL:     kc.EndpointURL(keycloaktest.Token),
+		SecretSource: func() string { return secret },
+	})
+	if err != nil {
+		t.Fatalf("NewKeycloakProvider failed: %v", err)
+	}
+
+	for _, want := range []string{"first", "second"} {
+		secret = want
+		if _, err := p.ExchangeToken(context.Background(), ExchangeRequest{SubjectToken: "access", Audience: "api"}); err != nil {
+			t.Fatalf("ExchangeToken() error = %v", err)
+		}
+		requests := kc.Requests(keycloaktest.Token)
+		if got := requests[len(requests)-1].Get("client_secret"); got != want {
+			t.Errorf("client_secret = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestKeycloakValidateToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		response keycloaktest.Response
+		wantErr  error
+		wantAny  bool
+	}{
+		{name: "active", response: keycloaktest.IntrospectActive},
+		{name: "inactive", response: keycloaktest.IntrospectInactive, wantErr: ErrInvalidToken},
+		{name: "invalid client", response: keycloaktest.InvalidClient, wantAny: true},
+		{name: "server error", response: keycloaktest.ServerError, wantErr: ErrProviderUnavailable},
+		{name: "malformed JSON", response: keycloaktest.MalformedJSON, wantAny: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, kc := newTestProvider(t)
+			kc.Respond(keycloaktest.Introspect, tt.response)
+
+			info, err := p.ValidateToken(context.Background(), "access")
+			checkContract(t, err, tt.wantErr, tt.wantAny)
+			if err == nil && (info.Username != "alice" || info.ClientID != "device-proxy" || info.ExpiresAt.Year() != 2100) {
+				t.Errorf("token info = %+v, want the recorded claims", info)
+			}
+		})
+	}
+}
+
+func TestKeycloakRevokeToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		response keycloaktest.Response
+		wantErr  error
+		wantAny  bool
+	}{
+		{name: "revoked", response: keycloaktest.RevokeSuccess},
+		{name: "unsupported token type", response: keycloaktest.UnsupportedTokenType, wantAny: true},
+		{name: "server error", response: keycloaktest.ServerError, wantErr: ErrProviderUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, kc := newTestProvider(t)
+			kc.Respond(keycloaktest.Revoke, tt.response)
+
+			checkContract(t, p.RevokeToken(context.Background(), "refresh"), tt.wantErr, tt.wantAny)
+			if form := kc.Requests(keycloaktest.Revoke)[0]; form.Get("token") != "refresh" {
+				t.Errorf("revocation request = %v", form)
+			}
+		})
+	}
+}
+
+func TestKeycloakCheckHealth(t *testing.T) {
+	p, kc := newTestProvider(t)
+	if err := p.CheckHealth(context.Background()); err != nil {
+		t.Errorf("CheckHealth = %v, want nil", err)
+	}
+	kc.Close()
+	if err := p.CheckHealth(context.Background()); err == nil {
+		t.Error("CheckHealth = nil with Keycloak down")
+	}
+}
+
+// checkContract compares an error with the expected outcome
+func checkContract(t *testing.T, err, want error, wantAny bool) {
+	t.Helper()
+	switch {
+	case want != nil:
+		if !errors.Is(err, want) {
+			t.Errorf("error = %v, want %v", err, want)
+		}
+	case wantAny:
+		if err == nil || errors.Is(err, ErrInvalidGrant) || errors.Is(err, ErrProviderUnavailable) {
+			t.Errorf("error = %v, want an error other than invalid_grant or unavailability", err)
+		}
+	case err != nil:
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package oauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+)
+
+// ScopeOfflineAccess requests a refresh token that outlives the user's
+// session per OpenID Connect Core 1.0 section 11
+const ScopeOfflineAccess = "offline_access"
+
+// keycloakOfflineType is the typ claim of Keycloak offline refresh tokens
+const keycloakOfflineType = "Offline"
+
+// IsOfflineToken reports whether a refresh token is an offline token. Keycloak
+// marks its offline tokens with the typ claim "Offline"; opaque refresh tokens
+// are offline when the granted scope includes offline_access. The token was
+// received directly from the token endpoint, so its claims are read without
+// verifying the signature.
+func IsOfflineToken(refreshToken, grantedScope string) bool {
+	if refreshToken == "" {
+		return false
+	}
+	if parts := strings.Split(refreshToken, "."); len(parts) == 3 {
+		if payload, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil {
+			var claims struct {
+				Type string `json:"typ"`
+			}
+			if json.Unmarshal(payload, &claims) == nil && claims.Type != "" {
+				return claims.Type == keycloakOfflineType
+			}
+		}
+	}
+	return HasScope(grantedScope, ScopeOfflineAccess)
+}
+
+// HasScope reports whether a space-delimited scope string includes scope
+func HasScope(scopes, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -15,13 +15,49 @@ var (
 	ErrProviderUnavailable = errors.New("oauth provider unavailable")
 )
 
+// ProviderError is a structured error response from the provider's token
+// endpoint per RFC 6749 section 5.2
+type ProviderError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// Error implements the error interface
+func (e *ProviderError) Error() string {
+	if e.Description != "" {
+		return e.Code + ": " + e.Description
+	}
+	return e.Code
+}
+
 // Token represents an OAuth2 access token with refresh capabilities
 type Token struct {
-	AccessToken  string    `json:"access_token"`
-	TokenType    string    `json:"token_type"`
-	RefreshToken string    `json:"refresh_token,omitempty"`
-	Scope        string    `json:"scope,omitempty"`
-	ExpiresAt    time.Time `json:"expires_at"`
+	AccessToken     string    `json:"access_token"`
+	TokenType       string    `json:"token_type"`
+	RefreshToken    string    `json:"refresh_token,omitempty"`
+	Scope           string    `json:"scope,omitempty"`
+	IssuedTokenType string    `json:"issued_token_type,omitempty"` // Set by token exchange per RFC 8693 section 2.2.1
+	ExpiresAt       time.Time `json:"expires_at"`
+}
+
+// Token exchange per RFC 8693
+const (
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+
+	// Token type identifiers, section 3
+	TokenTypeAccessToken  = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeRefreshToken = "urn:ietf:params:oauth:token-type:refresh_token"
+)
+
+// ExchangeRequest asks for a token in exchange for another per RFC 8693
+// section 2.1. Empty members are left out of the request, letting the
+// provider choose.
+type ExchangeRequest struct {
+	SubjectToken       string
+	SubjectTokenType   string // Defaults to TokenTypeAccessToken
+	Audience           string // Client the new token is for
+	Scope              string
+	RequestedTokenType string
 }
 
 // TokenInfo contains additional information about a validated token
@@ -36,6 +72,17 @@ type TokenInfo struct {
 	Issuer    string    `json:"iss"`
 }
 
+// IDTokenClaims are the validated claims of an OpenID Connect ID token per
+// OpenID Connect Core 1.0 section 2
+type IDTokenClaims struct {
+	Subject   string    `json:"sub"`
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"` // Display name, or the preferred username without one
+	Issuer    string    `json:"iss"`
+	Nonce     string    `json:"nonce,omitempty"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
 // Provider defines the interface for OAuth2 providers supporting device flow
 type Provider interface {
 	// ExchangeCode exchanges an authorization code for tokens
@@ -47,6 +94,9 @@ type Provider interface {
 	// RefreshToken refreshes an access token using a refresh token
 	RefreshToken(ctx context.Context, refreshToken string) (*Token, error)
 
+	// ExchangeToken exchanges a token for one targeted at another audience
+	ExchangeToken(ctx context.Context, req ExchangeRequest) (*Token, error)
+
 	// RevokeToken revokes an access or refresh token
 	RevokeToken(ctx context.Context, token string) error
 
@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxOPAResponse bounds how much of a decision response is read
+const maxOPAResponse = 1 << 20
+
+// OPAConfig configures an OPA policy
+type OPAConfig struct {
+	// URL is the Data API path of the decision document, for example
+	// http://localhost:8181/v1/data/deviceproxy/decision
+	URL        string
+	HTTPClient *http.Client // http.DefaultClient if nil
+}
+
+// OPA asks an Open Policy Agent server for decisions through its Data API,
+// posting the Input as the input document. The decision may be a boolean,
+// or an object with an allow boolean and an optional reason string. An
+// undefined decision denies.
+type OPA struct {
+	url    string
+	client *http.Client
+}
+
+// NewOPA creates a policy backed by an OPA server
+func NewOPA(cfg OPAConfig) *OPA {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	return &OPA{url: cfg.URL, client: cfg.HTTPClient}
+}
+
+// Decide implements Policy
+func (o *OPA) Decide(ctx context.Context, input Input) (Decision, error) {
+	body, err := json.Marshal(struct {
+		Input Input `json:"input"`
+	}{input})
+	if err != nil {
+		return Decision{}, fmt.Errorf("encoding policy input: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return Decision{}, fmt.Errorf("creating policy request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return Decision{}, fmt.Errorf("querying policy: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Decision{}, fmt.Errorf("querying policy: %s", resp.Status)
+	}
+
+	var result struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOPAResponse)).Decode(&result); err != nil {
+		return Decision{}, fmt.Errorf("parsing policy response: %w", err)
+	}
+	return parseOPAResult(result.Result)
+}
+
+// parseOPAResult reads a boolean or object decision document
+func parseOPAResult(raw json.RawMessage) (Decision, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return Decision{Reason: "policy decision is undefined"}, nil
+	}
+
+	var allow bool
+	if err := json.Unmarshal(raw, &allow); err == nil {
+		return Decision{Allow: allow}, nil
+	}
+
+	var decision struct {
+		Allow  *bool  `json:"allow"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(raw, &decision); err != nil {
+		return Decision{}, fmt.Errorf("policy decision is neither a boolean nor an object: %w", err)
+	}
+	if decision.Allow == nil {
+		return Decision{Reason: "policy decision has no allow member"}, nil
+	}
+	return Decision{Allow: *decision.Allow, Reason: decision.Reason}, nil
+}
@@ -0,0 +1,90 @@
+// Package policy lets operators decide whether a device authorization may
+// proceed, for rules such as restricting a client to users in a group or to
+// business hours. Policies are consulted before the user is sent to the
+// identity provider and again before the token is stored for the device.
+package policy
+
+import (
+	"context"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/metrics"
+)
+
+// Stage names the point in the flow a decision is made at
+type Stage string
+
+// Decision points
+const (
+	// StageRedirect is before the user is sent to the identity provider. The
+	// user is not yet known.
+	StageRedirect Stage = "redirect"
+
+	// StageToken is after the user signed in, before the token is stored for
+	// the polling device. Subject and Claims describe the user.
+	StageToken Stage = "token"
+)
+
+// Input describes the authorization being decided
+type Input struct {
+	Stage    Stage     `json:"stage"`
+	Time     time.Time `json:"time"`
+	ClientID string    `json:"client_id"`
+	Scope    string    `json:"scope,omitempty"`
+	DeviceID string    `json:"device_id,omitempty"` // Client-asserted device identifier
+	RemoteIP string    `json:"remote_ip,omitempty"` // Browser of the verifying user
+
+	// Known at StageToken only
+	Subject string         `json:"subject,omitempty"`
+	Claims  map[string]any `json:"claims,omitempty"` // Access token claims, such as groups
+}
+
+// Decision is a policy's answer
+type Decision struct {
+	Allow  bool   `json:"allow"`
+	Reason string `json:"reason,omitempty"` // Logged and audited, never shown to users
+}
+
+// Policy decides whether an authorization may proceed. An error means no
+// decision could be made, and the authorization is refused for now without
+// ending the flow.
+type Policy interface {
+	Decide(ctx context.Context, input Input) (Decision, error)
+}
+
+// Func adapts a function to the Policy interface
+type Func func(ctx context.Context, input Input) (Decision, error)
+
+// Decide implements Policy
+func (fn Func) Decide(ctx context.Context, input Input) (Decision, error) {
+	return fn(ctx, input)
+}
+
+// Evaluate asks p for a decision, counting the outcome. A nil policy allows
+// everything.
+func Evaluate(ctx context.Context, p Policy, input Input) (Decision, error) {
+	if p == nil {
+		return Decision{Allow: true}, nil
+	}
+	if input.Time.IsZero() {
+		input.Time = time.Now()
+	}
+
+	decision, err := p.Decide(ctx, input)
+	switch {
+	case err != nil:
+		decisions.Inc(string(input.Stage), "error")
+	case decision.Allow:
+		decisions.Inc(string(input.Stage), "allow")
+	default:
+		decisions.Inc(string(input.Stage), "deny")
+	}
+	return decision, err
+}
+
+// decisions counts policy decisions by stage and outcome
+var decisions = metrics.Default.NewCounter(
+	"device_proxy_policy_decisions_total",
+	"Authorization policy decisions by stage and outcome.",
+	"stage", "result",
+)
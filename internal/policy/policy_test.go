@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	ctx := context.Background()
+
+	decision, err := Evaluate(ctx, nil, Input{Stage: StageRedirect})
+	if err != nil || !decision.Allow {
+		t.Errorf("nil policy = %+v, %v; want allowed", decision, err)
+	}
+
+	var seen Input
+	deny := Func(func(ctx context.Context, input Input) (Decision, error) {
+		seen = input
+		return Decision{Reason: "outside business hours"}, nil
+	})
+	decision, err = Evaluate(ctx, deny, Input{Stage: StageToken, ClientID: "tv"})
+	if err != nil || decision.Allow || decision.Reason != "outside business hours" {
+		t.Errorf("Evaluate() = %+v, %v; want denied with reason", decision, err)
+	}
+	if seen.Time.IsZero() {
+		t.Error("Evaluate() did not set the decision time")
+	}
+
+	failing := Func(func(ctx context.Context, input Input) (Decision, error) {
+		return Decision{}, errors.New("unreachable")
+	})
+	if _, err := Evaluate(ctx, failing, Input{Stage: StageToken}); err == nil {
+		t.Error("Evaluate() expected policy error")
+	}
+}
+
+func TestOPA(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantAllow  bool
+		wantReason string
+		wantErr    bool
+	}{
+		{name: "boolean allow", status: http.StatusOK, body: `{"result": true}`, wantAllow: true},
+		{name: "boolean deny", status: http.StatusOK, body: `{"result": false}`},
+		{
+			name:       "object decision",
+			status:     http.StatusOK,
+			body:       `{"result": {"allow": false, "reason": "user not in group tv-admins"}}`,
+			wantReason: "user not in group tv-admins",
+		},
+		{name: "undefined decision", status: http.StatusOK, body: `{}`, wantReason: "policy decision is undefined"},
+		{name: "object without allow", status: http.StatusOK, body: `{"result": {"reason": "x"}}`, wantReason: "policy decision has no allow member"},
+		{name: "unexpected decision", status: http.StatusOK, body: `{"result": "yes"}`, wantErr: true},
+		{name: "server error", status: http.StatusInternalServerError, body: `{}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got struct {
+				Input Input `json:"input"`
+			}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+					t.Errorf("decoding request: %v", err)
+				}
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			opa := NewOPA(OPAConfig{URL: srv.URL + "/v1/data/deviceproxy/decision"})
+			decision, err := opa.Decide(context.Background(), Input{
+				Stage:    StageToken,
+				ClientID: "tv",
+				Claims:   map[string]any{"groups": []string{"tv-admins"}},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decide() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if decision.Allow != tt.wantAllow || decision.Reason != tt.wantReason {
+				t.Errorf("Decide() = %+v, want allow %v reason %q", decision, tt.wantAllow, tt.wantReason)
+			}
+			if got.Input.ClientID != "tv" || got.Input.Stage != StageToken || got.Input.Claims["groups"] == nil {
+				t.Errorf("policy received input %+v", got.Input)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+// Package pow issues and verifies hashcash-style proof of work challenges that
+// make requesting device codes in bulk costly for unauthenticated clients
+package pow
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Challenge defaults
+const (
+	// DefaultDifficulty requires about 260k hashes on average, well under a
+	// second on a device and costly across thousands of requests
+	DefaultDifficulty = 18
+
+	// DefaultTTL bounds how long a challenge may be solved and submitted
+	DefaultTTL = 2 * time.Minute
+
+	// MaxDifficulty keeps challenges solvable by constrained devices
+	MaxDifficulty = 32
+)
+
+var (
+	// ErrInvalidSolution indicates a malformed, tampered or incorrect solution,
+	// or one issued to a different client
+	ErrInvalidSolution = errors.New("invalid proof of work")
+
+	// ErrExpired indicates the challenge is no longer accepted
+	ErrExpired = errors.New("proof of work challenge expired")
+
+	// ErrReplayed indicates the challenge was already spent
+	ErrReplayed = errors.New("proof of work challenge already used")
+)
+
+// ReplayCache remembers spent challenges so that each solution is used once
+type ReplayCache interface {
+	// Claim records id until the given time, reporting false if it was
+	// already recorded
+	Claim(ctx context.Context, id string, until time.Time) (bool, error)
+}
+
+// Config configures challenge issuance. Zero values use the package defaults.
+type Config struct {
+	Secret     []byte        // Signs challenges so the proxy keeps no state until one is spent
+	Difficulty int           // Leading zero bits required of a solution's hash
+	TTL        time.Duration // How long a challenge is accepted
+	Replay     ReplayCache   // A MemoryReplayCache if nil
+}
+
+// Challenge is a puzzle a client solves by finding a nonce such that the
+// SHA-256 of "<Value>:<nonce>" starts with Difficulty zero bits
+type Challenge struct {
+	Value      string
+	Difficulty int
+	ExpiresIn  int // Seconds
+}
+
+// payload is the signed encoding of a challenge
+type payload struct {
+	Difficulty int    `json:"d"`
+	Expiry     int64  `json:"e"` // Unix seconds
+	Nonce      string `json:"n"` // Random, so every challenge is distinct
+}
+
+// Issuer issues and verifies challenges
+type Issuer struct {
+	mu       sync.RWMutex
+	key      []byte
+	previous []byte // Key before the last rotation, accepted until then-issued challenges expire
+
+	difficulty int
+	ttl        time.Duration
+	replay     ReplayCache
+	now        func() time.Time
+}
+
+// New creates a challenge issuer signing with a key derived from cfg.Secret
+func New(cfg Config) *Issuer {
+	if cfg.Difficulty <= 0 {
+		cfg.Difficulty = DefaultDifficulty
+	}
+	if cfg.Difficulty > MaxDifficulty {
+		cfg.Difficulty = MaxDifficulty
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultTTL
+	}
+	if cfg.Replay == nil {
+		cfg.Replay = NewMemoryReplayCache()
+	}
+
+	return &Issuer{
+		key:        deriveKey(cfg.Secret),
+		difficulty: cfg.Difficulty,
+		ttl:        cfg.TTL,
+		replay:     cfg.Replay,
+		now:        time.Now,
+	}
+}
+
+// deriveKey derives a dedicated signing key so that a shared secret never
+// produces signatures valid elsewhere
+func deriveKey(secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("oauth2-device-proxy proof of work"))
+	return mac.Sum(nil)
+}
+
+// Rotate replaces the signing secret. Challenges signed with the secret being
+// replaced remain valid until they expire or the secret is rotated again.
+func (i *Issuer) Rotate(secret []byte) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.previous, i.key = i.key, deriveKey(secret)
+}
+
+// keys returns the current and previous signing keys
+func (i *Issuer) keys() (current, previous []byte) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.key, i.previous
+}
+
+// Issue creates a challenge bound to a client
+func (i *Issuer) Issue(clientID string) (*Challenge, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return nil, fmt.Errorf("generating challenge: %w", err)
+	}
+	data, err := json.Marshal(payload{
+		Difficulty: i.difficulty,
+		Expiry:     i.now().Add(i.ttl).Unix(),
+		Nonce:      base64.RawURLEncoding.EncodeToString(random),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding challenge: %w", err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	key, _ := i.keys()
+	return &Challenge{
+		Value:      encoded + "." + sign(key, clientID, encoded),
+		Difficulty: i.difficulty,
+		ExpiresIn:  int(i.ttl.Seconds()),
+	}, nil
+}
+
+// Verify checks a solution to a challenge issued to the client and spends the
+// challenge, so that it cannot be used again
+func (i *Issuer) Verify(ctx context.Context, clientID, challenge, nonce string) error {
+	encoded, sig, ok := strings.Cut(challenge, ".")
+	if !ok || nonce == "" || !i.signed(sig, clientID, encoded) {
+		return ErrInvalidSolution
+	}
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalidSolution
+	}
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return ErrInvalidSolution
+	}
+
+	expiresAt := time.Unix(p.Expiry, 0)
+	if !i.now().Before(expiresAt) {
+		return ErrExpired
+	}
+	if LeadingZeroBits(challenge, nonce) < p.Difficulty {
+		return ErrInvalidSolution
+	}
+
+	claimed, err := i.replay.Claim(ctx, sig, expiresAt)
+	if err != nil {
+		return fmt.Errorf("spending challenge: %w", err)
+	}
+	if !claimed {
+		return ErrReplayed
+	}
+	return nil
+}
+
+// signed reports whether sig binds a challenge payload to the client under the
+// current or previous key
+func (i *Issuer) signed(sig, clientID, encoded string) bool {
+	current, previous := i.keys()
+	if hmac.Equal([]byte(sig), []byte(sign(current, clientID, encoded))) {
+		return true
+	}
+	return previous != nil && hmac.Equal([]byte(sig), []byte(sign(previous, clientID, encoded)))
+}
+
+// sign returns the encoded HMAC binding a challenge payload to a client
+func sign(key []byte, clientID, encoded string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(clientID))
+	mac.Write([]byte{0})
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// LeadingZeroBits returns the number of leading zero bits of the SHA-256 of
+// "<challenge>:<nonce>"
+func LeadingZeroBits(challenge, nonce string) int {
+	sum := sha256.Sum256([]byte(challenge + ":" + nonce))
+	n := 0
+	for _, b := range sum {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
+
+// Solve finds a nonce solving a challenge, as a reference for device
+// implementations. It gives up when ctx is done.
+func Solve(ctx context.Context, challenge string, difficulty int) (string, error) {
+	for counter := uint64(0); ; counter++ {
+		if counter%4096 == 0 {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+		}
+		nonce := strconv.FormatUint(counter, 36)
+		if LeadingZeroBits(challenge, nonce) >= difficulty {
+			return nonce, nil
+		}
+	}
+}
+
+// MemoryReplayCache remembers spent challenges in process memory. Each proxy
+// instance sees only its own requests, so deployments with several replicas
+// should use RedisReplayCache.
+type MemoryReplayCache struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+	now func() time.Time
+}
+
+// NewMemoryReplayCache creates an empty in-memory replay cache
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{ids: make(map[string]time.Time), now: time.Now}
+}
+
+// Claim implements ReplayCache
+func (m *MemoryReplayCache) Claim(ctx context.Context, id string, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if expiresAt, ok := m.ids[id]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+	for seen, expiresAt := range m.ids {
+		if !now.Before(expiresAt) {
+			delete(m.ids, seen)
+		}
+	}
+	m.ids[id] = until
+	return true, nil
+}
+
+// replayPrefix namespaces spent challenges in Redis, mirrored by keyspace.Patterns
+const replayPrefix = "pow:"
+
+// RedisReplayCache remembers spent challenges in Redis, shared by all proxy instances
+type RedisReplayCache struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisReplayCache creates a Redis-backed replay cache. The key prefix
+// namespaces its keys as REDIS_KEY_PREFIX does for the other stores.
+func NewRedisReplayCache(client *redis.Client, keyPrefix string) *RedisReplayCache {
+	return &RedisReplayCache{client: client, prefix: keyPrefix}
+}
+
+// Claim implements ReplayCache
+func (r *RedisReplayCache) Claim(ctx context.Context, id string, until time.Time) (bool, error) {
+	ttl := time.Until(until)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	sum := sha256.Sum256([]byte(id))
+	return r.client.SetNX(ctx, r.prefix+replayPrefix+hex.EncodeToString(sum[:]), 1, ttl).Result()
+}
@@ -0,0 +1,110 @@
+package pow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIssuer(t *testing.T) {
+	ctx := context.Background()
+	issuer := New(Config{Secret: []byte("secret"), Difficulty: 8, TTL: time.Minute})
+
+	challenge, err := issuer.Issue("tv")
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if challenge.Difficulty != 8 || challenge.ExpiresIn != 60 {
+		t.Errorf("challenge = %+v, want difficulty 8 expiring in 60s", challenge)
+	}
+	nonce, err := Solve(ctx, challenge.Value, challenge.Difficulty)
+	if err != nil {
+		t.Fatalf("Solve failed: %v", err)
+	}
+
+	// Find a nonce that does not solve the challenge
+	wrong := nonce + "x"
+	for LeadingZeroBits(challenge.Value, wrong) >= challenge.Difficulty {
+		wrong += "x"
+	}
+
+	tests := []struct {
+		name      string
+		clientID  string
+		challenge string
+		nonce     string
+		want      error
+	}{
+		{name: "wrong nonce", clientID: "tv", challenge: challenge.Value, nonce: wrong, want: ErrInvalidSolution},
+		{name: "other client", clientID: "kiosk", challenge: challenge.Value, nonce: nonce, want: ErrInvalidSolution},
+		{name: "tampered", clientID: "tv", challenge: "x" + challenge.Value, nonce: nonce, want: ErrInvalidSolution},
+		{name: "missing nonce", clientID: "tv", challenge: challenge.Value, want: ErrInvalidSolution},
+		{name: "solved", clientID: "tv", challenge: challenge.Value, nonce: nonce},
+		{name: "replayed", clientID: "tv", challenge: challenge.Value, nonce: nonce, want: ErrReplayed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := issuer.Verify(ctx, tt.clientID, tt.challenge, tt.nonce); !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssuerExpiry(t *testing.T) {
+	ctx := context.Background()
+	issuer := New(Config{Secret: []byte("secret"), Difficulty: 4, TTL: time.Minute})
+	challenge, err := issuer.Issue("tv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := Solve(ctx, challenge.Value, challenge.Difficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	if err := issuer.Verify(ctx, "tv", challenge.Value, nonce); !errors.Is(err, ErrExpired) {
+		t.Errorf("Verify() = %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestIssuerRotate(t *testing.T) {
+	ctx := context.Background()
+	issuer := New(Config{Secret: []byte("first"), Difficulty: 4, TTL: time.Minute})
+	solved := func() (string, string) {
+		challenge, err := issuer.Issue("tv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		nonce, err := Solve(ctx, challenge.Value, challenge.Difficulty)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return challenge.Value, nonce
+	}
+
+	// Challenges in flight survive one rotation but not two
+	before, beforeNonce := solved()
+	stale, staleNonce := solved()
+	issuer.Rotate([]byte("second"))
+	if err := issuer.Verify(ctx, "tv", before, beforeNonce); err != nil {
+		t.Errorf("Verify() of a challenge issued before rotation = %v", err)
+	}
+	after, afterNonce := solved()
+	if err := issuer.Verify(ctx, "tv", after, afterNonce); err != nil {
+		t.Errorf("Verify() of a challenge issued after rotation = %v", err)
+	}
+	issuer.Rotate([]byte("third"))
+	if err := issuer.Verify(ctx, "tv", stale, staleNonce); !errors.Is(err, ErrInvalidSolution) {
+		t.Errorf("Verify() after two rotations = %v, want %v", err, ErrInvalidSolution)
+	}
+}
+
+func TestLeadingZeroBits(t *testing.T) {
+	// The SHA-256 of "a:0" is 0ed3..., which has four leading zero bits
+	if got := LeadingZeroBits("a", "0"); got != 4 {
+		t.Errorf("LeadingZeroBits = %d, want 4", got)
+	}
+}
@@ -0,0 +1,163 @@
+// Package redact removes device codes, user codes, tokens and client
+// credentials from URLs, request bodies and dumped HTTP messages before they
+// are logged.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/url"
+	"strings"
+)
+
+// Placeholder replaces redacted values
+const Placeholder = "REDACTED"
+
+// sensitiveParams are query, form and JSON members holding secrets or codes
+// that grant access to a device flow
+var sensitiveParams = map[string]bool{
+	"access_token":     true,
+	"client_assertion": true,
+	"client_secret":    true,
+	"code":             true,
+	"device_code":      true,
+	"id_token":         true,
+	"password":         true,
+	"refresh_token":    true,
+	"subject_token":    true,
+	"token":            true,
+	"user_code":        true,
+}
+
+// sensitiveHeaders carry credentials
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+// Sensitive reports whether a parameter or JSON member is redacted
+func Sensitive(name string) bool {
+	return sensitiveParams[strings.ToLower(name)]
+}
+
+// Values returns a copy of the values with sensitive parameters redacted
+func Values(values url.Values) url.Values {
+	redacted := make(url.Values, len(values))
+	for name, vals := range values {
+		if Sensitive(name) {
+			vals = []string{Placeholder}
+		}
+		redacted[name] = vals
+	}
+	return redacted
+}
+
+// RequestURI redacts sensitive query parameters and path segments following
+// any of the given prefixes, such as the user code in /a/{code}
+func RequestURI(uri string, pathPrefixes ...string) string {
+	path, query, hasQuery := strings.Cut(uri, "?")
+	for _, prefix := range pathPrefixes {
+		if rest, ok := strings.CutPrefix(path, prefix); ok && rest != "" {
+			_, tail, _ := strings.Cut(rest, "/")
+			path = prefix + Placeholder
+			if tail != "" {
+				path += "/" + tail
+			}
+			break
+		}
+	}
+	if !hasQuery {
+		return path
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return path + "?" + Placeholder
+	}
+	return path + "?" + Values(values).Encode()
+}
+
+// Body redacts sensitive members of a form or JSON body. Bodies of other
+// types are returned unchanged, and unparseable ones replaced entirely.
+func Body(contentType string, body []byte) []byte {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return []byte(Placeholder)
+		}
+		return []byte(Values(values).Encode())
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var doc any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			return []byte(Placeholder)
+		}
+		redacted, err := json.Marshal(redactJSON(doc))
+		if err != nil {
+			return []byte(Placeholder)
+		}
+		return redacted
+	}
+	return body
+}
+
+// redactJSON replaces sensitive members throughout a decoded JSON document
+func redactJSON(doc any) any {
+	switch v := doc.(type) {
+	case map[string]any:
+		for name, member := range v {
+			if Sensitive(name) {
+				v[name] = Placeholder
+			} else {
+				v[name] = redactJSON(member)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactJSON(item)
+		}
+	}
+	return doc
+}
+
+// Dump redacts an HTTP message dumped with net/http/httputil, covering the
+// request target, credential headers and form or JSON bodies
+func Dump(dump []byte) []byte {
+	head, body, hasBody := bytes.Cut(dump, []byte("\r\n\r\n"))
+	lines := strings.Split(string(head), "\r\n")
+
+	// Request line: METHOD target PROTO
+	if fields := strings.SplitN(lines[0], " ", 3); len(fields) == 3 && !strings.HasPrefix(fields[0], "HTTP/") {
+		fields[1] = RequestURI(fields[1])
+		lines[0] = strings.Join(fields, " ")
+	}
+
+	var contentType string
+	for i, line := range lines[1:] {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.EqualFold(name, "Content-Type"):
+			contentType = strings.TrimSpace(value)
+		case isSensitiveHeader(name):
+			lines[i+1] = name + ": " + Placeholder
+		}
+	}
+
+	redacted := []byte(strings.Join(lines, "\r\n"))
+	if !hasBody {
+		return redacted
+	}
+	redacted = append(redacted, "\r\n\r\n"...)
+	return append(redacted, Body(contentType, body)...)
+}
+
+// isSensitiveHeader reports whether a header carries credentials
+func isSensitiveHeader(name string) bool {
+	for _, sensitive := range sensitiveHeaders {
+		if strings.EqualFold(name, sensitive) {
+			return true
+		}
+	}
+	return false
+}
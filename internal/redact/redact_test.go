@@ -0,0 +1,78 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequestURI(t *testing.T) {
+	tests := []struct {
+		uri      string
+		prefixes []string
+		want     string
+	}{
+		{uri: "/device", want: "/device"},
+		{uri: "/device?code=BCDF-GHJK", want: "/device?code=REDACTED"},
+		{uri: "/device/complete?code=abc&state=xyz", want: "/device/complete?code=REDACTED&state=xyz"},
+		{uri: "/a/BCDFGHJK", prefixes: []string{"/a/"}, want: "/a/REDACTED"},
+		{uri: "/a/BCDFGHJK?lang=de", prefixes: []string{"/a/"}, want: "/a/REDACTED?lang=de"},
+		{uri: "/device?code=%zz", want: "/device?REDACTED"},
+	}
+
+	for _, tt := range tests {
+		if got := RequestURI(tt.uri, tt.prefixes...); got != tt.want {
+			t.Errorf("RequestURI(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestBody(t *testing.T) {
+	form := Body("application/x-www-form-urlencoded",
+		[]byte("grant_type=urn%3Aietf%3Aparams%3Aoauth%3Agrant-type%3Adevice_code&device_code=secret-device&client_id=tv"))
+	if strings.Contains(string(form), "secret-device") || !strings.Contains(string(form), "client_id=tv") {
+		t.Errorf("form body = %s", form)
+	}
+
+	json := Body("application/json; charset=utf-8",
+		[]byte(`{"access_token":"at","token_type":"Bearer","nested":[{"refresh_token":"rt"}]}`))
+	for _, secret := range []string{`"at"`, `"rt"`} {
+		if strings.Contains(string(json), secret) {
+			t.Errorf("JSON body = %s, contains %s", json, secret)
+		}
+	}
+	if !strings.Contains(string(json), `"token_type":"Bearer"`) {
+		t.Errorf("JSON body = %s, lost non-sensitive members", json)
+	}
+
+	if got := Body("application/json", []byte(`{"access_token":"at`)); string(got) != Placeholder {
+		t.Errorf("truncated JSON body = %s, want it replaced", got)
+	}
+	if got := Body("text/html", []byte("<p>hi</p>")); string(got) != "<p>hi</p>" {
+		t.Errorf("HTML body = %s, want it unchanged", got)
+	}
+}
+
+func TestDump(t *testing.T) {
+	dump := "POST /device/token?device_code=dc HTTP/1.1\r\n" +
+		"Host: proxy\r\n" +
+		"Authorization: Basic dHY6c2VjcmV0\r\n" +
+		"Content-Type: application/x-www-form-urlencoded\r\n" +
+		"\r\n" +
+		"client_secret=s3cret&client_id=tv"
+
+	got := string(Dump([]byte(dump)))
+	for _, secret := range []string{"device_code=dc", "dHY6c2VjcmV0", "s3cret"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("dump contains %q:\n%s", secret, got)
+		}
+	}
+	if !strings.Contains(got, "Host: proxy") || !strings.Contains(got, "client_id=tv") {
+		t.Errorf("dump lost non-sensitive content:\n%s", got)
+	}
+
+	response := "HTTP/1.1 200 OK\r\nSet-Cookie: session=abc\r\nContent-Type: application/json\r\n\r\n{\"access_token\":\"at\"}"
+	got = string(Dump([]byte(response)))
+	if strings.Contains(got, "session=abc") || strings.Contains(got, `"at"`) || !strings.HasPrefix(got, "HTTP/1.1 200 OK") {
+		t.Errorf("response dump = %s", got)
+	}
+}
@@ -0,0 +1,261 @@
+package renewal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/wrale/oauth2-device-proxy/internal/envelope"
+)
+
+// grantPrefix namespaces grants in Redis, mirrored by keyspace.Patterns
+const grantPrefix = "renewal:"
+
+// offlineIndex maps offline grant IDs to the hash keying their current grant
+const offlineIndex = grantPrefix + "offline"
+
+// authorizationPrefix keys the hash of a grant's current access token by the
+// device code hash of the authorization that created it
+const authorizationPrefix = grantPrefix + "authorization:"
+
+// RedisStore keeps grants in Redis, keyed by a hash of their access token so
+// that tokens never appear in key names
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+	sealer *envelope.Sealer // Encrypts grants, nil stores plaintext
+}
+
+// RedisOption configures the Redis store
+type RedisOption func(*RedisStore)
+
+// WithKeyPrefix namespaces the grant keys as REDIS_KEY_PREFIX does for the
+// other stores
+func WithKeyPrefix(prefix string) RedisOption {
+	return func(s *RedisStore) {
+		s.prefix = prefix
+	}
+}
+
+// WithEncryption seals grants, which hold refresh tokens, before they are written
+func WithEncryption(sealer *envelope.Sealer) RedisOption {
+	return func(s *RedisStore) {
+		s.sealer = sealer
+	}
+}
+
+// NewRedisStore creates a Redis-backed grant store
+func NewRedisStore(client *redis.Client, opts ...RedisOption) *RedisStore {
+	s := &RedisStore{client: client}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// key returns the key of the grant for an access token and the hash it is
+// derived from
+func (s *RedisStore) key(accessToken string) (string, string) {
+	sum := sha256.Sum256([]byte(accessToken))
+	hash := hex.EncodeToString(sum[:])
+	return s.hashKey(hash), hash
+}
+
+func (s *RedisStore) hashKey(hash string) string {
+	return s.prefix + grantPrefix + hash
+}
+
+func (s *RedisStore) authorizationKey(deviceCodeHash string) string {
+	return s.prefix + authorizationPrefix + deviceCodeHash
+}
+
+// aad binds a sealed grant to its key
+func aad(hash string) []byte {
+	return []byte(grantPrefix + hash)
+}
+
+// Get implements Store
+func (s *RedisStore) Get(ctx context.Context, accessToken string) (*Grant, error) {
+	key, hash := s.key(accessToken)
+	data, err := s.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting grant: %w", err)
+	}
+	return s.decode(ctx, data, aad(hash))
+}
+
+// Take implements Store
+func (s *RedisStore) Take(ctx context.Context, accessToken string) (*Grant, error) {
+	key, hash := s.key(accessToken)
+	data, err := s.client.GetDel(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("taking grant: %w", err)
+	}
+	return s.decode(ctx, data, aad(hash))
+}
+
+// Save implements Store, indexing offline grants by their ID and grants by
+// their authorization. The authorization entry expires with the grant.
+func (s *RedisStore) Save(ctx context.Context, grant *Grant, ttl time.Duration) error {
+	key, hash := s.key(grant.AccessToken)
+	data, err := json.Marshal(grant)
+	if err != nil {
+		return fmt.Errorf("encoding grant: %w", err)
+	}
+	if s.sealer != nil {
+		if data, err = s.sealer.Seal(ctx, data, aad(hash)); err != nil {
+			return fmt.Errorf("sealing grant: %w", err)
+		}
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, key, data, ttl)
+	if grant.Offline {
+		pipe.HSet(ctx, s.prefix+offlineIndex, grant.ID, hash)
+	}
+	if grant.DeviceCodeHash != "" {
+		pipe.Set(ctx, s.authorizationKey(grant.DeviceCodeHash), hash, ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("saving grant: %w", err)
+	}
+	return nil
+}
+
+// ListOffline implements Store, dropping index entries whose grant is gone
+func (s *RedisStore) ListOffline(ctx context.Context) ([]*Grant, error) {
+	index, err := s.client.HGetAll(ctx, s.prefix+offlineIndex).Result()
+	if err != nil {
+		return nil, fmt.Errorf("listing offline grants: %w", err)
+	}
+
+	grants := make([]*Grant, 0, len(index))
+	for id, hash := range index {
+		data, err := s.client.Get(ctx, s.hashKey(hash)).Bytes()
+		if errors.Is(err, redis.Nil) {
+			// Taken for renewal or revoked; renewal indexes the new grant
+			_ = removeStaleIndex.Run(ctx, s.client, []string{s.prefix + offlineIndex}, id, hash).Err()
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("getting offline grant: %w", err)
+		}
+		grant, err := s.decode(ctx, data, aad(hash))
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, nil
+}
+
+// TakeOffline implements Store
+func (s *RedisStore) TakeOffline(ctx context.Context, id string) (*Grant, error) {
+	hash, err := s.client.HGet(ctx, s.prefix+offlineIndex, id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding offline grant: %w", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	taken := pipe.GetDel(ctx, s.hashKey(hash))
+	pipe.HDel(ctx, s.prefix+offlineIndex, id)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("taking offline grant: %w", err)
+	}
+	data, err := taken.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("taking offline grant: %w", err)
+	}
+	return s.decode(ctx, data, aad(hash))
+}
+
+// GetAuthorization implements Store
+func (s *RedisStore) GetAuthorization(ctx context.Context, deviceCodeHash string) (*Grant, error) {
+	hash, err := s.client.Get(ctx, s.authorizationKey(deviceCodeHash)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding grant: %w", err)
+	}
+
+	data, err := s.client.Get(ctx, s.hashKey(hash)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil // Taken for renewal or revoked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting grant: %w", err)
+	}
+	return s.decode(ctx, data, aad(hash))
+}
+
+// TakeAuthorization implements Store. An offline index entry left behind is
+// dropped by the next ListOffline.
+func (s *RedisStore) TakeAuthorization(ctx context.Context, deviceCodeHash string) (*Grant, error) {
+	hash, err := s.client.Get(ctx, s.authorizationKey(deviceCodeHash)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("finding grant: %w", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	taken := pipe.GetDel(ctx, s.hashKey(hash))
+	pipe.Del(ctx, s.authorizationKey(deviceCodeHash))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("taking grant: %w", err)
+	}
+	data, err := taken.Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("taking grant: %w", err)
+	}
+	return s.decode(ctx, data, aad(hash))
+}
+
+// removeStaleIndex deletes an offline index entry only if it still points at
+// the missing grant, so a concurrent renewal's entry survives
+var removeStaleIndex = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call("HDEL", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+func (s *RedisStore) decode(ctx context.Context, data, aad []byte) (*Grant, error) {
+	if envelope.IsSealed(data) {
+		if s.sealer == nil {
+			return nil, errors.New("reading grant: sealed grant without encryption keys")
+		}
+		var err error
+		if data, err = s.sealer.Open(ctx, data, aad); err != nil {
+			return nil, fmt.Errorf("opening grant: %w", err)
+		}
+	}
+	var grant Grant
+	if err := json.Unmarshal(data, &grant); err != nil {
+		return nil, fmt.Errorf("decoding grant: %w", err)
+	}
+	return &grant, nil
+}
@@ -0,0 +1,332 @@
+// Package renewal keeps refresh tokens on the proxy and renews access tokens
+// for devices, so that devices never store a long-lived credential
+package renewal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// Renewal defaults
+const (
+	// DefaultGrantTTL is how long an unused grant is kept. Each renewal
+	// extends it, so devices in regular use keep their grant indefinitely.
+	DefaultGrantTTL = 30 * 24 * time.Hour
+
+	// DefaultSkew renews access tokens this long before they expire
+	DefaultSkew = 30 * time.Second
+)
+
+var (
+	// ErrUnknownGrant indicates the access token is not the current token of
+	// any grant, because it was never registered, was already renewed or the
+	// grant expired
+	ErrUnknownGrant = errors.New("no grant for access token")
+
+	// ErrGrantRevoked indicates the authorization server rejected the refresh
+	// token, ending the grant
+	ErrGrantRevoked = errors.New("grant revoked by the authorization server")
+)
+
+// Grant is a device's authorization as held by the proxy
+type Grant struct {
+	ID           string    `json:"id"` // Stable across renewals, unlike the access token
+	ClientID     string    `json:"client_id"`
+	Scope        string    `json:"scope,omitempty"`
+	TokenType    string    `json:"token_type"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry,omitempty"` // Access token expiry, zero if unknown
+	CreatedAt    time.Time `json:"created_at"`
+
+	// DeviceCodeHash links the grant to the authorization's audit records, as
+	// computed by audit.HashDeviceCode
+	DeviceCodeHash string `json:"device_code_hash,omitempty"`
+
+	// Offline grants hold a refresh token that outlives the user's session.
+	// They are kept until revoked instead of expiring after GrantTTL.
+	Offline bool `json:"offline,omitempty"`
+}
+
+// Store keeps grants by their current access token
+type Store interface {
+	// Get returns the grant whose current access token is accessToken, or nil
+	Get(ctx context.Context, accessToken string) (*Grant, error)
+
+	// Take removes and returns the grant whose current access token is
+	// accessToken, or nil if it is unknown or another request took it first
+	Take(ctx context.Context, accessToken string) (*Grant, error)
+
+	// Save stores a grant under its current access token. A zero ttl keeps
+	// the grant until it is taken.
+	Save(ctx context.Context, grant *Grant, ttl time.Duration) error
+
+	// ListOffline returns the stored offline grants
+	ListOffline(ctx context.Context) ([]*Grant, error)
+
+	// TakeOffline removes and returns the offline grant with the given ID, or
+	// nil if it is unknown
+	TakeOffline(ctx context.Context, id string) (*Grant, error)
+
+	// GetAuthorization returns the grant created by the authorization with
+	// the given device code hash, or nil
+	GetAuthorization(ctx context.Context, deviceCodeHash string) (*Grant, error)
+
+	// TakeAuthorization removes and returns the grant created by the
+	// authorization with the given device code hash, or nil if it is unknown
+	TakeAuthorization(ctx context.Context, deviceCodeHash string) (*Grant, error)
+}
+
+// RefreshFunc redeems a refresh token at the authorization server. It returns
+// ErrGrantRevoked when the refresh token is no longer valid.
+type RefreshFunc func(ctx context.Context, refreshToken string) (*deviceflow.TokenResponse, error)
+
+// RevokeFunc revokes a refresh token at the authorization server per RFC 7009
+type RevokeFunc func(ctx context.Context, refreshToken string) error
+
+// Config configures a Renewer. Zero durations use the package defaults.
+type Config struct {
+	Store    Store
+	Refresh  RefreshFunc
+	Revoke   RevokeFunc // Required to revoke offline grants
+	GrantTTL time.Duration
+	Skew     time.Duration
+}
+
+// Renewer registers grants as devices are authorized and renews their access
+// tokens on request
+type Renewer struct {
+	store    Store
+	refresh  RefreshFunc
+	revoke   RevokeFunc
+	grantTTL time.Duration
+	skew     time.Duration
+	now      func() time.Time
+}
+
+// New creates a Renewer
+func New(cfg Config) *Renewer {
+	if cfg.GrantTTL <= 0 {
+		cfg.GrantTTL = DefaultGrantTTL
+	}
+	if cfg.Skew <= 0 {
+		cfg.Skew = DefaultSkew
+	}
+	return &Renewer{
+		store:    cfg.Store,
+		refresh:  cfg.Refresh,
+		revoke:   cfg.Revoke,
+		grantTTL: cfg.GrantTTL,
+		skew:     cfg.Skew,
+		now:      time.Now,
+	}
+}
+
+// EnrichToken implements deviceflow.TokenEnricher, registering a grant for
+// each authorization that returned a refresh token. The token response is
+// stored unchanged; withhold the refresh token from devices with
+// deviceflow.WithTokenWithholding.
+func (r *Renewer) EnrichToken(ctx context.Context, code *deviceflow.DeviceCode, token *deviceflow.TokenResponse) (*deviceflow.TokenResponse, error) {
+	if token.RefreshToken == "" {
+		return token, nil
+	}
+	id, err := newGrantID()
+	if err != nil {
+		return nil, err
+	}
+	grant := &Grant{
+		ID:             id,
+		ClientID:       code.ClientID,
+		Scope:          token.Scope,
+		TokenType:      token.TokenType,
+		AccessToken:    token.AccessToken,
+		RefreshToken:   token.RefreshToken,
+		CreatedAt:      r.now(),
+		DeviceCodeHash: audit.HashDeviceCode(code.DeviceCode),
+		Offline:        token.Offline,
+	}
+	if token.ExpiresIn > 0 {
+		grant.Expiry = r.now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if err := r.store.Save(ctx, grant, r.ttl(grant)); err != nil {
+		return nil, fmt.Errorf("registering grant: %w", err)
+	}
+	return token, nil
+}
+
+// Current returns a valid access token for the grant whose current access
+// token is accessToken, renewing it when it has expired or is about to. A
+// renewed access token replaces the previous one, which can no longer be used
+// to obtain tokens.
+func (r *Renewer) Current(ctx context.Context, accessToken string) (*deviceflow.TokenResponse, error) {
+	grant, err := r.store.Get(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("loading grant: %w", err)
+	}
+	if grant == nil {
+		return nil, ErrUnknownGrant
+	}
+	if grant.Expiry.IsZero() || r.now().Add(r.skew).Before(grant.Expiry) {
+		return r.response(grant), nil
+	}
+
+	// Take the grant so that concurrent requests cannot redeem the refresh
+	// token twice, which rotating authorization servers treat as theft
+	grant, err = r.store.Take(ctx, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("claiming grant: %w", err)
+	}
+	if grant == nil {
+		return nil, ErrUnknownGrant
+	}
+
+	token, err := r.refresh(ctx, grant.RefreshToken)
+	if err != nil {
+		if !errors.Is(err, ErrGrantRevoked) {
+			// Put the grant back so the device can retry
+			if saveErr := r.store.Save(ctx, grant, r.ttl(grant)); saveErr != nil {
+				log.Printf("Error: failed to restore grant after refresh failure: %v", saveErr)
+			}
+		}
+		return nil, err
+	}
+
+	renewed := &Grant{
+		ID:             grant.ID,
+		ClientID:       grant.ClientID,
+		Scope:          grant.Scope,
+		TokenType:      token.TokenType,
+		AccessToken:    token.AccessToken,
+		RefreshToken:   token.RefreshToken,
+		CreatedAt:      grant.CreatedAt,
+		DeviceCodeHash: grant.DeviceCodeHash,
+		Offline:        grant.Offline,
+	}
+	if renewed.RefreshToken == "" {
+		renewed.RefreshToken = grant.RefreshToken // Not rotated
+	}
+	if token.Scope != "" {
+		renewed.Scope = token.Scope
+	}
+	if token.ExpiresIn > 0 {
+		renewed.Expiry = r.now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	if err := r.store.Save(ctx, renewed, r.ttl(renewed)); err != nil {
+		return nil, fmt.Errorf("saving renewed grant: %w", err)
+	}
+	return r.response(renewed), nil
+}
+
+// ClientOf returns the client of the grant whose current access token is
+// accessToken, or an empty string if it is not current
+func (r *Renewer) ClientOf(ctx context.Context, accessToken string) (string, error) {
+	grant, err := r.store.Get(ctx, accessToken)
+	if err != nil {
+		return "", fmt.Errorf("loading grant: %w", err)
+	}
+	if grant == nil {
+		return "", nil
+	}
+	return grant.ClientID, nil
+}
+
+// OfflineGrants returns the offline grants held by the proxy
+func (r *Renewer) OfflineGrants(ctx context.Context) ([]*Grant, error) {
+	grants, err := r.store.ListOffline(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing offline grants: %w", err)
+	}
+	return grants, nil
+}
+
+// RevokeOffline revokes an offline grant's refresh token at the authorization
+// server and removes the grant. The grant is kept if revocation fails.
+func (r *Renewer) RevokeOffline(ctx context.Context, id string) (*Grant, error) {
+	if r.revoke == nil {
+		return nil, errors.New("revocation is not configured")
+	}
+	grant, err := r.store.TakeOffline(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("claiming grant: %w", err)
+	}
+	return r.revokeGrant(ctx, grant)
+}
+
+// AuthorizationGrant returns the grant created by the authorization with the
+// given device code hash, or nil when the proxy holds none
+func (r *Renewer) AuthorizationGrant(ctx context.Context, deviceCodeHash string) (*Grant, error) {
+	grant, err := r.store.GetAuthorization(ctx, deviceCodeHash)
+	if err != nil {
+		return nil, fmt.Errorf("loading grant: %w", err)
+	}
+	return grant, nil
+}
+
+// RevokeAuthorization revokes the refresh token of the grant created by the
+// authorization with the given device code hash and removes the grant, so the
+// device can no longer renew its access token. The grant is kept if
+// revocation fails.
+func (r *Renewer) RevokeAuthorization(ctx context.Context, deviceCodeHash string) (*Grant, error) {
+	if r.revoke == nil {
+		return nil, errors.New("revocation is not configured")
+	}
+	grant, err := r.store.TakeAuthorization(ctx, deviceCodeHash)
+	if err != nil {
+		return nil, fmt.Errorf("claiming grant: %w", err)
+	}
+	return r.revokeGrant(ctx, grant)
+}
+
+// revokeGrant revokes a claimed grant's refresh token, restoring the grant
+// when revocation fails
+func (r *Renewer) revokeGrant(ctx context.Context, grant *Grant) (*Grant, error) {
+	if grant == nil {
+		return nil, ErrUnknownGrant
+	}
+	if err := r.revoke(ctx, grant.RefreshToken); err != nil {
+		if saveErr := r.store.Save(ctx, grant, r.ttl(grant)); saveErr != nil {
+			log.Printf("Error: failed to restore grant after revocation failure: %v", saveErr)
+		}
+		return nil, fmt.Errorf("revoking refresh token: %w", err)
+	}
+	return grant, nil
+}
+
+// ttl returns how long an unused grant is kept. Offline grants are exempt
+// from expiry and kept until revoked.
+func (r *Renewer) ttl(grant *Grant) time.Duration {
+	if grant.Offline {
+		return 0
+	}
+	return r.grantTTL
+}
+
+// newGrantID generates a random grant identifier
+func newGrantID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating grant ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// response is the token response returned to the device for a grant
+func (r *Renewer) response(grant *Grant) *deviceflow.TokenResponse {
+	resp := &deviceflow.TokenResponse{
+		AccessToken: grant.AccessToken,
+		TokenType:   grant.TokenType,
+		Scope:       grant.Scope,
+	}
+	if !grant.Expiry.IsZero() {
+		resp.ExpiresIn = int(grant.Expiry.Sub(r.now()).Seconds())
+	}
+	return resp
+}
@@ -0,0 +1,324 @@
+package renewal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/audit"
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// memoryStore keeps grants in a map for tests
+type memoryStore struct {
+	mu     sync.Mutex
+	grants map[string]Grant
+	ttls   map[string]time.Duration
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{grants: make(map[string]Grant), ttls: make(map[string]time.Duration)}
+}
+
+func (m *memoryStore) Get(ctx context.Context, accessToken string) (*Grant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	grant, ok := m.grants[accessToken]
+	if !ok {
+		return nil, nil
+	}
+	return &grant, nil
+}
+
+func (m *memoryStore) Take(ctx context.Context, accessToken string) (*Grant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	grant, ok := m.grants[accessToken]
+	if !ok {
+		return nil, nil
+	}
+	delete(m.grants, accessToken)
+	return &grant, nil
+}
+
+func (m *memoryStore) Save(ctx context.Context, grant *Grant, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.grants[grant.AccessToken] = *grant
+	m.ttls[grant.AccessToken] = ttl
+	return nil
+}
+
+func (m *memoryStore) ListOffline(ctx context.Context) ([]*Grant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var grants []*Grant
+	for _, grant := range m.grants {
+		if grant.Offline {
+			grant := grant
+			grants = append(grants, &grant)
+		}
+	}
+	return grants, nil
+}
+
+func (m *memoryStore) TakeOffline(ctx context.Context, id string) (*Grant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for accessToken, grant := range m.grants {
+		if grant.Offline && grant.ID == id {
+			delete(m.grants, accessToken)
+			return &grant, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryStore) GetAuthorization(ctx context.Context, deviceCodeHash string) (*Grant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, grant := range m.grants {
+		if grant.DeviceCodeHash == deviceCodeHash {
+			return &grant, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryStore) TakeAuthorization(ctx context.Context, deviceCodeHash string) (*Grant, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for accessToken, grant := range m.grants {
+		if grant.DeviceCodeHash == deviceCodeHash {
+			delete(m.grants, accessToken)
+			return &grant, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestRenewer(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := newMemoryStore()
+	refreshes := 0
+	r := New(Config{
+		Store: store,
+		Refresh: func(ctx context.Context, refreshToken string) (*deviceflow.TokenResponse, error) {
+			refreshes++
+			if refreshToken != "refresh-1" {
+				t.Errorf("refresh token = %q, want refresh-1", refreshToken)
+			}
+			return &deviceflow.TokenResponse{AccessToken: "access-2", TokenType: "Bearer", ExpiresIn: 300}, nil
+		},
+	})
+	r.now = func() time.Time { return now }
+
+	code := &deviceflow.DeviceCode{ClientID: "tv"}
+	issued := &deviceflow.TokenResponse{
+		AccessToken:  "access-1",
+		TokenType:    "Bearer",
+		RefreshToken: "refresh-1",
+		ExpiresIn:    300,
+		Scope:        "read",
+	}
+	if _, err := r.EnrichToken(ctx, code, issued); err != nil {
+		t.Fatalf("EnrichToken: %v", err)
+	}
+
+	// A fresh access token is returned as is
+	token, err := r.Current(ctx, "access-1")
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "" || refreshes != 0 {
+		t.Errorf("Current = %+v after %d refreshes, want access-1 without refreshing", token, refreshes)
+	}
+
+	// Within the skew of expiry the grant is renewed
+	now = now.Add(290 * time.Second)
+	token, err = r.Current(ctx, "access-1")
+	if err != nil {
+		t.Fatalf("Current near expiry: %v", err)
+	}
+	if token.AccessToken != "access-2" || token.ExpiresIn != 300 || token.Scope != "read" || refreshes != 1 {
+		t.Errorf("Current = %+v after %d refreshes, want renewed access-2", token, refreshes)
+	}
+
+	// The renewed token replaces the previous one, keeping the unrotated refresh token
+	if _, err := r.Current(ctx, "access-1"); !errors.Is(err, ErrUnknownGrant) {
+		t.Errorf("Current with replaced token = %v, want ErrUnknownGrant", err)
+	}
+	grant, _ := store.Get(ctx, "access-2")
+	if grant == nil || grant.RefreshToken != "refresh-1" || grant.ClientID != "tv" {
+		t.Errorf("renewed grant = %+v, want refresh-1 for tv", grant)
+	}
+
+	// Only the current access token identifies the grant's client
+	if clientID, err := r.ClientOf(ctx, "access-2"); err != nil || clientID != "tv" {
+		t.Errorf("ClientOf(access-2) = %q, %v, want tv", clientID, err)
+	}
+	if clientID, err := r.ClientOf(ctx, "access-1"); err != nil || clientID != "" {
+		t.Errorf("ClientOf(access-1) = %q, %v, want no client", clientID, err)
+	}
+}
+
+func TestRenewerRefreshFailure(t *testing.T) {
+	ctx := context.Background()
+	unavailable := errors.New("upstream unavailable")
+
+	tests := []struct {
+		name      string
+		err       error
+		wantGrant bool
+	}{
+		{name: "transient failure keeps grant", err: unavailable, wantGrant: true},
+		{name: "revoked grant is dropped", err: ErrGrantRevoked, wantGrant: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemoryStore()
+			r := New(Config{
+				Store: store,
+				Refresh: func(ctx context.Context, refreshToken string) (*deviceflow.TokenResponse, error) {
+					return nil, tt.err
+				},
+			})
+			expired := &Grant{AccessToken: "access", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Minute)}
+			if err := store.Save(ctx, expired, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := r.Current(ctx, "access"); !errors.Is(err, tt.err) {
+				t.Errorf("Current = %v, want %v", err, tt.err)
+			}
+			if grant, _ := store.Get(ctx, "access"); (grant != nil) != tt.wantGrant {
+				t.Errorf("grant kept = %v, want %v", grant != nil, tt.wantGrant)
+			}
+		})
+	}
+}
+
+func TestEnrichTokenWithoutRefreshToken(t *testing.T) {
+	store := newMemoryStore()
+	r := New(Config{Store: store})
+	token := &deviceflow.TokenResponse{AccessToken: "access", TokenType: "Bearer"}
+	if _, err := r.EnrichToken(context.Background(), &deviceflow.DeviceCode{}, token); err != nil {
+		t.Fatalf("EnrichToken: %v", err)
+	}
+	if len(store.grants) != 0 {
+		t.Errorf("registered %d grants, want none without a refresh token", len(store.grants))
+	}
+}
+
+func TestRedisStoreKey(t *testing.T) {
+	s := NewRedisStore(nil, WithKeyPrefix("staging:"))
+	key, hash := s.key("access")
+	if want := "staging:renewal:"; key[:len(want)] != want || string(aad(hash)) != key[len("staging:"):] {
+		t.Errorf("key = %q, aad = %q", key, aad(hash))
+	}
+	if key == "staging:renewal:access" {
+		t.Error("key contains the access token")
+	}
+}
+
+func TestOfflineGrants(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	revokeErr := errors.New("unavailable")
+	var revoked []string
+	r := New(Config{
+		Store: store,
+		Revoke: func(ctx context.Context, refreshToken string) error {
+			if revokeErr != nil {
+				return revokeErr
+			}
+			revoked = append(revoked, refreshToken)
+			return nil
+		},
+	})
+
+	code := &deviceflow.DeviceCode{ClientID: "tv"}
+	offline := &deviceflow.TokenResponse{AccessToken: "access-1", TokenType: "Bearer", RefreshToken: "offline-1", Offline: true}
+	online := &deviceflow.TokenResponse{AccessToken: "access-2", TokenType: "Bearer", RefreshToken: "refresh-2"}
+	for _, token := range []*deviceflow.TokenResponse{offline, online} {
+		if _, err := r.EnrichToken(ctx, code, token); err != nil {
+			t.Fatalf("EnrichToken: %v", err)
+		}
+	}
+	if ttl := store.ttls["access-1"]; ttl != 0 {
+		t.Errorf("offline grant TTL = %v, want none", ttl)
+	}
+	if ttl := store.ttls["access-2"]; ttl != DefaultGrantTTL {
+		t.Errorf("grant TTL = %v, want %v", ttl, DefaultGrantTTL)
+	}
+
+	grants, err := r.OfflineGrants(ctx)
+	if err != nil || len(grants) != 1 || grants[0].ClientID != "tv" || grants[0].ID == "" {
+		t.Fatalf("OfflineGrants = %+v, %v, want the offline grant", grants, err)
+	}
+	id := grants[0].ID
+
+	// A failed revocation keeps the grant
+	if _, err := r.RevokeOffline(ctx, id); !errors.Is(err, revokeErr) {
+		t.Fatalf("RevokeOffline error = %v, want %v", err, revokeErr)
+	}
+	if grant, _ := store.Get(ctx, "access-1"); grant == nil {
+		t.Fatal("grant removed after failed revocation")
+	}
+
+	revokeErr = nil
+	if _, err := r.RevokeOffline(ctx, id); err != nil {
+		t.Fatalf("RevokeOffline: %v", err)
+	}
+	if len(revoked) != 1 || revoked[0] != "offline-1" {
+		t.Errorf("revoked %v, want the offline refresh token", revoked)
+	}
+	if _, err := r.RevokeOffline(ctx, id); !errors.Is(err, ErrUnknownGrant) {
+		t.Errorf("second RevokeOffline error = %v, want ErrUnknownGrant", err)
+	}
+}
+
+func TestRevokeAuthorization(t *testing.T) {
+	ctx := context.Background()
+	store := newMemoryStore()
+	var revoked []string
+	r := New(Config{
+		Store: store,
+		Revoke: func(ctx context.Context, refreshToken string) error {
+			revoked = append(revoked, refreshToken)
+			return nil
+		},
+	})
+
+	code := &deviceflow.DeviceCode{DeviceCode: "device-1", ClientID: "tv"}
+	token := &deviceflow.TokenResponse{AccessToken: "access-1", TokenType: "Bearer", RefreshToken: "refresh-1"}
+	if _, err := r.EnrichToken(ctx, code, token); err != nil {
+		t.Fatalf("EnrichToken: %v", err)
+	}
+
+	hash := audit.HashDeviceCode("device-1")
+	grant, err := r.AuthorizationGrant(ctx, hash)
+	if err != nil || grant == nil || grant.ClientID != "tv" {
+		t.Fatalf("AuthorizationGrant = %+v, %v, want the grant", grant, err)
+	}
+	if grant, _ := r.AuthorizationGrant(ctx, audit.HashDeviceCode("device-2")); grant != nil {
+		t.Errorf("AuthorizationGrant for another authorization = %+v, want nil", grant)
+	}
+
+	if _, err := r.RevokeAuthorization(ctx, hash); err != nil {
+		t.Fatalf("RevokeAuthorization: %v", err)
+	}
+	if len(revoked) != 1 || revoked[0] != "refresh-1" {
+		t.Errorf("revoked %v, want the grant's refresh token", revoked)
+	}
+	if _, err := r.Current(ctx, "access-1"); !errors.Is(err, ErrUnknownGrant) {
+		t.Errorf("Current after revocation error = %v, want ErrUnknownGrant", err)
+	}
+	if _, err := r.RevokeAuthorization(ctx, hash); !errors.Is(err, ErrUnknownGrant) {
+		t.Errorf("second RevokeAuthorization error = %v, want ErrUnknownGrant", err)
+	}
+}
@@ -0,0 +1,224 @@
+// Package secrets resolves configuration secrets held in files, such as Docker
+// and Kubernetes secret mounts, or in HashiCorp Vault, and refreshes them so
+// rotated values take effect without a restart
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reference prefixes. A configuration value starting with one of these names
+// where the secret is kept; any other value is the secret itself.
+const (
+	FilePrefix  = "file:"  // file:/run/secrets/csrf_secret
+	VaultPrefix = "vault:" // vault:secret/data/device-proxy#csrf_secret
+)
+
+// DefaultRefreshInterval is how often Watch re-reads referenced secrets
+const DefaultRefreshInterval = 5 * time.Minute
+
+// maxSecretSize bounds secrets read from files and Vault responses
+const maxSecretSize = 64 << 10
+
+// defaultClient bounds Vault requests so an unreachable server cannot stall
+// startup or rotation
+var defaultClient = &http.Client{Timeout: 10 * time.Second}
+
+// ErrVaultNotConfigured indicates a Vault reference without a Vault address
+var ErrVaultNotConfigured = errors.New("secrets: vault reference used but VAULT_ADDR is not set")
+
+// Resolver reads referenced secrets
+type Resolver struct {
+	VaultAddr  string       // Vault server, e.g. https://vault:8200
+	VaultToken string       // Vault token, itself resolvable as a file reference
+	HTTPClient *http.Client // Client for Vault requests, a 10 second timeout client if nil
+}
+
+// Resolve returns the secret named by value, or value itself when it is not
+// a reference
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, FilePrefix):
+		return readFile(strings.TrimPrefix(value, FilePrefix))
+	case strings.HasPrefix(value, VaultPrefix):
+		return r.readVault(ctx, strings.TrimPrefix(value, VaultPrefix))
+	default:
+		return value, nil
+	}
+}
+
+// IsReference reports whether value names a secret kept elsewhere
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, FilePrefix) || strings.HasPrefix(value, VaultPrefix)
+}
+
+// readFile reads a secret file, dropping the trailing newline editors and
+// secret tooling commonly add
+func readFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("secrets: reading %s: %w", path, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxSecretSize))
+	if err != nil {
+		return "", fmt.Errorf("secrets: reading %s: %w", path, err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// readVault reads a field of a Vault secret given as path#field. Both KV
+// version 1 and version 2 mounts are supported.
+func (r *Resolver) readVault(ctx context.Context, ref string) (string, error) {
+	if r.VaultAddr == "" {
+		return "", ErrVaultNotConfigured
+	}
+	path, field, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || field == "" {
+		return "", fmt.Errorf("secrets: vault reference %q must be in path#field form", ref)
+	}
+
+	token, err := r.Resolve(ctx, r.VaultToken)
+	if err != nil {
+		return "", fmt.Errorf("secrets: resolving vault token: %w", err)
+	}
+
+	endpoint, err := url.JoinPath(r.VaultAddr, "v1", path)
+	if err != nil {
+		return "", fmt.Errorf("secrets: invalid vault address: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("secrets: building vault request: %w", err)
+	}
+	req.Header.Set("X-Vault-Token", token)
+
+	client := r.HTTPClient
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("secrets: reading vault secret %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("secrets: reading vault secret %s: status %d", path, resp.StatusCode)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSecretSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("secrets: decoding vault secret %s: %w", path, err)
+	}
+
+	// KV version 2 nests the secret under data.data alongside its metadata
+	data := body.Data
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = nested
+		}
+	}
+
+	value, ok := data[field].(string)
+	if !ok {
+		return "", fmt.Errorf("secrets: vault secret %s has no string field %q", path, field)
+	}
+	return value, nil
+}
+
+// Secret is a configuration secret kept current by Refresh
+type Secret struct {
+	resolver *Resolver
+	ref      string
+
+	mu        sync.RWMutex
+	value     string
+	listeners []func(string)
+}
+
+// Load resolves a configuration value into a Secret
+func (r *Resolver) Load(ctx context.Context, ref string) (*Secret, error) {
+	value, err := r.Resolve(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	return &Secret{resolver: r, ref: ref, value: value}, nil
+}
+
+// Value returns the current secret
+func (s *Secret) Value() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.value
+}
+
+// OnChange registers fn to be called with the new value after a rotation
+func (s *Secret) OnChange(fn func(value string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+// Refresh re-reads a referenced secret, notifying listeners when it changed.
+// On failure the previous value is kept.
+func (s *Secret) Refresh(ctx context.Context) error {
+	if !IsReference(s.ref) {
+		return nil
+	}
+
+	value, err := s.resolver.Resolve(ctx, s.ref)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if value == s.value {
+		s.mu.Unlock()
+		return nil
+	}
+	s.value = value
+	listeners := append([]func(string){}, s.listeners...)
+	s.mu.Unlock()
+
+	for _, fn := range listeners {
+		fn(value)
+	}
+	return nil
+}
+
+// Watch refreshes the secrets every interval until ctx is done, logging
+// failures and keeping the last good values
+func Watch(ctx context.Context, interval time.Duration, secrets ...*Secret) {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, secret := range secrets {
+				if err := secret.Refresh(ctx); err != nil {
+					log.Printf("Warning: failed to refresh secret: %v", err)
+				}
+			}
+		}
+	}
+}
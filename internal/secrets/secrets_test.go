@@ -0,0 +1,132 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// vaultServer serves KV secrets, requiring the given token
+func vaultServer(t *testing.T, token string, secrets map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, ok := secrets[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "csrf_secret")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tokenFile := filepath.Join(dir, "vault_token")
+	if err := os.WriteFile(tokenFile, []byte("vault-token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	vault := vaultServer(t, "vault-token", map[string]string{
+		"/v1/secret/data/proxy": `{"data":{"data":{"csrf":"from-kv2","count":3},"metadata":{"version":2}}}`,
+		"/v1/kv/proxy":          `{"data":{"csrf":"from-kv1"}}`,
+	})
+	resolver := &Resolver{VaultAddr: vault.URL, VaultToken: FilePrefix + tokenFile}
+
+	tests := []struct {
+		name    string
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "literal", value: "plain-secret", want: "plain-secret"},
+		{name: "file", value: FilePrefix + secretFile, want: "from-file"},
+		{name: "missing file", value: FilePrefix + filepath.Join(dir, "missing"), wantErr: true},
+		{name: "vault kv2", value: "vault:secret/data/proxy#csrf", want: "from-kv2"},
+		{name: "vault kv1", value: "vault:kv/proxy#csrf", want: "from-kv1"},
+		{name: "vault missing field", value: "vault:kv/proxy#other", wantErr: true},
+		{name: "vault non-string field", value: "vault:secret/data/proxy#count", wantErr: true},
+		{name: "vault missing secret", value: "vault:kv/absent#csrf", wantErr: true},
+		{name: "vault without field", value: "vault:kv/proxy", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.Resolve(context.Background(), tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Resolve(%q) = %q, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve(%q) failed: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveVaultNotConfigured(t *testing.T) {
+	_, err := (&Resolver{}).Resolve(context.Background(), "vault:kv/proxy#csrf")
+	if !errors.Is(err, ErrVaultNotConfigured) {
+		t.Errorf("Resolve error = %v, want %v", err, ErrVaultNotConfigured)
+	}
+}
+
+func TestSecretRefresh(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("first"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := (&Resolver{}).Load(ctx, FilePrefix+path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	var rotated []string
+	secret.OnChange(func(value string) { rotated = append(rotated, value) })
+
+	// Unchanged files do not notify
+	if err := secret.Refresh(ctx); err != nil || len(rotated) != 0 {
+		t.Errorf("unchanged refresh = %v, notified %v", err, rotated)
+	}
+
+	if err := os.WriteFile(path, []byte("second"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := secret.Refresh(ctx); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if secret.Value() != "second" || len(rotated) != 1 || rotated[0] != "second" {
+		t.Errorf("after rotation value = %q, notified %v", secret.Value(), rotated)
+	}
+
+	// A failed read keeps the last good value
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := secret.Refresh(ctx); err == nil {
+		t.Error("Refresh of removed file succeeded")
+	}
+	if secret.Value() != "second" {
+		t.Errorf("value after failed refresh = %q, want second", secret.Value())
+	}
+}
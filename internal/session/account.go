@@ -0,0 +1,179 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AccountCookieName is the cookie carrying the self-service account session
+const AccountCookieName = "device_account"
+
+// DefaultAccountTTL bounds how long a signed-in user may manage their devices
+// before signing in again
+const DefaultAccountTTL = 15 * time.Minute
+
+// Account is a user signed in to the self-service pages, or signing in. The
+// identity provider session authenticates the user; the cookie only
+// remembers the outcome briefly.
+type Account struct {
+	State     string // OAuth state of a sign-in in progress, empty once signed in
+	Nonce     string // OpenID Connect nonce of a sign-in in progress
+	Subject   string // sub claim of the signed-in user, empty while signing in
+	Name      string // Display name of the signed-in user, if known
+	ExpiresAt time.Time
+}
+
+// SignedIn reports whether the account session belongs to a signed-in user
+func (a *Account) SignedIn() bool {
+	return a.Subject != ""
+}
+
+// accountPayload is the signed cookie encoding of an account session
+type accountPayload struct {
+	State   string `json:"s,omitempty"`
+	Nonce   string `json:"n,omitempty"`
+	Subject string `json:"u,omitempty"`
+	Name    string `json:"m,omitempty"`
+	Expiry  int64  `json:"e"` // Unix seconds
+}
+
+// StartSignIn begins signing in to the self-service pages with fresh state
+// and nonce values, setting the account cookie on the response. The user has
+// as long as a verification session to finish signing in.
+func (m *Manager) StartSignIn(w http.ResponseWriter) (*Account, error) {
+	state, err := randomValue()
+	if err != nil {
+		return nil, fmt.Errorf("generating state: %w", err)
+	}
+	nonce, err := randomValue()
+	if err != nil {
+		return nil, fmt.Errorf("generating nonce: %w", err)
+	}
+
+	account := &Account{
+		State:     state,
+		Nonce:     nonce,
+		ExpiresAt: time.Now().Add(m.ttl).Truncate(time.Second),
+	}
+	if err := m.saveAccount(w, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// VerifySignIn loads the sign-in in progress and checks that the OAuth state
+// returned by the authorization server is the one issued for it
+func (m *Manager) VerifySignIn(r *http.Request, state string) (*Account, error) {
+	account, err := m.LoadAccount(r)
+	if err != nil {
+		return nil, err
+	}
+	if account.SignedIn() || account.State == "" {
+		return nil, ErrStateMismatch
+	}
+	if subtle.ConstantTimeCompare([]byte(state), []byte(account.State)) != 1 {
+		return nil, ErrStateMismatch
+	}
+	return account, nil
+}
+
+// SignIn replaces the sign-in in progress with a session for the user
+func (m *Manager) SignIn(w http.ResponseWriter, subject, name string) (*Account, error) {
+	account := &Account{
+		Subject:   subject,
+		Name:      name,
+		ExpiresAt: time.Now().Add(DefaultAccountTTL).Truncate(time.Second),
+	}
+	if err := m.saveAccount(w, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// LoadAccount returns the account session carried by the request after
+// checking its signature and expiry
+func (m *Manager) LoadAccount(r *http.Request) (*Account, error) {
+	cookie, err := r.Cookie(AccountCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, ErrNoSession
+	}
+
+	encoded, sig, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signWith(m.accountKey, encoded))) {
+		return nil, ErrInvalidSession
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+	var p accountPayload
+	if err := json.Unmarshal(data, &p); err != nil || (p.State == "") == (p.Subject == "") {
+		return nil, ErrInvalidSession
+	}
+
+	account := &Account{State: p.State, Nonce: p.Nonce, Subject: p.Subject, Name: p.Name, ExpiresAt: time.Unix(p.Expiry, 0)}
+	if time.Now().After(account.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+	return account, nil
+}
+
+// ClearAccount removes the account cookie, signing the user out
+func (m *Manager) ClearAccount(w http.ResponseWriter) {
+	http.SetCookie(w, m.accountCookie("", -1))
+}
+
+// saveAccount signs the account session and sets its cookie on the response
+func (m *Manager) saveAccount(w http.ResponseWriter, account *Account) error {
+	data, err := json.Marshal(accountPayload{
+		State:   account.State,
+		Nonce:   account.Nonce,
+		Subject: account.Subject,
+		Name:    account.Name,
+		Expiry:  account.ExpiresAt.Unix(),
+	})
+	if err != nil {
+		return fmt.Errorf("encoding account session: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+
+	maxAge := int(math.Ceil(time.Until(account.ExpiresAt).Seconds()))
+	if maxAge <= 0 {
+		maxAge = -1
+	}
+	http.SetCookie(w, m.accountCookie(encoded+"."+signWith(m.accountKey, encoded), maxAge))
+	return nil
+}
+
+// accountCookie builds the account cookie, scoped to the self-service pages.
+// Lax same-site mode still sends it on the redirect back from the
+// authorization server, while cross-site form posts arrive without it.
+func (m *Manager) accountCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     AccountCookieName,
+		Value:    value,
+		Path:     "/my",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// randomValue returns an unguessable URL-safe value
+func randomValue() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
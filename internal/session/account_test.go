@@ -0,0 +1,75 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// withCookies returns a request carrying the cookies set on the recorder
+func withCookies(w *httptest.ResponseRecorder) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/my/devices", nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	return req
+}
+
+func TestAccountSignIn(t *testing.T) {
+	m := NewManager([]byte("secret"), time.Minute, true)
+
+	w := httptest.NewRecorder()
+	pending, err := m.StartSignIn(w)
+	if err != nil {
+		t.Fatalf("StartSignIn failed: %v", err)
+	}
+	if cookie := w.Result().Cookies()[0]; cookie.Path != "/my" || !cookie.Secure || !cookie.HttpOnly {
+		t.Errorf("cookie = %+v, want a secure HTTP-only cookie scoped to /my", cookie)
+	}
+	req := withCookies(w)
+
+	if _, err := m.VerifySignIn(req, "other-state"); !errors.Is(err, ErrStateMismatch) {
+		t.Errorf("substituted state error = %v, want %v", err, ErrStateMismatch)
+	}
+	got, err := m.VerifySignIn(req, pending.State)
+	if err != nil {
+		t.Fatalf("VerifySignIn failed: %v", err)
+	}
+	if got.Nonce != pending.Nonce || got.SignedIn() {
+		t.Errorf("sign-in = %+v, want the pending sign-in", got)
+	}
+
+	w = httptest.NewRecorder()
+	if _, err := m.SignIn(w, "alice", "Alice"); err != nil {
+		t.Fatalf("SignIn failed: %v", err)
+	}
+	req = withCookies(w)
+	account, err := m.LoadAccount(req)
+	if err != nil {
+		t.Fatalf("LoadAccount failed: %v", err)
+	}
+	if !account.SignedIn() || account.Subject != "alice" || account.Name != "Alice" {
+		t.Errorf("account = %+v, want alice signed in", account)
+	}
+	if _, err := m.VerifySignIn(req, ""); !errors.Is(err, ErrStateMismatch) {
+		t.Errorf("VerifySignIn on a signed-in session error = %v, want %v", err, ErrStateMismatch)
+	}
+}
+
+func TestAccountRejectsDeviceSession(t *testing.T) {
+	m := NewManager([]byte("secret"), time.Minute, true)
+	w := httptest.NewRecorder()
+	if _, err := m.Start(w, "device-123"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// A verification session cookie copied into the account cookie is not
+	// signed with the account key
+	req := httptest.NewRequest(http.MethodGet, "/my/devices", nil)
+	req.AddCookie(&http.Cookie{Name: AccountCookieName, Value: w.Result().Cookies()[0].Value})
+	if _, err := m.LoadAccount(req); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("LoadAccount error = %v, want %v", err, ErrInvalidSession)
+	}
+}
@@ -0,0 +1,212 @@
+// Package session binds the browser verification flow to a signed cookie
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CookieName is the cookie carrying the verification session
+const CookieName = "device_session"
+
+// DefaultTTL bounds how long a user has to finish signing in
+const DefaultTTL = 10 * time.Minute
+
+var (
+	// ErrNoSession indicates the request carries no session cookie
+	ErrNoSession = errors.New("no verification session")
+
+	// ErrInvalidSession indicates a malformed or tampered session cookie
+	ErrInvalidSession = errors.New("invalid verification session")
+
+	// ErrSessionExpired indicates the session cookie has expired
+	ErrSessionExpired = errors.New("verification session expired")
+
+	// ErrStateMismatch indicates the OAuth state does not belong to the session
+	ErrStateMismatch = errors.New("state does not match verification session")
+
+	// ErrSessionFinished indicates the session already completed an authorization
+	ErrSessionFinished = errors.New("verification session already finished")
+)
+
+// Session ties an OAuth state value to the device code being verified
+type Session struct {
+	State      string
+	DeviceCode string
+	ExpiresAt  time.Time
+	Finished   bool // The callback was processed; only status lookups remain
+}
+
+// payload is the signed cookie encoding of a session
+type payload struct {
+	State      string `json:"s"`
+	DeviceCode string `json:"d"`
+	Expiry     int64  `json:"e"` // Unix seconds
+	Finished   bool   `json:"f,omitempty"`
+}
+
+// Manager issues and validates session cookies
+type Manager struct {
+	key        []byte
+	accountKey []byte // Signs account sessions, see account.go
+	ttl        time.Duration
+	secure     bool
+}
+
+// NewManager creates a session manager signing cookies with a key derived
+// from secret. Secure cookies are only sent over HTTPS.
+func NewManager(secret []byte, ttl time.Duration, secure bool) *Manager {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	// Derive a dedicated key so a secret shared with CSRF tokens never
+	// produces signatures valid in both places
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("oauth2-device-proxy session"))
+	accountMAC := hmac.New(sha256.New, secret)
+	accountMAC.Write([]byte("oauth2-device-proxy account session"))
+
+	return &Manager{
+		key:        mac.Sum(nil),
+		accountKey: accountMAC.Sum(nil),
+		ttl:        ttl,
+		secure:     secure,
+	}
+}
+
+// Start creates a session for the device code with a fresh state value and
+// sets its cookie on the response
+func (m *Manager) Start(w http.ResponseWriter, deviceCode string) (*Session, error) {
+	state := make([]byte, 32)
+	if _, err := rand.Read(state); err != nil {
+		return nil, fmt.Errorf("generating state: %w", err)
+	}
+
+	sess := &Session{
+		State:      base64.RawURLEncoding.EncodeToString(state),
+		DeviceCode: deviceCode,
+		ExpiresAt:  time.Now().Add(m.ttl).Truncate(time.Second),
+	}
+	if err := m.save(w, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// Finish marks the session as having completed its authorization. The cookie
+// keeps its original expiry so the pages can still show the flow's status,
+// but Verify rejects it from then on.
+func (m *Manager) Finish(w http.ResponseWriter, sess *Session) error {
+	finished := *sess
+	finished.Finished = true
+	return m.save(w, &finished)
+}
+
+// save signs the session and sets its cookie on the response
+func (m *Manager) save(w http.ResponseWriter, sess *Session) error {
+	data, err := json.Marshal(payload{
+		State:      sess.State,
+		DeviceCode: sess.DeviceCode,
+		Expiry:     sess.ExpiresAt.Unix(),
+		Finished:   sess.Finished,
+	})
+	if err != nil {
+		return fmt.Errorf("encoding session: %w", err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+
+	maxAge := int(math.Ceil(time.Until(sess.ExpiresAt).Seconds()))
+	if maxAge <= 0 {
+		maxAge = -1
+	}
+	http.SetCookie(w, m.cookie(encoded+"."+m.sign(encoded), maxAge))
+	return nil
+}
+
+// Load returns the session carried by the request after checking its
+// signature and expiry
+func (m *Manager) Load(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, ErrNoSession
+	}
+
+	encoded, sig, ok := strings.Cut(cookie.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(m.sign(encoded))) {
+		return nil, ErrInvalidSession
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidSession
+	}
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil || p.State == "" || p.DeviceCode == "" {
+		return nil, ErrInvalidSession
+	}
+
+	sess := &Session{State: p.State, DeviceCode: p.DeviceCode, ExpiresAt: time.Unix(p.Expiry, 0), Finished: p.Finished}
+	if time.Now().After(sess.ExpiresAt) {
+		return nil, ErrSessionExpired
+	}
+	return sess, nil
+}
+
+// Verify loads the session and checks that the OAuth state returned by the
+// authorization server is the one issued for it. Finished sessions never verify.
+func (m *Manager) Verify(r *http.Request, state string) (*Session, error) {
+	sess, err := m.Load(r)
+	if err != nil {
+		return nil, err
+	}
+	if sess.Finished {
+		return nil, ErrSessionFinished
+	}
+	if subtle.ConstantTimeCompare([]byte(state), []byte(sess.State)) != 1 {
+		return nil, ErrStateMismatch
+	}
+	return sess, nil
+}
+
+// Clear removes the session cookie once the flow is finished
+func (m *Manager) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, m.cookie("", -1))
+}
+
+// cookie builds the session cookie, scoped to the verification pages. Lax
+// same-site mode still sends it on the top-level redirect back from the
+// authorization server.
+func (m *Manager) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    value,
+		Path:     "/device",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   m.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// sign returns the encoded HMAC of the session payload
+func (m *Manager) sign(encoded string) string {
+	return signWith(m.key, encoded)
+}
+
+// signWith returns the encoded HMAC of a payload under key
+func signWith(key []byte, encoded string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,157 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTrip starts a session and returns a request carrying its cookie
+func roundTrip(t *testing.T, m *Manager, deviceCode string) (*Session, *http.Request) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	sess, err := m.Start(w, deviceCode)
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/device/complete", nil)
+	for _, c := range w.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	return sess, req
+}
+
+func TestManager(t *testing.T) {
+	m := NewManager([]byte("secret"), time.Minute, true)
+	sess, req := roundTrip(t, m, "device-123")
+
+	if sess.State == "" || strings.Contains(sess.State, "device-123") {
+		t.Errorf("state = %q, want an opaque random value", sess.State)
+	}
+
+	got, err := m.Verify(req, sess.State)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if got.DeviceCode != "device-123" {
+		t.Errorf("device code = %q, want device-123", got.DeviceCode)
+	}
+
+	if _, err := m.Verify(req, "other-state"); !errors.Is(err, ErrStateMismatch) {
+		t.Errorf("substituted state error = %v, want %v", err, ErrStateMismatch)
+	}
+}
+
+func TestManagerRejects(t *testing.T) {
+	m := NewManager([]byte("secret"), time.Minute, true)
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		wantErr error
+	}{
+		{
+			name: "missing cookie",
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/device/complete", nil)
+			},
+			wantErr: ErrNoSession,
+		},
+		{
+			name: "tampered payload",
+			request: func() *http.Request {
+				_, req := roundTrip(t, m, "device-123")
+				c, _ := req.Cookie(CookieName)
+				req = httptest.NewRequest(http.MethodGet, "/device/complete", nil)
+				req.AddCookie(&http.Cookie{Name: CookieName, Value: "x" + c.Value})
+				return req
+			},
+			wantErr: ErrInvalidSession,
+		},
+		{
+			name: "signed with another secret",
+			request: func() *http.Request {
+				_, req := roundTrip(t, NewManager([]byte("other"), time.Minute, true), "device-123")
+				return req
+			},
+			wantErr: ErrInvalidSession,
+		},
+		{
+			name: "expired",
+			request: func() *http.Request {
+				_, req := roundTrip(t, &Manager{key: m.key, ttl: -time.Minute}, "device-123")
+				return req
+			},
+			wantErr: ErrSessionExpired,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Load(tt.request()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Load error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManagerCookie(t *testing.T) {
+	m := NewManager([]byte("secret"), time.Minute, true)
+
+	w := httptest.NewRecorder()
+	if _, err := m.Start(w, "device-123"); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	c := w.Result().Cookies()[0]
+	if !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteLaxMode || c.Path != "/device" || c.MaxAge != 60 {
+		t.Errorf("cookie = %+v, want HttpOnly, Secure, Lax, /device, 60s", c)
+	}
+
+	w = httptest.NewRecorder()
+	m.Clear(w)
+	if c := w.Result().Cookies()[0]; c.Value != "" || c.MaxAge >= 0 {
+		t.Errorf("cleared cookie = %+v", c)
+	}
+}
+
+func TestManagerFinish(t *testing.T) {
+	m := NewManager([]byte("secret"), time.Minute, false)
+
+	w := httptest.NewRecorder()
+	sess, err := m.Start(w, "device-123")
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	if err := m.Finish(w, sess); err != nil {
+		t.Fatalf("Finish failed: %v", err)
+	}
+	cookie := w.Result().Cookies()[0]
+	if cookie.MaxAge <= 0 || cookie.MaxAge > 60 {
+		t.Errorf("finished cookie MaxAge = %d, want remaining lifetime", cookie.MaxAge)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/device/status", nil)
+	r.AddCookie(cookie)
+
+	loaded, err := m.Load(r)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !loaded.Finished || loaded.DeviceCode != "device-123" || !loaded.ExpiresAt.Equal(sess.ExpiresAt) {
+		t.Errorf("loaded session = %+v, want finished copy of %+v", loaded, sess)
+	}
+	if sess.Finished {
+		t.Error("Finish modified the original session")
+	}
+
+	// The callback state cannot be replayed once the session is finished
+	if _, err := m.Verify(r, sess.State); !errors.Is(err, ErrSessionFinished) {
+		t.Errorf("Verify error = %v, want %v", err, ErrSessionFinished)
+	}
+}
@@ -0,0 +1,119 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis keys, mirrored by keyspace.Patterns
+const (
+	clientsKey   = "stats:clients"
+	clientPrefix = "stats:client:"
+)
+
+// trackClient adds a client to the tracked set unless MaxClients are already
+// tracked, returning 1 if the client is tracked
+var trackClient = redis.NewScript(`
+if redis.call('SISMEMBER', KEYS[1], ARGV[1]) == 1 then
+	return 1
+end
+if redis.call('SCARD', KEYS[1]) >= tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('SADD', KEYS[1], ARGV[1])
+return 1
+`)
+
+// RedisStore keeps counters in Redis hashes shared by all proxy instances
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// RedisOption configures the Redis stats store
+type RedisOption func(*RedisStore)
+
+// WithKeyPrefix namespaces the stats keys so that several proxy environments
+// can share one Redis instance
+func WithKeyPrefix(prefix string) RedisOption {
+	return func(s *RedisStore) {
+		s.prefix = prefix
+	}
+}
+
+// NewRedisStore creates a Redis-backed stats store
+func NewRedisStore(client *redis.Client, opts ...RedisOption) *RedisStore {
+	s := &RedisStore{client: client}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// key namespaces a stats key
+func (s *RedisStore) key(parts ...string) string {
+	key := s.prefix
+	for _, part := range parts {
+		key += part
+	}
+	return key
+}
+
+// Add implements Store
+func (s *RedisStore) Add(ctx context.Context, clientID string, counts map[string]int64) error {
+	tracked, err := trackClient.Run(ctx, s.client, []string{s.key(clientsKey)}, clientID, MaxClients).Int()
+	if err != nil {
+		return fmt.Errorf("tracking client: %w", err)
+	}
+	if tracked == 0 {
+		clientID = OtherClients
+		if err := s.client.SAdd(ctx, s.key(clientsKey), clientID).Err(); err != nil {
+			return fmt.Errorf("tracking client: %w", err)
+		}
+	}
+
+	pipe := s.client.TxPipeline()
+	for field, n := range counts {
+		pipe.HIncrBy(ctx, s.key(clientPrefix, clientID), field, n)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("incrementing counters: %w", err)
+	}
+	return nil
+}
+
+// Load implements Store
+func (s *RedisStore) Load(ctx context.Context) (map[string]map[string]int64, error) {
+	clients, err := s.client.SMembers(ctx, s.key(clientsKey)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("listing clients: %w", err)
+	}
+
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(clients))
+	for i, clientID := range clients {
+		cmds[i] = pipe.HGetAll(ctx, s.key(clientPrefix, clientID))
+	}
+	if len(clients) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("reading counters: %w", err)
+		}
+	}
+
+	out := make(map[string]map[string]int64, len(clients))
+	for i, clientID := range clients {
+		counts := make(map[string]int64)
+		for field, value := range cmds[i].Val() {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing counter %s of client %s: %w", field, clientID, err)
+			}
+			counts[field] = n
+		}
+		out[clientID] = counts
+	}
+	return out, nil
+}
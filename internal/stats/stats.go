@@ -0,0 +1,269 @@
+// Package stats aggregates per-client device flow conversion metrics from
+// lifecycle events, helping operators tune code expiry and the verification UX
+package stats
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+)
+
+// Counter fields kept per client
+const (
+	fieldIssued    = "issued"
+	fieldVerified  = "verified"
+	fieldCompleted = "completed"
+	fieldDenied    = "denied"
+	fieldFailed    = "failed"
+	fieldExpired   = "expired"
+
+	// fieldAuthorizeMillis sums the time to authorize of fieldTimed completions.
+	// Codes issued before issuance times were recorded are not timed.
+	fieldAuthorizeMillis = "authorize_ms"
+	fieldTimed           = "timed"
+)
+
+// MaxClients bounds the clients tracked individually. Client IDs come from
+// unauthenticated requests, so further clients are counted under OtherClients.
+const MaxClients = 1000
+
+// OtherClients collects the counts of clients beyond MaxClients
+const OtherClients = "(other)"
+
+// DefaultQueueSize is the number of events buffered before new ones are dropped
+const DefaultQueueSize = 1024
+
+// Store persists per-client counters
+type Store interface {
+	// Add increments the client's counters
+	Add(ctx context.Context, clientID string, counts map[string]int64) error
+
+	// Load returns the counters of every tracked client
+	Load(ctx context.Context) (map[string]map[string]int64, error)
+}
+
+// ClientStats summarizes the device flows of one client
+type ClientStats struct {
+	ClientID  string `json:"client_id"`
+	Issued    int64  `json:"issued"`
+	Verified  int64  `json:"verified"`  // User codes entered successfully
+	Completed int64  `json:"completed"` // Tokens issued to the device
+	Denied    int64  `json:"denied"`
+	Failed    int64  `json:"failed"`
+	Expired   int64  `json:"expired"` // Codes used after expiring, not every expiry
+
+	// ConversionRate is the share of issued codes that were completed
+	ConversionRate float64 `json:"conversion_rate"`
+
+	// AvgTimeToAuthorize is the mean seconds from issuance to completion
+	AvgTimeToAuthorize float64 `json:"avg_time_to_authorize_seconds"`
+}
+
+// Recorder aggregates lifecycle events into per-client counters. It is an
+// events.Emitter, updating the store in the background so that a slow store
+// cannot stall the device flow.
+type Recorder struct {
+	store Store
+	queue chan events.Event
+	done  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+// NewRecorder creates a recorder and starts its worker
+func NewRecorder(store Store) *Recorder {
+	r := &Recorder{
+		store: store,
+		queue: make(chan events.Event, DefaultQueueSize),
+		done:  make(chan struct{}),
+	}
+
+	r.wg.Add(1)
+	go r.run()
+
+	return r
+}
+
+// Emit implements events.Emitter, dropping the event if the queue is full
+func (r *Recorder) Emit(ctx context.Context, event events.Event) {
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+
+	select {
+	case r.queue <- event:
+	default:
+		log.Printf("Warning: stats queue full, dropping %s event %s", event.Type, event.ID)
+	}
+}
+
+// Close stops accepting events and waits for queued ones to be recorded or ctx to expire
+func (r *Recorder) Close(ctx context.Context) error {
+	r.once.Do(func() { close(r.done) })
+
+	finished := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for stats to be recorded: %w", ctx.Err())
+	}
+}
+
+// run records queued events until the recorder is closed and the queue drained
+func (r *Recorder) run() {
+	defer r.wg.Done()
+
+	for {
+		select {
+		case event := <-r.queue:
+			r.record(event)
+		case <-r.done:
+			for {
+				select {
+				case event := <-r.queue:
+					r.record(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// record adds an event to its client's counters
+func (r *Recorder) record(event events.Event) {
+	counts := countsFor(event)
+	if counts == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.store.Add(ctx, event.ClientID, counts); err != nil {
+		log.Printf("Warning: recording stats for %s event %s: %v", event.Type, event.ID, err)
+	}
+}
+
+// countsFor maps a lifecycle event to the counters it increments
+func countsFor(event events.Event) map[string]int64 {
+	switch event.Type {
+	case events.TypeDeviceCodeCreated:
+		return map[string]int64{fieldIssued: 1}
+	case events.TypeUserVerified:
+		return map[string]int64{fieldVerified: 1}
+	case events.TypeAuthorizationDenied:
+		return map[string]int64{fieldDenied: 1}
+	case events.TypeAuthorizationFailed:
+		return map[string]int64{fieldFailed: 1}
+	case events.TypeCodeExpired:
+		return map[string]int64{fieldExpired: 1}
+	case events.TypeAuthorizationCompleted:
+		counts := map[string]int64{fieldCompleted: 1}
+		if issuedAt, ok := event.Data["issued_at"].(time.Time); ok && !issuedAt.IsZero() {
+			counts[fieldAuthorizeMillis] = event.Timestamp.Sub(issuedAt).Milliseconds()
+			counts[fieldTimed] = 1
+		}
+		return counts
+	default:
+		return nil
+	}
+}
+
+// Stats returns the stats of every tracked client, ordered by client ID
+func (r *Recorder) Stats(ctx context.Context) ([]ClientStats, error) {
+	counters, err := r.store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]ClientStats, 0, len(counters))
+	for clientID, counts := range counters {
+		stats = append(stats, summarize(clientID, counts))
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ClientID < stats[j].ClientID })
+	return stats, nil
+}
+
+// summarize derives a client's stats from its counters
+func summarize(clientID string, counts map[string]int64) ClientStats {
+	s := ClientStats{
+		ClientID:  clientID,
+		Issued:    counts[fieldIssued],
+		Verified:  counts[fieldVerified],
+		Completed: counts[fieldCompleted],
+		Denied:    counts[fieldDenied],
+		Failed:    counts[fieldFailed],
+		Expired:   counts[fieldExpired],
+	}
+	if s.Issued > 0 {
+		s.ConversionRate = float64(s.Completed) / float64(s.Issued)
+	}
+	if timed := counts[fieldTimed]; timed > 0 {
+		s.AvgTimeToAuthorize = float64(counts[fieldAuthorizeMillis]) / float64(timed) / 1000
+	}
+	return s
+}
+
+// MemoryStore keeps counters in process memory. Each proxy instance counts
+// only its own traffic, so deployments with several replicas should use
+// RedisStore.
+type MemoryStore struct {
+	mu      sync.Mutex
+	clients map[string]map[string]int64
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{clients: make(map[string]map[string]int64)}
+}
+
+// Add implements Store
+func (m *MemoryStore) Add(ctx context.Context, clientID string, counts map[string]int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	client, ok := m.clients[clientID]
+	if !ok {
+		if len(m.clients) >= MaxClients {
+			clientID = OtherClients
+			client = m.clients[clientID]
+		}
+		if client == nil {
+			client = make(map[string]int64)
+			m.clients[clientID] = client
+		}
+	}
+	for field, n := range counts {
+		client[field] += n
+	}
+	return nil
+}
+
+// Load implements Store
+func (m *MemoryStore) Load(ctx context.Context) (map[string]map[string]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make(map[string]map[string]int64, len(m.clients))
+	for clientID, counts := range m.clients {
+		copied := make(map[string]int64, len(counts))
+		for field, n := range counts {
+			copied[field] = n
+		}
+		out[clientID] = copied
+	}
+	return out, nil
+}
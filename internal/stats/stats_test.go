@@ -0,0 +1,84 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/events"
+)
+
+// event creates a lifecycle event for the client
+func event(eventType events.Type, clientID string) events.Event {
+	e := events.New(eventType)
+	e.ClientID = clientID
+	return e
+}
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+	recorder := NewRecorder(NewMemoryStore())
+
+	completed := event(events.TypeAuthorizationCompleted, "tv")
+	completed.Data = map[string]any{"issued_at": completed.Timestamp.Add(-30 * time.Second)}
+	untimed := event(events.TypeAuthorizationCompleted, "tv")
+
+	for _, e := range []events.Event{
+		event(events.TypeDeviceCodeCreated, "tv"),
+		event(events.TypeDeviceCodeCreated, "tv"),
+		event(events.TypeDeviceCodeCreated, "tv"),
+		event(events.TypeDeviceCodeCreated, "tv"),
+		event(events.TypeUserVerified, "tv"),
+		event(events.TypeUserVerified, "tv"),
+		completed,
+		untimed,
+		event(events.TypeAuthorizationDenied, "tv"),
+		event(events.TypeCodeExpired, "tv"),
+		event(events.TypeDeviceCodeCreated, "printer"),
+		event(events.TypePollAnomaly, "printer"),
+	} {
+		recorder.Emit(ctx, e)
+	}
+	if err := recorder.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	got, err := recorder.Stats(ctx)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	want := []ClientStats{
+		{ClientID: "printer", Issued: 1},
+		{
+			ClientID: "tv", Issued: 4, Verified: 2, Completed: 2, Denied: 1, Expired: 1,
+			ConversionRate: 0.5, AvgTimeToAuthorize: 30,
+		},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d clients, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("client %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMemoryStoreClientLimit(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	for i := 0; i < MaxClients+5; i++ {
+		if err := store.Add(ctx, fmt.Sprintf("client-%d", i), map[string]int64{fieldIssued: 1}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	counters, _ := store.Load(ctx)
+	if len(counters) != MaxClients+1 {
+		t.Errorf("tracked %d clients, want %d", len(counters), MaxClients+1)
+	}
+	if got := counters[OtherClients][fieldIssued]; got != 5 {
+		t.Errorf("other clients issued = %d, want 5", got)
+	}
+}
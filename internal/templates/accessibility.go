@@ -0,0 +1,44 @@
+package templates
+
+import (
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
+)
+
+// Accessibility adapts the hosted pages for users with low vision or who rely
+// on screen readers. The pages carry labels, live regions and focus handling
+// regardless; these toggles change their presentation for all users.
+type Accessibility struct {
+	HighContrast bool // Black-on-white colors with heavy focus outlines
+	LargeCode    bool // Enlarged user code display and entry field
+	SpellOutCode bool // Screen readers announce user codes one character at a time
+}
+
+// Classes returns the CSS classes enabling the toggles on the page root
+func (a Accessibility) Classes() string {
+	var classes []string
+	if a.HighContrast {
+		classes = append(classes, "high-contrast")
+	}
+	if a.LargeCode {
+		classes = append(classes, "large-code")
+	}
+	return strings.Join(classes, " ")
+}
+
+// codeGroups splits a user code into the groups it is displayed in, such as
+// "BCDF" and "GHJK" for BCDF-GHJK
+func codeGroups(code string) []string {
+	return strings.FieldsFunc(code, validation.IsCodeSeparator)
+}
+
+// spokenCode spells out a user code for screen readers, separating characters
+// with spaces and groups with commas so each is read and paused on in turn
+func spokenCode(code string) string {
+	groups := codeGroups(code)
+	for i, group := range groups {
+		groups[i] = strings.Join(strings.Split(group, ""), " ")
+	}
+	return strings.Join(groups, ", ")
+}
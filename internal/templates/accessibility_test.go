@@ -0,0 +1,84 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSpokenCode(t *testing.T) {
+	tests := map[string]string{
+		"BCDF-GHJK": "B C D F, G H J K",
+		"BCDFGHJK":  "B C D F G H J K",
+		"":          "",
+	}
+	for code, want := range tests {
+		if got := spokenCode(code); got != want {
+			t.Errorf("spokenCode(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestRenderAccessibility(t *testing.T) {
+	templates := setupTemplates(t)
+	templates.SetBrand(Brand{
+		PrimaryColor:  "#0b5fff",
+		Accessibility: Accessibility{HighContrast: true, LargeCode: true, SpellOutCode: true},
+	})
+
+	mock := newMockResponseWriter()
+	if err := templates.RenderConsent(mock, ConsentData{ClientName: "Lobby Kiosk", UserCode: "BCDF-GHJK"}); err != nil {
+		t.Fatalf("RenderConsent() error = %v", err)
+	}
+	wantContains := []string{
+		`<html lang="en" class="high-contrast large-code">`,
+		`<span class="code-group">BCDF</span>`,
+		`<span class="visually-hidden">B C D F, G H J K</span>`,
+		`href="#main"`,
+	}
+	if !mock.Contains(wantContains...) {
+		t.Errorf("response missing required content.\ngot: %s", mock.Written())
+	}
+	// Brand colors would undo the high-contrast palette
+	if strings.Contains(mock.Written(), "#0b5fff") {
+		t.Error("high-contrast page includes the brand color")
+	}
+
+	mock = newMockResponseWriter()
+	if err := templates.RenderVerify(mock, VerifyData{Error: "Invalid code"}); err != nil {
+		t.Fatalf("RenderVerify() error = %v", err)
+	}
+	wantContains = []string{
+		`<label for="code"`,
+		`aria-describedby="code-error code-hint"`,
+		`aria-invalid="true"`,
+		`role="alert"`,
+	}
+	if !mock.Contains(wantContains...) {
+		t.Errorf("response missing required content.\ngot: %s", mock.Written())
+	}
+}
+
+func TestRenderCodeFormat(t *testing.T) {
+	templates := setupTemplates(t)
+	templates.SetBrand(Brand{
+		CodeFormat:    "XXXX XXXX",
+		Accessibility: Accessibility{SpellOutCode: true},
+	})
+
+	mock := newMockResponseWriter()
+	if err := templates.RenderConsent(mock, ConsentData{ClientName: "Lobby Kiosk", UserCode: "BCDF-GHJK"}); err != nil {
+		t.Fatalf("RenderConsent() error = %v", err)
+	}
+	if !mock.Contains(`<span class="code-group">BCDF</span><span class="code-separator"> </span><span class="code-group">GHJK</span>`,
+		`<span class="visually-hidden">B C D F, G H J K</span>`) {
+		t.Errorf("consent page does not lay out the code in the display format.\ngot: %s", mock.Written())
+	}
+
+	mock = newMockResponseWriter()
+	if err := templates.RenderVerify(mock, VerifyData{PrefilledCode: "bcdf-ghjk"}); err != nil {
+		t.Fatalf("RenderVerify() error = %v", err)
+	}
+	if !mock.Contains(`value="BCDF GHJK"`, `placeholder="XXXX XXXX"`, `data-separator=" "`) {
+		t.Errorf("verify page does not use the display format.\ngot: %s", mock.Written())
+	}
+}
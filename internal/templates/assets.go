@@ -0,0 +1,139 @@
+package templates
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+//go:embed assets
+var assetFiles embed.FS
+
+// AssetPrefix is the URL path under which static assets are served
+const AssetPrefix = "/assets/"
+
+// asset is an embedded static file
+type asset struct {
+	content     []byte
+	contentType string
+	etag        string
+}
+
+// assetSet holds the embedded assets by name and by fingerprinted name
+type assetSet struct {
+	byName        map[string]*asset // e.g. "style.css"
+	byFingerprint map[string]*asset // e.g. "style.3f2a9c1b.css"
+	paths         map[string]string // Name to fingerprinted URL path
+}
+
+// staticAssets is loaded once from the embedded files
+var staticAssets = mustLoadAssets()
+
+// mustLoadAssets reads and fingerprints the embedded assets
+func mustLoadAssets() *assetSet {
+	set := &assetSet{
+		byName:        make(map[string]*asset),
+		byFingerprint: make(map[string]*asset),
+		paths:         make(map[string]string),
+	}
+
+	err := fs.WalkDir(assetFiles, "assets", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := assetFiles.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])[:8]
+		name := strings.TrimPrefix(p, "assets/")
+		ext := path.Ext(name)
+		fingerprinted := strings.TrimSuffix(name, ext) + "." + hash + ext
+
+		contentType := mime.TypeByExtension(ext)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		a := &asset{content: content, contentType: contentType, etag: `"` + hash + `"`}
+		set.byName[name] = a
+		set.byFingerprint[fingerprinted] = a
+		set.paths[name] = AssetPrefix + fingerprinted
+		return nil
+	})
+	if err != nil {
+		panic(fmt.Sprintf("templates: loading embedded assets: %v", err))
+	}
+	return set
+}
+
+// assetPath returns the fingerprinted URL path of an asset for use in templates.
+// Unknown names fail template execution rather than render a broken link.
+func assetPath(name string) (string, error) {
+	p, ok := staticAssets.paths[name]
+	if !ok {
+		return "", fmt.Errorf("unknown asset %q", name)
+	}
+	return p, nil
+}
+
+// templateFuncs are available to all page templates
+var templateFuncs = template.FuncMap{
+	"asset":       assetPath,
+	"codeGroups":  codeGroups,
+	"spokenCode":  spokenCode,
+	"codeCharset": codeCharset,
+	"codePattern": codePattern,
+}
+
+// AssetHandler serves the embedded assets under AssetPrefix. Fingerprinted
+// names change with their content, so they are cached indefinitely; plain
+// names are served for external references and must be revalidated.
+func AssetHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, AssetPrefix)
+		cacheControl := "public, max-age=31536000, immutable"
+		a, ok := staticAssets.byFingerprint[name]
+		if !ok {
+			a, ok = staticAssets.byName[name]
+			cacheControl = "public, no-cache"
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", a.contentType)
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", a.etag)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(a.content))
+	})
+}
+
+// AssetFiles returns the embedded assets keyed by fingerprinted URL path, for
+// copying them next to pages rendered outside the server
+func AssetFiles() map[string][]byte {
+	files := make(map[string][]byte, len(staticAssets.paths))
+	for name, p := range staticAssets.paths {
+		files[p] = staticAssets.byName[name].content
+	}
+	return files
+}
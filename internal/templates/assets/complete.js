@@ -0,0 +1,2 @@
+// Replace the current history entry to prevent back button navigation
+window.history.replaceState({}, '', '/device/complete');
@@ -0,0 +1,8 @@
+// Move focus to the element marked as the page's starting point, such as an
+// error heading, so screen readers announce it without the user searching
+document.addEventListener('DOMContentLoaded', function() {
+    const target = document.querySelector('[data-autofocus]');
+    if (target) {
+        target.focus();
+    }
+});
@@ -0,0 +1,38 @@
+document.addEventListener('DOMContentLoaded', function() {
+    const el = document.getElementById('flow-status');
+    if (!el) {
+        return;
+    }
+
+    const messages = {
+        authorized: 'This device has been authorized. You may close this window.',
+        denied: 'Access was denied for this device. You may close this window.',
+        failed: 'Authorization could not be completed. You may close this window.',
+        expired: 'This code has expired. Please request a new code on your device.'
+    };
+    const url = '/device/status?code=' + encodeURIComponent(el.dataset.userCode);
+
+    // Poll until the flow finishes, which may happen in another tab
+    function poll() {
+        fetch(url, { credentials: 'same-origin', cache: 'no-store' })
+            .then(function(resp) { return resp.ok ? resp.json() : null; })
+            .then(function(body) {
+                if (!body) {
+                    return; // No session for this code, stop polling
+                }
+                if (!messages[body.status]) {
+                    setTimeout(poll, 3000);
+                    return;
+                }
+                el.textContent = messages[body.status];
+                el.hidden = false;
+                document.querySelectorAll('[data-hide-when-done]').forEach(function(node) {
+                    node.hidden = true;
+                });
+            })
+            .catch(function() {
+                setTimeout(poll, 3000);
+            });
+    }
+    setTimeout(poll, 3000);
+});
@@ -0,0 +1,161 @@
+// Split code entry into two four-character fields. Without script the single
+// code field remains and the server accepts any case and separator.
+document.addEventListener('DOMContentLoaded', function() {
+    const input = document.getElementById('code');
+    if (!input) {
+        return;
+    }
+
+    const GROUP_SIZE = 4;
+    const charset = input.dataset.charset || '';
+    const form = input.form;
+    const entryError = document.getElementById('code-entry-error');
+    const letters = charset.replace(/[^A-Z]/g, '');
+
+    // clean uppercases a value and drops separators and characters that can
+    // never appear in a code
+    function clean(value) {
+        return value.toUpperCase().split('').filter(function(c) {
+            return charset.indexOf(c) !== -1;
+        }).join('');
+    }
+
+    function segment(label) {
+        const field = document.createElement('input');
+        field.type = 'text';
+        field.className = 'code-segment';
+        field.autocomplete = 'off';
+        field.spellcheck = false;
+        field.setAttribute('autocapitalize', 'characters');
+        field.setAttribute('aria-label', label);
+        field.setAttribute('aria-describedby', input.getAttribute('aria-describedby') || '');
+        if (input.getAttribute('aria-invalid')) {
+            field.setAttribute('aria-invalid', 'true');
+        }
+        return field;
+    }
+
+    const first = segment('First four characters of the code');
+    const second = segment('Last four characters of the code');
+    second.maxLength = GROUP_SIZE; // The first field may overflow into this one
+    const separator = document.createElement('span');
+    separator.className = 'code-separator';
+    separator.setAttribute('aria-hidden', 'true');
+    separator.textContent = input.dataset.separator ?? '-';
+
+    const group = document.createElement('div');
+    group.className = 'code-segments';
+    group.setAttribute('role', 'group');
+    group.setAttribute('aria-label', 'Device code');
+    group.append(first, separator, second);
+
+    // The original field keeps carrying the code to the server
+    input.type = 'hidden';
+    input.removeAttribute('required');
+    input.removeAttribute('pattern');
+    input.insertAdjacentElement('afterend', group);
+    const label = document.querySelector('label[for="code"]');
+    if (label) {
+        label.htmlFor = '';
+    }
+
+    // fill distributes a code over both fields, returning the cleaned code
+    function fill(value) {
+        const code = clean(value).slice(0, GROUP_SIZE * 2);
+        first.value = code.slice(0, GROUP_SIZE);
+        second.value = code.slice(GROUP_SIZE);
+        sync();
+        return code;
+    }
+
+    function sync() {
+        input.value = second.value ? first.value + '-' + second.value : first.value;
+        clearError();
+    }
+
+    function showError(message) {
+        entryError.textContent = message;
+        entryError.hidden = false;
+        first.setAttribute('aria-invalid', 'true');
+        second.setAttribute('aria-invalid', 'true');
+    }
+
+    // warnRejected explains characters dropped from what was typed
+    function warnRejected(value) {
+        const rejected = value.toUpperCase().replace(/[\s-]/g, '').split('').filter(function(c) {
+            return charset.indexOf(c) === -1;
+        });
+        if (rejected.length > 0) {
+            showError('"' + rejected[0] + '" is not used in device codes. Codes only contain the letters ' + letters + '.');
+        }
+    }
+
+    function clearError() {
+        if (entryError.hidden) {
+            return;
+        }
+        entryError.hidden = true;
+        entryError.textContent = '';
+        first.removeAttribute('aria-invalid');
+        second.removeAttribute('aria-invalid');
+    }
+
+    first.addEventListener('input', function() {
+        const typed = first.value;
+        // Typing past the first group, or autofill of the whole code, spills over
+        if (clean(typed).length > GROUP_SIZE) {
+            const code = fill(typed + second.value);
+            (code.length > GROUP_SIZE ? second : first).focus();
+        } else {
+            first.value = clean(typed);
+            sync();
+            if (first.value.length === GROUP_SIZE) {
+                second.focus();
+            }
+        }
+        warnRejected(typed);
+    });
+
+    second.addEventListener('input', function() {
+        const typed = second.value;
+        second.value = clean(typed);
+        sync();
+        warnRejected(typed);
+    });
+
+    // Backspace in an empty second field returns to the first
+    second.addEventListener('keydown', function(e) {
+        if (e.key === 'Backspace' && second.value === '') {
+            first.focus();
+        }
+    });
+
+    // Pasted codes fill both fields whichever one has focus
+    [first, second].forEach(function(field) {
+        field.addEventListener('paste', function(e) {
+            e.preventDefault();
+            const pasted = (e.clipboardData || window.clipboardData).getData('text');
+            const code = fill(pasted);
+            warnRejected(pasted);
+            (code.length >= GROUP_SIZE ? second : first).focus();
+        });
+    });
+
+    form.addEventListener('submit', function(e) {
+        const code = clean(first.value + second.value);
+        if (code.length !== GROUP_SIZE * 2) {
+            e.preventDefault();
+            showError('Enter all ' + GROUP_SIZE * 2 + ' letters of the code shown on your device.');
+            (first.value.length < GROUP_SIZE ? first : second).focus();
+            return;
+        }
+        input.value = code.slice(0, GROUP_SIZE) + '-' + code.slice(GROUP_SIZE);
+    });
+
+    fill(input.value);
+
+    // Focus the entry if no QR code is shown or the last attempt failed
+    if (!document.querySelector('.qr-code') || input.hasAttribute('data-autofocus')) {
+        first.focus();
+    }
+});
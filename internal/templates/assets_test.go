@@ -0,0 +1,131 @@
+package templates
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAssetPath(t *testing.T) {
+	p, err := assetPath("style.css")
+	if err != nil {
+		t.Fatalf("assetPath() error = %v", err)
+	}
+	if !regexp.MustCompile(`^/assets/style\.[0-9a-f]{8}\.css$`).MatchString(p) {
+		t.Errorf("assetPath() = %q, want fingerprinted path", p)
+	}
+
+	if _, err := assetPath("missing.css"); err == nil {
+		t.Error("assetPath() accepted an unknown asset")
+	}
+}
+
+func TestAssetHandler(t *testing.T) {
+	fingerprinted, _ := assetPath("style.css")
+	handler := AssetHandler()
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		header      http.Header
+		wantStatus  int
+		wantCache   string
+		wantType    string
+		wantContent string
+	}{
+		{
+			name:        "fingerprinted asset is immutable",
+			path:        fingerprinted,
+			wantStatus:  http.StatusOK,
+			wantCache:   "public, max-age=31536000, immutable",
+			wantType:    "text/css",
+			wantContent: "--primary-color",
+		},
+		{
+			name:       "plain name must be revalidated",
+			path:       "/assets/verify.js",
+			wantStatus: http.StatusOK,
+			wantCache:  "public, no-cache",
+			wantType:   "text/javascript",
+		},
+		{
+			name:       "matching etag is not modified",
+			path:       fingerprinted,
+			header:     http.Header{"If-None-Match": {staticAssets.byName["style.css"].etag}},
+			wantStatus: http.StatusNotModified,
+			wantCache:  "public, max-age=31536000, immutable",
+		},
+		{
+			name:       "unknown asset",
+			path:       "/assets/missing.css",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "post not allowed",
+			method:     http.MethodPost,
+			path:       fingerprinted,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Cache-Control"); got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tt.wantType) {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantContent) {
+				t.Errorf("body missing %q", tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestPagesReferenceAssets(t *testing.T) {
+	templates := setupTemplates(t)
+	stylesheet, _ := assetPath("style.css")
+	script, _ := assetPath("verify.js")
+
+	mock := newMockResponseWriter()
+	if err := templates.RenderVerify(mock, VerifyData{CSRFToken: "token123"}); err != nil {
+		t.Fatalf("RenderVerify() error = %v", err)
+	}
+	if !mock.Contains(`href="`+stylesheet+`"`, `src="`+script+`"`) {
+		t.Errorf("verify page missing asset references.\ngot: %s", mock.Written())
+	}
+}
+
+func TestConsentPageReferencesStatusScript(t *testing.T) {
+	templates := setupTemplates(t)
+	script, err := assetPath("status.js")
+	if err != nil {
+		t.Fatalf("assetPath() error = %v", err)
+	}
+
+	mock := newMockResponseWriter()
+	if err := templates.RenderConsent(mock, ConsentData{UserCode: "BCDF-GHJK", CSRFToken: "token123"}); err != nil {
+		t.Fatalf("RenderConsent() error = %v", err)
+	}
+	if !mock.Contains(`src="` + script + `"`) {
+		t.Errorf("consent page missing status script.\ngot: %s", mock.Written())
+	}
+}
@@ -0,0 +1,121 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
+)
+
+// DefaultProductName titles the hosted pages when no brand is configured
+const DefaultProductName = "Device Authorization"
+
+// Brand customizes the hosted pages to match the operator's product
+type Brand struct {
+	ProductName  string // Shown in page titles and as the logo's alt text
+	LogoURL      string // Absolute https URL or a path served by this proxy
+	PrimaryColor string // CSS hex color for headings, buttons and focus rings
+	FooterLinks  []FooterLink
+
+	// CodeFormat lays out user codes on the pages, the issued XXXX-XXXX
+	// layout if empty
+	CodeFormat validation.DisplayFormat
+
+	Accessibility Accessibility // Presentation toggles for all pages
+}
+
+// FooterLink is a link shown in the page footer, such as a privacy policy
+type FooterLink struct {
+	Label string
+	URL   string
+}
+
+// hexColor matches #rgb, #rgba, #rrggbb and #rrggbbaa colors
+var hexColor = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3,4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`)
+
+// Validate checks that brand values are safe to place in the page
+func (b Brand) Validate() error {
+	var errs []error
+	if b.PrimaryColor != "" && !hexColor.MatchString(b.PrimaryColor) {
+		errs = append(errs, fmt.Errorf("primary color %q must be a hex color such as #1a73e8", b.PrimaryColor))
+	}
+	if b.LogoURL != "" {
+		if err := validateLink(b.LogoURL); err != nil {
+			errs = append(errs, fmt.Errorf("logo URL: %w", err))
+		}
+	}
+	for _, link := range b.FooterLinks {
+		if link.Label == "" {
+			errs = append(errs, fmt.Errorf("footer link %q has no label", link.URL))
+		}
+		if err := validateLink(link.URL); err != nil {
+			errs = append(errs, fmt.Errorf("footer link %q: %w", link.Label, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Name returns the product name, or DefaultProductName when unset
+func (b *Brand) Name() string {
+	if b == nil || b.ProductName == "" {
+		return DefaultProductName
+	}
+	return b.ProductName
+}
+
+// A11y returns the accessibility toggles, all off when no brand is set
+func (b *Brand) A11y() Accessibility {
+	if b == nil {
+		return Accessibility{}
+	}
+	return b.Accessibility
+}
+
+// CodeLayout returns the display format of user codes on the pages
+func (b *Brand) CodeLayout() validation.DisplayFormat {
+	if b == nil || b.CodeFormat == "" {
+		return validation.DefaultDisplayFormat
+	}
+	return b.CodeFormat
+}
+
+// DisplayCode lays out a user code in the display format of the pages
+func (b *Brand) DisplayCode(code string) string {
+	return b.CodeLayout().Format(code)
+}
+
+// validateLink accepts absolute http(s) URLs and root-relative paths
+func validateLink(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch {
+	case u.Scheme == "https" || u.Scheme == "http":
+		if u.Host == "" {
+			return errors.New("missing host")
+		}
+	case u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/"):
+		// Served by this proxy
+	default:
+		return errors.New("must be an http(s) URL or a path starting with /")
+	}
+	return nil
+}
+
+// ParseFooterLinks parses "Label=URL" entries, such as those from a
+// comma-separated environment variable
+func ParseFooterLinks(entries []string) ([]FooterLink, error) {
+	links := make([]FooterLink, 0, len(entries))
+	for _, entry := range entries {
+		label, link, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("footer link %q must be in Label=URL form", entry)
+		}
+		links = append(links, FooterLink{Label: strings.TrimSpace(label), URL: strings.TrimSpace(link)})
+	}
+	return links, nil
+}
@@ -0,0 +1,92 @@
+package templates
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestBrandValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		brand   Brand
+		wantErr bool
+	}{
+		{name: "empty"},
+		{
+			name: "complete",
+			brand: Brand{
+				ProductName:  "Acme TV",
+				LogoURL:      "https://cdn.acme.example/logo.svg",
+				PrimaryColor: "#0b5fff",
+				FooterLinks:  []FooterLink{{Label: "Privacy", URL: "/privacy"}},
+			},
+		},
+		{name: "named color", brand: Brand{PrimaryColor: "red"}, wantErr: true},
+		{name: "css injection", brand: Brand{PrimaryColor: "#fff;background:url(x)"}, wantErr: true},
+		{name: "script logo", brand: Brand{LogoURL: "javascript:alert(1)"}, wantErr: true},
+		{name: "relative logo", brand: Brand{LogoURL: "logo.png"}, wantErr: true},
+		{name: "unlabelled link", brand: Brand{FooterLinks: []FooterLink{{URL: "/privacy"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.brand.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseFooterLinks(t *testing.T) {
+	got, err := ParseFooterLinks([]string{"Privacy=https://acme.example/privacy", " Help = /help "})
+	if err != nil {
+		t.Fatalf("ParseFooterLinks() error = %v", err)
+	}
+	want := []FooterLink{
+		{Label: "Privacy", URL: "https://acme.example/privacy"},
+		{Label: "Help", URL: "/help"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ParseFooterLinks() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := ParseFooterLinks([]string{"https://acme.example"}); err == nil {
+		t.Error("ParseFooterLinks() accepted an entry without a label")
+	}
+}
+
+func TestRenderWithBrand(t *testing.T) {
+	templates := setupTemplates(t)
+	templates.SetBrand(Brand{
+		ProductName:  "Acme TV",
+		LogoURL:      "https://cdn.acme.example/logo.svg",
+		PrimaryColor: "#0b5fff",
+		FooterLinks:  []FooterLink{{Label: "Privacy", URL: "https://acme.example/privacy"}},
+	})
+
+	mock := newMockResponseWriter()
+	if err := templates.RenderComplete(mock, CompleteData{Message: "Done"}); err != nil {
+		t.Fatalf("RenderComplete() error = %v", err)
+	}
+
+	wantContains := []string{
+		"<title>Acme TV - Authorization Complete</title>",
+		`<img class="brand-logo" src="https://cdn.acme.example/logo.svg" alt="Acme TV">`,
+		"--primary-color: #0b5fff;",
+		`<a href="https://acme.example/privacy">Privacy</a>`,
+	}
+	if !mock.Contains(wantContains...) {
+		t.Errorf("response missing brand content.\ngot: %s", mock.Written())
+	}
+
+	// Unbranded pages keep the default title
+	templates.SetBrand(Brand{})
+	mock = newMockResponseWriter()
+	if err := templates.RenderError(mock, ErrorData{Title: "Oops", Message: "Failed"}); err != nil {
+		t.Fatalf("RenderError() error = %v", err)
+	}
+	if !mock.Contains("<title>Device Authorization - Error</title>") || mock.Contains("brand-logo\"") {
+		t.Errorf("unbranded page mismatch.\ngot: %s", mock.Written())
+	}
+}
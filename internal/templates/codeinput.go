@@ -0,0 +1,21 @@
+package templates
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/wrale/oauth2-device-proxy/internal/validation"
+)
+
+// codeCharset lists the characters user codes are made of, in both cases
+// since entry is case-insensitive per RFC 8628 section 6.1
+func codeCharset() string {
+	return validation.ValidCharset + strings.ToLower(validation.ValidCharset)
+}
+
+// codePattern is the HTML pattern for a user code entered without script, in
+// any display format: an optional separator may follow each character
+func codePattern() string {
+	char := fmt.Sprintf("[%s]", codeCharset())
+	return fmt.Sprintf(`%s(?:[\- .]?%s){%d}`, char, char, validation.MinLength-1)
+}
@@ -0,0 +1,29 @@
+package templates
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestCodePattern(t *testing.T) {
+	// Browsers anchor pattern attributes to the whole value
+	pattern := regexp.MustCompile("^(?:" + codePattern() + ")$")
+
+	tests := map[string]bool{
+		"BCDF-GHJK":   true,
+		"bcdf-ghjk":   true,
+		"BCDFGHJK":    true,
+		"BCDF GHJK":   true,
+		"BC.DF.GH.JK": true,
+		"BCD-FGH-JK":  true,
+		"ABCD-EFGH":   false, // Vowels are never used
+		"BCD1-GHJK":   false,
+		"BCDF--GHJK":  false,
+		"BCDF-GHJ":    false,
+	}
+	for code, want := range tests {
+		if got := pattern.MatchString(code); got != want {
+			t.Errorf("pattern match %q = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package templates
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QRPrefix is the URL path under which cached QR codes are served
+const QRPrefix = "/device/qr/"
+
+// DefaultQRCacheSize is the number of QR codes kept in memory by default
+const DefaultQRCacheSize = 256
+
+// qrEntry is a rendered QR code and the validator it is served with
+type qrEntry struct {
+	uri  string
+	key  string // Content hash naming the entry in its URL path
+	svg  string
+	etag string
+}
+
+// qrCache is a fixed size least recently used cache of rendered QR codes,
+// indexed both by the encoded URI and by the key used in its URL path
+type qrCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // Front is most recently used
+	byURI map[string]*list.Element
+	byKey map[string]*list.Element
+}
+
+// newQRCache creates a cache holding up to size QR codes, or nil when size
+// is not positive so that every QR code is generated on demand
+func newQRCache(size int) *qrCache {
+	if size <= 0 {
+		return nil
+	}
+	return &qrCache{
+		size:  size,
+		order: list.New(),
+		byURI: make(map[string]*list.Element),
+		byKey: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached QR code for a URI
+func (c *qrCache) get(uri string) (*qrEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.byURI[uri]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*qrEntry), true
+}
+
+// lookup returns the cached QR code served under a key
+func (c *qrCache) lookup(key string) (*qrEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.byKey[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*qrEntry), true
+}
+
+// add caches the QR code rendered for a URI, evicting the least recently used
+// entry when full, and returns the cached entry
+func (c *qrCache) add(uri, svg string) *qrEntry {
+	sum := sha256.Sum256([]byte(svg))
+	key := hex.EncodeToString(sum[:])[:16]
+	entry := &qrEntry{uri: uri, key: key, svg: svg, etag: `"` + key + `"`}
+	if c == nil {
+		return entry
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.byURI[uri]; ok {
+		c.order.MoveToFront(el)
+		return el.Value.(*qrEntry)
+	}
+	el := c.order.PushFront(entry)
+	c.byURI[uri] = el
+	c.byKey[key] = el
+
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		evicted := c.order.Remove(oldest).(*qrEntry)
+		delete(c.byURI, evicted.uri)
+		delete(c.byKey, evicted.key)
+	}
+	return entry
+}
+
+// SetQRCacheSize replaces the QR code cache with one holding up to size
+// codes. A size of zero or less disables caching.
+func (t *Templates) SetQRCacheSize(size int) {
+	t.qrCodes = newQRCache(size)
+}
+
+// QRCodePath returns the URL path serving the cached QR code for a URI, or ""
+// if it has not been generated or was evicted
+func (t *Templates) QRCodePath(verificationURI string) string {
+	entry, ok := t.qrCodes.get(verificationURI)
+	if !ok {
+		return ""
+	}
+	return QRPrefix + entry.key + ".svg"
+}
+
+// QRCodeHandler serves cached QR codes under QRPrefix. Responses carry an
+// ETag so browsers revalidate a code shown again rather than download it.
+func (t *Templates) QRCodeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, QRPrefix)
+		entry, ok := t.qrCodes.lookup(strings.TrimSuffix(name, ".svg"))
+		if !ok || !strings.HasSuffix(name, ".svg") {
+			http.NotFound(w, r)
+			return
+		}
+
+		// Codes embed a user code, so keep them out of shared caches
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Header().Set("Cache-Control", "private, no-cache")
+		w.Header().Set("ETag", entry.etag)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, name, time.Time{}, strings.NewReader(entry.svg))
+	})
+}
@@ -0,0 +1,74 @@
+package templates
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQRCodeCache(t *testing.T) {
+	tmpl := &Templates{}
+	tmpl.SetQRCacheSize(2)
+
+	const uri = "HTTPS://EX.CO/D/BCDFGHJK"
+	svg, err := tmpl.GenerateQRCode(uri)
+	if err != nil {
+		t.Fatalf("GenerateQRCode failed: %v", err)
+	}
+	path := tmpl.QRCodePath(uri)
+	if path == "" {
+		t.Fatal("generated QR code was not cached")
+	}
+
+	handler := tmpl.QRCodeHandler()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec.Body.String() != svg {
+		t.Error("served QR code differs from the generated one")
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/svg+xml" {
+		t.Errorf("Content-Type = %q, want image/svg+xml", got)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional status = %d, want %d", rec.Code, http.StatusNotModified)
+	}
+
+	// Filling the cache evicts the least recently used code
+	for _, other := range []string{"HTTPS://EX.CO/D/LMNPQRST", "HTTPS://EX.CO/D/VWXZBCDF"} {
+		if _, err := tmpl.GenerateQRCode(other); err != nil {
+			t.Fatalf("GenerateQRCode(%q) failed: %v", other, err)
+		}
+	}
+	if tmpl.QRCodePath(uri) != "" {
+		t.Error("least recently used QR code was not evicted")
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("evicted code status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestQRCodeCacheDisabled(t *testing.T) {
+	tmpl := &Templates{}
+	tmpl.SetQRCacheSize(0)
+
+	if _, err := tmpl.GenerateQRCode("HTTPS://EX.CO/D/BCDFGHJK"); err != nil {
+		t.Fatalf("GenerateQRCode failed: %v", err)
+	}
+	if path := tmpl.QRCodePath("HTTPS://EX.CO/D/BCDFGHJK"); path != "" {
+		t.Errorf("QRCodePath = %q with caching disabled", path)
+	}
+}
@@ -2,7 +2,6 @@
 package templates
 
 import (
-	"bytes"
 	"fmt"
 	"strings"
 )
@@ -21,40 +20,30 @@ const (
 // This enables non-textual transmission of the verification URI and code while still
 // requiring the user to verify the code matches their device for security.
 func (t *Templates) GenerateQRCode(verificationURI string) (string, error) {
+	if t.GenerateQRCodeFunc != nil {
+		return t.GenerateQRCodeFunc(verificationURI)
+	}
 	if verificationURI == "" {
 		return "", fmt.Errorf("empty verification URI")
 	}
+	if entry, ok := t.qrCodes.get(verificationURI); ok {
+		return entry.svg, nil
+	}
 
-	// Calculate total size including quiet zones
-	totalSize := (qrSize + 2*qrQuietZone) * qrModuleSize
-
-	var buf bytes.Buffer
-
-	// Create SVG container with white background
-	buf.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d">`, totalSize, totalSize))
-	buf.WriteString(`<rect width="100%" height="100%" fill="white"/>`)
-
-	// Generate QR code data matrix using Reed-Solomon encoding
-	matrix, err := generateQRMatrix(verificationURI)
+	svg, err := RenderQRCode(SVGRenderer{}, verificationURI)
 	if err != nil {
-		return "", fmt.Errorf("generating QR matrix: %w", err)
+		return "", err
 	}
+	return t.qrCodes.add(verificationURI, string(svg)).svg, nil
+}
 
-	// Draw QR code modules
-	for y := 0; y < qrSize; y++ {
-		for x := 0; x < qrSize; x++ {
-			if matrix[y][x] {
-				// Draw black module with offset for quiet zone
-				drawX := (x + qrQuietZone) * qrModuleSize
-				drawY := (y + qrQuietZone) * qrModuleSize
-				buf.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d"/>`,
-					drawX, drawY, qrModuleSize, qrModuleSize))
-			}
-		}
+// QRMatrix returns the QR code modules for the verification URI, with true
+// marking dark modules, for rendering outside of HTML such as in a terminal
+func QRMatrix(verificationURI string) ([][]bool, error) {
+	if verificationURI == "" {
+		return nil, fmt.Errorf("empty verification URI")
 	}
-
-	buf.WriteString("</svg>")
-	return buf.String(), nil
+	return generateQRMatrix(verificationURI)
 }
 
 // generateQRMatrix creates a QR code matrix for the verification URI
@@ -0,0 +1,193 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// QR code output formats
+const (
+	QRFormatSVG      = "svg"      // SVG image
+	QRFormatPNG      = "png"      // Black and white PNG image
+	QRFormatASCII    = "ascii"    // Plain ASCII text, two characters per module
+	QRFormatTerminal = "terminal" // Unicode half blocks, for dark terminal backgrounds
+)
+
+// QRRenderer draws a QR code matrix, with true marking dark modules, in one
+// output format
+type QRRenderer interface {
+	// Render draws the matrix
+	Render(matrix [][]bool) ([]byte, error)
+
+	// ContentType is the media type of rendered codes
+	ContentType() string
+}
+
+// NewQRRenderer returns the renderer for a QR code format
+func NewQRRenderer(format string) (QRRenderer, error) {
+	switch format {
+	case QRFormatSVG:
+		return SVGRenderer{}, nil
+	case QRFormatPNG:
+		return PNGRenderer{}, nil
+	case QRFormatASCII:
+		return ASCIIRenderer{}, nil
+	case QRFormatTerminal:
+		return TerminalRenderer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown QR code format %q, want %s, %s, %s or %s",
+			format, QRFormatSVG, QRFormatPNG, QRFormatASCII, QRFormatTerminal)
+	}
+}
+
+// RenderQRCode renders the QR code for the verification URI per RFC 8628
+// section 3.3.1
+func RenderQRCode(r QRRenderer, verificationURI string) ([]byte, error) {
+	matrix, err := QRMatrix(verificationURI)
+	if err != nil {
+		return nil, fmt.Errorf("generating QR matrix: %w", err)
+	}
+	return r.Render(matrix)
+}
+
+// QRCodeDataURI renders the QR code for the verification URI as a data URI,
+// for embedding in JSON responses
+func QRCodeDataURI(r QRRenderer, verificationURI string) (string, error) {
+	code, err := RenderQRCode(r, verificationURI)
+	if err != nil {
+		return "", err
+	}
+	return "data:" + r.ContentType() + ";base64," + base64.StdEncoding.EncodeToString(code), nil
+}
+
+// SVGRenderer draws QR codes as SVG images
+type SVGRenderer struct{}
+
+// ContentType implements QRRenderer
+func (SVGRenderer) ContentType() string { return "image/svg+xml" }
+
+// Render implements QRRenderer
+func (SVGRenderer) Render(matrix [][]bool) ([]byte, error) {
+	size := len(matrix)
+	totalSize := (size + 2*qrQuietZone) * qrModuleSize
+
+	var buf bytes.Buffer
+
+	// Create SVG container with white background
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d">`, totalSize, totalSize)
+	buf.WriteString(`<rect width="100%" height="100%" fill="white"/>`)
+
+	// Draw black modules offset by the quiet zone
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if matrix[y][x] {
+				fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="%d" height="%d"/>`,
+					(x+qrQuietZone)*qrModuleSize, (y+qrQuietZone)*qrModuleSize, qrModuleSize, qrModuleSize)
+			}
+		}
+	}
+
+	buf.WriteString("</svg>")
+	return buf.Bytes(), nil
+}
+
+// PNGRenderer draws QR codes as black and white PNG images
+type PNGRenderer struct{}
+
+// ContentType implements QRRenderer
+func (PNGRenderer) ContentType() string { return "image/png" }
+
+// Render implements QRRenderer
+func (PNGRenderer) Render(matrix [][]bool) ([]byte, error) {
+	size := len(matrix)
+	totalSize := (size + 2*qrQuietZone) * qrModuleSize
+	img := image.NewPaletted(image.Rect(0, 0, totalSize, totalSize), color.Palette{color.White, color.Black})
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if !matrix[y][x] {
+				continue
+			}
+			for dy := 0; dy < qrModuleSize; dy++ {
+				for dx := 0; dx < qrModuleSize; dx++ {
+					img.SetColorIndex((x+qrQuietZone)*qrModuleSize+dx, (y+qrQuietZone)*qrModuleSize+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("encoding QR code PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// textQuietZone is the quiet zone of text renderings, in modules. Terminals
+// usually pad their output, so it is narrower than in images.
+const textQuietZone = 2
+
+// darkAt reports whether the module at x, y of a matrix padded with a quiet
+// zone is dark
+func darkAt(matrix [][]bool, x, y, quiet int) bool {
+	x, y = x-quiet, y-quiet
+	return y >= 0 && y < len(matrix) && x >= 0 && x < len(matrix[y]) && matrix[y][x]
+}
+
+// ASCIIRenderer draws QR codes as plain ASCII text, with "##" for each dark
+// module so that codes stay roughly square in monospace fonts
+type ASCIIRenderer struct{}
+
+// ContentType implements QRRenderer
+func (ASCIIRenderer) ContentType() string { return "text/plain;charset=us-ascii" }
+
+// Render implements QRRenderer
+func (ASCIIRenderer) Render(matrix [][]bool) ([]byte, error) {
+	total := len(matrix) + 2*textQuietZone
+	var b strings.Builder
+	for y := 0; y < total; y++ {
+		for x := 0; x < total; x++ {
+			if darkAt(matrix, x, y, textQuietZone) {
+				b.WriteString("##")
+			} else {
+				b.WriteString("  ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), nil
+}
+
+// TerminalRenderer draws QR codes with half-block characters, two module rows
+// per line, inverted so that they scan on dark terminal backgrounds
+type TerminalRenderer struct{}
+
+// ContentType implements QRRenderer
+func (TerminalRenderer) ContentType() string { return "text/plain;charset=utf-8" }
+
+// Render implements QRRenderer
+func (TerminalRenderer) Render(matrix [][]bool) ([]byte, error) {
+	total := len(matrix) + 2*textQuietZone
+	var b strings.Builder
+	for y := 0; y < total; y += 2 {
+		for x := 0; x < total; x++ {
+			top, bottom := !darkAt(matrix, x, y, textQuietZone), !darkAt(matrix, x, y+1, textQuietZone)
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return []byte(b.String()), nil
+}
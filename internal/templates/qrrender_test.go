@@ -0,0 +1,86 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestQRRenderers(t *testing.T) {
+	const uri = "HTTPS://EX.CO/D/BCDFGHJK" // Short enough for the QR encoder
+	matrix, err := QRMatrix(uri)
+	if err != nil {
+		t.Fatalf("QRMatrix failed: %v", err)
+	}
+	textLines := len(matrix) + 2*textQuietZone
+
+	tests := []struct {
+		format string
+		check  func(t *testing.T, code []byte)
+	}{
+		{format: QRFormatSVG, check: func(t *testing.T, code []byte) {
+			if !bytes.HasPrefix(code, []byte("<svg")) || !bytes.HasSuffix(code, []byte("</svg>")) {
+				t.Errorf("SVG = %.40q, want an svg element", code)
+			}
+		}},
+		{format: QRFormatPNG, check: func(t *testing.T, code []byte) {
+			img, err := png.Decode(bytes.NewReader(code))
+			if err != nil {
+				t.Fatalf("decoding PNG: %v", err)
+			}
+			if want := (len(matrix) + 2*qrQuietZone) * qrModuleSize; img.Bounds().Dx() != want {
+				t.Errorf("PNG width = %d, want %d", img.Bounds().Dx(), want)
+			}
+		}},
+		{format: QRFormatASCII, check: func(t *testing.T, code []byte) {
+			lines := strings.Split(strings.TrimSuffix(string(code), "\n"), "\n")
+			if len(lines) != textLines {
+				t.Errorf("ASCII has %d lines, want %d", len(lines), textLines)
+			}
+			for _, c := range code {
+				if c != '#' && c != ' ' && c != '\n' {
+					t.Fatalf("ASCII contains %q", c)
+				}
+			}
+		}},
+		{format: QRFormatTerminal, check: func(t *testing.T, code []byte) {
+			lines := strings.Split(strings.TrimSuffix(string(code), "\n"), "\n")
+			if want := (textLines + 1) / 2; len(lines) != want {
+				t.Errorf("terminal art has %d lines, want %d", len(lines), want)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			r, err := NewQRRenderer(tt.format)
+			if err != nil {
+				t.Fatalf("NewQRRenderer failed: %v", err)
+			}
+			code, err := RenderQRCode(r, uri)
+			if err != nil {
+				t.Fatalf("RenderQRCode failed: %v", err)
+			}
+			tt.check(t, code)
+
+			dataURI, err := QRCodeDataURI(r, uri)
+			if err != nil {
+				t.Fatalf("QRCodeDataURI failed: %v", err)
+			}
+			prefix := "data:" + r.ContentType() + ";base64,"
+			decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(dataURI, prefix))
+			if !strings.HasPrefix(dataURI, prefix) || err != nil || !bytes.Equal(decoded, code) {
+				t.Errorf("data URI = %.50q, want the rendered code with prefix %q", dataURI, prefix)
+			}
+		})
+	}
+
+	if _, err := NewQRRenderer("gif"); err == nil {
+		t.Error("NewQRRenderer accepted an unknown format")
+	}
+	if _, err := RenderQRCode(PNGRenderer{}, ""); err == nil {
+		t.Error("RenderQRCode accepted an empty URI")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestRenderVerify(t *testing.T) {
@@ -74,6 +75,52 @@ func TestRenderVerify(t *testing.T) {
 	}
 }
 
+func TestRenderConsent(t *testing.T) {
+	templates := setupTemplates(t)
+
+	mock := newMockResponseWriter()
+	err := templates.RenderConsent(mock, ConsentData{
+		ClientName: "Lobby Kiosk",
+		UserCode:   "BCDF-GHJK",
+		Scopes: []ConsentScope{
+			{Name: "openid", Description: "Sign you in with your account"},
+			{Name: "orders:read"},
+		},
+		Device:    &ConsentDevice{ID: "SN-4411-A", Attested: true},
+		Origin:    "203.0.113.7 (Berlin, DE)",
+		Anomaly:   &ConsentAnomaly{IP: "198.51.100.7", NetworkChanged: true, UserAgentChanged: true},
+		CSRFToken: "token123",
+		Ticket:    "ticket123",
+	})
+	if err != nil {
+		t.Fatalf("RenderConsent() error = %v", err)
+	}
+
+	if mock.statusCode != http.StatusOK {
+		t.Errorf("status = %v, want %v", mock.statusCode, http.StatusOK)
+	}
+
+	wantContains := []string{
+		"Lobby Kiosk",
+		"BCDF-GHJK",
+		"Sign you in with your account",
+		"<code>orders:read</code>",
+		"<code>SN-4411-A</code>",
+		"provided an attestation",
+		"Requested from <strong>203.0.113.7 (Berlin, DE)</strong>",
+		`value="token123"`,
+		`value="ticket123"`,
+		`value="approve"`,
+		`value="deny"`,
+		`data-user-code="BCDF-GHJK"`,
+		"approval is connecting from a different network (<strong>198.51.100.7</strong>) and identifies itself differently",
+		`name="anomaly_acknowledged" value="true"`,
+	}
+	if !mock.Contains(wantContains...) {
+		t.Errorf("response missing required content.\ngot: %s", mock.Written())
+	}
+}
+
 func TestRenderComplete(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -101,6 +148,28 @@ func TestRenderComplete(t *testing.T) {
 			},
 			wantStatus: http.StatusOK,
 		},
+		{
+			name: "renders authorization summary",
+			data: CompleteData{
+				Summary: &CompleteSummary{
+					UserName:   "Alice Example",
+					UserEmail:  "alice@example.com",
+					ClientName: "Living Room TV",
+					UserCode:   "WDJB-MJHT",
+					DeviceID:   "serial-42",
+					Origin:     "203.0.113.7 (Berlin, DE)",
+				},
+			},
+			wantContains: []string{
+				"Authorization details",
+				"Alice Example (alice@example.com)",
+				"Living Room TV",
+				"<code>WDJB-MJHT</code>",
+				"<code>serial-42</code>",
+				"203.0.113.7 (Berlin, DE)",
+			},
+			wantStatus: http.StatusOK,
+		},
 	}
 
 	templates := setupTemplates(t)
@@ -124,11 +193,47 @@ func TestRenderComplete(t *testing.T) {
 	}
 }
 
+func TestRenderDevices(t *testing.T) {
+	authorizedAt := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	templates := setupTemplates(t)
+	mock := newMockResponseWriter()
+	err := templates.RenderDevices(mock, DevicesData{
+		UserName:  "alice",
+		ManageURL: "https://sso.example.com/account/applications",
+		CSRFToken: "csrf-123",
+		Devices: []Device{
+			{Ref: "ref-1", ClientName: "Living Room TV", DeviceID: "serial-42", AuthorizedAt: authorizedAt, Revocable: true},
+			{Ref: "ref-2", ClientName: "Kiosk", AuthorizedAt: authorizedAt, Revoked: true},
+			{Ref: "ref-3", ClientName: "Printer", AuthorizedAt: authorizedAt},
+		},
+	})
+	if err != nil {
+		t.Fatalf("RenderDevices() error = %v", err)
+	}
+
+	if !mock.Contains(
+		"Signed in as <strong>alice</strong>",
+		"Living Room TV",
+		"<code>serial-42</code>",
+		"2024-05-01 12:30 UTC",
+		`name="device" value="ref-1"`,
+		`name="csrf_token" value="csrf-123"`,
+		"Access revoked",
+		`href="https://sso.example.com/account/applications"`,
+	) {
+		t.Errorf("response missing required content.\ngot: %s", mock.Written())
+	}
+	if strings.Contains(mock.Written(), `value="ref-2"`) || strings.Contains(mock.Written(), `value="ref-3"`) {
+		t.Errorf("revoke form shown for a device without a revocable grant:\n%s", mock.Written())
+	}
+}
+
 func TestRenderError(t *testing.T) {
 	tests := []struct {
 		name         string
 		data         ErrorData
 		wantContains []string
+		wantAbsent   []string
 		wantStatus   int
 	}{
 		{
@@ -144,6 +249,36 @@ func TestRenderError(t *testing.T) {
 			},
 			wantStatus: http.StatusBadRequest,
 		},
+		{
+			name: "renders error code and retry link",
+			data: ErrorData{
+				Title:         "Code Expired",
+				Message:       "The code has expired",
+				Code:          "expired_token",
+				CorrelationID: "req-123",
+				RetryURL:      "/proxy/device",
+				RetryLabel:    "Enter a New Code",
+			},
+			wantContains: []string{
+				"expired_token",
+				"req-123",
+				`href="/proxy/device"`,
+				"Enter a New Code",
+			},
+			wantAbsent: []string{"Try Again"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name: "final error has no retry link",
+			data: ErrorData{
+				Title:   "Authorization Denied",
+				Message: "The request was denied",
+				Final:   true,
+			},
+			wantContains: []string{"Authorization Denied"},
+			wantAbsent:   []string{"Try Again", `class="button"`},
+			wantStatus:   http.StatusBadRequest,
+		},
 	}
 
 	templates := setupTemplates(t)
@@ -163,6 +298,11 @@ func TestRenderError(t *testing.T) {
 			if !mock.Contains(tt.wantContains...) {
 				t.Errorf("response missing required content.\ngot: %s", mock.Written())
 			}
+			for _, absent := range tt.wantAbsent {
+				if strings.Contains(mock.Written(), absent) {
+					t.Errorf("response contains %q.\ngot: %s", absent, mock.Written())
+				}
+			}
 		})
 	}
 }
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"html/template"
 	"io"
+	"io/fs"
 	"net/http"
+	"time"
 )
 
 //go:embed html/*.html
@@ -23,13 +25,21 @@ var requiredDefinitions = []string{
 // Templates manages the HTML templates per RFC 8628 section 3.3
 type Templates struct {
 	verify   *template.Template
+	consent  *template.Template
 	complete *template.Template
+	devices  *template.Template
 	error    *template.Template
 
+	brand Brand // Applied to pages rendered without their own brand
+
+	qrCodes *qrCache // Rendered QR codes by verification URI, nil disables caching
+
 	// Function overrides for testing
 	RenderVerifyFunc   func(w http.ResponseWriter, data VerifyData) error
+	RenderConsentFunc  func(w http.ResponseWriter, data ConsentData) error
 	RenderErrorFunc    func(w http.ResponseWriter, data ErrorData) error
 	RenderCompleteFunc func(w http.ResponseWriter, data CompleteData) error
+	RenderDevicesFunc  func(w http.ResponseWriter, data DevicesData) error
 	GenerateQRCodeFunc func(uri string) (string, error)
 }
 
@@ -63,29 +73,60 @@ func validateTemplate(tmpl *template.Template) error {
 	return nil
 }
 
+// parsePage parses a page template together with the shared layout
+func parsePage(fsys fs.FS, name string) (*template.Template, error) {
+	return template.New(name).Funcs(templateFuncs).ParseFS(fsys, name, "layout.html")
+}
+
 // LoadTemplates loads and parses all HTML templates
 func LoadTemplates() (*Templates, error) {
-	t := &Templates{}
+	pages, err := fs.Sub(content, "html")
+	if err != nil {
+		return nil, fmt.Errorf("opening embedded templates: %w", err)
+	}
+	return LoadTemplatesFS(pages)
+}
+
+// LoadTemplatesFS loads and parses the HTML templates from fsys, which holds
+// layout.html and a file per page like the embedded html directory
+func LoadTemplatesFS(fsys fs.FS) (*Templates, error) {
+	t := &Templates{qrCodes: newQRCache(DefaultQRCacheSize)}
 	var err error
 
 	// Load verification page template
-	if t.verify, err = template.ParseFS(content, "html/verify.html", "html/layout.html"); err != nil {
+	if t.verify, err = parsePage(fsys, "verify.html"); err != nil {
 		return nil, fmt.Errorf("parsing verify template: %w", err)
 	}
 	if err = validateTemplate(t.verify); err != nil {
 		return nil, fmt.Errorf("validating verify template: %w", err)
 	}
 
+	// Load consent page template
+	if t.consent, err = parsePage(fsys, "consent.html"); err != nil {
+		return nil, fmt.Errorf("parsing consent template: %w", err)
+	}
+	if err = validateTemplate(t.consent); err != nil {
+		return nil, fmt.Errorf("validating consent template: %w", err)
+	}
+
 	// Load complete page template
-	if t.complete, err = template.ParseFS(content, "html/complete.html", "html/layout.html"); err != nil {
+	if t.complete, err = parsePage(fsys, "complete.html"); err != nil {
 		return nil, fmt.Errorf("parsing complete template: %w", err)
 	}
 	if err = validateTemplate(t.complete); err != nil {
 		return nil, fmt.Errorf("validating complete template: %w", err)
 	}
 
+	// Load devices page template
+	if t.devices, err = parsePage(fsys, "devices.html"); err != nil {
+		return nil, fmt.Errorf("parsing devices template: %w", err)
+	}
+	if err = validateTemplate(t.devices); err != nil {
+		return nil, fmt.Errorf("validating devices template: %w", err)
+	}
+
 	// Load error page template
-	if t.error, err = template.ParseFS(content, "html/error.html", "html/layout.html"); err != nil {
+	if t.error, err = parsePage(fsys, "error.html"); err != nil {
 		return nil, fmt.Errorf("parsing error template: %w", err)
 	}
 	if err = validateTemplate(t.error); err != nil {
@@ -95,16 +136,40 @@ func LoadTemplates() (*Templates, error) {
 	return t, nil
 }
 
+// SetBrand sets the branding applied to all rendered pages
+func (t *Templates) SetBrand(brand Brand) {
+	t.brand = brand
+}
+
+// brandFor returns the page's brand, defaulting to the configured one
+func (t *Templates) brandFor(brand *Brand) *Brand {
+	if brand != nil {
+		return brand
+	}
+	b := t.brand
+	return &b
+}
+
 // SetVerify sets the verify template (for testing)
 func (t *Templates) SetVerify(tmpl *template.Template) {
 	t.verify = tmpl
 }
 
+// SetConsent sets the consent template (for testing)
+func (t *Templates) SetConsent(tmpl *template.Template) {
+	t.consent = tmpl
+}
+
 // SetComplete sets the complete template (for testing)
 func (t *Templates) SetComplete(tmpl *template.Template) {
 	t.complete = tmpl
 }
 
+// SetDevices sets the devices template (for testing)
+func (t *Templates) SetDevices(tmpl *template.Template) {
+	t.devices = tmpl
+}
+
 // SetError sets the error template (for testing)
 func (t *Templates) SetError(tmpl *template.Template) {
 	t.error = tmpl
@@ -115,6 +180,11 @@ func (t *Templates) SetRenderVerifyFunc(fn func(w http.ResponseWriter, data Veri
 	t.RenderVerifyFunc = fn
 }
 
+// SetRenderConsentFunc overrides the consent render function (for testing)
+func (t *Templates) SetRenderConsentFunc(fn func(w http.ResponseWriter, data ConsentData) error) {
+	t.RenderConsentFunc = fn
+}
+
 // SetRenderErrorFunc overrides the error render function (for testing)
 func (t *Templates) SetRenderErrorFunc(fn func(w http.ResponseWriter, data ErrorData) error) {
 	t.RenderErrorFunc = fn
@@ -125,6 +195,11 @@ func (t *Templates) SetRenderCompleteFunc(fn func(w http.ResponseWriter, data Co
 	t.RenderCompleteFunc = fn
 }
 
+// SetRenderDevicesFunc overrides the devices render function (for testing)
+func (t *Templates) SetRenderDevicesFunc(fn func(w http.ResponseWriter, data DevicesData) error) {
+	t.RenderDevicesFunc = fn
+}
+
 // SetGenerateQRCodeFunc overrides the QR code generation function (for testing)
 func (t *Templates) SetGenerateQRCodeFunc(fn func(uri string) (string, error)) {
 	t.GenerateQRCodeFunc = fn
@@ -181,9 +256,12 @@ func (w *SafeWriter) SetStatusCode(code int) {
 type VerifyData struct {
 	PrefilledCode         string
 	CSRFToken             string
+	FormNonce             string // Identifies this form rendering for duplicate submission detection
 	Error                 string
 	VerificationURI       string // Per RFC 8628 section 3.2
 	VerificationQRCodeSVG string // QR code for verification_uri_complete per RFC 8628 section 3.3.1
+	VerificationQRCodeURL string // Cached copy of the QR code, shown instead of the inline SVG when set
+	Brand                 *Brand // Defaults to the templates' configured brand
 }
 
 // RenderVerify renders the code verification page
@@ -191,6 +269,7 @@ func (t *Templates) RenderVerify(w http.ResponseWriter, data VerifyData) error {
 	if t.RenderVerifyFunc != nil {
 		return t.RenderVerifyFunc(w, data)
 	}
+	data.Brand = t.brandFor(data.Brand)
 
 	sw := t.NewSafeWriter(w)
 	if err := t.executeToWriter(sw, t.verify, data); err != nil {
@@ -210,9 +289,80 @@ func (t *Templates) RenderVerify(w http.ResponseWriter, data VerifyData) error {
 	return nil
 }
 
+// ConsentScope describes a requested scope on the consent page
+type ConsentScope struct {
+	Name        string
+	Description string // Empty when the scope has no configured description
+}
+
+// ConsentData holds data for the consent page shown before authorization
+type ConsentData struct {
+	ClientName  string
+	ClientLogo  string // Absolute https URL of the client's logo, if any
+	ConsentText string // Client-specific text shown with the requested scopes
+	UserCode    string // Shown so users can compare it with the device per RFC 8628 section 5.4
+	Scopes      []ConsentScope
+	Device      *ConsentDevice  // Device identity asserted by the client, if any
+	Origin      string          // Where the device request came from, e.g. "203.0.113.7 (Berlin, DE)"
+	Anomaly     *ConsentAnomaly // Set when the code was polled by an unexpected client
+	CSRFToken   string
+	Ticket      string // Opaque reference to the device code awaiting approval
+	Brand       *Brand // Defaults to the templates' configured brand
+}
+
+// ConsentDevice describes the requesting device on the consent page
+type ConsentDevice struct {
+	ID       string
+	Attested bool // The client supplied an attestation with the request
+}
+
+// ConsentAnomaly warns that the device polling for the code differs from the
+// one that requested it
+type ConsentAnomaly struct {
+	IP               string // Address of the unexpected poller
+	NetworkChanged   bool
+	UserAgentChanged bool
+}
+
+// RenderConsent renders the consent page
+func (t *Templates) RenderConsent(w http.ResponseWriter, data ConsentData) error {
+	if t.RenderConsentFunc != nil {
+		return t.RenderConsentFunc(w, data)
+	}
+	data.Brand = t.brandFor(data.Brand)
+
+	sw := t.NewSafeWriter(w)
+	if err := t.executeToWriter(sw, t.consent, data); err != nil {
+		var templateErr *TemplateError
+		if errors.As(err, &templateErr) {
+			if renderErr := t.renderError(w, "Unable to display consent page", templateErr.Code, err); renderErr != nil {
+				return fmt.Errorf("failed to render consent page with fallback error: %w", renderErr)
+			}
+			return err
+		}
+		if renderErr := t.renderError(w, "Unable to display consent page", http.StatusInternalServerError, err); renderErr != nil {
+			return fmt.Errorf("failed to render consent page with fallback error: %w", renderErr)
+		}
+		return err
+	}
+	return nil
+}
+
 // CompleteData holds data for the completion page
 type CompleteData struct {
 	Message string
+	Summary *CompleteSummary // What was authorized, so users can confirm it, if known
+	Brand   *Brand           // Defaults to the templates' configured brand
+}
+
+// CompleteSummary describes an authorization on the completion page
+type CompleteSummary struct {
+	UserName   string // Signed-in user's display name, if known
+	UserEmail  string // Signed-in user's email address, if known
+	ClientName string // Client display name, or its client ID
+	UserCode   string
+	DeviceID   string // Client-asserted device identifier, if any
+	Origin     string // Where the device request came from, e.g. "203.0.113.7 (Berlin, DE)"
 }
 
 // RenderComplete renders the completion page
@@ -220,6 +370,7 @@ func (t *Templates) RenderComplete(w http.ResponseWriter, data CompleteData) err
 	if t.RenderCompleteFunc != nil {
 		return t.RenderCompleteFunc(w, data)
 	}
+	data.Brand = t.brandFor(data.Brand)
 
 	sw := t.NewSafeWriter(w)
 	if err := t.executeToWriter(sw, t.complete, data); err != nil {
@@ -238,10 +389,63 @@ func (t *Templates) RenderComplete(w http.ResponseWriter, data CompleteData) err
 	return nil
 }
 
+// DevicesData holds data for the self-service page listing the devices a
+// user authorized
+type DevicesData struct {
+	UserName  string // Signed-in user
+	Devices   []Device
+	Message   string // Outcome of the last action, if any
+	ManageURL string // Where devices without a revocable grant can be removed, if known
+	CSRFToken string
+	Brand     *Brand // Defaults to the templates' configured brand
+}
+
+// Device describes an authorized device on the devices page
+type Device struct {
+	Ref          string // Opaque reference to the authorization, posted to revoke it
+	ClientName   string // Client display name, or its client ID
+	DeviceID     string // Client-asserted device identifier, if any
+	Scope        string
+	AuthorizedAt time.Time
+	ApprovedFrom string // Address of the browser that approved the device
+	Revocable    bool   // The proxy holds the device's grant and can revoke it
+	Revoked      bool   // The user revoked the device's grant
+}
+
+// RenderDevices renders the devices page
+func (t *Templates) RenderDevices(w http.ResponseWriter, data DevicesData) error {
+	if t.RenderDevicesFunc != nil {
+		return t.RenderDevicesFunc(w, data)
+	}
+	data.Brand = t.brandFor(data.Brand)
+
+	sw := t.NewSafeWriter(w)
+	if err := t.executeToWriter(sw, t.devices, data); err != nil {
+		var templateErr *TemplateError
+		if errors.As(err, &templateErr) {
+			if renderErr := t.renderError(w, "Unable to display devices page", templateErr.Code, err); renderErr != nil {
+				return fmt.Errorf("failed to render devices page with fallback error: %w", renderErr)
+			}
+			return err
+		}
+		if renderErr := t.renderError(w, "Unable to display devices page", http.StatusInternalServerError, err); renderErr != nil {
+			return fmt.Errorf("failed to render devices page with fallback error: %w", renderErr)
+		}
+		return err
+	}
+	return nil
+}
+
 // ErrorData holds data for the error page
 type ErrorData struct {
-	Title   string
-	Message string
+	Title         string
+	Message       string
+	Code          string // Machine-readable error code quoted in support requests
+	CorrelationID string // Identifies the failed request in server logs
+	RetryURL      string // Target of the retry link, /device if empty
+	RetryLabel    string // Text of the retry link, "Try Again" if empty
+	Final         bool   // The flow has ended, so no retry link is shown
+	Brand         *Brand // Defaults to the templates' configured brand
 }
 
 // RenderError renders the error page
@@ -249,6 +453,7 @@ func (t *Templates) RenderError(w http.ResponseWriter, data ErrorData) error {
 	if t.RenderErrorFunc != nil {
 		return t.RenderErrorFunc(w, data)
 	}
+	data.Brand = t.brandFor(data.Brand)
 
 	// If this is a SafeWriter, get the underlying ResponseWriter
 	if sw, ok := w.(*SafeWriter); ok {
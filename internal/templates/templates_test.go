@@ -2,7 +2,11 @@ package templates
 
 import (
 	"errors"
+	"io/fs"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestLoadTemplates(t *testing.T) {
@@ -30,6 +34,43 @@ func TestLoadTemplates(t *testing.T) {
 	}
 }
 
+func TestLoadTemplatesFS(t *testing.T) {
+	embedded, err := fs.Sub(content, "html")
+	if err != nil {
+		t.Fatalf("fs.Sub failed: %v", err)
+	}
+
+	// Start from the embedded pages, replacing the error page
+	fsys := fstest.MapFS{}
+	for _, name := range []string{"layout.html", "verify.html", "consent.html", "complete.html", "devices.html"} {
+		data, err := fs.ReadFile(embedded, name)
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		fsys[name] = &fstest.MapFile{Data: data}
+	}
+	fsys["error.html"] = &fstest.MapFile{Data: []byte(
+		`{{define "title"}}Oops{{end}}{{define "content"}}<p class="custom">{{.Message}}</p>{{end}}`)}
+
+	tmpls, err := LoadTemplatesFS(fsys)
+	if err != nil {
+		t.Fatalf("LoadTemplatesFS failed: %v", err)
+	}
+	w := httptest.NewRecorder()
+	if err := tmpls.RenderError(w, ErrorData{Message: "custom page"}); err != nil {
+		t.Fatalf("RenderError failed: %v", err)
+	}
+	if !strings.Contains(w.Body.String(), `<p class="custom">custom page</p>`) {
+		t.Errorf("error page not rendered from fsys: %s", w.Body.String())
+	}
+
+	// Pages missing a required definition are rejected
+	fsys["error.html"] = &fstest.MapFile{Data: []byte(`{{define "content"}}{{end}}`)}
+	if _, err := LoadTemplatesFS(fsys); err == nil || !strings.Contains(err.Error(), "error template") {
+		t.Errorf("LoadTemplatesFS error = %v, want a validation error for the error template", err)
+	}
+}
+
 func TestTemplateError(t *testing.T) {
 	cause := errors.New("original error")
 	err := &TemplateError{
@@ -56,6 +97,9 @@ func TestTemplatesInitialization(t *testing.T) {
 	if templates.verify == nil {
 		t.Error("verify template not loaded")
 	}
+	if templates.consent == nil {
+		t.Error("consent template not loaded")
+	}
 	if templates.complete == nil {
 		t.Error("complete template not loaded")
 	}
@@ -0,0 +1,62 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// throttlePrefix namespaces throttle keys in Redis, mirrored by keyspace.Patterns
+const throttlePrefix = "throttle:"
+
+// hitScript increments a counter, starting its window on the first hit
+var hitScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// RedisStore keeps counters in Redis, shared by all proxy instances
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore creates a Redis-backed store. The key prefix namespaces its
+// keys as REDIS_KEY_PREFIX does for the other stores.
+func NewRedisStore(client *redis.Client, keyPrefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: keyPrefix + throttlePrefix}
+}
+
+// Hit implements Store
+func (s *RedisStore) Hit(ctx context.Context, key string, window time.Duration) (int64, error) {
+	count, err := hitScript.Run(ctx, s.client, []string{s.prefix + key}, window.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("incrementing %s: %w", key, err)
+	}
+	return count, nil
+}
+
+// Lock implements Store
+func (s *RedisStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	if err := s.client.Set(ctx, s.prefix+key, 1, d).Err(); err != nil {
+		return fmt.Errorf("setting %s: %w", key, err)
+	}
+	return nil
+}
+
+// Locked implements Store
+func (s *RedisStore) Locked(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, s.prefix+key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("reading %s: %w", key, err)
+	}
+	if ttl < 0 {
+		return 0, nil // Missing, or a lock without expiry which Lock never writes
+	}
+	return ttl, nil
+}
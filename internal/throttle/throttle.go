@@ -0,0 +1,188 @@
+// Package throttle limits how often a client IP may use the verification pages
+// and locks it out after repeated failed code entries, separately from the
+// per-device polling limits of the device flow
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Throttle defaults
+const (
+	DefaultWindow      = time.Minute
+	DefaultMaxRequests = 30
+	DefaultMaxFailures = 10
+	DefaultLockout     = 15 * time.Minute
+)
+
+// Store keeps per-key counters and lockouts
+type Store interface {
+	// Hit increments the counter for key and returns its new value. The first
+	// hit starts a window of the given length after which the counter resets.
+	Hit(ctx context.Context, key string, window time.Duration) (int64, error)
+
+	// Lock locks key out for the given duration
+	Lock(ctx context.Context, key string, d time.Duration) error
+
+	// Locked returns how much longer key is locked out, zero if it is not
+	Locked(ctx context.Context, key string) (time.Duration, error)
+}
+
+// Config configures a Limiter. Zero values use the package defaults.
+type Config struct {
+	Store       Store
+	Window      time.Duration // Length of the counting window
+	MaxRequests int           // Page requests allowed per IP per window
+	MaxFailures int           // Failed code entries per IP per window before lockout
+	Lockout     time.Duration // How long an IP is locked out after MaxFailures
+}
+
+// Limiter throttles verification page requests by client IP
+type Limiter struct {
+	store       Store
+	window      time.Duration
+	maxRequests int64
+	maxFailures int64
+	lockout     time.Duration
+}
+
+// New creates a Limiter
+func New(cfg Config) *Limiter {
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.MaxRequests <= 0 {
+		cfg.MaxRequests = DefaultMaxRequests
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = DefaultMaxFailures
+	}
+	if cfg.Lockout <= 0 {
+		cfg.Lockout = DefaultLockout
+	}
+	return &Limiter{
+		store:       cfg.Store,
+		window:      cfg.Window,
+		maxRequests: int64(cfg.MaxRequests),
+		maxFailures: int64(cfg.MaxFailures),
+		lockout:     cfg.Lockout,
+	}
+}
+
+// Allow counts a page request from ip and returns how long it must wait
+// before trying again, zero if the request may proceed
+func (l *Limiter) Allow(ctx context.Context, ip string) (time.Duration, error) {
+	locked, err := l.store.Locked(ctx, lockKey(ip))
+	if err != nil {
+		return 0, fmt.Errorf("checking lockout: %w", err)
+	}
+	if locked > 0 {
+		return locked, nil
+	}
+
+	requests, err := l.store.Hit(ctx, requestKey(ip), l.window)
+	if err != nil {
+		return 0, fmt.Errorf("counting request: %w", err)
+	}
+	if requests > l.maxRequests {
+		return l.window, nil
+	}
+	return 0, nil
+}
+
+// Fail counts a failed code entry from ip and reports whether it locked the
+// IP out. Only the failure reaching the limit reports a lockout, so callers
+// can record it once.
+func (l *Limiter) Fail(ctx context.Context, ip string) (bool, error) {
+	failures, err := l.store.Hit(ctx, failureKey(ip), l.window)
+	if err != nil {
+		return false, fmt.Errorf("counting failure: %w", err)
+	}
+	if failures != l.maxFailures {
+		return false, nil
+	}
+	if err := l.store.Lock(ctx, lockKey(ip), l.lockout); err != nil {
+		return false, fmt.Errorf("locking out: %w", err)
+	}
+	return true, nil
+}
+
+// Lockout returns how long an IP stays locked out after too many failures
+func (l *Limiter) Lockout() time.Duration {
+	return l.lockout
+}
+
+func requestKey(ip string) string { return "req:" + ip }
+func failureKey(ip string) string { return "fail:" + ip }
+func lockKey(ip string) string    { return "lock:" + ip }
+
+// MemoryStore keeps counters in process memory. Each proxy instance counts
+// only its own requests, so deployments with several replicas should use
+// RedisStore.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+type memoryEntry struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+// Hit implements Store
+func (m *MemoryStore) Hit(ctx context.Context, key string, window time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+	entry, ok := m.entries[key]
+	if !ok {
+		entry = memoryEntry{expiresAt: now.Add(window)}
+	}
+	entry.count++
+	m.entries[key] = entry
+	return entry.count, nil
+}
+
+// Lock implements Store
+func (m *MemoryStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = memoryEntry{count: 1, expiresAt: m.now().Add(d)}
+	return nil
+}
+
+// Locked implements Store
+func (m *MemoryStore) Locked(ctx context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return 0, nil
+	}
+	remaining := entry.expiresAt.Sub(m.now())
+	if remaining <= 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// sweep drops expired entries
+func (m *MemoryStore) sweep(now time.Time) {
+	for key, entry := range m.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+}
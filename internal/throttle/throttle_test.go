@@ -0,0 +1,63 @@
+package throttle
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterRequests(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	l := New(Config{Store: store, Window: time.Minute, MaxRequests: 2})
+
+	for i := 0; i < 2; i++ {
+		if wait, err := l.Allow(ctx, "192.0.2.1"); err != nil || wait != 0 {
+			t.Fatalf("request %d: wait = %v, err = %v, want allowed", i+1, wait, err)
+		}
+	}
+	if wait, _ := l.Allow(ctx, "192.0.2.1"); wait != time.Minute {
+		t.Errorf("request over the limit: wait = %v, want the window", wait)
+	}
+	if wait, _ := l.Allow(ctx, "198.51.100.7"); wait != 0 {
+		t.Errorf("other IP throttled: wait = %v", wait)
+	}
+
+	// The counter resets with the window
+	now = now.Add(time.Minute)
+	if wait, _ := l.Allow(ctx, "192.0.2.1"); wait != 0 {
+		t.Errorf("request in a new window: wait = %v, want allowed", wait)
+	}
+}
+
+func TestLimiterLockout(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	l := New(Config{Store: store, Window: time.Minute, MaxFailures: 3, Lockout: 10 * time.Minute})
+
+	var lockouts int
+	for i := 0; i < 5; i++ {
+		locked, err := l.Fail(ctx, "192.0.2.1")
+		if err != nil {
+			t.Fatalf("Fail: %v", err)
+		}
+		if locked {
+			lockouts++
+		}
+	}
+	if lockouts != 1 {
+		t.Errorf("reported %d lockouts, want 1", lockouts)
+	}
+
+	if wait, _ := l.Allow(ctx, "192.0.2.1"); wait != 10*time.Minute {
+		t.Errorf("locked out IP: wait = %v, want the lockout", wait)
+	}
+	now = now.Add(10 * time.Minute)
+	if wait, _ := l.Allow(ctx, "192.0.2.1"); wait != 0 {
+		t.Errorf("after lockout: wait = %v, want allowed", wait)
+	}
+}
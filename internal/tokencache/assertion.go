@@ -0,0 +1,217 @@
+package tokencache
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Client assertion defaults
+const (
+	// DefaultMaxAssertionLifetime bounds how far in the future an assertion's
+	// exp may be, which also bounds how long its jti must be remembered
+	DefaultMaxAssertionLifetime = 5 * time.Minute
+)
+
+// ErrInvalidAssertion is returned for client assertions that must be rejected
+var ErrInvalidAssertion = errors.New("invalid client assertion")
+
+// ClientKeys looks up the JWK Set a client registered for private_key_jwt
+// authentication
+type ClientKeys interface {
+	ClientJWKS(clientID string) (json.RawMessage, bool)
+}
+
+// ReplayCache remembers the IDs of accepted assertions so that each is used once
+// per RFC 7523 section 3
+type ReplayCache interface {
+	// Claim records id until the given time, reporting false if it was
+	// already recorded
+	Claim(ctx context.Context, id string, until time.Time) (bool, error)
+}
+
+// AssertionConfig configures client assertion verification. Zero durations use
+// the package defaults.
+type AssertionConfig struct {
+	Keys        ClientKeys    // Registered client keys
+	Audiences   []string      // Accepted aud values, such as the proxy's endpoint URLs
+	Replay      ReplayCache   // A MemoryReplayCache if nil
+	MaxLifetime time.Duration // Latest accepted exp, relative to now
+	Leeway      time.Duration // Clock skew allowed on exp and nbf
+}
+
+// AssertionVerifier authenticates clients by their JWT assertions per RFC 7523
+// section 3, the private_key_jwt method of OpenID Connect Core 1.0 section 9
+type AssertionVerifier struct {
+	cfg AssertionConfig
+	now func() time.Time
+}
+
+// NewAssertionVerifier creates a client assertion verifier
+func NewAssertionVerifier(cfg AssertionConfig) *AssertionVerifier {
+	if cfg.Replay == nil {
+		cfg.Replay = NewMemoryReplayCache()
+	}
+	if cfg.MaxLifetime <= 0 {
+		cfg.MaxLifetime = DefaultMaxAssertionLifetime
+	}
+	if cfg.Leeway <= 0 {
+		cfg.Leeway = DefaultLeeway
+	}
+	return &AssertionVerifier{cfg: cfg, now: time.Now}
+}
+
+// VerifyAssertion checks a client assertion against the keys of the client it
+// names and returns that client's ID. Rejected assertions return an error
+// wrapping ErrInvalidAssertion; other errors mean it could not be checked.
+func (v *AssertionVerifier) VerifyAssertion(ctx context.Context, assertion string) (string, error) {
+	t, err := parseJWT(assertion)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidAssertion, err)
+	}
+
+	claims := t.claims
+	clientID := claims.Subject
+	if clientID == "" || claims.Issuer != clientID {
+		return "", fmt.Errorf("%w: iss and sub must both name the client", ErrInvalidAssertion)
+	}
+
+	key, err := v.clientKey(clientID, t.header.Kid)
+	if err != nil {
+		return "", err
+	}
+	if err := t.verifySignature(key); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidAssertion, err)
+	}
+
+	now := v.now()
+	if claims.ExpiresAt == 0 {
+		return "", fmt.Errorf("%w: missing exp claim", ErrInvalidAssertion)
+	}
+	expiresAt := time.Unix(claims.ExpiresAt, 0)
+	if now.After(expiresAt.Add(v.cfg.Leeway)) {
+		return "", fmt.Errorf("%w: assertion expired", ErrInvalidAssertion)
+	}
+	if expiresAt.After(now.Add(v.cfg.MaxLifetime + v.cfg.Leeway)) {
+		return "", fmt.Errorf("%w: exp too far in the future", ErrInvalidAssertion)
+	}
+	if claims.NotBefore != 0 && now.Add(v.cfg.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return "", fmt.Errorf("%w: assertion not yet valid", ErrInvalidAssertion)
+	}
+	if !v.audienceAccepted(claims.Audience) {
+		return "", fmt.Errorf("%w: audience mismatch", ErrInvalidAssertion)
+	}
+	if claims.ID == "" {
+		return "", fmt.Errorf("%w: missing jti claim", ErrInvalidAssertion)
+	}
+
+	// Assertion IDs are only unique per issuer
+	fresh, err := v.cfg.Replay.Claim(ctx, clientID+":"+claims.ID, expiresAt.Add(v.cfg.Leeway))
+	if err != nil {
+		return "", fmt.Errorf("recording assertion ID: %w", err)
+	}
+	if !fresh {
+		return "", fmt.Errorf("%w: assertion already used", ErrInvalidAssertion)
+	}
+
+	return clientID, nil
+}
+
+// clientKey returns the registered key of the client matching kid. Without a
+// kid, the client must have registered a single key.
+func (v *AssertionVerifier) clientKey(clientID, kid string) (crypto.PublicKey, error) {
+	if v.cfg.Keys == nil {
+		return nil, fmt.Errorf("%w: no client keys configured", ErrInvalidAssertion)
+	}
+	raw, ok := v.cfg.Keys.ClientJWKS(clientID)
+	if !ok {
+		return nil, fmt.Errorf("%w: client %q has no registered keys", ErrInvalidAssertion, clientID)
+	}
+	keys, err := parseKeySet(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAssertion, err)
+	}
+
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %w", ErrInvalidAssertion, errUnknownKey)
+}
+
+// audienceAccepted reports whether the aud claim names this server
+func (v *AssertionVerifier) audienceAccepted(aud audience) bool {
+	for _, accepted := range v.cfg.Audiences {
+		if aud.contains(accepted) {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryReplayCache remembers assertion IDs in process memory. Each proxy
+// instance sees only its own requests, so deployments with several replicas
+// should use RedisReplayCache.
+type MemoryReplayCache struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+	now func() time.Time
+}
+
+// NewMemoryReplayCache creates an empty in-memory replay cache
+func NewMemoryReplayCache() *MemoryReplayCache {
+	return &MemoryReplayCache{ids: make(map[string]time.Time), now: time.Now}
+}
+
+// Claim implements ReplayCache
+func (m *MemoryReplayCache) Claim(ctx context.Context, id string, until time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if expiresAt, ok := m.ids[id]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+	for seen, expiresAt := range m.ids {
+		if !now.Before(expiresAt) {
+			delete(m.ids, seen)
+		}
+	}
+	m.ids[id] = until
+	return true, nil
+}
+
+// replayPrefix namespaces assertion IDs in Redis, mirrored by keyspace.Patterns
+const replayPrefix = "assertion:"
+
+// RedisReplayCache remembers assertion IDs in Redis, shared by all proxy instances
+type RedisReplayCache struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisReplayCache creates a Redis-backed replay cache. The key prefix
+// namespaces its keys as REDIS_KEY_PREFIX does for the other stores.
+func NewRedisReplayCache(client *redis.Client, keyPrefix string) *RedisReplayCache {
+	return &RedisReplayCache{client: client, prefix: keyPrefix}
+}
+
+// Claim implements ReplayCache
+func (r *RedisReplayCache) Claim(ctx context.Context, id string, until time.Time) (bool, error) {
+	ttl := time.Until(until)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return r.client.SetNX(ctx, r.prefix+replayPrefix+cacheKey(id), 1, ttl).Result()
+}
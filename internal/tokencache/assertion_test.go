@@ -0,0 +1,162 @@
+package tokencache
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+)
+
+const testAudience = "https://proxy.example.com/device/token"
+
+// staticKeys registers one public key per client
+type staticKeys map[string]crypto.PublicKey
+
+func (k staticKeys) ClientJWKS(clientID string) (json.RawMessage, bool) {
+	key, ok := k[clientID]
+	if !ok {
+		return nil, false
+	}
+	var entry jwk
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		entry = jwk{Kty: "RSA", Kid: "device-1", N: b64(pub.N.Bytes()), E: b64([]byte{1, 0, 1})}
+	case *ecdsa.PublicKey:
+		entry = jwk{Kty: "EC", Kid: "device-1", Crv: "P-256",
+			X: b64(pub.X.FillBytes(make([]byte, 32))), Y: b64(pub.Y.FillBytes(make([]byte, 32)))}
+	}
+	raw, _ := json.Marshal(map[string]any{"keys": []jwk{entry}})
+	return raw, true
+}
+
+// newSigner creates an assertion signer for the client
+func newSigner(t *testing.T, clientID, audience string, key crypto.Signer) *oauth.AssertionSigner {
+	t.Helper()
+	signer, err := oauth.NewAssertionSigner(oauth.AssertionConfig{
+		ClientID: clientID,
+		Audience: audience,
+		Key:      key,
+		KeyID:    "device-1",
+	})
+	if err != nil {
+		t.Fatalf("NewAssertionSigner failed: %v", err)
+	}
+	return signer
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := NewAssertionVerifier(AssertionConfig{
+		Keys:      staticKeys{"tv": &rsaKey.PublicKey, "printer": &ecKey.PublicKey},
+		Audiences: []string{testAudience},
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		signer  *oauth.AssertionSigner
+		wantErr bool
+	}{
+		{name: "RSA key", signer: newSigner(t, "tv", testAudience, rsaKey)},
+		{name: "EC key", signer: newSigner(t, "printer", testAudience, ecKey)},
+		{name: "wrong audience", signer: newSigner(t, "tv", "https://other.example.com/token", rsaKey), wantErr: true},
+		{name: "unregistered key", signer: newSigner(t, "printer", testAudience, otherKey), wantErr: true},
+		{name: "unknown client", signer: newSigner(t, "toaster", testAudience, ecKey), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion, err := tt.signer.Sign()
+			if err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+			clientID, err := verifier.VerifyAssertion(ctx, assertion)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAssertion) {
+					t.Errorf("VerifyAssertion() error = %v, want ErrInvalidAssertion", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("VerifyAssertion failed: %v", err)
+			}
+			if clientID != "tv" && clientID != "printer" {
+				t.Errorf("client ID = %q", clientID)
+			}
+
+			// Each assertion is accepted once
+			if _, err := verifier.VerifyAssertion(ctx, assertion); !errors.Is(err, ErrInvalidAssertion) {
+				t.Errorf("replayed assertion error = %v, want ErrInvalidAssertion", err)
+			}
+		})
+	}
+}
+
+func TestVerifyAssertion_Claims(t *testing.T) {
+	iss := newTestIssuer(t)
+	iss.addRSAKey("device-1")
+	key := iss.rsaKeys["device-1"]
+	verifier := NewAssertionVerifier(AssertionConfig{
+		Keys:      staticKeys{"tv": &key.PublicKey},
+		Audiences: []string{testAudience},
+	})
+
+	now := time.Now()
+	claims := func(mutate func(map[string]any)) map[string]any {
+		c := map[string]any{
+			"iss": "tv",
+			"sub": "tv",
+			"aud": testAudience,
+			"jti": now.String(),
+			"exp": now.Add(time.Minute).Unix(),
+		}
+		mutate(c)
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		wantErr bool
+	}{
+		{"valid", claims(func(c map[string]any) {}), false},
+		{"issuer differs from subject", claims(func(c map[string]any) { c["iss"] = "printer" }), true},
+		{"missing jti", claims(func(c map[string]any) { delete(c, "jti") }), true},
+		{"missing exp", claims(func(c map[string]any) { delete(c, "exp") }), true},
+		{"expired", claims(func(c map[string]any) { c["exp"] = now.Add(-time.Hour).Unix() }), true},
+		{"long-lived", claims(func(c map[string]any) { c["exp"] = now.Add(time.Hour).Unix() }), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := iss.sign("RS256", "device-1", tt.claims)
+			_, err := verifier.VerifyAssertion(context.Background(), token)
+			if tt.wantErr && !errors.Is(err, ErrInvalidAssertion) {
+				t.Errorf("VerifyAssertion() error = %v, want ErrInvalidAssertion", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("VerifyAssertion failed: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// Package tokencache validates access tokens locally against the provider's
+// JWKS, falling back to cached introspection for opaque tokens or unknown keys,
+// so that token validation does not call the provider on every request
+package tokencache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+)
+
+// Cache defaults
+const (
+	DefaultJWKSMaxAge       = time.Hour
+	DefaultJWKSMinRefresh   = time.Minute
+	DefaultIntrospectionTTL = time.Minute
+	DefaultMaxEntries       = 10000
+	DefaultLeeway           = 30 * time.Second
+)
+
+// Introspector validates tokens with the provider, as oauth.Provider does
+type Introspector interface {
+	ValidateToken(ctx context.Context, token string) (*oauth.TokenInfo, error)
+}
+
+// Config configures token validation. Zero durations use the package defaults.
+type Config struct {
+	JWKSURL          string        // Provider JWKS endpoint, local validation is disabled when empty
+	Issuer           string        // Required iss claim, unchecked when empty
+	Audience         string        // Required aud entry, unchecked when empty
+	ClientID         string        // Required aud of ID tokens issued to this proxy
+	HTTPClient       *http.Client  // Client for JWKS requests, http.DefaultClient if nil
+	Introspector     Introspector  // Fallback for opaque tokens and unknown keys
+	JWKSMaxAge       time.Duration // Keys are refetched after this age
+	JWKSMinRefresh   time.Duration // Minimum time between refetches for unknown kids
+	IntrospectionTTL time.Duration // Upper bound on caching an introspection result
+	MaxEntries       int           // Maximum cached introspection results
+	Leeway           time.Duration // Clock skew allowed on exp and nbf
+}
+
+// Cache validates access tokens, implementing the validation half of oauth.Provider
+type Cache struct {
+	cfg          Config
+	keys         *keySet
+	introspector Introspector
+	now          func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a cached introspection result
+type cacheEntry struct {
+	info      *oauth.TokenInfo
+	expiresAt time.Time
+}
+
+// New creates a token validation cache
+func New(cfg Config) *Cache {
+	if cfg.JWKSMaxAge <= 0 {
+		cfg.JWKSMaxAge = DefaultJWKSMaxAge
+	}
+	if cfg.JWKSMinRefresh <= 0 {
+		cfg.JWKSMinRefresh = DefaultJWKSMinRefresh
+	}
+	if cfg.IntrospectionTTL <= 0 {
+		cfg.IntrospectionTTL = DefaultIntrospectionTTL
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultMaxEntries
+	}
+	if cfg.Leeway <= 0 {
+		cfg.Leeway = DefaultLeeway
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	c := &Cache{
+		cfg:          cfg,
+		introspector: cfg.Introspector,
+		now:          time.Now,
+		entries:      make(map[string]cacheEntry),
+	}
+	if cfg.JWKSURL != "" {
+		c.keys = &keySet{
+			url:         cfg.JWKSURL,
+			client:      cfg.HTTPClient,
+			maxAge:      cfg.JWKSMaxAge,
+			minInterval: cfg.JWKSMinRefresh,
+			now:         func() time.Time { return c.now() },
+		}
+	}
+	return c
+}
+
+// ValidateToken validates an access token, returning oauth.ErrInvalidToken or
+// oauth.ErrTokenExpired for tokens that must be rejected
+func (c *Cache) ValidateToken(ctx context.Context, token string) (*oauth.TokenInfo, error) {
+	if c.keys != nil {
+		info, err := c.validateJWT(ctx, token)
+		if err == nil {
+			return info, nil
+		}
+		if !c.canFallBack(err) {
+			return nil, err
+		}
+	}
+
+	if c.introspector == nil {
+		return nil, oauth.ErrInvalidToken
+	}
+	return c.introspect(ctx, token)
+}
+
+// canFallBack reports whether a local validation failure may be retried by
+// introspection. Tokens with bad signatures or claims are rejected outright.
+func (c *Cache) canFallBack(err error) bool {
+	if c.introspector == nil {
+		return false
+	}
+	return errors.Is(err, errNotJWT) || errors.Is(err, errUnknownKey) || errors.Is(err, oauth.ErrProviderUnavailable)
+}
+
+// validateJWT verifies the token signature and registered claims locally
+func (c *Cache) validateJWT(ctx context.Context, token string) (*oauth.TokenInfo, error) {
+	claims, err := c.verifyJWT(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.Audience != "" && !claims.Audience.contains(c.cfg.Audience) {
+		return nil, fmt.Errorf("%w: audience mismatch", oauth.ErrInvalidToken)
+	}
+
+	return &oauth.TokenInfo{
+		Active:    true,
+		Subject:   claims.Subject,
+		ClientID:  claims.ClientID,
+		Username:  claims.PreferredUsername,
+		Scope:     claims.Scope,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+		IssuedAt:  time.Unix(claims.IssuedAt, 0),
+		Issuer:    claims.Issuer,
+	}, nil
+}
+
+// verifyJWT checks the signature, lifetime and issuer of a JWT, returning its claims
+func (c *Cache) verifyJWT(ctx context.Context, token string) (*jwtClaims, error) {
+	t, err := parseJWT(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", oauth.ErrInvalidToken, err)
+	}
+
+	key, err := c.keys.key(ctx, t.header.Kid)
+	if err != nil {
+		if errors.Is(err, errUnknownKey) {
+			return nil, fmt.Errorf("%w: %w", oauth.ErrInvalidToken, err)
+		}
+		return nil, fmt.Errorf("%w: %v", oauth.ErrProviderUnavailable, err)
+	}
+
+	if err := t.verifySignature(key); err != nil {
+		return nil, fmt.Errorf("%w: %v", oauth.ErrInvalidToken, err)
+	}
+
+	now := c.now()
+	claims := t.claims
+	if claims.ExpiresAt == 0 {
+		return nil, fmt.Errorf("%w: missing exp claim", oauth.ErrInvalidToken)
+	}
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(c.cfg.Leeway)) {
+		return nil, oauth.ErrTokenExpired
+	}
+	if claims.NotBefore != 0 && now.Add(c.cfg.Leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, fmt.Errorf("%w: token not yet valid", oauth.ErrInvalidToken)
+	}
+	if c.cfg.Issuer != "" && claims.Issuer != c.cfg.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", oauth.ErrInvalidToken, claims.Issuer)
+	}
+
+	return &claims, nil
+}
+
+// introspect validates the token with the provider, caching active results
+// until the token expires or the introspection TTL passes, whichever is first
+func (c *Cache) introspect(ctx context.Context, token string) (*oauth.TokenInfo, error) {
+	key := cacheKey(token)
+	now := c.now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		info := *entry.info
+		return &info, nil
+	}
+
+	info, err := c.introspector.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := now.Add(c.cfg.IntrospectionTTL)
+	if !info.ExpiresAt.IsZero() && info.ExpiresAt.Before(expiresAt) {
+		expiresAt = info.ExpiresAt
+	}
+
+	c.mu.Lock()
+	c.evictLocked(now)
+	c.entries[key] = cacheEntry{info: info, expiresAt: expiresAt}
+	c.mu.Unlock()
+
+	copied := *info
+	return &copied, nil
+}
+
+// evictLocked makes room for a new entry, dropping expired entries first.
+// Callers hold c.mu.
+func (c *Cache) evictLocked(now time.Time) {
+	if len(c.entries) < c.cfg.MaxEntries {
+		return
+	}
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	for key := range c.entries {
+		if len(c.entries) < c.cfg.MaxEntries {
+			break
+		}
+		delete(c.entries, key)
+	}
+}
+
+// cacheKey hashes the token so that raw bearer tokens are not held as map keys
+func cacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,257 @@
+package tokencache
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+)
+
+// testIssuer signs tokens and serves its keys as a JWKS
+type testIssuer struct {
+	t       *testing.T
+	rsaKeys map[string]*rsa.PrivateKey
+	ecKey   *ecdsa.PrivateKey
+	fetches atomic.Int32
+	server  *httptest.Server
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	iss := &testIssuer{t: t, rsaKeys: map[string]*rsa.PrivateKey{}}
+	iss.addRSAKey("rsa-1")
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss.ecKey = ecKey
+
+	iss.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		iss.fetches.Add(1)
+		var keys []jwk
+		for kid, k := range iss.rsaKeys {
+			keys = append(keys, jwk{
+				Kty: "RSA", Kid: kid, Use: "sig",
+				N: b64(k.N.Bytes()),
+				E: b64([]byte{1, 0, 1}),
+			})
+		}
+		keys = append(keys, jwk{
+			Kty: "EC", Kid: "ec-1", Crv: "P-256",
+			X: b64(iss.ecKey.X.FillBytes(make([]byte, 32))),
+			Y: b64(iss.ecKey.Y.FillBytes(make([]byte, 32))),
+		})
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	t.Cleanup(iss.server.Close)
+	return iss
+}
+
+func (iss *testIssuer) addRSAKey(kid string) {
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		iss.t.Fatal(err)
+	}
+	iss.rsaKeys[kid] = k
+}
+
+// sign builds a token signed with the named key
+func (iss *testIssuer) sign(alg, kid string, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	var err error
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, iss.rsaKeys[kid], crypto.SHA256, digest[:])
+	case "ES256":
+		r, s, signErr := ecdsa.Sign(rand.Reader, iss.ecKey, digest[:])
+		err = signErr
+		sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	if err != nil {
+		iss.t.Fatal(err)
+	}
+	return input + "." + b64(sig)
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":   "https://idp.example/realms/test",
+		"sub":   "user-1",
+		"aud":   []string{"device-proxy"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"azp":   "tv",
+		"scope": "openid",
+	}
+}
+
+// countingIntrospector records calls and returns a fixed result
+type countingIntrospector struct {
+	calls atomic.Int32
+	info  *oauth.TokenInfo
+	err   error
+}
+
+func (c *countingIntrospector) ValidateToken(ctx context.Context, token string) (*oauth.TokenInfo, error) {
+	c.calls.Add(1)
+	return c.info, c.err
+}
+
+func TestValidateJWT(t *testing.T) {
+	iss := newTestIssuer(t)
+	cache := New(Config{
+		JWKSURL:  iss.server.URL,
+		Issuer:   "https://idp.example/realms/test",
+		Audience: "device-proxy",
+	})
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	wrongIssuer := validClaims()
+	wrongIssuer["iss"] = "https://evil.example"
+	wrongAudience := validClaims()
+	wrongAudience["aud"] = "other"
+
+	tampered := iss.sign("RS256", "rsa-1", validClaims())
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "valid RS256", token: iss.sign("RS256", "rsa-1", validClaims())},
+		{name: "valid ES256", token: iss.sign("ES256", "ec-1", validClaims())},
+		{name: "expired", token: iss.sign("RS256", "rsa-1", expired), wantErr: oauth.ErrTokenExpired},
+		{name: "wrong issuer", token: iss.sign("RS256", "rsa-1", wrongIssuer), wantErr: oauth.ErrInvalidToken},
+		{name: "wrong audience", token: iss.sign("RS256", "rsa-1", wrongAudience), wantErr: oauth.ErrInvalidToken},
+		{name: "bad signature", token: tampered, wantErr: oauth.ErrInvalidToken},
+		{name: "opaque token without introspection", token: "opaque", wantErr: oauth.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := cache.ValidateToken(context.Background(), tt.token)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ValidateToken() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateToken() error = %v", err)
+			}
+			if !info.Active || info.Subject != "user-1" || info.ClientID != "tv" {
+				t.Errorf("ValidateToken() = %+v", info)
+			}
+		})
+	}
+
+	if got := iss.fetches.Load(); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", got)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	iss := newTestIssuer(t)
+	cache := New(Config{JWKSURL: iss.server.URL, JWKSMinRefresh: time.Minute})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	if _, err := cache.ValidateToken(context.Background(), iss.sign("RS256", "rsa-1", validClaims())); err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+
+	// A token signed by a new key within the refresh interval is not yet trusted
+	iss.addRSAKey("rsa-2")
+	rotated := iss.sign("RS256", "rsa-2", validClaims())
+	if _, err := cache.ValidateToken(context.Background(), rotated); err == nil {
+		t.Fatal("expected unknown key to be rejected within the refresh interval")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.ValidateToken(context.Background(), rotated); err != nil {
+		t.Fatalf("ValidateToken() after rotation error = %v", err)
+	}
+	if got := iss.fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
+
+func TestIntrospectionFallback(t *testing.T) {
+	iss := newTestIssuer(t)
+	introspector := &countingIntrospector{info: &oauth.TokenInfo{
+		Active:    true,
+		Subject:   "user-2",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}}
+	cache := New(Config{JWKSURL: iss.server.URL, Introspector: introspector})
+
+	for i := 0; i < 3; i++ {
+		info, err := cache.ValidateToken(context.Background(), "opaque-token")
+		if err != nil {
+			t.Fatalf("ValidateToken() error = %v", err)
+		}
+		if info.Subject != "user-2" {
+			t.Errorf("Subject = %q, want user-2", info.Subject)
+		}
+	}
+	if got := introspector.calls.Load(); got != 1 {
+		t.Errorf("introspected %d times, want 1", got)
+	}
+
+	// Unknown signing keys fall back to the provider
+	unknown := iss.sign("RS256", "rsa-1", validClaims())
+	delete(iss.rsaKeys, "rsa-1")
+	cache = New(Config{JWKSURL: iss.server.URL, Introspector: introspector})
+	if _, err := cache.ValidateToken(context.Background(), unknown); err != nil {
+		t.Fatalf("ValidateToken() with unknown key error = %v", err)
+	}
+	if got := introspector.calls.Load(); got != 2 {
+		t.Errorf("introspected %d times, want 2", got)
+	}
+}
+
+func TestIntrospectionCacheExpiry(t *testing.T) {
+	introspector := &countingIntrospector{info: &oauth.TokenInfo{Active: true}}
+	cache := New(Config{Introspector: introspector, IntrospectionTTL: time.Minute})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	cache.ValidateToken(context.Background(), "opaque")
+	now = now.Add(2 * time.Minute)
+	cache.ValidateToken(context.Background(), "opaque")
+
+	if got := introspector.calls.Load(); got != 2 {
+		t.Errorf("introspected %d times, want 2 after TTL", got)
+	}
+
+	introspector.err = oauth.ErrInvalidToken
+	now = now.Add(2 * time.Minute)
+	if _, err := cache.ValidateToken(context.Background(), "opaque"); !errors.Is(err, oauth.ErrInvalidToken) {
+		t.Errorf("ValidateToken() error = %v, want %v", err, oauth.ErrInvalidToken)
+	}
+}
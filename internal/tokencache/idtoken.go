@@ -0,0 +1,56 @@
+package tokencache
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+)
+
+// ValidateIDToken validates an ID token received from the token endpoint per
+// OpenID Connect Core 1.0 section 3.1.3.7. The token must be signed by a JWKS
+// key, name the configured issuer, and be issued to the configured client. A
+// non-empty nonce must match the nonce claim exactly.
+func (c *Cache) ValidateIDToken(ctx context.Context, token, nonce string) (*oauth.IDTokenClaims, error) {
+	if c.keys == nil {
+		return nil, errors.New("ID token validation requires a JWKS URL")
+	}
+	if c.cfg.ClientID == "" {
+		return nil, errors.New("ID token validation requires a client ID")
+	}
+
+	claims, err := c.verifyJWT(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub claim", oauth.ErrInvalidToken)
+	}
+	if !claims.Audience.contains(c.cfg.ClientID) {
+		return nil, fmt.Errorf("%w: audience mismatch", oauth.ErrInvalidToken)
+	}
+
+	// With several audiences the authorized party must be this client
+	if len(claims.Audience) > 1 && claims.ClientID != c.cfg.ClientID {
+		return nil, fmt.Errorf("%w: unexpected authorized party %q", oauth.ErrInvalidToken, claims.ClientID)
+	}
+	if nonce != "" && subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", oauth.ErrInvalidToken)
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.PreferredUsername
+	}
+	return &oauth.IDTokenClaims{
+		Subject:   claims.Subject,
+		Email:     claims.Email,
+		Name:      name,
+		Issuer:    claims.Issuer,
+		Nonce:     claims.Nonce,
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0),
+	}, nil
+}
@@ -0,0 +1,69 @@
+package tokencache
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/wrale/oauth2-device-proxy/internal/oauth"
+)
+
+// idClaims returns the claims of a valid ID token issued to device-proxy
+func idClaims() map[string]any {
+	claims := validClaims()
+	claims["azp"] = "device-proxy"
+	claims["email"] = "user@example.com"
+	claims["preferred_username"] = "user1"
+	claims["nonce"] = "nonce-1"
+	return claims
+}
+
+func TestValidateIDToken(t *testing.T) {
+	iss := newTestIssuer(t)
+	cache := New(Config{
+		JWKSURL:  iss.server.URL,
+		Issuer:   "https://idp.example/realms/test",
+		ClientID: "device-proxy",
+	})
+
+	otherAudience := idClaims()
+	otherAudience["aud"] = "tv"
+	otherParty := idClaims()
+	otherParty["aud"] = []string{"device-proxy", "tv"}
+	otherParty["azp"] = "tv"
+	noSubject := idClaims()
+	delete(noSubject, "sub")
+
+	tests := []struct {
+		name    string
+		claims  map[string]any
+		nonce   string
+		wantErr error
+	}{
+		{name: "valid", claims: idClaims(), nonce: "nonce-1"},
+		{name: "nonce not checked when none was sent", claims: idClaims()},
+		{name: "nonce mismatch", claims: idClaims(), nonce: "nonce-2", wantErr: oauth.ErrInvalidToken},
+		{name: "issued to another client", claims: otherAudience, wantErr: oauth.ErrInvalidToken},
+		{name: "authorized party is another client", claims: otherParty, wantErr: oauth.ErrInvalidToken},
+		{name: "missing subject", claims: noSubject, wantErr: oauth.ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := iss.sign("RS256", "rsa-1", tt.claims)
+			claims, err := cache.ValidateIDToken(context.Background(), token, tt.nonce)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ValidateIDToken() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateIDToken() error = %v", err)
+			}
+			if claims.Subject != "user-1" || claims.Email != "user@example.com" || claims.Name != "user1" {
+				t.Errorf("ValidateIDToken() = %+v", claims)
+			}
+		})
+	}
+}
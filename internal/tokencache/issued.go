@@ -0,0 +1,73 @@
+package tokencache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/wrale/oauth2-device-proxy/internal/deviceflow"
+)
+
+// DefaultIssuedTTL is how long the client of an access token without a
+// known lifetime is remembered
+const DefaultIssuedTTL = time.Hour
+
+// issuedPrefix namespaces the client each delivered access token belongs to
+const issuedPrefix = "issued:"
+
+// IssuedTokens remembers which client each access token was delivered to.
+// The authorization server issues device tokens to the proxy's own client, so
+// their azp cannot tell device clients apart; requests presenting a token on
+// behalf of a client are checked against this record instead.
+type IssuedTokens struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewIssuedTokens creates a Redis-backed record of delivered tokens. The key
+// prefix namespaces its keys as REDIS_KEY_PREFIX does for the other stores.
+func NewIssuedTokens(client *redis.Client, keyPrefix string) *IssuedTokens {
+	return &IssuedTokens{client: client, prefix: keyPrefix}
+}
+
+// EnrichToken implements deviceflow.TokenEnricher, recording the client of
+// each token the device flow delivers. The token response is unchanged.
+func (i *IssuedTokens) EnrichToken(ctx context.Context, code *deviceflow.DeviceCode, token *deviceflow.TokenResponse) (*deviceflow.TokenResponse, error) {
+	ttl := DefaultIssuedTTL
+	if token.ExpiresIn > 0 {
+		ttl = time.Duration(token.ExpiresIn) * time.Second
+	}
+	if err := i.Remember(ctx, token.AccessToken, code.ClientID, ttl); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Remember records that accessToken was delivered to clientID for ttl
+func (i *IssuedTokens) Remember(ctx context.Context, accessToken, clientID string, ttl time.Duration) error {
+	if err := i.client.Set(ctx, i.key(accessToken), clientID, ttl).Err(); err != nil {
+		return fmt.Errorf("recording issued token: %w", err)
+	}
+	return nil
+}
+
+// ClientOf returns the client accessToken was delivered to, or an empty
+// string if the proxy did not deliver it or the record expired
+func (i *IssuedTokens) ClientOf(ctx context.Context, accessToken string) (string, error) {
+	clientID, err := i.client.Get(ctx, i.key(accessToken)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("loading issued token: %w", err)
+	}
+	return clientID, nil
+}
+
+// key hashes the token so that raw bearer tokens are not stored as keys
+func (i *IssuedTokens) key(accessToken string) string {
+	return i.prefix + issuedPrefix + cacheKey(accessToken)
+}
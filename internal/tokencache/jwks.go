@@ -0,0 +1,183 @@
+package tokencache
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errUnknownKey is returned when no JWKS key matches a token's kid
+var errUnknownKey = errors.New("signing key not found in JWKS")
+
+// jwk is a JSON Web Key per RFC 7517 section 4, limited to RSA and EC public keys
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keySet caches the provider's signing keys, refreshing them periodically and
+// when a token references an unknown kid so that key rotation is picked up
+type keySet struct {
+	url         string
+	client      *http.Client
+	maxAge      time.Duration // Keys are refetched after this age
+	minInterval time.Duration // Unknown kids trigger at most one fetch per interval
+	now         func() time.Time
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+// key returns the public key for kid, fetching the JWKS when it is stale or
+// the kid is unknown
+func (s *keySet) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	age := s.now().Sub(s.fetchedAt)
+	if key, ok := s.keys[kid]; ok && age < s.maxAge {
+		return key, nil
+	}
+
+	// Rotation: refetch for unknown kids, but not more often than minInterval
+	if s.keys == nil || age >= s.minInterval {
+		if err := s.fetch(ctx); err != nil {
+			// Serve known keys through a failed refresh
+			if key, ok := s.keys[kid]; ok {
+				return key, nil
+			}
+			return nil, err
+		}
+	}
+
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+// fetch replaces the cached keys with the current JWKS. Callers hold s.mu.
+func (s *keySet) fetch(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return fmt.Errorf("creating JWKS request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JWKS: %s", resp.Status)
+	}
+
+	keys, err := parseKeySet(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	s.keys = keys
+	s.fetchedAt = s.now()
+	return nil
+}
+
+// parseKeySet decodes the signing keys of a JWK Set per RFC 7517 section 5,
+// indexed by kid
+func parseKeySet(r io.Reader) (map[string]crypto.PublicKey, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue // Skip keys of unsupported types
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+// publicKey decodes an RSA or EC public key
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// decodeBigInt decodes a base64url unsigned big-endian integer per RFC 7518 section 2
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
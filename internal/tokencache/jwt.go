@@ -0,0 +1,156 @@
+package tokencache
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// errNotJWT marks opaque tokens that can only be validated by introspection
+var errNotJWT = errors.New("token is not a JWT")
+
+// jwtHeader is the JOSE header of a signed JWT per RFC 7515 section 4
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Typ string `json:"typ"`
+}
+
+// jwtClaims holds the registered claims used for validation per RFC 7519 section 4.1
+type jwtClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	ExpiresAt         int64    `json:"exp"`
+	NotBefore         int64    `json:"nbf"`
+	IssuedAt          int64    `json:"iat"`
+	ClientID          string   `json:"azp"`
+	Scope             string   `json:"scope"`
+	PreferredUsername string   `json:"preferred_username"`
+	Email             string   `json:"email"`
+	Name              string   `json:"name"`
+	Nonce             string   `json:"nonce"`
+	ID                string   `json:"jti"`
+}
+
+// audience accepts the aud claim as either a string or an array of strings
+type audience []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// contains reports whether the audience includes aud
+func (a audience) contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// parsedJWT is a decoded but not yet verified token
+type parsedJWT struct {
+	header       jwtHeader
+	claims       jwtClaims
+	signingInput string
+	signature    []byte
+}
+
+// parseJWT decodes a compact serialized JWS without verifying it
+func parseJWT(token string) (*parsedJWT, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errNotJWT
+	}
+
+	var t parsedJWT
+	if err := decodeSegment(parts[0], &t.header); err != nil {
+		return nil, errNotJWT
+	}
+	if err := decodeSegment(parts[1], &t.claims); err != nil {
+		return nil, fmt.Errorf("decoding claims: %w", err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decoding signature: %w", err)
+	}
+	t.signature = sig
+	t.signingInput = parts[0] + "." + parts[1]
+
+	return &t, nil
+}
+
+// decodeSegment decodes a base64url JSON segment
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature checks the token signature for the asymmetric algorithms
+// defined in RFC 7518 section 3. The "none" and HMAC algorithms are rejected.
+func (t *parsedJWT) verifySignature(key crypto.PublicKey) error {
+	var hash crypto.Hash
+	switch t.header.Alg {
+	case "RS256", "ES256", "PS256":
+		hash = crypto.SHA256
+	case "RS384", "ES384", "PS384":
+		hash = crypto.SHA384
+	case "RS512", "ES512", "PS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", t.header.Alg)
+	}
+
+	h := hash.New()
+	h.Write([]byte(t.signingInput))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch t.header.Alg[0] {
+		case 'R':
+			return rsa.VerifyPKCS1v15(k, hash, digest, t.signature)
+		case 'P':
+			return rsa.VerifyPSS(k, hash, digest, t.signature, nil)
+		}
+	case *ecdsa.PublicKey:
+		if t.header.Alg[0] != 'E' {
+			break
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(t.signature) != 2*size {
+			return errors.New("invalid ECDSA signature length")
+		}
+		r := new(big.Int).SetBytes(t.signature[:size])
+		s := new(big.Int).SetBytes(t.signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("algorithm %q does not match key type", t.header.Alg)
+}
@@ -0,0 +1,142 @@
+// Package ttl declares the lifetimes of all stored device flow state in one
+// place so that related expirations are configured and validated together
+package ttl
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MinDeviceCode is the shortest device code lifetime. RFC 8628 section 3.2 leaves
+// expires_in to the server; ten minutes gives users time to complete sign-in.
+const MinDeviceCode = 10 * time.Minute
+
+// DefaultMaxDeviceCode caps device code lifetimes unless configured otherwise,
+// so that a mistyped expiry cannot leave codes open to phishing for hours
+const DefaultMaxDeviceCode = 30 * time.Minute
+
+// MaxBatchCode is the longest lifetime MaxBatchCode may allow pre-generated
+// batch codes, which kiosks may hold offline for weeks
+const MaxBatchCode = 30 * 24 * time.Hour
+
+// Policy holds the lifetimes of device flow state
+type Policy struct {
+	// DeviceCode is the lifetime of device and user codes, returned as expires_in
+	DeviceCode time.Duration
+
+	// MaxDeviceCode caps DeviceCode, DefaultMaxDeviceCode when zero
+	MaxDeviceCode time.Duration
+
+	// MaxBatchCode caps the lifetime of pre-generated batch codes. Zero caps
+	// them at the device code maximum like any other code; longer lifetimes
+	// must be allowed explicitly.
+	MaxBatchCode time.Duration
+
+	// Token bounds how long an issued token waits for the device to collect it
+	Token time.Duration
+
+	// RateLimitWindow is the window over which token polls are counted, and so
+	// how long poll history is retained
+	RateLimitWindow time.Duration
+
+	// Submission is how long verification form results are kept for answering
+	// duplicate submissions
+	Submission time.Duration
+
+	// CSRFToken is the lifetime of verification form CSRF tokens
+	CSRFToken time.Duration
+
+	// ExpiredGrace is how long a tombstone outlives a device code after it
+	// expires or is revoked, so that polls and verifications are told the code
+	// expired or was already used rather than that it is unknown. Zero
+	// forgets codes as soon as they are gone.
+	ExpiredGrace time.Duration
+}
+
+// Default returns the policy used when nothing is configured
+func Default() Policy {
+	return Policy{
+		DeviceCode:      15 * time.Minute,
+		MaxDeviceCode:   DefaultMaxDeviceCode,
+		Token:           15 * time.Minute,
+		RateLimitWindow: time.Minute,
+		Submission:      30 * time.Second,
+		CSRFToken:       time.Hour,
+		ExpiredGrace:    10 * time.Minute,
+	}
+}
+
+// Validate checks that every lifetime is positive, that the device code
+// lifetime is within its bounds and that state derived from a device code
+// never outlives it
+func (p Policy) Validate() error {
+	var errs []error
+
+	maxDeviceCode := p.DeviceCodeCap()
+	switch {
+	case maxDeviceCode < MinDeviceCode:
+		errs = append(errs, fmt.Errorf("maximum device code TTL %s is below the minimum of %s", maxDeviceCode, MinDeviceCode))
+	case p.DeviceCode < MinDeviceCode:
+		errs = append(errs, fmt.Errorf("device code TTL %s is below the minimum of %s", p.DeviceCode, MinDeviceCode))
+	case p.DeviceCode > maxDeviceCode:
+		errs = append(errs, fmt.Errorf("device code TTL %s exceeds the maximum of %s", p.DeviceCode, maxDeviceCode))
+	}
+	if p.MaxBatchCode != 0 {
+		switch {
+		case p.MaxBatchCode < maxDeviceCode:
+			errs = append(errs, fmt.Errorf("maximum batch code TTL %s is below the maximum device code TTL %s", p.MaxBatchCode, maxDeviceCode))
+		case p.MaxBatchCode > MaxBatchCode:
+			errs = append(errs, fmt.Errorf("maximum batch code TTL %s exceeds the limit of %s", p.MaxBatchCode, MaxBatchCode))
+		}
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"token", p.Token},
+		{"rate limit window", p.RateLimitWindow},
+		{"submission", p.Submission},
+	} {
+		switch {
+		case d.value <= 0:
+			errs = append(errs, fmt.Errorf("%s TTL must be positive", d.name))
+		case d.value > p.DeviceCode:
+			errs = append(errs, fmt.Errorf("%s TTL %s exceeds device code TTL %s", d.name, d.value, p.DeviceCode))
+		}
+	}
+	if p.CSRFToken <= 0 {
+		errs = append(errs, errors.New("CSRF token TTL must be positive"))
+	}
+	if p.ExpiredGrace < 0 {
+		errs = append(errs, errors.New("expired code grace must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// DeviceCodeCap returns the longest allowed device code lifetime
+func (p Policy) DeviceCodeCap() time.Duration {
+	if p.MaxDeviceCode <= 0 {
+		return DefaultMaxDeviceCode
+	}
+	return p.MaxDeviceCode
+}
+
+// BatchCodeCap returns the longest allowed batch code lifetime
+func (p Policy) BatchCodeCap() time.Duration {
+	if p.MaxBatchCode <= 0 {
+		return p.DeviceCodeCap()
+	}
+	return p.MaxBatchCode
+}
+
+// TokenTTL returns how long to keep a token issued for a code expiring at
+// expiresAt: the token TTL, cut short by the code's remaining lifetime
+func (p Policy) TokenTTL(expiresAt time.Time) time.Duration {
+	remaining := time.Until(expiresAt)
+	if p.Token > 0 && p.Token < remaining {
+		return p.Token
+	}
+	return remaining
+}
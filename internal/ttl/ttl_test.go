@@ -0,0 +1,61 @@
+package ttl
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(p *Policy)
+		wantErr string
+	}{
+		{name: "default policy", modify: func(p *Policy) {}},
+		{name: "device code below minimum", modify: func(p *Policy) { p.DeviceCode = 5 * time.Minute }, wantErr: "below the minimum"},
+		{name: "device code above maximum", modify: func(p *Policy) { p.DeviceCode = 2 * time.Hour }, wantErr: "exceeds the maximum of 30m0s"},
+		{name: "raised maximum", modify: func(p *Policy) { p.DeviceCode, p.MaxDeviceCode = time.Hour, time.Hour }},
+		{name: "default maximum", modify: func(p *Policy) { p.DeviceCode, p.MaxDeviceCode = time.Hour, 0 }, wantErr: "exceeds the maximum of 30m0s"},
+		{name: "maximum below minimum", modify: func(p *Policy) { p.MaxDeviceCode = 5 * time.Minute }, wantErr: "maximum device code TTL 5m0s is below the minimum"},
+		{name: "batch maximum", modify: func(p *Policy) { p.MaxBatchCode = 7 * 24 * time.Hour }},
+		{name: "batch maximum below code maximum", modify: func(p *Policy) { p.MaxBatchCode = 20 * time.Minute }, wantErr: "maximum batch code TTL 20m0s is below"},
+		{name: "batch maximum over limit", modify: func(p *Policy) { p.MaxBatchCode = MaxBatchCode + time.Hour }, wantErr: "exceeds the limit of 720h0m0s"},
+		{name: "token outlives device code", modify: func(p *Policy) { p.Token = time.Hour }, wantErr: "token TTL 1h0m0s exceeds device code TTL"},
+		{name: "rate limit window outlives device code", modify: func(p *Policy) { p.RateLimitWindow = time.Hour }, wantErr: "rate limit window TTL"},
+		{name: "zero submission", modify: func(p *Policy) { p.Submission = 0 }, wantErr: "submission TTL must be positive"},
+		{name: "zero CSRF token", modify: func(p *Policy) { p.CSRFToken = 0 }, wantErr: "CSRF token TTL must be positive"},
+		{name: "no expired code grace", modify: func(p *Policy) { p.ExpiredGrace = 0 }},
+		{name: "negative expired code grace", modify: func(p *Policy) { p.ExpiredGrace = -time.Minute }, wantErr: "grace must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Default()
+			tt.modify(&p)
+
+			err := p.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenTTL(t *testing.T) {
+	p := Default()
+	p.Token = 5 * time.Minute
+
+	if got := p.TokenTTL(time.Now().Add(10 * time.Minute)); got != 5*time.Minute {
+		t.Errorf("TokenTTL() = %s, want 5m", got)
+	}
+	if got := p.TokenTTL(time.Now().Add(time.Minute)); got > time.Minute || got <= 0 {
+		t.Errorf("TokenTTL() = %s, want at most the remaining 1m", got)
+	}
+}
@@ -2,10 +2,12 @@
 package validation
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"regexp"
 	"strings"
+	"unicode"
 )
 
 // Validation settings based on RFC 8628 section 6.1
@@ -126,9 +128,28 @@ func calculateEntropy(code string) float64 {
 	return entropy
 }
 
-// NormalizeCode converts a user code to the canonical storage format
+// NormalizeCode converts a user code to the canonical storage format,
+// accepting any case and any display variant: codes grouped with spaces,
+// dots, underscores or dashes of any kind, or not grouped at all
 func NormalizeCode(code string) string {
-	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	return strings.Map(func(r rune) rune {
+		if IsCodeSeparator(r) {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, code)
+}
+
+// IsCodeSeparator reports whether a character may separate groups of a
+// displayed or entered user code
+func IsCodeSeparator(r rune) bool {
+	return unicode.IsSpace(r) || unicode.Is(unicode.Pd, r) || r == '.' || r == '_'
+}
+
+// CanonicalCode converts any display variant of a user code to the XXXX-XXXX
+// format codes are issued and validated in
+func CanonicalCode(code string) string {
+	return FormatCode(NormalizeCode(code))
 }
 
 // FormatCode converts a normalized code into the RFC 8628 display format (XXXX-XXXX)
@@ -139,3 +160,89 @@ func FormatCode(code string) string {
 	mid := len(code) / 2
 	return code[:mid] + "-" + code[mid:]
 }
+
+// DefaultDisplayFormat shows user codes as issued, in two groups of four
+const DefaultDisplayFormat DisplayFormat = "XXXX-XXXX"
+
+// displaySeparators lists the separators display formats may use
+const displaySeparators = "- ."
+
+// DisplayFormat lays out user codes for people, with an X for each character
+// of the code and a separator between groups, such as "XXXX XXXX" or
+// "XX-XX-XX-XX". Display formats only change how codes are shown; codes are
+// stored, and accepted on input, in every layout. The zero value is
+// DefaultDisplayFormat.
+type DisplayFormat string
+
+// ParseDisplayFormat checks a display format, returning DefaultDisplayFormat
+// for an empty one
+func ParseDisplayFormat(pattern string) (DisplayFormat, error) {
+	if pattern == "" {
+		return DefaultDisplayFormat, nil
+	}
+	if n := strings.Count(pattern, "X"); n != MinLength {
+		return "", fmt.Errorf("display format %q must have %d X characters, one per code character, not %d", pattern, MinLength, n)
+	}
+
+	var separator rune
+	for i, r := range pattern {
+		if r == 'X' {
+			continue
+		}
+		if !strings.ContainsRune(displaySeparators, r) {
+			return "", fmt.Errorf("display format %q may only separate groups with one of %q", pattern, displaySeparators)
+		}
+		if separator != 0 && r != separator {
+			return "", fmt.Errorf("display format %q must use a single separator", pattern)
+		}
+		separator = r
+		if i == 0 || i == len(pattern)-1 || pattern[i-1] != 'X' {
+			return "", errors.New("display format separators must sit between groups of X characters")
+		}
+	}
+	return DisplayFormat(pattern), nil
+}
+
+// pattern returns the format's pattern, DefaultDisplayFormat for the zero value
+func (f DisplayFormat) pattern() string {
+	if f == "" {
+		return string(DefaultDisplayFormat)
+	}
+	return string(f)
+}
+
+// Format lays out a user code, given in any variant. Codes of the wrong length
+// are returned unchanged.
+func (f DisplayFormat) Format(code string) string {
+	normalized := NormalizeCode(code)
+	if len(normalized) != MinLength {
+		return code
+	}
+
+	var b strings.Builder
+	next := 0
+	for _, r := range f.pattern() {
+		if r == 'X' {
+			b.WriteByte(normalized[next])
+			next++
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Separator returns the separator between groups, empty if codes are shown
+// as a single group
+func (f DisplayFormat) Separator() string {
+	pattern := f.pattern()
+	if i := strings.IndexFunc(pattern, func(r rune) bool { return r != 'X' }); i >= 0 {
+		return pattern[i : i+1]
+	}
+	return ""
+}
+
+// String returns the format's pattern, usable as an input placeholder
+func (f DisplayFormat) String() string {
+	return f.pattern()
+}
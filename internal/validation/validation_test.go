@@ -170,6 +170,21 @@ func TestNormalizeCode(t *testing.T) {
 			code: "BC-DH-KL-MN",
 			want: "BCDHKLMN",
 		},
+		{
+			name: "space separator",
+			code: "bcdh klmn",
+			want: "BCDHKLMN",
+		},
+		{
+			name: "typographic dash and dots",
+			code: "BCDH\u2013KL.MN",
+			want: "BCDHKLMN",
+		},
+		{
+			name: "non-breaking space",
+			code: "BCDH\u00a0KLMN",
+			want: "BCDHKLMN",
+		},
 	}
 
 	for _, tt := range tests {
@@ -217,3 +232,57 @@ func TestFormatCode(t *testing.T) {
 		})
 	}
 }
+
+func TestCanonicalCode(t *testing.T) {
+	for _, code := range []string{"BCDH-KLMN", "bcdh klmn", "BCDHKLMN", "BC.DH.KL.MN", " bcdh_klmn "} {
+		if got := CanonicalCode(code); got != "BCDH-KLMN" {
+			t.Errorf("CanonicalCode(%q) = %q, want %q", code, got, "BCDH-KLMN")
+		}
+		if err := ValidateUserCode(CanonicalCode(code)); err != nil {
+			t.Errorf("ValidateUserCode(CanonicalCode(%q)) failed: %v", code, err)
+		}
+	}
+}
+
+func TestDisplayFormat(t *testing.T) {
+	tests := []struct {
+		pattern   string
+		wantErr   bool
+		code      string
+		want      string
+		separator string
+	}{
+		{pattern: "", code: "BCDHKLMN", want: "BCDH-KLMN", separator: "-"},
+		{pattern: "XXXX XXXX", code: "bcdh-klmn", want: "BCDH KLMN", separator: " "},
+		{pattern: "XX.XX.XX.XX", code: "BCDH KLMN", want: "BC.DH.KL.MN", separator: "."},
+		{pattern: "XXXXXXXX", code: "BCDH-KLMN", want: "BCDHKLMN", separator: ""},
+		{pattern: "XXXX-XXXX", code: "BCD", want: "BCD", separator: "-"},
+		{pattern: "XXX-XXX", wantErr: true},
+		{pattern: "XXXX/XXXX", wantErr: true},
+		{pattern: "XX-XX XX-XX", wantErr: true},
+		{pattern: "-XXXXXXXX", wantErr: true},
+		{pattern: "XXXX--XXXX", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			format, err := ParseDisplayFormat(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDisplayFormat error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := format.Format(tt.code); got != tt.want {
+				t.Errorf("Format(%q) = %q, want %q", tt.code, got, tt.want)
+			}
+			if got := format.Separator(); got != tt.separator {
+				t.Errorf("Separator() = %q, want %q", got, tt.separator)
+			}
+			// Every display variant reads back as the same code
+			if NormalizeCode(format.Format(tt.code)) != NormalizeCode(tt.code) {
+				t.Errorf("NormalizeCode(Format(%q)) = %q", tt.code, NormalizeCode(format.Format(tt.code)))
+			}
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/wrale/oauth2-device-proxy/internal/redact"
 )
 
 // Configuration for integration tests
@@ -51,7 +53,7 @@ func (s *TestSuite) LogRequest(req *http.Request) error {
 	if err != nil {
 		return fmt.Errorf("dumping request: %w", err)
 	}
-	s.T.Logf("\n=== REQUEST ===\n%s\n", dump)
+	s.T.Logf("\n=== REQUEST ===\n%s\n", redact.Dump(dump))
 	return nil
 }
 
@@ -61,7 +63,7 @@ func (s *TestSuite) LogResponse(resp *http.Response) error {
 	if err != nil {
 		return fmt.Errorf("dumping response: %w", err)
 	}
-	s.T.Logf("\n=== RESPONSE ===\n%s\n", dump)
+	s.T.Logf("\n=== RESPONSE ===\n%s\n", redact.Dump(dump))
 	return nil
 }
 
@@ -101,8 +103,8 @@ func (s *TestSuite) ReadBody(resp *http.Response) ([]byte, error) {
 		return nil, fmt.Errorf("closing response body: %w", err)
 	}
 
-	// Log body content
-	s.T.Logf("\n=== BODY ===\n%s\n", body)
+	// Log body content without codes or tokens
+	s.T.Logf("\n=== BODY ===\n%s\n", redact.Body(resp.Header.Get("Content-Type"), body))
 
 	// Replace body for future reads
 	resp.Body = io.NopCloser(bytes.NewReader(body))